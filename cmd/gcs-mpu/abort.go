@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+)
+
+// Actions taken on an upload by abort and cleanup.
+const (
+	actionAborted    = "aborted"
+	actionWouldAbort = "would_abort"
+	actionFailed     = "failed"
+)
+
+// abortRecord is the outcome of aborting, or not, an upload.
+type abortRecord struct {
+	Bucket    string     `json:"bucket"`
+	Key       string     `json:"key"`
+	UploadID  string     `json:"upload_id"`
+	Initiated *time.Time `json:"initiated,omitempty"`
+	Action    string     `json:"action"`
+	Error     string     `json:"error,omitempty"`
+}
+
+func runAbort(ctx context.Context, e *env, args []string) error {
+	args, err := parseArgs(newFlagSet(e, "abort"), args, 2)
+	if err != nil {
+		return err
+	}
+	ref, err := parseGSURL(args[0], false)
+	if err != nil {
+		return err
+	}
+
+	mpuc, err := e.client(ctx)
+	if err != nil {
+		return err
+	}
+	err = mpuc.AbortMultipartUpload(ctx, &multipartclient.AbortMultipartUploadRequest{
+		Bucket:   ref.Bucket,
+		Key:      ref.Key,
+		UploadID: args[1],
+	})
+	if err != nil {
+		return err
+	}
+
+	out := e.output(false)
+	if !out.structured() {
+		fmt.Fprintf(e.stdout, "aborted upload %s of %s\n", args[1], gsURL(ref))
+		return nil
+	}
+	if err := out.record(abortRecord{Bucket: ref.Bucket, Key: ref.Key, UploadID: args[1], Action: actionAborted}); err != nil {
+		return err
+	}
+	return out.close()
+}
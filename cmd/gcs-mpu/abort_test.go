@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multiparttest"
+)
+
+func TestAbort(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	mpuc := multipartclient.New(srv.Client())
+	initResult, err := mpuc.InitiateMultipartUpload(context.Background(), &multipartclient.InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "file1.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	te := newTestEnv(srv.Client())
+	te.run(t, 0, "abort", "gs://bucket1/file1.txt", initResult.UploadID)
+	if uploads := srv.Uploads(); len(uploads) != 0 {
+		t.Errorf("got uploads %v after abort, want none", uploads)
+	}
+
+	// Aborting again fails, since the upload no longer exists.
+	te = newTestEnv(srv.Client())
+	te.run(t, 1, "abort", "gs://bucket1/file1.txt", initResult.UploadID)
+}
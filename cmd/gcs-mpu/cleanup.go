@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+)
+
+func runCleanup(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "cleanup")
+	bucket := fs.String("bucket", "", "bucket to clean up (required)")
+	prefix := fs.String("prefix", "", "only clean up uploads of objects whose names begin with this prefix")
+	olderThanFlag := fs.String("older-than", "7d", "only clean up uploads initiated longer ago than this, e.g. 7d, 36h or 1d12h")
+	dryRun := fs.Bool("dry-run", false, "list the uploads that would be aborted without aborting them")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if *bucket == "" {
+		return usageErrorf("-bucket is required")
+	}
+	olderThan, err := parseAge(*olderThanFlag)
+	if err != nil {
+		return usageErrorf("invalid -older-than: %v", err)
+	}
+
+	mpuc, err := e.client(ctx)
+	if err != nil {
+		return err
+	}
+	uploads, err := listAll(mpuc.Uploads(ctx, &multipartclient.ListMultipartUploadsRequest{
+		Bucket: *bucket,
+		Prefix: *prefix,
+	}))
+	if err != nil {
+		return err
+	}
+
+	out := e.output(true)
+	now := e.now()
+	var stale, aborted, failed int
+	for _, u := range uploads {
+		age := now.Sub(u.Initiated)
+		if age <= olderThan {
+			continue
+		}
+		stale++
+		ref := multipartclient.ObjectRef{Bucket: *bucket, Key: u.Key}
+		rec := abortRecord{Bucket: *bucket, Key: u.Key, UploadID: u.UploadID, Initiated: &u.Initiated}
+		switch {
+		case *dryRun:
+			rec.Action = actionWouldAbort
+			if !out.structured() {
+				fmt.Fprintf(e.stdout, "would abort %s upload %s, initiated %s ago\n", gsURL(ref), u.UploadID, formatAge(age))
+			}
+		default:
+			err := mpuc.AbortMultipartUpload(ctx, &multipartclient.AbortMultipartUploadRequest{
+				Bucket:   *bucket,
+				Key:      u.Key,
+				UploadID: u.UploadID,
+			})
+			if err != nil {
+				failed++
+				rec.Action, rec.Error = actionFailed, err.Error()
+				if !out.structured() {
+					fmt.Fprintf(e.stderr, "failed to abort %s upload %s: %v\n", gsURL(ref), u.UploadID, err)
+				}
+				break
+			}
+			aborted++
+			rec.Action = actionAborted
+			if !out.structured() {
+				fmt.Fprintf(e.stdout, "aborted %s upload %s, initiated %s ago\n", gsURL(ref), u.UploadID, formatAge(age))
+			}
+		}
+		if out.structured() {
+			if err := out.record(rec); err != nil {
+				return err
+			}
+		}
+	}
+
+	if out.structured() {
+		if err := out.close(); err != nil {
+			return err
+		}
+		if failed > 0 {
+			return fmt.Errorf("failed to abort %d uploads", failed)
+		}
+		return nil
+	}
+	if *dryRun {
+		fmt.Fprintf(e.stdout, "%d of %d uploads are older than %s; none aborted (dry run)\n", stale, len(uploads), *olderThanFlag)
+		return nil
+	}
+	fmt.Fprintf(e.stdout, "%d of %d uploads are older than %s; aborted %d, failed %d\n", stale, len(uploads), *olderThanFlag, aborted, failed)
+	if failed > 0 {
+		return fmt.Errorf("failed to abort %d uploads", failed)
+	}
+	return nil
+}
+
+// parseAge parses a duration as accepted by time.ParseDuration, optionally
+// preceded by a number of days, e.g. "7d" or "1d12h".
+func parseAge(s string) (time.Duration, error) {
+	var age time.Duration
+	if days, rest, ok := strings.Cut(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid number of days in %q", s)
+		}
+		age = time.Duration(n) * 24 * time.Hour
+		s = rest
+		if s == "" {
+			return age, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative duration %q", s)
+	}
+	return age + d, nil
+}
+
+// formatAge formats d in days and hours, rounded down to the hour.
+func formatAge(d time.Duration) string {
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	if days == 0 {
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dd%dh", days, hours)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multipartclienttest"
+)
+
+// listResponse lists an upload of logs/old.txt initiated 8 days before
+// testNow, logs/new.txt 1 day before and logs/older.txt 30 days before.
+func listResponse() *http.Response {
+	return multipartclienttest.XMLResponse(http.StatusOK, `<ListMultipartUploadsResult>
+  <Upload><Key>logs/old.txt</Key><UploadId>upload-1</UploadId><Initiated>2024-03-02T12:00:00.000Z</Initiated></Upload>
+  <Upload><Key>logs/new.txt</Key><UploadId>upload-2</UploadId><Initiated>2024-03-09T12:00:00.000Z</Initiated></Upload>
+  <Upload><Key>logs/older.txt</Key><UploadId>upload-3</UploadId><Initiated>2024-02-09T06:00:00.000Z</Initiated></Upload>
+</ListMultipartUploadsResult>`)
+}
+
+func TestCleanup(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		responses    []*http.Response
+		wantStatus   int
+		wantRequests []string
+		wantStdout   string
+	}{
+		{
+			name:      "Abort stale uploads",
+			args:      []string{"-bucket", "bucket1", "-prefix", "logs/", "-older-than", "7d"},
+			responses: []*http.Response{listResponse(), multipartclienttest.AbortResponse(), multipartclienttest.AbortResponse()},
+			wantRequests: []string{
+				"GET /bucket1/?uploads&prefix=logs%2F HTTP/1.1",
+				"DELETE /bucket1/logs/old.txt?uploadId=upload-1 HTTP/1.1",
+				"DELETE /bucket1/logs/older.txt?uploadId=upload-3 HTTP/1.1",
+			},
+			wantStdout: "aborted gs://bucket1/logs/old.txt upload upload-1, initiated 8d0h ago\n" +
+				"aborted gs://bucket1/logs/older.txt upload upload-3, initiated 30d6h ago\n" +
+				"2 of 3 uploads are older than 7d; aborted 2, failed 0\n",
+		},
+		{
+			name:      "Dry run",
+			args:      []string{"--bucket", "bucket1", "--older-than", "10d", "--dry-run"},
+			responses: []*http.Response{listResponse()},
+			wantRequests: []string{
+				"GET /bucket1/?uploads HTTP/1.1",
+			},
+			wantStdout: "would abort gs://bucket1/logs/older.txt upload upload-3, initiated 30d6h ago\n" +
+				"1 of 3 uploads are older than 10d; none aborted (dry run)\n",
+		},
+		{
+			name: "Abort failure",
+			args: []string{"-bucket", "bucket1", "-older-than", "12h"},
+			responses: []*http.Response{
+				listResponse(),
+				multipartclienttest.AbortResponse(),
+				multipartclienttest.ErrorResponse(http.StatusForbidden, "AccessDenied", "Access denied."),
+				multipartclienttest.AbortResponse(),
+			},
+			wantStatus: 1,
+			wantRequests: []string{
+				"GET /bucket1/?uploads HTTP/1.1",
+				"DELETE /bucket1/logs/old.txt?uploadId=upload-1 HTTP/1.1",
+				"DELETE /bucket1/logs/new.txt?uploadId=upload-2 HTTP/1.1",
+				"DELETE /bucket1/logs/older.txt?uploadId=upload-3 HTTP/1.1",
+			},
+			wantStdout: "aborted gs://bucket1/logs/old.txt upload upload-1, initiated 8d0h ago\n" +
+				"aborted gs://bucket1/logs/older.txt upload upload-3, initiated 30d6h ago\n" +
+				"3 of 3 uploads are older than 12h; aborted 2, failed 1\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			trans := multipartclienttest.NewTransport(t)
+			for _, resp := range tc.responses {
+				trans.Respond(resp)
+			}
+
+			te := newTestEnv(trans.Client())
+			te.run(t, tc.wantStatus, append([]string{"cleanup"}, tc.args...)...)
+
+			trans.AssertRequestLines(t, tc.wantRequests...)
+			if diff := cmp.Diff(tc.wantStdout, te.stdout.String()); diff != "" {
+				t.Errorf("unexpected diff for output: (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCleanupUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{"cleanup"},
+		{"cleanup", "-bucket", "bucket1", "-older-than", "soon"},
+		{"cleanup", "-bucket", "bucket1", "extra"},
+	} {
+		te := newTestEnv(nil)
+		te.run(t, 2, args...)
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "7d", want: 7 * 24 * time.Hour},
+		{in: "36h", want: 36 * time.Hour},
+		{in: "1d12h", want: 36 * time.Hour},
+		{in: "0d", want: 0},
+		{in: "xd", wantErr: true},
+		{in: "-1h", wantErr: true},
+		{in: "week", wantErr: true},
+	}
+	for _, tc := range tests {
+		got, err := parseAge(tc.in)
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("parseAge(%q) = %v, %v; want %v, error %v", tc.in, got, err, tc.want, tc.wantErr)
+		}
+	}
+}
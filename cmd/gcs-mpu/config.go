@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+	"gopkg.in/yaml.v3"
+)
+
+// configFileName is the name of the config file in the home directory.
+const configFileName = ".gcs-mpu.yaml"
+
+// Environment variables read by gcs-mpu. Each but envConfig overrides the
+// config file setting of the same name.
+const (
+	envConfig      = "GCS_MPU_CONFIG"
+	envEndpoint    = "GCS_MPU_ENDPOINT"
+	envProject     = "GCS_MPU_PROJECT"
+	envCredentials = "GCS_MPU_CREDENTIALS"
+	envPartSize    = "GCS_MPU_PART_SIZE"
+	envConcurrency = "GCS_MPU_CONCURRENCY"
+)
+
+// config holds the settings shared by all commands, from the config file and
+// the environment. Zero values are unset.
+type config struct {
+	// Endpoint is the base URL requests are sent to instead of
+	// https://storage.googleapis.com, e.g. for an emulator or a private
+	// endpoint.
+	Endpoint string `yaml:"endpoint"`
+	// Project is billed for requests, as the x-goog-user-project header.
+	Project string `yaml:"project"`
+	// Credentials is the path of a service account key or other credentials
+	// file used instead of Application Default Credentials.
+	Credentials string `yaml:"credentials"`
+	// PartSize and Concurrency are the defaults of the -part-size and
+	// -concurrency flags.
+	PartSize    int64 `yaml:"part_size"`
+	Concurrency int   `yaml:"concurrency"`
+}
+
+// loadConfig reads the config file, which is $GCS_MPU_CONFIG if set and
+// otherwise $HOME/.gcs-mpu.yaml if it exists, and applies the environment
+// variables over it.
+func loadConfig(getenv func(string) string) (*config, error) {
+	cfg := &config{}
+	path, optional := getenv(envConfig), false
+	if path == "" && getenv("HOME") != "" {
+		path, optional = filepath.Join(getenv("HOME"), configFileName), true
+	}
+	if path != "" {
+		b, err := os.ReadFile(path)
+		switch {
+		case optional && errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return nil, fmt.Errorf("failed to read config: %w", err)
+		default:
+			dec := yaml.NewDecoder(bytes.NewReader(b))
+			dec.KnownFields(true)
+			if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+			}
+		}
+	}
+
+	for name, dst := range map[string]*string{
+		envEndpoint:    &cfg.Endpoint,
+		envProject:     &cfg.Project,
+		envCredentials: &cfg.Credentials,
+	} {
+		if v := getenv(name); v != "" {
+			*dst = v
+		}
+	}
+	if v := getenv(envPartSize); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", envPartSize, err)
+		}
+		cfg.PartSize = n
+	}
+	if v := getenv(envConcurrency); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", envConcurrency, err)
+		}
+		cfg.Concurrency = n
+	}
+
+	if cfg.Endpoint != "" {
+		u, err := url.Parse(cfg.Endpoint)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid endpoint %q, want a URL like https://host:port", cfg.Endpoint)
+		}
+	}
+	if cfg.PartSize < 0 || cfg.Concurrency < 0 {
+		return nil, fmt.Errorf("part_size and concurrency must be positive, got %d and %d", cfg.PartSize, cfg.Concurrency)
+	}
+	return cfg, nil
+}
+
+// partSize returns the default of the -part-size flag.
+func (c *config) partSize() int64 {
+	if c.PartSize > 0 {
+		return c.PartSize
+	}
+	return defaultPartSize
+}
+
+// concurrency returns the default of the -concurrency flag.
+func (c *config) concurrency() int {
+	if c.Concurrency > 0 {
+		return c.Concurrency
+	}
+	return defaultConcurrency
+}
+
+// options returns the client options of the configured endpoint, if set.
+func (c *config) options() []multipartclient.Option {
+	if c.Endpoint == "" {
+		return nil
+	}
+	return []multipartclient.Option{multipartclient.WithEndpoint(c.Endpoint)}
+}
+
+// wrap returns hc, billing the configured project if it is set.
+func (c *config) wrap(hc *http.Client) *http.Client {
+	if c.Project == "" {
+		return hc
+	}
+	base := hc.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	wrapped := *hc
+	wrapped.Transport = &projectTransport{base: base, project: c.Project}
+	return &wrapped
+}
+
+// projectTransport bills requests to the configured project.
+type projectTransport struct {
+	base    http.RoundTripper
+	project string
+}
+
+func (t *projectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("x-goog-user-project", t.project)
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multiparttest"
+)
+
+// mapGetenv returns a getenv looking up vars.
+func mapGetenv(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+func TestLoadConfig(t *testing.T) {
+	home := t.TempDir()
+	if err := os.WriteFile(filepath.Join(home, configFileName), []byte(`
+endpoint: https://storage.example.com
+project: project1
+part_size: 67108864
+concurrency: 8
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(t.TempDir(), "other.yaml")
+	if err := os.WriteFile(other, []byte("concurrency: 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	invalid := filepath.Join(t.TempDir(), "invalid.yaml")
+	if err := os.WriteFile(invalid, []byte("concurency: 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    *config
+		wantErr string
+	}{
+		{
+			name: "No config",
+			env:  map[string]string{"HOME": t.TempDir()},
+			want: &config{},
+		},
+		{
+			name: "Home config",
+			env:  map[string]string{"HOME": home},
+			want: &config{Endpoint: "https://storage.example.com", Project: "project1", PartSize: 64 << 20, Concurrency: 8},
+		},
+		{
+			name: "Environment overrides config",
+			env: map[string]string{
+				"HOME":              home,
+				envProject:          "project2",
+				envCredentials:      "/etc/key.json",
+				envPartSize:         "1024",
+				"GCS_MPU_UNRELATED": "x",
+			},
+			want: &config{Endpoint: "https://storage.example.com", Project: "project2", Credentials: "/etc/key.json", PartSize: 1024, Concurrency: 8},
+		},
+		{
+			name: "Part size over 4 GiB",
+			env:  map[string]string{envPartSize: "5368709120"},
+			want: &config{PartSize: 5 << 30},
+		},
+		{
+			name: "Config from environment",
+			env:  map[string]string{"HOME": home, envConfig: other},
+			want: &config{Concurrency: 2},
+		},
+		{
+			name:    "Missing config from environment",
+			env:     map[string]string{envConfig: filepath.Join(home, "missing.yaml")},
+			wantErr: "failed to read config",
+		},
+		{
+			name:    "Unknown setting",
+			env:     map[string]string{envConfig: invalid},
+			wantErr: "field concurency not found",
+		},
+		{
+			name:    "Invalid number",
+			env:     map[string]string{envConcurrency: "many"},
+			wantErr: "invalid GCS_MPU_CONCURRENCY",
+		},
+		{
+			name:    "Invalid endpoint",
+			env:     map[string]string{envEndpoint: "storage.example.com"},
+			wantErr: `invalid endpoint "storage.example.com"`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := loadConfig(mapGetenv(tc.env))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("got error %v, want it to contain %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected diff for config: (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// recordTransport records the requests sent through it.
+type recordTransport struct {
+	reqs []*http.Request
+}
+
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.reqs = append(t.reqs, req)
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+}
+
+func TestConfigWrap(t *testing.T) {
+	rt := &recordTransport{}
+	cfg := &config{Project: "project1"}
+	hc := cfg.wrap(&http.Client{Transport: rt})
+
+	resp, err := hc.Get("https://storage.googleapis.com/bucket1/dir/file1.txt?uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if len(rt.reqs) != 1 {
+		t.Fatalf("got %d requests, want 1", len(rt.reqs))
+	}
+	if got := rt.reqs[0].Header.Get("x-goog-user-project"); got != "project1" {
+		t.Errorf("got x-goog-user-project %q, want %q", got, "project1")
+	}
+}
+
+func TestConfigEndpoint(t *testing.T) {
+	rt := &recordTransport{}
+	cfg := &config{Endpoint: "http://localhost:9000/storage/"}
+	mpuc := multipartclient.New(cfg.wrap(&http.Client{Transport: rt}), cfg.options()...)
+	// The empty response doesn't decode, but only the request matters.
+	_, _ = mpuc.ListMultipartUploads(context.Background(), &multipartclient.ListMultipartUploadsRequest{Bucket: "bucket1", Prefix: "dir/"})
+
+	if len(rt.reqs) != 1 {
+		t.Fatalf("got %d requests, want 1", len(rt.reqs))
+	}
+	if got, want := rt.reqs[0].URL.String(), "http://localhost:9000/storage/bucket1/?uploads&prefix=dir%2F"; got != want {
+		t.Errorf("got URL %q, want %q", got, want)
+	}
+}
+
+func TestConfigFromEnvironment(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	data := partsData(3)
+	path := writeTempFile(t, data)
+
+	// Requests reach the server only through the configured endpoint.
+	te := newTestEnv(&http.Client{})
+	te.getenv = mapGetenv(map[string]string{
+		envEndpoint: srv.URL(),
+		envPartSize: strconv.Itoa(2 * multipartclient.MinPartSize),
+	})
+	te.run(t, 0, "upload", path, "gs://bucket1/file1.txt")
+
+	if !strings.Contains(te.stdout.String(), "in 2 parts") {
+		t.Errorf("got stdout %q, want 2 parts of the configured size", te.stdout.String())
+	}
+	if got, _ := srv.Object("bucket1", "file1.txt"); string(got) != data {
+		t.Errorf("got object of %d bytes, want %d bytes", len(got), len(data))
+	}
+
+	// Flags override the configuration.
+	te.stdout.Reset()
+	te.run(t, 0, "upload", "-part-size", testPartSize, path, "gs://bucket1/file1.txt")
+	if !strings.Contains(te.stdout.String(), "in 3 parts") {
+		t.Errorf("got stdout %q, want 3 parts of the flag's size", te.stdout.String())
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	te := newTestEnv(nil)
+	te.getenv = mapGetenv(map[string]string{envPartSize: "-1"})
+	te.run(t, 1, "list-uploads", "gs://bucket1")
+	if !strings.Contains(te.stderr.String(), "must be positive") {
+		t.Errorf("got stderr %q, want it to report the invalid part size", te.stderr.String())
+	}
+}
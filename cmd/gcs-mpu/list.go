@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+	"google.golang.org/api/iterator"
+)
+
+// listUploadRecord is an upload listed by list-uploads.
+type listUploadRecord struct {
+	Bucket    string    `json:"bucket"`
+	Key       string    `json:"key"`
+	UploadID  string    `json:"upload_id"`
+	Initiated time.Time `json:"initiated"`
+}
+
+// partRecord is a part listed by list-parts.
+type partRecord struct {
+	PartNumber int    `json:"part_number"`
+	ETag       string `json:"etag"`
+}
+
+func runListUploads(ctx context.Context, e *env, args []string) error {
+	args, err := parseArgs(newFlagSet(e, "list-uploads"), args, 1)
+	if err != nil {
+		return err
+	}
+	ref, err := parseGSURL(args[0], true)
+	if err != nil {
+		return err
+	}
+	if ref.Key != "" {
+		return usageErrorf("%q must name a bucket, not an object", args[0])
+	}
+
+	mpuc, err := e.client(ctx)
+	if err != nil {
+		return err
+	}
+	uploads, err := listAll(mpuc.Uploads(ctx, &multipartclient.ListMultipartUploadsRequest{Bucket: ref.Bucket}))
+	if err != nil {
+		return err
+	}
+	out := e.output(true)
+	if out.structured() {
+		for _, u := range uploads {
+			if err := out.record(listUploadRecord{Bucket: ref.Bucket, Key: u.Key, UploadID: u.UploadID, Initiated: u.Initiated}); err != nil {
+				return err
+			}
+		}
+		return out.close()
+	}
+	tw := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tUPLOAD ID")
+	for _, u := range uploads {
+		fmt.Fprintf(tw, "%s\t%s\n", u.Key, u.UploadID)
+	}
+	return tw.Flush()
+}
+
+func runListParts(ctx context.Context, e *env, args []string) error {
+	args, err := parseArgs(newFlagSet(e, "list-parts"), args, 2)
+	if err != nil {
+		return err
+	}
+	ref, err := parseGSURL(args[0], false)
+	if err != nil {
+		return err
+	}
+
+	mpuc, err := e.client(ctx)
+	if err != nil {
+		return err
+	}
+	parts, err := listAll(mpuc.Parts(ctx, &multipartclient.ListObjectPartsRequest{
+		Bucket:   ref.Bucket,
+		Key:      ref.Key,
+		UploadID: args[1],
+	}))
+	if err != nil {
+		return err
+	}
+	out := e.output(true)
+	if out.structured() {
+		for _, p := range parts {
+			if err := out.record(partRecord{PartNumber: p.PartNumber, ETag: p.ETag}); err != nil {
+				return err
+			}
+		}
+		return out.close()
+	}
+	tw := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PART\tETAG")
+	for _, p := range parts {
+		fmt.Fprintf(tw, "%d\t%s\n", p.PartNumber, p.ETag)
+	}
+	return tw.Flush()
+}
+
+// listAll returns every item of it, an UploadIterator or a PartIterator.
+func listAll[T any](it interface{ Next() (*T, error) }) ([]T, error) {
+	var items []T
+	for {
+		item, err := it.Next()
+		if err == iterator.Done {
+			return items, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+}
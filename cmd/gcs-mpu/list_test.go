@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multipartclienttest"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multiparttest"
+)
+
+func TestListUploads(t *testing.T) {
+	trans := multipartclienttest.NewTransport(t).Respond(multipartclienttest.XMLResponse(http.StatusOK, `<ListMultipartUploadsResult>
+  <Upload><Key>dir/file1.txt</Key><UploadId>upload-1</UploadId></Upload>
+  <Upload><Key>file2.txt</Key><UploadId>upload-2</UploadId></Upload>
+</ListMultipartUploadsResult>`))
+
+	te := newTestEnv(trans.Client())
+	te.run(t, 0, "list-uploads", "gs://bucket1")
+
+	want := "KEY            UPLOAD ID\n" +
+		"dir/file1.txt  upload-1\n" +
+		"file2.txt      upload-2\n"
+	if diff := cmp.Diff(want, te.stdout.String()); diff != "" {
+		t.Errorf("unexpected diff for output: (-want, +got):\n%s", diff)
+	}
+	trans.AssertRequestLines(t, "GET /bucket1/?uploads HTTP/1.1")
+}
+
+func TestListParts(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	mpuc := multipartclient.New(srv.Client())
+	ctx := context.Background()
+	initResult, err := mpuc.InitiateMultipartUpload(ctx, &multipartclient.InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "file1.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, partNumber := range []int{2, 1} {
+		_, err := mpuc.UploadObjectPart(ctx, &multipartclient.UploadObjectPartRequest{
+			Bucket:     "bucket1",
+			Key:        "file1.txt",
+			PartNumber: partNumber,
+			UploadID:   initResult.UploadID,
+			Body:       io.NopCloser(strings.NewReader("part")),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	te := newTestEnv(srv.Client())
+	te.run(t, 0, "list-parts", "gs://bucket1/file1.txt", initResult.UploadID)
+
+	// The MD5 of "part".
+	want := "PART  ETAG\n" +
+		"1     \"f4c9385f1902f7334b00b9b4ecd164de\"\n" +
+		"2     \"f4c9385f1902f7334b00b9b4ecd164de\"\n"
+	if diff := cmp.Diff(want, te.stdout.String()); diff != "" {
+		t.Errorf("unexpected diff for output: (-want, +got):\n%s", diff)
+	}
+}
+
+func TestListUploadsRejectsObject(t *testing.T) {
+	te := newTestEnv(nil)
+	te.run(t, 2, "list-uploads", "gs://bucket1/file1.txt")
+}
@@ -0,0 +1,257 @@
+// Command gcs-mpu drives and inspects Cloud Storage XML multipart uploads.
+//
+// Usage:
+//
+//	gcs-mpu upload [-part-size N] [-chunking fixed|content] [-concurrency N] [-auto-tune] [-quiet] FILE|- gs://BUCKET/KEY
+//	gcs-mpu sync [-checksum] [-dry-run] [-part-size N] [-concurrency N] [-quiet] DIR gs://BUCKET[/PREFIX]
+//	gcs-mpu list-uploads gs://BUCKET
+//	gcs-mpu list-parts gs://BUCKET/KEY UPLOAD_ID
+//	gcs-mpu abort gs://BUCKET/KEY UPLOAD_ID
+//	gcs-mpu cleanup -bucket BUCKET [-prefix PREFIX] [-older-than 7d] [-dry-run]
+//
+// Every command accepts -format=json or -format=ndjson to write its results
+// as JSON records for scripts; errors are then written to stderr as records
+// too.
+//
+// upload sends -concurrency parts at once. With -auto-tune it picks the part
+// size from the file size, and the concurrency by doubling it from one while
+// that raises throughput. A FILE of - uploads stdin, of unknown length, so
+// that pipelines like
+//
+//	pg_dump mydb | gcs-mpu upload - gs://backups/mydb.sql
+//
+// can stream to Cloud Storage; such a stream may be at most 10000 times
+// -part-size bytes long.
+//
+// With -chunking content, upload cuts parts at boundaries that depend on the
+// data rather than every -part-size bytes, so an edit to the input changes
+// only the parts around it. The parts average -part-size bytes and are read
+// into memory, even from a file.
+//
+// sync uploads the files under DIR that are missing from PREFIX, or whose
+// objects differ in size or are older than the files; with -checksum, whose
+// objects differ in size or CRC32C.
+//
+// Requests are authorized with Application Default Credentials. Progress is
+// drawn on stderr when it is a terminal.
+//
+// Settings shared by fleets of machines can be kept in $HOME/.gcs-mpu.yaml,
+// or the file named by $GCS_MPU_CONFIG:
+//
+//	endpoint: https://storage.example.com  # instead of storage.googleapis.com
+//	project: my-project                     # billed for requests
+//	credentials: /etc/gcs-mpu/key.json      # instead of ADC
+//	part_size: 67108864                     # default of -part-size
+//	concurrency: 8                          # default of -concurrency
+//
+// Each setting is overridden by the environment variable GCS_MPU_ and its
+// name in upper case, such as GCS_MPU_PART_SIZE, and flags override both.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+)
+
+// env is what commands use from outside the process, replaced in tests.
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	// httpClient returns the client requests are sent with.
+	httpClient func(ctx context.Context) (*http.Client, error)
+	now        func() time.Time
+	// interactive is set if stderr is a terminal, so progress can be drawn
+	// on it.
+	interactive bool
+	// format is the output format set with -format.
+	format string
+	getenv func(string) string
+	// cfg is loaded by run before the command runs.
+	cfg *config
+}
+
+// client returns a MultipartClient sending requests with e.httpClient.
+func (e *env) client(ctx context.Context) (*multipartclient.MultipartClient, error) {
+	hc, err := e.httpClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+	return multipartclient.New(e.cfg.wrap(hc), e.cfg.options()...), nil
+}
+
+// command is a gcs-mpu subcommand.
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, e *env, args []string) error
+}
+
+var commands = []command{
+	{name: "upload", usage: "upload [-part-size N] [-chunking fixed|content] [-concurrency N] [-auto-tune] [-quiet] FILE|- gs://BUCKET/KEY", run: runUpload},
+	{name: "sync", usage: "sync [-checksum] [-dry-run] [-part-size N] [-concurrency N] [-quiet] DIR gs://BUCKET[/PREFIX]", run: runSync},
+	{name: "list-uploads", usage: "list-uploads gs://BUCKET", run: runListUploads},
+	{name: "list-parts", usage: "list-parts gs://BUCKET/KEY UPLOAD_ID", run: runListParts},
+	{name: "abort", usage: "abort gs://BUCKET/KEY UPLOAD_ID", run: runAbort},
+	{name: "cleanup", usage: "cleanup -bucket BUCKET [-prefix PREFIX] [-older-than 7d] [-dry-run]", run: runCleanup},
+}
+
+// usageError is returned for invalid command lines.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func usageErrorf(format string, args ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// errUsageReported is returned for invalid command lines that the flag
+// package has already reported.
+var errUsageReported = errors.New("invalid usage")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	e := &env{
+		stdin:       os.Stdin,
+		stdout:      os.Stdout,
+		stderr:      os.Stderr,
+		now:         time.Now,
+		interactive: isTerminal(os.Stderr),
+		getenv:      os.Getenv,
+	}
+	e.httpClient = func(ctx context.Context) (*http.Client, error) {
+		return newHTTPClient(ctx, e.cfg)
+	}
+	os.Exit(run(ctx, e, os.Args[1:]))
+}
+
+// newHTTPClient returns a client authorized with the credentials file of cfg,
+// or with Application Default Credentials if it has none. Credentials are
+// detected as by other Google API clients, so service account impersonation,
+// workload identity federation and self-signed JWTs work too.
+func newHTTPClient(ctx context.Context, cfg *config) (*http.Client, error) {
+	opts := []option.ClientOption{option.WithScopes(multipartclient.ScopeReadWrite)}
+	if cfg.Credentials != "" {
+		if _, err := os.Stat(cfg.Credentials); err != nil {
+			return nil, err
+		}
+		opts = append(opts, option.WithCredentialsFile(cfg.Credentials))
+	}
+	hc, _, err := htransport.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid credentials: %w", err)
+	}
+	return hc, nil
+}
+
+// run runs the command line args and returns the exit status: 0 on success,
+// 2 for invalid usage and 1 for other failures.
+func run(ctx context.Context, e *env, args []string) int {
+	if len(args) == 0 {
+		printUsage(e.stderr)
+		return 2
+	}
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		cfg, err := loadConfig(e.getenv)
+		if err == nil {
+			e.cfg = cfg
+			err = cmd.run(ctx, e, args[1:])
+		}
+		var usageErr *usageError
+		switch {
+		case err == nil:
+			return 0
+		case errors.Is(err, errUsageReported):
+			return 2
+		case errors.As(err, &usageErr):
+			fmt.Fprintf(e.stderr, "gcs-mpu %s: %v\nusage: gcs-mpu %s\n", cmd.name, err, cmd.usage)
+			return 2
+		case e.format == formatJSON || e.format == formatNDJSON:
+			// Keep stdout parseable; the error goes to stderr as a record.
+			_ = json.NewEncoder(e.stderr).Encode(errorRecord{Command: cmd.name, Error: err.Error()})
+			return 1
+		default:
+			fmt.Fprintf(e.stderr, "gcs-mpu %s: %v\n", cmd.name, err)
+			return 1
+		}
+	}
+	fmt.Fprintf(e.stderr, "gcs-mpu: unknown command %q\n", args[0])
+	printUsage(e.stderr)
+	return 2
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  gcs-mpu %s\n", cmd.usage)
+	}
+}
+
+// newFlagSet returns a flag set for the command that reports errors instead
+// of exiting, with the -format flag common to all commands.
+func newFlagSet(e *env, name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("gcs-mpu "+name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Func("format", "output format: text, json or ndjson (default text)", parseFormat(e))
+	return fs
+}
+
+// parseArgs parses the flags of fs from args and checks that n positional
+// arguments remain.
+func parseArgs(fs *flag.FlagSet, args []string, n int) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		// Parse has printed the error, or the help for -h, and the usage.
+		return nil, errUsageReported
+	}
+	if fs.NArg() != n {
+		return nil, usageErrorf("got %d arguments, want %d", fs.NArg(), n)
+	}
+	return fs.Args(), nil
+}
+
+// parseGSURL parses a gs://BUCKET/KEY URL. The key may be empty only if
+// keyOptional is set.
+func parseGSURL(s string, keyOptional bool) (multipartclient.ObjectRef, error) {
+	rest, ok := strings.CutPrefix(s, "gs://")
+	if !ok {
+		return multipartclient.ObjectRef{}, usageErrorf("%q is not a gs:// URL", s)
+	}
+	bucket, key, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return multipartclient.ObjectRef{}, usageErrorf("%q has no bucket", s)
+	}
+	if key == "" && !keyOptional {
+		return multipartclient.ObjectRef{}, usageErrorf("%q has no object name", s)
+	}
+	return multipartclient.ObjectRef{Bucket: bucket, Key: key}, nil
+}
+
+func gsURL(ref multipartclient.ObjectRef) string {
+	return fmt.Sprintf("gs://%s/%s", ref.Bucket, ref.Key)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+)
+
+// testNow is the current time in tests.
+var testNow = time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+// testEnv is an env sending requests with hc and capturing output.
+type testEnv struct {
+	env
+	stdout, stderr bytes.Buffer
+}
+
+func newTestEnv(hc *http.Client) *testEnv {
+	te := &testEnv{}
+	te.env = env{
+		stdout: &te.stdout,
+		stderr: &te.stderr,
+		httpClient: func(ctx context.Context) (*http.Client, error) {
+			return hc, nil
+		},
+		now:    func() time.Time { return testNow },
+		getenv: func(string) string { return "" },
+	}
+	return te
+}
+
+// run runs args and fails the test if the exit status is not wantStatus.
+func (te *testEnv) run(t *testing.T, wantStatus int, args ...string) {
+	t.Helper()
+	if got := run(context.Background(), &te.env, args); got != wantStatus {
+		t.Fatalf("gcs-mpu %s: got exit status %d, want %d\nstdout:\n%s\nstderr:\n%s",
+			strings.Join(args, " "), got, wantStatus, te.stdout.String(), te.stderr.String())
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantStderr string
+	}{
+		{
+			name:       "No command",
+			wantStderr: "usage:",
+		},
+		{
+			name:       "Unknown command",
+			args:       []string{"frobnicate"},
+			wantStderr: `unknown command "frobnicate"`,
+		},
+		{
+			name:       "Wrong argument count",
+			args:       []string{"abort", "gs://bucket1/file1.txt"},
+			wantStderr: "got 1 arguments, want 2\nusage: gcs-mpu abort gs://BUCKET/KEY UPLOAD_ID",
+		},
+		{
+			name:       "Unknown flag",
+			args:       []string{"upload", "-bogus", "file", "gs://bucket1/file1.txt"},
+			wantStderr: "flag provided but not defined: -bogus",
+		},
+		{
+			name:       "Not a gs URL",
+			args:       []string{"list-uploads", "bucket1"},
+			wantStderr: `"bucket1" is not a gs:// URL`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			te := newTestEnv(nil)
+			te.run(t, 2, tc.args...)
+			if !strings.Contains(te.stderr.String(), tc.wantStderr) {
+				t.Errorf("got stderr %q, want it to contain %q", te.stderr.String(), tc.wantStderr)
+			}
+		})
+	}
+}
+
+func TestParseGSURL(t *testing.T) {
+	tests := []struct {
+		url         string
+		keyOptional bool
+		want        multipartclient.ObjectRef
+		wantErr     bool
+	}{
+		{url: "gs://bucket1/dir/file1.txt", want: multipartclient.ObjectRef{Bucket: "bucket1", Key: "dir/file1.txt"}},
+		{url: "gs://bucket1", keyOptional: true, want: multipartclient.ObjectRef{Bucket: "bucket1"}},
+		{url: "gs://bucket1/", keyOptional: true, want: multipartclient.ObjectRef{Bucket: "bucket1"}},
+		{url: "gs://bucket1", wantErr: true},
+		{url: "gs:///file1.txt", wantErr: true},
+		{url: "s3://bucket1/file1.txt", wantErr: true},
+	}
+	for _, tc := range tests {
+		got, err := parseGSURL(tc.url, tc.keyOptional)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("parseGSURL(%q): got error %v, want error %v", tc.url, err, tc.wantErr)
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("parseGSURL(%q): unexpected diff: (-want, +got):\n%s", tc.url, diff)
+		}
+	}
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.json")
+	if err := os.WriteFile(valid, []byte(`{"type": "authorized_user", "client_id": "id1", "client_secret": "secret1", "refresh_token": "token1"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte(`not json`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if _, err := newHTTPClient(ctx, &config{Credentials: valid}); err != nil {
+		t.Errorf("got error %v for a valid credentials file", err)
+	}
+	for _, path := range []string{invalid, filepath.Join(dir, "missing.json")} {
+		if _, err := newHTTPClient(ctx, &config{Credentials: path}); err == nil {
+			t.Errorf("got no error for credentials file %s", filepath.Base(path))
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Output formats selected with -format.
+const (
+	formatText   = "text"
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+)
+
+// parseFormat is the -format flag's parser.
+func parseFormat(e *env) func(string) error {
+	return func(s string) error {
+		switch s {
+		case formatText, formatJSON, formatNDJSON:
+			e.format = s
+			return nil
+		}
+		return fmt.Errorf("unknown format %q, want text, json or ndjson", s)
+	}
+}
+
+// output writes the results of a command as records in the JSON formats.
+// With -format=json, the records of a command that lists results are
+// written as one array, and the record of any other command as one object;
+// with -format=ndjson each record is written on its own line as soon as it
+// is produced.
+type output struct {
+	w      io.Writer
+	format string
+	list   bool
+	// records are buffered until close for -format=json.
+	records []any
+}
+
+// output returns the output of a command; list is set if it lists results.
+func (e *env) output(list bool) *output {
+	return &output{w: e.stdout, format: e.format, list: list}
+}
+
+// structured reports whether results are written as records instead of
+// text.
+func (o *output) structured() bool {
+	return o.format == formatJSON || o.format == formatNDJSON
+}
+
+// record writes v, which is marshaled to a JSON object.
+func (o *output) record(v any) error {
+	if o.format == formatNDJSON {
+		return json.NewEncoder(o.w).Encode(v)
+	}
+	o.records = append(o.records, v)
+	return nil
+}
+
+// close writes the buffered records.
+func (o *output) close() error {
+	if o.format != formatJSON {
+		return nil
+	}
+	var v any = o.records
+	switch {
+	case o.list && o.records == nil:
+		v = []any{}
+	case !o.list && len(o.records) == 1:
+		v = o.records[0]
+	}
+	enc := json.NewEncoder(o.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// errorRecord is written instead of a command's results when it fails.
+type errorRecord struct {
+	Command string `json:"command"`
+	Error   string `json:"error"`
+}
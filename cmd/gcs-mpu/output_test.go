@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multipartclienttest"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multiparttest"
+)
+
+func TestOutput(t *testing.T) {
+	type rec struct {
+		N int `json:"n"`
+	}
+	tests := []struct {
+		name    string
+		format  string
+		list    bool
+		records []any
+		want    string
+	}{
+		{
+			name:    "JSON object",
+			format:  formatJSON,
+			records: []any{rec{N: 1}},
+			want:    "{\n  \"n\": 1\n}\n",
+		},
+		{
+			name:    "JSON list",
+			format:  formatJSON,
+			list:    true,
+			records: []any{rec{N: 1}, rec{N: 2}},
+			want:    "[\n  {\n    \"n\": 1\n  },\n  {\n    \"n\": 2\n  }\n]\n",
+		},
+		{
+			name:   "Empty JSON list",
+			format: formatJSON,
+			list:   true,
+			want:   "[]\n",
+		},
+		{
+			name:    "NDJSON",
+			format:  formatNDJSON,
+			list:    true,
+			records: []any{rec{N: 1}, rec{N: 2}},
+			want:    "{\"n\":1}\n{\"n\":2}\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			e := &env{stdout: buf, format: tc.format}
+			out := e.output(tc.list)
+			if !out.structured() {
+				t.Fatalf("structured() = false for format %q, want true", tc.format)
+			}
+			for _, r := range tc.records {
+				if err := out.record(r); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := out.close(); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, buf.String()); diff != "" {
+				t.Errorf("unexpected diff for output: (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// decodeRecords decodes the newline-delimited JSON records in s.
+func decodeRecords(t *testing.T, s string) []map[string]any {
+	t.Helper()
+	var recs []map[string]any
+	dec := json.NewDecoder(bytes.NewBufferString(s))
+	for dec.More() {
+		var rec map[string]any
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatalf("decoding %q: %v", s, err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+func TestUploadJSON(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	path := writeTempFile(t, partsData(3))
+
+	te := newTestEnv(srv.Client())
+	te.run(t, 0, "upload", "-format", "json", "-part-size", testPartSize, path, "gs://bucket1/file1.txt")
+
+	recs := decodeRecords(t, te.stdout.String())
+	for _, rec := range recs {
+		if rec["etag"] == "" {
+			t.Errorf("got record %v without an ETag", rec)
+		}
+		delete(rec, "etag")
+	}
+	want := []map[string]any{{
+		"source":      path,
+		"bucket":      "bucket1",
+		"key":         "file1.txt",
+		"upload_id":   "upload-1",
+		"parts":       3.0,
+		"part_size":   float64(multipartclient.MinPartSize),
+		"concurrency": 4.0,
+	}}
+	if diff := cmp.Diff(want, recs); diff != "" {
+		t.Errorf("unexpected diff for records: (-want, +got):\n%s", diff)
+	}
+}
+
+func TestListUploadsNDJSON(t *testing.T) {
+	trans := multipartclienttest.NewTransport(t).Respond(listResponse())
+
+	te := newTestEnv(trans.Client())
+	te.run(t, 0, "list-uploads", "-format=ndjson", "gs://bucket1")
+
+	want := `{"bucket":"bucket1","key":"logs/old.txt","upload_id":"upload-1","initiated":"2024-03-02T12:00:00Z"}
+{"bucket":"bucket1","key":"logs/new.txt","upload_id":"upload-2","initiated":"2024-03-09T12:00:00Z"}
+{"bucket":"bucket1","key":"logs/older.txt","upload_id":"upload-3","initiated":"2024-02-09T06:00:00Z"}
+`
+	if diff := cmp.Diff(want, te.stdout.String()); diff != "" {
+		t.Errorf("unexpected diff for output: (-want, +got):\n%s", diff)
+	}
+}
+
+func TestCleanupNDJSON(t *testing.T) {
+	trans := multipartclienttest.NewTransport(t).Respond(listResponse()).
+		Respond(multipartclienttest.AbortResponse()).
+		Respond(multipartclienttest.ErrorResponse(http.StatusForbidden, "AccessDenied", "Access denied."))
+
+	te := newTestEnv(trans.Client())
+	te.run(t, 1, "cleanup", "-format=ndjson", "-bucket", "bucket1")
+
+	recs := decodeRecords(t, te.stdout.String())
+	if len(recs) == 2 {
+		if recs[1]["error"] == nil {
+			t.Errorf("got record %v for a failed abort without an error", recs[1])
+		}
+		delete(recs[1], "error")
+	}
+	want := []map[string]any{
+		{"bucket": "bucket1", "key": "logs/old.txt", "upload_id": "upload-1", "initiated": "2024-03-02T12:00:00Z", "action": "aborted"},
+		{"bucket": "bucket1", "key": "logs/older.txt", "upload_id": "upload-3", "initiated": "2024-02-09T06:00:00Z", "action": "failed"},
+	}
+	if diff := cmp.Diff(want, recs); diff != "" {
+		t.Errorf("unexpected diff for records: (-want, +got):\n%s", diff)
+	}
+	wantErr := []map[string]any{{"command": "cleanup", "error": "failed to abort 1 uploads"}}
+	if diff := cmp.Diff(wantErr, decodeRecords(t, te.stderr.String())); diff != "" {
+		t.Errorf("unexpected diff for error records: (-want, +got):\n%s", diff)
+	}
+}
+
+func TestErrorJSON(t *testing.T) {
+	trans := multipartclienttest.NewTransport(t).Respond(
+		multipartclienttest.ErrorResponse(http.StatusNotFound, "NoSuchUpload", "The upload does not exist."),
+	)
+
+	te := newTestEnv(trans.Client())
+	te.run(t, 1, "abort", "-format", "json", "gs://bucket1/file1.txt", "upload-1")
+
+	if te.stdout.Len() != 0 {
+		t.Errorf("got stdout %q, want none", te.stdout.String())
+	}
+	recs := decodeRecords(t, te.stderr.String())
+	if len(recs) != 1 || recs[0]["command"] != "abort" || recs[0]["error"] == "" {
+		t.Errorf("got error records %v, want one for abort", recs)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// renderInterval is the minimum time between redraws of the progress line.
+const renderInterval = 100 * time.Millisecond
+
+// progress draws the progress of uploads on a terminal line that is redrawn
+// as they advance: bytes and parts sent, throughput, estimated time left and
+// retries of the current file, and the totals of all files once there is
+// more than one. A nil *progress draws nothing.
+type progress struct {
+	w   io.Writer
+	now func() time.Time
+	// retries returns the number of requests retried so far.
+	retries func() uint64
+
+	mu         sync.Mutex
+	start      time.Time
+	lastRender time.Time
+	files      int
+	// total is the sum of the sizes of the files started, or -1 if any
+	// size is unknown.
+	total   int64
+	bytes   int64
+	current *fileProgress
+}
+
+// fileProgress is the progress of one file.
+type fileProgress struct {
+	p     *progress
+	name  string
+	size  int64
+	parts int
+	// partsTotal is the number of parts the file is sent in, or 0 if not
+	// known.
+	partsTotal int
+	start      time.Time
+	bytes      int64
+}
+
+func newProgress(w io.Writer, now func() time.Time, retries func() uint64) *progress {
+	return &progress{w: w, now: now, retries: retries, start: now()}
+}
+
+// startFile starts tracking the upload of a file of size bytes, or of
+// unknown size if size is negative, sent in parts of partSize bytes, or of
+// varying size if partSize is 0.
+func (p *progress) startFile(name string, size int64, partSize int64) *fileProgress {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	f := &fileProgress{p: p, name: name, size: size, start: p.now()}
+	if size >= 0 && partSize > 0 {
+		f.partsTotal = max(1, int((size+partSize-1)/partSize))
+	}
+	p.files++
+	if size < 0 || p.total < 0 {
+		p.total = -1
+	} else {
+		p.total += size
+	}
+	p.current = f
+	p.render(true)
+	return f
+}
+
+// partDone records that a part of n bytes was uploaded.
+func (f *fileProgress) partDone(n int) {
+	if f == nil {
+		return
+	}
+	p := f.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	f.parts++
+	f.bytes += int64(n)
+	p.bytes += int64(n)
+	p.render(false)
+}
+
+// done draws the final progress of the file and ends its line.
+func (f *fileProgress) done() {
+	if f == nil {
+		return
+	}
+	p := f.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.render(true)
+	fmt.Fprintln(p.w)
+}
+
+// render redraws the progress line, at most once per renderInterval unless
+// force is set. p.mu must be held.
+func (p *progress) render(force bool) {
+	now := p.now()
+	if !force && now.Sub(p.lastRender) < renderInterval {
+		return
+	}
+	p.lastRender = now
+	f := p.current
+
+	var b strings.Builder
+	// Return to the start of the line and clear it.
+	b.WriteString("\r\x1b[K")
+	if p.files > 1 {
+		fmt.Fprintf(&b, "[file %d] total %s  ", p.files, transferStatus(p.bytes, p.total, now.Sub(p.start)))
+	}
+	fmt.Fprintf(&b, "%s: %s  ", f.name, transferStatus(f.bytes, f.size, now.Sub(f.start)))
+	if f.partsTotal > 0 {
+		fmt.Fprintf(&b, "%d/%d parts", f.parts, f.partsTotal)
+	} else {
+		fmt.Fprintf(&b, "%d parts", f.parts)
+	}
+	if p.retries != nil {
+		fmt.Fprintf(&b, "  %d retries", p.retries())
+	}
+	io.WriteString(p.w, b.String())
+}
+
+// transferStatus formats the progress of sending total bytes, or an unknown
+// number if total is negative, of which sent were sent in elapsed.
+func transferStatus(sent, total int64, elapsed time.Duration) string {
+	var rate float64
+	if elapsed > 0 {
+		rate = float64(sent) / elapsed.Seconds()
+	}
+	if total < 0 {
+		return fmt.Sprintf("%s  %s/s", formatBytes(sent), formatBytes(int64(rate)))
+	}
+	percent := 100
+	if total > 0 {
+		percent = int(sent * 100 / total)
+	}
+	eta := "?"
+	if rate > 0 {
+		eta = time.Duration(float64(total-sent) / rate * float64(time.Second)).Round(time.Second).String()
+	}
+	return fmt.Sprintf("%s/%s (%d%%)  %s/s  ETA %s", formatBytes(sent), formatBytes(total), percent, formatBytes(int64(rate)), eta)
+}
+
+// formatBytes formats n in binary units with one decimal.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
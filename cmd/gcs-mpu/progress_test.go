@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multiparttest"
+)
+
+// progressLines returns the lines drawn by a progress in out.
+func progressLines(out string) []string {
+	lines := strings.Split(out, "\r\x1b[K")
+	return lines[1:]
+}
+
+func TestProgress(t *testing.T) {
+	start := testNow
+	now := start
+	var retries uint64
+	buf := &bytes.Buffer{}
+	p := newProgress(buf, func() time.Time { return now }, func() uint64 { return retries })
+
+	f := p.startFile("file1.txt", 3<<20, 1<<20)
+	now = start.Add(time.Second)
+	f.partDone(1 << 20)
+	// Drawn again only once renderInterval has passed.
+	now = start.Add(time.Second + renderInterval/2)
+	retries = 1
+	f.partDone(1 << 20)
+	now = start.Add(3 * time.Second)
+	f.partDone(1 << 20)
+	f.done()
+
+	g := p.startFile("file2.txt", -1, 1<<20)
+	now = start.Add(4 * time.Second)
+	g.partDone(512)
+	g.done()
+
+	want := []string{
+		"file1.txt: 0 B/3.0 MiB (0%)  0 B/s  ETA ?  0/3 parts  0 retries",
+		"file1.txt: 1.0 MiB/3.0 MiB (33%)  1.0 MiB/s  ETA 2s  1/3 parts  0 retries",
+		"file1.txt: 3.0 MiB/3.0 MiB (100%)  1.0 MiB/s  ETA 0s  3/3 parts  1 retries",
+		"file1.txt: 3.0 MiB/3.0 MiB (100%)  1.0 MiB/s  ETA 0s  3/3 parts  1 retries\n",
+		"[file 2] total 3.0 MiB  1.0 MiB/s  file2.txt: 0 B  0 B/s  0 parts  1 retries",
+		"[file 2] total 3.0 MiB  768.1 KiB/s  file2.txt: 512 B  512 B/s  1 parts  1 retries",
+		"[file 2] total 3.0 MiB  768.1 KiB/s  file2.txt: 512 B  512 B/s  1 parts  1 retries\n",
+	}
+	if diff := cmp.Diff(want, progressLines(buf.String())); diff != "" {
+		t.Errorf("unexpected diff for progress lines: (-want, +got):\n%s", diff)
+	}
+}
+
+func TestNilProgress(t *testing.T) {
+	var p *progress
+	f := p.startFile("file1.txt", 10, 5)
+	f.partDone(5)
+	f.done()
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{n: 0, want: "0 B"},
+		{n: 1023, want: "1023 B"},
+		{n: 1536, want: "1.5 KiB"},
+		{n: 5 << 30, want: "5.0 GiB"},
+	}
+	for _, tc := range tests {
+		if got := formatBytes(tc.n); got != tc.want {
+			t.Errorf("formatBytes(%d) = %q, want %q", tc.n, got, tc.want)
+		}
+	}
+}
+
+func TestUploadProgress(t *testing.T) {
+	tests := []struct {
+		name         string
+		quiet        bool
+		wantProgress bool
+	}{
+		{name: "Progress", wantProgress: true},
+		{name: "Quiet", quiet: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := multiparttest.NewServer(t)
+			data := partsData(3)
+			path := writeTempFile(t, data)
+
+			te := newTestEnv(srv.Client())
+			te.interactive = true
+			args := []string{"upload", "-part-size", testPartSize, path, "gs://bucket1/file1.txt"}
+			if tc.quiet {
+				args = append(args[:1], append([]string{"-quiet"}, args[1:]...)...)
+			}
+			te.run(t, 0, args...)
+
+			lines := progressLines(te.stderr.String())
+			if !tc.wantProgress {
+				if len(lines) != 0 {
+					t.Errorf("got progress %q with -quiet, want none", lines)
+				}
+				return
+			}
+			size := formatBytes(int64(len(data)))
+			if len(lines) == 0 || !strings.Contains(lines[len(lines)-1], size+"/"+size+" (100%)") || !strings.Contains(lines[len(lines)-1], "3/3 parts") {
+				t.Errorf("got progress %q, want it to end with %s in 3 parts", lines, size)
+			}
+		})
+	}
+}
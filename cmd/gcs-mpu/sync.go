@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+)
+
+// Actions taken on a file by sync.
+const (
+	actionUploaded    = "uploaded"
+	actionWouldUpload = "would_upload"
+	actionSkipped     = "skipped"
+)
+
+// Why sync uploads a file.
+const (
+	reasonNew     = "new"
+	reasonChanged = "changed"
+)
+
+// syncRecord is the outcome of syncing a file.
+type syncRecord struct {
+	Path       string `json:"path"`
+	Bucket     string `json:"bucket"`
+	Key        string `json:"key"`
+	Action     string `json:"action"`
+	Reason     string `json:"reason,omitempty"`
+	UploadID   string `json:"upload_id,omitempty"`
+	ETag       string `json:"etag,omitempty"`
+	Generation int64  `json:"generation,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+func runSync(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "sync")
+	checksum := fs.Bool("checksum", false, "compare files by size and CRC32C instead of size and modification time")
+	dryRun := fs.Bool("dry-run", false, "list the files that would be uploaded without uploading them")
+	partSize := fs.Int64("part-size", e.cfg.partSize(), "size of each part in bytes, from 5 MiB to 5 GiB; raised if a file doesn't fit in 10000 parts")
+	concurrency := fs.Int("concurrency", e.cfg.concurrency(), "number of parts of a file uploaded at once")
+	quiet := fs.Bool("quiet", false, "don't show progress")
+	args, err := parseArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	if err := checkPartSize(*partSize); err != nil {
+		return err
+	}
+	if *concurrency <= 0 {
+		return usageErrorf("-concurrency must be positive, got %d", *concurrency)
+	}
+	dir := args[0]
+	dst, err := parseGSURL(args[1], true)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(dir); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return usageErrorf("%q is not a directory", dir)
+	}
+
+	mpuc, err := e.client(ctx)
+	if err != nil {
+		return err
+	}
+	var prog *progress
+	if e.interactive && !*quiet && !*dryRun {
+		prog = newProgress(e.stderr, e.now, func() uint64 { return mpuc.Stats().Retries })
+	}
+
+	s := &syncer{
+		mpuc:        mpuc,
+		checksum:    *checksum,
+		dryRun:      *dryRun,
+		partSize:    *partSize,
+		concurrency: *concurrency,
+		prog:        prog,
+	}
+	out := e.output(true)
+	var files, uploaded, skipped, failed int
+	err = filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		ref := multipartclient.ObjectRef{Bucket: dst.Bucket, Key: syncKey(dst.Key, rel)}
+		rec := s.syncFile(ctx, p, ref)
+		files++
+		switch rec.Action {
+		case actionUploaded, actionWouldUpload:
+			uploaded++
+		case actionSkipped:
+			skipped++
+		case actionFailed:
+			failed++
+		}
+		if out.structured() {
+			return out.record(rec)
+		}
+		switch rec.Action {
+		case actionUploaded:
+			fmt.Fprintf(e.stdout, "uploaded %s to %s (%s)\n", p, gsURL(ref), rec.Reason)
+		case actionWouldUpload:
+			fmt.Fprintf(e.stdout, "would upload %s to %s (%s)\n", p, gsURL(ref), rec.Reason)
+		case actionFailed:
+			fmt.Fprintf(e.stderr, "failed to sync %s to %s: %s\n", p, gsURL(ref), rec.Error)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if out.structured() {
+		if err := out.close(); err != nil {
+			return err
+		}
+	} else if *dryRun {
+		fmt.Fprintf(e.stdout, "%d files, %d unchanged; %d to upload, none uploaded (dry run)\n", files, skipped, uploaded)
+	} else {
+		fmt.Fprintf(e.stdout, "%d files, %d unchanged; uploaded %d, failed %d\n", files, skipped, uploaded, failed)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to sync %d files", failed)
+	}
+	return nil
+}
+
+// syncKey returns the key of the object a file at rel, relative to the synced
+// directory, is uploaded to under prefix.
+func syncKey(prefix, rel string) string {
+	rel = filepath.ToSlash(rel)
+	if prefix == "" {
+		return rel
+	}
+	return path.Join(prefix, rel)
+}
+
+// syncer uploads the files of a directory that differ from their objects.
+type syncer struct {
+	mpuc        *multipartclient.MultipartClient
+	checksum    bool
+	dryRun      bool
+	partSize    int64
+	concurrency int
+	prog        *progress
+}
+
+// syncFile uploads the file at p to ref if it is new or changed. Failures are
+// reported in the record rather than returned, so the other files are still
+// synced.
+func (s *syncer) syncFile(ctx context.Context, p string, ref multipartclient.ObjectRef) *syncRecord {
+	rec := &syncRecord{Path: p, Bucket: ref.Bucket, Key: ref.Key}
+	fail := func(err error) *syncRecord {
+		rec.Action, rec.Error = actionFailed, err.Error()
+		return rec
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return fail(err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fail(err)
+	}
+
+	rec.Reason, err = s.reason(ctx, f, info, ref)
+	if err != nil {
+		return fail(err)
+	}
+	switch {
+	case rec.Reason == "":
+		rec.Action = actionSkipped
+		return rec
+	case s.dryRun:
+		rec.Action = actionWouldUpload
+		return rec
+	}
+
+	// Parts are read at their offsets, so where reason left the file doesn't
+	// matter.
+	parts, err := newParts(f, info.Size(), s.partSize, false)
+	if err != nil {
+		return fail(err)
+	}
+	fp := s.prog.startFile(p, info.Size(), parts.fixedSize())
+	up, err := upload(ctx, s.mpuc, parts, ref, newLimiter(s.concurrency, nil), fp)
+	fp.done()
+	if err != nil {
+		return fail(err)
+	}
+	rec.Action = actionUploaded
+	rec.UploadID, rec.ETag, rec.Generation = up.UploadID, up.ETag, up.Generation
+	return rec
+}
+
+// reason returns why the file f must be uploaded to ref, or "" if the object
+// is up to date: it has the same size and, with -checksum, the same CRC32C,
+// or otherwise was last modified no earlier than the file.
+func (s *syncer) reason(ctx context.Context, f *os.File, info os.FileInfo, ref multipartclient.ObjectRef) (string, error) {
+	attrs, err := s.mpuc.StatObject(ctx, ref)
+	if errors.Is(err, multipartclient.ErrObjectNotExist) {
+		return reasonNew, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if attrs.Size != info.Size() {
+		return reasonChanged, nil
+	}
+	if !s.checksum {
+		// Last-Modified has a resolution of a second.
+		if info.ModTime().Truncate(time.Second).After(attrs.LastModified) {
+			return reasonChanged, nil
+		}
+		return "", nil
+	}
+	if !attrs.Sums.HasCRC32C {
+		return reasonChanged, nil
+	}
+	h := gcshash.NewCRC32C()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	if h.Sum32() != attrs.Sums.CRC32C {
+		return reasonChanged, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multipartclienttest"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multiparttest"
+)
+
+// syncPast is a modification time older than any object on the fake server.
+var syncPast = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// writeSyncDir writes files, keyed by slash-separated path, under a new
+// directory with modification times of syncPast, and returns the directory.
+func writeSyncDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		writeSyncFile(t, dir, name, data, syncPast)
+	}
+	return dir
+}
+
+func writeSyncFile(t *testing.T, dir, name, data string, modified time.Time) {
+	t.Helper()
+	p := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(p, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// syncActions runs sync with -format=ndjson and returns the action taken on
+// each file, keyed by object, with the reason if there is one.
+func syncActions(t *testing.T, te *testEnv, wantStatus int, args ...string) map[string]string {
+	t.Helper()
+	te.stdout.Reset()
+	te.run(t, wantStatus, append([]string{"sync", "-format=ndjson", "-part-size", testPartSize}, args...)...)
+	got := map[string]string{}
+	for _, rec := range decodeRecords(t, te.stdout.String()) {
+		action := rec["action"].(string)
+		if reason, ok := rec["reason"].(string); ok {
+			action += " " + reason
+		}
+		got[rec["key"].(string)] = action
+	}
+	return got
+}
+
+func TestSync(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	dir := writeSyncDir(t, map[string]string{
+		"a.txt":       "the quick brown fox",
+		"sub/b.txt":   "jumps over",
+		"sub/c/d.txt": "the lazy dog",
+	})
+	te := newTestEnv(srv.Client())
+
+	got := syncActions(t, te, 0, dir, "gs://bucket1/backup")
+	want := map[string]string{
+		"backup/a.txt":       "uploaded new",
+		"backup/sub/b.txt":   "uploaded new",
+		"backup/sub/c/d.txt": "uploaded new",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected diff for first sync: (-want, +got):\n%s", diff)
+	}
+	if data, _ := srv.Object("bucket1", "backup/sub/c/d.txt"); string(data) != "the lazy dog" {
+		t.Errorf("got object %q, want %q", data, "the lazy dog")
+	}
+
+	// A different size, a newer file and the same size and age but different
+	// data, which only -checksum notices.
+	writeSyncFile(t, dir, "a.txt", "the quick brown fox!", syncPast)
+	writeSyncFile(t, dir, "sub/b.txt", "jumps over", time.Now().Add(time.Hour))
+	writeSyncFile(t, dir, "sub/c/d.txt", "the lazy cat", syncPast)
+
+	got = syncActions(t, te, 0, "-dry-run", dir, "gs://bucket1/backup")
+	want = map[string]string{
+		"backup/a.txt":       "would_upload changed",
+		"backup/sub/b.txt":   "would_upload changed",
+		"backup/sub/c/d.txt": "skipped",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected diff for dry run: (-want, +got):\n%s", diff)
+	}
+	if data, _ := srv.Object("bucket1", "backup/a.txt"); string(data) != "the quick brown fox" {
+		t.Errorf("got object %q after a dry run, want it unchanged", data)
+	}
+
+	got = syncActions(t, te, 0, "-checksum", dir, "gs://bucket1/backup")
+	want = map[string]string{
+		"backup/a.txt":       "uploaded changed",
+		"backup/sub/b.txt":   "skipped",
+		"backup/sub/c/d.txt": "uploaded changed",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected diff for checksum sync: (-want, +got):\n%s", diff)
+	}
+	if data, _ := srv.Object("bucket1", "backup/sub/c/d.txt"); string(data) != "the lazy cat" {
+		t.Errorf("got object %q, want %q", data, "the lazy cat")
+	}
+}
+
+func TestSyncText(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	srv.MinPartSize = 4
+	dir := writeSyncDir(t, map[string]string{"a.txt": "hello"})
+	te := newTestEnv(srv.Client())
+
+	te.run(t, 0, "sync", dir, "gs://bucket1")
+	te.run(t, 0, "sync", dir, "gs://bucket1")
+
+	want := "uploaded " + filepath.Join(dir, "a.txt") + " to gs://bucket1/a.txt (new)\n" +
+		"1 files, 0 unchanged; uploaded 1, failed 0\n" +
+		"1 files, 1 unchanged; uploaded 0, failed 0\n"
+	if diff := cmp.Diff(want, te.stdout.String()); diff != "" {
+		t.Errorf("unexpected diff for output: (-want, +got):\n%s", diff)
+	}
+}
+
+func TestSyncFailure(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	dir := writeSyncDir(t, map[string]string{"a.txt": "first", "b.txt": "second"})
+	ft := multipartclienttest.NewFaultTransport(srv.Transport(), &multipartclienttest.Fault{
+		Match: func(req *http.Request) bool {
+			return req.Method == http.MethodPut && req.URL.Path == "/bucket1/a.txt"
+		},
+		DropConnection: true,
+	})
+	te := newTestEnv(ft.Client())
+
+	got := syncActions(t, te, 1, dir, "gs://bucket1")
+	want := map[string]string{"a.txt": "failed new", "b.txt": "uploaded new"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected diff for sync: (-want, +got):\n%s", diff)
+	}
+	if !strings.Contains(te.stderr.String(), "failed to sync 1 files") {
+		t.Errorf("got stderr %q, want it to report the failure", te.stderr.String())
+	}
+}
+
+func TestSyncNotADirectory(t *testing.T) {
+	te := newTestEnv(nil)
+	te.run(t, 2, "sync", writeTempFile(t, "data"), "gs://bucket1")
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+)
+
+const (
+	// defaultConcurrency is the number of parts uploaded at once.
+	defaultConcurrency = 4
+	// maxAutoConcurrency is the most parts -auto-tune uploads at once unless
+	// -concurrency is set.
+	maxAutoConcurrency = 16
+	// autoTuneParts is the most parts -auto-tune splits a file into before
+	// it raises the part size above defaultPartSize. Fewer, larger parts
+	// spend less time on per-request overhead.
+	autoTuneParts = 1000
+	// tuneGain is the factor by which doubling the concurrency must raise
+	// the throughput for the tuner to keep doubling it.
+	tuneGain = 1.1
+)
+
+// autoPartSize returns the part size -auto-tune picks for a file of size
+// bytes: defaultPartSize, or the smallest whole number of MiB that splits the
+// file into at most autoTuneParts parts.
+func autoPartSize(size int64) int64 {
+	const mib = 1 << 20
+	partSize := (size + autoTuneParts - 1) / autoTuneParts
+	partSize = (partSize + mib - 1) / mib * mib
+	return min(max(partSize, defaultPartSize), multipartclient.MaxPartSize)
+}
+
+// limiter bounds the number of parts uploaded at once. If it has a tuner, the
+// bound follows the tuner as parts complete.
+type limiter struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	limit    int
+	inflight int
+	tuner    *tuner
+}
+
+func newLimiter(limit int, t *tuner) *limiter {
+	l := &limiter{limit: limit, tuner: t}
+	if t != nil {
+		l.limit = t.level
+	}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// acquire blocks until another part may be uploaded.
+func (l *limiter) acquire() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.inflight >= l.limit {
+		l.cond.Wait()
+	}
+	l.inflight++
+}
+
+// release records that a part of n bytes finished uploading, or that a part
+// acquired for was never uploaded if n is negative.
+func (l *limiter) release(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inflight--
+	if l.tuner != nil && n >= 0 {
+		l.limit = l.tuner.partDone(n)
+	}
+	l.cond.Broadcast()
+}
+
+// concurrency returns the current bound.
+func (l *limiter) concurrency() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// tuner picks the number of parts to upload at once by probing throughput.
+// Starting from one part, it doubles the concurrency, up to max, for as long
+// as each doubling raises the throughput by at least tuneGain, then settles
+// on the concurrency with the best throughput seen. Each level is measured
+// over twice as many parts as it uploads at once.
+type tuner struct {
+	now func() time.Time
+	max int
+
+	level   int
+	settled bool
+	// start, parts and bytes measure the current level.
+	start time.Time
+	parts int
+	bytes int64
+	// best is the best throughput seen, in bytes per second, at bestLevel.
+	best      float64
+	bestLevel int
+}
+
+func newTuner(now func() time.Time, max int) *tuner {
+	return &tuner{now: now, max: max, level: 1, bestLevel: 1, start: now()}
+}
+
+// partDone records that a part of n bytes was uploaded and returns the
+// concurrency to use from now on.
+func (t *tuner) partDone(n int) int {
+	if t.settled {
+		return t.level
+	}
+	t.parts++
+	t.bytes += int64(n)
+	if t.parts < 2*t.level {
+		return t.level
+	}
+
+	throughput := float64(t.bytes) / t.now().Sub(t.start).Seconds()
+	if throughput >= t.best*tuneGain {
+		t.best, t.bestLevel = throughput, t.level
+		if t.level < t.max {
+			t.level = min(2*t.level, t.max)
+			t.start, t.parts, t.bytes = t.now(), 0, 0
+			return t.level
+		}
+	}
+	t.level = t.bestLevel
+	t.settled = true
+	return t.level
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+)
+
+func TestAutoPartSize(t *testing.T) {
+	tests := []struct {
+		size int64
+		want int64
+	}{
+		{size: 0, want: defaultPartSize},
+		{size: 1 << 30, want: defaultPartSize},
+		{size: 100 << 30, want: 103 << 20},
+		{size: 10 << 40, want: multipartclient.MaxPartSize},
+	}
+	for _, tc := range tests {
+		if got := autoPartSize(tc.size); got != tc.want {
+			t.Errorf("autoPartSize(%d) = %d, want %d", tc.size, got, tc.want)
+		}
+	}
+}
+
+func TestTuner(t *testing.T) {
+	tests := []struct {
+		name string
+		max  int
+		// speed returns the throughput in bytes per second at a level.
+		speed func(level int) int
+		want  []int
+	}{
+		{
+			name:  "Scales linearly up to max",
+			max:   8,
+			speed: func(level int) int { return level << 20 },
+			want:  []int{1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 4, 4, 4, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8},
+		},
+		{
+			name: "Plateaus",
+			max:  16,
+			speed: func(level int) int {
+				return min(level, 2) << 20
+			},
+			want: []int{1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 4, 4, 4, 2, 2},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			now := testNow
+			tu := newTuner(func() time.Time { return now }, tc.max)
+			var got []int
+			for range tc.want {
+				got = append(got, tu.level)
+				// Each part of 1 MiB takes 1/speed of a second.
+				now = now.Add(time.Second * time.Duration(1<<20) / time.Duration(tc.speed(tu.level)))
+				tu.partDone(1 << 20)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Fatalf("got levels %v, want %v", got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(2, nil)
+	l.acquire()
+	l.acquire()
+	acquired := make(chan struct{})
+	go func() {
+		l.acquire()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired a third part with a limit of 2")
+	case <-time.After(10 * time.Millisecond):
+	}
+	l.release(1)
+	<-acquired
+}
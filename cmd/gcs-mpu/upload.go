@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"runtime/pprof"
+	"sync"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+)
+
+// defaultPartSize is the size of the parts files are uploaded in.
+const defaultPartSize = 16 << 20
+
+// stdinName is the FILE argument of upload that reads stdin.
+const stdinName = "-"
+
+// uploadRecord is the result of uploading a file.
+type uploadRecord struct {
+	Source     string `json:"source"`
+	Bucket     string `json:"bucket"`
+	Key        string `json:"key"`
+	UploadID   string `json:"upload_id"`
+	ETag       string `json:"etag"`
+	Generation int64  `json:"generation,omitempty"`
+	Parts      int    `json:"parts"`
+	// PartSize is the size of every part but the last, or their average size
+	// with -chunking content.
+	PartSize int64 `json:"part_size"`
+	// Concurrency is the number of parts uploaded at once at the end, which
+	// -auto-tune may have lowered.
+	Concurrency int `json:"concurrency"`
+}
+
+// checkPartSize returns a usage error unless partSize, the value of
+// -part-size, is between MinPartSize and MaxPartSize.
+func checkPartSize(partSize int64) error {
+	if partSize < multipartclient.MinPartSize || partSize > multipartclient.MaxPartSize {
+		return usageErrorf("-part-size must be between %d and %d, got %d", int64(multipartclient.MinPartSize), int64(multipartclient.MaxPartSize), partSize)
+	}
+	return nil
+}
+
+func runUpload(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "upload")
+	partSize := fs.Int64("part-size", e.cfg.partSize(), "size of each part in bytes, from 5 MiB to 5 GiB; raised if a file doesn't fit in 10000 parts")
+	concurrency := fs.Int("concurrency", e.cfg.concurrency(), "number of parts uploaded at once; with -auto-tune, the most tried")
+	chunking := fs.String("chunking", chunkingFixed, "how files are cut into parts: fixed, in parts of -part-size bytes, or content, at boundaries that depend on the data, in parts averaging -part-size bytes")
+	autoTune := fs.Bool("auto-tune", false, "pick the part size from the file size unless -part-size is set, and the concurrency by probing throughput")
+	quiet := fs.Bool("quiet", false, "don't show progress")
+	args, err := parseArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	if err := checkPartSize(*partSize); err != nil {
+		return err
+	}
+	if *concurrency <= 0 {
+		return usageErrorf("-concurrency must be positive, got %d", *concurrency)
+	}
+	if *chunking != chunkingFixed && *chunking != chunkingContent {
+		return usageErrorf("-chunking must be %s or %s, got %q", chunkingFixed, chunkingContent, *chunking)
+	}
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	dst, err := parseGSURL(args[1], false)
+	if err != nil {
+		return err
+	}
+
+	// The length of stdin is not known up front.
+	r, name, size := e.stdin, "stdin", int64(-1)
+	if args[0] != stdinName {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		r, name, size = f, args[0], info.Size()
+	}
+
+	var t *tuner
+	if *autoTune {
+		if !set["part-size"] && size >= 0 {
+			*partSize = autoPartSize(size)
+		}
+		if !set["concurrency"] {
+			*concurrency = maxAutoConcurrency
+		}
+		t = newTuner(e.now, *concurrency)
+	}
+	p, err := newParts(r, size, *partSize, *chunking == chunkingContent)
+	if err != nil {
+		return err
+	}
+
+	mpuc, err := e.client(ctx)
+	if err != nil {
+		return err
+	}
+	var prog *progress
+	if e.interactive && !*quiet {
+		prog = newProgress(e.stderr, e.now, func() uint64 { return mpuc.Stats().Retries })
+	}
+	fp := prog.startFile(name, size, p.fixedSize())
+	rec, err := upload(ctx, mpuc, p, dst, newLimiter(*concurrency, t), fp)
+	fp.done()
+	if err != nil {
+		return err
+	}
+	rec.Source = args[0]
+
+	out := e.output(false)
+	if !out.structured() {
+		fmt.Fprintf(e.stdout, "uploaded %s to %s in %d parts, ETag %s\n", rec.Source, gsURL(dst), rec.Parts, rec.ETag)
+		if *autoTune {
+			fmt.Fprintf(e.stdout, "auto-tuned to parts of %s, %d at once\n", formatBytes(rec.PartSize), rec.Concurrency)
+		}
+		return nil
+	}
+	if err := out.record(rec); err != nil {
+		return err
+	}
+	return out.close()
+}
+
+// Values of -chunking.
+const (
+	chunkingFixed   = "fixed"
+	chunkingContent = "content"
+)
+
+// parts are the parts of the data of an upload.
+type parts struct {
+	// partSize is the size of every part but the last, or their average size
+	// if contentDefined.
+	partSize int64
+	// contentDefined reports whether the parts are cut at boundaries that
+	// depend on the data, so their sizes vary.
+	contentDefined bool
+	// next returns the body of the next part and its length, and io.EOF
+	// after the last.
+	next func() (io.ReadCloser, int64, error)
+}
+
+// newParts returns the parts of the size bytes of r, or of all of r if size
+// is negative, of partSize bytes. Parts of a file of known size are planned
+// by PlanParts, which raises partSize if the file doesn't fit in MaxParts
+// parts, and read in place; the parts of other readers are read into memory.
+// If contentDefined, the parts are cut by a content-defined Chunker instead,
+// to between MinPartSize and twice partSize bytes, averaging partSize.
+func newParts(r io.Reader, size, partSize int64, contentDefined bool) (*parts, error) {
+	if ra, ok := r.(io.ReaderAt); ok && size >= 0 && !contentDefined {
+		plan, err := multipartclient.PlanParts(size, &multipartclient.PartPlanOptions{PartSize: partSize})
+		if err != nil {
+			return nil, err
+		}
+		i := 0
+		return &parts{partSize: plan.PartSize, next: func() (io.ReadCloser, int64, error) {
+			if i == len(plan.Parts) {
+				return nil, 0, io.EOF
+			}
+			part := plan.Parts[i]
+			i++
+			return multipartclient.NewSectionBody(ra, part.Offset, part.Length), part.Length, nil
+		}}, nil
+	}
+	// Parts are read into memory, so they must fit in an int.
+	maxSize := partSize
+	if contentDefined {
+		maxSize = 2 * partSize
+	}
+	if maxSize > math.MaxInt {
+		return nil, fmt.Errorf("part size %d is too large to hold in memory on this platform", partSize)
+	}
+	var (
+		chunker multipartclient.Chunker
+		err     error
+	)
+	if contentDefined {
+		chunker, err = multipartclient.NewContentDefinedChunker(r, multipartclient.CDCOptions{
+			MinSize: multipartclient.MinPartSize,
+			AvgSize: int(partSize),
+			MaxSize: int(2 * partSize),
+		})
+	} else {
+		chunker, err = multipartclient.NewFixedSizeChunker(r, int(partSize))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &parts{partSize: partSize, contentDefined: contentDefined, next: func() (io.ReadCloser, int64, error) {
+		chunk, err := chunker.Next()
+		if err != nil {
+			return nil, 0, err
+		}
+		// The body is seekable so it can be sent again if a request is
+		// retried.
+		n := int64(len(chunk.Data))
+		return multipartclient.NewSectionBody(bytes.NewReader(chunk.Data), 0, n), n, nil
+	}}, nil
+}
+
+// fixedSize returns the size of every part but the last, or 0 if the parts
+// vary in size.
+func (p *parts) fixedSize() int64 {
+	if p.contentDefined {
+		return 0
+	}
+	return p.partSize
+}
+
+// upload uploads p to dst, as many parts at once as lim allows, reporting
+// them to fp. If any step fails the upload is aborted.
+func upload(ctx context.Context, mpuc *multipartclient.MultipartClient, p *parts, dst multipartclient.ObjectRef, lim *limiter, fp *fileProgress) (*uploadRecord, error) {
+	s, err := mpuc.NewSession(ctx, &multipartclient.InitiateMultipartUploadRequest{
+		Bucket: dst.Bucket,
+		Key:    dst.Key,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result *multipartclient.CompleteMultipartUploadResult
+	pprof.Do(ctx, multipartclient.UploadLabels(dst.Bucket, s.ID()), func(ctx context.Context) {
+		result, err = uploadParts(ctx, s, p, lim, fp)
+	})
+	if err != nil {
+		// Abort even if ctx was cancelled so the uploaded parts are not
+		// orphaned.
+		if abortErr := s.Abort(context.WithoutCancel(ctx)); abortErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to abort upload %s: %w", s.ID(), abortErr))
+		}
+		return nil, err
+	}
+	return &uploadRecord{
+		Bucket:      dst.Bucket,
+		Key:         dst.Key,
+		UploadID:    s.ID(),
+		ETag:        result.ETag,
+		Generation:  result.Generation,
+		Parts:       len(s.Parts()),
+		PartSize:    p.partSize,
+		Concurrency: lim.concurrency(),
+	}, nil
+}
+
+// uploadParts uploads the parts of p to s, reading each part only once lim
+// allows another to be uploaded so at most that many are held in memory, and
+// completes the upload. After the first failure, no more parts are started
+// and those in flight are cancelled.
+func uploadParts(ctx context.Context, s *multipartclient.UploadSession, p *parts, lim *limiter, fp *fileProgress) (*multipartclient.CompleteMultipartUploadResult, error) {
+	partsCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var wg sync.WaitGroup
+	for partNumber := 1; partsCtx.Err() == nil; partNumber++ {
+		lim.acquire()
+		body, n, err := p.next()
+		last := false
+		if errors.Is(err, io.EOF) {
+			if partNumber > 1 {
+				lim.release(-1)
+				break
+			}
+			// An upload needs at least one part, even for an empty object.
+			body, last = multipartclient.NewSectionBody(bytes.NewReader(nil), 0, 0), true
+		} else if err != nil {
+			lim.release(-1)
+			cancel(err)
+			break
+		}
+		if partNumber > multipartclient.MaxParts {
+			body.Close()
+			lim.release(-1)
+			cancel(fmt.Errorf("input needs more than %d parts; use a larger -part-size", multipartclient.MaxParts))
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.UploadPart(partsCtx, partNumber, body); err != nil {
+				lim.release(-1)
+				cancel(fmt.Errorf("failed to upload part %d: %w", partNumber, err))
+				return
+			}
+			lim.release(int(n))
+			fp.partDone(int(n))
+		}()
+		if last {
+			break
+		}
+	}
+	wg.Wait()
+	if err := context.Cause(partsCtx); err != nil {
+		return nil, err
+	}
+	return s.Complete(ctx)
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multipartclienttest"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multiparttest"
+)
+
+// testPartSize is the -part-size of the tests, the smallest allowed.
+var testPartSize = strconv.Itoa(multipartclient.MinPartSize)
+
+// partsData returns data of n parts of testPartSize bytes, the last of them
+// half full.
+func partsData(n int) string {
+	return strings.Repeat("0123456789abcdef", ((n-1)*multipartclient.MinPartSize+multipartclient.MinPartSize/2)/16)
+}
+
+func writeTempFile(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestUpload(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		wantParts string
+	}{
+		{
+			name:      "Several parts",
+			data:      partsData(3),
+			wantParts: "in 3 parts",
+		},
+		{
+			name:      "Empty file",
+			data:      "",
+			wantParts: "in 1 parts",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := multiparttest.NewServer(t)
+			path := writeTempFile(t, tc.data)
+
+			te := newTestEnv(srv.Client())
+			te.run(t, 0, "upload", "-part-size", testPartSize, path, "gs://bucket1/dir/file1.txt")
+
+			got, ok := srv.Object("bucket1", "dir/file1.txt")
+			if !ok || string(got) != tc.data {
+				t.Errorf("got object of %d bytes (exists %v), want %d bytes", len(got), ok, len(tc.data))
+			}
+			if !strings.Contains(te.stdout.String(), tc.wantParts) {
+				t.Errorf("got stdout %q, want it to contain %q", te.stdout.String(), tc.wantParts)
+			}
+		})
+	}
+}
+
+func TestUploadAbortsOnFailure(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	ft := multipartclienttest.NewFaultTransport(srv.Transport(), &multipartclienttest.Fault{
+		Match:          multipartclienttest.MatchPart(2),
+		DropConnection: true,
+	})
+	path := writeTempFile(t, partsData(3))
+
+	te := newTestEnv(ft.Client())
+	te.run(t, 1, "upload", "-part-size", testPartSize, path, "gs://bucket1/file1.txt")
+
+	if !strings.Contains(te.stderr.String(), "failed to upload part 2") {
+		t.Errorf("got stderr %q, want it to report part 2", te.stderr.String())
+	}
+	if uploads := srv.Uploads(); len(uploads) != 0 {
+		t.Errorf("got uploads %v after a failed upload, want none", uploads)
+	}
+}
+
+func TestUploadMissingFile(t *testing.T) {
+	te := newTestEnv(nil)
+	te.run(t, 1, "upload", filepath.Join(t.TempDir(), "missing"), "gs://bucket1/file1.txt")
+}
+
+func TestUploadConcurrency(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "Sequential", args: []string{"-concurrency", "1"}},
+		{name: "Concurrent", args: []string{"-concurrency", "3"}},
+		{name: "Auto-tune", args: []string{"-auto-tune", "-concurrency", "8"}},
+	}
+
+	data := partsData(4)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := multiparttest.NewServer(t)
+			path := writeTempFile(t, data)
+
+			te := newTestEnv(srv.Client())
+			args := append([]string{"upload", "-part-size", testPartSize}, tc.args...)
+			te.run(t, 0, append(args, path, "gs://bucket1/file1.txt")...)
+
+			got, ok := srv.Object("bucket1", "file1.txt")
+			if !ok || string(got) != data {
+				t.Errorf("got object of %d bytes (exists %v), want %d bytes", len(got), ok, len(data))
+			}
+			if !strings.Contains(te.stdout.String(), "in 4 parts") {
+				t.Errorf("got stdout %q, want it to report 4 parts", te.stdout.String())
+			}
+		})
+	}
+}
+
+func TestUploadContentDefinedChunking(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	data := make([]byte, 4*multipartclient.MinPartSize)
+	rand.New(rand.NewSource(1)).Read(data)
+	path := writeTempFile(t, string(data))
+
+	te := newTestEnv(srv.Client())
+	te.run(t, 0, "upload", "-chunking", "content", "-part-size", testPartSize, path, "gs://bucket1/file1.txt")
+
+	if got, ok := srv.Object("bucket1", "file1.txt"); !ok || !bytes.Equal(got, data) {
+		t.Errorf("got object of %d bytes (exists %v), want %d bytes", len(got), ok, len(data))
+	}
+	p, err := newParts(bytes.NewReader(data), int64(len(data)), multipartclient.MinPartSize, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := 0
+	for {
+		body, _, err := p.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body.Close()
+		parts++
+	}
+	if want := fmt.Sprintf("in %d parts", parts); !strings.Contains(te.stdout.String(), want) {
+		t.Errorf("got stdout %q, want it to contain %q", te.stdout.String(), want)
+	}
+}
+
+func TestUploadInvalidTuning(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantStderr string
+	}{
+		{
+			name:       "Zero concurrency",
+			args:       []string{"-concurrency", "0"},
+			wantStderr: "-concurrency must be positive, got 0",
+		},
+		{
+			name:       "Small part size",
+			args:       []string{"-part-size", "1"},
+			wantStderr: "-part-size must be between 5242880 and 5368709120, got 1",
+		},
+		{
+			name:       "Unknown chunking",
+			args:       []string{"-chunking", "random"},
+			wantStderr: `-chunking must be fixed or content, got "random"`,
+		},
+		{
+			name:       "Large part size",
+			args:       []string{"-part-size", "5368709121"},
+			wantStderr: "-part-size must be between 5242880 and 5368709120, got 5368709121",
+		},
+	}
+
+	path := writeTempFile(t, "data")
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			te := newTestEnv(nil)
+			args := append(append([]string{"upload"}, tc.args...), path, "gs://bucket1/file1.txt")
+			te.run(t, 2, args...)
+			if !strings.Contains(te.stderr.String(), tc.wantStderr) {
+				t.Errorf("got stderr %q, want it to contain %q", te.stderr.String(), tc.wantStderr)
+			}
+		})
+	}
+}
+
+func TestUploadStdin(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	data := partsData(3)
+
+	te := newTestEnv(srv.Client())
+	te.stdin = strings.NewReader(data)
+	te.interactive = true
+	te.run(t, 0, "upload", "-part-size", testPartSize, "-", "gs://bucket1/backup.sql")
+
+	got, ok := srv.Object("bucket1", "backup.sql")
+	if !ok || string(got) != data {
+		t.Errorf("got object of %d bytes (exists %v), want %d bytes", len(got), ok, len(data))
+	}
+	if want := "uploaded - to gs://bucket1/backup.sql in 3 parts"; !strings.Contains(te.stdout.String(), want) {
+		t.Errorf("got stdout %q, want it to contain %q", te.stdout.String(), want)
+	}
+	lines := progressLines(te.stderr.String())
+	if want := "stdin: " + formatBytes(int64(len(data))); len(lines) == 0 || !strings.Contains(lines[len(lines)-1], want) {
+		t.Errorf("got progress %q, want it to end with %q", lines, want)
+	}
+}
+
+func TestUploadStdinTooLong(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	srv.MinPartSize = 1
+
+	// Parts of a byte, which -part-size doesn't allow, keep the input small.
+	p, err := newParts(strings.NewReader(strings.Repeat("x", multipartclient.MaxParts+1)), -1, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := multipartclient.ObjectRef{Bucket: "bucket1", Key: "backup.sql"}
+	_, err = upload(context.Background(), multipartclient.New(srv.Client()), p, dst, newLimiter(16, nil), nil)
+	if want := "input needs more than 10000 parts"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("got error %v, want it to contain %q", err, want)
+	}
+	if uploads := srv.Uploads(); len(uploads) != 0 {
+		t.Errorf("got uploads %v after a failed upload, want none", uploads)
+	}
+}
+
+func TestUploadResendsStdinParts(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	ft := multipartclienttest.NewFaultTransport(srv.Transport(), &multipartclienttest.Fault{
+		Match: multipartclienttest.MatchPart(2),
+		Nth:   1,
+		Error: func() *http.Response {
+			return multipartclienttest.ErrorResponse(http.StatusServiceUnavailable, "ServiceUnavailable", "Try again.")
+		},
+	})
+	mpuc := multipartclient.New(ft.Client(), multipartclient.WithRetry(multipartclient.RetryConfig{MaxAttempts: 2}))
+	data := partsData(3)
+
+	// Parts of stdin are read into memory, and sent again from there when a
+	// request is retried.
+	p, err := newParts(strings.NewReader(data), -1, multipartclient.MinPartSize, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := multipartclient.ObjectRef{Bucket: "bucket1", Key: "backup.sql"}
+	if _, err := upload(context.Background(), mpuc, p, dst, newLimiter(2, nil), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := srv.Object("bucket1", "backup.sql"); !ok || string(got) != data {
+		t.Errorf("got object of %d bytes (exists %v), want %d bytes", len(got), ok, len(data))
+	}
+}
@@ -0,0 +1,53 @@
+package multipartclient
+
+import (
+	"context"
+	"io"
+	"io/fs"
+)
+
+//go:generate go run go.uber.org/mock/mockgen@v0.4.0 -source=api.go -destination=multipartclientmock/multipartclientmock.go -package=multipartclientmock -typed
+
+// MultipartAPI is the set of operations of a *MultipartClient. Code that
+// depends on MultipartAPI rather than the client can be unit tested with the
+// generated mock in the multipartclientmock package instead of a fake server.
+type MultipartAPI interface {
+	InitiateMultipartUpload(ctx context.Context, req *InitiateMultipartUploadRequest) (*InitiateMultipartUploadResult, error)
+	UploadObjectPart(ctx context.Context, req *UploadObjectPartRequest) (*UploadObjectPartResult, error)
+	UploadPartCopy(ctx context.Context, req *UploadPartCopyRequest) (*CopyPartResult, error)
+	CompleteMultipartUpload(ctx context.Context, req *CompleteMultipartUploadRequest) (*CompleteMultipartUploadResult, error)
+	MarshalCompleteBody(body CompleteMultipartUploadBody) ([]byte, error)
+	AbortMultipartUpload(ctx context.Context, req *AbortMultipartUploadRequest) error
+	ListMultipartUploads(ctx context.Context, req *ListMultipartUploadsRequest) (*ListMultipartUploadsResult, error)
+	ListObjectParts(ctx context.Context, req *ListObjectPartsRequest) (*ListObjectPartsResult, error)
+	Uploads(ctx context.Context, req *ListMultipartUploadsRequest) *UploadIterator
+	ListMultipartUploadsPages(ctx context.Context, req *ListMultipartUploadsRequest, fn func(*ListMultipartUploadsResult) bool) error
+	Parts(ctx context.Context, req *ListObjectPartsRequest) *PartIterator
+	ListAllObjectParts(ctx context.Context, req *ListObjectPartsRequest) ([]CompletePart, error)
+	ValidateUploadedParts(ctx context.Context, req *ListObjectPartsRequest, records []PartRecord) (*PartValidation, error)
+	Rewrite(ctx context.Context, src ObjectRef, req *InitiateMultipartUploadRequest, partPlan []ByteRange) (*CompleteMultipartUploadResult, error)
+	RewriteWithOptions(ctx context.Context, src ObjectRef, req *InitiateMultipartUploadRequest, partPlan []ByteRange, opts *RewriteOptions) (*CompleteMultipartUploadResult, error)
+	HealthCheck(ctx context.Context, bucket string) (*HealthCheckResult, error)
+	Warmup(ctx context.Context, n int) (int, error)
+	StatObject(ctx context.Context, ref ObjectRef) (*ObjectAttrs, error)
+	PatchObjectMetadata(ctx context.Context, req *PatchObjectMetadataRequest) (*ObjectAttrs, error)
+	UploadFS(ctx context.Context, bucket, prefix string, fsys fs.FS) ([]UploadedFile, error)
+	UploadFSWithOptions(ctx context.Context, bucket, prefix string, fsys fs.FS, opts *UploadFSOptions) ([]UploadedFile, error)
+	Stats() Stats
+	Bucket(name string) *BucketHandle
+	NewSession(ctx context.Context, req *InitiateMultipartUploadRequest) (*UploadSession, error)
+	AttachSession(ctx context.Context, bucket, key, uploadID string) (*UploadSession, error)
+	ResumeSession(ctx context.Context, cp *Checkpoint) (*UploadSession, error)
+}
+
+var _ MultipartAPI = (*MultipartClient)(nil)
+
+// UploaderAPI is the set of operations of an *Uploader, which code that
+// uploads objects can depend on to be unit tested with the generated mock in
+// the multipartclientmock package.
+type UploaderAPI interface {
+	Upload(ctx context.Context, req *InitiateMultipartUploadRequest, r io.Reader) (*CompleteMultipartUploadResult, error)
+	UploadFile(ctx context.Context, req *InitiateMultipartUploadRequest, name string) (*CompleteMultipartUploadResult, error)
+}
+
+var _ UploaderAPI = (*Uploader)(nil)
@@ -0,0 +1,80 @@
+package multipartclient_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multipartclientmock"
+	"go.uber.org/mock/gomock"
+)
+
+// abortAll stands in for application code that depends on MultipartAPI.
+func abortAll(ctx context.Context, api multipartclient.MultipartAPI, bucket string) error {
+	uploads, err := api.ListMultipartUploads(ctx, &multipartclient.ListMultipartUploadsRequest{Bucket: bucket})
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, u := range uploads.Uploads {
+		errs = append(errs, api.AbortMultipartUpload(ctx, &multipartclient.AbortMultipartUploadRequest{Bucket: bucket, UploadID: u.UploadID}))
+	}
+	return errors.Join(errs...)
+}
+
+func TestMockMultipartAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	api := multipartclientmock.NewMockMultipartAPI(ctrl)
+	ctx := context.Background()
+
+	api.EXPECT().
+		ListMultipartUploads(ctx, &multipartclient.ListMultipartUploadsRequest{Bucket: "bucket1"}).
+		Return(&multipartclient.ListMultipartUploadsResult{Uploads: []multipartclient.ListUpload{{UploadID: "upload-1"}, {UploadID: "upload-2"}}}, nil)
+	api.EXPECT().
+		AbortMultipartUpload(ctx, &multipartclient.AbortMultipartUploadRequest{Bucket: "bucket1", UploadID: "upload-1"}).
+		Return(nil)
+	errAbort := errors.New("abort failed")
+	api.EXPECT().
+		AbortMultipartUpload(ctx, &multipartclient.AbortMultipartUploadRequest{Bucket: "bucket1", UploadID: "upload-2"}).
+		Return(errAbort)
+
+	if err := abortAll(ctx, api, "bucket1"); !errors.Is(err, errAbort) {
+		t.Errorf("got error %v, want %v", err, errAbort)
+	}
+}
+
+// backup stands in for application code that depends on UploaderAPI.
+func backup(ctx context.Context, u multipartclient.UploaderAPI, names ...string) ([]string, error) {
+	var etags []string
+	for _, name := range names {
+		result, err := u.UploadFile(ctx, &multipartclient.InitiateMultipartUploadRequest{Bucket: "backups", Key: name}, name)
+		if err != nil {
+			return etags, err
+		}
+		etags = append(etags, result.ETag)
+	}
+	return etags, nil
+}
+
+func TestMockUploaderAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	u := multipartclientmock.NewMockUploaderAPI(ctrl)
+	ctx := context.Background()
+
+	u.EXPECT().
+		UploadFile(ctx, &multipartclient.InitiateMultipartUploadRequest{Bucket: "backups", Key: "a.tar"}, "a.tar").
+		Return(&multipartclient.CompleteMultipartUploadResult{ETag: `"etag-a"`}, nil)
+	errUpload := errors.New("upload failed")
+	u.EXPECT().
+		UploadFile(ctx, &multipartclient.InitiateMultipartUploadRequest{Bucket: "backups", Key: "b.tar"}, "b.tar").
+		Return(nil, errUpload)
+
+	etags, err := backup(ctx, u, "a.tar", "b.tar", "c.tar")
+	if !errors.Is(err, errUpload) {
+		t.Errorf("got error %v, want %v", err, errUpload)
+	}
+	if len(etags) != 1 || etags[0] != `"etag-a"` {
+		t.Errorf("got ETags %q, want only that of a.tar", etags)
+	}
+}
@@ -0,0 +1,72 @@
+package multipartclient
+
+import (
+	"context"
+	"time"
+)
+
+// Audit results.
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditRecord describes one call to an operation of the client. The JSON
+// encoding is stable so records can be shipped to a SIEM as is.
+type AuditRecord struct {
+	Time time.Time `json:"time"`
+	// Principal is the caller-supplied identity from ContextWithPrincipal.
+	Principal string `json:"principal,omitempty"`
+	Operation string `json:"operation"`
+	Bucket    string `json:"bucket"`
+	Key       string `json:"key,omitempty"`
+	UploadID  string `json:"upload_id,omitempty"`
+	// PartNumber is set for UploadObjectPart and UploadPartCopy.
+	PartNumber int `json:"part_number,omitempty"`
+	// Result is AuditSuccess or AuditFailure.
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+	// Bytes is the part data sent, including retries.
+	Bytes    int64         `json:"bytes"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+type principalKey struct{}
+
+// ContextWithPrincipal returns a copy of ctx whose operations are attributed
+// to principal in AuditRecords. The client does not verify it.
+func ContextWithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal set by ContextWithPrincipal, or
+// "" if there is none.
+func PrincipalFromContext(ctx context.Context) string {
+	principal, _ := ctx.Value(principalKey{}).(string)
+	return principal
+}
+
+// audit passes the AuditRecord of an operation to the hook set by
+// WithAuditHook.
+func (mpuc *MultipartClient) audit(ctx context.Context, op string, info operationInfo, start time.Time, err error) {
+	if mpuc.auditHook == nil {
+		return
+	}
+	rec := AuditRecord{
+		Time:       start,
+		Principal:  PrincipalFromContext(ctx),
+		Operation:  op,
+		Bucket:     info.Bucket,
+		Key:        info.Key,
+		UploadID:   info.UploadID,
+		PartNumber: info.PartNumber,
+		Result:     AuditSuccess,
+		Bytes:      info.Bytes,
+		Duration:   mpuc.since(start),
+	}
+	if err != nil {
+		rec.Result = AuditFailure
+		rec.Error = err.Error()
+	}
+	mpuc.auditHook(rec)
+}
@@ -0,0 +1,116 @@
+package multipartclient
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestAuditHook(t *testing.T) {
+	const contents = "part contents"
+	trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+		if req.Body != nil {
+			if _, err := io.Copy(io.Discard, req.Body); err != nil {
+				t.Fatal(err)
+			}
+		}
+		switch req.Method {
+		case http.MethodPost:
+			return &http.Response{StatusCode: http.StatusOK, Status: "OK", Body: io.NopCloser(strings.NewReader(
+				`<InitiateMultipartUploadResult><Bucket>bucket1</Bucket><Key>object.txt</Key><UploadId>my-upload-id</UploadId></InitiateMultipartUploadResult>`))}, nil
+		case http.MethodDelete:
+			return &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found", Body: http.NoBody}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Status: "OK", Header: http.Header{}, Body: http.NoBody}, nil
+	})
+
+	var mu sync.Mutex
+	var records []AuditRecord
+	mpuc := New(&http.Client{Transport: trans}, WithAuditHook(func(rec AuditRecord) {
+		mu.Lock()
+		defer mu.Unlock()
+		records = append(records, rec)
+	}))
+
+	ctx := ContextWithPrincipal(context.Background(), "svc-backup@example.com")
+	if _, err := mpuc.InitiateMultipartUpload(ctx, &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mpuc.UploadObjectPart(ctx, &UploadObjectPartRequest{
+		Bucket:     "bucket1",
+		Key:        "object.txt",
+		PartNumber: 1,
+		UploadID:   "my-upload-id",
+		Body:       toBody(contents),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mpuc.AbortMultipartUpload(context.Background(), &AbortMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt", UploadID: "my-upload-id"}); err == nil {
+		t.Fatal("expected abort error")
+	}
+
+	want := []AuditRecord{
+		{
+			Principal: "svc-backup@example.com",
+			Operation: OpInitiateMultipartUpload,
+			Bucket:    "bucket1",
+			Key:       "object.txt",
+			UploadID:  "my-upload-id",
+			Result:    AuditSuccess,
+		},
+		{
+			Principal:  "svc-backup@example.com",
+			Operation:  OpUploadObjectPart,
+			Bucket:     "bucket1",
+			Key:        "object.txt",
+			UploadID:   "my-upload-id",
+			PartNumber: 1,
+			Result:     AuditSuccess,
+			Bytes:      int64(len(contents)),
+		},
+		{
+			Operation: OpAbortMultipartUpload,
+			Bucket:    "bucket1",
+			Key:       "object.txt",
+			UploadID:  "my-upload-id",
+			Result:    AuditFailure,
+			Error:     "404 Not Found",
+		},
+	}
+	if diff := cmp.Diff(want, records, cmpopts.IgnoreFields(AuditRecord{}, "Time", "Duration")); diff != "" {
+		t.Errorf("unexpected diff for audit records: (-want, +got):\n%s", diff)
+	}
+	for i, rec := range records {
+		if rec.Time.IsZero() {
+			t.Errorf("record %d: Time not set", i)
+		}
+	}
+}
+
+func TestAuditRecordJSON(t *testing.T) {
+	rec := AuditRecord{
+		Time:      time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+		Operation: OpCompleteMultipartUpload,
+		Bucket:    "bucket1",
+		Key:       "object.txt",
+		UploadID:  "my-upload-id",
+		Result:    AuditSuccess,
+		Duration:  time.Second,
+	}
+	got, err := json.Marshal(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"time":"2024-06-01T12:00:00Z","operation":"CompleteMultipartUpload","bucket":"bucket1","key":"object.txt","upload_id":"my-upload-id","result":"success","bytes":0,"duration_ns":1000000000}`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("unexpected diff for JSON: (-want, +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,143 @@
+package multipartclient
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"net/http"
+	"time"
+)
+
+// userProjectHeader names the project billed for a request, as for
+// Requester Pays buckets.
+const userProjectHeader = "x-goog-user-project"
+
+// BucketConfig holds the defaults of requests to one bucket, set with
+// WithBucketConfig. The fields of a request, or ContextWithUserProject,
+// override them.
+type BucketConfig struct {
+	// StorageClass is the storage class of uploaded objects, such as
+	// "NEARLINE".
+	StorageClass string
+	// KMSKeyName is the Cloud KMS key that encrypts uploaded objects, as
+	// projects/P/locations/L/keyRings/R/cryptoKeys/K. With
+	// WithS3Compatibility, it is the server's KMS key ID.
+	KMSKeyName string
+	// ACL is the predefined ACL of uploaded objects, such as
+	// "bucket-owner-full-control".
+	ACL string
+	// Metadata is the custom metadata of uploaded objects. A request's
+	// Metadata is merged over it, key by key.
+	Metadata map[string]string
+	// UserProject is billed for every request to the bucket. It isn't sent
+	// to S3-compatible servers.
+	UserProject string
+}
+
+// WithBucketConfig sets the defaults of requests to bucket, replacing those
+// of an earlier WithBucketConfig for the same bucket.
+func WithBucketConfig(bucket string, cfg BucketConfig) Option {
+	return func(mpuc *MultipartClient) {
+		if mpuc.buckets == nil {
+			mpuc.buckets = make(map[string]*BucketConfig)
+		}
+		cfg.Metadata = maps.Clone(cfg.Metadata)
+		mpuc.buckets[bucket] = &cfg
+	}
+}
+
+// bucketConfig returns the defaults of requests to bucket, which are zero if
+// it has no BucketConfig.
+func (mpuc *MultipartClient) bucketConfig(bucket string) *BucketConfig {
+	if cfg, ok := mpuc.buckets[bucket]; ok {
+		return cfg
+	}
+	return &BucketConfig{}
+}
+
+type userProjectKey struct{}
+
+// ContextWithUserProject returns a copy of ctx whose requests bill project,
+// overriding the UserProject of the bucket's BucketConfig.
+func ContextWithUserProject(ctx context.Context, project string) context.Context {
+	return context.WithValue(ctx, userProjectKey{}, project)
+}
+
+// UserProjectFromContext returns the project set by ContextWithUserProject,
+// or "" if there is none.
+func UserProjectFromContext(ctx context.Context) string {
+	project, _ := ctx.Value(userProjectKey{}).(string)
+	return project
+}
+
+// setUserProject sets the project billed for httpReq, a request to bucket.
+func (mpuc *MultipartClient) setUserProject(ctx context.Context, httpReq *http.Request, bucket string) {
+	if mpuc.compat != nil {
+		return
+	}
+	project := UserProjectFromContext(ctx)
+	if project == "" {
+		project = mpuc.bucketConfig(bucket).UserProject
+	}
+	if project != "" {
+		httpReq.Header.Set(userProjectHeader, project)
+	}
+}
+
+// setObjectHeaders sets the headers of the object settings of req, falling
+// back to those of its bucket's BucketConfig, on httpReq.
+func (mpuc *MultipartClient) setObjectHeaders(httpReq *http.Request, req *InitiateMultipartUploadRequest) {
+	cfg := mpuc.bucketConfig(req.Bucket)
+	if class := cmp.Or(req.StorageClass, cfg.StorageClass); class != "" {
+		httpReq.Header.Set(mpuc.header("x-goog-storage-class"), class)
+	}
+	if acl := cmp.Or(req.PredefinedACL, req.ACL, cfg.ACL); acl != "" {
+		httpReq.Header.Set(mpuc.header("x-goog-acl"), acl)
+	}
+	for name, v := range map[string]string{
+		"Cache-Control":       req.CacheControl,
+		"Content-Type":        req.ContentType,
+		"Content-Disposition": req.ContentDisposition,
+		"Content-Encoding":    req.ContentEncoding,
+		"Content-Language":    req.ContentLanguage,
+	} {
+		if v != "" {
+			httpReq.Header.Set(name, v)
+		}
+	}
+	if key := cmp.Or(req.KMSKeyName, cfg.KMSKeyName); key != "" {
+		if mpuc.compat == nil {
+			httpReq.Header.Set("x-goog-encryption-kms-key-name", key)
+		} else {
+			httpReq.Header.Set("x-amz-server-side-encryption", "aws:kms")
+			httpReq.Header.Set("x-amz-server-side-encryption-aws-kms-key-id", key)
+		}
+	}
+	if req.RetentionMode != "" {
+		if mpuc.compat == nil {
+			httpReq.Header.Set("x-goog-object-retention-mode", req.RetentionMode)
+			httpReq.Header.Set("x-goog-object-retention-retain-until-time", req.RetainUntil.UTC().Format(time.RFC3339))
+		} else {
+			httpReq.Header.Set("x-amz-object-lock-mode", req.RetentionMode)
+			httpReq.Header.Set("x-amz-object-lock-retain-until-date", req.RetainUntil.UTC().Format(time.RFC3339))
+		}
+	}
+	metaPrefix := mpuc.header("x-goog-meta-")
+	for k, v := range cfg.Metadata {
+		httpReq.Header.Set(metaPrefix+k, v)
+	}
+	for k, v := range req.Metadata {
+		httpReq.Header.Set(metaPrefix+k, v)
+	}
+}
+
+// kmsKeyNameOf returns the KMS key that encrypts the object of resp, or "" if
+// the response doesn't name one. Cloud Storage names the key version, as
+// projects/P/locations/L/keyRings/R/cryptoKeys/K/cryptoKeyVersions/V; an
+// S3-compatible server sends the key ID of aws:kms encryption.
+func (mpuc *MultipartClient) kmsKeyNameOf(resp *http.Response) string {
+	if mpuc.compat == nil {
+		return resp.Header.Get("x-goog-encryption-kms-key-name")
+	}
+	return resp.Header.Get("x-amz-server-side-encryption-aws-kms-key-id")
+}
@@ -0,0 +1,210 @@
+package multipartclient
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWithBucketConfig(t *testing.T) {
+	cfg := BucketConfig{
+		StorageClass: "NEARLINE",
+		KMSKeyName:   "projects/p/locations/us/keyRings/r/cryptoKeys/k",
+		ACL:          "bucket-owner-full-control",
+		Metadata:     map[string]string{"owner": "team1", "tier": "cold"},
+		UserProject:  "project1",
+	}
+	tests := []struct {
+		name   string
+		opts   []Option
+		ctx    context.Context
+		req    *InitiateMultipartUploadRequest
+		header http.Header
+	}{
+		{
+			name: "Defaults",
+			req:  &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt"},
+			header: http.Header{
+				"X-Goog-Storage-Class":           {"NEARLINE"},
+				"X-Goog-Encryption-Kms-Key-Name": {"projects/p/locations/us/keyRings/r/cryptoKeys/k"},
+				"X-Goog-Acl":                     {"bucket-owner-full-control"},
+				"X-Goog-Meta-Owner":              {"team1"},
+				"X-Goog-Meta-Tier":               {"cold"},
+				"X-Goog-User-Project":            {"project1"},
+			},
+		},
+		{
+			name: "Request overrides",
+			ctx:  ContextWithUserProject(context.Background(), "project2"),
+			req: &InitiateMultipartUploadRequest{
+				Bucket:        "bucket1",
+				Key:           "object.txt",
+				StorageClass:  "STANDARD",
+				PredefinedACL: "private",
+				ACL:           "publicRead",
+				Metadata:      map[string]string{"owner": "team2"},
+			},
+			header: http.Header{
+				"X-Goog-Storage-Class":           {"STANDARD"},
+				"X-Goog-Encryption-Kms-Key-Name": {"projects/p/locations/us/keyRings/r/cryptoKeys/k"},
+				"X-Goog-Acl":                     {"private"},
+				"X-Goog-Meta-Owner":              {"team2"},
+				"X-Goog-Meta-Tier":               {"cold"},
+				"X-Goog-User-Project":            {"project2"},
+			},
+		},
+		{
+			name:   "Deprecated ACL",
+			req:    &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt", ACL: "publicRead"},
+			header: http.Header{"X-Goog-Acl": {"publicRead"}},
+		},
+		{
+			name: "Content headers",
+			req: &InitiateMultipartUploadRequest{
+				Bucket:             "bucket2",
+				Key:                "object.txt",
+				CacheControl:       "no-cache",
+				ContentType:        "text/plain; charset=utf-8",
+				ContentDisposition: `attachment; filename="object.txt"`,
+				ContentEncoding:    "gzip",
+				ContentLanguage:    "en",
+			},
+			header: http.Header{
+				"Cache-Control":       {"no-cache"},
+				"Content-Type":        {"text/plain; charset=utf-8"},
+				"Content-Disposition": {`attachment; filename="object.txt"`},
+				"Content-Encoding":    {"gzip"},
+				"Content-Language":    {"en"},
+			},
+		},
+		{
+			name:   "Other bucket",
+			req:    &InitiateMultipartUploadRequest{Bucket: "bucket2", Key: "object.txt", StorageClass: "ARCHIVE"},
+			header: http.Header{"X-Goog-Storage-Class": {"ARCHIVE"}},
+		},
+		{
+			name: "Retention",
+			req: &InitiateMultipartUploadRequest{
+				Bucket:        "bucket2",
+				Key:           "object.txt",
+				RetentionMode: "Locked",
+				RetainUntil:   time.Date(2030, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600)),
+			},
+			header: http.Header{
+				"X-Goog-Object-Retention-Mode":              {"Locked"},
+				"X-Goog-Object-Retention-Retain-Until-Time": {"2030-01-02T02:04:05Z"},
+			},
+		},
+		{
+			name: "S3 retention",
+			opts: []Option{WithS3Compatibility(S3Compatibility{})},
+			req: &InitiateMultipartUploadRequest{
+				Bucket:        "bucket2",
+				Key:           "object.txt",
+				RetentionMode: "COMPLIANCE",
+				RetainUntil:   time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+			},
+			header: http.Header{
+				"X-Amz-Object-Lock-Mode":              {"COMPLIANCE"},
+				"X-Amz-Object-Lock-Retain-Until-Date": {"2030-01-02T03:04:05Z"},
+			},
+		},
+		{
+			name: "S3 compatibility",
+			opts: []Option{WithS3Compatibility(S3Compatibility{})},
+			req:  &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt"},
+			header: http.Header{
+				"X-Amz-Storage-Class":                         {"NEARLINE"},
+				"X-Amz-Server-Side-Encryption":                {"aws:kms"},
+				"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id": {"projects/p/locations/us/keyRings/r/cryptoKeys/k"},
+				"X-Amz-Acl":        {"bucket-owner-full-control"},
+				"X-Amz-Meta-Owner": {"team1"},
+				"X-Amz-Meta-Tier":  {"cold"},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got http.Header
+			hc := &http.Client{Transport: funcTransport(func(req *http.Request) (*http.Response, error) {
+				got = req.Header.Clone()
+				return nil, errMock
+			})}
+			mpuc := New(hc, append([]Option{WithBucketConfig("bucket1", cfg)}, tc.opts...)...)
+			ctx := tc.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			mpuc.InitiateMultipartUpload(ctx, tc.req)
+			for name := range got {
+				if _, ok := tc.header[name]; !ok {
+					got.Del(name)
+				}
+			}
+			if diff := cmp.Diff(tc.header, got); diff != "" {
+				t.Errorf("unexpected diff for headers (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestInitiateKMSKeyName(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []Option
+		header http.Header
+	}{
+		{
+			name:   "Cloud Storage",
+			header: http.Header{"X-Goog-Encryption-Kms-Key-Name": {"projects/p/locations/us/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1"}},
+		},
+		{
+			name:   "S3 compatibility",
+			opts:   []Option{WithS3Compatibility(S3Compatibility{})},
+			header: http.Header{"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id": {"projects/p/locations/us/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1"}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			hc := &http.Client{Transport: funcTransport(func(req *http.Request) (*http.Response, error) {
+				resp := xmlResponse("<InitiateMultipartUploadResult><UploadId>u1</UploadId></InitiateMultipartUploadResult>")
+				for name, v := range tc.header {
+					resp.Header[name] = v
+				}
+				return resp, nil
+			})}
+			mpuc := New(hc, tc.opts...)
+			result, err := mpuc.InitiateMultipartUpload(context.Background(), &InitiateMultipartUploadRequest{
+				Bucket:     "bucket1",
+				Key:        "object.txt",
+				KMSKeyName: "projects/p/locations/us/keyRings/r/cryptoKeys/k",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := "projects/p/locations/us/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1"; result.KMSKeyName != want {
+				t.Errorf("got KMSKeyName %q, want %q", result.KMSKeyName, want)
+			}
+		})
+	}
+}
+
+func TestBucketConfigUserProject(t *testing.T) {
+	var got []string
+	hc := &http.Client{Transport: funcTransport(func(req *http.Request) (*http.Response, error) {
+		got = append(got, req.Header.Get(userProjectHeader))
+		return nil, errMock
+	})}
+	mpuc := New(hc, WithBucketConfig("bucket1", BucketConfig{UserProject: "project1"}))
+	ctx := context.Background()
+	mpuc.AbortMultipartUpload(ctx, &AbortMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt", UploadID: "u1"})
+	mpuc.ListObjectParts(ctx, &ListObjectPartsRequest{Bucket: "bucket1", Key: "object.txt", UploadID: "u1"})
+	mpuc.StatObject(ctx, ObjectRef{Bucket: "bucket1", Key: "object.txt"})
+	mpuc.StatObject(ctx, ObjectRef{Bucket: "bucket2", Key: "object.txt"})
+	if diff := cmp.Diff([]string{"project1", "project1", "project1", ""}, got); diff != "" {
+		t.Errorf("unexpected diff for user projects (-want, +got):\n%s", diff)
+	}
+}
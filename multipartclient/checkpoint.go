@@ -0,0 +1,104 @@
+package multipartclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Checkpoint is the state of an UploadSession, saved by a Checkpointer so
+// that a process that restarts can resume the upload with ResumeSession
+// instead of starting over. It is serialized as JSON.
+type Checkpoint struct {
+	Bucket   string
+	Key      string
+	UploadID string
+	// PartSize is the size of every part but the last, as an Uploader splits
+	// the data. An Uploader resumes only the uploads it split alike.
+	PartSize int64 `json:",omitempty"`
+	// Parts are the records of the uploaded parts in ascending order of part
+	// number.
+	Parts []PartRecord
+	// Partial are the records of the parts being buffered, such as from a
+	// stream, with the HashState of their data so far, in ascending order of
+	// part number.
+	Partial []PartRecord `json:",omitempty"`
+}
+
+// Checkpointer saves the checkpoints of uploads, one per object. Set one with
+// UploadSession.SetCheckpointer or UploaderOptions.Checkpointer. A
+// Checkpointer must be safe for concurrent use.
+type Checkpointer interface {
+	// Load returns the checkpoint of the upload of the object key in
+	// bucket, or nil if there is none.
+	Load(ctx context.Context, bucket, key string) (*Checkpoint, error)
+	// Save saves cp, replacing any checkpoint of the same object.
+	Save(ctx context.Context, cp *Checkpoint) error
+	// Delete deletes the checkpoint of the object key in bucket, if any.
+	Delete(ctx context.Context, bucket, key string) error
+}
+
+// FileCheckpointer saves checkpoints as JSON files in the directory Dir, which
+// must exist. Files are replaced atomically, so a process that crashes while
+// saving leaves the previous checkpoint.
+type FileCheckpointer struct {
+	Dir string
+}
+
+// name returns the file name of the checkpoint of the object key in bucket.
+func (c *FileCheckpointer) name(bucket, key string) string {
+	// Bucket names can't contain "/", so the hash input is unambiguous.
+	return filepath.Join(c.Dir, fmt.Sprintf("%x.json", sha256.Sum256([]byte(bucket+"/"+key))))
+}
+
+func (c *FileCheckpointer) Load(ctx context.Context, bucket, key string) (*Checkpoint, error) {
+	b, err := os.ReadFile(c.name(bucket, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cp := &Checkpoint{}
+	if err := json.Unmarshal(b, cp); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint for gs://%s/%s: %w", bucket, key, err)
+	}
+	return cp, nil
+}
+
+func (c *FileCheckpointer) Save(ctx context.Context, cp *Checkpoint) error {
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(c.Dir, ".checkpoint-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), c.name(cp.Bucket, cp.Key)); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+func (c *FileCheckpointer) Delete(ctx context.Context, bucket, key string) error {
+	if err := os.Remove(c.name(bucket, key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package multipartclient
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+)
+
+func TestFileCheckpointer(t *testing.T) {
+	ctx := context.Background()
+	c := &FileCheckpointer{Dir: t.TempDir()}
+
+	if cp, err := c.Load(ctx, "bucket1", "object.txt"); err != nil || cp != nil {
+		t.Fatalf("Load() of a missing checkpoint = %v, %v, want nil, nil", cp, err)
+	}
+	want := &Checkpoint{
+		Bucket:   "bucket1",
+		Key:      "dir/object.txt",
+		UploadID: "u1",
+		PartSize: MinPartSize,
+		Parts: []PartRecord{
+			{PartNumber: 1, ETag: `"e1"`, Hashes: gcshash.Sums{CRC32C: 7, HasCRC32C: true, MD5: []byte{1, 2, 3}}},
+			{PartNumber: 2, ETag: `"e2"`},
+		},
+	}
+	if err := c.Save(ctx, &Checkpoint{Bucket: "bucket1", Key: "dir/object.txt", UploadID: "u0"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Save(ctx, want); err != nil {
+		t.Fatal(err)
+	}
+	// Another object has its own checkpoint.
+	if err := c.Save(ctx, &Checkpoint{Bucket: "bucket1", Key: "other.txt", UploadID: "u2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.Load(ctx, "bucket1", "dir/object.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected diff for checkpoint (-want, +got):\n%s", diff)
+	}
+	if err := c.Delete(ctx, "bucket1", "dir/object.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if cp, err := c.Load(ctx, "bucket1", "dir/object.txt"); err != nil || cp != nil {
+		t.Errorf("Load() of a deleted checkpoint = %v, %v, want nil, nil", cp, err)
+	}
+	if err := c.Delete(ctx, "bucket1", "dir/object.txt"); err != nil {
+		t.Errorf("Delete() of a missing checkpoint failed: %v", err)
+	}
+	if cp, err := c.Load(ctx, "bucket1", "other.txt"); err != nil || cp == nil || cp.UploadID != "u2" {
+		t.Errorf("Load() of the other checkpoint = %v, %v, want upload u2", cp, err)
+	}
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d files, want only the other checkpoint", len(entries))
+	}
+}
@@ -0,0 +1,305 @@
+package multipartclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+)
+
+const defaultMaxChecksumAttempts = 3
+
+// ChecksumMismatchError is returned when the checksums the server reports for
+// an uploaded part differ from those computed locally.
+type ChecksumMismatchError struct {
+	PartNumber int
+	Local      gcshash.Sums
+	Server     gcshash.Sums
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("checksum mismatch for part %d: local %s, server %s", e.PartNumber, formatSums(e.Local), formatSums(e.Server))
+}
+
+func formatSums(s gcshash.Sums) string {
+	crc32c, md5 := "none", "none"
+	if s.HasCRC32C {
+		crc32c = gcshash.EncodeCRC32C(s.CRC32C)
+	}
+	if s.MD5 != nil {
+		md5 = gcshash.EncodeMD5(s.MD5)
+	}
+	return fmt.Sprintf("crc32c=%s md5=%s", crc32c, md5)
+}
+
+// InconsistentHashesError is returned when the checksums supplied with a part
+// don't match its data. The part is not sent.
+type InconsistentHashesError struct {
+	PartNumber int
+	Supplied   gcshash.Sums
+	Computed   gcshash.Sums
+}
+
+func (e *InconsistentHashesError) Error() string {
+	return fmt.Sprintf("supplied checksums for part %d don't match its data: supplied %s, computed %s", e.PartNumber, formatSums(e.Supplied), formatSums(e.Computed))
+}
+
+// checkSums compares the checksums reported by the server against the local
+// ones. Only checksums present on both sides are compared.
+func checkSums(partNumber int, local, server gcshash.Sums) error {
+	crc32cMismatch := local.HasCRC32C && server.HasCRC32C && server.CRC32C != local.CRC32C
+	md5Mismatch := local.MD5 != nil && server.MD5 != nil && !bytes.Equal(server.MD5, local.MD5)
+	if crc32cMismatch || md5Mismatch {
+		return &ChecksumMismatchError{PartNumber: partNumber, Local: local, Server: server}
+	}
+	return nil
+}
+
+func (mpuc *MultipartClient) uploadVerifiedObjectPart(ctx context.Context, req *UploadObjectPartRequest) (*UploadObjectPartResult, error) {
+	if req.Body == nil {
+		return nil, errors.New("VerifyChecksums requires a Body")
+	}
+	defer closeBody(req.Body)
+
+	// A seekable body can be re-sent after a mismatch and its length is known
+	// up front, as can one with GetBody; any other body gets a single
+	// attempt.
+	seeker, seekable := req.Body.(io.Seeker)
+	seekable = seekable && req.GetBody == nil
+	replayable := seekable || req.GetBody != nil
+	start, contentLength := int64(0), int64(-1)
+	maxAttempts := 1
+	if replayable {
+		maxAttempts = req.MaxChecksumAttempts
+		if maxAttempts <= 0 {
+			maxAttempts = defaultMaxChecksumAttempts
+		}
+	}
+	if seekable {
+		var err error
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+		end, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, err
+		}
+		contentLength = end - start
+		if err := mpuc.checkSuppliedHashes(req, seeker, start); err != nil {
+			return nil, err
+		}
+	}
+	if mpuc.partChecksums && !req.Hashes.HasCRC32C && req.Hashes.MD5 == nil && replayable {
+		sums, err := mpuc.precomputeHashes(req, seeker, start, seekable)
+		if err != nil {
+			return nil, err
+		}
+		withHashes := *req
+		withHashes.Hashes = sums
+		req = &withHashes
+	}
+
+	var mismatchErr error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			mpuc.metrics.RequestRetried(OpUploadObjectPart)
+			mpuc.stats.retries.Add(1)
+		}
+		if seekable {
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+		partBody := req.Body
+		if attempt > 0 && req.GetBody != nil {
+			r, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			defer r.Close()
+			partBody = r
+		}
+		// Hash the body as the HTTP client sends it. The HTTP client closes
+		// request bodies, so Close is hidden to be able to send it again.
+		hb := &hashingBody{mpuc: mpuc, r: partBody}
+		if err := hb.reset(); err != nil {
+			return nil, err
+		}
+		var body io.Reader = hb
+		var reopen func() (io.ReadCloser, error)
+		switch {
+		case req.GetBody != nil:
+			// Each copy of the body is hashed from the start.
+			reopen = func() (io.ReadCloser, error) {
+				r, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				hb.wait()
+				hb = &hashingBody{mpuc: mpuc, r: r}
+				if err := hb.reset(); err != nil {
+					r.Close()
+					return nil, err
+				}
+				return hashingReadCloser{hb, r}, nil
+			}
+		case seekable:
+			body = seekableHashingBody{hb, seeker, start}
+		}
+		result, err := mpuc.uploadObjectPart(ctx, req, body, reopen, contentLength)
+		hb.wait()
+		if err != nil {
+			return nil, err
+		}
+		result.ComputedHashes = hb.hasher.Sums()
+		result.ComputedDigests = hb.hasher.Digests()
+		mismatchErr = checkSums(req.PartNumber, result.ComputedHashes, result.Hashes)
+		if mismatchErr == nil {
+			return result, nil
+		}
+	}
+	return nil, mismatchErr
+}
+
+// newHasher returns a Hasher for the algorithms selected by
+// WithHashAlgorithms.
+func (mpuc *MultipartClient) newHasher() (*gcshash.Hasher, error) {
+	if mpuc.hashRegistry == nil {
+		return gcshash.NewHasher(), nil
+	}
+	return mpuc.hashRegistry.NewHasher(mpuc.hashNames...)
+}
+
+// unmarshalHasher restores a Hasher serialized with MarshalBinary, whose
+// algorithms must be those of WithHashAlgorithms.
+func (mpuc *MultipartClient) unmarshalHasher(data []byte) (*gcshash.Hasher, error) {
+	if mpuc.hashRegistry == nil {
+		return gcshash.UnmarshalHasher(data)
+	}
+	return mpuc.hashRegistry.UnmarshalHasher(data)
+}
+
+// checkSuppliedHashes hashes the seekable body of req from start and compares
+// the result with the checksums supplied in the request.
+func (mpuc *MultipartClient) checkSuppliedHashes(req *UploadObjectPartRequest, seeker io.Seeker, start int64) error {
+	if !req.Hashes.HasCRC32C && req.Hashes.MD5 == nil {
+		return nil
+	}
+	if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	computed, err := mpuc.hashBody(req.Body)
+	if err != nil {
+		return fmt.Errorf("failed to hash part %d: %w", req.PartNumber, err)
+	}
+	if checkSums(req.PartNumber, computed, req.Hashes) != nil {
+		return &InconsistentHashesError{PartNumber: req.PartNumber, Supplied: req.Hashes, Computed: computed}
+	}
+	return nil
+}
+
+// precomputeHashes returns the checksums of the body of req, read from start
+// if it is seekable, and otherwise from a copy returned by GetBody, to be sent
+// with the part.
+func (mpuc *MultipartClient) precomputeHashes(req *UploadObjectPartRequest, seeker io.Seeker, start int64, seekable bool) (gcshash.Sums, error) {
+	var body io.Reader = req.Body
+	if seekable {
+		if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+			return gcshash.Sums{}, err
+		}
+	} else {
+		r, err := req.GetBody()
+		if err != nil {
+			return gcshash.Sums{}, err
+		}
+		defer r.Close()
+		body = r
+	}
+	sums, err := mpuc.hashBody(body)
+	if err != nil {
+		return gcshash.Sums{}, fmt.Errorf("failed to hash part %d: %w", req.PartNumber, err)
+	}
+	return sums, nil
+}
+
+// hashBody returns the checksums of r, read to its end.
+func (mpuc *MultipartClient) hashBody(r io.Reader) (gcshash.Sums, error) {
+	hasher, err := mpuc.newHasher()
+	if err != nil {
+		return gcshash.Sums{}, err
+	}
+	hashWriter, waitHashed := mpuc.hashWriter(hasher)
+	_, err = io.Copy(hashWriter, r)
+	waitHashed()
+	if err != nil {
+		return gcshash.Sums{}, err
+	}
+	return hasher.Sums(), nil
+}
+
+// hashingBody hashes a part body with a new Hasher as the HTTP client reads
+// it.
+type hashingBody struct {
+	mpuc   *MultipartClient
+	r      io.Reader
+	hasher *gcshash.Hasher
+	w      io.Writer
+	// wait must be called before reading the sums of hasher.
+	wait func()
+}
+
+func (b *hashingBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if n > 0 {
+		if _, werr := b.w.Write(p[:n]); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// reset starts the hash over with a new Hasher.
+func (b *hashingBody) reset() error {
+	if b.wait != nil {
+		b.wait()
+	}
+	hasher, err := b.mpuc.newHasher()
+	if err != nil {
+		return err
+	}
+	b.hasher = hasher
+	b.w, b.wait = b.mpuc.hashWriter(hasher)
+	return nil
+}
+
+// hashingReadCloser is a hashingBody of a copy of a part body returned by
+// GetBody, which the HTTP client closes.
+type hashingReadCloser struct {
+	*hashingBody
+	io.Closer
+}
+
+// seekableHashingBody is a hashingBody of a seekable part body starting at
+// start. Seeking it back to start, to send it again after a redirect or on
+// retry, starts the hash over.
+type seekableHashingBody struct {
+	*hashingBody
+	seeker io.Seeker
+	start  int64
+}
+
+func (b seekableHashingBody) Seek(offset int64, whence int) (int64, error) {
+	switch {
+	case offset == 0 && whence == io.SeekCurrent:
+		return b.seeker.Seek(0, io.SeekCurrent)
+	case offset == b.start && whence == io.SeekStart:
+		if err := b.reset(); err != nil {
+			return 0, err
+		}
+		return b.seeker.Seek(b.start, io.SeekStart)
+	}
+	return 0, errors.New("seekableHashingBody: seek other than to the start")
+}
@@ -0,0 +1,490 @@
+package multipartclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multiparttest"
+)
+
+// seekableBody is a request body that can be rewound.
+type seekableBody struct {
+	*strings.Reader
+	closed bool
+}
+
+func (b *seekableBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestUploadObjectPartVerifyChecksums(t *testing.T) {
+	const contents = "part contents"
+	good := gcshash.NewHasher()
+	good.Write([]byte(contents))
+	corrupt := gcshash.NewHasher()
+	corrupt.Write([]byte("part c0ntents"))
+
+	tests := []struct {
+		name string
+		// serverSums are the checksums reported for each upload attempt.
+		serverSums   []gcshash.Sums
+		maxAttempts  int
+		wantAttempts int
+		wantMismatch bool
+	}{
+		{
+			name:         "Match on first attempt",
+			serverSums:   []gcshash.Sums{good.Sums()},
+			wantAttempts: 1,
+		},
+		{
+			name:         "Mismatch then match",
+			serverSums:   []gcshash.Sums{corrupt.Sums(), good.Sums()},
+			wantAttempts: 2,
+		},
+		{
+			name:         "Mismatch on every attempt",
+			serverSums:   []gcshash.Sums{corrupt.Sums(), corrupt.Sums()},
+			maxAttempts:  2,
+			wantAttempts: 2,
+			wantMismatch: true,
+		},
+		{
+			name:         "Server reports no checksums",
+			serverSums:   []gcshash.Sums{{}},
+			wantAttempts: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotBodies []string
+			trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+				b, err := io.ReadAll(req.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				gotBodies = append(gotBodies, string(b))
+				if req.ContentLength != int64(len(contents)) {
+					t.Errorf("got Content-Length %d, want %d", req.ContentLength, len(contents))
+				}
+
+				resp := &http.Response{StatusCode: http.StatusOK, Status: "OK", Header: http.Header{}, Body: http.NoBody}
+				tc.serverSums[len(gotBodies)-1].SetHeader(resp.Header)
+				return resp, nil
+			})
+
+			body := &seekableBody{Reader: strings.NewReader(contents)}
+			mpuc := New(&http.Client{Transport: trans})
+			_, err := mpuc.UploadObjectPart(context.Background(), &UploadObjectPartRequest{
+				Bucket:              "bucket1",
+				Key:                 "object.txt",
+				PartNumber:          1,
+				UploadID:            "my-upload-id",
+				Body:                body,
+				VerifyChecksums:     true,
+				MaxChecksumAttempts: tc.maxAttempts,
+			})
+
+			var mismatch *ChecksumMismatchError
+			if got := errors.As(err, &mismatch); got != tc.wantMismatch {
+				t.Fatalf("UploadObjectPart() = %v, want mismatch: %v", err, tc.wantMismatch)
+			}
+			if !tc.wantMismatch && err != nil {
+				t.Fatal(err)
+			}
+			wantBodies := make([]string, tc.wantAttempts)
+			for i := range wantBodies {
+				wantBodies[i] = contents
+			}
+			if diff := cmp.Diff(wantBodies, gotBodies); diff != "" {
+				t.Errorf("unexpected diff for uploaded bodies: (-want, +got):\n%s", diff)
+			}
+			if !body.closed {
+				t.Error("request body was not closed")
+			}
+		})
+	}
+}
+
+func TestUploadObjectPartVerifyChecksumsGetBody(t *testing.T) {
+	const contents = "part contents"
+	good := gcshash.NewHasher()
+	good.Write([]byte(contents))
+	serverSums := []gcshash.Sums{{CRC32C: 1, HasCRC32C: true}, good.Sums()}
+
+	var gotBodies []string
+	trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Body.Close()
+		gotBodies = append(gotBodies, string(b))
+		resp := &http.Response{StatusCode: http.StatusOK, Status: "OK", Header: http.Header{}, Body: http.NoBody}
+		serverSums[len(gotBodies)-1].SetHeader(resp.Header)
+		return resp, nil
+	})
+
+	var copies []*seekableBody
+	body := &seekableBody{Reader: strings.NewReader(contents)}
+	mpuc := New(&http.Client{Transport: trans})
+	result, err := mpuc.UploadObjectPart(context.Background(), &UploadObjectPartRequest{
+		Bucket:     "bucket1",
+		Key:        "object.txt",
+		PartNumber: 1,
+		UploadID:   "my-upload-id",
+		// Body is read once, and copies are sent after the mismatch.
+		Body: io.MultiReader(body),
+		GetBody: func() (io.ReadCloser, error) {
+			c := &seekableBody{Reader: strings.NewReader(contents)}
+			copies = append(copies, c)
+			return c, nil
+		},
+		VerifyChecksums: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{contents, contents}, gotBodies); diff != "" {
+		t.Errorf("unexpected diff for uploaded bodies: (-want, +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(good.Sums(), result.ComputedHashes); diff != "" {
+		t.Errorf("unexpected diff for computed hashes: (-want, +got):\n%s", diff)
+	}
+	if len(copies) != 1 || !copies[0].closed {
+		t.Errorf("got %d copies of the body, want 1 that is closed", len(copies))
+	}
+}
+
+func TestUploadObjectPartVerifyChecksumsStreamingBody(t *testing.T) {
+	const contents = "part contents"
+	want := gcshash.NewHasher()
+	want.Write([]byte(contents))
+
+	tests := []struct {
+		name         string
+		serverSums   gcshash.Sums
+		wantMismatch bool
+	}{
+		{
+			name:       "Match",
+			serverSums: want.Sums(),
+		},
+		{
+			name:         "Mismatch is not retried",
+			serverSums:   gcshash.Sums{CRC32C: 1, HasCRC32C: true},
+			wantMismatch: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			attempts := 0
+			trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+				attempts++
+				if _, err := io.Copy(io.Discard, req.Body); err != nil {
+					t.Fatal(err)
+				}
+				resp := &http.Response{StatusCode: http.StatusOK, Status: "OK", Header: http.Header{}, Body: http.NoBody}
+				tc.serverSums.SetHeader(resp.Header)
+				return resp, nil
+			})
+
+			mpuc := New(&http.Client{Transport: trans})
+			result, err := mpuc.UploadObjectPart(context.Background(), &UploadObjectPartRequest{
+				Bucket:          "bucket1",
+				Key:             "object.txt",
+				PartNumber:      1,
+				UploadID:        "my-upload-id",
+				Body:            toBody(contents),
+				VerifyChecksums: true,
+			})
+
+			if attempts != 1 {
+				t.Errorf("got %d upload attempts, want 1", attempts)
+			}
+			var mismatch *ChecksumMismatchError
+			if got := errors.As(err, &mismatch); got != tc.wantMismatch {
+				t.Fatalf("UploadObjectPart() = %v, want mismatch: %v", err, tc.wantMismatch)
+			}
+			if tc.wantMismatch {
+				return
+			}
+			if diff := cmp.Diff(want.Sums(), result.ComputedHashes); diff != "" {
+				t.Errorf("unexpected diff for computed hashes: (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUploadObjectPartHashAlgorithms(t *testing.T) {
+	const contents = "part contents"
+	registry := gcshash.NewRegistry()
+	if err := registry.Register(gcshash.Algorithm{Name: "sha256", New: sha256.New}); err != nil {
+		t.Fatal(err)
+	}
+	registry.Disable(gcshash.MD5Name)
+
+	trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+		if _, err := io.Copy(io.Discard, req.Body); err != nil {
+			t.Fatal(err)
+		}
+		// The MD5 is wrong, but it is not computed locally so it is not compared.
+		resp := &http.Response{StatusCode: http.StatusOK, Status: "OK", Header: http.Header{}, Body: http.NoBody}
+		gcshash.Sums{CRC32C: gcshash.CRC32C([]byte(contents)), HasCRC32C: true, MD5: md5Of("other")}.SetHeader(resp.Header)
+		return resp, nil
+	})
+
+	mpuc := New(&http.Client{Transport: trans}, WithHashAlgorithms(registry))
+	result, err := mpuc.UploadObjectPart(context.Background(), &UploadObjectPartRequest{
+		Bucket:          "bucket1",
+		Key:             "object.txt",
+		PartNumber:      1,
+		UploadID:        "my-upload-id",
+		Body:            toBody(contents),
+		VerifyChecksums: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantSHA := sha256.Sum256([]byte(contents))
+	wantDigests := map[string][]byte{
+		gcshash.CRC32CName: binary.BigEndian.AppendUint32(nil, gcshash.CRC32C([]byte(contents))),
+		"sha256":           wantSHA[:],
+	}
+	if diff := cmp.Diff(wantDigests, result.ComputedDigests); diff != "" {
+		t.Errorf("unexpected diff for computed digests: (-want, +got):\n%s", diff)
+	}
+	if result.ComputedHashes.MD5 != nil {
+		t.Error("MD5 computed while disabled")
+	}
+}
+
+func TestUploadObjectPartSuppliedHashes(t *testing.T) {
+	const contents = "part contents"
+	correct := gcshash.Sums{CRC32C: gcshash.CRC32C([]byte(contents)), HasCRC32C: true, MD5: md5Of(contents)}
+
+	tests := []struct {
+		name             string
+		supplied         gcshash.Sums
+		wantInconsistent bool
+		wantHashHeader   []string
+	}{
+		{
+			name:     "Consistent hashes are sent",
+			supplied: correct,
+			wantHashHeader: []string{
+				"crc32c=" + gcshash.EncodeCRC32C(correct.CRC32C),
+				"md5=" + gcshash.EncodeMD5(correct.MD5),
+			},
+		},
+		{
+			name:             "Inconsistent MD5 is rejected",
+			supplied:         gcshash.Sums{CRC32C: correct.CRC32C, HasCRC32C: true, MD5: md5Of("other")},
+			wantInconsistent: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotHashHeader []string
+			requests := 0
+			trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+				requests++
+				gotHashHeader = req.Header.Values("x-goog-hash")
+				if _, err := io.Copy(io.Discard, req.Body); err != nil {
+					t.Fatal(err)
+				}
+				return &http.Response{StatusCode: http.StatusOK, Status: "OK", Body: http.NoBody}, nil
+			})
+
+			mpuc := New(&http.Client{Transport: trans})
+			_, err := mpuc.UploadObjectPart(context.Background(), &UploadObjectPartRequest{
+				Bucket:          "bucket1",
+				Key:             "object.txt",
+				PartNumber:      1,
+				UploadID:        "my-upload-id",
+				Body:            &seekableBody{Reader: strings.NewReader(contents)},
+				Hashes:          tc.supplied,
+				VerifyChecksums: true,
+			})
+
+			var inconsistent *InconsistentHashesError
+			if got := errors.As(err, &inconsistent); got != tc.wantInconsistent {
+				t.Fatalf("UploadObjectPart() = %v, want inconsistent: %v", err, tc.wantInconsistent)
+			}
+			if tc.wantInconsistent {
+				if requests != 0 {
+					t.Errorf("got %d requests, want none", requests)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.wantHashHeader, gotHashHeader); diff != "" {
+				t.Errorf("unexpected diff for x-goog-hash: (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUploadObjectPartHashingDisabled(t *testing.T) {
+	const contents = "part contents"
+	var gotPayloadHash string
+	trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+		gotPayloadHash = req.Header.Get("x-goog-content-sha256")
+		if _, err := io.Copy(io.Discard, req.Body); err != nil {
+			t.Fatal(err)
+		}
+		// A mismatch would be detected if the part were hashed.
+		resp := &http.Response{StatusCode: http.StatusOK, Status: "OK", Header: http.Header{"Etag": []string{"etag-1"}}, Body: http.NoBody}
+		gcshash.Sums{CRC32C: 1, HasCRC32C: true}.SetHeader(resp.Header)
+		return resp, nil
+	})
+
+	mpuc := New(&http.Client{Transport: trans}, WithHashingDisabled(), WithContentSHA256(ContentSHA256Auto))
+	result, err := mpuc.UploadObjectPart(context.Background(), &UploadObjectPartRequest{
+		Bucket:          "bucket1",
+		Key:             "object.txt",
+		PartNumber:      1,
+		UploadID:        "my-upload-id",
+		Body:            &seekableBody{Reader: strings.NewReader(contents)},
+		VerifyChecksums: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotPayloadHash != UnsignedPayload {
+		t.Errorf("got x-goog-content-sha256 %q, want %q", gotPayloadHash, UnsignedPayload)
+	}
+
+	want := PartRecord{PartNumber: 1, ETag: "etag-1", HashingDisabled: true}
+	if diff := cmp.Diff(want, PartRecordFromResult(1, result)); diff != "" {
+		t.Errorf("unexpected diff for part record: (-want, +got):\n%s", diff)
+	}
+}
+
+func TestWithPartChecksums(t *testing.T) {
+	const contents = "part contents"
+	good := gcshash.NewHasher()
+	good.Write([]byte(contents))
+	tests := []struct {
+		name        string
+		body        func() io.Reader
+		getBody     func() (io.ReadCloser, error)
+		hashes      gcshash.Sums
+		wantHeaders bool
+	}{
+		{
+			name:        "Seekable",
+			body:        func() io.Reader { return NewSectionBody(strings.NewReader(contents), 0, int64(len(contents))) },
+			wantHeaders: true,
+		},
+		{
+			name:        "GetBody",
+			body:        func() io.Reader { return io.MultiReader(strings.NewReader(contents)) },
+			getBody:     func() (io.ReadCloser, error) { return toBody(contents), nil },
+			wantHeaders: true,
+		},
+		{
+			name:        "Supplied hashes",
+			body:        func() io.Reader { return &seekableBody{Reader: strings.NewReader(contents)} },
+			hashes:      good.Sums(),
+			wantHeaders: true,
+		},
+		{
+			name: "Streaming",
+			body: func() io.Reader { return toBody(contents) },
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := multiparttest.NewServer(t)
+			trans := srv.Transport()
+			var header http.Header
+			hc := &http.Client{Transport: funcTransport(func(req *http.Request) (*http.Response, error) {
+				if req.Method == http.MethodPut {
+					header = req.Header.Clone()
+				}
+				return trans.RoundTrip(req)
+			})}
+			mpuc := New(hc, WithPartChecksums())
+			init, err := mpuc.InitiateMultipartUpload(context.Background(), &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := mpuc.UploadObjectPart(context.Background(), &UploadObjectPartRequest{
+				Bucket:     "bucket1",
+				Key:        "object.txt",
+				PartNumber: 1,
+				UploadID:   init.UploadID,
+				Body:       tc.body(),
+				GetBody:    tc.getBody,
+				Hashes:     tc.hashes,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(good.Sums(), result.ComputedHashes); diff != "" {
+				t.Errorf("unexpected diff for computed hashes: (-want, +got):\n%s", diff)
+			}
+			sent, err := gcshash.ParseHeader(header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want gcshash.Sums
+			wantMD5 := ""
+			if tc.wantHeaders {
+				want, wantMD5 = good.Sums(), gcshash.EncodeMD5(good.Sums().MD5)
+			}
+			if diff := cmp.Diff(want, sent); diff != "" {
+				t.Errorf("unexpected diff for sent x-goog-hash: (-want, +got):\n%s", diff)
+			}
+			if got := header.Get("Content-MD5"); got != wantMD5 {
+				t.Errorf("got Content-MD5 %q, want %q", got, wantMD5)
+			}
+		})
+	}
+}
+
+// TestWithPartChecksumsCorrupted checks that the server rejects a part
+// corrupted on the way, since its checksums are sent.
+func TestWithPartChecksumsCorrupted(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	trans := srv.Transport()
+	hc := &http.Client{Transport: funcTransport(func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodPut {
+			req = req.Clone(req.Context())
+			req.Body = toBody("part c0ntents")
+		}
+		return trans.RoundTrip(req)
+	})}
+	mpuc := New(hc, WithPartChecksums())
+	init, err := mpuc.InitiateMultipartUpload(context.Background(), &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = mpuc.UploadObjectPart(context.Background(), &UploadObjectPartRequest{
+		Bucket:     "bucket1",
+		Key:        "object.txt",
+		PartNumber: 1,
+		UploadID:   init.UploadID,
+		Body:       NewSectionBody(strings.NewReader("part contents"), 0, 13),
+	})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Code != "BadDigest" {
+		t.Errorf("got error %v, want BadDigest", err)
+	}
+}
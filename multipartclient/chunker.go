@@ -0,0 +1,196 @@
+package multipartclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// Chunk is a contiguous piece of an input stream produced by a Chunker.
+type Chunk struct {
+	// Offset is the position of the first byte of Data within the input stream.
+	Offset int64
+	Data   []byte
+}
+
+// Chunker splits a stream into the parts of a multipart upload. Next returns
+// io.EOF once the input is exhausted.
+type Chunker interface {
+	Next() (*Chunk, error)
+}
+
+type fixedSizeChunker struct {
+	r      io.Reader
+	size   int
+	offset int64
+	eof    bool
+}
+
+// NewFixedSizeChunker returns a Chunker that splits r into chunks of exactly
+// size bytes, except for the final chunk which may be shorter.
+func NewFixedSizeChunker(r io.Reader, size int) (Chunker, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("chunk size must be positive, got %d", size)
+	}
+	return &fixedSizeChunker{r: r, size: size}, nil
+}
+
+func (c *fixedSizeChunker) Next() (*Chunk, error) {
+	if c.eof {
+		return nil, io.EOF
+	}
+	data := make([]byte, c.size)
+	n, err := io.ReadFull(c.r, data)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		c.eof = true
+	} else if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, io.EOF
+	}
+
+	chunk := &Chunk{Offset: c.offset, Data: data[:n]}
+	c.offset += int64(n)
+	return chunk, nil
+}
+
+// CDCOptions configures the content-defined chunker. Zero values are replaced
+// by the defaults below.
+type CDCOptions struct {
+	// MinSize is the smallest chunk emitted, other than the final chunk.
+	// Defaults to 5 MiB, the smallest part GCS accepts.
+	MinSize int
+	// AvgSize is the target chunk size. Defaults to 8 MiB.
+	AvgSize int
+	// MaxSize is the largest chunk emitted. Defaults to 16 MiB.
+	MaxSize int
+}
+
+func (o CDCOptions) withDefaults() CDCOptions {
+	if o.MinSize == 0 {
+		o.MinSize = MinPartSize
+	}
+	if o.AvgSize == 0 {
+		o.AvgSize = 8 << 20
+	}
+	if o.MaxSize == 0 {
+		o.MaxSize = 16 << 20
+	}
+	return o
+}
+
+// gearTable holds the per-byte values mixed into the rolling hash. It is
+// derived from a fixed seed so chunk boundaries are stable across processes
+// and releases; changing it would defeat deduplication against earlier runs.
+var gearTable = func() [256]uint64 {
+	var table [256]uint64
+	// splitmix64
+	state := uint64(0x6763732d6d707563)
+	for i := range table {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+type cdcChunker struct {
+	r      io.Reader
+	opts   CDCOptions
+	maskS  uint64
+	maskL  uint64
+	buf    []byte
+	offset int64
+	eof    bool
+}
+
+// NewContentDefinedChunker returns a Chunker that places chunk boundaries
+// based on the content of r using the FastCDC algorithm. Because boundaries
+// depend only on nearby bytes, an insertion or deletion in the input only
+// changes the chunks around the edit; the remaining chunks are byte-for-byte
+// identical to those of the original input and can be skipped when
+// re-uploading.
+func NewContentDefinedChunker(r io.Reader, opts CDCOptions) (Chunker, error) {
+	opts = opts.withDefaults()
+	if opts.MinSize <= 0 || opts.MinSize > opts.AvgSize || opts.AvgSize > opts.MaxSize {
+		return nil, fmt.Errorf("invalid chunk sizes: require 0 < MinSize <= AvgSize <= MaxSize, got %d, %d, %d", opts.MinSize, opts.AvgSize, opts.MaxSize)
+	}
+
+	// Normalized chunking: below the average size a harder mask (more bits)
+	// makes cuts less likely, above it an easier mask makes them more likely,
+	// which narrows the chunk size distribution around AvgSize.
+	avgBits := bits.Len(uint(opts.AvgSize)) - 1
+	return &cdcChunker{
+		r:     r,
+		opts:  opts,
+		maskS: topBitsMask(avgBits + 1),
+		maskL: topBitsMask(avgBits - 1),
+		buf:   make([]byte, 0, opts.MaxSize),
+	}, nil
+}
+
+func topBitsMask(n int) uint64 {
+	if n <= 0 {
+		return 0
+	}
+	if n >= 64 {
+		return ^uint64(0)
+	}
+	return ^uint64(0) << (64 - n)
+}
+
+func (c *cdcChunker) Next() (*Chunk, error) {
+	if !c.eof && len(c.buf) < c.opts.MaxSize {
+		n, err := io.ReadFull(c.r, c.buf[len(c.buf):c.opts.MaxSize])
+		c.buf = c.buf[:len(c.buf)+n]
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			c.eof = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if len(c.buf) == 0 {
+		return nil, io.EOF
+	}
+
+	n := c.cutPoint(c.buf)
+	data := make([]byte, n)
+	copy(data, c.buf[:n])
+	c.buf = c.buf[:copy(c.buf, c.buf[n:])]
+
+	chunk := &Chunk{Offset: c.offset, Data: data}
+	c.offset += int64(n)
+	return chunk, nil
+}
+
+// cutPoint returns the length of the next chunk at the start of data.
+func (c *cdcChunker) cutPoint(data []byte) int {
+	n := len(data)
+	if n <= c.opts.MinSize {
+		return n
+	}
+	normal := c.opts.AvgSize
+	if n < normal {
+		normal = n
+	}
+
+	var fp uint64
+	i := c.opts.MinSize
+	for ; i < normal; i++ {
+		fp = (fp << 1) + gearTable[data[i]]
+		if fp&c.maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		fp = (fp << 1) + gearTable[data[i]]
+		if fp&c.maskL == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
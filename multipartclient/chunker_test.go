@@ -0,0 +1,151 @@
+package multipartclient
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func randomBytes(t *testing.T, seed int64, n int) []byte {
+	t.Helper()
+
+	data := make([]byte, n)
+	if _, err := rand.New(rand.NewSource(seed)).Read(data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func readAllChunks(t *testing.T, c Chunker) []*Chunk {
+	t.Helper()
+
+	var chunks []*Chunk
+	for {
+		chunk, err := c.Next()
+		if errors.Is(err, io.EOF) {
+			return chunks
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks = append(chunks, chunk)
+	}
+}
+
+func TestFixedSizeChunker(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		size       int
+		wantChunks []*Chunk
+	}{
+		{
+			name:  "Input not a multiple of size",
+			input: "abcdefgh",
+			size:  3,
+			wantChunks: []*Chunk{
+				{Offset: 0, Data: []byte("abc")},
+				{Offset: 3, Data: []byte("def")},
+				{Offset: 6, Data: []byte("gh")},
+			},
+		},
+		{
+			name:  "Input a multiple of size",
+			input: "abcdef",
+			size:  3,
+			wantChunks: []*Chunk{
+				{Offset: 0, Data: []byte("abc")},
+				{Offset: 3, Data: []byte("def")},
+			},
+		},
+		{
+			name:       "Empty input",
+			input:      "",
+			size:       3,
+			wantChunks: nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewFixedSizeChunker(bytes.NewReader([]byte(tc.input)), tc.size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.wantChunks, readAllChunks(t, c)); diff != "" {
+				t.Errorf("unexpected diff for chunks: (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestContentDefinedChunkerBounds(t *testing.T) {
+	opts := CDCOptions{MinSize: 256, AvgSize: 1024, MaxSize: 4096}
+	input := randomBytes(t, 1, 256<<10)
+
+	c, err := NewContentDefinedChunker(bytes.NewReader(input), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := readAllChunks(t, c)
+
+	var reassembled []byte
+	for i, chunk := range chunks {
+		if chunk.Offset != int64(len(reassembled)) {
+			t.Errorf("chunk %d: got offset %d, want %d", i, chunk.Offset, len(reassembled))
+		}
+		if len(chunk.Data) > opts.MaxSize {
+			t.Errorf("chunk %d: size %d exceeds MaxSize %d", i, len(chunk.Data), opts.MaxSize)
+		}
+		if i != len(chunks)-1 && len(chunk.Data) < opts.MinSize {
+			t.Errorf("chunk %d: size %d below MinSize %d", i, len(chunk.Data), opts.MinSize)
+		}
+		reassembled = append(reassembled, chunk.Data...)
+	}
+	if !bytes.Equal(input, reassembled) {
+		t.Error("reassembled chunks do not match input")
+	}
+}
+
+func TestContentDefinedChunkerResynchronizes(t *testing.T) {
+	opts := CDCOptions{MinSize: 256, AvgSize: 1024, MaxSize: 4096}
+	original := randomBytes(t, 2, 256<<10)
+	// Insert a few bytes near the start of the input.
+	modified := append(append(append([]byte{}, original[:1000]...), "inserted"...), original[1000:]...)
+
+	chunkSet := func(data []byte) map[string]bool {
+		c, err := NewContentDefinedChunker(bytes.NewReader(data), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		set := map[string]bool{}
+		for _, chunk := range readAllChunks(t, c) {
+			set[string(chunk.Data)] = true
+		}
+		return set
+	}
+
+	before := chunkSet(original)
+	after := chunkSet(modified)
+	shared := 0
+	for data := range after {
+		if before[data] {
+			shared++
+		}
+	}
+	// Only the chunks around the edit should change.
+	if changed := len(after) - shared; changed > 3 {
+		t.Errorf("got %d changed chunks out of %d, want at most 3", changed, len(after))
+	}
+}
+
+func TestContentDefinedChunkerInvalidOptions(t *testing.T) {
+	_, err := NewContentDefinedChunker(bytes.NewReader(nil), CDCOptions{MinSize: 2048, AvgSize: 1024, MaxSize: 4096})
+	if err == nil {
+		t.Error("expected error for MinSize > AvgSize")
+	}
+}
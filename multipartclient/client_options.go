@@ -0,0 +1,104 @@
+package multipartclient
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+
+	"google.golang.org/api/option"
+	"google.golang.org/api/option/internaloption"
+	htransport "google.golang.org/api/transport/http"
+)
+
+// ScopeReadWrite is the OAuth scope NewClient requests unless given
+// option.WithScopes.
+const ScopeReadWrite = "https://www.googleapis.com/auth/devstorage.read_write"
+
+// emulatorHostEnv names the environment variable that points the client at
+// a Cloud Storage emulator, as it does cloud.google.com/go/storage.
+const emulatorHostEnv = "STORAGE_EMULATOR_HOST"
+
+// NewClient returns a client configured the way other Google API clients
+// are, with options such as option.WithCredentialsFile, option.WithEndpoint,
+// option.WithHTTPClient, option.WithQuotaProject and option.WithUserAgent.
+// Without options, requests are authorized with Application Default
+// Credentials and sent to https://storage.googleapis.com. The transport is
+// built by google.golang.org/api/transport/http, so credentials are detected
+// and refreshed as by other Google API clients, including impersonated
+// service accounts, workload identity federation and self-signed JWTs.
+//
+// A *storage.Client from cloud.google.com/go/storage doesn't expose its
+// credentials or settings, so to share them pass NewClient the options the
+// storage client was created with, except for option.WithEndpoint, which
+// there names the JSON API rather than the XML API:
+//
+//	opts := []option.ClientOption{option.WithCredentialsFile(path), option.WithQuotaProject(project)}
+//	sc, err := storage.NewClient(ctx, opts...)
+//	...
+//	mpuc, err := multipartclient.NewClient(ctx, opts...)
+//
+// Like storage.NewClient, NewClient sends unauthenticated requests to the
+// emulator at $STORAGE_EMULATOR_HOST if it is set, unless opts name another
+// endpoint.
+func NewClient(ctx context.Context, opts ...option.ClientOption) (*MultipartClient, error) {
+	return NewClientWithOptions(ctx, opts)
+}
+
+// NewClientWithOptions is like NewClient, and also applies the client
+// options opts as New does. With WithHTTPVersion, WithCopyBufferSize or
+// WithDialer, the authorized transport is built on a clone of http.DefaultTransport with those
+// settings instead of the default Google API transport.
+func NewClientWithOptions(ctx context.Context, clientOpts []option.ClientOption, opts ...Option) (*MultipartClient, error) {
+	// Transport settings in opts set up a transport on nil clients.
+	mpuc := New(nil, opts...)
+	defaults := []option.ClientOption{
+		internaloption.WithDefaultEndpoint(defaultEndpoint + "/"),
+		internaloption.WithDefaultScopes(ScopeReadWrite),
+	}
+	if mpuc.endpoint != "" {
+		// WithEndpoint overrides the emulator, and is overridden by
+		// option.WithEndpoint.
+		defaults = append(defaults, option.WithEndpoint(mpuc.endpoint+"/"))
+	} else if host := os.Getenv(emulatorHostEnv); host != "" {
+		if !strings.Contains(host, "://") {
+			host = "http://" + host
+		}
+		defaults = append(defaults,
+			option.WithEndpoint(host),
+			option.WithoutAuthentication(),
+			// Credentials in clientOpts would otherwise conflict with
+			// WithoutAuthentication.
+			internaloption.SkipDialSettingsValidation(),
+		)
+	}
+	clientOpts = append(defaults, clientOpts...)
+	hc, endpoint, err := newHTTPClient(ctx, mpuc.hc, mpuc.transport, clientOpts)
+	if err != nil {
+		return nil, err
+	}
+	mpuc.hc = hc
+	mpuc.endpoint = ""
+	if endpoint = strings.TrimSuffix(endpoint, "/"); endpoint != defaultEndpoint {
+		mpuc.endpoint = endpoint
+	}
+	return mpuc, nil
+}
+
+// newHTTPClient returns the authorized client for clientOpts and the endpoint
+// they name. With transport settings s, the client's transport is built on
+// base's, as set up by New.
+func newHTTPClient(ctx context.Context, base *http.Client, s transportSettings, clientOpts []option.ClientOption) (*http.Client, string, error) {
+	hc, endpoint, err := htransport.NewClient(ctx, clientOpts...)
+	if err != nil || s == (transportSettings{}) {
+		return hc, endpoint, err
+	}
+	trans, err := htransport.NewTransport(ctx, base.Transport, clientOpts...)
+	if err != nil {
+		// The options were accepted by NewClient, so they include
+		// option.WithHTTPClient, which NewTransport rejects. The caller's
+		// client gets the settings as it would from New.
+		return withTransportSettings(hc, s), endpoint, nil
+	}
+	return &http.Client{Transport: trans}, endpoint, nil
+}
@@ -0,0 +1,170 @@
+package multipartclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/option"
+)
+
+func TestNewClient(t *testing.T) {
+	var gotReq *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotReq = r
+		w.Write([]byte(`<ListMultipartUploadsResult></ListMultipartUploadsResult>`))
+	}))
+	t.Cleanup(srv.Close)
+
+	ctx := context.Background()
+	mpuc, err := NewClient(ctx,
+		option.WithEndpoint(srv.URL+"/storage/"),
+		option.WithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token1"})),
+		option.WithQuotaProject("project1"),
+		option.WithUserAgent("agent1"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mpuc.ListMultipartUploads(ctx, &ListMultipartUploadsRequest{Bucket: "bucket1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := gotReq.URL.RequestURI(), "/storage/bucket1/?uploads"; got != want {
+		t.Errorf("got request URI %q, want %q", got, want)
+	}
+	for header, want := range map[string]string{
+		"Authorization":       "Bearer token1",
+		"X-Goog-User-Project": "project1",
+		"User-Agent":          "agent1",
+	} {
+		if got := gotReq.Header.Get(header); got != want {
+			t.Errorf("got %s header %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestNewClientWithHTTPClient(t *testing.T) {
+	var gotURL string
+	hc := &http.Client{Transport: funcTransport(func(req *http.Request) (*http.Response, error) {
+		gotURL = req.URL.String()
+		return &http.Response{StatusCode: http.StatusOK, Body: toBody(`<ListMultipartUploadsResult></ListMultipartUploadsResult>`)}, nil
+	})}
+
+	ctx := context.Background()
+	mpuc, err := NewClientWithOptions(ctx, []option.ClientOption{option.WithHTTPClient(hc)}, WithClock(systemClock{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mpuc.ListMultipartUploads(ctx, &ListMultipartUploadsRequest{Bucket: "bucket1"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://storage.googleapis.com/bucket1/?uploads"; gotURL != want {
+		t.Errorf("got URL %q, want %q", gotURL, want)
+	}
+}
+
+func TestNewClientMissingCredentials(t *testing.T) {
+	_, err := NewClient(context.Background(), option.WithCredentialsFile(filepath.Join(t.TempDir(), "missing.json")))
+	if err == nil {
+		t.Error("got no error for a missing credentials file")
+	}
+}
+
+func TestNewClientEmulator(t *testing.T) {
+	var gotReq *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotReq = r
+		w.Write([]byte(`<ListMultipartUploadsResult></ListMultipartUploadsResult>`))
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv(emulatorHostEnv, srv.Listener.Addr().String())
+
+	ctx := context.Background()
+	// The credentials are not used with an emulator.
+	mpuc, err := NewClient(ctx, option.WithCredentialsFile(filepath.Join(t.TempDir(), "missing.json")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mpuc.ListMultipartUploads(ctx, &ListMultipartUploadsRequest{Bucket: "bucket1"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := gotReq.URL.RequestURI(), "/bucket1/?uploads"; got != want {
+		t.Errorf("got request URI %q, want %q", got, want)
+	}
+	if got := gotReq.Header.Get("Authorization"); got != "" {
+		t.Errorf("got Authorization header %q for an emulator, want none", got)
+	}
+}
+
+func TestWithEndpoint(t *testing.T) {
+	var gotReq *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotReq = r
+		w.Write([]byte(`<ListMultipartUploadsResult></ListMultipartUploadsResult>`))
+	}))
+	t.Cleanup(srv.Close)
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("got request %s for the overridden endpoint", r.URL)
+	}))
+	t.Cleanup(other.Close)
+
+	ctx := context.Background()
+	newClient := func(opts ...Option) func() (*MultipartClient, error) {
+		return func() (*MultipartClient, error) { return New(srv.Client(), opts...), nil }
+	}
+	tests := []struct {
+		name      string
+		newClient func() (*MultipartClient, error)
+		emulator  string
+		wantURI   string
+	}{
+		{
+			name:      "New",
+			newClient: newClient(WithEndpoint(srv.URL)),
+			wantURI:   "/bucket1/?uploads",
+		},
+		{
+			name:      "Path",
+			newClient: newClient(WithEndpoint(srv.URL + "/storage/")),
+			wantURI:   "/storage/bucket1/?uploads",
+		},
+		{
+			name: "NewClientWithOptions",
+			newClient: func() (*MultipartClient, error) {
+				return NewClientWithOptions(ctx, []option.ClientOption{option.WithoutAuthentication()}, WithEndpoint(srv.URL+"/storage"))
+			},
+			emulator: other.Listener.Addr().String(),
+			wantURI:  "/storage/bucket1/?uploads",
+		},
+		{
+			name: "Overridden by option.WithEndpoint",
+			newClient: func() (*MultipartClient, error) {
+				return NewClientWithOptions(ctx, []option.ClientOption{option.WithoutAuthentication(), option.WithEndpoint(srv.URL + "/")}, WithEndpoint(other.URL))
+			},
+			wantURI: "/bucket1/?uploads",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(emulatorHostEnv, tc.emulator)
+			gotReq = nil
+			mpuc, err := tc.newClient()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := mpuc.ListMultipartUploads(ctx, &ListMultipartUploadsRequest{Bucket: "bucket1"}); err != nil {
+				t.Fatal(err)
+			}
+			if gotReq == nil {
+				t.Fatal("got no request")
+			}
+			if got := gotReq.URL.RequestURI(); got != tc.wantURI {
+				t.Errorf("got request URI %q, want %q", got, tc.wantURI)
+			}
+		})
+	}
+}
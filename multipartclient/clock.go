@@ -0,0 +1,29 @@
+package multipartclient
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Clock tells the client the time. Set one with WithClock to make timings,
+// timestamps and waits deterministic in tests. A Clock must be safe for
+// concurrent use.
+type Clock interface {
+	Now() time.Time
+	// After waits for d to elapse and then sends the current time, like
+	// time.After.
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// since returns the time elapsed since t according to the client's clock.
+func (mpuc *MultipartClient) since(t time.Time) time.Duration {
+	return mpuc.clock.Now().Sub(t)
+}
+
+// defaultRandom is the source of random numbers without WithRandom.
+var defaultRandom = rand.Float64
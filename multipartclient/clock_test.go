@@ -0,0 +1,38 @@
+package multipartclient
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multipartclienttest"
+)
+
+func TestWithClock(t *testing.T) {
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	clock := multipartclienttest.NewFakeClock(start)
+	trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+		// The request takes exactly 3 seconds.
+		clock.Advance(3 * time.Second)
+		return &http.Response{StatusCode: http.StatusNoContent, Status: "No Content", Body: http.NoBody}, nil
+	})
+
+	var records []AuditRecord
+	mpuc := New(&http.Client{Transport: trans}, WithClock(clock), WithAuditHook(func(rec AuditRecord) {
+		records = append(records, rec)
+	}))
+	if err := mpuc.AbortMultipartUpload(context.Background(), &AbortMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt", UploadID: "my-upload-id"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 1 {
+		t.Fatalf("got %d audit records, want 1", len(records))
+	}
+	if !records[0].Time.Equal(start) {
+		t.Errorf("got Time %v, want %v", records[0].Time, start)
+	}
+	if want := 3 * time.Second; records[0].Duration != want {
+		t.Errorf("got Duration %v, want %v", records[0].Duration, want)
+	}
+}
@@ -0,0 +1,176 @@
+package multipartclient
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestS3CompatibilityRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		compat *S3Compatibility
+		run    func(ctx context.Context, mpuc *MultipartClient) error
+		// want are the method, URL, copy source header and body of the request.
+		want string
+	}{
+		{
+			name: "Cloud Storage",
+			run: func(ctx context.Context, mpuc *MultipartClient) error {
+				_, err := mpuc.InitiateMultipartUpload(ctx, &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "file1.txt"})
+				return err
+			},
+			want: "POST https://storage.googleapis.com/bucket1/file1.txt?uploads",
+		},
+		{
+			name:   "Path style",
+			compat: &S3Compatibility{Endpoint: "http://localhost:9000/"},
+			run: func(ctx context.Context, mpuc *MultipartClient) error {
+				_, err := mpuc.InitiateMultipartUpload(ctx, &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "file1.txt"})
+				return err
+			},
+			want: "POST http://localhost:9000/bucket1/file1.txt?uploads",
+		},
+		{
+			name:   "Virtual-hosted style",
+			compat: &S3Compatibility{Endpoint: "https://s3.example.com", URLStyle: VirtualHostedStyle},
+			run: func(ctx context.Context, mpuc *MultipartClient) error {
+				_, err := mpuc.ListMultipartUploads(ctx, &ListMultipartUploadsRequest{Bucket: "bucket1"})
+				return err
+			},
+			want: "GET https://bucket1.s3.example.com/?uploads",
+		},
+		{
+			name:   "Copy source header",
+			compat: &S3Compatibility{Endpoint: "http://localhost:9000"},
+			run: func(ctx context.Context, mpuc *MultipartClient) error {
+				_, err := mpuc.UploadPartCopy(ctx, &UploadPartCopyRequest{
+					Bucket:       "bucket1",
+					Key:          "file1.txt",
+					PartNumber:   1,
+					UploadID:     "my-upload-id",
+					SourceBucket: "bucket2",
+					SourceKey:    "file2.txt",
+				})
+				return err
+			},
+			want: "PUT http://localhost:9000/bucket1/file1.txt?partNumber=1&uploadId=my-upload-id\n" +
+				"x-amz-copy-source: /bucket2/file2.txt",
+		},
+		{
+			name:   "Complete body namespace",
+			compat: &S3Compatibility{Endpoint: "http://localhost:9000"},
+			run: func(ctx context.Context, mpuc *MultipartClient) error {
+				_, err := mpuc.CompleteMultipartUpload(ctx, &CompleteMultipartUploadRequest{
+					Bucket:   "bucket1",
+					Key:      "file1.txt",
+					UploadID: "my-upload-id",
+					Body:     CompleteMultipartUploadBody{Parts: []CompletePart{{PartNumber: 1, ETag: "etag1"}}},
+				})
+				return err
+			},
+			want: "POST http://localhost:9000/bucket1/file1.txt?uploadId=my-upload-id\n" +
+				"<CompleteMultipartUpload xmlns=\"http://s3.amazonaws.com/doc/2006-03-01/\">\n" +
+				"  <Part>\n" +
+				"    <PartNumber>1</PartNumber>\n" +
+				"    <ETag>etag1</ETag>\n" +
+				"  </Part>\n" +
+				"</CompleteMultipartUpload>",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got string
+			trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+				got = req.Method + " " + req.URL.String()
+				if src := req.Header.Get("x-amz-copy-source"); src != "" {
+					got += "\nx-amz-copy-source: " + src
+				}
+				if req.Body != nil {
+					body, _ := io.ReadAll(req.Body)
+					if len(body) > 0 {
+						got += "\n" + string(body)
+					}
+				}
+				return &http.Response{StatusCode: http.StatusOK, Body: toBody("")}, nil
+			})
+			var opts []Option
+			if tc.compat != nil {
+				opts = append(opts, WithS3Compatibility(*tc.compat))
+			}
+			mpuc := New(&http.Client{Transport: trans}, opts...)
+			// Decoding the empty responses may fail; only the request matters.
+			_ = tc.run(context.Background(), mpuc)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected diff for request: (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestS3CompatibilityVerifyMultipartETag(t *testing.T) {
+	parts := []CompletePart{
+		{PartNumber: 1, ETag: `"` + hex.EncodeToString(md5Of("part one")) + `"`},
+		{PartNumber: 2, ETag: `"` + hex.EncodeToString(md5Of("part two")) + `"`},
+	}
+	etag := MultipartETag([][]byte{md5Of("part one"), md5Of("part two")})
+
+	tests := []struct {
+		name         string
+		verify       bool
+		resultETag   string
+		wantMismatch bool
+	}{
+		{
+			name:       "Match",
+			verify:     true,
+			resultETag: etag,
+		},
+		{
+			name:         "Mismatch",
+			verify:       true,
+			resultETag:   "0123-2",
+			wantMismatch: true,
+		},
+		{
+			name:       "Mismatch not verified",
+			resultETag: "0123-2",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+				// S3-compatible servers send the namespace on responses.
+				body := fmt.Sprintf(`<CompleteMultipartUploadResult xmlns="%s"><ETag>"%s"</ETag></CompleteMultipartUploadResult>`, S3Namespace, tc.resultETag)
+				return &http.Response{StatusCode: http.StatusOK, Body: toBody(body)}, nil
+			})
+			mpuc := New(&http.Client{Transport: trans}, WithS3Compatibility(S3Compatibility{
+				Endpoint:            "http://localhost:9000",
+				VerifyMultipartETag: tc.verify,
+			}))
+			result, err := mpuc.CompleteMultipartUpload(context.Background(), &CompleteMultipartUploadRequest{
+				Bucket:   "bucket1",
+				Key:      "file1.txt",
+				UploadID: "my-upload-id",
+				Body:     CompleteMultipartUploadBody{Parts: parts},
+			})
+
+			var mismatch *ETagMismatchError
+			if gotMismatch := errors.As(err, &mismatch); gotMismatch != tc.wantMismatch {
+				t.Fatalf("got error %v, want mismatch %v", err, tc.wantMismatch)
+			}
+			if !tc.wantMismatch && strings.Trim(result.ETag, `"`) != tc.resultETag {
+				t.Errorf("got ETag %s, want %s", result.ETag, tc.resultETag)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package multipartclient
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// completeChunkBytes is the size of the chunks a CompleteMultipartUploadBody is
+// written to its destination in.
+const completeChunkBytes = 32 << 10
+
+var completeChunkPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, completeChunkBytes)
+		return &b
+	},
+}
+
+// WithCompactXML sends the CompleteMultipartUpload body without indentation,
+// which makes it about 14% smaller, for bandwidth-sensitive users
+// completing uploads of many parts. By default the body is indented for
+// readability in debug output and captured traffic.
+func WithCompactXML() Option {
+	return func(mpuc *MultipartClient) {
+		mpuc.compactXML = true
+	}
+}
+
+// MarshalCompleteBody returns the exact bytes CompleteMultipartUpload sends
+// for body, for callers that sign or hash the request body themselves. The
+// encoding is canonical: the same body and client options always give the
+// same bytes, with the parts in the order given, each with its PartNumber
+// before its ETag, no XML declaration, and the namespace and indentation of
+// the client's options.
+func (mpuc *MultipartClient) MarshalCompleteBody(body CompleteMultipartUploadBody) ([]byte, error) {
+	var b bytes.Buffer
+	if _, err := mpuc.completeBody(body).WriteTo(&b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// completeBody returns the request body of CompleteMultipartUpload for body.
+func (mpuc *MultipartClient) completeBody(body CompleteMultipartUploadBody) xmlBody {
+	return xmlBody{v: body, start: mpuc.completeStart(), compact: mpuc.compactXML}
+}
+
+// canWriteCompleteBody reports whether writeCompleteBody can encode a body as
+// the element start.
+func canWriteCompleteBody(start xml.StartElement) bool {
+	return start.Name.Local != "" && len(start.Attr) == 0
+}
+
+// writeCompleteBody writes body to w encoded as the element start, byte for
+// byte as encodeXML would, but without reflection: encoding/xml takes several
+// milliseconds for 10,000 parts, during which every part is uploaded but the
+// object doesn't exist yet.
+func writeCompleteBody(w io.Writer, body CompleteMultipartUploadBody, start xml.StartElement, compact bool) error {
+	// The markup before a part's number, before its ETag, after the part,
+	// and after the last part.
+	partOpen, etagOpen, partClose, partsClose := "\n  <Part>\n    <PartNumber>", "\n    <ETag>", "\n  </Part>", "\n"
+	if compact {
+		partOpen, etagOpen, partClose, partsClose = "<Part><PartNumber>", "<ETag>", "</Part>", ""
+	}
+
+	bp := completeChunkPool.Get().(*[]byte)
+	b := (*bp)[:0]
+	defer func() {
+		if cap(b) <= maxPooledBufferBytes {
+			*bp = b[:0]
+			completeChunkPool.Put(bp)
+		}
+	}()
+
+	b = append(b, '<')
+	b = append(b, start.Name.Local...)
+	if start.Name.Space != "" {
+		b = append(b, ` xmlns="`...)
+		b = appendEscapedXML(b, start.Name.Space)
+		b = append(b, '"')
+	}
+	b = append(b, '>')
+	for _, part := range body.Parts {
+		b = append(b, partOpen...)
+		b = strconv.AppendInt(b, int64(part.PartNumber), 10)
+		b = append(b, "</PartNumber>"...)
+		if part.ETag != "" {
+			b = append(b, etagOpen...)
+			b = appendEscapedXML(b, part.ETag)
+			b = append(b, "</ETag>"...)
+		}
+		b = append(b, partClose...)
+		if len(b) >= completeChunkBytes {
+			if _, err := w.Write(b); err != nil {
+				return err
+			}
+			b = b[:0]
+		}
+	}
+	if len(body.Parts) > 0 {
+		b = append(b, partsClose...)
+	}
+	b = append(b, "</"...)
+	b = append(b, start.Name.Local...)
+	b = append(b, '>')
+	_, err := w.Write(b)
+	return err
+}
+
+// appendEscapedXML appends s to b escaped as encoding/xml escapes text.
+func appendEscapedXML(b []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		var esc string
+		switch c := s[i]; c {
+		case '"':
+			esc = "&#34;"
+		case '\'':
+			esc = "&#39;"
+		case '&':
+			esc = "&amp;"
+		case '<':
+			esc = "&lt;"
+		case '>':
+			esc = "&gt;"
+		default:
+			if c < ' ' || c >= utf8.RuneSelf {
+				// Leave control characters and non-ASCII text, which ETags
+				// don't have, to encoding/xml.
+				w := appendWriter(b)
+				xml.EscapeText(&w, []byte(s[i:]))
+				return w
+			}
+			b = append(b, c)
+			continue
+		}
+		b = append(b, esc...)
+	}
+	return b
+}
+
+// appendWriter appends what is written to it to itself.
+type appendWriter []byte
+
+func (w *appendWriter) Write(p []byte) (int, error) {
+	*w = append(*w, p...)
+	return len(p), nil
+}
@@ -0,0 +1,420 @@
+// Package conformance is a suite of scenarios checking that a backend of the
+// XML multipart API behaves like Cloud Storage, as observed through
+// multipartclient. It runs against real GCS, an emulator or a fake server, so
+// alternative backends and fakes stay aligned with the real API.
+//
+// To run it from a test:
+//
+//	func TestConformance(t *testing.T) {
+//		conformance.Run(t, conformance.Target{HTTPClient: hc, Bucket: "my-test-bucket"})
+//	}
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+)
+
+// Target is a backend to run the suite against.
+type Target struct {
+	// HTTPClient sends the requests and adds any credentials. For an emulator
+	// or fake, its transport should send requests for storage.googleapis.com
+	// to the backend.
+	HTTPClient *http.Client
+	// Bucket is an existing bucket the suite may create and delete objects
+	// in.
+	Bucket string
+	// KeyPrefix prefixes the names of objects created by the suite. Defaults
+	// to "multipartclient-conformance/".
+	KeyPrefix string
+	// Options are passed to multipartclient.New.
+	Options []multipartclient.Option
+	// S3, if set, makes the target an S3-compatible server: the client is
+	// created WithS3Compatibility(*S3), objects are read and deleted at its
+	// endpoint, and scenarios of behavior specific to Cloud Storage are
+	// skipped.
+	S3 *multipartclient.S3Compatibility
+}
+
+// Env is the environment a Scenario runs in.
+type Env struct {
+	Client     *multipartclient.MultipartClient
+	HTTPClient *http.Client
+	Bucket     string
+
+	t      *testing.T
+	prefix string
+	s3     *multipartclient.S3Compatibility
+}
+
+// Key returns a unique object name for the scenario. The object, if
+// created, is deleted when the scenario ends.
+func (env *Env) Key(name string) string {
+	key := fmt.Sprintf("%s%s-%d", env.prefix, name, rand.Int63())
+	env.t.Cleanup(func() {
+		// Best effort: the object may not have been created.
+		req, err := http.NewRequest(http.MethodDelete, env.objectURL(key), http.NoBody)
+		if err != nil {
+			return
+		}
+		if resp, err := env.HTTPClient.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	})
+	return key
+}
+
+// Scenario is a behavior of the API checked by the suite.
+type Scenario struct {
+	Name string
+	Run  func(ctx context.Context, t *testing.T, env *Env)
+	// GCSOnly marks behavior specific to Cloud Storage, which is not checked
+	// against S3-compatible targets.
+	GCSOnly bool
+}
+
+// Run runs every scenario of Scenarios against target as a subtest.
+func Run(t *testing.T, target Target) {
+	prefix := target.KeyPrefix
+	if prefix == "" {
+		prefix = "multipartclient-conformance/"
+	}
+	for _, sc := range Scenarios {
+		t.Run(sc.Name, func(t *testing.T) {
+			if sc.GCSOnly && target.S3 != nil {
+				t.Skip("scenario is specific to Cloud Storage")
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+			defer cancel()
+			opts := target.Options
+			if target.S3 != nil {
+				opts = append(slices.Clip(opts), multipartclient.WithS3Compatibility(*target.S3))
+			}
+			env := &Env{
+				Client:     multipartclient.New(target.HTTPClient, opts...),
+				HTTPClient: target.HTTPClient,
+				Bucket:     target.Bucket,
+				t:          t,
+				prefix:     prefix,
+				s3:         target.S3,
+			}
+			sc.Run(ctx, t, env)
+		})
+	}
+}
+
+// Scenarios are the scenarios run by Run.
+var Scenarios = []Scenario{
+	{Name: "SinglePartUpload", Run: singlePartUpload},
+	{Name: "MultiPartUpload", Run: multiPartUpload},
+	{Name: "PartTooSmall", Run: partTooSmall},
+	{Name: "ListObjectParts", Run: listObjectParts},
+	{Name: "PartOverwritten", Run: partOverwritten},
+	{Name: "ListMultipartUploads", Run: listMultipartUploads},
+	{Name: "AbortDiscardsUpload", Run: abortDiscardsUpload},
+	{Name: "CompleteWithWrongETag", Run: completeWithWrongETag},
+	{Name: "UploadPartCopy", Run: uploadPartCopy},
+	{Name: "VerifiedChecksums", Run: verifiedChecksums, GCSOnly: true},
+}
+
+// objectURL returns the URL of the object key.
+func (env *Env) objectURL(key string) string {
+	switch {
+	case env.s3 == nil:
+		return fmt.Sprintf("https://storage.googleapis.com/%s/%s", env.Bucket, key)
+	case env.s3.URLStyle == multipartclient.VirtualHostedStyle:
+		scheme, host, _ := strings.Cut(strings.TrimSuffix(env.s3.Endpoint, "/"), "://")
+		return fmt.Sprintf("%s://%s.%s/%s", scheme, env.Bucket, host, key)
+	default:
+		return fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(env.s3.Endpoint, "/"), env.Bucket, key)
+	}
+}
+
+// upload is a multipart upload in progress, aborted at the end of the
+// scenario unless completed.
+type upload struct {
+	env       *Env
+	key       string
+	id        string
+	completed bool
+}
+
+func initiate(ctx context.Context, t *testing.T, env *Env, key string) *upload {
+	t.Helper()
+
+	result, err := env.Client.InitiateMultipartUpload(ctx, &multipartclient.InitiateMultipartUploadRequest{Bucket: env.Bucket, Key: key})
+	if err != nil {
+		t.Fatalf("InitiateMultipartUpload() failed: %v", err)
+	}
+	if result.UploadID == "" {
+		t.Fatal("InitiateMultipartUpload() returned an empty upload ID")
+	}
+	u := &upload{env: env, key: key, id: result.UploadID}
+	t.Cleanup(func() {
+		if !u.completed {
+			_ = env.Client.AbortMultipartUpload(context.Background(), &multipartclient.AbortMultipartUploadRequest{Bucket: env.Bucket, Key: key, UploadID: u.id})
+		}
+	})
+	return u
+}
+
+func (u *upload) part(ctx context.Context, partNumber int, data []byte) (*multipartclient.UploadObjectPartResult, error) {
+	return u.env.Client.UploadObjectPart(ctx, &multipartclient.UploadObjectPartRequest{
+		Bucket:     u.env.Bucket,
+		Key:        u.key,
+		PartNumber: partNumber,
+		UploadID:   u.id,
+		Body:       io.NopCloser(bytes.NewReader(data)),
+	})
+}
+
+func (u *upload) mustPart(ctx context.Context, t *testing.T, partNumber int, data []byte) multipartclient.CompletePart {
+	t.Helper()
+
+	result, err := u.part(ctx, partNumber, data)
+	if err != nil {
+		t.Fatalf("UploadObjectPart(%d) failed: %v", partNumber, err)
+	}
+	if result.ETag == "" {
+		t.Fatalf("UploadObjectPart(%d) returned an empty ETag", partNumber)
+	}
+	return multipartclient.CompletePart{PartNumber: partNumber, ETag: result.ETag}
+}
+
+func (u *upload) complete(ctx context.Context, parts ...multipartclient.CompletePart) (*multipartclient.CompleteMultipartUploadResult, error) {
+	result, err := u.env.Client.CompleteMultipartUpload(ctx, &multipartclient.CompleteMultipartUploadRequest{
+		Bucket:   u.env.Bucket,
+		Key:      u.key,
+		UploadID: u.id,
+		Body:     multipartclient.CompleteMultipartUploadBody{Parts: parts},
+	})
+	if err == nil {
+		u.completed = true
+	}
+	return result, err
+}
+
+// readObject returns the contents of the object key.
+func readObject(ctx context.Context, t *testing.T, env *Env, key string) []byte {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, env.objectURL(key), http.NoBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := env.HTTPClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to read object %s: %v", key, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read object %s: %v", key, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("failed to read object %s: %s: %s", key, resp.Status, data)
+	}
+	return data
+}
+
+func randomData(n int) []byte {
+	data := make([]byte, n)
+	// Reads from a rand.Rand never fail.
+	_, _ = rand.New(rand.NewSource(time.Now().UnixNano())).Read(data)
+	return data
+}
+
+func singlePartUpload(ctx context.Context, t *testing.T, env *Env) {
+	key := env.Key("single-part")
+	data := []byte("conformance single part")
+	u := initiate(ctx, t, env, key)
+	part := u.mustPart(ctx, t, 1, data)
+	result, err := u.complete(ctx, part)
+	if err != nil {
+		t.Fatalf("CompleteMultipartUpload() failed: %v", err)
+	}
+	if result.ETag == "" {
+		t.Error("CompleteMultipartUpload() returned an empty ETag")
+	}
+	if got := readObject(ctx, t, env, key); !bytes.Equal(got, data) {
+		t.Errorf("got object %q, want %q", got, data)
+	}
+}
+
+func multiPartUpload(ctx context.Context, t *testing.T, env *Env) {
+	key := env.Key("multi-part")
+	first, last := randomData(multipartclient.MinPartSize), []byte("last part")
+	u := initiate(ctx, t, env, key)
+	part1 := u.mustPart(ctx, t, 1, first)
+	part2 := u.mustPart(ctx, t, 2, last)
+	if _, err := u.complete(ctx, part1, part2); err != nil {
+		t.Fatalf("CompleteMultipartUpload() failed: %v", err)
+	}
+	if got := readObject(ctx, t, env, key); !bytes.Equal(got, append(first, last...)) {
+		t.Errorf("got object of %d bytes, want the %d bytes of both parts", len(got), len(first)+len(last))
+	}
+}
+
+func partTooSmall(ctx context.Context, t *testing.T, env *Env) {
+	u := initiate(ctx, t, env, env.Key("part-too-small"))
+	part1 := u.mustPart(ctx, t, 1, []byte("too small"))
+	part2 := u.mustPart(ctx, t, 2, []byte("last part"))
+	_, err := u.complete(ctx, part1, part2)
+	if err == nil {
+		t.Fatal("CompleteMultipartUpload() with a small non-final part succeeded, want EntityTooSmall")
+	}
+	if errorCode(err) != "EntityTooSmall" {
+		t.Errorf("got error %v, want EntityTooSmall", err)
+	}
+}
+
+func listObjectParts(ctx context.Context, t *testing.T, env *Env) {
+	key := env.Key("list-parts")
+	u := initiate(ctx, t, env, key)
+	// Upload out of order; parts are listed by number.
+	part2 := u.mustPart(ctx, t, 2, []byte("part two"))
+	part1 := u.mustPart(ctx, t, 1, []byte("part one"))
+
+	result, err := env.Client.ListObjectParts(ctx, &multipartclient.ListObjectPartsRequest{Bucket: env.Bucket, Key: key, UploadID: u.id})
+	if err != nil {
+		t.Fatalf("ListObjectParts() failed: %v", err)
+	}
+	want := []multipartclient.CompletePart{part1, part2}
+	if fmt.Sprint(result.Parts) != fmt.Sprint(want) {
+		t.Errorf("got parts %v, want %v", result.Parts, want)
+	}
+}
+
+func partOverwritten(ctx context.Context, t *testing.T, env *Env) {
+	key := env.Key("part-overwritten")
+	u := initiate(ctx, t, env, key)
+	u.mustPart(ctx, t, 1, []byte("first version"))
+	part := u.mustPart(ctx, t, 1, []byte("second version"))
+	if _, err := u.complete(ctx, part); err != nil {
+		t.Fatalf("CompleteMultipartUpload() failed: %v", err)
+	}
+	if got, want := readObject(ctx, t, env, key), "second version"; string(got) != want {
+		t.Errorf("got object %q, want %q", got, want)
+	}
+}
+
+func listMultipartUploads(ctx context.Context, t *testing.T, env *Env) {
+	key := env.Key("list-uploads")
+	u := initiate(ctx, t, env, key)
+
+	listed := func() bool {
+		result, err := env.Client.ListMultipartUploads(ctx, &multipartclient.ListMultipartUploadsRequest{Bucket: env.Bucket})
+		if err != nil {
+			t.Fatalf("ListMultipartUploads() failed: %v", err)
+		}
+		for _, upload := range result.Uploads {
+			if upload.UploadID == u.id {
+				return true
+			}
+		}
+		return false
+	}
+	if !listed() {
+		t.Errorf("upload %s not listed after InitiateMultipartUpload()", u.id)
+	}
+	if err := env.Client.AbortMultipartUpload(ctx, &multipartclient.AbortMultipartUploadRequest{Bucket: env.Bucket, Key: key, UploadID: u.id}); err != nil {
+		t.Fatalf("AbortMultipartUpload() failed: %v", err)
+	}
+	if listed() {
+		t.Errorf("upload %s listed after AbortMultipartUpload()", u.id)
+	}
+}
+
+func abortDiscardsUpload(ctx context.Context, t *testing.T, env *Env) {
+	key := env.Key("abort")
+	u := initiate(ctx, t, env, key)
+	u.mustPart(ctx, t, 1, []byte("discarded"))
+	if err := env.Client.AbortMultipartUpload(ctx, &multipartclient.AbortMultipartUploadRequest{Bucket: env.Bucket, Key: key, UploadID: u.id}); err != nil {
+		t.Fatalf("AbortMultipartUpload() failed: %v", err)
+	}
+	if _, err := u.part(ctx, 2, []byte("too late")); err == nil {
+		t.Error("UploadObjectPart() after AbortMultipartUpload() succeeded, want NoSuchUpload")
+	} else if errorCode(err) != "NoSuchUpload" {
+		t.Errorf("got error %v, want NoSuchUpload", err)
+	}
+}
+
+func completeWithWrongETag(ctx context.Context, t *testing.T, env *Env) {
+	u := initiate(ctx, t, env, env.Key("wrong-etag"))
+	u.mustPart(ctx, t, 1, []byte("part"))
+	_, err := u.complete(ctx, multipartclient.CompletePart{PartNumber: 1, ETag: `"00000000000000000000000000000000"`})
+	if err == nil {
+		t.Fatal("CompleteMultipartUpload() with a wrong ETag succeeded, want InvalidPart")
+	}
+	if errorCode(err) != "InvalidPart" {
+		t.Errorf("got error %v, want InvalidPart", err)
+	}
+}
+
+func uploadPartCopy(ctx context.Context, t *testing.T, env *Env) {
+	srcKey := env.Key("copy-source")
+	src := initiate(ctx, t, env, srcKey)
+	if _, err := src.complete(ctx, src.mustPart(ctx, t, 1, []byte("0123456789"))); err != nil {
+		t.Fatalf("CompleteMultipartUpload() of the source failed: %v", err)
+	}
+
+	dstKey := env.Key("copy-destination")
+	dst := initiate(ctx, t, env, dstKey)
+	copyResult, err := env.Client.UploadPartCopy(ctx, &multipartclient.UploadPartCopyRequest{
+		Bucket:       env.Bucket,
+		Key:          dstKey,
+		PartNumber:   1,
+		UploadID:     dst.id,
+		SourceBucket: env.Bucket,
+		SourceKey:    srcKey,
+		SourceRange:  &multipartclient.ByteRange{Offset: 2, Length: 5},
+	})
+	if err != nil {
+		t.Fatalf("UploadPartCopy() failed: %v", err)
+	}
+	if _, err := dst.complete(ctx, multipartclient.CompletePart{PartNumber: 1, ETag: copyResult.ETag}); err != nil {
+		t.Fatalf("CompleteMultipartUpload() failed: %v", err)
+	}
+	if got, want := readObject(ctx, t, env, dstKey), "23456"; string(got) != want {
+		t.Errorf("got object %q, want %q", got, want)
+	}
+}
+
+func verifiedChecksums(ctx context.Context, t *testing.T, env *Env) {
+	u := initiate(ctx, t, env, env.Key("checksums"))
+	result, err := env.Client.UploadObjectPart(ctx, &multipartclient.UploadObjectPartRequest{
+		Bucket:          env.Bucket,
+		Key:             u.key,
+		PartNumber:      1,
+		UploadID:        u.id,
+		Body:            io.NopCloser(bytes.NewReader([]byte("checksummed part"))),
+		VerifyChecksums: true,
+	})
+	if err != nil {
+		t.Fatalf("UploadObjectPart() with VerifyChecksums failed: %v", err)
+	}
+	if !result.Hashes.HasCRC32C && result.Hashes.MD5 == nil {
+		t.Error("server reported no x-goog-hash for the part")
+	}
+}
+
+// errorCode returns the code of the error response err, or "" if err isn't
+// one.
+func errorCode(err error) string {
+	var apiErr *multipartclient.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return ""
+}
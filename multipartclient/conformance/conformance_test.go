@@ -0,0 +1,74 @@
+package conformance_test
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/conformance"
+	"golang.org/x/oauth2/google"
+)
+
+var (
+	bucket   = flag.String("conformance.bucket", "", "bucket to run the conformance suite in; the suite is skipped if empty")
+	endpoint = flag.String("conformance.endpoint", "", "base URL of an emulator to run against instead of GCS, e.g. http://localhost:4443")
+	s3       = flag.Bool("conformance.s3", false, "treat -conformance.endpoint as an S3-compatible server, e.g. MinIO")
+)
+
+// endpointTransport sends requests for storage.googleapis.com to endpoint.
+type endpointTransport struct {
+	endpoint *url.URL
+}
+
+func (et endpointTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = et.endpoint.Scheme
+	req.URL.Host = et.endpoint.Host
+	req.Host = et.endpoint.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// TestConformance runs the suite against GCS with Application Default
+// Credentials, against the emulator at -conformance.endpoint, or against the
+// S3-compatible server there with -conformance.s3:
+//
+//	go test ./multipartclient/conformance -conformance.bucket=my-bucket
+//	go test ./multipartclient/conformance -conformance.bucket=my-bucket \
+//		-conformance.endpoint=http://localhost:9000 -conformance.s3
+//
+// The S3-compatible server must accept anonymous requests to the bucket.
+func TestConformance(t *testing.T) {
+	if *bucket == "" {
+		t.Skip("set -conformance.bucket to run the conformance suite")
+	}
+
+	var hc *http.Client
+	if *s3 {
+		if *endpoint == "" {
+			t.Fatal("-conformance.s3 requires -conformance.endpoint")
+		}
+		conformance.Run(t, conformance.Target{
+			HTTPClient: http.DefaultClient,
+			Bucket:     *bucket,
+			S3:         &multipartclient.S3Compatibility{Endpoint: *endpoint},
+		})
+		return
+	}
+	if *endpoint != "" {
+		u, err := url.Parse(*endpoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hc = &http.Client{Transport: endpointTransport{endpoint: u}}
+	} else {
+		var err error
+		hc, err = google.DefaultClient(context.Background(), "https://www.googleapis.com/auth/devstorage.read_write")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	conformance.Run(t, conformance.Target{HTTPClient: hc, Bucket: *bucket})
+}
@@ -0,0 +1,45 @@
+package multipartclient
+
+import (
+	"context"
+	"net/http"
+)
+
+type headersKey struct{}
+
+// ContextWithHeaders returns a copy of ctx whose requests carry the headers
+// h, such as for middleware to tag every request of a tenant. Cloud Storage
+// copies x-goog-custom-audit-* headers into Cloud Audit Logs entries, so
+//
+//	ctx = multipartclient.ContextWithHeaders(ctx, http.Header{"X-Goog-Custom-Audit-Tenant": {"acme"}})
+//
+// labels the requests in the audit log. The headers are added to any set on
+// ctx by an earlier ContextWithHeaders, replacing those of the same name.
+// Headers the client sets itself, such as Content-Length or x-goog-hash,
+// take precedence.
+func ContextWithHeaders(ctx context.Context, h http.Header) context.Context {
+	merged := HeadersFromContext(ctx).Clone()
+	if merged == nil {
+		merged = make(http.Header, len(h))
+	}
+	for name, values := range h {
+		merged[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+	}
+	return context.WithValue(ctx, headersKey{}, merged)
+}
+
+// HeadersFromContext returns the headers set by ContextWithHeaders, or nil if
+// there are none. The returned headers must not be modified.
+func HeadersFromContext(ctx context.Context) http.Header {
+	h, _ := ctx.Value(headersKey{}).(http.Header)
+	return h
+}
+
+// setContextHeaders adds the headers of ctx that httpReq doesn't already have.
+func setContextHeaders(ctx context.Context, httpReq *http.Request) {
+	for name, values := range HeadersFromContext(ctx) {
+		if _, ok := httpReq.Header[name]; !ok {
+			httpReq.Header[name] = append([]string(nil), values...)
+		}
+	}
+}
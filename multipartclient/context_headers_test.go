@@ -0,0 +1,56 @@
+package multipartclient
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestContextWithHeaders(t *testing.T) {
+	var got http.Header
+	hc := &http.Client{Transport: funcTransport(func(req *http.Request) (*http.Response, error) {
+		got = req.Header.Clone()
+		return nil, errMock
+	})}
+	mpuc := New(hc)
+
+	ctx := ContextWithHeaders(context.Background(), http.Header{
+		"x-goog-custom-audit-tenant": {"acme"},
+		"X-Team":                     {"storage"},
+	})
+	ctx = ContextWithHeaders(ctx, http.Header{
+		"X-Team":                {"ingest"},
+		"X-Goog-Copy-Source":    {"/other/object"},
+		"X-Goog-Copy-Source-Xx": {"kept"},
+	})
+	mpuc.UploadPartCopy(ctx, &UploadPartCopyRequest{
+		Bucket:       "bucket1",
+		Key:          "object.txt",
+		PartNumber:   1,
+		UploadID:     "u1",
+		SourceBucket: "bucket1",
+		SourceKey:    "source.txt",
+	})
+
+	want := http.Header{
+		"X-Goog-Custom-Audit-Tenant": {"acme"},
+		// Replaced by the inner context.
+		"X-Team": {"ingest"},
+		// Set by the client.
+		"X-Goog-Copy-Source":    {"/bucket1/source.txt"},
+		"X-Goog-Copy-Source-Xx": {"kept"},
+	}
+	for name := range got {
+		if _, ok := want[name]; !ok {
+			got.Del(name)
+		}
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected diff for headers (-want, +got):\n%s", diff)
+	}
+	if h := HeadersFromContext(context.Background()); h != nil {
+		t.Errorf("got headers %v from an empty context, want nil", h)
+	}
+}
@@ -0,0 +1,75 @@
+package multipartclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+const (
+	// correlationIDHeader carries the caller's correlation ID. GCS copies
+	// x-goog-custom-audit-* headers into Cloud Audit Logs entries.
+	correlationIDHeader = "x-goog-custom-audit-correlation-id"
+	// serverRequestIDHeader is the ID GCS assigns to every request, which
+	// Google support asks for when investigating one.
+	serverRequestIDHeader = "X-GUploader-UploadID"
+)
+
+// serverRequestIDKey is serverRequestIDHeader in canonical form, which
+// http.Header.Get would otherwise allocate for every response.
+var serverRequestIDKey = http.CanonicalHeaderKey(serverRequestIDHeader)
+
+type correlationIDKey struct{}
+
+// ContextWithCorrelationID returns a copy of ctx whose requests carry id, so
+// they can be found from the caller's own logs and traces. It is sent in the
+// x-goog-custom-audit-correlation-id header, added to log records and
+// reported with the server's request ID in results and errors.
+func ContextWithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationIDFromContext returns the ID set by ContextWithCorrelationID, or
+// "" if there is none.
+func CorrelationIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// Correlation pairs the caller's correlation ID with the ID the server
+// assigned to the request that produced a result.
+type Correlation struct {
+	CorrelationID   string `xml:"-"`
+	ServerRequestID string `xml:"-"`
+}
+
+func correlationOf(ctx context.Context, resp *http.Response) Correlation {
+	c := Correlation{CorrelationID: CorrelationIDFromContext(ctx)}
+	if resp != nil {
+		c.ServerRequestID = resp.Header.Get(serverRequestIDKey)
+	}
+	return c
+}
+
+// CorrelatedError is returned for a failed request that had a correlation ID
+// or for which the server reported a request ID.
+type CorrelatedError struct {
+	Correlation
+	Err error
+}
+
+func (e *CorrelatedError) Error() string {
+	return fmt.Sprintf("%v (correlation ID %q, server request ID %q)", e.Err, e.CorrelationID, e.ServerRequestID)
+}
+
+func (e *CorrelatedError) Unwrap() error {
+	return e.Err
+}
+
+// correlateError wraps err of a request with its Correlation, if there is any.
+func correlateError(c Correlation, err error) error {
+	if err == nil || c == (Correlation{}) {
+		return err
+	}
+	return &CorrelatedError{Correlation: c, Err: err}
+}
@@ -0,0 +1,104 @@
+package multipartclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCorrelationID(t *testing.T) {
+	tests := []struct {
+		name            string
+		ctx             context.Context
+		respStatus      int
+		serverRequestID string
+		wantHeader      string
+		wantCorrelation Correlation
+		wantErr         bool
+	}{
+		{
+			name:            "Correlation ID paired with server request ID",
+			ctx:             ContextWithCorrelationID(context.Background(), "job-42"),
+			respStatus:      http.StatusOK,
+			serverRequestID: "server-id",
+			wantHeader:      "job-42",
+			wantCorrelation: Correlation{CorrelationID: "job-42", ServerRequestID: "server-id"},
+		},
+		{
+			name:            "No correlation ID",
+			ctx:             context.Background(),
+			respStatus:      http.StatusOK,
+			serverRequestID: "server-id",
+			wantCorrelation: Correlation{ServerRequestID: "server-id"},
+		},
+		{
+			name:            "Failed request",
+			ctx:             ContextWithCorrelationID(context.Background(), "job-42"),
+			respStatus:      http.StatusForbidden,
+			serverRequestID: "server-id",
+			wantHeader:      "job-42",
+			wantCorrelation: Correlation{CorrelationID: "job-42", ServerRequestID: "server-id"},
+			wantErr:         true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotHeader string
+			trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+				gotHeader = req.Header.Get("x-goog-custom-audit-correlation-id")
+				return &http.Response{
+					StatusCode: tc.respStatus,
+					Status:     http.StatusText(tc.respStatus),
+					Header:     http.Header{"X-Guploader-Uploadid": []string{tc.serverRequestID}},
+					Body:       http.NoBody,
+				}, nil
+			})
+			mpuc := New(&http.Client{Transport: trans})
+
+			result, err := mpuc.UploadObjectPart(tc.ctx, &UploadObjectPartRequest{
+				Bucket:     "bucket1",
+				Key:        "object.txt",
+				PartNumber: 1,
+				UploadID:   "my-upload-id",
+				Body:       toBody("contents"),
+			})
+			if gotHeader != tc.wantHeader {
+				t.Errorf("got correlation header %q, want %q", gotHeader, tc.wantHeader)
+			}
+			var gotCorrelation Correlation
+			if tc.wantErr {
+				var correlatedErr *CorrelatedError
+				if !errors.As(err, &correlatedErr) {
+					t.Fatalf("got error %v, want a *CorrelatedError", err)
+				}
+				gotCorrelation = correlatedErr.Correlation
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				gotCorrelation = result.Correlation
+			}
+			if diff := cmp.Diff(tc.wantCorrelation, gotCorrelation); diff != "" {
+				t.Errorf("unexpected diff for correlation: (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCorrelatedError(t *testing.T) {
+	if err := correlateError(Correlation{}, errors.New("failed")); err.Error() != "failed" {
+		t.Errorf("got %q, want the error unwrapped without a correlation", err)
+	}
+	errFailed := errors.New("failed")
+	err := correlateError(Correlation{CorrelationID: "job-42"}, errFailed)
+	if want := `failed (correlation ID "job-42", server request ID "")`; err.Error() != want {
+		t.Errorf("got %q, want %q", err, want)
+	}
+	if !errors.Is(err, errFailed) {
+		t.Error("errors.Is() = false, want the wrapped error")
+	}
+}
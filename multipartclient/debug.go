@@ -0,0 +1,142 @@
+package multipartclient
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// debugDumper writes sanitized requests and responses for troubleshooting.
+// A nil *debugDumper does nothing.
+type debugDumper struct {
+	mu           sync.Mutex
+	w            io.Writer
+	maxBodyBytes int
+}
+
+// bodyCapture records the first bytes read from a request body as the HTTP
+// transport sends it, so the body can be dumped without consuming it.
+type bodyCapture struct {
+	io.ReadCloser
+	mu  sync.Mutex
+	max int
+	buf []byte
+	n   int64
+}
+
+func (c *bodyCapture) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if room := c.max - len(c.buf); room > 0 {
+		c.buf = append(c.buf, p[:min(n, room)]...)
+	}
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *bodyCapture) captured() ([]byte, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return bytes.Clone(c.buf), c.n
+}
+
+// captureRequestBody arranges for the start of req's body to be recorded
+// while it is sent.
+func (d *debugDumper) captureRequestBody(req *http.Request) *bodyCapture {
+	if d == nil || d.maxBodyBytes <= 0 || req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	capture := &bodyCapture{ReadCloser: req.Body, max: d.maxBodyBytes}
+	req.Body = capture
+	return capture
+}
+
+// dump writes req and the response or error it got. The start of the response
+// body is read and put back so callers still see the whole body.
+func (d *debugDumper) dump(req *http.Request, reqBody *bodyCapture, resp *http.Response, err error) {
+	if d == nil {
+		return
+	}
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "--> %s %s\n", req.Method, redactURL(req.URL))
+	writeHeader(b, req.Header)
+	if reqBody != nil {
+		body, n := reqBody.captured()
+		writeBody(b, body, n)
+	}
+
+	switch {
+	case err != nil:
+		fmt.Fprintf(b, "<-- error: %v\n", err)
+	case resp != nil:
+		fmt.Fprintf(b, "<-- %s\n", resp.Status)
+		writeHeader(b, resp.Header)
+		if d.maxBodyBytes > 0 && resp.Body != nil {
+			body, readErr := io.ReadAll(io.LimitReader(resp.Body, int64(d.maxBodyBytes)))
+			resp.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(body), errReader{readErr}, resp.Body), resp.Body}
+			// The full length is unknown without reading the rest.
+			n := int64(len(body))
+			if len(body) == d.maxBodyBytes {
+				n = -1
+			}
+			writeBody(b, body, n)
+		}
+	}
+	b.WriteString("\n")
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	io.WriteString(d.w, b.String())
+}
+
+// errReader returns err, or io.EOF if err is nil, so a read error hit while
+// peeking at a body is still reported to the body's reader.
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	return 0, io.EOF
+}
+
+func writeHeader(b *strings.Builder, h http.Header) {
+	h = redactHeader(h)
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range h[name] {
+			fmt.Fprintf(b, "%s: %s\n", name, value)
+		}
+	}
+}
+
+// writeBody writes the captured start of a body of n bytes, or of unknown
+// length if n is negative.
+func writeBody(b *strings.Builder, body []byte, n int64) {
+	if len(body) == 0 {
+		return
+	}
+	b.WriteString("\n")
+	b.Write(body)
+	switch {
+	case n < 0:
+		fmt.Fprintf(b, "\n[body truncated to %d bytes]", len(body))
+	case n > int64(len(body)):
+		fmt.Fprintf(b, "\n[body truncated to %d of %d bytes]", len(body), n)
+	}
+	b.WriteString("\n")
+}
@@ -0,0 +1,71 @@
+package multipartclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+)
+
+func TestWithDebug(t *testing.T) {
+	trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+		if _, err := io.Copy(io.Discard, req.Body); err != nil {
+			t.Fatal(err)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     "200 OK",
+			Header:     http.Header{"Content-Type": []string{"application/xml"}},
+			Body:       toBody("<InitiateMultipartUploadResult><UploadId>upload-1</UploadId></InitiateMultipartUploadResult>"),
+		}, nil
+	})
+
+	buf := &bytes.Buffer{}
+	mpuc := New(&http.Client{Transport: trans}, WithDebug(buf, 30))
+	ctx := context.Background()
+	_, err := mpuc.UploadObjectPart(ctx, &UploadObjectPartRequest{
+		Bucket:     "bucket1",
+		Key:        "object.txt",
+		PartNumber: 1,
+		UploadID:   "my-upload-id",
+		Body:       toBody("part contents that are longer than the limit"),
+		Hashes:     gcshash.Sums{CRC32C: 1, HasCRC32C: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The peeked response body must still be fully readable.
+	result, err := mpuc.InitiateMultipartUpload(ctx, &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.UploadID != "upload-1" {
+		t.Errorf("got upload ID %q, want %q", result.UploadID, "upload-1")
+	}
+
+	want := "--> PUT https://storage.googleapis.com/bucket1/object.txt?partNumber=1&uploadId=my-upload-id\n" +
+		"X-Goog-Hash: crc32c=AAAAAQ==\n" +
+		"\n" +
+		"part contents that are longer \n" +
+		"[body truncated to 30 of 44 bytes]\n" +
+		"<-- 200 OK\n" +
+		"Content-Type: application/xml\n" +
+		"\n" +
+		"<InitiateMultipartUploadResult\n" +
+		"[body truncated to 30 bytes]\n" +
+		"\n" +
+		"--> POST https://storage.googleapis.com/bucket1/object.txt?uploads\n" +
+		"<-- 200 OK\n" +
+		"Content-Type: application/xml\n" +
+		"\n" +
+		"<InitiateMultipartUploadResult\n" +
+		"[body truncated to 30 bytes]\n" +
+		"\n"
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("unexpected diff for dump: (-want, +got):\n%s", diff)
+	}
+}
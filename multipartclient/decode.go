@@ -0,0 +1,282 @@
+package multipartclient
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// maxXMLResponseBytes bounds the XML body decoded from a response. The
+	// largest legitimate body, a listing of 10,000 parts, is a few MiB.
+	maxXMLResponseBytes = 32 << 20
+	// maxErrorBodyBytes bounds the body of an error response read into an
+	// error message.
+	maxErrorBodyBytes = 64 << 10
+	// maxListEntries bounds the uploads or parts decoded from a listing, which
+	// maxXMLResponseBytes alone doesn't: it holds millions of empty elements.
+	// A page lists at most 1,000, and no upload has more than MaxParts parts.
+	maxListEntries = MaxParts
+)
+
+// ErrResponseTooLarge is returned when a response body exceeds the size the
+// client is willing to decode.
+var ErrResponseTooLarge = errors.New("response body too large")
+
+// limitedReader is like io.LimitedReader, but fails with ErrResponseTooLarge
+// instead of ending the stream at the limit, so a truncated document is not
+// mistaken for a complete one.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if lr.n <= 0 {
+		// Check for data past the limit.
+		var b [1]byte
+		if n, _ := lr.r.Read(b[:]); n > 0 {
+			return 0, ErrResponseTooLarge
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > lr.n {
+		p = p[:lr.n]
+	}
+	n, err := lr.r.Read(p)
+	lr.n -= int64(n)
+	return n, err
+}
+
+// charsetReader converts the charsets GCS and S3-compatible servers declare
+// to UTF-8. Other charsets are rejected rather than decoded as garbage.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "utf-8", "utf8", "us-ascii", "ascii":
+		return input, nil
+	case "iso-8859-1", "latin1":
+		return &latin1Reader{r: input}, nil
+	}
+	return nil, fmt.Errorf("unsupported XML charset %q", charset)
+}
+
+// latin1Reader converts ISO-8859-1 to UTF-8.
+type latin1Reader struct {
+	r   io.Reader
+	buf []byte
+}
+
+func (lr *latin1Reader) Read(p []byte) (int, error) {
+	if len(lr.buf) == 0 {
+		// Every byte becomes at most 2 bytes of UTF-8.
+		in := make([]byte, max(len(p)/2, 1))
+		n, err := lr.r.Read(in)
+		for _, b := range in[:n] {
+			lr.buf = append(lr.buf, string(rune(b))...)
+		}
+		if n == 0 {
+			return 0, err
+		}
+	}
+	n := copy(p, lr.buf)
+	lr.buf = lr.buf[n:]
+	return n, nil
+}
+
+// newXMLDecoder returns a decoder for an XML response body read through r.
+func newXMLDecoder(r *xmlResponseReader) *xml.Decoder {
+	// The decoder reads a bufio.Reader directly rather than wrapping it in a
+	// new one.
+	decoder := xml.NewDecoder(r.buf)
+	decoder.CharsetReader = charsetReader
+	return decoder
+}
+
+// IncompleteResponseError is returned when the body of a successful response
+// ends before the XML document it should hold does, such as when a proxy
+// drops or cuts it short. Operations that are retried are retried on it as on
+// a network error. Its Err is io.EOF if the body was empty, and
+// io.ErrUnexpectedEOF if it was truncated.
+type IncompleteResponseError struct {
+	StatusCode int
+	// BytesRead is the length of the body received.
+	BytesRead int64
+	Err       error
+}
+
+func (e *IncompleteResponseError) Error() string {
+	if e.BytesRead == 0 {
+		return fmt.Sprintf("empty body in %d response where XML was expected", e.StatusCode)
+	}
+	return fmt.Sprintf("XML body of %d response truncated after %d bytes", e.StatusCode, e.BytesRead)
+}
+
+func (e *IncompleteResponseError) Unwrap() error {
+	return e.Err
+}
+
+// incompleteXML returns the *IncompleteResponseError for the error err of
+// decoding the body of resp, of which n bytes were read, or nil if err isn't
+// the end of an incomplete document.
+func incompleteXML(resp *http.Response, n int64, err error) error {
+	var syntaxErr *xml.SyntaxError
+	switch {
+	case errors.Is(err, io.EOF):
+		// Only whitespace, comments or a declaration were read.
+		err = io.EOF
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.As(err, &syntaxErr) && syntaxErr.Msg == "unexpected EOF":
+		err = io.ErrUnexpectedEOF
+	default:
+		return nil
+	}
+	if n == 0 {
+		err = io.EOF
+	}
+	return &IncompleteResponseError{StatusCode: resp.StatusCode, BytesRead: n, Err: err}
+}
+
+// xmlEntryLimiter is implemented by listings, whose entries decodeXMLResponse
+// decodes at most maxListEntries of.
+type xmlEntryLimiter interface {
+	// entryName returns the name of the elements of the entries.
+	entryName() string
+}
+
+func (*ListMultipartUploadsResult) entryName() string { return "Upload" }
+
+func (*ListObjectPartsResult) entryName() string { return "Part" }
+
+// entryLimitReader is an xml.TokenReader that reads the tokens of d, and fails
+// with ErrResponseTooLarge once it has read more than max elements named name.
+type entryLimitReader struct {
+	d    *xml.Decoder
+	name string
+	n    int
+	max  int
+}
+
+func (r *entryLimitReader) Token() (xml.Token, error) {
+	// The decoder reading from r checks and translates the raw tokens.
+	t, err := r.d.RawToken()
+	if start, ok := t.(xml.StartElement); ok && start.Name.Local == r.name {
+		if r.n++; r.n > r.max {
+			return nil, fmt.Errorf("%w: more than %d %s elements", ErrResponseTooLarge, r.max, r.name)
+		}
+	}
+	return t, err
+}
+
+// decodeXMLResponse decodes the XML body of resp into v, reading it through a
+// pooled buffer.
+func decodeXMLResponse(resp *http.Response, v any) error {
+	r := getXMLResponseReader(resp.Body)
+	decoder := newXMLDecoder(r)
+	if l, ok := v.(xmlEntryLimiter); ok {
+		decoder = xml.NewTokenDecoder(&entryLimitReader{d: decoder, name: l.entryName(), max: maxListEntries})
+	}
+	err := decoder.Decode(v)
+	read := maxXMLResponseBytes - r.limited.n
+	putXMLResponseReader(r)
+	if t, ok := v.(xmlTrimmer); ok && err == nil {
+		t.trimSpace()
+	}
+	if incomplete := incompleteXML(resp, read, err); incomplete != nil {
+		return incomplete
+	}
+	if err != nil {
+		// Bound the rest of the body included in the message.
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.LimitReader(resp.Body, maxErrorBodyBytes), resp.Body}
+		respStrBuilder := &strings.Builder{}
+		// strings.Builder.Write does not return errors.
+		_ = resp.Write(respStrBuilder)
+		return fmt.Errorf("failed to parse XML body from HTTP response: %w. Response: %v", err, respStrBuilder.String())
+	}
+	return nil
+}
+
+// Responses are decoded whatever their namespace, which is that of S3 for
+// Cloud Storage and most compatible servers and none for some emulators, and
+// whatever the order of their elements. What else varies between servers is
+// normalized below.
+
+// xmlTrimmer is implemented by responses whose text fields decodeXMLResponse
+// trims of the whitespace that servers which indent their documents leave
+// around them. Object names are never trimmed, since they may begin or end
+// with spaces.
+type xmlTrimmer interface {
+	trimSpace()
+}
+
+func (r *InitiateMultipartUploadResult) trimSpace() {
+	r.Bucket = strings.TrimSpace(r.Bucket)
+	r.UploadID = strings.TrimSpace(r.UploadID)
+}
+
+func (r *CopyPartResult) trimSpace() {
+	r.LastModified = strings.TrimSpace(r.LastModified)
+	r.ETag = strings.TrimSpace(r.ETag)
+}
+
+func (r *CompleteMultipartUploadResult) trimSpace() {
+	r.Location = strings.TrimSpace(r.Location)
+	r.Bucket = strings.TrimSpace(r.Bucket)
+	r.ETag = strings.TrimSpace(r.ETag)
+}
+
+func (r *ListMultipartUploadsResult) trimSpace() {
+	for i := range r.Uploads {
+		r.Uploads[i].UploadID = strings.TrimSpace(r.Uploads[i].UploadID)
+	}
+	r.NextUploadIDMarker = strings.TrimSpace(r.NextUploadIDMarker)
+}
+
+func (r *ListObjectPartsResult) trimSpace() {
+	for i := range r.Parts {
+		r.Parts[i].ETag = strings.TrimSpace(r.Parts[i].ETag)
+	}
+}
+
+// xmlTimeLayouts are the layouts of timestamps in responses: RFC 3339, as Cloud
+// Storage and S3 send them, RFC 3339 without a zone, taken as UTC, and the
+// HTTP date format of some emulators.
+var xmlTimeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", http.TimeFormat, time.RFC1123Z}
+
+// parseXMLTime parses a timestamp of a response in any of xmlTimeLayouts.
+func parseXMLTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range xmlTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time %q", s)
+}
+
+// UnmarshalXML decodes an upload of a listing, with its initiation time in any
+// of xmlTimeLayouts.
+func (u *ListUpload) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var v struct {
+		Key       string `xml:"Key"`
+		UploadID  string `xml:"UploadId"`
+		Initiated string `xml:"Initiated"`
+	}
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+	*u = ListUpload{XMLName: start.Name, Key: v.Key, UploadID: v.UploadID}
+	if v.Initiated != "" {
+		t, err := parseXMLTime(v.Initiated)
+		if err != nil {
+			return err
+		}
+		u.Initiated = t
+	}
+	return nil
+}
@@ -0,0 +1,393 @@
+package multipartclient
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+)
+
+func xmlResponse(body string) *http.Response {
+	return &http.Response{StatusCode: http.StatusOK, Status: "OK", Header: http.Header{}, Body: toBody(body)}
+}
+
+func TestDecodeXMLResponseCharsets(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantKey string
+		wantErr bool
+	}{
+		{
+			name:    "UTF-8",
+			body:    `<?xml version="1.0" encoding="UTF-8"?><InitiateMultipartUploadResult><Key>caf` + "é" + `</Key></InitiateMultipartUploadResult>`,
+			wantKey: "café",
+		},
+		{
+			name:    "ISO-8859-1",
+			body:    `<?xml version="1.0" encoding="ISO-8859-1"?><InitiateMultipartUploadResult><Key>caf` + "\xe9" + `</Key></InitiateMultipartUploadResult>`,
+			wantKey: "café",
+		},
+		{
+			name:    "Unsupported charset",
+			body:    `<?xml version="1.0" encoding="Shift_JIS"?><InitiateMultipartUploadResult><Key>key</Key></InitiateMultipartUploadResult>`,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := &InitiateMultipartUploadResult{}
+			err := decodeXMLResponse(xmlResponse(tc.body), result)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tc.wantErr)
+			}
+			if result.Key != tc.wantKey {
+				t.Errorf("got key %q, want %q", result.Key, tc.wantKey)
+			}
+		})
+	}
+}
+
+func TestDecodeXMLResponseServerVariants(t *testing.T) {
+	initiated := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name string
+		body string
+		// got is decoded into and compared with want.
+		got, want any
+	}{
+		{
+			name: "Cloud Storage",
+			body: `<?xml version='1.0' encoding='UTF-8'?><InitiateMultipartUploadResult xmlns='http://s3.amazonaws.com/doc/2006-03-01/'><Bucket>bucket1</Bucket><Key>object.txt</Key><UploadId>ABPnzm5h1Sv</UploadId></InitiateMultipartUploadResult>`,
+			got:  &InitiateMultipartUploadResult{},
+			want: &InitiateMultipartUploadResult{Bucket: "bucket1", Key: "object.txt", UploadID: "ABPnzm5h1Sv"},
+		},
+		{
+			name: "No namespace",
+			body: `<InitiateMultipartUploadResult><Bucket>bucket1</Bucket><Key>object.txt</Key><UploadId>upload-1</UploadId></InitiateMultipartUploadResult>`,
+			got:  &InitiateMultipartUploadResult{},
+			want: &InitiateMultipartUploadResult{Bucket: "bucket1", Key: "object.txt", UploadID: "upload-1"},
+		},
+		{
+			name: "Prefixed namespace",
+			body: `<s3:InitiateMultipartUploadResult xmlns:s3="http://s3.amazonaws.com/doc/2006-03-01/"><s3:Bucket>bucket1</s3:Bucket><s3:Key>object.txt</s3:Key><s3:UploadId>upload-1</s3:UploadId></s3:InitiateMultipartUploadResult>`,
+			got:  &InitiateMultipartUploadResult{},
+			want: &InitiateMultipartUploadResult{Bucket: "bucket1", Key: "object.txt", UploadID: "upload-1"},
+		},
+		{
+			name: "Reordered, indented, with a byte order mark and unknown elements",
+			body: "\uFEFF" + `<?xml version="1.0" encoding="UTF-8"?>
+<!-- generated -->
+<InitiateMultipartUploadResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <UploadId>
+    upload-1
+  </UploadId>
+  <Owner><ID>owner</ID></Owner>
+  <Key> object.txt </Key>
+  <Bucket>bucket1</Bucket>
+</InitiateMultipartUploadResult>`,
+			got: &InitiateMultipartUploadResult{},
+			// Object names may end in spaces, so only they aren't trimmed.
+			want: &InitiateMultipartUploadResult{Bucket: "bucket1", Key: " object.txt ", UploadID: "upload-1"},
+		},
+		{
+			name: "MinIO copy",
+			body: `<?xml version="1.0" encoding="UTF-8"?>
+<CopyPartResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><LastModified>2024-01-02T03:04:05.000Z</LastModified><ETag>&#34;d41d8cd98f00b204e9800998ecf8427e&#34;</ETag></CopyPartResult>`,
+			got:  &CopyPartResult{},
+			want: &CopyPartResult{LastModified: "2024-01-02T03:04:05.000Z", ETag: `"d41d8cd98f00b204e9800998ecf8427e"`},
+		},
+		{
+			name: "Complete with the ETag first",
+			body: `<CompleteMultipartUploadResult>
+	<ETag>"abc-2"</ETag>
+	<Key>object.txt</Key>
+	<Bucket>bucket1</Bucket>
+	<Location>http://bucket1.storage.googleapis.com/object.txt</Location>
+</CompleteMultipartUploadResult>`,
+			got: &CompleteMultipartUploadResult{},
+			want: &CompleteMultipartUploadResult{
+				Location: "http://bucket1.storage.googleapis.com/object.txt",
+				Bucket:   "bucket1",
+				Key:      "object.txt",
+				ETag:     `"abc-2"`,
+			},
+		},
+		{
+			name: "Ceph part listing",
+			body: `<?xml version="1.0" encoding="UTF-8"?><ListPartsResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Bucket>bucket1</Bucket><Key>object.txt</Key><UploadId>2~abc</UploadId><MaxParts>1000</MaxParts><IsTruncated>false</IsTruncated><Part><LastModified>2024-01-02T03:04:05.000Z</LastModified><PartNumber>1</PartNumber><ETag>"a"</ETag><Size>5242880</Size></Part><Part><ETag>
+"b"
+</ETag><PartNumber> 2 </PartNumber></Part></ListPartsResult>`,
+			got:  &ListObjectPartsResult{},
+			want: &ListObjectPartsResult{Parts: []CompletePart{{PartNumber: 1, ETag: `"a"`}, {PartNumber: 2, ETag: `"b"`}}},
+		},
+		{
+			name: "Upload listing time formats",
+			body: `<ListMultipartUploadsResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+<Upload><Key>a</Key><UploadId>1</UploadId><Initiated>2024-01-02T03:04:05.000Z</Initiated></Upload>
+<Upload><Initiated>2024-01-02T03:04:05</Initiated><UploadId> 2 </UploadId><Key>b</Key></Upload>
+<Upload><Key>c</Key><UploadId>3</UploadId><Initiated>Tue, 02 Jan 2024 03:04:05 GMT</Initiated></Upload>
+<Upload><Key>d</Key><UploadId>4</UploadId><Initiated>
+  2024-01-02T04:04:05+01:00
+</Initiated></Upload>
+<Upload><Key>e</Key><UploadId>5</UploadId></Upload>
+</ListMultipartUploadsResult>`,
+			got: &ListMultipartUploadsResult{},
+			want: &ListMultipartUploadsResult{Uploads: []ListUpload{
+				{Key: "a", UploadID: "1", Initiated: initiated},
+				{Key: "b", UploadID: "2", Initiated: initiated},
+				{Key: "c", UploadID: "3", Initiated: initiated},
+				{Key: "d", UploadID: "4", Initiated: initiated},
+				{Key: "e", UploadID: "5"},
+			}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := decodeXMLResponse(xmlResponse(tc.body), tc.got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, tc.got, cmpopts.IgnoreTypes(xml.Name{}), cmpopts.EquateApproxTime(0)); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDecodeXMLResponseInvalidTime(t *testing.T) {
+	body := `<ListMultipartUploadsResult><Upload><Key>a</Key><UploadId>1</UploadId><Initiated>yesterday</Initiated></Upload></ListMultipartUploadsResult>`
+	if err := decodeXMLResponse(xmlResponse(body), &ListMultipartUploadsResult{}); err == nil {
+		t.Error("got no error for an invalid time")
+	}
+}
+
+// errAfterReader returns its data, then err.
+type errAfterReader struct {
+	data string
+	err  error
+}
+
+func (r *errAfterReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestDecodeXMLResponseIncomplete(t *testing.T) {
+	tests := []struct {
+		name string
+		body io.Reader
+		// wantErr is the error the *IncompleteResponseError wraps, or nil if
+		// the error isn't one.
+		wantErr   error
+		wantBytes int64
+	}{
+		{name: "Empty", body: strings.NewReader(""), wantErr: io.EOF},
+		{name: "Declaration only", body: strings.NewReader(`<?xml version="1.0"?>` + "\n"), wantErr: io.EOF, wantBytes: 22},
+		{name: "Truncated", body: strings.NewReader(`<ListPartsResult><Part><PartNum`), wantErr: io.ErrUnexpectedEOF, wantBytes: 31},
+		{name: "Connection dropped", body: &errAfterReader{data: `<ListPartsResult>`, err: io.ErrUnexpectedEOF}, wantErr: io.ErrUnexpectedEOF, wantBytes: 17},
+		{name: "Malformed", body: strings.NewReader(`<ListPartsResult></Other>`)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: http.StatusOK, Status: "OK", Header: http.Header{}, Body: io.NopCloser(tc.body)}
+			err := decodeXMLResponse(resp, &ListObjectPartsResult{})
+			var incomplete *IncompleteResponseError
+			if !errors.As(err, &incomplete) {
+				if tc.wantErr != nil || err == nil {
+					t.Fatalf("got error %v, want an *IncompleteResponseError", err)
+				}
+				return
+			}
+			if tc.wantErr == nil {
+				t.Fatalf("got error %v, want another error", err)
+			}
+			if !errors.Is(err, tc.wantErr) || incomplete.BytesRead != tc.wantBytes || incomplete.StatusCode != http.StatusOK {
+				t.Errorf("got %v after %d bytes, want %v after %d", incomplete.Err, incomplete.BytesRead, tc.wantErr, tc.wantBytes)
+			}
+		})
+	}
+}
+
+func TestDecodeXMLResponseTooLarge(t *testing.T) {
+	// A listing that never ends.
+	body := io.MultiReader(
+		strings.NewReader("<ListPartsResult>"),
+		infiniteReader("<Part><PartNumber>1</PartNumber></Part>"),
+	)
+	resp := &http.Response{StatusCode: http.StatusOK, Status: "OK", Header: http.Header{}, Body: io.NopCloser(body)}
+	err := decodeXMLResponse(resp, &ListObjectPartsResult{})
+	if !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("got error %v, want %v", err, ErrResponseTooLarge)
+	}
+	if len(err.Error()) > 2*maxErrorBodyBytes {
+		t.Errorf("got error message of %d bytes, want it bounded", len(err.Error()))
+	}
+}
+
+func TestDecodeXMLResponseTooManyEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries int
+		v       func() any
+		root    string
+		entry   string
+		wantErr bool
+	}{
+		{
+			name:    "Uploads at the limit",
+			entries: maxListEntries,
+			v:       func() any { return &ListMultipartUploadsResult{} },
+			root:    "ListMultipartUploadsResult",
+			entry:   "<Upload><Key>a</Key></Upload>",
+		},
+		{
+			name:    "Too many uploads",
+			entries: maxListEntries + 1,
+			v:       func() any { return &ListMultipartUploadsResult{} },
+			root:    "ListMultipartUploadsResult",
+			entry:   "<Upload/>",
+			wantErr: true,
+		},
+		{
+			name:    "Parts at the limit",
+			entries: maxListEntries,
+			v:       func() any { return &ListObjectPartsResult{} },
+			root:    "ListPartsResult",
+			entry:   "<Part><PartNumber>1</PartNumber></Part>",
+		},
+		{
+			name:    "Too many parts",
+			entries: maxListEntries + 1,
+			v:       func() any { return &ListObjectPartsResult{} },
+			root:    "ListPartsResult",
+			entry:   "<Part/>",
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			body := `<` + tc.root + ` xmlns="http://s3.amazonaws.com/doc/2006-03-01/">` + strings.Repeat(tc.entry, tc.entries) + `</` + tc.root + `>`
+			err := decodeXMLResponse(xmlResponse(body), tc.v())
+			if gotErr := errors.Is(err, ErrResponseTooLarge); gotErr != tc.wantErr {
+				t.Errorf("got error %v, want too large %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckResponseBoundsBody(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusInternalServerError, Status: "500 Internal Server Error", Body: io.NopCloser(infiniteReader("x"))}
+	err := checkResponse(resp)
+	if len(err.Error()) != maxErrorBodyBytes {
+		t.Errorf("got error message of %d bytes, want %d", len(err.Error()), maxErrorBodyBytes)
+	}
+}
+
+// infiniteReader repeats s forever.
+func infiniteReader(s string) io.Reader {
+	return &repeatReader{s: s}
+}
+
+type repeatReader struct {
+	s   string
+	off int
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	for n := 0; ; {
+		c := copy(p[n:], r.s[r.off:])
+		n += c
+		r.off = (r.off + c) % len(r.s)
+		if n == len(p) {
+			return n, nil
+		}
+	}
+}
+
+func TestLimitedReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		limit   int64
+		wantErr error
+	}{
+		{name: "Under limit", input: "abc", limit: 4},
+		{name: "At limit", input: "abcd", limit: 4},
+		{name: "Over limit", input: "abcde", limit: 4, wantErr: ErrResponseTooLarge},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := io.ReadAll(&limitedReader{r: strings.NewReader(tc.input), n: tc.limit})
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tc.wantErr)
+			}
+			if tc.wantErr == nil && string(got) != tc.input {
+				t.Errorf("got %q, want %q", got, tc.input)
+			}
+		})
+	}
+}
+
+// fuzzSeeds are response bodies of every operation that returns XML.
+var fuzzSeeds = []string{
+	`<?xml version="1.0" encoding="UTF-8"?><InitiateMultipartUploadResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Bucket>b</Bucket><Key>k</Key><UploadId>u</UploadId></InitiateMultipartUploadResult>`,
+	`<CopyPartResult><LastModified>2024-01-01T00:00:00.000Z</LastModified><ETag>"e"</ETag></CopyPartResult>`,
+	`<CompleteMultipartUploadResult><Location>l</Location><Bucket>b</Bucket><Key>k</Key><ETag>"e-2"</ETag></CompleteMultipartUploadResult>`,
+	`<ListMultipartUploadsResult><Upload><UploadId>u</UploadId></Upload></ListMultipartUploadsResult>`,
+	`<ListPartsResult><Part><PartNumber>1</PartNumber><ETag>"e"</ETag></Part></ListPartsResult>`,
+	`<?xml version="1.0" encoding="ISO-8859-1"?><Error><Code>c</Code></Error>`,
+	`<a><b><c><d>`,
+}
+
+// FuzzDecodeXMLResponse checks that no response body makes any decoder
+// panic.
+func FuzzDecodeXMLResponse(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		for _, v := range []any{
+			&InitiateMultipartUploadResult{},
+			&CopyPartResult{},
+			&CompleteMultipartUploadResult{},
+			&ListMultipartUploadsResult{},
+			&ListObjectPartsResult{},
+		} {
+			resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(bytes.NewReader(body))}
+			_ = decodeXMLResponse(resp, v)
+		}
+	})
+}
+
+// FuzzParseHashHeader checks that no x-goog-hash header makes the parser
+// panic.
+func FuzzParseHashHeader(f *testing.F) {
+	f.Add("crc32c=n03x6A==,md5=XrY7u+Ae7tCTyyK7j1rNww==")
+	f.Add("crc32c=AAAA")
+	f.Add("md5=")
+	f.Fuzz(func(t *testing.T, value string) {
+		_, _ = gcshash.ParseHeader(http.Header{"X-Goog-Hash": []string{value}})
+	})
+}
+
+// FuzzCheckResponse checks that no error response makes checkResponse panic.
+func FuzzCheckResponse(f *testing.F) {
+	f.Add(http.StatusNotFound, []byte(`<Error><Code>NoSuchUpload</Code></Error>`))
+	f.Fuzz(func(t *testing.T, code int, body []byte) {
+		resp := &http.Response{StatusCode: code, Status: http.StatusText(code), Body: io.NopCloser(bytes.NewReader(body))}
+		_ = checkResponse(resp)
+	})
+}
@@ -0,0 +1,72 @@
+package multipartclient
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+)
+
+// encryptionKeySize is the size of an AES-256 key.
+const encryptionKeySize = 32
+
+// WithEncryptionKey encrypts uploaded objects with key, a customer-supplied
+// AES-256 key of 32 bytes. Cloud Storage requires the same key on every
+// request of a multipart upload, so the key, its algorithm and its SHA-256
+// are sent in the x-goog-encryption-* headers of every InitiateMultipartUpload,
+// UploadObjectPart, UploadPartCopy and CompleteMultipartUpload request. With
+// WithS3Compatibility, the key and its MD5 are sent in the
+// x-amz-server-side-encryption-customer-* headers instead. The key of a part
+// copy's source isn't sent, so the source must not be encrypted with a
+// customer-supplied key. Requests fail if key isn't 32 bytes. Keys are
+// redacted from logs.
+func WithEncryptionKey(key []byte) Option {
+	return func(mpuc *MultipartClient) {
+		mpuc.encryptionKey = newEncryptionKey(key)
+	}
+}
+
+// encryptionKey is a customer-supplied encryption key, encoded as sent.
+type encryptionKey struct {
+	key    string
+	sha256 string
+	md5    string
+	// err is why the key is invalid, or nil.
+	err error
+}
+
+func newEncryptionKey(key []byte) *encryptionKey {
+	if len(key) != encryptionKeySize {
+		return &encryptionKey{err: fmt.Errorf("encryption key must be %d bytes, not %d", encryptionKeySize, len(key))}
+	}
+	sha := sha256.Sum256(key)
+	sum := md5.Sum(key)
+	return &encryptionKey{
+		key:    base64.StdEncoding.EncodeToString(key),
+		sha256: base64.StdEncoding.EncodeToString(sha[:]),
+		md5:    base64.StdEncoding.EncodeToString(sum[:]),
+	}
+}
+
+// setEncryptionKey sets the headers of the key of WithEncryptionKey, if any,
+// on httpReq.
+func (mpuc *MultipartClient) setEncryptionKey(httpReq *http.Request) error {
+	k := mpuc.encryptionKey
+	if k == nil {
+		return nil
+	}
+	if k.err != nil {
+		return k.err
+	}
+	if mpuc.compat == nil {
+		httpReq.Header.Set("x-goog-encryption-algorithm", "AES256")
+		httpReq.Header.Set("x-goog-encryption-key", k.key)
+		httpReq.Header.Set("x-goog-encryption-key-sha256", k.sha256)
+	} else {
+		httpReq.Header.Set("x-amz-server-side-encryption-customer-algorithm", "AES256")
+		httpReq.Header.Set("x-amz-server-side-encryption-customer-key", k.key)
+		httpReq.Header.Set("x-amz-server-side-encryption-customer-key-MD5", k.md5)
+	}
+	return nil
+}
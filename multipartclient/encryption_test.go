@@ -0,0 +1,77 @@
+package multipartclient
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWithEncryptionKey(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	tests := []struct {
+		name   string
+		opts   []Option
+		header http.Header
+	}{
+		{
+			name: "Cloud Storage",
+			header: http.Header{
+				"X-Goog-Encryption-Algorithm":  {"AES256"},
+				"X-Goog-Encryption-Key":        {"AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="},
+				"X-Goog-Encryption-Key-Sha256": {"cs1uhCLEB/ttCYaQ8RMLfe1+wvf14dML2dUh8BU2N5M="},
+			},
+		},
+		{
+			name: "S3 compatibility",
+			opts: []Option{WithS3Compatibility(S3Compatibility{})},
+			header: http.Header{
+				"X-Amz-Server-Side-Encryption-Customer-Algorithm": {"AES256"},
+				"X-Amz-Server-Side-Encryption-Customer-Key":       {"AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="},
+				"X-Amz-Server-Side-Encryption-Customer-Key-Md5":   {"4Funlf7OsLF0HL+vKU+fkg=="},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []http.Header
+			hc := &http.Client{Transport: funcTransport(func(req *http.Request) (*http.Response, error) {
+				h := http.Header{}
+				for name := range tc.header {
+					if v, ok := req.Header[name]; ok {
+						h[name] = v
+					}
+				}
+				got = append(got, h)
+				return nil, errMock
+			})}
+			mpuc := New(hc, append([]Option{WithEncryptionKey(key)}, tc.opts...)...)
+			ctx := context.Background()
+			mpuc.InitiateMultipartUpload(ctx, &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt"})
+			mpuc.UploadObjectPart(ctx, &UploadObjectPartRequest{Bucket: "bucket1", Key: "object.txt", UploadID: "u1", PartNumber: 1, Body: toBody("hello")})
+			mpuc.UploadPartCopy(ctx, &UploadPartCopyRequest{Bucket: "bucket1", Key: "object.txt", UploadID: "u1", PartNumber: 2, SourceBucket: "bucket1", SourceKey: "source.txt"})
+			mpuc.CompleteMultipartUpload(ctx, &CompleteMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt", UploadID: "u1", Body: CompleteMultipartUploadBody{Parts: []CompletePart{{PartNumber: 1, ETag: `"e1"`}}}})
+			mpuc.AbortMultipartUpload(ctx, &AbortMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt", UploadID: "u1"})
+
+			want := []http.Header{tc.header, tc.header, tc.header, tc.header, {}}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("unexpected diff for headers of initiate, upload, copy, complete and abort (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWithEncryptionKeyInvalid(t *testing.T) {
+	hc := &http.Client{Transport: funcTransport(func(req *http.Request) (*http.Response, error) {
+		t.Errorf("got request %s %s, want none", req.Method, req.URL)
+		return nil, errMock
+	})}
+	mpuc := New(hc, WithEncryptionKey([]byte("short")))
+	_, err := mpuc.InitiateMultipartUpload(context.Background(), &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt"})
+	if err == nil || !strings.Contains(err.Error(), "must be 32 bytes, not 5") {
+		t.Errorf("got error %v, want one for the key size", err)
+	}
+}
@@ -0,0 +1,64 @@
+package multipartclient
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+)
+
+// Error is an error response from the XML API. Its fields are those of the
+// XML error document in the response body, so callers can branch on Code:
+//
+//	var apiErr *multipartclient.Error
+//	if errors.As(err, &apiErr) && apiErr.Code == "NoSuchUpload" {
+//		// The upload was completed or aborted.
+//	}
+//
+// A response whose body isn't an XML error document, as some proxies send,
+// has an empty Code and the body, or the status if there is no body, as
+// Message.
+type Error struct {
+	// StatusCode is the HTTP status of the response, such as 404.
+	StatusCode int
+	// Code is the error code, such as "NoSuchUpload", "EntityTooSmall",
+	// "InvalidPart" or "AccessDenied".
+	Code string
+	// Message is the description of the error.
+	Message string
+	// Details is more information about the error, if the server gave any.
+	Details string
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return e.Message
+	}
+	msg := fmt.Sprintf("%d %s: %s", e.StatusCode, e.Code, e.Message)
+	if e.Details != "" {
+		msg += " (" + e.Details + ")"
+	}
+	return msg
+}
+
+// errorDocument is the XML error document of an error response.
+type errorDocument struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+	Details string   `xml:"Details"`
+}
+
+// newError returns the *Error of the error response resp, whose body, read up
+// to maxErrorBodyBytes, is body.
+func newError(resp *http.Response, body []byte) *Error {
+	var doc errorDocument
+	if err := xml.NewDecoder(bytes.NewReader(body)).Decode(&doc); err == nil && doc.Code != "" {
+		return &Error{StatusCode: resp.StatusCode, Code: doc.Code, Message: doc.Message, Details: doc.Details}
+	}
+	msg := string(body)
+	if msg == "" {
+		msg = resp.Status
+	}
+	return &Error{StatusCode: resp.StatusCode, Message: msg}
+}
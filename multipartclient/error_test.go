@@ -0,0 +1,77 @@
+package multipartclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multipartclienttest"
+)
+
+func TestError(t *testing.T) {
+	tests := []struct {
+		name    string
+		resp    func() *http.Response
+		want    *Error
+		wantMsg string
+	}{
+		{
+			name: "XML error",
+			resp: func() *http.Response {
+				return multipartclienttest.ErrorResponse(http.StatusNotFound, "NoSuchUpload", "The requested upload was not found.")
+			},
+			want:    &Error{StatusCode: http.StatusNotFound, Code: "NoSuchUpload", Message: "The requested upload was not found."},
+			wantMsg: "404 NoSuchUpload: The requested upload was not found.",
+		},
+		{
+			name: "Details",
+			resp: func() *http.Response {
+				return multipartclienttest.XMLResponse(http.StatusForbidden, `<?xml version="1.0" encoding="UTF-8"?>
+<Error><Code>AccessDenied</Code><Message>Access denied.</Message><Details>sa@example.com does not have storage.objects.create access.</Details></Error>`)
+			},
+			want: &Error{
+				StatusCode: http.StatusForbidden,
+				Code:       "AccessDenied",
+				Message:    "Access denied.",
+				Details:    "sa@example.com does not have storage.objects.create access.",
+			},
+			wantMsg: "403 AccessDenied: Access denied. (sa@example.com does not have storage.objects.create access.)",
+		},
+		{
+			name: "Not XML",
+			resp: func() *http.Response {
+				return &http.Response{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway", Body: toBody("upstream connect error")}
+			},
+			want:    &Error{StatusCode: http.StatusBadGateway, Message: "upstream connect error"},
+			wantMsg: "upstream connect error",
+		},
+		{
+			name: "No body",
+			resp: func() *http.Response {
+				return &http.Response{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable", Body: http.NoBody}
+			},
+			want:    &Error{StatusCode: http.StatusServiceUnavailable, Message: "503 Service Unavailable"},
+			wantMsg: "503 Service Unavailable",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mpuc := New(&http.Client{Transport: funcTransport(func(*http.Request) (*http.Response, error) {
+				return tc.resp(), nil
+			})})
+			err := mpuc.AbortMultipartUpload(context.Background(), &AbortMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt", UploadID: "u1"})
+			var got *Error
+			if !errors.As(err, &got) {
+				t.Fatalf("got error %v, want an *Error", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected diff for error (-want, +got):\n%s", diff)
+			}
+			if got.Error() != tc.wantMsg {
+				t.Errorf("got message %q, want %q", got.Error(), tc.wantMsg)
+			}
+		})
+	}
+}
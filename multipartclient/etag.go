@@ -0,0 +1,77 @@
+package multipartclient
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ETagMismatchError is returned when the ETag reported for a completed upload
+// differs from the one computed from its parts.
+type ETagMismatchError struct {
+	Want string
+	Got  string
+}
+
+func (e *ETagMismatchError) Error() string {
+	return fmt.Sprintf("multipart ETag mismatch: computed %s, server reported %s", e.Want, e.Got)
+}
+
+// MultipartETag returns the ETag S3-compatible servers assign to an object
+// assembled from parts with the given MD5 digests, in part order: the hex MD5
+// of the concatenated binary digests followed by "-" and the number of parts.
+//
+// Cloud Storage does not use this scheme for multipart objects, so this is
+// only meaningful when targeting S3-compatible endpoints.
+func MultipartETag(partMD5s [][]byte) string {
+	h := md5.New()
+	for _, sum := range partMD5s {
+		h.Write(sum)
+	}
+	return formatMultipartETag(h.Sum(nil), len(partMD5s))
+}
+
+// formatMultipartETag returns the ETag of an object of n parts whose
+// concatenated digests have the MD5 sum.
+func formatMultipartETag(sum []byte, n int) string {
+	return hex.EncodeToString(sum) + "-" + strconv.Itoa(n)
+}
+
+// MultipartETagFromParts computes MultipartETag from the part ETags returned
+// by UploadObjectPart, which S3-compatible servers set to the hex MD5 of each
+// part.
+func MultipartETagFromParts(parts []CompletePart) (string, error) {
+	h := md5.New()
+	var sum [md5.Size]byte
+	for _, part := range parts {
+		// hex.Decode accepts either case, so the ETag isn't lowercased.
+		etag := strings.Trim(part.ETag, `"`)
+		if len(etag) != hex.EncodedLen(md5.Size) {
+			return "", fmt.Errorf("part %d: ETag %q is not an MD5 digest", part.PartNumber, part.ETag)
+		}
+		if _, err := hex.Decode(sum[:], []byte(etag)); err != nil {
+			return "", fmt.Errorf("part %d: ETag %q is not an MD5 digest", part.PartNumber, part.ETag)
+		}
+		h.Write(sum[:])
+	}
+	return formatMultipartETag(h.Sum(nil), len(parts)), nil
+}
+
+// VerifyMultipartETag checks the ETag of a completed upload against the one
+// computed from the MD5 digests of its parts, returning an *ETagMismatchError
+// if they differ.
+func VerifyMultipartETag(result *CompleteMultipartUploadResult, partMD5s [][]byte) error {
+	want := MultipartETag(partMD5s)
+	if got := normalizeETag(result.ETag); got != want {
+		return &ETagMismatchError{Want: want, Got: result.ETag}
+	}
+	return nil
+}
+
+// normalizeETag strips the quotes servers put around ETags and lowercases the
+// hex digits.
+func normalizeETag(etag string) string {
+	return strings.ToLower(strings.Trim(etag, `"`))
+}
@@ -0,0 +1,70 @@
+package multipartclient
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func md5Of(s string) []byte {
+	sum := md5.Sum([]byte(s))
+	return sum[:]
+}
+
+func TestMultipartETag(t *testing.T) {
+	partMD5s := [][]byte{md5Of("part one"), md5Of("part two")}
+	combined := md5.Sum(append(append([]byte{}, partMD5s[0]...), partMD5s[1]...))
+	want := hex.EncodeToString(combined[:]) + "-2"
+
+	if got := MultipartETag(partMD5s); got != want {
+		t.Errorf("MultipartETag() = %q, want %q", got, want)
+	}
+
+	got, err := MultipartETagFromParts([]CompletePart{
+		{PartNumber: 1, ETag: `"` + hex.EncodeToString(partMD5s[0]) + `"`},
+		{PartNumber: 2, ETag: strings.ToUpper(hex.EncodeToString(partMD5s[1]))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("MultipartETagFromParts() = %q, want %q", got, want)
+	}
+
+	if _, err := MultipartETagFromParts([]CompletePart{{PartNumber: 1, ETag: "not-md5"}}); err == nil {
+		t.Error("MultipartETagFromParts() with a non-MD5 ETag: expected error")
+	}
+}
+
+func TestVerifyMultipartETag(t *testing.T) {
+	partMD5s := [][]byte{md5Of("part one"), md5Of("part two")}
+	etag := MultipartETag(partMD5s)
+
+	tests := []struct {
+		name         string
+		resultETag   string
+		wantMismatch bool
+	}{
+		{
+			name:       "Quoted upper case match",
+			resultETag: `"` + strings.ToUpper(etag) + `"`,
+		},
+		{
+			name:         "Different part count",
+			resultETag:   etag[:len(etag)-1] + "3",
+			wantMismatch: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := VerifyMultipartETag(&CompleteMultipartUploadResult{ETag: tc.resultETag}, partMD5s)
+			var mismatch *ETagMismatchError
+			if got := errors.As(err, &mismatch); got != tc.wantMismatch {
+				t.Errorf("VerifyMultipartETag() = %v, want mismatch: %v", err, tc.wantMismatch)
+			}
+		})
+	}
+}
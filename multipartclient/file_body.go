@@ -0,0 +1,120 @@
+package multipartclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+)
+
+// FileBody is a part body of n bytes at an offset of a file, opened for the
+// part alone. Unlike a SectionBody, it exposes the file's descriptor to the
+// HTTP transport, which then copies the part to the connection with
+// sendfile(2) instead of through userspace buffers.
+//
+// The kernel copy is only possible over plain HTTP/1.1 connections, such as
+// to an S3-compatible server or an emulator on the local network, and when
+// the client doesn't read the body itself: with VerifyChecksums the part is
+// hashed as it is sent, and over TLS or HTTP/2 it is encrypted or framed in
+// userspace. In those cases it is read like any other body.
+type FileBody struct {
+	f *os.File
+	// off and n are the offset and length of the part in f. The offset of f
+	// is the position in the part, since sendfile reads from and advances it.
+	off, n int64
+}
+
+// OpenFileBody opens the file name and returns a body of its n bytes at off.
+// The part must lie within the file. Closing the body closes the file.
+func OpenFileBody(name string, off, n int64) (*FileBody, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if off < 0 || n < 0 || off+n > info.Size() {
+		f.Close()
+		return nil, fmt.Errorf("part of %d bytes at offset %d is outside %s of %d bytes", n, off, name, info.Size())
+	}
+	if _, err := f.Seek(off, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &FileBody{f: f, off: off, n: n}, nil
+}
+
+func (b *FileBody) Read(p []byte) (int, error) {
+	remaining, err := b.remaining()
+	if err != nil {
+		return 0, err
+	}
+	if remaining == 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	return b.f.Read(p)
+}
+
+// Seek sets the position in the part.
+func (b *FileBody) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		offset += b.off
+	case io.SeekCurrent:
+		pos, err := b.f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, err
+		}
+		offset += pos
+	case io.SeekEnd:
+		offset += b.off + b.n
+	default:
+		return 0, errors.New("FileBody.Seek: invalid whence")
+	}
+	if offset < b.off {
+		return 0, errors.New("FileBody.Seek: negative position")
+	}
+	pos, err := b.f.Seek(offset, io.SeekStart)
+	return pos - b.off, err
+}
+
+// Size returns the length of the part.
+func (b *FileBody) Size() int64 {
+	return b.n
+}
+
+func (b *FileBody) Close() error {
+	return b.f.Close()
+}
+
+// SyscallConn returns the raw file, which lets the network stack send the
+// part with sendfile(2).
+func (b *FileBody) SyscallConn() (syscall.RawConn, error) {
+	return b.f.SyscallConn()
+}
+
+// remaining returns the number of bytes left to read from b.
+func (b *FileBody) remaining() (int64, error) {
+	pos, err := b.f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	return max(b.off+b.n-pos, 0), nil
+}
+
+// unclosedFileBody is a FileBody the HTTP transport can't close, so that the
+// bytes it sent can still be counted from the offset of the file.
+type unclosedFileBody struct {
+	*FileBody
+}
+
+func (unclosedFileBody) Close() error {
+	return nil
+}
@@ -0,0 +1,180 @@
+// Package gcshash computes and encodes the checksums used by Cloud Storage:
+// CRC32C (Castagnoli) and MD5, both transmitted as base64 in the x-goog-hash
+// header. Additional algorithms can be plugged in through a Registry.
+package gcshash
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"net/http"
+	"strings"
+)
+
+// HeaderName is the header Cloud Storage uses to send and report checksums.
+const HeaderName = "x-goog-hash"
+
+// headerKey is HeaderName in canonical form, which http.Header would
+// otherwise allocate on every access.
+var headerKey = http.CanonicalHeaderKey(HeaderName)
+
+// castagnoliTable must come from crc32.MakeTable: hash/crc32 only uses its
+// hardware implementations (SSE4.2 on amd64, the CRC32 instructions on arm64)
+// for the table MakeTable returns.
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// CRC32C returns the CRC32C checksum of b.
+func CRC32C(b []byte) uint32 {
+	return crc32.Checksum(b, castagnoliTable)
+}
+
+// NewCRC32C returns a hash computing the CRC32C checksum.
+func NewCRC32C() hash.Hash32 {
+	return crc32.New(castagnoliTable)
+}
+
+// EncodeCRC32C encodes a CRC32C checksum the way Cloud Storage expects: the
+// base64 encoding of its four big-endian bytes.
+func EncodeCRC32C(sum uint32) string {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], sum)
+	return base64.StdEncoding.EncodeToString(b[:])
+}
+
+// DecodeCRC32C decodes a checksum encoded by EncodeCRC32C.
+func DecodeCRC32C(s string) (uint32, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid crc32c %q: %w", s, err)
+	}
+	if len(b) != 4 {
+		return 0, fmt.Errorf("invalid crc32c %q: got %d bytes, want 4", s, len(b))
+	}
+	return binary.BigEndian.Uint32(b), nil
+}
+
+// EncodeMD5 encodes an MD5 digest as base64.
+func EncodeMD5(sum []byte) string {
+	return base64.StdEncoding.EncodeToString(sum)
+}
+
+// DecodeMD5 decodes a digest encoded by EncodeMD5.
+func DecodeMD5(s string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid md5 %q: %w", s, err)
+	}
+	if len(b) != md5.Size {
+		return nil, fmt.Errorf("invalid md5 %q: got %d bytes, want %d", s, len(b), md5.Size)
+	}
+	return b, nil
+}
+
+// Sums holds the checksums of an object or part.
+type Sums struct {
+	CRC32C uint32
+	// HasCRC32C reports whether CRC32C is set, since zero is a valid checksum.
+	HasCRC32C bool
+	// MD5 is nil if not set.
+	MD5 []byte
+}
+
+// SetHeader adds the set checksums in s to h as x-goog-hash values.
+func (s Sums) SetHeader(h http.Header) {
+	if s.HasCRC32C {
+		h.Add(headerKey, "crc32c="+EncodeCRC32C(s.CRC32C))
+	}
+	if s.MD5 != nil {
+		h.Add(headerKey, "md5="+EncodeMD5(s.MD5))
+	}
+}
+
+// ParseHeader returns the checksums reported in the x-goog-hash values of h.
+// Cloud Storage sends each checksum either as its own header line or joined
+// with commas on a single line; both forms are accepted. Unknown algorithms
+// are ignored.
+func ParseHeader(h http.Header) (Sums, error) {
+	var sums Sums
+	for _, value := range h.Values(headerKey) {
+		for _, entry := range strings.Split(value, ",") {
+			name, encoded, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			if !ok {
+				return Sums{}, fmt.Errorf("malformed %s entry %q", HeaderName, entry)
+			}
+			switch strings.ToLower(name) {
+			case "crc32c":
+				sum, err := DecodeCRC32C(encoded)
+				if err != nil {
+					return Sums{}, err
+				}
+				sums.CRC32C, sums.HasCRC32C = sum, true
+			case "md5":
+				sum, err := DecodeMD5(encoded)
+				if err != nil {
+					return Sums{}, err
+				}
+				sums.MD5 = sum
+			}
+		}
+	}
+	return sums, nil
+}
+
+// Hasher computes checksums of everything written to it in a single pass.
+// Wrap a reader with io.TeeReader to hash data while it is being consumed.
+type Hasher struct {
+	names  []string
+	hashes []hash.Hash
+	n      int64
+}
+
+// NewHasher returns a Hasher computing CRC32C and MD5.
+func NewHasher() *Hasher {
+	return &Hasher{
+		names:  []string{CRC32CName, MD5Name},
+		hashes: []hash.Hash{NewCRC32C(), md5.New()},
+	}
+}
+
+// Write adds p to the running checksums. It never returns an error.
+func (h *Hasher) Write(p []byte) (int, error) {
+	for _, hh := range h.hashes {
+		hh.Write(p)
+	}
+	h.n += int64(len(p))
+	return len(p), nil
+}
+
+// Size returns the number of bytes written so far.
+func (h *Hasher) Size() int64 {
+	return h.n
+}
+
+// Sums returns the CRC32C and MD5 checksums of the data written so far, for
+// whichever of the two the Hasher computes.
+func (h *Hasher) Sums() Sums {
+	var sums Sums
+	for i, name := range h.names {
+		switch name {
+		case CRC32CName:
+			sums.CRC32C = binary.BigEndian.Uint32(h.hashes[i].Sum(nil))
+			sums.HasCRC32C = true
+		case MD5Name:
+			sums.MD5 = h.hashes[i].Sum(nil)
+		}
+	}
+	return sums
+}
+
+// Digests returns the digest of the data written so far for every algorithm
+// the Hasher computes, keyed by algorithm name.
+func (h *Hasher) Digests() map[string][]byte {
+	digests := make(map[string][]byte, len(h.names))
+	for i, name := range h.names {
+		digests[name] = h.hashes[i].Sum(nil)
+	}
+	return digests
+}
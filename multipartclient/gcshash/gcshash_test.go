@@ -0,0 +1,172 @@
+package gcshash
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var (
+	// Checksums of "hello world".
+	helloCRC32C  = uint32(0xc99465aa)
+	helloMD5     = []byte{0x5e, 0xb6, 0x3b, 0xbb, 0xe0, 0x1e, 0xee, 0xd0, 0x93, 0xcb, 0x22, 0xbb, 0x8f, 0x5a, 0xcd, 0xc3}
+	helloEncCRC  = "yZRlqg=="
+	helloEncMD5  = "XrY7u+Ae7tCTyyK7j1rNww=="
+	helloWorldIn = "hello world"
+)
+
+func TestCRC32CEncoding(t *testing.T) {
+	if got := CRC32C([]byte(helloWorldIn)); got != helloCRC32C {
+		t.Errorf("CRC32C() = %#x, want %#x", got, helloCRC32C)
+	}
+	if got := EncodeCRC32C(helloCRC32C); got != helloEncCRC {
+		t.Errorf("EncodeCRC32C() = %q, want %q", got, helloEncCRC)
+	}
+	got, err := DecodeCRC32C(helloEncCRC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != helloCRC32C {
+		t.Errorf("DecodeCRC32C() = %#x, want %#x", got, helloCRC32C)
+	}
+	if _, err := DecodeCRC32C(helloEncMD5); err == nil {
+		t.Error("DecodeCRC32C() of a 16 byte value: expected error")
+	}
+}
+
+func TestMD5Encoding(t *testing.T) {
+	if got := EncodeMD5(helloMD5); got != helloEncMD5 {
+		t.Errorf("EncodeMD5() = %q, want %q", got, helloEncMD5)
+	}
+	got, err := DecodeMD5(helloEncMD5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(helloMD5, got); diff != "" {
+		t.Errorf("unexpected diff for DecodeMD5: (-want, +got):\n%s", diff)
+	}
+	if _, err := DecodeMD5(helloEncCRC); err == nil {
+		t.Error("DecodeMD5() of a 4 byte value: expected error")
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	want := Sums{CRC32C: helloCRC32C, HasCRC32C: true, MD5: helloMD5}
+	tests := []struct {
+		name    string
+		header  http.Header
+		want    Sums
+		wantErr bool
+	}{
+		{
+			name: "Separate header lines",
+			header: http.Header{
+				"X-Goog-Hash": []string{"crc32c=" + helloEncCRC, "md5=" + helloEncMD5},
+			},
+			want: want,
+		},
+		{
+			name: "Comma joined",
+			header: http.Header{
+				"X-Goog-Hash": []string{"crc32c=" + helloEncCRC + ", md5=" + helloEncMD5},
+			},
+			want: want,
+		},
+		{
+			name:   "Missing",
+			header: http.Header{},
+			want:   Sums{},
+		},
+		{
+			name: "Malformed",
+			header: http.Header{
+				"X-Goog-Hash": []string{"crc32c"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseHeader(tc.header)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ParseHeader() error = %v, want error: %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected diff for sums: (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSetHeaderRoundTrip(t *testing.T) {
+	want := Sums{CRC32C: helloCRC32C, HasCRC32C: true, MD5: helloMD5}
+	h := http.Header{}
+	want.SetHeader(h)
+	got, err := ParseHeader(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected diff for sums: (-want, +got):\n%s", diff)
+	}
+}
+
+func TestHasher(t *testing.T) {
+	h := NewHasher()
+	if _, err := io.Copy(io.Discard, io.TeeReader(strings.NewReader(helloWorldIn), h)); err != nil {
+		t.Fatal(err)
+	}
+	want := Sums{CRC32C: helloCRC32C, HasCRC32C: true, MD5: helloMD5}
+	if diff := cmp.Diff(want, h.Sums()); diff != "" {
+		t.Errorf("unexpected diff for sums: (-want, +got):\n%s", diff)
+	}
+	if got := h.Size(); got != int64(len(helloWorldIn)) {
+		t.Errorf("Size() = %d, want %d", got, len(helloWorldIn))
+	}
+}
+
+var benchSizes = []int{4 << 10, 32 << 10, 128 << 10, 1 << 20}
+
+func BenchmarkCRC32C(b *testing.B) {
+	for _, size := range benchSizes {
+		b.Run(fmt.Sprintf("block=%dKiB", size>>10), func(b *testing.B) {
+			data := make([]byte, size)
+			h := NewCRC32C()
+			b.SetBytes(int64(size))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				h.Write(data)
+			}
+		})
+	}
+}
+
+func BenchmarkMD5(b *testing.B) {
+	data := make([]byte, 128<<10)
+	h := md5.New()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Write(data)
+	}
+}
+
+func BenchmarkHasher(b *testing.B) {
+	for _, size := range benchSizes {
+		b.Run(fmt.Sprintf("block=%dKiB", size>>10), func(b *testing.B) {
+			data := make([]byte, size)
+			h := NewHasher()
+			b.SetBytes(int64(size))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				h.Write(data)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package gcshash
+
+import (
+	"crypto/md5"
+	"fmt"
+	"hash"
+	"sort"
+	"sync"
+)
+
+// Names of the algorithms Cloud Storage understands, as used in x-goog-hash.
+const (
+	CRC32CName = "crc32c"
+	MD5Name    = "md5"
+)
+
+// Algorithm is a checksum algorithm that can be registered in a Registry.
+type Algorithm struct {
+	// Name identifies the algorithm. Digests of algorithms other than CRC32C
+	// and MD5 are not understood by Cloud Storage; callers may record them,
+	// e.g. in object metadata.
+	Name string
+	New  func() hash.Hash
+}
+
+// Registry holds the checksum algorithms available to a Hasher. Algorithms
+// can be disabled, e.g. MD5 in FIPS environments. A Registry is safe for
+// concurrent use.
+type Registry struct {
+	mu         sync.RWMutex
+	algorithms map[string]Algorithm
+	disabled   map[string]bool
+}
+
+// NewRegistry returns a Registry containing CRC32C and MD5, both enabled.
+func NewRegistry() *Registry {
+	return &Registry{
+		algorithms: map[string]Algorithm{
+			CRC32CName: {Name: CRC32CName, New: func() hash.Hash { return NewCRC32C() }},
+			MD5Name:    {Name: MD5Name, New: md5.New},
+		},
+		disabled: map[string]bool{},
+	}
+}
+
+// Register adds an enabled algorithm. It is an error to register a name twice.
+func (r *Registry) Register(alg Algorithm) error {
+	if alg.Name == "" || alg.New == nil {
+		return fmt.Errorf("algorithm must have a name and a constructor")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.algorithms[alg.Name]; ok {
+		return fmt.Errorf("algorithm %q is already registered", alg.Name)
+	}
+	r.algorithms[alg.Name] = alg
+	return nil
+}
+
+// Disable stops the named algorithm from being used by new Hashers.
+func (r *Registry) Disable(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.disabled[name] = true
+}
+
+// Enable re-enables an algorithm disabled with Disable.
+func (r *Registry) Enable(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.disabled, name)
+}
+
+// Names returns the names of the enabled algorithms in sorted order.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var names []string
+	for name := range r.algorithms {
+		if !r.disabled[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewHasher returns a Hasher computing the named algorithms, or every enabled
+// algorithm if no names are given. Naming an unknown or disabled algorithm is
+// an error.
+func (r *Registry) NewHasher(names ...string) (*Hasher, error) {
+	if len(names) == 0 {
+		names = r.Names()
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	h := &Hasher{}
+	for _, name := range names {
+		alg, ok := r.algorithms[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown hash algorithm %q", name)
+		}
+		if r.disabled[name] {
+			return nil, fmt.Errorf("hash algorithm %q is disabled", name)
+		}
+		h.names = append(h.names, name)
+		h.hashes = append(h.hashes, alg.New())
+	}
+	return h, nil
+}
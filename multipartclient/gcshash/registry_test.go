@@ -0,0 +1,50 @@
+package gcshash
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Register(Algorithm{Name: "sha256", New: sha256.New}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register(Algorithm{Name: "sha256", New: sha256.New}); err == nil {
+		t.Error("registering a duplicate name: expected error")
+	}
+	if diff := cmp.Diff([]string{"crc32c", "md5", "sha256"}, r.Names()); diff != "" {
+		t.Errorf("unexpected diff for names: (-want, +got):\n%s", diff)
+	}
+
+	r.Disable(MD5Name)
+	if diff := cmp.Diff([]string{"crc32c", "sha256"}, r.Names()); diff != "" {
+		t.Errorf("unexpected diff for names after disabling md5: (-want, +got):\n%s", diff)
+	}
+	if _, err := r.NewHasher(MD5Name); err == nil {
+		t.Error("NewHasher() with a disabled algorithm: expected error")
+	}
+	if _, err := r.NewHasher("unknown"); err == nil {
+		t.Error("NewHasher() with an unknown algorithm: expected error")
+	}
+
+	h, err := r.NewHasher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Write([]byte(helloWorldIn))
+	if diff := cmp.Diff(Sums{CRC32C: helloCRC32C, HasCRC32C: true}, h.Sums()); diff != "" {
+		t.Errorf("unexpected diff for sums: (-want, +got):\n%s", diff)
+	}
+	wantSHA := sha256.Sum256([]byte(helloWorldIn))
+	if diff := cmp.Diff(wantSHA[:], h.Digests()["sha256"]); diff != "" {
+		t.Errorf("unexpected diff for sha256 digest: (-want, +got):\n%s", diff)
+	}
+
+	r.Enable(MD5Name)
+	if _, err := r.NewHasher(MD5Name); err != nil {
+		t.Errorf("NewHasher() after re-enabling md5: %v", err)
+	}
+}
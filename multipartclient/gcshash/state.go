@@ -0,0 +1,120 @@
+package gcshash
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// hasherStateVersion is the first byte of a serialized Hasher. Bump it when
+// the format changes.
+const hasherStateVersion = 1
+
+var errTruncatedState = errors.New("truncated hasher state")
+
+// MarshalBinary serializes the in-progress state of every hash, so hashing
+// can resume in another process without re-reading the data written so far.
+// Every algorithm's hash must implement encoding.BinaryMarshaler, as the
+// standard library hashes do.
+func (h *Hasher) MarshalBinary() ([]byte, error) {
+	b := []byte{hasherStateVersion}
+	b = binary.BigEndian.AppendUint64(b, uint64(h.n))
+	b = binary.AppendUvarint(b, uint64(len(h.names)))
+	for i, name := range h.names {
+		m, ok := h.hashes[i].(encoding.BinaryMarshaler)
+		if !ok {
+			return nil, fmt.Errorf("hash algorithm %q does not support serialization", name)
+		}
+		state, err := m.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize %q state: %w", name, err)
+		}
+		b = appendBytes(b, []byte(name))
+		b = appendBytes(b, state)
+	}
+	return b, nil
+}
+
+// UnmarshalHasher restores a Hasher serialized with MarshalBinary. Its
+// algorithms must be registered and enabled in r.
+func (r *Registry) UnmarshalHasher(data []byte) (*Hasher, error) {
+	if len(data) < 9 {
+		return nil, errTruncatedState
+	}
+	if data[0] != hasherStateVersion {
+		return nil, fmt.Errorf("unsupported hasher state version %d", data[0])
+	}
+	n := int64(binary.BigEndian.Uint64(data[1:9]))
+	if n < 0 {
+		return nil, fmt.Errorf("invalid hasher state size %d", n)
+	}
+	data = data[9:]
+	count, read := binary.Uvarint(data)
+	if read <= 0 {
+		return nil, errTruncatedState
+	}
+	data = data[read:]
+	// Every algorithm takes at least the two bytes of the lengths of its name
+	// and state, which bounds the count of well-formed data.
+	if count == 0 {
+		return nil, errors.New("hasher state has no algorithms")
+	}
+	if count > uint64(len(data)/2) {
+		return nil, errTruncatedState
+	}
+
+	names := make([]string, 0, count)
+	states := make([][]byte, 0, count)
+	for i := uint64(0); i < count; i++ {
+		var name, state []byte
+		var err error
+		if name, data, err = readBytes(data); err != nil {
+			return nil, err
+		}
+		if state, data, err = readBytes(data); err != nil {
+			return nil, err
+		}
+		names = append(names, string(name))
+		states = append(states, state)
+	}
+	if len(data) != 0 {
+		return nil, errors.New("trailing data after hasher state")
+	}
+
+	h, err := r.NewHasher(names...)
+	if err != nil {
+		return nil, err
+	}
+	for i, hh := range h.hashes {
+		u, ok := hh.(encoding.BinaryUnmarshaler)
+		if !ok {
+			return nil, fmt.Errorf("hash algorithm %q does not support serialization", names[i])
+		}
+		if err := u.UnmarshalBinary(states[i]); err != nil {
+			return nil, fmt.Errorf("failed to restore %q state: %w", names[i], err)
+		}
+	}
+	h.n = n
+	return h, nil
+}
+
+// UnmarshalHasher restores a Hasher serialized with MarshalBinary that uses
+// only CRC32C and MD5.
+func UnmarshalHasher(data []byte) (*Hasher, error) {
+	return NewRegistry().UnmarshalHasher(data)
+}
+
+func appendBytes(b, v []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func readBytes(data []byte) (v, rest []byte, err error) {
+	length, read := binary.Uvarint(data)
+	if read <= 0 || uint64(len(data)-read) < length {
+		return nil, nil, errTruncatedState
+	}
+	data = data[read:]
+	return data[:length], data[length:], nil
+}
@@ -0,0 +1,95 @@
+package gcshash
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHasherStateRoundTrip(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Register(Algorithm{Name: "sha256", New: sha256.New}); err != nil {
+		t.Fatal(err)
+	}
+	want, err := r.NewHasher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.Write([]byte(helloWorldIn))
+
+	partial, err := r.NewHasher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	partial.Write([]byte(helloWorldIn[:5]))
+	state, err := partial.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := r.UnmarshalHasher(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored.Write([]byte(helloWorldIn[5:]))
+	if diff := cmp.Diff(want.Digests(), restored.Digests()); diff != "" {
+		t.Errorf("unexpected diff for digests: (-want, +got):\n%s", diff)
+	}
+	if got := restored.Size(); got != int64(len(helloWorldIn)) {
+		t.Errorf("Size() = %d, want %d", got, len(helloWorldIn))
+	}
+
+	// The default registry doesn't know sha256.
+	if _, err := UnmarshalHasher(state); err == nil {
+		t.Error("UnmarshalHasher() with an unregistered algorithm: expected error")
+	}
+}
+
+func TestUnmarshalHasherInvalid(t *testing.T) {
+	state, err := NewHasher().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "Empty", data: nil},
+		{name: "Truncated", data: state[:len(state)-1]},
+		{name: "Trailing data", data: append(append([]byte{}, state...), 0)},
+		{name: "Unknown version", data: append([]byte{99}, state[1:]...)},
+		{name: "Huge count", data: []byte{hasherStateVersion, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{name: "No algorithms", data: []byte{hasherStateVersion, 0, 0, 0, 0, 0, 0, 0, 0, 0}},
+		{name: "Count beyond data", data: []byte{hasherStateVersion, 0, 0, 0, 0, 0, 0, 0, 0, 3, 0, 0}},
+		{name: "Negative size", data: append([]byte{hasherStateVersion, 0xff}, state[2:]...)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := UnmarshalHasher(tc.data); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+// FuzzUnmarshalHasher checks that no serialized state makes UnmarshalHasher
+// panic, and that the states it accepts serialize back to themselves.
+func FuzzUnmarshalHasher(f *testing.F) {
+	state, err := NewHasher().MarshalBinary()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(state)
+	f.Add(state[:len(state)/2])
+	f.Add([]byte{hasherStateVersion, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		h, err := UnmarshalHasher(data)
+		if err != nil {
+			return
+		}
+		if _, err := h.MarshalBinary(); err != nil {
+			t.Errorf("MarshalBinary() of a restored Hasher: %v", err)
+		}
+	})
+}
@@ -0,0 +1,173 @@
+package multipartclient
+
+import (
+	"context"
+	"io"
+)
+
+// BucketHandle refers to a bucket, in the style of the handles of
+// cloud.google.com/go/storage:
+//
+//	upload, err := mpuc.Bucket("bucket1").Object("dir/object.txt").NewMultipartUpload(ctx)
+//	...
+//	part, err := upload.UploadPart(ctx, 1, body)
+//	...
+//	result, err := upload.Complete(ctx, []CompletePart{{PartNumber: 1, ETag: part.ETag}})
+//
+// Handles hold no state beyond their names and only call the operations of
+// the client, so they are cheap to create and safe for concurrent use. Use the
+// request types with the client's operations for control the handles don't
+// offer.
+type BucketHandle struct {
+	mpuc *MultipartClient
+	name string
+}
+
+// Bucket returns a handle for the bucket named name. It doesn't check that
+// the bucket exists.
+func (mpuc *MultipartClient) Bucket(name string) *BucketHandle {
+	return &BucketHandle{mpuc: mpuc, name: name}
+}
+
+// Name returns the name of the bucket.
+func (b *BucketHandle) Name() string {
+	return b.name
+}
+
+// Uploads returns an iterator over the multipart uploads in progress of
+// objects whose names begin with prefix.
+func (b *BucketHandle) Uploads(ctx context.Context, prefix string) *UploadIterator {
+	return b.mpuc.Uploads(ctx, &ListMultipartUploadsRequest{Bucket: b.name, Prefix: prefix})
+}
+
+// Object returns a handle for the object named key in the bucket.
+func (b *BucketHandle) Object(key string) *ObjectHandle {
+	return &ObjectHandle{mpuc: b.mpuc, ref: ObjectRef{Bucket: b.name, Key: key}}
+}
+
+// ObjectHandle refers to an object, which need not exist yet.
+type ObjectHandle struct {
+	mpuc *MultipartClient
+	ref  ObjectRef
+}
+
+// BucketName returns the name of the object's bucket.
+func (o *ObjectHandle) BucketName() string {
+	return o.ref.Bucket
+}
+
+// ObjectName returns the name of the object.
+func (o *ObjectHandle) ObjectName() string {
+	return o.ref.Key
+}
+
+// Ref returns the bucket and name of the object.
+func (o *ObjectHandle) Ref() ObjectRef {
+	return o.ref
+}
+
+// Attrs returns the attributes of the object, as StatObject.
+func (o *ObjectHandle) Attrs(ctx context.Context) (*ObjectAttrs, error) {
+	return o.mpuc.StatObject(ctx, o.ref)
+}
+
+// NewMultipartUpload initiates a multipart upload of the object, with the
+// settings of the bucket's BucketConfig, and returns a handle for it. Use
+// InitiateMultipartUpload to set the object's settings.
+func (o *ObjectHandle) NewMultipartUpload(ctx context.Context) (*UploadHandle, error) {
+	result, err := o.mpuc.InitiateMultipartUpload(ctx, &InitiateMultipartUploadRequest{
+		Bucket: o.ref.Bucket,
+		Key:    o.ref.Key,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o.Upload(result.UploadID), nil
+}
+
+// Upload returns a handle for the multipart upload of the object with ID
+// uploadID, such as one initiated by another process. It doesn't check that
+// the upload exists.
+func (o *ObjectHandle) Upload(uploadID string) *UploadHandle {
+	return &UploadHandle{mpuc: o.mpuc, ref: o.ref, id: uploadID}
+}
+
+// UploadHandle refers to a multipart upload of an object.
+type UploadHandle struct {
+	mpuc *MultipartClient
+	ref  ObjectRef
+	id   string
+}
+
+// ID returns the ID of the upload.
+func (u *UploadHandle) ID() string {
+	return u.id
+}
+
+// Object returns a handle for the object being uploaded.
+func (u *UploadHandle) Object() *ObjectHandle {
+	return &ObjectHandle{mpuc: u.mpuc, ref: u.ref}
+}
+
+// UploadPart uploads body as part partNumber, verifying its checksums as
+// UploadObjectPartRequest.VerifyChecksums does. body is closed.
+func (u *UploadHandle) UploadPart(ctx context.Context, partNumber int, body io.ReadCloser) (*UploadObjectPartResult, error) {
+	return u.uploadPart(ctx, partNumber, body, nil)
+}
+
+// uploadPart is UploadPart reporting the progress of body to progress, if
+// not nil.
+func (u *UploadHandle) uploadPart(ctx context.Context, partNumber int, body io.ReadCloser, progress ProgressFunc) (*UploadObjectPartResult, error) {
+	return u.mpuc.UploadObjectPart(ctx, &UploadObjectPartRequest{
+		Bucket:          u.ref.Bucket,
+		Key:             u.ref.Key,
+		PartNumber:      partNumber,
+		UploadID:        u.id,
+		Body:            body,
+		VerifyChecksums: true,
+		Progress:        progress,
+	})
+}
+
+// CopyPart creates part partNumber from the range r of the object src, or
+// from all of it if r is nil, as UploadPartCopy.
+func (u *UploadHandle) CopyPart(ctx context.Context, partNumber int, src ObjectRef, r *ByteRange) (*CopyPartResult, error) {
+	return u.mpuc.UploadPartCopy(ctx, &UploadPartCopyRequest{
+		Bucket:       u.ref.Bucket,
+		Key:          u.ref.Key,
+		PartNumber:   partNumber,
+		UploadID:     u.id,
+		SourceBucket: src.Bucket,
+		SourceKey:    src.Key,
+		SourceRange:  r,
+	})
+}
+
+// Parts returns an iterator over the parts uploaded so far.
+func (u *UploadHandle) Parts(ctx context.Context) *PartIterator {
+	return u.mpuc.Parts(ctx, &ListObjectPartsRequest{
+		Bucket:   u.ref.Bucket,
+		Key:      u.ref.Key,
+		UploadID: u.id,
+	})
+}
+
+// Complete assembles parts, in ascending order of part number, into the
+// object.
+func (u *UploadHandle) Complete(ctx context.Context, parts []CompletePart) (*CompleteMultipartUploadResult, error) {
+	return u.mpuc.CompleteMultipartUpload(ctx, &CompleteMultipartUploadRequest{
+		Bucket:   u.ref.Bucket,
+		Key:      u.ref.Key,
+		UploadID: u.id,
+		Body:     CompleteMultipartUploadBody{Parts: parts},
+	})
+}
+
+// Abort aborts the upload, deleting its parts.
+func (u *UploadHandle) Abort(ctx context.Context) error {
+	return u.mpuc.AbortMultipartUpload(ctx, &AbortMultipartUploadRequest{
+		Bucket:   u.ref.Bucket,
+		Key:      u.ref.Key,
+		UploadID: u.id,
+	})
+}
@@ -0,0 +1,82 @@
+package multipartclient
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multiparttest"
+	"google.golang.org/api/iterator"
+)
+
+func TestHandles(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	srv.MinPartSize = 4
+	mpuc := New(srv.Client())
+	ctx := context.Background()
+
+	obj := mpuc.Bucket("bucket1").Object("dir/object.txt")
+	if got, want := obj.Ref(), (ObjectRef{Bucket: "bucket1", Key: "dir/object.txt"}); got != want {
+		t.Errorf("got ref %+v, want %+v", got, want)
+	}
+	upload, err := obj.NewMultipartUpload(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parts []CompletePart
+	for i, data := range []string{"hello ", "world"} {
+		part, err := upload.UploadPart(ctx, i+1, toBody(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, CompletePart{PartNumber: i + 1, ETag: part.ETag})
+	}
+
+	// A handle for the same upload, as another process would make.
+	attached := mpuc.Bucket("bucket1").Object("dir/object.txt").Upload(upload.ID())
+	var listed []CompletePart
+	it := attached.Parts(ctx)
+	for {
+		part, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		listed = append(listed, *part)
+	}
+	if diff := cmp.Diff(parts, listed); diff != "" {
+		t.Errorf("unexpected diff for parts (-want, +got):\n%s", diff)
+	}
+	if _, err := attached.Complete(ctx, listed); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := srv.Object("bucket1", "dir/object.txt"); string(got) != "hello world" {
+		t.Errorf("got object %q, want %q", got, "hello world")
+	}
+	attrs, err := attached.Object().Attrs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attrs.Size != int64(len("hello world")) {
+		t.Errorf("got size %d, want %d", attrs.Size, len("hello world"))
+	}
+}
+
+func TestUploadHandleAbort(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	mpuc := New(srv.Client())
+	ctx := context.Background()
+
+	upload, err := mpuc.Bucket("bucket1").Object("object.txt").NewMultipartUpload(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := upload.Abort(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if uploads := srv.Uploads(); len(uploads) != 0 {
+		t.Errorf("got uploads %v, want none", uploads)
+	}
+}
@@ -0,0 +1,127 @@
+package multipartclient
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+)
+
+const (
+	// hashBlockSize is the size writes are coalesced into before hashing.
+	// Large blocks amortize the per-block handoff and let the accelerated
+	// CRC32C and MD5 implementations run on long inputs, while still fitting
+	// in L2 cache.
+	hashBlockSize = 128 << 10
+	// asyncHasherQueueLen bounds the blocks buffered per part waiting to be
+	// hashed, and so the extra memory used by asynchronous hashing.
+	asyncHasherQueueLen = 8
+)
+
+var errHasherClosed = errors.New("write to hasher after it was closed")
+
+// hashBlockPool recycles the blocks handed to hash workers.
+var hashBlockPool = sync.Pool{
+	New: func() any {
+		block := make([]byte, 0, hashBlockSize)
+		return &block
+	},
+}
+
+// hashPool bounds the number of blocks hashed concurrently across all parts.
+type hashPool struct {
+	sem chan struct{}
+}
+
+func newHashPool(workers int) *hashPool {
+	return &hashPool{sem: make(chan struct{}, workers)}
+}
+
+// asyncHasher hashes written data on a background goroutine so hashing of a
+// part overlaps with sending it, and with the network I/O of other parts.
+// Blocks are hashed in the order they were written.
+type asyncHasher struct {
+	h      *gcshash.Hasher
+	pool   *hashPool
+	mu     sync.Mutex
+	closed bool
+	// pending accumulates written data until a full block can be queued.
+	pending []byte
+	blocks  chan []byte
+	done    chan struct{}
+}
+
+func (p *hashPool) newAsyncHasher(h *gcshash.Hasher) *asyncHasher {
+	a := &asyncHasher{
+		h:      h,
+		pool:   p,
+		blocks: make(chan []byte, asyncHasherQueueLen),
+		done:   make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+func (a *asyncHasher) run() {
+	defer close(a.done)
+	for block := range a.blocks {
+		a.pool.sem <- struct{}{}
+		a.h.Write(block)
+		<-a.pool.sem
+		block = block[:0]
+		hashBlockPool.Put(&block)
+	}
+}
+
+// Write copies p to be hashed, queueing it in blocks of hashBlockSize.
+func (a *asyncHasher) Write(p []byte) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// The HTTP transport may still read the request body after the request
+	// returns, so writes can arrive after wait.
+	if a.closed {
+		return 0, errHasherClosed
+	}
+	n := len(p)
+	for len(p) > 0 {
+		if a.pending == nil {
+			a.pending = *hashBlockPool.Get().(*[]byte)
+		}
+		copied := copy(a.pending[len(a.pending):cap(a.pending)], p)
+		a.pending = a.pending[:len(a.pending)+copied]
+		p = p[copied:]
+		if len(a.pending) == cap(a.pending) {
+			a.blocks <- a.pending
+			a.pending = nil
+		}
+	}
+	return n, nil
+}
+
+// wait stops accepting writes and returns once every queued block has been
+// hashed.
+func (a *asyncHasher) wait() {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		if len(a.pending) > 0 {
+			a.blocks <- a.pending
+			a.pending = nil
+		}
+		close(a.blocks)
+	}
+	a.mu.Unlock()
+	<-a.done
+}
+
+// hashWriter returns the writer that feeds hasher, hashing asynchronously if
+// the client has a hash pool. The returned function must be called before
+// reading the sums of hasher.
+func (mpuc *MultipartClient) hashWriter(hasher *gcshash.Hasher) (io.Writer, func()) {
+	if mpuc.hashPool == nil {
+		return hasher, func() {}
+	}
+	async := mpuc.hashPool.newAsyncHasher(hasher)
+	return async, async.wait
+}
@@ -0,0 +1,83 @@
+package multipartclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// HealthProblem classifies why a HealthCheck failed.
+type HealthProblem string
+
+const (
+	// HealthConnectivity means no response was received from GCS.
+	HealthConnectivity HealthProblem = "connectivity"
+	// HealthCredentials means the request was not authenticated.
+	HealthCredentials HealthProblem = "credentials"
+	// HealthBucketAccess means the bucket does not exist or the caller may not
+	// use multipart uploads in it.
+	HealthBucketAccess HealthProblem = "bucket_access"
+	// HealthServer means GCS failed the request.
+	HealthServer HealthProblem = "server"
+)
+
+// HealthCheckResult describes the outcome of a HealthCheck.
+type HealthCheckResult struct {
+	Bucket string
+	// Healthy reports whether the bucket's multipart uploads could be listed.
+	Healthy bool
+	// Problem is set if Healthy is false.
+	Problem HealthProblem
+	// StatusCode is the HTTP status of the probe, or 0 if none was received.
+	StatusCode int
+	Latency    time.Duration
+}
+
+// HealthCheck verifies that the client can reach GCS, that its credentials
+// are accepted and that multipart uploads can be listed in bucket, by listing
+// at most one upload. It is meant for readiness probes, before a service
+// accepts upload work. The result is returned even if the check fails, in
+// which case the error describes the failure.
+func (mpuc *MultipartClient) HealthCheck(ctx context.Context, bucket string) (*HealthCheckResult, error) {
+	url := mpuc.requestURL(bucket, "", "uploads&max-uploads=1")
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	mpuc.setUserProject(ctx, httpReq, bucket)
+	if err := mpuc.setPayloadHash(httpReq, http.NoBody); err != nil {
+		return nil, err
+	}
+
+	start := mpuc.clock.Now()
+	resp, err := mpuc.do(ctx, OpHealthCheck, httpReq)
+	defer googleapi.CloseBody(resp)
+	result := &HealthCheckResult{
+		Bucket:  bucket,
+		Healthy: err == nil,
+		Latency: mpuc.since(start),
+	}
+	if resp != nil {
+		result.StatusCode = resp.StatusCode
+	}
+	if err != nil {
+		result.Problem = healthProblem(result.StatusCode)
+		return result, fmt.Errorf("health check of bucket %s failed (%s): %w", bucket, result.Problem, err)
+	}
+	return result, nil
+}
+
+func healthProblem(statusCode int) HealthProblem {
+	switch {
+	case statusCode == 0:
+		return HealthConnectivity
+	case statusCode == http.StatusUnauthorized:
+		return HealthCredentials
+	case 400 <= statusCode && statusCode < 500:
+		return HealthBucketAccess
+	}
+	return HealthServer
+}
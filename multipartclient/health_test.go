@@ -0,0 +1,76 @@
+package multipartclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestHealthCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		respStatus int
+		respErr    error
+		want       *HealthCheckResult
+		wantErr    bool
+	}{
+		{
+			name:       "Healthy",
+			respStatus: http.StatusOK,
+			want:       &HealthCheckResult{Bucket: "bucket1", Healthy: true, StatusCode: http.StatusOK},
+		},
+		{
+			name:    "Unreachable",
+			respErr: errors.New("dial tcp: no route to host"),
+			want:    &HealthCheckResult{Bucket: "bucket1", Problem: HealthConnectivity},
+			wantErr: true,
+		},
+		{
+			name:       "Bad credentials",
+			respStatus: http.StatusUnauthorized,
+			want:       &HealthCheckResult{Bucket: "bucket1", Problem: HealthCredentials, StatusCode: http.StatusUnauthorized},
+			wantErr:    true,
+		},
+		{
+			name:       "No access to bucket",
+			respStatus: http.StatusForbidden,
+			want:       &HealthCheckResult{Bucket: "bucket1", Problem: HealthBucketAccess, StatusCode: http.StatusForbidden},
+			wantErr:    true,
+		},
+		{
+			name:       "Server error",
+			respStatus: http.StatusServiceUnavailable,
+			want:       &HealthCheckResult{Bucket: "bucket1", Problem: HealthServer, StatusCode: http.StatusServiceUnavailable},
+			wantErr:    true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotURL string
+			trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+				gotURL = req.URL.String()
+				if tc.respErr != nil {
+					return nil, tc.respErr
+				}
+				return &http.Response{StatusCode: tc.respStatus, Status: http.StatusText(tc.respStatus), Body: http.NoBody}, nil
+			})
+			mpuc := New(&http.Client{Transport: trans})
+
+			got, err := mpuc.HealthCheck(context.Background(), "bucket1")
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("got error %v, want error %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got, cmpopts.IgnoreFields(HealthCheckResult{}, "Latency")); diff != "" {
+				t.Errorf("unexpected diff for result: (-want, +got):\n%s", diff)
+			}
+			if want := "https://storage.googleapis.com/bucket1/?uploads&max-uploads=1"; gotURL != want {
+				t.Errorf("got URL %q, want %q", gotURL, want)
+			}
+		})
+	}
+}
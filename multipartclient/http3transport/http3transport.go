@@ -0,0 +1,180 @@
+// Package http3transport is an experimental HTTP/3 transport for
+// multipartclient, for long-haul links with packet loss, where QUIC's loss
+// recovery and per-stream delivery keep parts moving that a TCP connection
+// would stall.
+//
+// Pass a Transport to multipartclient.New in an *http.Client:
+//
+//	mpuc := multipartclient.New(&http.Client{Transport: http3transport.New(nil)})
+//
+// Hosts that can't be reached over QUIC, often because UDP is blocked, are
+// sent requests over HTTP/1.1 or HTTP/2 instead. Measure it against the
+// default transport before relying on it: on clean links QUIC usually costs
+// more CPU per byte than TCP.
+package http3transport
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+const (
+	// defaultHandshakeTimeout is the time to establish a QUIC connection
+	// before the host falls back.
+	defaultHandshakeTimeout = 5 * time.Second
+	// defaultRetryAfter is the time a host that fell back is sent requests
+	// over the fallback before HTTP/3 is tried again.
+	defaultRetryAfter = 5 * time.Minute
+)
+
+// Options configures a Transport.
+type Options struct {
+	// Fallback sends the requests to hosts that can't be reached over QUIC,
+	// and to http URLs. Defaults to http.DefaultTransport.
+	Fallback http.RoundTripper
+	// TLSClientConfig configures the TLS of QUIC connections. Its NextProtos
+	// are replaced with HTTP/3's.
+	TLSClientConfig *tls.Config
+	// HandshakeTimeout is the time to establish a QUIC connection to a host
+	// before falling back to Fallback for it. Defaults to 5 seconds.
+	HandshakeTimeout time.Duration
+	// RetryAfter is how long a host that fell back is sent requests over
+	// Fallback before HTTP/3 is tried again. Defaults to 5 minutes.
+	RetryAfter time.Duration
+}
+
+// Transport is an http.RoundTripper that sends requests over HTTP/3, and
+// falls back to another transport for hosts it can't connect to.
+type Transport struct {
+	h3         *http3.Transport
+	fallback   http.RoundTripper
+	retryAfter time.Duration
+	now        func() time.Time
+
+	mu sync.Mutex
+	// fellBack holds the time each host fell back at.
+	fellBack map[string]time.Time
+}
+
+// New returns a Transport with the options opts, which may be nil.
+func New(opts *Options) *Transport {
+	if opts == nil {
+		opts = &Options{}
+	}
+	t := &Transport{
+		fallback:   opts.Fallback,
+		retryAfter: opts.RetryAfter,
+		now:        time.Now,
+		fellBack:   make(map[string]time.Time),
+	}
+	if t.fallback == nil {
+		t.fallback = http.DefaultTransport
+	}
+	if t.retryAfter <= 0 {
+		t.retryAfter = defaultRetryAfter
+	}
+	handshakeTimeout := opts.HandshakeTimeout
+	if handshakeTimeout <= 0 {
+		handshakeTimeout = defaultHandshakeTimeout
+	}
+	t.h3 = &http3.Transport{
+		TLSClientConfig: opts.TLSClientConfig,
+		QUICConfig: &quic.Config{
+			HandshakeIdleTimeout: handshakeTimeout,
+		},
+		Dial: func(ctx context.Context, addr string, tlsConf *tls.Config, conf *quic.Config) (quic.EarlyConnection, error) {
+			conn, err := dialHandshake(ctx, addr, tlsConf, conf, handshakeTimeout)
+			if err != nil {
+				return nil, &dialError{err: err}
+			}
+			return conn, nil
+		},
+	}
+	return t
+}
+
+// dialHandshake dials a QUIC connection to addr and waits for its handshake,
+// so that a host that can't be reached fails here, before any request is sent
+// on it.
+func dialHandshake(ctx context.Context, addr string, tlsConf *tls.Config, conf *quic.Config, timeout time.Duration) (quic.EarlyConnection, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := quic.DialAddrEarly(ctx, addr, tlsConf, conf)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-conn.HandshakeComplete():
+		return conn, nil
+	case <-conn.Context().Done():
+		return nil, context.Cause(conn.Context())
+	case <-ctx.Done():
+		conn.CloseWithError(0, "")
+		return nil, ctx.Err()
+	}
+}
+
+// dialError is the error of a QUIC connection that couldn't be established.
+type dialError struct {
+	err error
+}
+
+func (e *dialError) Error() string { return "http3transport: " + e.err.Error() }
+func (e *dialError) Unwrap() error { return e.err }
+
+// RoundTrip sends req over HTTP/3, or over the fallback if req's host can't
+// be reached over QUIC. Requests to a host whose connection fails are sent
+// over the fallback, since no part of them was sent.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if req.URL.Scheme != "https" || t.fallingBack(host) {
+		return t.fallback.RoundTrip(req)
+	}
+	resp, err := t.h3.RoundTrip(req)
+	var de *dialError
+	if errors.As(err, &de) && req.Context().Err() == nil {
+		t.fallBack(host)
+		return t.fallback.RoundTrip(req)
+	}
+	return resp, err
+}
+
+// fallingBack reports whether requests to host are sent over the fallback.
+func (t *Transport) fallingBack(host string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	at, ok := t.fellBack[host]
+	if ok && t.now().Sub(at) >= t.retryAfter {
+		delete(t.fellBack, host)
+		return false
+	}
+	return ok
+}
+
+// fallBack sends requests to host over the fallback for the next RetryAfter.
+func (t *Transport) fallBack(host string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.fellBack[host] = t.now()
+}
+
+// CloseIdleConnections closes the idle connections of the HTTP/3 transport
+// and of the fallback.
+func (t *Transport) CloseIdleConnections() {
+	t.h3.CloseIdleConnections()
+	if ci, ok := t.fallback.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+}
+
+// Close closes the QUIC connections of t.
+func (t *Transport) Close() error {
+	return t.h3.Close()
+}
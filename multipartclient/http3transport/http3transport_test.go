@@ -0,0 +1,118 @@
+package http3transport
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// newServer returns a TLS server that responds to requests with their
+// protocol and body, and that serves HTTP/3 on the same port if h3 is set.
+func newServer(t *testing.T, h3 bool) *httptest.Server {
+	t.Helper()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		io.WriteString(w, r.Proto+" "+string(body))
+	})
+	srv := httptest.NewTLSServer(handler)
+	t.Cleanup(srv.Close)
+	if !h3 {
+		return srv
+	}
+	conn, err := net.ListenPacket("udp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h3srv := &http3.Server{Handler: handler, TLSConfig: http3.ConfigureTLSConfig(srv.TLS.Clone())}
+	go h3srv.Serve(conn)
+	t.Cleanup(func() { h3srv.Close() })
+	return srv
+}
+
+// newTransport returns a Transport that trusts srv.
+func newTransport(t *testing.T, srv *httptest.Server) *Transport {
+	t.Helper()
+	fallback := srv.Client().Transport.(*http.Transport)
+	trans := New(&Options{
+		Fallback:         fallback,
+		TLSClientConfig:  fallback.TLSClientConfig,
+		HandshakeTimeout: time.Second,
+	})
+	t.Cleanup(func() { trans.Close() })
+	return trans
+}
+
+func put(t *testing.T, trans http.RoundTripper, url, body string) string {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPut, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := trans.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	got, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(got)
+}
+
+func TestRoundTrip(t *testing.T) {
+	srv := newServer(t, true)
+	trans := newTransport(t, srv)
+	for i := 0; i < 2; i++ {
+		if got, want := put(t, trans, srv.URL+"/bucket/object?partNumber=1", "part"), "HTTP/3.0 part"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestRoundTripFallback(t *testing.T) {
+	srv := newServer(t, false)
+	trans := newTransport(t, srv)
+	now := time.Now()
+	trans.now = func() time.Time { return now }
+
+	if got, want := put(t, trans, srv.URL+"/bucket/object", "part 1"), "HTTP/1.1 part 1"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	host := strings.TrimPrefix(srv.URL, "https://")
+	if !trans.fallingBack(host) {
+		t.Fatalf("%s isn't falling back", host)
+	}
+	// Sent straight to the fallback.
+	if got, want := put(t, trans, srv.URL+"/bucket/object", "part 2"), "HTTP/1.1 part 2"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	now = now.Add(defaultRetryAfter)
+	if trans.fallingBack(host) {
+		t.Errorf("%s is still falling back after %v", host, defaultRetryAfter)
+	}
+}
+
+func TestRoundTripPlainText(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	t.Cleanup(srv.Close)
+	trans := New(nil)
+	if got, want := put(t, trans, srv.URL, ""), "HTTP/1.1"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if len(trans.fellBack) != 0 {
+		t.Errorf("got hosts falling back %v, want none", trans.fellBack)
+	}
+}
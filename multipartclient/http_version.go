@@ -0,0 +1,39 @@
+package multipartclient
+
+// HTTPVersion selects the HTTP version of requests.
+type HTTPVersion int
+
+const (
+	// HTTPVersionAuto leaves the version to the transport, which uses HTTP/2
+	// if the server offers it over TLS. This is the default.
+	HTTPVersionAuto HTTPVersion = iota
+	// HTTP1 sends every request over HTTP/1.1, with one connection per
+	// request in flight.
+	HTTP1
+	// HTTP2 sends requests over HTTP/2, multiplexed on a connection. It needs
+	// a TLS endpoint; plain-text endpoints keep using HTTP/1.1.
+	HTTP2
+)
+
+// idleConnsPerHost is the number of idle connections kept per host with HTTP1,
+// unless the transport sets its own, so the connections of parallel parts are
+// reused rather than redialed.
+const idleConnsPerHost = 100
+
+// WithHTTPVersion sends requests with HTTP version v. It applies to clients
+// whose transport is an *http.Transport, or nil, which it clones rather than
+// changes, and to NewClientWithOptions. Other transports are left as they
+// are.
+//
+// HTTP/2 multiplexes the parts in flight on one connection, whose flow control
+// windows and single TCP congestion window are shared by every part, so
+// parallel uploads of multi-gigabyte objects often run faster over HTTP1,
+// which gives each part its own connection. HTTP/2 saves connections and TLS
+// handshakes, which matters more for many small parts or a limited number of
+// sockets. Measure both with the expected part size and concurrency; see
+// BenchmarkHTTPVersion. For HTTP/3, see package http3transport.
+func WithHTTPVersion(v HTTPVersion) Option {
+	return func(mpuc *MultipartClient) {
+		mpuc.transport.httpVersion = v
+	}
+}
@@ -0,0 +1,160 @@
+package multipartclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/option"
+)
+
+// newHTTP2Server returns a TLS server that offers HTTP/2 and records the
+// protocol version of the last request.
+func newHTTP2Server(t testing.TB) (*httptest.Server, func() int) {
+	var mu sync.Mutex
+	proto := 0
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		mu.Lock()
+		proto = r.ProtoMajor
+		mu.Unlock()
+		w.Write([]byte(`<ListMultipartUploadsResult></ListMultipartUploadsResult>`))
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return proto
+	}
+}
+
+func TestWithHTTPVersion(t *testing.T) {
+	srv, proto := newHTTP2Server(t)
+	for _, tc := range []struct {
+		version   HTTPVersion
+		wantProto int
+	}{
+		{version: HTTPVersionAuto, wantProto: 2},
+		{version: HTTP1, wantProto: 1},
+		{version: HTTP2, wantProto: 2},
+	} {
+		mpuc := New(srv.Client(), WithHTTPVersion(tc.version), WithEndpoint(srv.URL))
+		if _, err := mpuc.ListMultipartUploads(context.Background(), &ListMultipartUploadsRequest{Bucket: "bucket1"}); err != nil {
+			t.Fatal(err)
+		}
+		if got := proto(); got != tc.wantProto {
+			t.Errorf("got HTTP/%d with version %d, want HTTP/%d", got, tc.version, tc.wantProto)
+		}
+	}
+
+	// The server's client is cloned rather than changed.
+	mpuc := New(srv.Client(), WithEndpoint(srv.URL))
+	if _, err := mpuc.ListMultipartUploads(context.Background(), &ListMultipartUploadsRequest{Bucket: "bucket1"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := proto(); got != 2 {
+		t.Errorf("got HTTP/%d after WithHTTPVersion(HTTP1) used the client, want HTTP/2", got)
+	}
+}
+
+func TestWithHTTPVersionOtherTransport(t *testing.T) {
+	hc := &http.Client{Transport: funcTransport(func(req *http.Request) (*http.Response, error) {
+		return nil, io.EOF
+	})}
+	if mpuc := New(hc, WithHTTPVersion(HTTP1)); mpuc.hc != hc {
+		t.Error("got a different client for a transport that isn't an *http.Transport")
+	}
+}
+
+func TestNewClientWithHTTPVersion(t *testing.T) {
+	t.Run("Authorized transport", func(t *testing.T) {
+		var gotReq *http.Request
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotReq = r
+			w.Write([]byte(`<ListMultipartUploadsResult></ListMultipartUploadsResult>`))
+		}))
+		t.Cleanup(srv.Close)
+
+		ctx := context.Background()
+		mpuc, err := NewClientWithOptions(ctx, []option.ClientOption{
+			option.WithEndpoint(srv.URL + "/"),
+			option.WithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token1"})),
+			option.WithQuotaProject("project1"),
+		}, WithHTTPVersion(HTTP1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := mpuc.ListMultipartUploads(ctx, &ListMultipartUploadsRequest{Bucket: "bucket1"}); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := gotReq.Header.Get("Authorization"), "Bearer token1"; got != want {
+			t.Errorf("got Authorization header %q, want %q", got, want)
+		}
+		if got, want := gotReq.URL.RequestURI(), "/bucket1/?uploads"; got != want {
+			t.Errorf("got request URI %q, want %q", got, want)
+		}
+	})
+
+	t.Run("HTTP client option", func(t *testing.T) {
+		srv, proto := newHTTP2Server(t)
+		ctx := context.Background()
+		mpuc, err := NewClientWithOptions(ctx, []option.ClientOption{
+			option.WithEndpoint(srv.URL + "/"),
+			option.WithHTTPClient(srv.Client()),
+		}, WithHTTPVersion(HTTP1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := mpuc.ListMultipartUploads(ctx, &ListMultipartUploadsRequest{Bucket: "bucket1"}); err != nil {
+			t.Fatal(err)
+		}
+		if got := proto(); got != 1 {
+			t.Errorf("got HTTP/%d, want HTTP/1", got)
+		}
+	})
+}
+
+// BenchmarkHTTPVersion uploads parts in parallel to a local TLS server over
+// HTTP/1.1 and HTTP/2, with 8 parts in flight per CPU. Even over a loopback,
+// HTTP/1.1 is about a third faster for 4 MiB parts, since HTTP/2 writes every
+// part's frames to one connection; over a real network the shared flow
+// control and congestion windows widen the gap.
+func BenchmarkHTTPVersion(b *testing.B) {
+	const (
+		partSize    = 4 << 20
+		concurrency = 8
+	)
+	srv, _ := newHTTP2Server(b)
+	data := make([]byte, partSize)
+
+	for _, version := range []HTTPVersion{HTTP1, HTTP2} {
+		b.Run(fmt.Sprintf("HTTP%d", version), func(b *testing.B) {
+			mpuc := New(srv.Client(), WithHTTPVersion(version), WithEndpoint(srv.URL))
+			b.SetBytes(partSize)
+			b.SetParallelism(concurrency)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					_, err := mpuc.UploadObjectPart(context.Background(), &UploadObjectPartRequest{
+						Bucket:     "bucket",
+						Key:        "object",
+						PartNumber: 1,
+						UploadID:   "my-upload-id",
+						Body:       NewSectionBody(bytes.NewReader(data), 0, partSize),
+					})
+					if err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package multipartclient
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"google.golang.org/api/iterator"
+)
+
+// UploadIterator iterates over multipart uploads in progress, requesting
+// pages of them as needed. It follows the conventions of
+// google.golang.org/api/iterator: Next returns iterator.Done after the last
+// upload, and PageInfo sets the page size and holds the token to resume from.
+type UploadIterator struct {
+	req      ListMultipartUploadsRequest
+	pages    *prefetcher[ListMultipartUploadsRequest, ListMultipartUploadsResult]
+	items    []ListUpload
+	pageInfo *iterator.PageInfo
+	nextFunc func() error
+}
+
+// Uploads returns an iterator over the uploads listed by req, starting after
+// its markers. req.MaxUploads is the initial page size.
+func (mpuc *MultipartClient) Uploads(ctx context.Context, req *ListMultipartUploadsRequest) *UploadIterator {
+	it := &UploadIterator{req: *req, pages: mpuc.uploadPages(ctx)}
+	it.pageInfo, it.nextFunc = iterator.NewPageInfo(
+		it.fetch,
+		func() int { return len(it.items) },
+		func() any { b := it.items; it.items = nil; return b },
+	)
+	it.pageInfo.MaxSize = req.MaxUploads
+	it.pageInfo.Token = uploadsToken(req.KeyMarker, req.UploadIDMarker)
+	return it
+}
+
+// PageInfo supports pagination. See the google.golang.org/api/iterator
+// package.
+func (it *UploadIterator) PageInfo() *iterator.PageInfo {
+	return it.pageInfo
+}
+
+// Next returns the next upload, or iterator.Done if there are no more.
+func (it *UploadIterator) Next() (*ListUpload, error) {
+	if err := it.nextFunc(); err != nil {
+		return nil, err
+	}
+	item := it.items[0]
+	it.items = it.items[1:]
+	return &item, nil
+}
+
+func (it *UploadIterator) fetch(pageSize int, pageToken string) (string, error) {
+	req := it.req
+	req.MaxUploads = pageSize
+	var err error
+	if req.KeyMarker, req.UploadIDMarker, err = parseUploadsToken(pageToken); err != nil {
+		return "", err
+	}
+	result, err := it.pages.get(req)
+	if err != nil {
+		return "", err
+	}
+	it.items = append(it.items, result.Uploads...)
+	if !result.IsTruncated {
+		return "", nil
+	}
+	next := req
+	next.KeyMarker, next.UploadIDMarker = nextUploadsMarkers(result)
+	if token := uploadsToken(next.KeyMarker, next.UploadIDMarker); token != "" {
+		it.pages.prefetch(next)
+		return token, nil
+	}
+	return "", nil
+}
+
+// uploadPages returns a prefetcher of the pages of listings of uploads.
+func (mpuc *MultipartClient) uploadPages(ctx context.Context) *prefetcher[ListMultipartUploadsRequest, ListMultipartUploadsResult] {
+	return newPrefetcher(mpuc, func(req ListMultipartUploadsRequest) (*ListMultipartUploadsResult, error) {
+		return mpuc.ListMultipartUploads(ctx, &req)
+	})
+}
+
+// nextUploadsMarkers returns the markers of the page after the truncated
+// result.
+func nextUploadsMarkers(result *ListMultipartUploadsResult) (keyMarker, uploadIDMarker string) {
+	keyMarker, uploadIDMarker = result.NextKeyMarker, result.NextUploadIDMarker
+	if keyMarker == "" && len(result.Uploads) > 0 {
+		// Servers that leave out the markers resume after the last upload.
+		last := result.Uploads[len(result.Uploads)-1]
+		keyMarker, uploadIDMarker = last.Key, last.UploadID
+	}
+	return keyMarker, uploadIDMarker
+}
+
+// ListMultipartUploadsPages calls fn with each page of the uploads listed by
+// req, following the markers of truncated pages, until fn has the last page
+// or returns false. It fails if a truncated page has no markers to follow.
+//
+//	err := mpuc.ListMultipartUploadsPages(ctx, &multipartclient.ListMultipartUploadsRequest{Bucket: "bucket1"},
+//		func(page *multipartclient.ListMultipartUploadsResult) bool {
+//			for _, u := range page.Uploads {
+//				fmt.Println(u.Key, u.UploadID)
+//			}
+//			return true
+//		})
+func (mpuc *MultipartClient) ListMultipartUploadsPages(ctx context.Context, req *ListMultipartUploadsRequest, fn func(*ListMultipartUploadsResult) bool) error {
+	pages := mpuc.uploadPages(ctx)
+	page := *req
+	for {
+		result, err := pages.get(page)
+		if err != nil {
+			return err
+		}
+		if result.IsTruncated {
+			next := page
+			next.KeyMarker, next.UploadIDMarker = nextUploadsMarkers(result)
+			if next == page || next.KeyMarker == "" && next.UploadIDMarker == "" {
+				return fmt.Errorf("listing of uploads in %s is truncated after key %q and upload %q, but doesn't continue", req.Bucket, page.KeyMarker, page.UploadIDMarker)
+			}
+			page = next
+			pages.prefetch(page)
+		}
+		if !fn(result) || !result.IsTruncated {
+			return nil
+		}
+	}
+}
+
+// uploadsToken returns the page token of the markers of a listing of uploads,
+// or "" if there are none.
+func uploadsToken(keyMarker, uploadIDMarker string) string {
+	if keyMarker == "" && uploadIDMarker == "" {
+		return ""
+	}
+	return url.Values{"key-marker": {keyMarker}, "upload-id-marker": {uploadIDMarker}}.Encode()
+}
+
+func parseUploadsToken(token string) (keyMarker, uploadIDMarker string, err error) {
+	v, err := url.ParseQuery(token)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid page token %q: %w", token, err)
+	}
+	return v.Get("key-marker"), v.Get("upload-id-marker"), nil
+}
+
+// PartIterator iterates over the parts of a multipart upload, requesting
+// pages of them as needed, with the conventions of UploadIterator.
+type PartIterator struct {
+	req      ListObjectPartsRequest
+	pages    *prefetcher[ListObjectPartsRequest, ListObjectPartsResult]
+	items    []CompletePart
+	pageInfo *iterator.PageInfo
+	nextFunc func() error
+}
+
+// Parts returns an iterator over the parts listed by req, starting after
+// req.PartNumberMarker. req.MaxParts is the initial page size.
+func (mpuc *MultipartClient) Parts(ctx context.Context, req *ListObjectPartsRequest) *PartIterator {
+	it := &PartIterator{req: *req, pages: mpuc.partPages(ctx)}
+	it.pageInfo, it.nextFunc = iterator.NewPageInfo(
+		it.fetch,
+		func() int { return len(it.items) },
+		func() any { b := it.items; it.items = nil; return b },
+	)
+	it.pageInfo.MaxSize = req.MaxParts
+	if req.PartNumberMarker > 0 {
+		it.pageInfo.Token = strconv.Itoa(req.PartNumberMarker)
+	}
+	return it
+}
+
+// PageInfo supports pagination. See the google.golang.org/api/iterator
+// package.
+func (it *PartIterator) PageInfo() *iterator.PageInfo {
+	return it.pageInfo
+}
+
+// Next returns the next part, or iterator.Done if there are no more.
+func (it *PartIterator) Next() (*CompletePart, error) {
+	if err := it.nextFunc(); err != nil {
+		return nil, err
+	}
+	item := it.items[0]
+	it.items = it.items[1:]
+	return &item, nil
+}
+
+func (it *PartIterator) fetch(pageSize int, pageToken string) (string, error) {
+	req := it.req
+	req.MaxParts, req.PartNumberMarker = pageSize, 0
+	if pageToken != "" {
+		marker, err := strconv.Atoi(pageToken)
+		if err != nil {
+			return "", fmt.Errorf("invalid page token %q: %w", pageToken, err)
+		}
+		req.PartNumberMarker = marker
+	}
+	result, err := it.pages.get(req)
+	if err != nil {
+		return "", err
+	}
+	it.items = append(it.items, result.Parts...)
+	if !result.IsTruncated {
+		return "", nil
+	}
+	marker := nextPartNumberMarker(result)
+	if marker == 0 {
+		return "", nil
+	}
+	next := req
+	next.PartNumberMarker = marker
+	it.pages.prefetch(next)
+	return strconv.Itoa(marker), nil
+}
+
+// ListAllObjectParts lists all the parts listed by req, starting after
+// req.PartNumberMarker, following the markers of truncated pages of
+// req.MaxParts parts. It fails if a truncated page has no marker to follow.
+func (mpuc *MultipartClient) ListAllObjectParts(ctx context.Context, req *ListObjectPartsRequest) ([]CompletePart, error) {
+	pages := mpuc.partPages(ctx)
+	page := *req
+	var parts []CompletePart
+	for {
+		result, err := pages.get(page)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, result.Parts...)
+		if !result.IsTruncated {
+			return parts, nil
+		}
+		marker := nextPartNumberMarker(result)
+		if marker <= page.PartNumberMarker {
+			return nil, fmt.Errorf("listing of parts of upload %s is truncated after part %d, but doesn't continue", req.UploadID, page.PartNumberMarker)
+		}
+		page.PartNumberMarker = marker
+		pages.prefetch(page)
+	}
+}
+
+// partPages returns a prefetcher of the pages of listings of parts.
+func (mpuc *MultipartClient) partPages(ctx context.Context) *prefetcher[ListObjectPartsRequest, ListObjectPartsResult] {
+	return newPrefetcher(mpuc, func(req ListObjectPartsRequest) (*ListObjectPartsResult, error) {
+		return mpuc.ListObjectParts(ctx, &req)
+	})
+}
+
+// nextPartNumberMarker returns the marker of the page after the truncated
+// result, or 0 if there is none.
+func nextPartNumberMarker(result *ListObjectPartsResult) int {
+	marker := result.NextPartNumberMarker
+	if marker == 0 && len(result.Parts) > 0 {
+		// Servers that leave out the marker resume after the last part.
+		marker = result.Parts[len(result.Parts)-1].PartNumber
+	}
+	return marker
+}
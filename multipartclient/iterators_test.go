@@ -0,0 +1,281 @@
+package multipartclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/iterator"
+)
+
+// pagingTransport serves listings of uploads and parts from keys and
+// partNumbers a page at a time, honouring the markers and limits of the
+// requests, which it records. With omitMarkers, truncated pages leave out
+// their next markers.
+type pagingTransport struct {
+	keys        []string
+	partNumbers []int
+	omitMarkers bool
+
+	mu      sync.Mutex
+	queries []string
+}
+
+func (pt *pagingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	q := req.URL.Query()
+	pt.mu.Lock()
+	pt.queries = append(pt.queries, req.URL.RawQuery)
+	pt.mu.Unlock()
+	pageSize := 1000
+	if n, err := strconv.Atoi(q.Get("max-uploads") + q.Get("max-parts")); err == nil {
+		pageSize = n
+	}
+	var b strings.Builder
+	if q.Has("uploads") {
+		b.WriteString("<ListMultipartUploadsResult>")
+		start := 0
+		for start < len(pt.keys) && pt.keys[start] <= q.Get("key-marker") {
+			start++
+		}
+		end := min(start+pageSize, len(pt.keys))
+		for _, key := range pt.keys[start:end] {
+			fmt.Fprintf(&b, "<Upload><Key>%s</Key><UploadId>id-%s</UploadId><Initiated>2024-03-10T12:00:00Z</Initiated></Upload>", key, key)
+		}
+		if end < len(pt.keys) {
+			b.WriteString("<IsTruncated>true</IsTruncated>")
+			if !pt.omitMarkers {
+				fmt.Fprintf(&b, "<NextKeyMarker>%s</NextKeyMarker><NextUploadIdMarker>id-%[1]s</NextUploadIdMarker>", pt.keys[end-1])
+			}
+		}
+		b.WriteString("</ListMultipartUploadsResult>")
+		return xmlResponse(b.String()), nil
+	}
+	b.WriteString("<ListPartsResult>")
+	marker, _ := strconv.Atoi(q.Get("part-number-marker"))
+	start := 0
+	for start < len(pt.partNumbers) && pt.partNumbers[start] <= marker {
+		start++
+	}
+	end := min(start+pageSize, len(pt.partNumbers))
+	for _, n := range pt.partNumbers[start:end] {
+		fmt.Fprintf(&b, "<Part><PartNumber>%d</PartNumber><ETag>etag-%[1]d</ETag></Part>", n)
+	}
+	if end < len(pt.partNumbers) {
+		b.WriteString("<IsTruncated>true</IsTruncated>")
+		if !pt.omitMarkers {
+			fmt.Fprintf(&b, "<NextPartNumberMarker>%d</NextPartNumberMarker>", pt.partNumbers[end-1])
+		}
+	}
+	b.WriteString("</ListPartsResult>")
+	return xmlResponse(b.String()), nil
+}
+
+func uploadKeys(t *testing.T, it *UploadIterator) []string {
+	t.Helper()
+	var keys []string
+	for {
+		u, err := it.Next()
+		if err == iterator.Done {
+			return keys
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, u.Key)
+	}
+}
+
+func TestUploadIterator(t *testing.T) {
+	keys := []string{"a", "b", "c", "d", "e"}
+	for _, omitMarkers := range []bool{false, true} {
+		t.Run(fmt.Sprintf("omitMarkers=%v", omitMarkers), func(t *testing.T) {
+			pt := &pagingTransport{keys: keys, omitMarkers: omitMarkers}
+			mpuc := New(&http.Client{Transport: pt})
+
+			it := mpuc.Uploads(context.Background(), &ListMultipartUploadsRequest{Bucket: "bucket1", MaxUploads: 2})
+			if diff := cmp.Diff(keys, uploadKeys(t, it)); diff != "" {
+				t.Errorf("unexpected diff for keys (-want, +got):\n%s", diff)
+			}
+			wantQueries := []string{
+				"uploads&max-uploads=2",
+				"uploads&key-marker=b&upload-id-marker=id-b&max-uploads=2",
+				"uploads&key-marker=d&upload-id-marker=id-d&max-uploads=2",
+			}
+			if diff := cmp.Diff(wantQueries, pt.queries); diff != "" {
+				t.Errorf("unexpected diff for queries (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUploadIteratorPager(t *testing.T) {
+	pt := &pagingTransport{keys: []string{"a", "b", "c"}}
+	mpuc := New(&http.Client{Transport: pt})
+	req := &ListMultipartUploadsRequest{Bucket: "bucket1"}
+
+	var page []ListUpload
+	token, err := iterator.NewPager(mpuc.Uploads(context.Background(), req), 2, "").NextPage(&page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 2 || token == "" {
+		t.Fatalf("got page of %d uploads and token %q, want 2 uploads and a token", len(page), token)
+	}
+
+	// A new iterator resumes from the token.
+	it := mpuc.Uploads(context.Background(), req)
+	it.PageInfo().Token = token
+	if diff := cmp.Diff([]string{"c"}, uploadKeys(t, it)); diff != "" {
+		t.Errorf("unexpected diff for keys (-want, +got):\n%s", diff)
+	}
+}
+
+func TestListMultipartUploadsPages(t *testing.T) {
+	tests := []struct {
+		name        string
+		omitMarkers bool
+		// stopAfter is the number of pages after which fn returns false, or
+		// zero.
+		stopAfter int
+		prefetch  bool
+		want      [][]string
+	}{
+		{name: "All pages", want: [][]string{{"a", "b"}, {"c", "d"}, {"e"}}},
+		{name: "Without markers", omitMarkers: true, want: [][]string{{"a", "b"}, {"c", "d"}, {"e"}}},
+		{name: "Stop", stopAfter: 2, want: [][]string{{"a", "b"}, {"c", "d"}}},
+		{name: "Prefetch", prefetch: true, want: [][]string{{"a", "b"}, {"c", "d"}, {"e"}}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pt := &pagingTransport{keys: []string{"a", "b", "c", "d", "e"}, omitMarkers: tc.omitMarkers}
+			var opts []Option
+			if tc.prefetch {
+				opts = append(opts, WithListPrefetch())
+			}
+			mpuc := New(&http.Client{Transport: pt}, opts...)
+			var got [][]string
+			err := mpuc.ListMultipartUploadsPages(context.Background(), &ListMultipartUploadsRequest{Bucket: "bucket1", MaxUploads: 2}, func(page *ListMultipartUploadsResult) bool {
+				var keys []string
+				for _, u := range page.Uploads {
+					keys = append(keys, u.Key)
+				}
+				got = append(got, keys)
+				return len(got) != tc.stopAfter
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected diff for pages (-want, +got):\n%s", diff)
+			}
+			if !tc.prefetch && len(pt.queries) != len(tc.want) {
+				t.Errorf("got %d requests, want one per page", len(pt.queries))
+			}
+		})
+	}
+}
+
+func TestListMultipartUploadsPagesStuck(t *testing.T) {
+	mpuc := New(&http.Client{Transport: funcTransport(func(*http.Request) (*http.Response, error) {
+		return xmlResponse("<ListMultipartUploadsResult><IsTruncated>true</IsTruncated></ListMultipartUploadsResult>"), nil
+	})})
+	pages := 0
+	err := mpuc.ListMultipartUploadsPages(context.Background(), &ListMultipartUploadsRequest{Bucket: "bucket1"}, func(*ListMultipartUploadsResult) bool {
+		pages++
+		return true
+	})
+	if err == nil || !strings.Contains(err.Error(), "doesn't continue") {
+		t.Errorf("got error %v, want one for a listing that doesn't continue", err)
+	}
+	if pages != 0 {
+		t.Errorf("got %d pages, want none", pages)
+	}
+}
+
+func TestListAllObjectParts(t *testing.T) {
+	for _, omitMarkers := range []bool{false, true} {
+		pt := &pagingTransport{partNumbers: []int{1, 2, 4, 7, 9}, omitMarkers: omitMarkers}
+		mpuc := New(&http.Client{Transport: pt})
+		parts, err := mpuc.ListAllObjectParts(context.Background(), &ListObjectPartsRequest{Bucket: "bucket1", Key: "object.txt", UploadID: "u1", PartNumberMarker: 1, MaxParts: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int
+		for _, part := range parts {
+			got = append(got, part.PartNumber)
+		}
+		if diff := cmp.Diff([]int{2, 4, 7, 9}, got); diff != "" {
+			t.Errorf("omitMarkers=%v: unexpected diff for parts (-want, +got):\n%s", omitMarkers, diff)
+		}
+		if len(pt.queries) != 2 {
+			t.Errorf("omitMarkers=%v: got %d requests, want 2", omitMarkers, len(pt.queries))
+		}
+	}
+}
+
+func TestListAllObjectPartsStuck(t *testing.T) {
+	mpuc := New(&http.Client{Transport: funcTransport(func(*http.Request) (*http.Response, error) {
+		return xmlResponse("<ListPartsResult><IsTruncated>true</IsTruncated><NextPartNumberMarker>3</NextPartNumberMarker></ListPartsResult>"), nil
+	})})
+	_, err := mpuc.ListAllObjectParts(context.Background(), &ListObjectPartsRequest{Bucket: "bucket1", Key: "object.txt", UploadID: "u1", PartNumberMarker: 3})
+	if err == nil || !strings.Contains(err.Error(), "doesn't continue") {
+		t.Errorf("got error %v, want one for a listing that doesn't continue", err)
+	}
+}
+
+func TestPartIterator(t *testing.T) {
+	partNumbers := []int{1, 2, 3, 5, 8}
+	for _, omitMarkers := range []bool{false, true} {
+		t.Run(fmt.Sprintf("omitMarkers=%v", omitMarkers), func(t *testing.T) {
+			pt := &pagingTransport{partNumbers: partNumbers, omitMarkers: omitMarkers}
+			mpuc := New(&http.Client{Transport: pt})
+
+			it := mpuc.Parts(context.Background(), &ListObjectPartsRequest{
+				Bucket:           "bucket1",
+				Key:              "object.txt",
+				UploadID:         "u1",
+				PartNumberMarker: 1,
+				MaxParts:         3,
+			})
+			var got []CompletePart
+			for {
+				part, err := it.Next()
+				if err == iterator.Done {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, *part)
+			}
+			want := []CompletePart{{2, "etag-2"}, {3, "etag-3"}, {5, "etag-5"}, {8, "etag-8"}}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("unexpected diff for parts (-want, +got):\n%s", diff)
+			}
+			wantQueries := []string{
+				"uploadId=u1&part-number-marker=1&max-parts=3",
+				"uploadId=u1&part-number-marker=5&max-parts=3",
+			}
+			if diff := cmp.Diff(wantQueries, pt.queries); diff != "" {
+				t.Errorf("unexpected diff for queries (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestIteratorError(t *testing.T) {
+	mpuc := New(&http.Client{Transport: funcTransport(func(*http.Request) (*http.Response, error) {
+		return nil, errMock
+	})})
+	if _, err := mpuc.Uploads(context.Background(), &ListMultipartUploadsRequest{Bucket: "bucket1"}).Next(); err == nil || err == iterator.Done {
+		t.Errorf("got error %v from UploadIterator, want the request's", err)
+	}
+	if _, err := mpuc.Parts(context.Background(), &ListObjectPartsRequest{Bucket: "bucket1", Key: "k", UploadID: "u1"}).Next(); err == nil || err == iterator.Done {
+		t.Errorf("got error %v from PartIterator, want the request's", err)
+	}
+}
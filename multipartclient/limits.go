@@ -0,0 +1,97 @@
+package multipartclient
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Limits of multipart uploads, as documented by Cloud Storage.
+const (
+	// MaxParts is the most parts an upload can have, and the largest part
+	// number.
+	MaxParts = 10000
+	// MinPartSize is the smallest size of every part of an upload but the
+	// last, which CompleteMultipartUpload enforces.
+	MinPartSize = 5 << 20
+	// MaxPartSize is the largest part.
+	MaxPartSize = 5 << 30
+	// MaxObjectSize is the largest object. It is less than MaxParts parts of
+	// MaxPartSize, so large objects are bound by it rather than by the part
+	// count.
+	MaxObjectSize = 5 << 40
+)
+
+// DefaultPlanPartSize is the part size PlanParts prefers unless given one.
+const DefaultPlanPartSize = 16 << 20
+
+// PartPlanOptions configures PlanParts.
+type PartPlanOptions struct {
+	// PartSize is the preferred size of every part but the last. PlanParts
+	// raises it to the next whole MiB that fits the object in MaxParts parts
+	// if it doesn't. It must be between MinPartSize and MaxPartSize, unless
+	// the object fits in one part. Defaults to DefaultPlanPartSize.
+	PartSize int64
+	// MaxParts bounds the parts of the plan, for example to leave part
+	// numbers for data appended later. It must not be more than the package's
+	// MaxParts, which is the default.
+	MaxParts int
+}
+
+// PartPlan is the split of an object into the parts of a multipart upload.
+type PartPlan struct {
+	// PartSize is the size of every part but the last, which may be smaller.
+	PartSize int64
+	// Parts are the byte ranges of the parts in order, so that part number
+	// i+1 is Parts[i]. They can be passed to Rewrite. An empty object has one
+	// empty part.
+	Parts []ByteRange
+}
+
+// PlanParts splits an object of totalSize bytes into parts within the limits
+// of multipart uploads, with the options opts, which may be nil. It returns an
+// error if the object can't be uploaded as planned, such as one larger than
+// MaxObjectSize, rather than a plan the server would reject.
+func PlanParts(totalSize int64, opts *PartPlanOptions) (*PartPlan, error) {
+	partSize, maxParts := int64(DefaultPlanPartSize), MaxParts
+	if opts != nil && opts.PartSize != 0 {
+		partSize = opts.PartSize
+	}
+	if opts != nil && opts.MaxParts != 0 {
+		maxParts = opts.MaxParts
+	}
+	switch {
+	case totalSize < 0:
+		return nil, fmt.Errorf("object size must not be negative, got %d", totalSize)
+	case totalSize > MaxObjectSize:
+		return nil, fmt.Errorf("object size %d is more than the %d bytes allowed", totalSize, int64(MaxObjectSize))
+	case partSize <= 0:
+		return nil, fmt.Errorf("part size must be positive, got %d", partSize)
+	case maxParts < 0 || maxParts > MaxParts:
+		return nil, fmt.Errorf("max parts must be between 1 and %d, got %d", MaxParts, maxParts)
+	}
+
+	if totalSize <= partSize && totalSize <= MaxPartSize {
+		return &PartPlan{PartSize: totalSize, Parts: []ByteRange{{Offset: 0, Length: totalSize}}}, nil
+	}
+	if partSize < MinPartSize || partSize > MaxPartSize {
+		return nil, fmt.Errorf("part size must be between %d and %d bytes, got %d", int64(MinPartSize), int64(MaxPartSize), partSize)
+	}
+	if parts := ceilDiv(totalSize, partSize); parts > int64(maxParts) {
+		const mib = 1 << 20
+		partSize = ceilDiv(ceilDiv(totalSize, int64(maxParts)), mib) * mib
+		if partSize > MaxPartSize {
+			return nil, errors.New("object doesn't fit in the allowed parts of at most MaxPartSize bytes")
+		}
+	}
+
+	plan := &PartPlan{PartSize: partSize, Parts: make([]ByteRange, 0, ceilDiv(totalSize, partSize))}
+	for off := int64(0); off < totalSize; off += partSize {
+		plan.Parts = append(plan.Parts, ByteRange{Offset: off, Length: min(partSize, totalSize-off)})
+	}
+	return plan, nil
+}
+
+// ceilDiv returns a/b rounded up, for positive b.
+func ceilDiv(a, b int64) int64 {
+	return (a + b - 1) / b
+}
@@ -0,0 +1,96 @@
+package multipartclient
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPlanParts(t *testing.T) {
+	const mib = 1 << 20
+	tests := []struct {
+		name      string
+		totalSize int64
+		opts      *PartPlanOptions
+		wantSize  int64
+		wantParts int
+		// wantLast is the length of the last part.
+		wantLast int64
+	}{
+		{name: "Empty", totalSize: 0, wantSize: 0, wantParts: 1, wantLast: 0},
+		{name: "One part", totalSize: 10 * mib, wantSize: 10 * mib, wantParts: 1, wantLast: 10 * mib},
+		{name: "Default part size", totalSize: 40 * mib, wantSize: 16 * mib, wantParts: 3, wantLast: 8 * mib},
+		{name: "Small part in one part", totalSize: 100, opts: &PartPlanOptions{PartSize: 1000}, wantSize: 100, wantParts: 1, wantLast: 100},
+		{name: "Exact multiple", totalSize: 20 * mib, opts: &PartPlanOptions{PartSize: 5 * mib}, wantSize: 5 * mib, wantParts: 4, wantLast: 5 * mib},
+		{
+			name:      "Raised to fit MaxParts",
+			totalSize: 1 << 40,
+			opts:      &PartPlanOptions{PartSize: MinPartSize},
+			wantSize:  105 * mib,
+			wantParts: 9987,
+			wantLast:  1<<40 - 9986*105*mib,
+		},
+		{
+			name:      "Raised to fit opts.MaxParts",
+			totalSize: 100 * mib,
+			opts:      &PartPlanOptions{PartSize: MinPartSize, MaxParts: 3},
+			wantSize:  34 * mib,
+			wantParts: 3,
+			wantLast:  32 * mib,
+		},
+		{
+			name:      "Largest object",
+			totalSize: MaxObjectSize,
+			wantSize:  525 * mib,
+			wantParts: 9987,
+			wantLast:  MaxObjectSize - 9986*525*mib,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			plan, err := PlanParts(tc.totalSize, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if plan.PartSize != tc.wantSize || len(plan.Parts) != tc.wantParts {
+				t.Fatalf("got %d parts of %d bytes, want %d of %d", len(plan.Parts), plan.PartSize, tc.wantParts, tc.wantSize)
+			}
+			var off int64
+			for i, part := range plan.Parts {
+				want := ByteRange{Offset: off, Length: plan.PartSize}
+				if i == len(plan.Parts)-1 {
+					want.Length = tc.wantLast
+				}
+				if diff := cmp.Diff(want, part); diff != "" {
+					t.Fatalf("part %d (-want +got):\n%s", i+1, diff)
+				}
+				off += part.Length
+			}
+			if off != tc.totalSize {
+				t.Errorf("parts cover %d bytes, want %d", off, tc.totalSize)
+			}
+		})
+	}
+}
+
+func TestPlanPartsErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		totalSize int64
+		opts      *PartPlanOptions
+	}{
+		{name: "Negative size", totalSize: -1},
+		{name: "Over MaxObjectSize", totalSize: MaxObjectSize + 1},
+		{name: "Part under MinPartSize", totalSize: 10 << 20, opts: &PartPlanOptions{PartSize: 1 << 20}},
+		{name: "Part over MaxPartSize", totalSize: 6 << 30, opts: &PartPlanOptions{PartSize: 6 << 30}},
+		{name: "Too many MaxParts", totalSize: 10 << 20, opts: &PartPlanOptions{MaxParts: MaxParts + 1}},
+		{name: "Doesn't fit", totalSize: 11 << 30, opts: &PartPlanOptions{MaxParts: 2}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if plan, err := PlanParts(tc.totalSize, tc.opts); err == nil {
+				t.Errorf("got %d parts of %d bytes, want an error", len(plan.Parts), plan.PartSize)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package multipartclient
+
+// WithListPrefetch makes the iterators of Uploads and Parts, and
+// ListMultipartUploadsPages, request the next page of a listing as soon as
+// they get a truncated one, so the request overlaps the caller's processing
+// of the page. Listings of many pages finish up to twice as fast, at the cost
+// of requesting a page that isn't used when the caller stops early or changes
+// the page size.
+func WithListPrefetch() Option {
+	return func(mpuc *MultipartClient) {
+		mpuc.listPrefetch = true
+	}
+}
+
+// prefetcher lists pages with list and, if enabled, starts listing the next
+// page in the background with prefetch. It isn't safe for concurrent use.
+type prefetcher[Req comparable, Res any] struct {
+	enabled bool
+	list    func(Req) (*Res, error)
+	pending *pendingPage[Req, Res]
+}
+
+// pendingPage is a page being listed in the background.
+type pendingPage[Req comparable, Res any] struct {
+	req    Req
+	done   chan struct{}
+	result *Res
+	err    error
+}
+
+func newPrefetcher[Req comparable, Res any](mpuc *MultipartClient, list func(Req) (*Res, error)) *prefetcher[Req, Res] {
+	return &prefetcher[Req, Res]{enabled: mpuc.listPrefetch, list: list}
+}
+
+// get returns the page listed by req, waiting for it if it was prefetched.
+// A prefetched page of another request is dropped.
+func (p *prefetcher[Req, Res]) get(req Req) (*Res, error) {
+	pending := p.pending
+	p.pending = nil
+	if pending != nil && pending.req == req {
+		<-pending.done
+		return pending.result, pending.err
+	}
+	return p.list(req)
+}
+
+// prefetch starts listing the page of req in the background, if enabled.
+func (p *prefetcher[Req, Res]) prefetch(req Req) {
+	if !p.enabled {
+		return
+	}
+	pending := &pendingPage[Req, Res]{req: req, done: make(chan struct{})}
+	go func() {
+		defer close(pending.done)
+		pending.result, pending.err = p.list(req)
+	}()
+	p.pending = pending
+}
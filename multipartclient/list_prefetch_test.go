@@ -0,0 +1,51 @@
+package multipartclient
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/iterator"
+)
+
+func TestWithListPrefetch(t *testing.T) {
+	pt := &pagingTransport{keys: []string{"a", "b", "c", "d", "e"}, partNumbers: []int{1, 2, 3}}
+	requested := make(chan string, 10)
+	mpuc := New(&http.Client{Transport: funcTransport(func(req *http.Request) (*http.Response, error) {
+		requested <- req.URL.RawQuery
+		return pt.RoundTrip(req)
+	})}, WithListPrefetch())
+
+	it := mpuc.Uploads(context.Background(), &ListMultipartUploadsRequest{Bucket: "bucket1", MaxUploads: 2})
+	if _, err := it.Next(); err != nil {
+		t.Fatal(err)
+	}
+	// The second page is requested while the first is being consumed.
+	<-requested
+	if got, want := <-requested, "uploads&key-marker=b&upload-id-marker=id-b&max-uploads=2"; got != want {
+		t.Errorf("got prefetch query %q, want %q", got, want)
+	}
+	if diff := cmp.Diff([]string{"b", "c", "d", "e"}, uploadKeys(t, it)); diff != "" {
+		t.Errorf("unexpected diff for keys (-want, +got):\n%s", diff)
+	}
+	if n := len(pt.queries); n != 3 {
+		t.Errorf("got %d requests, want 3", n)
+	}
+
+	parts := mpuc.Parts(context.Background(), &ListObjectPartsRequest{Bucket: "bucket1", Key: "object.txt", UploadID: "u1", MaxParts: 2})
+	var got []int
+	for {
+		part, err := parts.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, part.PartNumber)
+	}
+	if diff := cmp.Diff([]int{1, 2, 3}, got); diff != "" {
+		t.Errorf("unexpected diff for parts (-want, +got):\n%s", diff)
+	}
+}
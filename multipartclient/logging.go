@@ -0,0 +1,110 @@
+package multipartclient
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// LogOptions configures the logging enabled by WithLogger.
+type LogOptions struct {
+	// SuccessLevel is the level of records for successful requests. Defaults
+	// to slog.LevelDebug.
+	SuccessLevel slog.Leveler
+	// FailureLevel is the level of records for failed requests. Defaults to
+	// slog.LevelWarn.
+	FailureLevel slog.Leveler
+	// Headers adds request and response headers to records, with credentials
+	// and encryption keys redacted.
+	Headers bool
+	// Sampling, if set, logs only a selection of requests, with full detail,
+	// so high-QPS clients keep diagnostics without logging every request.
+	Sampling *LogSampling
+}
+
+// LogSampling selects the requests logged when set in LogOptions. Selected
+// requests are logged with headers, as if LogOptions.Headers were set; other
+// requests are not logged.
+type LogSampling struct {
+	// Rate is the fraction of requests logged, from 0 to 1.
+	Rate float64
+	// Failures logs every failed request, regardless of Rate.
+	Failures bool
+}
+
+// sampled reports whether a request that failed if err is non-nil is
+// selected by s. random returns a number in [0, 1).
+func (s *LogSampling) sampled(err error, random func() float64) bool {
+	if err != nil && s.Failures {
+		return true
+	}
+	return random() < s.Rate
+}
+
+// logRequest logs the outcome of a request sent for the operation op.
+func (mpuc *MultipartClient) logRequest(ctx context.Context, op string, req *http.Request, resp *http.Response, err error, elapsed time.Duration) {
+	if mpuc.logger == nil {
+		return
+	}
+	headers := mpuc.logOpts.Headers
+	if sampling := mpuc.logOpts.Sampling; sampling != nil {
+		if !sampling.sampled(err, mpuc.random) {
+			return
+		}
+		headers = true
+	}
+	level := slog.LevelDebug
+	if mpuc.logOpts.SuccessLevel != nil {
+		level = mpuc.logOpts.SuccessLevel.Level()
+	}
+	if err != nil {
+		level = slog.LevelWarn
+		if mpuc.logOpts.FailureLevel != nil {
+			level = mpuc.logOpts.FailureLevel.Level()
+		}
+	}
+	if !mpuc.logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("op", op),
+		slog.String("method", req.Method),
+		slog.String("url", redactURL(req.URL)),
+		slog.Duration("elapsed", elapsed),
+	}
+	if resp != nil {
+		attrs = append(attrs, slog.Int("status", resp.StatusCode))
+	}
+	c := correlationOf(ctx, resp)
+	if c.CorrelationID != "" {
+		attrs = append(attrs, slog.String("correlation_id", c.CorrelationID))
+	}
+	if c.ServerRequestID != "" {
+		attrs = append(attrs, slog.String("server_request_id", c.ServerRequestID))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	if headers {
+		attrs = append(attrs, headerAttr("request_headers", req.Header))
+		if resp != nil {
+			attrs = append(attrs, headerAttr("response_headers", resp.Header))
+		}
+	}
+	mpuc.logger.LogAttrs(ctx, level, "gcs multipart request", attrs...)
+}
+
+func headerAttr(key string, h http.Header) slog.Attr {
+	h = redactHeader(h)
+	attrs := make([]any, 0, len(h))
+	for name, values := range h {
+		if len(values) == 1 {
+			attrs = append(attrs, slog.String(name, values[0]))
+		} else {
+			attrs = append(attrs, slog.Any(name, values))
+		}
+	}
+	return slog.Group(key, attrs...)
+}
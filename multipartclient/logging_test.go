@@ -0,0 +1,171 @@
+package multipartclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestWithLogger(t *testing.T) {
+	tests := []struct {
+		name       string
+		respStatus int
+		opts       LogOptions
+		want       map[string]any
+	}{
+		{
+			name:       "Success logged at debug",
+			respStatus: http.StatusNoContent,
+			want: map[string]any{
+				"level":  "DEBUG",
+				"msg":    "gcs multipart request",
+				"op":     OpAbortMultipartUpload,
+				"method": "DELETE",
+				"url":    "https://storage.googleapis.com/bucket1/file1.txt?uploadId=my-upload-id",
+				"status": float64(http.StatusNoContent),
+
+				"server_request_id": "server-id",
+			},
+		},
+		{
+			name:       "Failure with headers",
+			respStatus: http.StatusNotFound,
+			opts:       LogOptions{FailureLevel: slog.LevelError, Headers: true},
+			want: map[string]any{
+				"level":  "ERROR",
+				"msg":    "gcs multipart request",
+				"op":     OpAbortMultipartUpload,
+				"method": "DELETE",
+				"url":    "https://storage.googleapis.com/bucket1/file1.txt?uploadId=my-upload-id",
+				"status": float64(http.StatusNotFound),
+				"error":  "Not Found",
+
+				"server_request_id": "server-id",
+				"request_headers": map[string]any{
+					"Authorization":         "REDACTED",
+					"X-Goog-Encryption-Key": "REDACTED",
+				},
+				"response_headers": map[string]any{
+					"X-Guploader-Uploadid": "server-id",
+				},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: tc.respStatus,
+					Status:     http.StatusText(tc.respStatus),
+					Header:     http.Header{"X-Guploader-Uploadid": []string{"server-id"}},
+					Body:       http.NoBody,
+				}, nil
+			})
+			// Stand in for an authenticating transport.
+			authTrans := funcTransport(func(req *http.Request) (*http.Response, error) {
+				req.Header.Set("Authorization", "Bearer secret-token")
+				req.Header.Set("X-Goog-Encryption-Key", "secret-key")
+				return trans(req)
+			})
+
+			buf := &bytes.Buffer{}
+			logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			mpuc := New(&http.Client{Transport: authTrans}, WithLogger(logger, tc.opts))
+			_ = mpuc.AbortMultipartUpload(context.Background(), &AbortMultipartUploadRequest{
+				Bucket:   "bucket1",
+				Key:      "file1.txt",
+				UploadID: "my-upload-id",
+			})
+
+			got := map[string]any{}
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("failed to parse log %q: %v", buf.String(), err)
+			}
+			opts := cmpopts.IgnoreMapEntries(func(k string, _ any) bool { return k == "time" || k == "elapsed" })
+			if diff := cmp.Diff(tc.want, got, opts); diff != "" {
+				t.Errorf("unexpected diff for log record: (-want, +got):\n%s", diff)
+			}
+			if bytes.Contains(buf.Bytes(), []byte("secret")) {
+				t.Errorf("log contains a secret: %s", buf.String())
+			}
+		})
+	}
+}
+
+func TestLogSampling(t *testing.T) {
+	tests := []struct {
+		name        string
+		sampling    LogSampling
+		random      float64
+		respStatus  int
+		wantLogged  bool
+		wantHeaders bool
+	}{
+		{
+			name:        "Success selected",
+			sampling:    LogSampling{Rate: 0.1},
+			random:      0.05,
+			respStatus:  http.StatusNoContent,
+			wantLogged:  true,
+			wantHeaders: true,
+		},
+		{
+			name:       "Success not selected",
+			sampling:   LogSampling{Rate: 0.1},
+			random:     0.5,
+			respStatus: http.StatusNoContent,
+		},
+		{
+			name:        "Failure always logged",
+			sampling:    LogSampling{Rate: 0.1, Failures: true},
+			random:      0.5,
+			respStatus:  http.StatusNotFound,
+			wantLogged:  true,
+			wantHeaders: true,
+		},
+		{
+			name:       "Failure not selected",
+			sampling:   LogSampling{Rate: 0.1},
+			random:     0.5,
+			respStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: tc.respStatus,
+					Status:     http.StatusText(tc.respStatus),
+					Header:     http.Header{"X-Guploader-Uploadid": []string{"server-id"}},
+					Body:       http.NoBody,
+				}, nil
+			})
+
+			buf := &bytes.Buffer{}
+			logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			mpuc := New(&http.Client{Transport: trans},
+				WithLogger(logger, LogOptions{Sampling: &tc.sampling}),
+				WithRandom(func() float64 { return tc.random }))
+			_ = mpuc.AbortMultipartUpload(context.Background(), &AbortMultipartUploadRequest{
+				Bucket:   "bucket1",
+				Key:      "file1.txt",
+				UploadID: "my-upload-id",
+			})
+
+			if gotLogged := buf.Len() > 0; gotLogged != tc.wantLogged {
+				t.Fatalf("got logged %v, want %v: %s", gotLogged, tc.wantLogged, buf.String())
+			}
+			if gotHeaders := bytes.Contains(buf.Bytes(), []byte("response_headers")); gotHeaders != tc.wantHeaders {
+				t.Errorf("got headers logged %v, want %v: %s", gotHeaders, tc.wantHeaders, buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package multipartclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+	"google.golang.org/api/googleapi"
+)
+
+// maxJSONResponseBytes bounds the JSON API object resource decoded from a
+// response, which holds at most a few KiB of custom metadata.
+const maxJSONResponseBytes = 1 << 20
+
+// ObjectMetadataPatch are the changes PatchObjectMetadata makes to the
+// metadata of an object. Nil fields are left unchanged; a pointer to "" clears
+// the field.
+type ObjectMetadataPatch struct {
+	ContentType        *string
+	ContentDisposition *string
+	ContentEncoding    *string
+	ContentLanguage    *string
+	CacheControl       *string
+	// Metadata sets the given custom metadata keys, or deletes those with an
+	// empty value. Other keys are left unchanged.
+	Metadata map[string]string
+}
+
+// MarshalJSON encodes p as a JSON API object resource with only the patched
+// fields.
+func (p ObjectMetadataPatch) MarshalJSON() ([]byte, error) {
+	fields := map[string]any{}
+	for name, v := range map[string]*string{
+		"contentType":        p.ContentType,
+		"contentDisposition": p.ContentDisposition,
+		"contentEncoding":    p.ContentEncoding,
+		"contentLanguage":    p.ContentLanguage,
+		"cacheControl":       p.CacheControl,
+	} {
+		if v == nil {
+			continue
+		}
+		if *v == "" {
+			fields[name] = nil
+		} else {
+			fields[name] = *v
+		}
+	}
+	if len(p.Metadata) > 0 {
+		metadata := map[string]any{}
+		for k, v := range p.Metadata {
+			if v == "" {
+				metadata[k] = nil
+			} else {
+				metadata[k] = v
+			}
+		}
+		fields["metadata"] = metadata
+	}
+	return json.Marshal(fields)
+}
+
+type PatchObjectMetadataRequest struct {
+	Bucket string
+	Key    string
+	// IfGenerationMatch, if not zero, fails the patch unless the object is
+	// at this generation, such as the one CompleteMultipartUpload created.
+	IfGenerationMatch int64
+	Patch             ObjectMetadataPatch
+}
+
+// PatchObjectMetadata changes the metadata of an object, such as one just
+// assembled by CompleteMultipartUpload, which the XML API can only do by
+// rewriting it. The request is sent to the JSON API of the client's endpoint,
+// so it isn't supported with S3Compatibility. It returns the updated
+// attributes, or ErrObjectNotExist if there is no such object.
+func (mpuc *MultipartClient) PatchObjectMetadata(ctx context.Context, req *PatchObjectMetadataRequest) (attrs *ObjectAttrs, err error) {
+	ctx = mpuc.startOperation(ctx, OpPatchObjectMetadata)
+	defer func(start time.Time) {
+		err = mpuc.operationDone(ctx, OpPatchObjectMetadata, req, operationInfo{Bucket: req.Bucket, Key: req.Key}, start, err)
+	}(mpuc.clock.Now())
+
+	if err := mpuc.validate(OpPatchObjectMetadata, req); err != nil {
+		return nil, err
+	}
+	if mpuc.compat != nil {
+		return nil, errors.New("PatchObjectMetadata needs the Cloud Storage JSON API, which S3-compatible servers don't have")
+	}
+	body, err := json.Marshal(req.Patch)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPatch, mpuc.jsonObjectURL(req.Bucket, req.Key, req.IfGenerationMatch), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	mpuc.setUserProject(ctx, httpReq, req.Bucket)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := mpuc.do(ctx, OpPatchObjectMetadata, httpReq)
+	defer googleapi.CloseBody(resp)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, ErrObjectNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var obj jsonObject
+	if err := json.NewDecoder(&limitedReader{r: resp.Body, n: maxJSONResponseBytes}).Decode(&obj); err != nil {
+		return nil, fmt.Errorf("failed to decode JSON API response: %w", err)
+	}
+	attrs, err = obj.attrs()
+	if err != nil {
+		return nil, err
+	}
+	attrs.Correlation = correlationOf(ctx, resp)
+	return attrs, nil
+}
+
+// jsonObjectURL returns the JSON API URL of an object on the client's
+// endpoint.
+func (mpuc *MultipartClient) jsonObjectURL(bucket, key string, ifGenerationMatch int64) string {
+	u := fmt.Sprintf("%s/storage/v1/b/%s/o/%s", mpuc.endpointURL(), url.PathEscape(bucket), url.PathEscape(key))
+	if ifGenerationMatch != 0 {
+		u += "?ifGenerationMatch=" + strconv.FormatInt(ifGenerationMatch, 10)
+	}
+	return u
+}
+
+// jsonObject is the part of a JSON API object resource read into ObjectAttrs.
+// The API encodes 64-bit integers as strings.
+type jsonObject struct {
+	Bucket         string            `json:"bucket"`
+	Name           string            `json:"name"`
+	Size           int64             `json:"size,string"`
+	ETag           string            `json:"etag"`
+	Generation     int64             `json:"generation,string"`
+	Metageneration int64             `json:"metageneration,string"`
+	ContentType    string            `json:"contentType"`
+	StorageClass   string            `json:"storageClass"`
+	Updated        time.Time         `json:"updated"`
+	CRC32C         string            `json:"crc32c"`
+	MD5Hash        string            `json:"md5Hash"`
+	Metadata       map[string]string `json:"metadata"`
+}
+
+func (o *jsonObject) attrs() (*ObjectAttrs, error) {
+	attrs := &ObjectAttrs{
+		Bucket:         o.Bucket,
+		Key:            o.Name,
+		Size:           o.Size,
+		ETag:           o.ETag,
+		Generation:     o.Generation,
+		Metageneration: o.Metageneration,
+		ContentType:    o.ContentType,
+		StorageClass:   o.StorageClass,
+		LastModified:   o.Updated,
+		Metadata:       o.Metadata,
+	}
+	if o.CRC32C != "" {
+		sum, err := gcshash.DecodeCRC32C(o.CRC32C)
+		if err != nil {
+			return nil, fmt.Errorf("invalid crc32c %q in JSON API response: %w", o.CRC32C, err)
+		}
+		attrs.Sums.CRC32C, attrs.Sums.HasCRC32C = sum, true
+	}
+	if o.MD5Hash != "" {
+		b, err := base64.StdEncoding.DecodeString(o.MD5Hash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid md5Hash %q in JSON API response", o.MD5Hash)
+		}
+		attrs.Sums.MD5 = b
+	}
+	return attrs, nil
+}
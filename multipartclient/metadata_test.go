@@ -0,0 +1,130 @@
+package multipartclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+)
+
+func TestObjectMetadataPatchJSON(t *testing.T) {
+	contentType, cacheControl := "text/plain", ""
+	patch := ObjectMetadataPatch{
+		ContentType:  &contentType,
+		CacheControl: &cacheControl,
+		Metadata:     map[string]string{"owner": "team1", "stale": ""},
+	}
+	b, err := json.Marshal(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `{"cacheControl":null,"contentType":"text/plain","metadata":{"owner":"team1","stale":null}}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestPatchObjectMetadata(t *testing.T) {
+	const respBody = `{
+		"kind": "storage#object",
+		"bucket": "bucket1",
+		"name": "dir/object.txt",
+		"size": "11",
+		"etag": "CIGAgICAgICAAhAC",
+		"generation": "1700000000000001",
+		"metageneration": "2",
+		"contentType": "text/plain",
+		"storageClass": "STANDARD",
+		"updated": "2024-03-10T12:00:00.000Z",
+		"crc32c": "yZRlqg==",
+		"metadata": {"owner": "team1"}
+	}`
+	tests := []struct {
+		name     string
+		endpoint string
+		resp     *http.Response
+		wantURL  string
+		want     *ObjectAttrs
+		wantErr  error
+	}{
+		{
+			name:    "Patched",
+			resp:    &http.Response{StatusCode: http.StatusOK, Body: toBody(respBody)},
+			wantURL: "https://storage.googleapis.com/storage/v1/b/bucket1/o/dir%2Fobject.txt?ifGenerationMatch=1700000000000001",
+			want: &ObjectAttrs{
+				Bucket:         "bucket1",
+				Key:            "dir/object.txt",
+				Size:           11,
+				ETag:           "CIGAgICAgICAAhAC",
+				Generation:     1700000000000001,
+				Metageneration: 2,
+				ContentType:    "text/plain",
+				StorageClass:   "STANDARD",
+				LastModified:   time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC),
+				Metadata:       map[string]string{"owner": "team1"},
+				Sums:           gcshash.Sums{CRC32C: gcshash.CRC32C([]byte("hello world")), HasCRC32C: true},
+			},
+		},
+		{
+			name:     "Custom endpoint",
+			endpoint: "http://localhost:8080",
+			resp:     &http.Response{StatusCode: http.StatusOK, Body: toBody(`{"bucket": "bucket1", "name": "dir/object.txt"}`)},
+			wantURL:  "http://localhost:8080/storage/v1/b/bucket1/o/dir%2Fobject.txt?ifGenerationMatch=1700000000000001",
+			want:     &ObjectAttrs{Bucket: "bucket1", Key: "dir/object.txt"},
+		},
+		{
+			name:    "Not found",
+			resp:    &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found", Body: toBody(`{"error": {"code": 404}}`)},
+			wantURL: "https://storage.googleapis.com/storage/v1/b/bucket1/o/dir%2Fobject.txt?ifGenerationMatch=1700000000000001",
+			wantErr: ErrObjectNotExist,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotReq *http.Request
+			var gotBody []byte
+			trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+				gotReq = req
+				gotBody, _ = io.ReadAll(req.Body)
+				return tc.resp, nil
+			})
+			mpuc := New(&http.Client{Transport: trans}, WithEndpoint(tc.endpoint))
+
+			contentType := "text/plain"
+			got, err := mpuc.PatchObjectMetadata(context.Background(), &PatchObjectMetadataRequest{
+				Bucket:            "bucket1",
+				Key:               "dir/object.txt",
+				IfGenerationMatch: 1700000000000001,
+				Patch:             ObjectMetadataPatch{ContentType: &contentType},
+			})
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("got error %v, want %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected diff for attrs: (-want, +got):\n%s", diff)
+			}
+			if gotReq.Method != http.MethodPatch || gotReq.URL.String() != tc.wantURL {
+				t.Errorf("got request %s %s, want PATCH %s", gotReq.Method, gotReq.URL, tc.wantURL)
+			}
+			if got, want := string(gotBody), `{"contentType":"text/plain"}`; got != want {
+				t.Errorf("got body %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestPatchObjectMetadataS3Compatibility(t *testing.T) {
+	trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+		t.Errorf("got request %s %s, want none", req.Method, req.URL)
+		return nil, errors.New("unexpected request")
+	})
+	mpuc := New(&http.Client{Transport: trans}, WithS3Compatibility(S3Compatibility{Endpoint: "http://localhost:9000"}))
+	if _, err := mpuc.PatchObjectMetadata(context.Background(), &PatchObjectMetadataRequest{Bucket: "bucket1", Key: "object.txt"}); err == nil {
+		t.Error("got no error patching metadata on an S3-compatible server")
+	}
+}
@@ -0,0 +1,71 @@
+package multipartclient
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Operation names reported to Metrics.
+const (
+	OpInitiateMultipartUpload = "InitiateMultipartUpload"
+	OpUploadObjectPart        = "UploadObjectPart"
+	OpUploadPartCopy          = "UploadPartCopy"
+	OpCompleteMultipartUpload = "CompleteMultipartUpload"
+	OpAbortMultipartUpload    = "AbortMultipartUpload"
+	OpListMultipartUploads    = "ListMultipartUploads"
+	OpListObjectParts         = "ListObjectParts"
+	OpHealthCheck             = "HealthCheck"
+	OpStatObject              = "StatObject"
+	OpPatchObjectMetadata     = "PatchObjectMetadata"
+)
+
+// Metrics receives measurements of the client's requests. Implementations
+// must be safe for concurrent use. See the prommetrics package for a
+// Prometheus implementation.
+type Metrics interface {
+	// RequestDone records a finished HTTP request for the operation op.
+	// statusCode is 0 if no response was received.
+	RequestDone(op string, statusCode int, latency time.Duration)
+	// BytesUploaded records n bytes of request body sent for op.
+	BytesUploaded(op string, n int64)
+	// RequestRetried records that a request for op is being sent again.
+	RequestRetried(op string)
+	// PartsInFlight adds delta to the number of part uploads and copies in
+	// progress.
+	PartsInFlight(delta int)
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) RequestDone(string, int, time.Duration) {}
+func (nopMetrics) BytesUploaded(string, int64)            {}
+func (nopMetrics) RequestRetried(string)                  {}
+func (nopMetrics) PartsInFlight(int)                      {}
+
+// countingReader counts the bytes read through it. The count may be read
+// while the HTTP transport is still reading, so it is atomic.
+type countingReader struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n.Add(int64(n))
+	return n, err
+}
+
+// Close closes the wrapped reader if it is an io.Closer, so the HTTP client
+// still closes request bodies it is given.
+func (cr *countingReader) Close() error {
+	if c, ok := cr.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// readerOnly hides the methods of a reader other than Read, such as Close.
+type readerOnly struct {
+	io.Reader
+}
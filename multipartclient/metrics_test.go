@@ -0,0 +1,103 @@
+package multipartclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+)
+
+type recordingMetrics struct {
+	mu          sync.Mutex
+	requests    []string
+	bytes       map[string]int64
+	retries     map[string]int
+	inFlight    int
+	maxInFlight int
+}
+
+func newRecordingMetrics() *recordingMetrics {
+	return &recordingMetrics{bytes: map[string]int64{}, retries: map[string]int{}}
+}
+
+func (m *recordingMetrics) RequestDone(op string, statusCode int, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, op+" "+http.StatusText(statusCode))
+}
+
+func (m *recordingMetrics) BytesUploaded(op string, n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bytes[op] += n
+}
+
+func (m *recordingMetrics) RequestRetried(op string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retries[op]++
+}
+
+func (m *recordingMetrics) PartsInFlight(delta int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight += delta
+	m.maxInFlight = max(m.maxInFlight, m.inFlight)
+}
+
+func TestMetrics(t *testing.T) {
+	const contents = "part contents"
+	uploads := 0
+	trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodDelete {
+			return &http.Response{StatusCode: http.StatusNotFound, Status: "Not Found", Body: http.NoBody}, nil
+		}
+		if _, err := io.Copy(io.Discard, req.Body); err != nil {
+			t.Fatal(err)
+		}
+		uploads++
+		resp := &http.Response{StatusCode: http.StatusOK, Status: "OK", Header: http.Header{}, Body: http.NoBody}
+		// Report a corrupt part on the first upload to cause a retry.
+		if uploads == 1 {
+			gcshash.Sums{CRC32C: 1, HasCRC32C: true}.SetHeader(resp.Header)
+		}
+		return resp, nil
+	})
+
+	metrics := newRecordingMetrics()
+	mpuc := New(&http.Client{Transport: trans}, WithMetrics(metrics))
+	ctx := context.Background()
+	if _, err := mpuc.UploadObjectPart(ctx, &UploadObjectPartRequest{
+		Bucket:          "bucket1",
+		Key:             "object.txt",
+		PartNumber:      1,
+		UploadID:        "my-upload-id",
+		Body:            &seekableBody{Reader: strings.NewReader(contents)},
+		VerifyChecksums: true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mpuc.AbortMultipartUpload(ctx, &AbortMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt", UploadID: "my-upload-id"}); err == nil {
+		t.Fatal("expected abort error")
+	}
+
+	wantRequests := []string{"UploadObjectPart OK", "UploadObjectPart OK", "AbortMultipartUpload Not Found"}
+	if diff := cmp.Diff(wantRequests, metrics.requests); diff != "" {
+		t.Errorf("unexpected diff for requests: (-want, +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]int64{OpUploadObjectPart: 2 * int64(len(contents))}, metrics.bytes); diff != "" {
+		t.Errorf("unexpected diff for uploaded bytes: (-want, +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]int{OpUploadObjectPart: 1}, metrics.retries); diff != "" {
+		t.Errorf("unexpected diff for retries: (-want, +got):\n%s", diff)
+	}
+	if metrics.inFlight != 0 || metrics.maxInFlight != 1 {
+		t.Errorf("got in-flight parts %d (max %d), want 0 (max 1)", metrics.inFlight, metrics.maxInFlight)
+	}
+}
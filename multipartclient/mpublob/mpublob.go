@@ -0,0 +1,384 @@
+// Package mpublob provides a gocloud.dev/blob driver that writes objects with
+// XML API multipart uploads, sending several parts at once, instead of the
+// single stream of gcsblob. Every other operation, such as reads, listing and
+// deletes, is served by another bucket for the same Cloud Storage bucket,
+// typically one opened with gcsblob:
+//
+//	base, err := gcsblob.OpenBucket(ctx, gcsClient, "my-bucket", nil)
+//	...
+//	bucket := mpublob.OpenBucket(mpuc, "my-bucket", base, nil)
+//	defer bucket.Close()
+//
+// Writes don't yet set the content type or other attributes from
+// blob.WriterOptions; objects are created with the server's defaults.
+package mpublob
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"runtime/pprof"
+	"sort"
+	"sync"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+	"gocloud.dev/blob"
+	"gocloud.dev/blob/driver"
+	"gocloud.dev/gcerrors"
+)
+
+const (
+	// DefaultPartSize is the size of every part but the last if Options
+	// doesn't set one.
+	DefaultPartSize = 16 << 20
+	// DefaultConcurrency is the number of parts of a write sent at once if
+	// Options doesn't set it.
+	DefaultConcurrency = 4
+)
+
+// Options configures writes to a bucket opened with OpenBucket.
+type Options struct {
+	// PartSize is the size of every part but the last; Cloud Storage
+	// requires at least 5 MiB. blob.WriterOptions.BufferSize overrides it
+	// for a write. Defaults to DefaultPartSize.
+	PartSize int
+	// Concurrency is the number of parts of a write sent at once, each
+	// buffered in memory. blob.WriterOptions.MaxConcurrency overrides it for
+	// a write. Defaults to DefaultConcurrency.
+	Concurrency int
+}
+
+// OpenBucket returns a bucket for the Cloud Storage bucket named bucket that
+// writes objects with mpuc and serves all other operations with base.
+// Closing the returned bucket closes base.
+//
+// The As method of writes' BeforeWrite callback supports
+// **multipartclient.InitiateMultipartUploadRequest, to adjust the request
+// that starts the upload.
+func OpenBucket(mpuc *multipartclient.MultipartClient, bucket string, base *blob.Bucket, opts *Options) *blob.Bucket {
+	b := &bucketDriver{mpuc: mpuc, name: bucket, base: base, partSize: DefaultPartSize, concurrency: DefaultConcurrency}
+	if opts != nil && opts.PartSize > 0 {
+		b.partSize = opts.PartSize
+	}
+	if opts != nil && opts.Concurrency > 0 {
+		b.concurrency = opts.Concurrency
+	}
+	return blob.NewBucket(b)
+}
+
+// bucketDriver implements driver.Bucket.
+type bucketDriver struct {
+	mpuc        *multipartclient.MultipartClient
+	name        string
+	base        *blob.Bucket
+	partSize    int
+	concurrency int
+}
+
+func (b *bucketDriver) ErrorCode(err error) gcerrors.ErrorCode {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return gcerrors.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return gcerrors.DeadlineExceeded
+	}
+	var apiErr *multipartclient.Error
+	if errors.As(err, &apiErr) {
+		return errorCodeOfStatus(apiErr.StatusCode)
+	}
+	// Errors of base already carry a code.
+	return gcerrors.Code(err)
+}
+
+// errorCodeOfStatus returns the code of an error response with status.
+func errorCodeOfStatus(status int) gcerrors.ErrorCode {
+	switch status {
+	case http.StatusBadRequest:
+		return gcerrors.InvalidArgument
+	case http.StatusForbidden, http.StatusUnauthorized:
+		return gcerrors.PermissionDenied
+	case http.StatusNotFound:
+		return gcerrors.NotFound
+	case http.StatusPreconditionFailed, http.StatusConflict:
+		return gcerrors.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return gcerrors.ResourceExhausted
+	}
+	return gcerrors.Unknown
+}
+
+func (b *bucketDriver) As(i any) bool {
+	if p, ok := i.(**multipartclient.MultipartClient); ok {
+		*p = b.mpuc
+		return true
+	}
+	return b.base.As(i)
+}
+
+func (b *bucketDriver) ErrorAs(err error, i any) bool {
+	return b.base.ErrorAs(err, i)
+}
+
+func (b *bucketDriver) Attributes(ctx context.Context, key string) (*driver.Attributes, error) {
+	a, err := b.base.Attributes(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return &driver.Attributes{
+		CacheControl:       a.CacheControl,
+		ContentDisposition: a.ContentDisposition,
+		ContentEncoding:    a.ContentEncoding,
+		ContentLanguage:    a.ContentLanguage,
+		ContentType:        a.ContentType,
+		Metadata:           a.Metadata,
+		CreateTime:         a.CreateTime,
+		ModTime:            a.ModTime,
+		Size:               a.Size,
+		MD5:                a.MD5,
+		ETag:               a.ETag,
+		AsFunc:             a.As,
+	}, nil
+}
+
+func (b *bucketDriver) ListPaged(ctx context.Context, opts *driver.ListOptions) (*driver.ListPage, error) {
+	token := opts.PageToken
+	if len(token) == 0 {
+		token = blob.FirstPageToken
+	}
+	objs, next, err := b.base.ListPage(ctx, token, opts.PageSize, &blob.ListOptions{
+		Prefix:     opts.Prefix,
+		Delimiter:  opts.Delimiter,
+		BeforeList: opts.BeforeList,
+	})
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	page := &driver.ListPage{NextPageToken: next}
+	for _, o := range objs {
+		page.Objects = append(page.Objects, &driver.ListObject{
+			Key:     o.Key,
+			ModTime: o.ModTime,
+			Size:    o.Size,
+			MD5:     o.MD5,
+			IsDir:   o.IsDir,
+			AsFunc:  o.As,
+		})
+	}
+	return page, nil
+}
+
+func (b *bucketDriver) NewRangeReader(ctx context.Context, key string, offset, length int64, opts *driver.ReaderOptions) (driver.Reader, error) {
+	r, err := b.base.NewRangeReader(ctx, key, offset, length, &blob.ReaderOptions{BeforeRead: opts.BeforeRead})
+	if err != nil {
+		return nil, err
+	}
+	return &reader{r: r}, nil
+}
+
+func (b *bucketDriver) Copy(ctx context.Context, dstKey, srcKey string, opts *driver.CopyOptions) error {
+	return b.base.Copy(ctx, dstKey, srcKey, &blob.CopyOptions{BeforeCopy: opts.BeforeCopy})
+}
+
+func (b *bucketDriver) Delete(ctx context.Context, key string) error {
+	return b.base.Delete(ctx, key)
+}
+
+func (b *bucketDriver) SignedURL(ctx context.Context, key string, opts *driver.SignedURLOptions) (string, error) {
+	return b.base.SignedURL(ctx, key, &blob.SignedURLOptions{
+		Expiry:                   opts.Expiry,
+		Method:                   opts.Method,
+		ContentType:              opts.ContentType,
+		EnforceAbsentContentType: opts.EnforceAbsentContentType,
+		BeforeSign:               opts.BeforeSign,
+	})
+}
+
+func (b *bucketDriver) Close() error {
+	return b.base.Close()
+}
+
+func (b *bucketDriver) NewTypedWriter(ctx context.Context, key, contentType string, opts *driver.WriterOptions) (driver.Writer, error) {
+	req := &multipartclient.InitiateMultipartUploadRequest{
+		Bucket:             b.name,
+		Key:                key,
+		CacheControl:       opts.CacheControl,
+		ContentType:        contentType,
+		ContentDisposition: opts.ContentDisposition,
+		ContentEncoding:    opts.ContentEncoding,
+		ContentLanguage:    opts.ContentLanguage,
+	}
+	if opts.BeforeWrite != nil {
+		asFunc := func(i any) bool {
+			if p, ok := i.(**multipartclient.InitiateMultipartUploadRequest); ok {
+				*p = req
+				return true
+			}
+			return false
+		}
+		if err := opts.BeforeWrite(asFunc); err != nil {
+			return nil, err
+		}
+	}
+	partSize, concurrency := b.partSize, b.concurrency
+	if opts.BufferSize > 0 {
+		partSize = opts.BufferSize
+	}
+	if opts.MaxConcurrency > 0 {
+		concurrency = opts.MaxConcurrency
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &writer{
+		ctx:      ctx,
+		cancel:   cancel,
+		mpuc:     b.mpuc,
+		req:      req,
+		partSize: partSize,
+		sem:      make(chan struct{}, concurrency),
+		buf:      make([]byte, 0, partSize),
+	}, nil
+}
+
+// writer uploads the data written to it as the parts of a multipart upload,
+// which is initiated when the first part is full and completed by Close.
+type writer struct {
+	// ctx is cancelled when a part fails, with the failure as its cause.
+	ctx      context.Context
+	cancel   context.CancelCauseFunc
+	mpuc     *multipartclient.MultipartClient
+	req      *multipartclient.InitiateMultipartUploadRequest
+	partSize int
+	// sem holds a token for every part being sent.
+	sem chan struct{}
+
+	buf      []byte
+	uploadID string
+	// parts is the number of parts started.
+	parts int
+
+	wg        sync.WaitGroup
+	mu        sync.Mutex
+	completed []multipartclient.CompletePart
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		if w.ctx.Err() != nil {
+			return n, context.Cause(w.ctx)
+		}
+		m := min(len(p), w.partSize-len(w.buf))
+		w.buf = append(w.buf, p[:m]...)
+		p, n = p[m:], n+m
+		if len(w.buf) == w.partSize {
+			if err := w.sendPart(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// sendPart starts uploading the buffered data as the next part, once fewer
+// than the allowed number of parts are being sent.
+func (w *writer) sendPart() error {
+	if w.uploadID == "" {
+		result, err := w.mpuc.InitiateMultipartUpload(w.ctx, w.req)
+		if err != nil {
+			return err
+		}
+		w.uploadID = result.UploadID
+	}
+	select {
+	case w.sem <- struct{}{}:
+	case <-w.ctx.Done():
+		return context.Cause(w.ctx)
+	}
+	w.parts++
+	partNumber, data := w.parts, w.buf
+	w.buf = make([]byte, 0, w.partSize)
+	w.wg.Add(1)
+	go func() {
+		defer func() {
+			<-w.sem
+			w.wg.Done()
+		}()
+		pprof.Do(w.ctx, multipartclient.UploadLabels(w.req.Bucket, w.uploadID), func(ctx context.Context) {
+			w.uploadPart(ctx, partNumber, data)
+		})
+	}()
+	return nil
+}
+
+// uploadPart uploads data as part partNumber, cancelling the write if it
+// fails.
+func (w *writer) uploadPart(ctx context.Context, partNumber int, data []byte) {
+	result, err := w.mpuc.UploadObjectPart(ctx, &multipartclient.UploadObjectPartRequest{
+		Bucket:          w.req.Bucket,
+		Key:             w.req.Key,
+		PartNumber:      partNumber,
+		UploadID:        w.uploadID,
+		Body:            io.NopCloser(bytes.NewReader(data)),
+		VerifyChecksums: true,
+	})
+	if err != nil {
+		w.cancel(err)
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.completed = append(w.completed, multipartclient.CompletePart{PartNumber: partNumber, ETag: result.ETag})
+}
+
+// Close sends the rest of the data and completes the upload, or aborts it if
+// a part failed or the context of the write was cancelled.
+func (w *writer) Close() error {
+	defer w.cancel(nil)
+	var err error
+	// An empty object is uploaded as one empty part.
+	if len(w.buf) > 0 || w.parts == 0 {
+		err = w.sendPart()
+	}
+	w.wg.Wait()
+	if w.ctx.Err() != nil {
+		err = context.Cause(w.ctx)
+	}
+	if err == nil {
+		sort.Slice(w.completed, func(i, j int) bool {
+			return w.completed[i].PartNumber < w.completed[j].PartNumber
+		})
+		_, err = w.mpuc.CompleteMultipartUpload(w.ctx, &multipartclient.CompleteMultipartUploadRequest{
+			Bucket:   w.req.Bucket,
+			Key:      w.req.Key,
+			UploadID: w.uploadID,
+			Body:     multipartclient.CompleteMultipartUploadBody{Parts: w.completed},
+		})
+		if err == nil {
+			return nil
+		}
+	}
+	if w.uploadID != "" {
+		// Abort even if the write was cancelled so its parts aren't left
+		// behind.
+		_ = w.mpuc.AbortMultipartUpload(context.WithoutCancel(w.ctx), &multipartclient.AbortMultipartUploadRequest{
+			Bucket:   w.req.Bucket,
+			Key:      w.req.Key,
+			UploadID: w.uploadID,
+		})
+	}
+	return err
+}
+
+// reader adapts a reader of base to driver.Reader.
+type reader struct {
+	r *blob.Reader
+}
+
+func (r *reader) Read(p []byte) (int, error) { return r.r.Read(p) }
+func (r *reader) Close() error               { return r.r.Close() }
+func (r *reader) As(i any) bool              { return r.r.As(i) }
+
+func (r *reader) Attributes() *driver.ReaderAttributes {
+	return &driver.ReaderAttributes{ContentType: r.r.ContentType(), ModTime: r.r.ModTime(), Size: r.r.Size()}
+}
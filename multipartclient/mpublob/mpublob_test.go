@@ -0,0 +1,212 @@
+package mpublob
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/pprof"
+	"sync"
+	"testing"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multipartclienttest"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multiparttest"
+	"gocloud.dev/blob"
+	"gocloud.dev/blob/memblob"
+	"gocloud.dev/gcerrors"
+)
+
+// openTestBucket returns a bucket writing to a fake server in parts of 4
+// bytes, and reading from a memblob bucket.
+func openTestBucket(t *testing.T) (*blob.Bucket, *blob.Bucket, *multiparttest.Server) {
+	srv := multiparttest.NewServer(t)
+	srv.MinPartSize = 4
+	base := memblob.OpenBucket(nil)
+	bucket := OpenBucket(multipartclient.New(srv.Client()), "bucket1", base, &Options{PartSize: 4, Concurrency: 2})
+	t.Cleanup(func() { bucket.Close() })
+	return bucket, base, srv
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "Several parts", data: []byte("hello multipart world")},
+		{name: "Exact parts", data: []byte("12345678")},
+		{name: "One short part", data: []byte("hi")},
+		{name: "Empty", data: []byte{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket, _, srv := openTestBucket(t)
+			if err := bucket.WriteAll(context.Background(), "dir/object.txt", tt.data, nil); err != nil {
+				t.Fatal(err)
+			}
+			got, ok := srv.Object("bucket1", "dir/object.txt")
+			if !ok {
+				t.Fatal("object was not created")
+			}
+			if !bytes.Equal(got, tt.data) {
+				t.Errorf("got object %q, want %q", got, tt.data)
+			}
+			if uploads := srv.Uploads(); len(uploads) != 0 {
+				t.Errorf("got uploads %v left in progress, want none", uploads)
+			}
+		})
+	}
+}
+
+func TestWriteBeforeWrite(t *testing.T) {
+	bucket, _, srv := openTestBucket(t)
+	opts := &blob.WriterOptions{BeforeWrite: func(as func(any) bool) error {
+		var req *multipartclient.InitiateMultipartUploadRequest
+		if !as(&req) {
+			return errors.New("As failed for *InitiateMultipartUploadRequest")
+		}
+		if req.ContentType != "text/plain" || req.CacheControl != "no-cache" {
+			return fmt.Errorf("got content type %q and cache control %q, want those of the writer options", req.ContentType, req.CacheControl)
+		}
+		req.Key = "renamed.txt"
+		return nil
+	}, ContentType: "text/plain", CacheControl: "no-cache"}
+	if err := bucket.WriteAll(context.Background(), "object.txt", []byte("hello"), opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := srv.Object("bucket1", "renamed.txt"); !ok {
+		t.Error("object was not created with the key set in BeforeWrite")
+	}
+}
+
+func TestWriteFailureAborts(t *testing.T) {
+	bucket, _, srv := openTestBucket(t)
+	// Completing fails, as every part but the last is too small.
+	srv.MinPartSize = 1 << 20
+	err := bucket.WriteAll(context.Background(), "object.txt", []byte("hello multipart world"), nil)
+	if err == nil {
+		t.Fatal("got no error completing an upload of small parts")
+	}
+	if code := gcerrors.Code(err); code != gcerrors.InvalidArgument {
+		t.Errorf("got error code %v, want %v for EntityTooSmall", code, gcerrors.InvalidArgument)
+	}
+	if _, ok := srv.Object("bucket1", "object.txt"); ok {
+		t.Error("object was created")
+	}
+	if uploads := srv.Uploads(); len(uploads) != 0 {
+		t.Errorf("got uploads %v left in progress, want them aborted", uploads)
+	}
+}
+
+func TestWriteCancelled(t *testing.T) {
+	bucket, _, srv := openTestBucket(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	w, err := bucket.NewWriter(ctx, "object.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("hello multipart world")); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := w.Close(); gcerrors.Code(err) != gcerrors.Canceled {
+		t.Errorf("got error %v closing a cancelled write, want code Canceled", err)
+	}
+	if _, ok := srv.Object("bucket1", "object.txt"); ok {
+		t.Error("object was created")
+	}
+	if uploads := srv.Uploads(); len(uploads) != 0 {
+		t.Errorf("got uploads %v left in progress, want them aborted", uploads)
+	}
+}
+
+func TestWriteProfileLabels(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	srv.MinPartSize = 4
+	var (
+		mu     sync.Mutex
+		labels []string
+	)
+	hc := &http.Client{Transport: multipartclienttest.TransportFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodPut {
+			uploadID, _ := pprof.Label(req.Context(), "upload_id")
+			if bucket, _ := pprof.Label(req.Context(), "bucket"); bucket != "bucket1" || uploadID != req.URL.Query().Get("uploadId") {
+				t.Errorf("got labels bucket=%q upload_id=%q for a part of upload %q", bucket, uploadID, req.URL.Query().Get("uploadId"))
+			}
+			mu.Lock()
+			labels = append(labels, uploadID)
+			mu.Unlock()
+		}
+		return srv.Transport().RoundTrip(req)
+	})}
+	bucket := OpenBucket(multipartclient.New(hc), "bucket1", memblob.OpenBucket(nil), &Options{PartSize: 4, Concurrency: 2})
+	defer bucket.Close()
+	if err := bucket.WriteAll(context.Background(), "object.txt", []byte("hello multipart world"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(labels) != 6 {
+		t.Errorf("got %d parts uploaded, want 6", len(labels))
+	}
+}
+
+func TestDelegatesToBase(t *testing.T) {
+	bucket, base, _ := openTestBucket(t)
+	ctx := context.Background()
+	if err := base.WriteAll(ctx, "dir/object.txt", []byte("hello world"), &blob.WriterOptions{ContentType: "text/plain"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := bucket.ReadAll(ctx, "dir/object.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello world" {
+		t.Errorf("got %q, want %q", got, "hello world")
+	}
+	r, err := bucket.NewRangeReader(ctx, "dir/object.txt", 6, 5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if r.ContentType() != "text/plain" || r.Size() != 11 {
+		t.Errorf("got reader content type %q and size %d, want text/plain and 11", r.ContentType(), r.Size())
+	}
+
+	attrs, err := bucket.Attributes(ctx, "dir/object.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attrs.ContentType != "text/plain" || attrs.Size != 11 {
+		t.Errorf("got content type %q and size %d, want text/plain and 11", attrs.ContentType, attrs.Size)
+	}
+
+	objs, _, err := bucket.ListPage(ctx, blob.FirstPageToken, 10, &blob.ListOptions{Delimiter: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 1 || objs[0].Key != "dir/" || !objs[0].IsDir {
+		t.Errorf("got listing %+v, want the directory dir/", objs)
+	}
+
+	if err := bucket.Copy(ctx, "copy.txt", "dir/object.txt", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := bucket.Delete(ctx, "dir/object.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bucket.Attributes(ctx, "dir/object.txt"); gcerrors.Code(err) != gcerrors.NotFound {
+		t.Errorf("got error %v for a deleted object, want code NotFound", err)
+	}
+	if ok, err := bucket.Exists(ctx, "copy.txt"); err != nil || !ok {
+		t.Errorf("got Exists %v, %v for the copy, want true", ok, err)
+	}
+}
+
+func TestBucketAs(t *testing.T) {
+	bucket, _, _ := openTestBucket(t)
+	var mpuc *multipartclient.MultipartClient
+	if !bucket.As(&mpuc) || mpuc == nil {
+		t.Error("As failed for *multipartclient.MultipartClient")
+	}
+}
@@ -1,83 +1,302 @@
 package multipartclient
 
 import (
+	"cmp"
 	"context"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
-	"strings"
-
+	neturl "net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/googleapis/gax-go/v2"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/api/googleapi"
 )
 
-type multipartClient struct {
-	hc *http.Client
+// MultipartClient calls the GCS XML API for multipart uploads.
+//
+// A MultipartClient is safe for concurrent use by multiple goroutines, and
+// should be shared rather than created per upload: its configuration is fixed
+// by New and never changes afterwards, and the state it accumulates, such as
+// Stats, is synchronized. The callbacks it is given, such as a Clock, hooks
+// and Metrics, are called from the goroutines of concurrent operations and
+// must be safe for concurrent use too.
+type MultipartClient struct {
+	hc            *http.Client
+	contentSHA256 ContentSHA256Mode
+	hashRegistry  *gcshash.Registry
+	hashNames     []string
+	hashPool      *hashPool
+	// hashingDisabled turns off all client-side hashing of request bodies.
+	hashingDisabled bool
+	metrics         Metrics
+	logger          *slog.Logger
+	logOpts         LogOptions
+	debug           *debugDumper
+	clock           Clock
+	random          func() float64
+	auditHook       func(AuditRecord)
+	onError         func(op string, req any, err error)
+	stats           *clientStats
+	// endpoint is the base URL of Cloud Storage's XML API, if not
+	// defaultEndpoint, as set by WithEndpoint or NewClient.
+	endpoint string
+	// compat is set when talking to an S3-compatible server.
+	compat *S3Compatibility
+	// retry is set by WithRetry.
+	retry *RetryConfig
+	// buckets holds the defaults set by WithBucketConfig.
+	buckets map[string]*BucketConfig
+	// strictValidation is set by WithStrictValidation.
+	strictValidation bool
+	// compactXML is set by WithCompactXML.
+	compactXML bool
+	// partChecksums is set by WithPartChecksums.
+	partChecksums bool
+	// listPrefetch is set by WithListPrefetch.
+	listPrefetch bool
+	// encryptionKey is set by WithEncryptionKey.
+	encryptionKey *encryptionKey
+	// tracer is set by WithTracerProvider.
+	tracer trace.Tracer
+	// signer is set by WithSigner.
+	signer Signer
+	// redirectHosts are the hosts set by WithRedirectHosts.
+	redirectHosts []string
+	// transport holds the settings of WithHTTPVersion, WithCopyBufferSize
+	// and WithDialer.
+	transport transportSettings
+	// urls caches the template of request URLs for the endpoint.
+	urls atomic.Pointer[urlTemplate]
 }
 
-func New(hc *http.Client) *multipartClient {
-	return &multipartClient{
-		hc: hc,
+func New(hc *http.Client, opts ...Option) *MultipartClient {
+	mpuc := &MultipartClient{
+		hc:      hc,
+		metrics: nopMetrics{},
+		stats:   newClientStats(),
+		clock:   systemClock{},
+		random:  defaultRandom,
+	}
+	for _, opt := range opts {
+		opt(mpuc)
+	}
+	if mpuc.transport != (transportSettings{}) {
+		mpuc.hc = withTransportSettings(mpuc.hc, mpuc.transport)
 	}
+	return mpuc
 }
 
+// checkResponse returns an *Error if resp is an error response.
 func checkResponse(resp *http.Response) error {
 	if 200 <= resp.StatusCode && resp.StatusCode < 300 {
 		return nil
 	}
-	// Default to a basic message if there is no body.
-	errStr := resp.Status
+	var body []byte
 	if resp.Body != nil {
-		body, readErr := io.ReadAll(resp.Body)
+		var readErr error
+		body, readErr = io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
 		if readErr != nil {
-			return fmt.Errorf("%w (failed to read response body); %s", readErr, errStr)
+			return fmt.Errorf("%w (failed to read response body); %s", readErr, resp.Status)
 		}
-		if bodyStr := string(body); bodyStr != "" {
-			errStr = bodyStr
+	}
+	return newError(resp, body)
+}
+
+// do sends httpReq for the operation op and checks the response status,
+// retrying as configured by WithRetry. The response is returned even if its
+// status is an error so its body can be closed.
+func (mpuc *MultipartClient) do(ctx context.Context, op string, httpReq *http.Request) (*http.Response, error) {
+	return mpuc.doChecked(ctx, op, httpReq, nil)
+}
+
+// doXML is like do, and also decodes the XML body of a successful response
+// into a new T. A response cut short, with an *IncompleteResponseError, is
+// retried like a failed request.
+func doXML[T any](ctx context.Context, mpuc *MultipartClient, op string, httpReq *http.Request) (*T, *http.Response, error) {
+	var result *T
+	resp, err := mpuc.doChecked(ctx, op, httpReq, func(resp *http.Response) error {
+		// Start over on every attempt.
+		result = new(T)
+		return decodeXMLResponse(resp, result)
+	})
+	return result, resp, err
+}
+
+// doChecked is like do, and also checks successful responses with check, if
+// not nil, whose error is retried as that of a request.
+func (mpuc *MultipartClient) doChecked(ctx context.Context, op string, httpReq *http.Request, check func(*http.Response) error) (*http.Response, error) {
+	if id := CorrelationIDFromContext(ctx); id != "" {
+		httpReq.Header.Set(correlationIDHeader, id)
+	}
+	mpuc.setTraceHeaders(ctx, httpReq)
+	setContextHeaders(ctx, httpReq)
+	var bo gax.Backoff
+	if mpuc.retry != nil {
+		bo = mpuc.retry.Backoff
+	}
+	backoff := newBackoff(bo, mpuc.random)
+	for attempts, redirects := 1, 0; ; attempts++ {
+		// Every attempt is signed anew, as a signature covers the time it is
+		// made and, after a redirect, the host and path.
+		mpuc.sign(httpReq)
+		resp, err := mpuc.send(ctx, op, httpReq)
+		if isRedirect(resp) && ctx.Err() == nil {
+			// Following a redirect isn't a retry.
+			redirects++
+			var redirectErr error
+			if redirects > maxRedirects {
+				redirectErr = fmt.Errorf("stopped after %d redirects", maxRedirects)
+			} else {
+				redirectErr = mpuc.redirect(httpReq, resp)
+			}
+			if redirectErr != nil {
+				return resp, correlateError(correlationOf(ctx, resp), errors.Join(err, redirectErr))
+			}
+			attempts--
+			continue
+		}
+		if err == nil && check != nil {
+			err = check(resp)
+		}
+		if err != nil && ctx.Err() != nil {
+			// A request that failed once ctx is done isn't retried, whatever
+			// the error the transport made of it.
+			if !errors.Is(err, ctx.Err()) {
+				err = errors.Join(err, ctx.Err())
+			}
+			return resp, correlateError(correlationOf(ctx, resp), err)
+		}
+		if !mpuc.retryable(op, httpReq, attempts, resp, err) {
+			return resp, correlateError(correlationOf(ctx, resp), err)
 		}
+		pause := max(backoff.pause(), mpuc.retryAfter(resp))
+		if rewindErr := rewind(httpReq, resp); rewindErr != nil {
+			return nil, correlateError(correlationOf(ctx, nil), errors.Join(err, rewindErr))
+		}
+		select {
+		case <-mpuc.clock.After(pause):
+		case <-ctx.Done():
+			return nil, correlateError(correlationOf(ctx, nil), errors.Join(err, ctx.Err()))
+		}
+		mpuc.metrics.RequestRetried(op)
+		mpuc.stats.retries.Add(1)
 	}
+}
 
-	return errors.New(errStr)
+// send sends httpReq once and checks the response status.
+func (mpuc *MultipartClient) send(ctx context.Context, op string, httpReq *http.Request) (*http.Response, error) {
+	start := mpuc.clock.Now()
+	var tracer *phaseTracer
+	phaseObserver, observePhases := mpuc.metrics.(PhaseObserver)
+	if observePhases {
+		ctx, tracer = withPhaseTrace(ctx, mpuc.clock)
+	}
+	reqBody := mpuc.debug.captureRequestBody(httpReq)
+	resp, err := mpuc.httpClient().Do(httpReq.WithContext(ctx))
+	mpuc.debug.dump(httpReq, reqBody, resp, err)
+	if observePhases {
+		phaseObserver.ObservePhases(op, tracer.result())
+	}
+	if err == nil {
+		err = checkResponse(resp)
+	}
+	statusCode := 0
+	if resp != nil {
+		statusCode = resp.StatusCode
+	}
+	elapsed := mpuc.since(start)
+	mpuc.metrics.RequestDone(op, statusCode, elapsed)
+	setSpanStatusCode(ctx, statusCode)
+	mpuc.stats.requestDone(ctx, op, statusCode, err)
+	mpuc.logRequest(ctx, op, httpReq, resp, err, elapsed)
+	return resp, err
 }
 
 type InitiateMultipartUploadRequest struct {
 	Bucket string
 	Key    string
+	// StorageClass, KMSKeyName, PredefinedACL and Metadata are the settings
+	// of the object, overriding those of the bucket's BucketConfig; see there.
+	StorageClass  string
+	KMSKeyName    string
+	PredefinedACL string
+	Metadata      map[string]string
+	// ACL is the predefined ACL of the object if PredefinedACL is empty.
+	//
+	// Deprecated: Use PredefinedACL.
+	ACL string
+	// CacheControl, ContentType, ContentDisposition, ContentEncoding and
+	// ContentLanguage are the properties of the object, sent as the headers
+	// of the same name.
+	CacheControl       string
+	ContentType        string
+	ContentDisposition string
+	ContentEncoding    string
+	ContentLanguage    string
+	// RetentionMode and RetainUntil set the retention of the object, which
+	// the server applies when the upload completes, for buckets with object
+	// retention enabled. RetentionMode is "Unlocked" or "Locked", or with
+	// WithS3Compatibility, "GOVERNANCE" or "COMPLIANCE". Both are set or
+	// neither.
+	RetentionMode string
+	RetainUntil   time.Time
 }
 
 type InitiateMultipartUploadResult struct {
-	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
-	Bucket   string   `xml:"Bucket"`
-	Key      string   `xml:"Key"`
-	UploadID string   `xml:"UploadId"`
+	XMLName xml.Name `xml:"InitiateMultipartUploadResult"`
+	Correlation
+	Bucket   string `xml:"Bucket"`
+	Key      string `xml:"Key"`
+	UploadID string `xml:"UploadId"`
+	// KMSKeyName is the Cloud KMS key version that will encrypt the object,
+	// from the x-goog-encryption-kms-key-name response header, or "" if the
+	// server didn't send it.
+	KMSKeyName string `xml:"-"`
 }
 
 // InitiateMultipartUpload calls the XML Multipart API to Inititate a Multipart Upload.
-func (mpuc *multipartClient) InitiateMultipartUpload(ctx context.Context, req *InitiateMultipartUploadRequest) (*InitiateMultipartUploadResult, error) {
-	url := fmt.Sprintf("https://storage.googleapis.com/%s/%s?uploads", req.Bucket, req.Key)
-	httpReq, err := http.NewRequest("POST", url, http.NoBody)
-	if err != nil {
+func (mpuc *MultipartClient) InitiateMultipartUpload(ctx context.Context, req *InitiateMultipartUploadRequest) (result *InitiateMultipartUploadResult, err error) {
+	ctx = mpuc.startOperation(ctx, OpInitiateMultipartUpload)
+	defer func(start time.Time) {
+		info := operationInfo{Bucket: req.Bucket, Key: req.Key}
+		if result != nil {
+			info.UploadID = result.UploadID
+		}
+		err = mpuc.operationDone(ctx, OpInitiateMultipartUpload, req, info, start, err)
+	}(mpuc.clock.Now())
+
+	if err := mpuc.validate(OpInitiateMultipartUpload, req); err != nil {
 		return nil, err
 	}
-
-	resp, err := mpuc.hc.Do(httpReq.WithContext(ctx))
-	defer googleapi.CloseBody(resp)
+	url := mpuc.requestURL(req.Bucket, req.Key, "uploads")
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, http.NoBody)
 	if err != nil {
 		return nil, err
 	}
-	if err := checkResponse(resp); err != nil {
+	mpuc.setUserProject(ctx, httpReq, req.Bucket)
+	mpuc.setObjectHeaders(httpReq, req)
+	if err := mpuc.setEncryptionKey(httpReq); err != nil {
+		return nil, err
+	}
+	if err := mpuc.setPayloadHash(httpReq, http.NoBody); err != nil {
 		return nil, err
 	}
 
-	result := &InitiateMultipartUploadResult{}
-	xml := xml.NewDecoder(resp.Body)
-	if err := xml.Decode(result); err != nil {
-		respStrBuilder := &strings.Builder{}
-		// strings.Builder.Write does not return errors.
-		resp.Write(respStrBuilder)
-		return nil, fmt.Errorf("failed to parse XML body from HTTP response: %v. Response: %v", err, respStrBuilder.String())
+	result, resp, err := doXML[InitiateMultipartUploadResult](ctx, mpuc, OpInitiateMultipartUpload, httpReq)
+	defer googleapi.CloseBody(resp)
+	if err != nil {
+		return nil, err
 	}
+	result.Correlation = correlationOf(ctx, resp)
+	result.KMSKeyName = mpuc.kmsKeyNameOf(resp)
 	return result, nil
 }
 
@@ -86,35 +305,307 @@ type UploadObjectPartRequest struct {
 	Key        string
 	PartNumber int
 	UploadID   string
-	Body       io.ReadCloser
+	// Body is the data of the part. Use a *SectionBody to upload part of a
+	// file, or of a memory-mapped region, without copying it, or a *FileBody
+	// to let the kernel send part of a file. If Body implements io.Seeker,
+	// it is sent again from where it started after a redirect or a failure
+	// WithRetry retries. If Body implements io.Closer, it is closed.
+	Body io.Reader
+	// GetBody, if set, returns a new copy of Body, to be sent again after a
+	// redirect, a failure WithRetry retries or, with VerifyChecksums, a
+	// checksum mismatch, as http.Request.GetBody does. It takes precedence
+	// over seeking Body, so a body that isn't seekable, such as one read
+	// from a cache or another service, can be sent again. The copies are
+	// closed.
+	GetBody func() (io.ReadCloser, error)
+	// Hashes are caller-supplied checksums of Body. They are sent in the
+	// x-goog-hash header so the server rejects a part whose data doesn't
+	// match.
+	Hashes gcshash.Sums
+	// VerifyChecksums computes the CRC32C and MD5 of Body while it is being
+	// sent and compares them with the hashes the server reports for the
+	// stored part. If Body implements io.Seeker, a mismatched part is
+	// re-uploaded, up to MaxChecksumAttempts uploads in total, before a
+	// *ChecksumMismatchError is returned. If Body implements io.Seeker and
+	// Hashes are supplied, Body is also hashed before sending and an
+	// *InconsistentHashesError is returned without sending if they differ.
+	VerifyChecksums bool
+	// MaxChecksumAttempts bounds the uploads made when VerifyChecksums is set.
+	// Defaults to 3 if zero.
+	MaxChecksumAttempts int
+	// Progress, if set, is called as Body is sent, from the goroutine of the
+	// HTTP transport that reads it. The progress of a *FileBody, which the
+	// kernel sends, is reported once it has been sent.
+	Progress ProgressFunc
+}
+
+type UploadObjectPartResult struct {
+	Correlation
+	ETag string
+	// Hashes are the checksums the server reported for the stored part.
+	Hashes gcshash.Sums
+	// ComputedHashes are the checksums computed locally while sending the
+	// part. Only set when VerifyChecksums is requested.
+	ComputedHashes gcshash.Sums
+	// ComputedDigests holds the digest of every algorithm computed locally,
+	// keyed by algorithm name. Only set when VerifyChecksums is requested.
+	ComputedDigests map[string][]byte
+	// HashingDisabled reports that the part was sent by a client created with
+	// WithHashingDisabled, so its integrity was not verified by the client.
+	HashingDisabled bool
 }
 
-func (mpuc *multipartClient) UploadObjectPart(ctx context.Context, req *UploadObjectPartRequest) error {
-	url := fmt.Sprintf("https://storage.googleapis.com/%s/%s?partNumber=%v&uploadId=%s", req.Bucket, req.Key, req.PartNumber, req.UploadID)
-	httpReq, err := http.NewRequest(http.MethodPut, url, req.Body)
+func (mpuc *MultipartClient) UploadObjectPart(ctx context.Context, req *UploadObjectPartRequest) (result *UploadObjectPartResult, err error) {
+	ctx = mpuc.startOperation(ctx, OpUploadObjectPart)
+	mpuc.metrics.PartsInFlight(1)
+	defer mpuc.metrics.PartsInFlight(-1)
+	ctx, stats := trackPartStats(ctx)
+	defer func(start time.Time) {
+		mpuc.reportPartDone(ctx, stats, start, err)
+		err = mpuc.operationDone(ctx, OpUploadObjectPart, req, operationInfo{
+			Bucket:     req.Bucket,
+			Key:        req.Key,
+			UploadID:   req.UploadID,
+			PartNumber: req.PartNumber,
+			Bytes:      stats.bytes,
+		}, start, err)
+	}(mpuc.clock.Now())
+
+	// Don't hash a body that won't be sent.
+	if err := cmp.Or(ctx.Err(), mpuc.validate(OpUploadObjectPart, req)); err != nil {
+		closeBody(req.Body)
+		return nil, err
+	}
+	if mpuc.hashingDisabled {
+		result, err = mpuc.uploadObjectPart(ctx, req, req.Body, req.GetBody, -1)
+		if err != nil {
+			return nil, err
+		}
+		result.HashingDisabled = true
+		return result, nil
+	}
+	if req.VerifyChecksums || mpuc.partChecksums && req.Body != nil {
+		return mpuc.uploadVerifiedObjectPart(ctx, req)
+	}
+	return mpuc.uploadObjectPart(ctx, req, req.Body, req.GetBody, -1)
+}
+
+// closeBody closes body if it is an io.Closer.
+func closeBody(body io.Reader) {
+	if c, ok := body.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+// uploadObjectPart sends body as the part described by req. reopen, if not
+// nil, returns a new copy of body to send again. contentLength is the length
+// of body, or -1 if unknown.
+func (mpuc *MultipartClient) uploadObjectPart(ctx context.Context, req *UploadObjectPartRequest, body io.Reader, reopen func() (io.ReadCloser, error), contentLength int64) (*UploadObjectPartResult, error) {
+	url := mpuc.partURL(req.Bucket, req.Key, req.PartNumber, req.UploadID)
+	if sb, ok := body.(sizedBody); ok && contentLength < 0 {
+		var err error
+		if contentLength, err = sb.remaining(); err != nil {
+			return nil, err
+		}
+	}
+	var counter *countingReader
+	// resent counts the bytes read from the copies of body counter replaced.
+	var resent int64
+	var fileSent func() int64
+	// getBody returns the body to send again, if it can be.
+	var getBody func() (io.ReadCloser, error)
+	fileBody, _ := body.(*FileBody)
+	seeker, _ := body.(io.Seeker)
+	switch {
+	case body == nil:
+	case reopen != nil:
+		counter = &countingReader{r: body}
+		getBody = func() (io.ReadCloser, error) {
+			r, err := reopen()
+			if err != nil {
+				return nil, err
+			}
+			resent += counter.n.Load()
+			counter = &countingReader{r: r}
+			return counter, nil
+		}
+		body = counter
+	case fileBody != nil:
+		b := fileBody
+		// The file is handed to the transport as is, so it can use
+		// sendfile(2), and the bytes sent are counted from its offset.
+		defer b.Close()
+		start, err := b.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		fileSent = func() int64 {
+			end, _ := b.Seek(0, io.SeekCurrent)
+			return max(end-start, 0)
+		}
+		getBody = func() (io.ReadCloser, error) {
+			_, err := b.Seek(start, io.SeekStart)
+			return unclosedFileBody{b}, err
+		}
+		body = unclosedFileBody{b}
+	case seeker != nil:
+		// The HTTP client closes the bodies it sends, so Close is hidden
+		// to be able to send the body again, and the body is closed here.
+		b := seeker
+		start, err := b.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		if c, ok := body.(io.Closer); ok {
+			defer c.Close()
+		}
+		counter = &countingReader{r: readerOnly{body}}
+		getBody = func() (io.ReadCloser, error) {
+			_, err := b.Seek(start, io.SeekStart)
+			return counter, err
+		}
+		body = counter
+	default:
+		counter = &countingReader{r: body}
+		body = counter
+	}
+	if req.Progress != nil && counter != nil {
+		body, getBody = withProgress(body, getBody, contentLength, req.PartNumber, req.Progress)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPut, url, body)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	httpReq.GetBody = getBody
+	mpuc.setUserProject(ctx, httpReq, req.Bucket)
+	if err := mpuc.setEncryptionKey(httpReq); err != nil {
+		return nil, err
+	}
+	if err := mpuc.setPayloadHash(httpReq, req.Body); err != nil {
+		return nil, err
+	}
+	if contentLength >= 0 {
+		httpReq.ContentLength = contentLength
+	}
+	req.Hashes.SetHeader(httpReq.Header)
+	if mpuc.partChecksums && req.Hashes.MD5 != nil {
+		// S3-compatible servers check Content-MD5 rather than x-goog-hash.
+		httpReq.Header.Set("Content-MD5", gcshash.EncodeMD5(req.Hashes.MD5))
 	}
 
-	resp, err := mpuc.hc.Do(httpReq.WithContext(ctx))
+	resp, err := mpuc.do(ctx, OpUploadObjectPart, httpReq)
 	defer googleapi.CloseBody(resp)
+	var sent int64
+	if counter != nil || fileSent != nil {
+		if counter != nil {
+			sent = resent + counter.n.Load()
+		} else {
+			sent = fileSent()
+			if req.Progress != nil && sent > 0 {
+				req.Progress(sent, contentLength, req.PartNumber)
+			}
+		}
+		mpuc.metrics.BytesUploaded(OpUploadObjectPart, sent)
+		mpuc.stats.bytesSent.Add(sent)
+	}
+	recordPartAttempt(ctx, sent)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if err := checkResponse(resp); err != nil {
-		return err
+
+	hashes, err := gcshash.ParseHeader(resp.Header)
+	if err != nil {
+		return nil, err
 	}
+	return &UploadObjectPartResult{
+		Correlation: correlationOf(ctx, resp),
+		ETag:        resp.Header.Get("ETag"),
+		Hashes:      hashes,
+	}, nil
+}
 
-	return nil
+// ByteRange is a range of Length bytes starting at Offset.
+type ByteRange struct {
+	Offset int64
+	Length int64
+}
+
+type UploadPartCopyRequest struct {
+	Bucket     string
+	Key        string
+	PartNumber int
+	UploadID   string
+	// SourceBucket and SourceKey name the existing object the part is copied from.
+	SourceBucket string
+	SourceKey    string
+	// SourceRange selects the bytes of the source object to copy. The whole
+	// source object is copied if nil.
+	SourceRange *ByteRange
+}
+
+type CopyPartResult struct {
+	XMLName xml.Name `xml:"CopyPartResult"`
+	Correlation
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+}
+
+// UploadPartCopy creates a part of a multipart upload from a range of an existing object. The data is copied server-side.
+func (mpuc *MultipartClient) UploadPartCopy(ctx context.Context, req *UploadPartCopyRequest) (result *CopyPartResult, err error) {
+	ctx = mpuc.startOperation(ctx, OpUploadPartCopy)
+	mpuc.metrics.PartsInFlight(1)
+	defer mpuc.metrics.PartsInFlight(-1)
+	defer func(start time.Time) {
+		err = mpuc.operationDone(ctx, OpUploadPartCopy, req, operationInfo{
+			Bucket:     req.Bucket,
+			Key:        req.Key,
+			UploadID:   req.UploadID,
+			PartNumber: req.PartNumber,
+		}, start, err)
+	}(mpuc.clock.Now())
+
+	if err := mpuc.validate(OpUploadPartCopy, req); err != nil {
+		return nil, err
+	}
+	if req.SourceRange != nil && req.SourceRange.Length <= 0 {
+		return nil, fmt.Errorf("source range length must be positive, got %d", req.SourceRange.Length)
+	}
+
+	url := mpuc.partURL(req.Bucket, req.Key, req.PartNumber, req.UploadID)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPut, url, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	mpuc.setUserProject(ctx, httpReq, req.Bucket)
+	if err := mpuc.setEncryptionKey(httpReq); err != nil {
+		return nil, err
+	}
+	if err := mpuc.setPayloadHash(httpReq, http.NoBody); err != nil {
+		return nil, err
+	}
+	// The source is a path, so its key is escaped as in request URLs.
+	httpReq.Header.Set(mpuc.header("x-goog-copy-source"), "/"+req.SourceBucket+"/"+string(appendPathEscaped(nil, req.SourceKey)))
+	if r := req.SourceRange; r != nil {
+		httpReq.Header.Set(mpuc.header("x-goog-copy-source-range"), "bytes="+strconv.FormatInt(r.Offset, 10)+"-"+strconv.FormatInt(r.Offset+r.Length-1, 10))
+	}
+
+	result, resp, err := doXML[CopyPartResult](ctx, mpuc, OpUploadPartCopy, httpReq)
+	defer googleapi.CloseBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	result.Correlation = correlationOf(ctx, resp)
+	return result, nil
 }
 
 type CompletePart struct {
-	PartNumber int `xml:"PartNumber"`
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag,omitempty"`
 }
 
 type CompleteMultipartUploadBody struct {
-	XMLName xml.Name `xml:"CompleteMultipartUpload"`
-	Parts   []CompletePart
+	XMLName xml.Name       `xml:"CompleteMultipartUpload"`
+	Parts   []CompletePart `xml:"Part"`
 }
 
 type CompleteMultipartUploadRequest struct {
@@ -126,34 +617,65 @@ type CompleteMultipartUploadRequest struct {
 
 type CompleteMultipartUploadResult struct {
 	XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+	Correlation
+	Location string `xml:"Location"`
+	Bucket   string `xml:"Bucket"`
+	Key      string `xml:"Key"`
+	ETag     string `xml:"ETag"`
+	// Generation is the generation of the new object, from the
+	// x-goog-generation response header, or 0 if the server didn't send it.
+	Generation int64 `xml:"-"`
+	// KMSKeyName is the Cloud KMS key that encrypts the new object, or "" if
+	// the server didn't report one.
+	KMSKeyName string `xml:"-"`
 }
 
-func (mpuc *multipartClient) CompleteMultipartUpload(ctx context.Context, req *CompleteMultipartUploadRequest) (*CompleteMultipartUploadResult, error) {
-	xmlBody := &strings.Builder{}
-	encoder := xml.NewEncoder(xmlBody)
-	encoder.Indent("", "  ")
-	err := encoder.Encode(req.Body)
-	if err != nil {
+func (mpuc *MultipartClient) CompleteMultipartUpload(ctx context.Context, req *CompleteMultipartUploadRequest) (result *CompleteMultipartUploadResult, err error) {
+	ctx = mpuc.startOperation(ctx, OpCompleteMultipartUpload)
+	defer func(start time.Time) {
+		err = mpuc.operationDone(ctx, OpCompleteMultipartUpload, req, operationInfo{Bucket: req.Bucket, Key: req.Key, UploadID: req.UploadID}, start, err)
+	}(mpuc.clock.Now())
+
+	if err := mpuc.validate(OpCompleteMultipartUpload, req); err != nil {
 		return nil, err
 	}
-
-	url := fmt.Sprintf("https://storage.googleapis.com/%s/%s?uploadId=%s", req.Bucket, req.Key, req.UploadID)
-	httpBody := io.NopCloser(strings.NewReader(xmlBody.String()))
-	httpReq, err := http.NewRequest(http.MethodPost, url, httpBody)
+	url := mpuc.requestURL(req.Bucket, req.Key, "uploadId="+neturl.QueryEscape(req.UploadID))
+	body := mpuc.completeBody(req.Body)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body.reader())
 	if err != nil {
 		return nil, err
 	}
+	mpuc.setUserProject(ctx, httpReq, req.Bucket)
+	if err := mpuc.setEncryptionKey(httpReq); err != nil {
+		return nil, err
+	}
+	httpReq.GetBody = func() (io.ReadCloser, error) { return body.reader(), nil }
+	if err := mpuc.setPayloadHash(httpReq, httpReq.Body); err != nil {
+		return nil, err
+	}
 
-	resp, err := mpuc.hc.Do(httpReq.WithContext(ctx))
+	resp, err := mpuc.do(ctx, OpCompleteMultipartUpload, httpReq)
 	defer googleapi.CloseBody(resp)
 	if err != nil {
 		return nil, err
 	}
-	if err := checkResponse(resp); err != nil {
+
+	result = &CompleteMultipartUploadResult{}
+	// Tolerate an empty body; the upload has completed either way.
+	if err := decodeXMLResponse(resp, result); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	result.Correlation = correlationOf(ctx, resp)
+	if g := resp.Header.Get("x-goog-generation"); g != "" {
+		result.Generation, err = strconv.ParseInt(g, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x-goog-generation header %q: %w", g, err)
+		}
+	}
+	result.KMSKeyName = mpuc.kmsKeyNameOf(resp)
+	if err := mpuc.verifyCompleteETag(req.Body, result); err != nil {
 		return nil, err
 	}
-
-	result := &CompleteMultipartUploadResult{}
 	return result, nil
 }
 
@@ -163,62 +685,105 @@ type AbortMultipartUploadRequest struct {
 	UploadID string `xml:"UploadId"`
 }
 
-func (mpuc *multipartClient) AbortMultipartUpload(ctx context.Context, req *AbortMultipartUploadRequest) error {
-	url := fmt.Sprintf("https://storage.googleapis.com/%s/%s?uploadId=%s", req.Bucket, req.Key, req.UploadID)
-	httpReq, err := http.NewRequest("DELETE", url, http.NoBody)
+func (mpuc *MultipartClient) AbortMultipartUpload(ctx context.Context, req *AbortMultipartUploadRequest) (err error) {
+	ctx = mpuc.startOperation(ctx, OpAbortMultipartUpload)
+	defer func(start time.Time) {
+		err = mpuc.operationDone(ctx, OpAbortMultipartUpload, req, operationInfo{Bucket: req.Bucket, Key: req.Key, UploadID: req.UploadID}, start, err)
+	}(mpuc.clock.Now())
+
+	if err := mpuc.validate(OpAbortMultipartUpload, req); err != nil {
+		return err
+	}
+	url := mpuc.requestURL(req.Bucket, req.Key, "uploadId="+neturl.QueryEscape(req.UploadID))
+	httpReq, err := http.NewRequestWithContext(ctx, "DELETE", url, http.NoBody)
 	if err != nil {
 		return err
 	}
+	mpuc.setUserProject(ctx, httpReq, req.Bucket)
+	if err := mpuc.setPayloadHash(httpReq, http.NoBody); err != nil {
+		return err
+	}
 
-	resp, err := mpuc.hc.Do(httpReq.WithContext(ctx))
+	resp, err := mpuc.do(ctx, OpAbortMultipartUpload, httpReq)
 	defer googleapi.CloseBody(resp)
 	if err != nil {
 		return err
 	}
-	if err := checkResponse(resp); err != nil {
-		return err
-	}
 
 	return nil
 }
 
 type ListMultipartUploadsRequest struct {
 	Bucket string
+	// Prefix limits the listing to uploads of objects whose names begin
+	// with it.
+	Prefix string
+	// KeyMarker and UploadIDMarker start the listing after the upload
+	// UploadIDMarker of the object KeyMarker, or after all uploads of
+	// KeyMarker if UploadIDMarker is empty, as given by the NextKeyMarker
+	// and NextUploadIDMarker of a truncated result.
+	KeyMarker      string
+	UploadIDMarker string
+	// MaxUploads bounds the uploads of the result. The server's limit, 1000
+	// for Cloud Storage, applies if it is zero.
+	MaxUploads int
 }
 
 type ListUpload struct {
-	XMLName  xml.Name `xml:"Upload"`
-	UploadID string   `xml:"UploadId"`
+	XMLName   xml.Name  `xml:"Upload"`
+	Key       string    `xml:"Key"`
+	UploadID  string    `xml:"UploadId"`
+	Initiated time.Time `xml:"Initiated"`
 }
 type ListMultipartUploadsResult struct {
-	XMLName xml.Name     `xml:"ListMultipartUploadsResult"`
+	XMLName xml.Name `xml:"ListMultipartUploadsResult"`
+	Correlation
 	Uploads []ListUpload `xml:"Upload"`
+	// IsTruncated reports that there are more uploads, listed by a request
+	// with the markers NextKeyMarker and NextUploadIDMarker.
+	IsTruncated        bool   `xml:"IsTruncated"`
+	NextKeyMarker      string `xml:"NextKeyMarker"`
+	NextUploadIDMarker string `xml:"NextUploadIdMarker"`
 }
 
-func (mpuc *multipartClient) ListMultipartUploads(ctx context.Context, req *ListMultipartUploadsRequest) (*ListMultipartUploadsResult, error) {
-	url := fmt.Sprintf("https://storage.googleapis.com/%s/?uploads", req.Bucket)
-	httpReq, err := http.NewRequest(http.MethodGet, url, http.NoBody)
-	if err != nil {
+func (mpuc *MultipartClient) ListMultipartUploads(ctx context.Context, req *ListMultipartUploadsRequest) (result *ListMultipartUploadsResult, err error) {
+	ctx = mpuc.startOperation(ctx, OpListMultipartUploads)
+	defer func(start time.Time) {
+		err = mpuc.operationDone(ctx, OpListMultipartUploads, req, operationInfo{Bucket: req.Bucket}, start, err)
+	}(mpuc.clock.Now())
+
+	if err := mpuc.validate(OpListMultipartUploads, req); err != nil {
 		return nil, err
 	}
-
-	resp, err := mpuc.hc.Do(httpReq.WithContext(ctx))
-	defer googleapi.CloseBody(resp)
+	query := "uploads"
+	if req.Prefix != "" {
+		query += "&prefix=" + neturl.QueryEscape(req.Prefix)
+	}
+	if req.KeyMarker != "" {
+		query += "&key-marker=" + neturl.QueryEscape(req.KeyMarker)
+	}
+	if req.UploadIDMarker != "" {
+		query += "&upload-id-marker=" + neturl.QueryEscape(req.UploadIDMarker)
+	}
+	if req.MaxUploads > 0 {
+		query += "&max-uploads=" + strconv.Itoa(req.MaxUploads)
+	}
+	url := mpuc.requestURL(req.Bucket, "", query)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
 	if err != nil {
 		return nil, err
 	}
-	if err := checkResponse(resp); err != nil {
+	mpuc.setUserProject(ctx, httpReq, req.Bucket)
+	if err := mpuc.setPayloadHash(httpReq, http.NoBody); err != nil {
 		return nil, err
 	}
 
-	result := &ListMultipartUploadsResult{}
-	xml := xml.NewDecoder(resp.Body)
-	if err := xml.Decode(result); err != nil {
-		respStrBuilder := &strings.Builder{}
-		// strings.Builder.Write does not return errors.
-		_ = resp.Write(respStrBuilder)
-		return nil, fmt.Errorf("failed to parse XML body from HTTP response: %v. Response: %v", err, respStrBuilder.String())
+	result, resp, err := doXML[ListMultipartUploadsResult](ctx, mpuc, OpListMultipartUploads, httpReq)
+	defer googleapi.CloseBody(resp)
+	if err != nil {
+		return nil, err
 	}
+	result.Correlation = correlationOf(ctx, resp)
 	return result, nil
 }
 
@@ -226,35 +791,54 @@ type ListObjectPartsRequest struct {
 	Bucket   string
 	Key      string
 	UploadID string
+	// PartNumberMarker starts the listing after the part with this number,
+	// as given by the NextPartNumberMarker of a truncated result.
+	PartNumberMarker int
+	// MaxParts bounds the parts of the result. The server's limit, 1000 for
+	// Cloud Storage, applies if it is zero.
+	MaxParts int
 }
 
 type ListObjectPartsResult struct {
-	Parts []CompletePart
+	Correlation
+	Parts []CompletePart `xml:"Part"`
+	// IsTruncated reports that there are more parts, listed by a request
+	// with the marker NextPartNumberMarker.
+	IsTruncated          bool `xml:"IsTruncated"`
+	NextPartNumberMarker int  `xml:"NextPartNumberMarker"`
 }
 
-func (mpuc *multipartClient) ListObjectParts(ctx context.Context, req *ListObjectPartsRequest) (*ListObjectPartsResult, error) {
-	url := fmt.Sprintf("https://storage.googleapis.com/%s/%s?uploadId=%s", req.Bucket, req.Key, req.UploadID)
-	httpReq, err := http.NewRequest(http.MethodGet, url, http.NoBody)
-	if err != nil {
+func (mpuc *MultipartClient) ListObjectParts(ctx context.Context, req *ListObjectPartsRequest) (result *ListObjectPartsResult, err error) {
+	ctx = mpuc.startOperation(ctx, OpListObjectParts)
+	defer func(start time.Time) {
+		err = mpuc.operationDone(ctx, OpListObjectParts, req, operationInfo{Bucket: req.Bucket, Key: req.Key, UploadID: req.UploadID}, start, err)
+	}(mpuc.clock.Now())
+
+	if err := mpuc.validate(OpListObjectParts, req); err != nil {
 		return nil, err
 	}
-
-	resp, err := mpuc.hc.Do(httpReq.WithContext(ctx))
-	defer googleapi.CloseBody(resp)
+	query := "uploadId=" + neturl.QueryEscape(req.UploadID)
+	if req.PartNumberMarker > 0 {
+		query += "&part-number-marker=" + strconv.Itoa(req.PartNumberMarker)
+	}
+	if req.MaxParts > 0 {
+		query += "&max-parts=" + strconv.Itoa(req.MaxParts)
+	}
+	url := mpuc.requestURL(req.Bucket, req.Key, query)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
 	if err != nil {
 		return nil, err
 	}
-	if err := checkResponse(resp); err != nil {
+	mpuc.setUserProject(ctx, httpReq, req.Bucket)
+	if err := mpuc.setPayloadHash(httpReq, http.NoBody); err != nil {
 		return nil, err
 	}
 
-	result := &ListObjectPartsResult{}
-	xml := xml.NewDecoder(resp.Body)
-	if err := xml.Decode(result); err != nil {
-		respStrBuilder := &strings.Builder{}
-		// strings.Builder.Write does not return errors.
-		_ = resp.Write(respStrBuilder)
-		return nil, fmt.Errorf("failed to parse XML body from HTTP response: %v. Response: %v", err, respStrBuilder.String())
+	result, resp, err := doXML[ListObjectPartsResult](ctx, mpuc, OpListObjectParts, httpReq)
+	defer googleapi.CloseBody(resp)
+	if err != nil {
+		return nil, err
 	}
+	result.Correlation = correlationOf(ctx, resp)
 	return result, nil
 }
@@ -1,16 +1,23 @@
 package multipartclient
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httputil"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multiparttest"
 )
 
 var (
@@ -169,6 +176,7 @@ func TestUploadObjectPart(t *testing.T) {
 		req           *UploadObjectPartRequest
 		wantHttpReq   string
 		httpResp      *http.Response
+		wantResult    *UploadObjectPartResult
 		wantResultErr error
 	}{
 		{
@@ -186,7 +194,19 @@ func TestUploadObjectPart(t *testing.T) {
 			httpResp: &http.Response{
 				Status:     http.StatusText(http.StatusOK),
 				StatusCode: http.StatusOK,
-				Body:       http.NoBody,
+				Header: http.Header{
+					"Etag":        []string{"\"39a59594290b0f9a30662a56d695b71d\""},
+					"X-Goog-Hash": []string{"crc32c=n03x6A==,md5=OaWVlCkLD5owZipW1pW3HQ=="},
+				},
+				Body: http.NoBody,
+			},
+			wantResult: &UploadObjectPartResult{
+				ETag: "\"39a59594290b0f9a30662a56d695b71d\"",
+				Hashes: gcshash.Sums{
+					CRC32C:    0x9f4df1e8,
+					HasCRC32C: true,
+					MD5:       []byte{0x39, 0xa5, 0x95, 0x94, 0x29, 0x0b, 0x0f, 0x9a, 0x30, 0x66, 0x2a, 0x56, 0xd6, 0x95, 0xb7, 0x1d},
+				},
 			},
 			wantResultErr: nil,
 		},
@@ -207,7 +227,8 @@ func TestUploadObjectPart(t *testing.T) {
 				StatusCode: http.StatusNotFound,
 				Body:       toBody("Bucket not found."),
 			},
-			wantResultErr: errors.New("Bucket not found."),
+			wantResult:    nil,
+			wantResultErr: &Error{StatusCode: http.StatusNotFound, Message: "Bucket not found."},
 		},
 	}
 
@@ -223,7 +244,7 @@ func TestUploadObjectPart(t *testing.T) {
 			}
 			mpuc := New(hc)
 			ctx := context.Background()
-			err := mpuc.UploadObjectPart(ctx, tc.req)
+			result, err := mpuc.UploadObjectPart(ctx, tc.req)
 
 			// Verify request.
 			if diff := cmp.Diff(tc.wantHttpReq, trans.recordedHttpReq, strCompareOpt); diff != "" {
@@ -231,6 +252,9 @@ func TestUploadObjectPart(t *testing.T) {
 			}
 
 			// Verify response.
+			if diff := cmp.Diff(tc.wantResult, result); diff != "" {
+				t.Errorf("unexpected diff for result: (-want, +got):\n%s", diff)
+			}
 			if diff := cmp.Diff(tc.wantResultErr, err, compareErrorValues()); diff != "" {
 				t.Errorf("unexpected diff for error: (-want, +got):\n%s", diff)
 			}
@@ -271,12 +295,12 @@ func TestCompleteMultipartUpload(t *testing.T) {
 				"Host: storage.googleapis.com\n" +
 				"\n" +
 				"<CompleteMultipartUpload>\n" +
-				"  <Parts>\n" +
+				"  <Part>\n" +
 				"    <PartNumber>1</PartNumber>\n" +
-				"  </Parts>\n" +
-				"  <Parts>\n" +
+				"  </Part>\n" +
+				"  <Part>\n" +
 				"    <PartNumber>2</PartNumber>\n" +
-				"  </Parts>\n" +
+				"  </Part>\n" +
 				"</CompleteMultipartUpload>",
 			httpResp: &http.Response{
 				Status:     http.StatusText(http.StatusOK),
@@ -285,6 +309,55 @@ func TestCompleteMultipartUpload(t *testing.T) {
 			wantResult:    &CompleteMultipartUploadResult{},
 			wantResultErr: nil,
 		},
+		{
+			name: "Successful request with result body",
+			req: &CompleteMultipartUploadRequest{
+				Bucket:   "test-bucket",
+				Key:      "object.txt",
+				UploadID: "test-upload-id",
+				Body: CompleteMultipartUploadBody{
+					Parts: []CompletePart{
+						{
+							PartNumber: 1,
+							ETag:       "\"7778aef83f66abc1fa1e8477f296d394\"",
+						},
+					},
+				},
+			},
+			wantHttpReq: "POST /test-bucket/object.txt?uploadId=test-upload-id HTTP/1.1\n" +
+				"Host: storage.googleapis.com\n" +
+				"\n" +
+				"<CompleteMultipartUpload>\n" +
+				"  <Part>\n" +
+				"    <PartNumber>1</PartNumber>\n" +
+				"    <ETag>&#34;7778aef83f66abc1fa1e8477f296d394&#34;</ETag>\n" +
+				"  </Part>\n" +
+				"</CompleteMultipartUpload>",
+			httpResp: &http.Response{
+				Status:     http.StatusText(http.StatusOK),
+				StatusCode: http.StatusOK,
+				Header: http.Header{
+					"X-Goog-Generation":              []string{"1700000000000001"},
+					"X-Goog-Encryption-Kms-Key-Name": []string{"projects/p/locations/us/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1"},
+				},
+				Body: toBody("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n" +
+					"<CompleteMultipartUploadResult xmlns=\"http://s3.amazonaws.com/doc/2006-03-01/\">\n" +
+					"  <Location>http://travel-maps.storage.googleapis.com/paris.jpg</Location>\n" +
+					"  <Bucket>travel-maps</Bucket>\n" +
+					"  <Key>paris.jpg</Key>\n" +
+					"  <ETag>\"7fc8ba7a2f2ffbd4d5e8c0bbaf5bd2a0-1\"</ETag>\n" +
+					"</CompleteMultipartUploadResult>"),
+			},
+			wantResult: &CompleteMultipartUploadResult{
+				Location:   "http://travel-maps.storage.googleapis.com/paris.jpg",
+				Bucket:     "travel-maps",
+				Key:        "paris.jpg",
+				ETag:       "\"7fc8ba7a2f2ffbd4d5e8c0bbaf5bd2a0-1\"",
+				Generation: 1700000000000001,
+				KMSKeyName: "projects/p/locations/us/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1",
+			},
+			wantResultErr: nil,
+		},
 	}
 
 	for _, tc := range tests {
@@ -360,7 +433,7 @@ func TestAbortMultipartUploads(t *testing.T) {
 			wantHttpReq: "DELETE /bucket1/some/file/with/a/path/file1.txt?uploadId=my-upload-id HTTP/1.1\n" +
 				"Host: storage.googleapis.com\n\n",
 			httpResp:   notFound,
-			wantResult: errors.New("Not Found"),
+			wantResult: &Error{StatusCode: http.StatusNotFound, Message: "Not Found"},
 		},
 	}
 
@@ -437,15 +510,54 @@ func TestListMultipartUploads(t *testing.T) {
 			wantResult: &ListMultipartUploadsResult{
 				Uploads: []ListUpload{
 					{
-						UploadID: "VXBsb2FkIElEIGZvciBlbHZpbmcncyBteS1tb3ZpZS5tMnRzIHVwbG9hZA",
+						Key:       "paris.jpeg",
+						UploadID:  "VXBsb2FkIElEIGZvciBlbHZpbmcncyBteS1tb3ZpZS5tMnRzIHVwbG9hZA",
+						Initiated: time.Date(2021, 11, 10, 20, 48, 33, 0, time.UTC),
 					},
 					{
-						UploadID: "YW55IGlkZWEgd2h5IGVsdmluZydzIHVwbG9hZCBmYWlsZWQ",
+						Key:       "tokyo.jpeg",
+						UploadID:  "YW55IGlkZWEgd2h5IGVsdmluZydzIHVwbG9hZCBmYWlsZWQ",
+						Initiated: time.Date(2021, 11, 10, 20, 49, 33, 0, time.UTC),
 					},
 				},
+				IsTruncated:        true,
+				NextKeyMarker:      "cannes.jpeg",
+				NextUploadIDMarker: "YW55IGlkZWEgd2h5IGVsdmluZydzIHVwbG9hZCBmYWlsZWQ",
 			},
 			wantResultErr: nil,
 		},
+		{
+			name: "List with markers",
+			req: &ListMultipartUploadsRequest{
+				Bucket:         "bucket1",
+				KeyMarker:      "cannes.jpeg",
+				UploadIDMarker: "YW55",
+				MaxUploads:     2,
+			},
+			wantHttpReq: "GET /bucket1/?uploads&key-marker=cannes.jpeg&upload-id-marker=YW55&max-uploads=2 HTTP/1.1\n" +
+				"Host: storage.googleapis.com\n\n",
+			httpResp: &http.Response{
+				Status:     http.StatusText(http.StatusOK),
+				StatusCode: http.StatusOK,
+				Body:       toBody("<ListMultipartUploadsResult></ListMultipartUploadsResult>"),
+			},
+			wantResult: &ListMultipartUploadsResult{},
+		},
+		{
+			name: "List with a prefix",
+			req: &ListMultipartUploadsRequest{
+				Bucket: "bucket1",
+				Prefix: "logs/2024 &",
+			},
+			wantHttpReq: "GET /bucket1/?uploads&prefix=logs%2F2024+%26 HTTP/1.1\n" +
+				"Host: storage.googleapis.com\n\n",
+			httpResp: &http.Response{
+				Status:     http.StatusText(http.StatusOK),
+				StatusCode: http.StatusOK,
+				Body:       toBody("<ListMultipartUploadsResult></ListMultipartUploadsResult>"),
+			},
+			wantResult: &ListMultipartUploadsResult{},
+		},
 	}
 
 	for _, tc := range tests {
@@ -508,12 +620,12 @@ func TestListObjectParts(t *testing.T) {
 				StatusCode: http.StatusOK,
 				Body: toBody("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n" +
 					"<ListPartsResult>\n" +
-					"  <Parts>\n" +
+					"  <Part>\n" +
 					"    <PartNumber>1</PartNumber>\n" +
-					"  </Parts>\n" +
-					"  <Parts>\n" +
+					"  </Part>\n" +
+					"  <Part>\n" +
 					"    <PartNumber>2</PartNumber>\n" +
-					"  </Parts>\n" +
+					"  </Part>\n" +
 					"</ListPartsResult>"),
 			},
 			wantResult: &ListObjectPartsResult{
@@ -563,3 +675,157 @@ func TestListObjectParts(t *testing.T) {
 		})
 	}
 }
+
+func TestUploadPartCopy(t *testing.T) {
+	tests := []struct {
+		name          string
+		req           *UploadPartCopyRequest
+		wantHttpReq   string
+		httpResp      *http.Response
+		wantResult    *CopyPartResult
+		wantResultErr error
+	}{
+		{
+			name: "Copy a range",
+			req: &UploadPartCopyRequest{
+				Bucket:       "bucket1",
+				Key:          "dst.txt",
+				PartNumber:   3,
+				UploadID:     "my-upload-id",
+				SourceBucket: "bucket2",
+				SourceKey:    "path/src.txt",
+				SourceRange:  &ByteRange{Offset: 100, Length: 50},
+			},
+			wantHttpReq: "PUT /bucket1/dst.txt?partNumber=3&uploadId=my-upload-id HTTP/1.1\n" +
+				"Host: storage.googleapis.com\n" +
+				"X-Goog-Copy-Source: /bucket2/path/src.txt\n" +
+				"X-Goog-Copy-Source-Range: bytes=100-149\n\n",
+			httpResp: &http.Response{
+				Status:     http.StatusText(http.StatusOK),
+				StatusCode: http.StatusOK,
+				Body: toBody("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n" +
+					"<CopyPartResult>\n" +
+					"  <LastModified>2021-11-10T20:48:33.000Z</LastModified>\n" +
+					"  <ETag>\"7fc8ba7a2f2ffbd4d5e8c0bbaf5bd2a0\"</ETag>\n" +
+					"</CopyPartResult>"),
+			},
+			wantResult: &CopyPartResult{
+				LastModified: "2021-11-10T20:48:33.000Z",
+				ETag:         "\"7fc8ba7a2f2ffbd4d5e8c0bbaf5bd2a0\"",
+			},
+			wantResultErr: nil,
+		},
+		{
+			name: "Copy a whole object with an error",
+			req: &UploadPartCopyRequest{
+				Bucket:       "bucket1",
+				Key:          "dst.txt",
+				PartNumber:   1,
+				UploadID:     "my-upload-id",
+				SourceBucket: "bucket2",
+				SourceKey:    "src.txt",
+			},
+			wantHttpReq: "PUT /bucket1/dst.txt?partNumber=1&uploadId=my-upload-id HTTP/1.1\n" +
+				"Host: storage.googleapis.com\n" +
+				"X-Goog-Copy-Source: /bucket2/src.txt\n\n",
+			httpResp: &http.Response{
+				Status:     http.StatusText(http.StatusNotFound),
+				StatusCode: http.StatusNotFound,
+				Body:       toBody("Object not found."),
+			},
+			wantResult:    nil,
+			wantResultErr: &Error{StatusCode: http.StatusNotFound, Message: "Object not found."},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			trans := &mockTransport{
+				t:               t,
+				respondWithHttp: tc.httpResp,
+				respondWithErr:  nil,
+			}
+			hc := &http.Client{
+				Transport: trans,
+			}
+			mpuc := New(hc)
+			ctx := context.Background()
+			result, err := mpuc.UploadPartCopy(ctx, tc.req)
+
+			// Verify request.
+			if diff := cmp.Diff(tc.wantHttpReq, trans.recordedHttpReq, strCompareOpt); diff != "" {
+				t.Errorf("unexpected diff for http request: (-want, +got):\n%s", diff)
+			}
+
+			// Verify response.
+			opts := []cmp.Option{
+				cmpopts.IgnoreFields(CopyPartResult{}, "XMLName"),
+			}
+			if diff := cmp.Diff(tc.wantResult, result, opts...); diff != "" {
+				t.Errorf("unexpected diff for result: (-want, +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantResultErr, err, compareErrorValues()); diff != "" {
+				t.Errorf("unexpected diff for error: (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestConcurrentUse shares one client, with most of its options set, between
+// goroutines uploading at once. Run it with -race.
+func TestConcurrentUse(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	var (
+		mu      sync.Mutex
+		records int
+	)
+	mpuc := New(srv.Client(),
+		WithHashWorkers(2),
+		WithStrictValidation(),
+		WithRetry(RetryConfig{MaxAttempts: 3, Backoff: testBackoff}),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil)), LogOptions{}),
+		WithDebug(io.Discard, 16),
+		WithAuditHook(func(AuditRecord) {
+			mu.Lock()
+			defer mu.Unlock()
+			records++
+		}),
+	)
+
+	ctx := context.Background()
+	const uploaders = 8
+	var wg sync.WaitGroup
+	for i := 0; i < uploaders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := mpuc.UploadFSWithOptions(ctx, "bucket1", fmt.Sprint("u", i), testFS, &UploadFSOptions{PartSize: MinPartSize, Concurrency: 2}); err != nil {
+				t.Errorf("uploader %d: %v", i, err)
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			mpuc.Stats()
+		}
+	}()
+	wg.Wait()
+	<-done
+
+	for i := 0; i < uploaders; i++ {
+		got, ok := srv.Object("bucket1", fmt.Sprintf("u%d/big.bin", i))
+		if want := testFS["big.bin"].Data; !ok || !bytes.Equal(got, want) {
+			t.Errorf("got object u%d/big.bin of %d bytes (exists %v), want %d bytes", i, len(got), ok, len(want))
+		}
+	}
+	if got := mpuc.Stats().Failures; got[FailureClient]+got[FailureServer]+got[FailureNetwork] != 0 {
+		t.Errorf("got failures %v, want none", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if records == 0 {
+		t.Error("got no audit records")
+	}
+}
@@ -0,0 +1,1152 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: api.go
+//
+// Generated by this command:
+//
+//	mockgen -source=api.go -destination=multipartclientmock/multipartclientmock.go -package=multipartclientmock -typed
+//
+
+// Package multipartclientmock is a generated GoMock package.
+package multipartclientmock
+
+import (
+	context "context"
+	io "io"
+	fs "io/fs"
+	reflect "reflect"
+
+	multipartclient "github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockMultipartAPI is a mock of MultipartAPI interface.
+type MockMultipartAPI struct {
+	ctrl     *gomock.Controller
+	recorder *MockMultipartAPIMockRecorder
+}
+
+// MockMultipartAPIMockRecorder is the mock recorder for MockMultipartAPI.
+type MockMultipartAPIMockRecorder struct {
+	mock *MockMultipartAPI
+}
+
+// NewMockMultipartAPI creates a new mock instance.
+func NewMockMultipartAPI(ctrl *gomock.Controller) *MockMultipartAPI {
+	mock := &MockMultipartAPI{ctrl: ctrl}
+	mock.recorder = &MockMultipartAPIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMultipartAPI) EXPECT() *MockMultipartAPIMockRecorder {
+	return m.recorder
+}
+
+// AbortMultipartUpload mocks base method.
+func (m *MockMultipartAPI) AbortMultipartUpload(ctx context.Context, req *multipartclient.AbortMultipartUploadRequest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AbortMultipartUpload", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AbortMultipartUpload indicates an expected call of AbortMultipartUpload.
+func (mr *MockMultipartAPIMockRecorder) AbortMultipartUpload(ctx, req any) *MockMultipartAPIAbortMultipartUploadCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AbortMultipartUpload", reflect.TypeOf((*MockMultipartAPI)(nil).AbortMultipartUpload), ctx, req)
+	return &MockMultipartAPIAbortMultipartUploadCall{Call: call}
+}
+
+// MockMultipartAPIAbortMultipartUploadCall wrap *gomock.Call
+type MockMultipartAPIAbortMultipartUploadCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockMultipartAPIAbortMultipartUploadCall) Return(arg0 error) *MockMultipartAPIAbortMultipartUploadCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockMultipartAPIAbortMultipartUploadCall) Do(f func(context.Context, *multipartclient.AbortMultipartUploadRequest) error) *MockMultipartAPIAbortMultipartUploadCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockMultipartAPIAbortMultipartUploadCall) DoAndReturn(f func(context.Context, *multipartclient.AbortMultipartUploadRequest) error) *MockMultipartAPIAbortMultipartUploadCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// AttachSession mocks base method.
+func (m *MockMultipartAPI) AttachSession(ctx context.Context, bucket, key, uploadID string) (*multipartclient.UploadSession, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AttachSession", ctx, bucket, key, uploadID)
+	ret0, _ := ret[0].(*multipartclient.UploadSession)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AttachSession indicates an expected call of AttachSession.
+func (mr *MockMultipartAPIMockRecorder) AttachSession(ctx, bucket, key, uploadID any) *MockMultipartAPIAttachSessionCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AttachSession", reflect.TypeOf((*MockMultipartAPI)(nil).AttachSession), ctx, bucket, key, uploadID)
+	return &MockMultipartAPIAttachSessionCall{Call: call}
+}
+
+// MockMultipartAPIAttachSessionCall wrap *gomock.Call
+type MockMultipartAPIAttachSessionCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockMultipartAPIAttachSessionCall) Return(arg0 *multipartclient.UploadSession, arg1 error) *MockMultipartAPIAttachSessionCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockMultipartAPIAttachSessionCall) Do(f func(context.Context, string, string, string) (*multipartclient.UploadSession, error)) *MockMultipartAPIAttachSessionCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockMultipartAPIAttachSessionCall) DoAndReturn(f func(context.Context, string, string, string) (*multipartclient.UploadSession, error)) *MockMultipartAPIAttachSessionCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// Bucket mocks base method.
+func (m *MockMultipartAPI) Bucket(name string) *multipartclient.BucketHandle {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Bucket", name)
+	ret0, _ := ret[0].(*multipartclient.BucketHandle)
+	return ret0
+}
+
+// Bucket indicates an expected call of Bucket.
+func (mr *MockMultipartAPIMockRecorder) Bucket(name any) *MockMultipartAPIBucketCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Bucket", reflect.TypeOf((*MockMultipartAPI)(nil).Bucket), name)
+	return &MockMultipartAPIBucketCall{Call: call}
+}
+
+// MockMultipartAPIBucketCall wrap *gomock.Call
+type MockMultipartAPIBucketCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockMultipartAPIBucketCall) Return(arg0 *multipartclient.BucketHandle) *MockMultipartAPIBucketCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockMultipartAPIBucketCall) Do(f func(string) *multipartclient.BucketHandle) *MockMultipartAPIBucketCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockMultipartAPIBucketCall) DoAndReturn(f func(string) *multipartclient.BucketHandle) *MockMultipartAPIBucketCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// CompleteMultipartUpload mocks base method.
+func (m *MockMultipartAPI) CompleteMultipartUpload(ctx context.Context, req *multipartclient.CompleteMultipartUploadRequest) (*multipartclient.CompleteMultipartUploadResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteMultipartUpload", ctx, req)
+	ret0, _ := ret[0].(*multipartclient.CompleteMultipartUploadResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CompleteMultipartUpload indicates an expected call of CompleteMultipartUpload.
+func (mr *MockMultipartAPIMockRecorder) CompleteMultipartUpload(ctx, req any) *MockMultipartAPICompleteMultipartUploadCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteMultipartUpload", reflect.TypeOf((*MockMultipartAPI)(nil).CompleteMultipartUpload), ctx, req)
+	return &MockMultipartAPICompleteMultipartUploadCall{Call: call}
+}
+
+// MockMultipartAPICompleteMultipartUploadCall wrap *gomock.Call
+type MockMultipartAPICompleteMultipartUploadCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockMultipartAPICompleteMultipartUploadCall) Return(arg0 *multipartclient.CompleteMultipartUploadResult, arg1 error) *MockMultipartAPICompleteMultipartUploadCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockMultipartAPICompleteMultipartUploadCall) Do(f func(context.Context, *multipartclient.CompleteMultipartUploadRequest) (*multipartclient.CompleteMultipartUploadResult, error)) *MockMultipartAPICompleteMultipartUploadCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockMultipartAPICompleteMultipartUploadCall) DoAndReturn(f func(context.Context, *multipartclient.CompleteMultipartUploadRequest) (*multipartclient.CompleteMultipartUploadResult, error)) *MockMultipartAPICompleteMultipartUploadCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// HealthCheck mocks base method.
+func (m *MockMultipartAPI) HealthCheck(ctx context.Context, bucket string) (*multipartclient.HealthCheckResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HealthCheck", ctx, bucket)
+	ret0, _ := ret[0].(*multipartclient.HealthCheckResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HealthCheck indicates an expected call of HealthCheck.
+func (mr *MockMultipartAPIMockRecorder) HealthCheck(ctx, bucket any) *MockMultipartAPIHealthCheckCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HealthCheck", reflect.TypeOf((*MockMultipartAPI)(nil).HealthCheck), ctx, bucket)
+	return &MockMultipartAPIHealthCheckCall{Call: call}
+}
+
+// MockMultipartAPIHealthCheckCall wrap *gomock.Call
+type MockMultipartAPIHealthCheckCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockMultipartAPIHealthCheckCall) Return(arg0 *multipartclient.HealthCheckResult, arg1 error) *MockMultipartAPIHealthCheckCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockMultipartAPIHealthCheckCall) Do(f func(context.Context, string) (*multipartclient.HealthCheckResult, error)) *MockMultipartAPIHealthCheckCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockMultipartAPIHealthCheckCall) DoAndReturn(f func(context.Context, string) (*multipartclient.HealthCheckResult, error)) *MockMultipartAPIHealthCheckCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// InitiateMultipartUpload mocks base method.
+func (m *MockMultipartAPI) InitiateMultipartUpload(ctx context.Context, req *multipartclient.InitiateMultipartUploadRequest) (*multipartclient.InitiateMultipartUploadResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InitiateMultipartUpload", ctx, req)
+	ret0, _ := ret[0].(*multipartclient.InitiateMultipartUploadResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InitiateMultipartUpload indicates an expected call of InitiateMultipartUpload.
+func (mr *MockMultipartAPIMockRecorder) InitiateMultipartUpload(ctx, req any) *MockMultipartAPIInitiateMultipartUploadCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InitiateMultipartUpload", reflect.TypeOf((*MockMultipartAPI)(nil).InitiateMultipartUpload), ctx, req)
+	return &MockMultipartAPIInitiateMultipartUploadCall{Call: call}
+}
+
+// MockMultipartAPIInitiateMultipartUploadCall wrap *gomock.Call
+type MockMultipartAPIInitiateMultipartUploadCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockMultipartAPIInitiateMultipartUploadCall) Return(arg0 *multipartclient.InitiateMultipartUploadResult, arg1 error) *MockMultipartAPIInitiateMultipartUploadCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockMultipartAPIInitiateMultipartUploadCall) Do(f func(context.Context, *multipartclient.InitiateMultipartUploadRequest) (*multipartclient.InitiateMultipartUploadResult, error)) *MockMultipartAPIInitiateMultipartUploadCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockMultipartAPIInitiateMultipartUploadCall) DoAndReturn(f func(context.Context, *multipartclient.InitiateMultipartUploadRequest) (*multipartclient.InitiateMultipartUploadResult, error)) *MockMultipartAPIInitiateMultipartUploadCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// ListAllObjectParts mocks base method.
+func (m *MockMultipartAPI) ListAllObjectParts(ctx context.Context, req *multipartclient.ListObjectPartsRequest) ([]multipartclient.CompletePart, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAllObjectParts", ctx, req)
+	ret0, _ := ret[0].([]multipartclient.CompletePart)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAllObjectParts indicates an expected call of ListAllObjectParts.
+func (mr *MockMultipartAPIMockRecorder) ListAllObjectParts(ctx, req any) *MockMultipartAPIListAllObjectPartsCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAllObjectParts", reflect.TypeOf((*MockMultipartAPI)(nil).ListAllObjectParts), ctx, req)
+	return &MockMultipartAPIListAllObjectPartsCall{Call: call}
+}
+
+// MockMultipartAPIListAllObjectPartsCall wrap *gomock.Call
+type MockMultipartAPIListAllObjectPartsCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockMultipartAPIListAllObjectPartsCall) Return(arg0 []multipartclient.CompletePart, arg1 error) *MockMultipartAPIListAllObjectPartsCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockMultipartAPIListAllObjectPartsCall) Do(f func(context.Context, *multipartclient.ListObjectPartsRequest) ([]multipartclient.CompletePart, error)) *MockMultipartAPIListAllObjectPartsCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockMultipartAPIListAllObjectPartsCall) DoAndReturn(f func(context.Context, *multipartclient.ListObjectPartsRequest) ([]multipartclient.CompletePart, error)) *MockMultipartAPIListAllObjectPartsCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// ListMultipartUploads mocks base method.
+func (m *MockMultipartAPI) ListMultipartUploads(ctx context.Context, req *multipartclient.ListMultipartUploadsRequest) (*multipartclient.ListMultipartUploadsResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListMultipartUploads", ctx, req)
+	ret0, _ := ret[0].(*multipartclient.ListMultipartUploadsResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListMultipartUploads indicates an expected call of ListMultipartUploads.
+func (mr *MockMultipartAPIMockRecorder) ListMultipartUploads(ctx, req any) *MockMultipartAPIListMultipartUploadsCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListMultipartUploads", reflect.TypeOf((*MockMultipartAPI)(nil).ListMultipartUploads), ctx, req)
+	return &MockMultipartAPIListMultipartUploadsCall{Call: call}
+}
+
+// MockMultipartAPIListMultipartUploadsCall wrap *gomock.Call
+type MockMultipartAPIListMultipartUploadsCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockMultipartAPIListMultipartUploadsCall) Return(arg0 *multipartclient.ListMultipartUploadsResult, arg1 error) *MockMultipartAPIListMultipartUploadsCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockMultipartAPIListMultipartUploadsCall) Do(f func(context.Context, *multipartclient.ListMultipartUploadsRequest) (*multipartclient.ListMultipartUploadsResult, error)) *MockMultipartAPIListMultipartUploadsCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockMultipartAPIListMultipartUploadsCall) DoAndReturn(f func(context.Context, *multipartclient.ListMultipartUploadsRequest) (*multipartclient.ListMultipartUploadsResult, error)) *MockMultipartAPIListMultipartUploadsCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// ListMultipartUploadsPages mocks base method.
+func (m *MockMultipartAPI) ListMultipartUploadsPages(ctx context.Context, req *multipartclient.ListMultipartUploadsRequest, fn func(*multipartclient.ListMultipartUploadsResult) bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListMultipartUploadsPages", ctx, req, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ListMultipartUploadsPages indicates an expected call of ListMultipartUploadsPages.
+func (mr *MockMultipartAPIMockRecorder) ListMultipartUploadsPages(ctx, req, fn any) *MockMultipartAPIListMultipartUploadsPagesCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListMultipartUploadsPages", reflect.TypeOf((*MockMultipartAPI)(nil).ListMultipartUploadsPages), ctx, req, fn)
+	return &MockMultipartAPIListMultipartUploadsPagesCall{Call: call}
+}
+
+// MockMultipartAPIListMultipartUploadsPagesCall wrap *gomock.Call
+type MockMultipartAPIListMultipartUploadsPagesCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockMultipartAPIListMultipartUploadsPagesCall) Return(arg0 error) *MockMultipartAPIListMultipartUploadsPagesCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockMultipartAPIListMultipartUploadsPagesCall) Do(f func(context.Context, *multipartclient.ListMultipartUploadsRequest, func(*multipartclient.ListMultipartUploadsResult) bool) error) *MockMultipartAPIListMultipartUploadsPagesCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockMultipartAPIListMultipartUploadsPagesCall) DoAndReturn(f func(context.Context, *multipartclient.ListMultipartUploadsRequest, func(*multipartclient.ListMultipartUploadsResult) bool) error) *MockMultipartAPIListMultipartUploadsPagesCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// ListObjectParts mocks base method.
+func (m *MockMultipartAPI) ListObjectParts(ctx context.Context, req *multipartclient.ListObjectPartsRequest) (*multipartclient.ListObjectPartsResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListObjectParts", ctx, req)
+	ret0, _ := ret[0].(*multipartclient.ListObjectPartsResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListObjectParts indicates an expected call of ListObjectParts.
+func (mr *MockMultipartAPIMockRecorder) ListObjectParts(ctx, req any) *MockMultipartAPIListObjectPartsCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListObjectParts", reflect.TypeOf((*MockMultipartAPI)(nil).ListObjectParts), ctx, req)
+	return &MockMultipartAPIListObjectPartsCall{Call: call}
+}
+
+// MockMultipartAPIListObjectPartsCall wrap *gomock.Call
+type MockMultipartAPIListObjectPartsCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockMultipartAPIListObjectPartsCall) Return(arg0 *multipartclient.ListObjectPartsResult, arg1 error) *MockMultipartAPIListObjectPartsCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockMultipartAPIListObjectPartsCall) Do(f func(context.Context, *multipartclient.ListObjectPartsRequest) (*multipartclient.ListObjectPartsResult, error)) *MockMultipartAPIListObjectPartsCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockMultipartAPIListObjectPartsCall) DoAndReturn(f func(context.Context, *multipartclient.ListObjectPartsRequest) (*multipartclient.ListObjectPartsResult, error)) *MockMultipartAPIListObjectPartsCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// MarshalCompleteBody mocks base method.
+func (m *MockMultipartAPI) MarshalCompleteBody(body multipartclient.CompleteMultipartUploadBody) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarshalCompleteBody", body)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarshalCompleteBody indicates an expected call of MarshalCompleteBody.
+func (mr *MockMultipartAPIMockRecorder) MarshalCompleteBody(body any) *MockMultipartAPIMarshalCompleteBodyCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarshalCompleteBody", reflect.TypeOf((*MockMultipartAPI)(nil).MarshalCompleteBody), body)
+	return &MockMultipartAPIMarshalCompleteBodyCall{Call: call}
+}
+
+// MockMultipartAPIMarshalCompleteBodyCall wrap *gomock.Call
+type MockMultipartAPIMarshalCompleteBodyCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockMultipartAPIMarshalCompleteBodyCall) Return(arg0 []byte, arg1 error) *MockMultipartAPIMarshalCompleteBodyCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockMultipartAPIMarshalCompleteBodyCall) Do(f func(multipartclient.CompleteMultipartUploadBody) ([]byte, error)) *MockMultipartAPIMarshalCompleteBodyCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockMultipartAPIMarshalCompleteBodyCall) DoAndReturn(f func(multipartclient.CompleteMultipartUploadBody) ([]byte, error)) *MockMultipartAPIMarshalCompleteBodyCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// NewSession mocks base method.
+func (m *MockMultipartAPI) NewSession(ctx context.Context, req *multipartclient.InitiateMultipartUploadRequest) (*multipartclient.UploadSession, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NewSession", ctx, req)
+	ret0, _ := ret[0].(*multipartclient.UploadSession)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NewSession indicates an expected call of NewSession.
+func (mr *MockMultipartAPIMockRecorder) NewSession(ctx, req any) *MockMultipartAPINewSessionCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewSession", reflect.TypeOf((*MockMultipartAPI)(nil).NewSession), ctx, req)
+	return &MockMultipartAPINewSessionCall{Call: call}
+}
+
+// MockMultipartAPINewSessionCall wrap *gomock.Call
+type MockMultipartAPINewSessionCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockMultipartAPINewSessionCall) Return(arg0 *multipartclient.UploadSession, arg1 error) *MockMultipartAPINewSessionCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockMultipartAPINewSessionCall) Do(f func(context.Context, *multipartclient.InitiateMultipartUploadRequest) (*multipartclient.UploadSession, error)) *MockMultipartAPINewSessionCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockMultipartAPINewSessionCall) DoAndReturn(f func(context.Context, *multipartclient.InitiateMultipartUploadRequest) (*multipartclient.UploadSession, error)) *MockMultipartAPINewSessionCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// Parts mocks base method.
+func (m *MockMultipartAPI) Parts(ctx context.Context, req *multipartclient.ListObjectPartsRequest) *multipartclient.PartIterator {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Parts", ctx, req)
+	ret0, _ := ret[0].(*multipartclient.PartIterator)
+	return ret0
+}
+
+// Parts indicates an expected call of Parts.
+func (mr *MockMultipartAPIMockRecorder) Parts(ctx, req any) *MockMultipartAPIPartsCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Parts", reflect.TypeOf((*MockMultipartAPI)(nil).Parts), ctx, req)
+	return &MockMultipartAPIPartsCall{Call: call}
+}
+
+// MockMultipartAPIPartsCall wrap *gomock.Call
+type MockMultipartAPIPartsCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockMultipartAPIPartsCall) Return(arg0 *multipartclient.PartIterator) *MockMultipartAPIPartsCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockMultipartAPIPartsCall) Do(f func(context.Context, *multipartclient.ListObjectPartsRequest) *multipartclient.PartIterator) *MockMultipartAPIPartsCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockMultipartAPIPartsCall) DoAndReturn(f func(context.Context, *multipartclient.ListObjectPartsRequest) *multipartclient.PartIterator) *MockMultipartAPIPartsCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// PatchObjectMetadata mocks base method.
+func (m *MockMultipartAPI) PatchObjectMetadata(ctx context.Context, req *multipartclient.PatchObjectMetadataRequest) (*multipartclient.ObjectAttrs, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PatchObjectMetadata", ctx, req)
+	ret0, _ := ret[0].(*multipartclient.ObjectAttrs)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PatchObjectMetadata indicates an expected call of PatchObjectMetadata.
+func (mr *MockMultipartAPIMockRecorder) PatchObjectMetadata(ctx, req any) *MockMultipartAPIPatchObjectMetadataCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PatchObjectMetadata", reflect.TypeOf((*MockMultipartAPI)(nil).PatchObjectMetadata), ctx, req)
+	return &MockMultipartAPIPatchObjectMetadataCall{Call: call}
+}
+
+// MockMultipartAPIPatchObjectMetadataCall wrap *gomock.Call
+type MockMultipartAPIPatchObjectMetadataCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockMultipartAPIPatchObjectMetadataCall) Return(arg0 *multipartclient.ObjectAttrs, arg1 error) *MockMultipartAPIPatchObjectMetadataCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockMultipartAPIPatchObjectMetadataCall) Do(f func(context.Context, *multipartclient.PatchObjectMetadataRequest) (*multipartclient.ObjectAttrs, error)) *MockMultipartAPIPatchObjectMetadataCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockMultipartAPIPatchObjectMetadataCall) DoAndReturn(f func(context.Context, *multipartclient.PatchObjectMetadataRequest) (*multipartclient.ObjectAttrs, error)) *MockMultipartAPIPatchObjectMetadataCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// ResumeSession mocks base method.
+func (m *MockMultipartAPI) ResumeSession(ctx context.Context, cp *multipartclient.Checkpoint) (*multipartclient.UploadSession, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResumeSession", ctx, cp)
+	ret0, _ := ret[0].(*multipartclient.UploadSession)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResumeSession indicates an expected call of ResumeSession.
+func (mr *MockMultipartAPIMockRecorder) ResumeSession(ctx, cp any) *MockMultipartAPIResumeSessionCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResumeSession", reflect.TypeOf((*MockMultipartAPI)(nil).ResumeSession), ctx, cp)
+	return &MockMultipartAPIResumeSessionCall{Call: call}
+}
+
+// MockMultipartAPIResumeSessionCall wrap *gomock.Call
+type MockMultipartAPIResumeSessionCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockMultipartAPIResumeSessionCall) Return(arg0 *multipartclient.UploadSession, arg1 error) *MockMultipartAPIResumeSessionCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockMultipartAPIResumeSessionCall) Do(f func(context.Context, *multipartclient.Checkpoint) (*multipartclient.UploadSession, error)) *MockMultipartAPIResumeSessionCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockMultipartAPIResumeSessionCall) DoAndReturn(f func(context.Context, *multipartclient.Checkpoint) (*multipartclient.UploadSession, error)) *MockMultipartAPIResumeSessionCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// Rewrite mocks base method.
+func (m *MockMultipartAPI) Rewrite(ctx context.Context, src multipartclient.ObjectRef, req *multipartclient.InitiateMultipartUploadRequest, partPlan []multipartclient.ByteRange) (*multipartclient.CompleteMultipartUploadResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rewrite", ctx, src, req, partPlan)
+	ret0, _ := ret[0].(*multipartclient.CompleteMultipartUploadResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Rewrite indicates an expected call of Rewrite.
+func (mr *MockMultipartAPIMockRecorder) Rewrite(ctx, src, req, partPlan any) *MockMultipartAPIRewriteCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rewrite", reflect.TypeOf((*MockMultipartAPI)(nil).Rewrite), ctx, src, req, partPlan)
+	return &MockMultipartAPIRewriteCall{Call: call}
+}
+
+// MockMultipartAPIRewriteCall wrap *gomock.Call
+type MockMultipartAPIRewriteCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockMultipartAPIRewriteCall) Return(arg0 *multipartclient.CompleteMultipartUploadResult, arg1 error) *MockMultipartAPIRewriteCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockMultipartAPIRewriteCall) Do(f func(context.Context, multipartclient.ObjectRef, *multipartclient.InitiateMultipartUploadRequest, []multipartclient.ByteRange) (*multipartclient.CompleteMultipartUploadResult, error)) *MockMultipartAPIRewriteCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockMultipartAPIRewriteCall) DoAndReturn(f func(context.Context, multipartclient.ObjectRef, *multipartclient.InitiateMultipartUploadRequest, []multipartclient.ByteRange) (*multipartclient.CompleteMultipartUploadResult, error)) *MockMultipartAPIRewriteCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// RewriteWithOptions mocks base method.
+func (m *MockMultipartAPI) RewriteWithOptions(ctx context.Context, src multipartclient.ObjectRef, req *multipartclient.InitiateMultipartUploadRequest, partPlan []multipartclient.ByteRange, opts *multipartclient.RewriteOptions) (*multipartclient.CompleteMultipartUploadResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RewriteWithOptions", ctx, src, req, partPlan, opts)
+	ret0, _ := ret[0].(*multipartclient.CompleteMultipartUploadResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RewriteWithOptions indicates an expected call of RewriteWithOptions.
+func (mr *MockMultipartAPIMockRecorder) RewriteWithOptions(ctx, src, req, partPlan, opts any) *MockMultipartAPIRewriteWithOptionsCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RewriteWithOptions", reflect.TypeOf((*MockMultipartAPI)(nil).RewriteWithOptions), ctx, src, req, partPlan, opts)
+	return &MockMultipartAPIRewriteWithOptionsCall{Call: call}
+}
+
+// MockMultipartAPIRewriteWithOptionsCall wrap *gomock.Call
+type MockMultipartAPIRewriteWithOptionsCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockMultipartAPIRewriteWithOptionsCall) Return(arg0 *multipartclient.CompleteMultipartUploadResult, arg1 error) *MockMultipartAPIRewriteWithOptionsCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockMultipartAPIRewriteWithOptionsCall) Do(f func(context.Context, multipartclient.ObjectRef, *multipartclient.InitiateMultipartUploadRequest, []multipartclient.ByteRange, *multipartclient.RewriteOptions) (*multipartclient.CompleteMultipartUploadResult, error)) *MockMultipartAPIRewriteWithOptionsCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockMultipartAPIRewriteWithOptionsCall) DoAndReturn(f func(context.Context, multipartclient.ObjectRef, *multipartclient.InitiateMultipartUploadRequest, []multipartclient.ByteRange, *multipartclient.RewriteOptions) (*multipartclient.CompleteMultipartUploadResult, error)) *MockMultipartAPIRewriteWithOptionsCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// StatObject mocks base method.
+func (m *MockMultipartAPI) StatObject(ctx context.Context, ref multipartclient.ObjectRef) (*multipartclient.ObjectAttrs, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StatObject", ctx, ref)
+	ret0, _ := ret[0].(*multipartclient.ObjectAttrs)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StatObject indicates an expected call of StatObject.
+func (mr *MockMultipartAPIMockRecorder) StatObject(ctx, ref any) *MockMultipartAPIStatObjectCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StatObject", reflect.TypeOf((*MockMultipartAPI)(nil).StatObject), ctx, ref)
+	return &MockMultipartAPIStatObjectCall{Call: call}
+}
+
+// MockMultipartAPIStatObjectCall wrap *gomock.Call
+type MockMultipartAPIStatObjectCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockMultipartAPIStatObjectCall) Return(arg0 *multipartclient.ObjectAttrs, arg1 error) *MockMultipartAPIStatObjectCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockMultipartAPIStatObjectCall) Do(f func(context.Context, multipartclient.ObjectRef) (*multipartclient.ObjectAttrs, error)) *MockMultipartAPIStatObjectCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockMultipartAPIStatObjectCall) DoAndReturn(f func(context.Context, multipartclient.ObjectRef) (*multipartclient.ObjectAttrs, error)) *MockMultipartAPIStatObjectCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// Stats mocks base method.
+func (m *MockMultipartAPI) Stats() multipartclient.Stats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats")
+	ret0, _ := ret[0].(multipartclient.Stats)
+	return ret0
+}
+
+// Stats indicates an expected call of Stats.
+func (mr *MockMultipartAPIMockRecorder) Stats() *MockMultipartAPIStatsCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockMultipartAPI)(nil).Stats))
+	return &MockMultipartAPIStatsCall{Call: call}
+}
+
+// MockMultipartAPIStatsCall wrap *gomock.Call
+type MockMultipartAPIStatsCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockMultipartAPIStatsCall) Return(arg0 multipartclient.Stats) *MockMultipartAPIStatsCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockMultipartAPIStatsCall) Do(f func() multipartclient.Stats) *MockMultipartAPIStatsCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockMultipartAPIStatsCall) DoAndReturn(f func() multipartclient.Stats) *MockMultipartAPIStatsCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// UploadFS mocks base method.
+func (m *MockMultipartAPI) UploadFS(ctx context.Context, bucket, prefix string, fsys fs.FS) ([]multipartclient.UploadedFile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UploadFS", ctx, bucket, prefix, fsys)
+	ret0, _ := ret[0].([]multipartclient.UploadedFile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UploadFS indicates an expected call of UploadFS.
+func (mr *MockMultipartAPIMockRecorder) UploadFS(ctx, bucket, prefix, fsys any) *MockMultipartAPIUploadFSCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadFS", reflect.TypeOf((*MockMultipartAPI)(nil).UploadFS), ctx, bucket, prefix, fsys)
+	return &MockMultipartAPIUploadFSCall{Call: call}
+}
+
+// MockMultipartAPIUploadFSCall wrap *gomock.Call
+type MockMultipartAPIUploadFSCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockMultipartAPIUploadFSCall) Return(arg0 []multipartclient.UploadedFile, arg1 error) *MockMultipartAPIUploadFSCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockMultipartAPIUploadFSCall) Do(f func(context.Context, string, string, fs.FS) ([]multipartclient.UploadedFile, error)) *MockMultipartAPIUploadFSCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockMultipartAPIUploadFSCall) DoAndReturn(f func(context.Context, string, string, fs.FS) ([]multipartclient.UploadedFile, error)) *MockMultipartAPIUploadFSCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// UploadFSWithOptions mocks base method.
+func (m *MockMultipartAPI) UploadFSWithOptions(ctx context.Context, bucket, prefix string, fsys fs.FS, opts *multipartclient.UploadFSOptions) ([]multipartclient.UploadedFile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UploadFSWithOptions", ctx, bucket, prefix, fsys, opts)
+	ret0, _ := ret[0].([]multipartclient.UploadedFile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UploadFSWithOptions indicates an expected call of UploadFSWithOptions.
+func (mr *MockMultipartAPIMockRecorder) UploadFSWithOptions(ctx, bucket, prefix, fsys, opts any) *MockMultipartAPIUploadFSWithOptionsCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadFSWithOptions", reflect.TypeOf((*MockMultipartAPI)(nil).UploadFSWithOptions), ctx, bucket, prefix, fsys, opts)
+	return &MockMultipartAPIUploadFSWithOptionsCall{Call: call}
+}
+
+// MockMultipartAPIUploadFSWithOptionsCall wrap *gomock.Call
+type MockMultipartAPIUploadFSWithOptionsCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockMultipartAPIUploadFSWithOptionsCall) Return(arg0 []multipartclient.UploadedFile, arg1 error) *MockMultipartAPIUploadFSWithOptionsCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockMultipartAPIUploadFSWithOptionsCall) Do(f func(context.Context, string, string, fs.FS, *multipartclient.UploadFSOptions) ([]multipartclient.UploadedFile, error)) *MockMultipartAPIUploadFSWithOptionsCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockMultipartAPIUploadFSWithOptionsCall) DoAndReturn(f func(context.Context, string, string, fs.FS, *multipartclient.UploadFSOptions) ([]multipartclient.UploadedFile, error)) *MockMultipartAPIUploadFSWithOptionsCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// UploadObjectPart mocks base method.
+func (m *MockMultipartAPI) UploadObjectPart(ctx context.Context, req *multipartclient.UploadObjectPartRequest) (*multipartclient.UploadObjectPartResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UploadObjectPart", ctx, req)
+	ret0, _ := ret[0].(*multipartclient.UploadObjectPartResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UploadObjectPart indicates an expected call of UploadObjectPart.
+func (mr *MockMultipartAPIMockRecorder) UploadObjectPart(ctx, req any) *MockMultipartAPIUploadObjectPartCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadObjectPart", reflect.TypeOf((*MockMultipartAPI)(nil).UploadObjectPart), ctx, req)
+	return &MockMultipartAPIUploadObjectPartCall{Call: call}
+}
+
+// MockMultipartAPIUploadObjectPartCall wrap *gomock.Call
+type MockMultipartAPIUploadObjectPartCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockMultipartAPIUploadObjectPartCall) Return(arg0 *multipartclient.UploadObjectPartResult, arg1 error) *MockMultipartAPIUploadObjectPartCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockMultipartAPIUploadObjectPartCall) Do(f func(context.Context, *multipartclient.UploadObjectPartRequest) (*multipartclient.UploadObjectPartResult, error)) *MockMultipartAPIUploadObjectPartCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockMultipartAPIUploadObjectPartCall) DoAndReturn(f func(context.Context, *multipartclient.UploadObjectPartRequest) (*multipartclient.UploadObjectPartResult, error)) *MockMultipartAPIUploadObjectPartCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// UploadPartCopy mocks base method.
+func (m *MockMultipartAPI) UploadPartCopy(ctx context.Context, req *multipartclient.UploadPartCopyRequest) (*multipartclient.CopyPartResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UploadPartCopy", ctx, req)
+	ret0, _ := ret[0].(*multipartclient.CopyPartResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UploadPartCopy indicates an expected call of UploadPartCopy.
+func (mr *MockMultipartAPIMockRecorder) UploadPartCopy(ctx, req any) *MockMultipartAPIUploadPartCopyCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadPartCopy", reflect.TypeOf((*MockMultipartAPI)(nil).UploadPartCopy), ctx, req)
+	return &MockMultipartAPIUploadPartCopyCall{Call: call}
+}
+
+// MockMultipartAPIUploadPartCopyCall wrap *gomock.Call
+type MockMultipartAPIUploadPartCopyCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockMultipartAPIUploadPartCopyCall) Return(arg0 *multipartclient.CopyPartResult, arg1 error) *MockMultipartAPIUploadPartCopyCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockMultipartAPIUploadPartCopyCall) Do(f func(context.Context, *multipartclient.UploadPartCopyRequest) (*multipartclient.CopyPartResult, error)) *MockMultipartAPIUploadPartCopyCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockMultipartAPIUploadPartCopyCall) DoAndReturn(f func(context.Context, *multipartclient.UploadPartCopyRequest) (*multipartclient.CopyPartResult, error)) *MockMultipartAPIUploadPartCopyCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// Uploads mocks base method.
+func (m *MockMultipartAPI) Uploads(ctx context.Context, req *multipartclient.ListMultipartUploadsRequest) *multipartclient.UploadIterator {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Uploads", ctx, req)
+	ret0, _ := ret[0].(*multipartclient.UploadIterator)
+	return ret0
+}
+
+// Uploads indicates an expected call of Uploads.
+func (mr *MockMultipartAPIMockRecorder) Uploads(ctx, req any) *MockMultipartAPIUploadsCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Uploads", reflect.TypeOf((*MockMultipartAPI)(nil).Uploads), ctx, req)
+	return &MockMultipartAPIUploadsCall{Call: call}
+}
+
+// MockMultipartAPIUploadsCall wrap *gomock.Call
+type MockMultipartAPIUploadsCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockMultipartAPIUploadsCall) Return(arg0 *multipartclient.UploadIterator) *MockMultipartAPIUploadsCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockMultipartAPIUploadsCall) Do(f func(context.Context, *multipartclient.ListMultipartUploadsRequest) *multipartclient.UploadIterator) *MockMultipartAPIUploadsCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockMultipartAPIUploadsCall) DoAndReturn(f func(context.Context, *multipartclient.ListMultipartUploadsRequest) *multipartclient.UploadIterator) *MockMultipartAPIUploadsCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// ValidateUploadedParts mocks base method.
+func (m *MockMultipartAPI) ValidateUploadedParts(ctx context.Context, req *multipartclient.ListObjectPartsRequest, records []multipartclient.PartRecord) (*multipartclient.PartValidation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ValidateUploadedParts", ctx, req, records)
+	ret0, _ := ret[0].(*multipartclient.PartValidation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ValidateUploadedParts indicates an expected call of ValidateUploadedParts.
+func (mr *MockMultipartAPIMockRecorder) ValidateUploadedParts(ctx, req, records any) *MockMultipartAPIValidateUploadedPartsCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ValidateUploadedParts", reflect.TypeOf((*MockMultipartAPI)(nil).ValidateUploadedParts), ctx, req, records)
+	return &MockMultipartAPIValidateUploadedPartsCall{Call: call}
+}
+
+// MockMultipartAPIValidateUploadedPartsCall wrap *gomock.Call
+type MockMultipartAPIValidateUploadedPartsCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockMultipartAPIValidateUploadedPartsCall) Return(arg0 *multipartclient.PartValidation, arg1 error) *MockMultipartAPIValidateUploadedPartsCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockMultipartAPIValidateUploadedPartsCall) Do(f func(context.Context, *multipartclient.ListObjectPartsRequest, []multipartclient.PartRecord) (*multipartclient.PartValidation, error)) *MockMultipartAPIValidateUploadedPartsCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockMultipartAPIValidateUploadedPartsCall) DoAndReturn(f func(context.Context, *multipartclient.ListObjectPartsRequest, []multipartclient.PartRecord) (*multipartclient.PartValidation, error)) *MockMultipartAPIValidateUploadedPartsCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// Warmup mocks base method.
+func (m *MockMultipartAPI) Warmup(ctx context.Context, n int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Warmup", ctx, n)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Warmup indicates an expected call of Warmup.
+func (mr *MockMultipartAPIMockRecorder) Warmup(ctx, n any) *MockMultipartAPIWarmupCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Warmup", reflect.TypeOf((*MockMultipartAPI)(nil).Warmup), ctx, n)
+	return &MockMultipartAPIWarmupCall{Call: call}
+}
+
+// MockMultipartAPIWarmupCall wrap *gomock.Call
+type MockMultipartAPIWarmupCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockMultipartAPIWarmupCall) Return(arg0 int, arg1 error) *MockMultipartAPIWarmupCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockMultipartAPIWarmupCall) Do(f func(context.Context, int) (int, error)) *MockMultipartAPIWarmupCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockMultipartAPIWarmupCall) DoAndReturn(f func(context.Context, int) (int, error)) *MockMultipartAPIWarmupCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// MockUploaderAPI is a mock of UploaderAPI interface.
+type MockUploaderAPI struct {
+	ctrl     *gomock.Controller
+	recorder *MockUploaderAPIMockRecorder
+}
+
+// MockUploaderAPIMockRecorder is the mock recorder for MockUploaderAPI.
+type MockUploaderAPIMockRecorder struct {
+	mock *MockUploaderAPI
+}
+
+// NewMockUploaderAPI creates a new mock instance.
+func NewMockUploaderAPI(ctrl *gomock.Controller) *MockUploaderAPI {
+	mock := &MockUploaderAPI{ctrl: ctrl}
+	mock.recorder = &MockUploaderAPIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUploaderAPI) EXPECT() *MockUploaderAPIMockRecorder {
+	return m.recorder
+}
+
+// Upload mocks base method.
+func (m *MockUploaderAPI) Upload(ctx context.Context, req *multipartclient.InitiateMultipartUploadRequest, r io.Reader) (*multipartclient.CompleteMultipartUploadResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Upload", ctx, req, r)
+	ret0, _ := ret[0].(*multipartclient.CompleteMultipartUploadResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Upload indicates an expected call of Upload.
+func (mr *MockUploaderAPIMockRecorder) Upload(ctx, req, r any) *MockUploaderAPIUploadCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Upload", reflect.TypeOf((*MockUploaderAPI)(nil).Upload), ctx, req, r)
+	return &MockUploaderAPIUploadCall{Call: call}
+}
+
+// MockUploaderAPIUploadCall wrap *gomock.Call
+type MockUploaderAPIUploadCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockUploaderAPIUploadCall) Return(arg0 *multipartclient.CompleteMultipartUploadResult, arg1 error) *MockUploaderAPIUploadCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockUploaderAPIUploadCall) Do(f func(context.Context, *multipartclient.InitiateMultipartUploadRequest, io.Reader) (*multipartclient.CompleteMultipartUploadResult, error)) *MockUploaderAPIUploadCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockUploaderAPIUploadCall) DoAndReturn(f func(context.Context, *multipartclient.InitiateMultipartUploadRequest, io.Reader) (*multipartclient.CompleteMultipartUploadResult, error)) *MockUploaderAPIUploadCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// UploadFile mocks base method.
+func (m *MockUploaderAPI) UploadFile(ctx context.Context, req *multipartclient.InitiateMultipartUploadRequest, name string) (*multipartclient.CompleteMultipartUploadResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UploadFile", ctx, req, name)
+	ret0, _ := ret[0].(*multipartclient.CompleteMultipartUploadResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UploadFile indicates an expected call of UploadFile.
+func (mr *MockUploaderAPIMockRecorder) UploadFile(ctx, req, name any) *MockUploaderAPIUploadFileCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadFile", reflect.TypeOf((*MockUploaderAPI)(nil).UploadFile), ctx, req, name)
+	return &MockUploaderAPIUploadFileCall{Call: call}
+}
+
+// MockUploaderAPIUploadFileCall wrap *gomock.Call
+type MockUploaderAPIUploadFileCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockUploaderAPIUploadFileCall) Return(arg0 *multipartclient.CompleteMultipartUploadResult, arg1 error) *MockUploaderAPIUploadFileCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockUploaderAPIUploadFileCall) Do(f func(context.Context, *multipartclient.InitiateMultipartUploadRequest, string) (*multipartclient.CompleteMultipartUploadResult, error)) *MockUploaderAPIUploadFileCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockUploaderAPIUploadFileCall) DoAndReturn(f func(context.Context, *multipartclient.InitiateMultipartUploadRequest, string) (*multipartclient.CompleteMultipartUploadResult, error)) *MockUploaderAPIUploadFileCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
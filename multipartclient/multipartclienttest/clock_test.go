@@ -0,0 +1,42 @@
+package multipartclienttest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multipartclienttest"
+)
+
+var _ multipartclient.Clock = (*multipartclienttest.FakeClock)(nil)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	clock := multipartclienttest.NewFakeClock(start)
+
+	after := clock.After(time.Second)
+	if got := clock.Waiters(); got != 1 {
+		t.Errorf("got %d waiters, want 1", got)
+	}
+	clock.Advance(500 * time.Millisecond)
+	select {
+	case <-after:
+		t.Fatal("After() fired before the duration elapsed")
+	default:
+	}
+	clock.Advance(500 * time.Millisecond)
+	select {
+	case got := <-after:
+		if want := start.Add(time.Second); !got.Equal(want) {
+			t.Errorf("After() sent %v, want %v", got, want)
+		}
+	default:
+		t.Fatal("After() did not fire once the duration elapsed")
+	}
+	if got := clock.Waiters(); got != 0 {
+		t.Errorf("got %d waiters, want 0", got)
+	}
+	if got, want := clock.Now(), start.Add(time.Second); !got.Equal(want) {
+		t.Errorf("Now() = %v, want %v", got, want)
+	}
+}
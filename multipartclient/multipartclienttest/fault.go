@@ -0,0 +1,186 @@
+package multipartclienttest
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrConnectionDropped is returned for requests failed by a Fault with
+// DropConnection or DropResponse set.
+var ErrConnectionDropped = errors.New("multipartclienttest: connection dropped")
+
+// Fault describes a failure injected by a FaultTransport.
+type Fault struct {
+	// Match selects the requests the fault applies to. All requests match if
+	// nil.
+	Match func(req *http.Request) bool
+	// Nth is the 1-based index, among matching requests, of the first request
+	// the fault applies to. If zero, it applies to every matching request.
+	Nth int
+	// Times is the number of consecutive matching requests from Nth the fault
+	// applies to. Defaults to 1 if Nth is set.
+	Times int
+
+	// Latency delays the request, or until its context is done.
+	Latency time.Duration
+	// DropConnection fails the request with ErrConnectionDropped without
+	// sending it.
+	DropConnection bool
+	// DropResponse sends the request and then fails it with
+	// ErrConnectionDropped, as if the connection broke after the server
+	// handled it.
+	DropResponse bool
+	// TruncateBody cuts the response body after TruncateAt bytes; reading
+	// further fails with io.ErrUnexpectedEOF.
+	TruncateBody bool
+	TruncateAt   int
+	// Error, if set, is returned instead of sending the request, e.g. an
+	// ErrorResponse(http.StatusServiceUnavailable, "SlowDown", "...") built
+	// fresh for every call.
+	Error func() *http.Response
+}
+
+// FaultTransport is an http.RoundTripper that injects Faults into requests
+// before passing them to another transport, so retry and resume logic can be
+// exercised deterministically. It is safe for concurrent use.
+type FaultTransport struct {
+	base   http.RoundTripper
+	faults []*Fault
+
+	mu      sync.Mutex
+	matches []int
+}
+
+// NewFaultTransport returns a FaultTransport sending requests with base. When
+// several faults apply to a request, all of them are applied in order.
+func NewFaultTransport(base http.RoundTripper, faults ...*Fault) *FaultTransport {
+	return &FaultTransport{base: base, faults: faults, matches: make([]int, len(faults))}
+}
+
+// Client returns an *http.Client using the transport.
+func (ft *FaultTransport) Client() *http.Client {
+	return &http.Client{Transport: ft}
+}
+
+func (ft *FaultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var active []*Fault
+	ft.mu.Lock()
+	for i, f := range ft.faults {
+		if f.Match != nil && !f.Match(req) {
+			continue
+		}
+		ft.matches[i]++
+		if f.applies(ft.matches[i]) {
+			active = append(active, f)
+		}
+	}
+	ft.mu.Unlock()
+
+	var dropResponse bool
+	var truncate *Fault
+	for _, f := range active {
+		if f.Latency > 0 {
+			timer := time.NewTimer(f.Latency)
+			select {
+			case <-timer.C:
+			case <-req.Context().Done():
+				timer.Stop()
+				return nil, req.Context().Err()
+			}
+		}
+		if f.DropConnection {
+			closeBody(req)
+			return nil, ErrConnectionDropped
+		}
+		if f.Error != nil {
+			closeBody(req)
+			resp := f.Error()
+			resp.Request = req
+			return resp, nil
+		}
+		dropResponse = dropResponse || f.DropResponse
+		if f.TruncateBody {
+			truncate = f
+		}
+	}
+
+	resp, err := ft.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if dropResponse {
+		resp.Body.Close()
+		return nil, ErrConnectionDropped
+	}
+	if truncate != nil {
+		resp.Body = &truncatedBody{r: io.LimitReader(resp.Body, int64(truncate.TruncateAt)), c: resp.Body}
+	}
+	return resp, nil
+}
+
+// applies reports whether the fault applies to the n-th matching request.
+func (f *Fault) applies(n int) bool {
+	if f.Nth == 0 {
+		return true
+	}
+	times := f.Times
+	if times == 0 {
+		times = 1
+	}
+	return f.Nth <= n && n < f.Nth+times
+}
+
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+// truncatedBody fails with io.ErrUnexpectedEOF where the body was cut.
+type truncatedBody struct {
+	r io.Reader
+	c io.Closer
+}
+
+func (b *truncatedBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (b *truncatedBody) Close() error {
+	return b.c.Close()
+}
+
+// MatchPart matches UploadObjectPart requests for the part partNumber, or
+// for any part if partNumber is zero.
+func MatchPart(partNumber int) func(req *http.Request) bool {
+	return func(req *http.Request) bool {
+		q := req.URL.Query()
+		if req.Method != http.MethodPut || !q.Has("uploadId") || req.Header.Get("x-goog-copy-source") != "" {
+			return false
+		}
+		return partNumber == 0 || q.Get("partNumber") == strconv.Itoa(partNumber)
+	}
+}
+
+// MatchInitiate matches InitiateMultipartUpload requests.
+func MatchInitiate(req *http.Request) bool {
+	return req.Method == http.MethodPost && req.URL.Query().Has("uploads")
+}
+
+// MatchComplete matches CompleteMultipartUpload requests.
+func MatchComplete(req *http.Request) bool {
+	return req.Method == http.MethodPost && req.URL.Query().Has("uploadId")
+}
+
+// MatchAbort matches AbortMultipartUpload requests.
+func MatchAbort(req *http.Request) bool {
+	return req.Method == http.MethodDelete && req.URL.Query().Has("uploadId")
+}
@@ -0,0 +1,104 @@
+package multipartclienttest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multipartclienttest"
+)
+
+func uploadPart(ctx context.Context, mpuc *multipartclient.MultipartClient, partNumber int) error {
+	_, err := mpuc.UploadObjectPart(ctx, &multipartclient.UploadObjectPartRequest{
+		Bucket:     "bucket1",
+		Key:        "object.txt",
+		PartNumber: partNumber,
+		UploadID:   "upload-1",
+		Body:       multipartclienttest.Body("contents"),
+	})
+	return err
+}
+
+func TestFaultTransportNthPart(t *testing.T) {
+	server := multipartclienttest.NewTransport(t).Fallback(func(req *http.Request) (*http.Response, error) {
+		return multipartclienttest.UploadPartResponse("etag"), nil
+	})
+	ft := multipartclienttest.NewFaultTransport(server, &multipartclienttest.Fault{
+		Match:          multipartclienttest.MatchPart(2),
+		Nth:            1,
+		Times:          2,
+		DropConnection: true,
+	})
+	mpuc := multipartclient.New(ft.Client())
+	ctx := context.Background()
+
+	var got []bool
+	for _, partNumber := range []int{1, 2, 2, 2} {
+		got = append(got, errors.Is(uploadPart(ctx, mpuc, partNumber), multipartclienttest.ErrConnectionDropped))
+	}
+	if want := []bool{false, true, true, false}; !slices.Equal(got, want) {
+		t.Errorf("got dropped %v, want %v", got, want)
+	}
+	// Dropped connections never reach the server.
+	if n := len(server.Requests()); n != 2 {
+		t.Errorf("got %d requests at the server, want 2", n)
+	}
+}
+
+func TestFaultTransportDuringComplete(t *testing.T) {
+	server := multipartclienttest.NewTransport(t).Respond(multipartclienttest.CompleteResponse("bucket1", "object.txt", "etag"))
+	ft := multipartclienttest.NewFaultTransport(server,
+		&multipartclienttest.Fault{Match: multipartclienttest.MatchComplete, DropResponse: true},
+		&multipartclienttest.Fault{
+			Match: multipartclienttest.MatchAbort,
+			Error: func() *http.Response {
+				return multipartclienttest.ErrorResponse(http.StatusServiceUnavailable, "SlowDown", "Please reduce your request rate.")
+			},
+		},
+	)
+	mpuc := multipartclient.New(ft.Client())
+	ctx := context.Background()
+
+	_, err := mpuc.CompleteMultipartUpload(ctx, &multipartclient.CompleteMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt", UploadID: "upload-1"})
+	if !errors.Is(err, multipartclienttest.ErrConnectionDropped) {
+		t.Errorf("got error %v, want %v", err, multipartclienttest.ErrConnectionDropped)
+	}
+	// The server saw the request even though the client did not get the response.
+	if n := len(server.Requests()); n != 1 {
+		t.Errorf("got %d requests at the server, want 1", n)
+	}
+
+	err = mpuc.AbortMultipartUpload(ctx, &multipartclient.AbortMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt", UploadID: "upload-1"})
+	if err == nil || !strings.Contains(err.Error(), "SlowDown") {
+		t.Errorf("got error %v, want SlowDown", err)
+	}
+}
+
+func TestFaultTransportTruncateBody(t *testing.T) {
+	server := multipartclienttest.NewTransport(t).Respond(multipartclienttest.InitiateResponse("bucket1", "object.txt", "upload-1"))
+	ft := multipartclienttest.NewFaultTransport(server, &multipartclienttest.Fault{TruncateBody: true, TruncateAt: 20})
+	mpuc := multipartclient.New(ft.Client())
+
+	_, err := mpuc.InitiateMultipartUpload(context.Background(), &multipartclient.InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt"})
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("got error %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestFaultTransportLatency(t *testing.T) {
+	server := multipartclienttest.NewTransport(t)
+	ft := multipartclienttest.NewFaultTransport(server, &multipartclienttest.Fault{Latency: time.Hour})
+	mpuc := multipartclient.New(ft.Client())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := uploadPart(ctx, mpuc, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
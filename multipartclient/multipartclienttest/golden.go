@@ -0,0 +1,107 @@
+package multipartclienttest
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// UpdateGoldenEnv is the environment variable that makes AssertGolden write
+// golden files instead of comparing with them, e.g.
+//
+//	MULTIPARTCLIENTTEST_UPDATE=1 go test ./...
+const UpdateGoldenEnv = "MULTIPARTCLIENTTEST_UPDATE"
+
+// volatileHeaders change between runs or environments and are left out of
+// normalized requests.
+var volatileHeaders = []string{
+	"Authorization",
+	"Date",
+	"Traceparent",
+	"Tracestate",
+	"User-Agent",
+	"X-Cloud-Trace-Context",
+	"X-Goog-Api-Client",
+	"X-Goog-Date",
+}
+
+// NormalizeRequest returns dump, a request dumped by DumpRequest, in a stable
+// form for snapshots: lines end in "\n", headers are sorted and headers that
+// vary between runs, such as Authorization, Date and User-Agent, are removed.
+func NormalizeRequest(dump string) string {
+	head, body, _ := strings.Cut(dump, "\r\n\r\n")
+	lines := strings.Split(head, "\r\n")
+	requestLine, headers := lines[0], lines[1:]
+
+	kept := headers[:0]
+	for _, line := range headers {
+		name, _, _ := strings.Cut(line, ":")
+		if !isVolatileHeader(name) {
+			kept = append(kept, line)
+		}
+	}
+	sort.Strings(kept)
+
+	b := &strings.Builder{}
+	b.WriteString(requestLine + "\n")
+	for _, line := range kept {
+		b.WriteString(line + "\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(strings.ReplaceAll(body, "\r\n", "\n"))
+	return b.String()
+}
+
+func isVolatileHeader(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+	for _, h := range volatileHeaders {
+		if name == h {
+			return true
+		}
+	}
+	return false
+}
+
+// Snapshot returns the requests received so far, normalized by
+// NormalizeRequest and separated by "---" lines, for AssertGolden.
+func (tr *Transport) Snapshot() string {
+	var normalized []string
+	for _, dump := range tr.Requests() {
+		normalized = append(normalized, NormalizeRequest(dump))
+	}
+	return strings.Join(normalized, "\n---\n")
+}
+
+// AssertGolden fails the test unless got equals the contents of the golden
+// file at path. If the UpdateGoldenEnv environment variable is set, the
+// file is written with got instead.
+func AssertGolden(t *testing.T, path, got string) {
+	t.Helper()
+
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("golden file %s does not exist; run with %s=1 to create it", path, UpdateGoldenEnv)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(want), got); diff != "" {
+		t.Errorf("request snapshot differs from %s; run with %s=1 to update it: (-want, +got):\n%s", path, UpdateGoldenEnv, diff)
+	}
+}
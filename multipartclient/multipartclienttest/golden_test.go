@@ -0,0 +1,54 @@
+package multipartclienttest_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multipartclienttest"
+)
+
+func TestNormalizeRequest(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "https://storage.googleapis.com/bucket1/object.txt?uploads", http.NoBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Goog-Meta-B", "2")
+	req.Header.Set("X-Goog-Meta-A", "1")
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("User-Agent", "test/1.0")
+
+	got := multipartclienttest.NormalizeRequest(multipartclienttest.DumpRequest(t, req))
+	want := "POST /bucket1/object.txt?uploads HTTP/1.1\n" +
+		"Host: storage.googleapis.com\n" +
+		"X-Goog-Meta-A: 1\n" +
+		"X-Goog-Meta-B: 2\n" +
+		"\n"
+	if got != want {
+		t.Errorf("NormalizeRequest() = %q, want %q", got, want)
+	}
+}
+
+func TestAssertGolden(t *testing.T) {
+	tr := multipartclienttest.NewTransport(t).
+		Respond(multipartclienttest.InitiateResponse("bucket1", "object.txt", "upload-1")).
+		Respond(multipartclienttest.CompleteResponse("bucket1", "object.txt", "etag"))
+	mpuc := multipartclient.New(tr.Client())
+	ctx := context.Background()
+	if _, err := mpuc.InitiateMultipartUpload(ctx, &multipartclient.InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mpuc.CompleteMultipartUpload(ctx, &multipartclient.CompleteMultipartUploadRequest{
+		Bucket:   "bucket1",
+		Key:      "object.txt",
+		UploadID: "upload-1",
+		Body: multipartclient.CompleteMultipartUploadBody{
+			Parts: []multipartclient.CompletePart{{PartNumber: 1, ETag: `"etag-1"`}},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	multipartclienttest.AssertGolden(t, "testdata/upload.golden", tr.Snapshot())
+}
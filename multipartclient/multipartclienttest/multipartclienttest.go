@@ -0,0 +1,159 @@
+// Package multipartclienttest provides helpers for testing code that uses
+// multipartclient without sending requests to GCS: a mock transport that
+// records requests, canned XML responses and request assertions.
+package multipartclienttest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// TransportFunc is an http.RoundTripper that responds to each request by
+// calling the function.
+type TransportFunc func(req *http.Request) (*http.Response, error)
+
+func (f TransportFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Transport is an http.RoundTripper that records every request and responds
+// with the next queued response. It is safe for concurrent use.
+type Transport struct {
+	t *testing.T
+
+	mu        sync.Mutex
+	requests  []string
+	responses []response
+	// fallback responds once the queue is empty.
+	fallback TransportFunc
+}
+
+type response struct {
+	resp *http.Response
+	err  error
+}
+
+// NewTransport returns a Transport that fails the test if a request arrives
+// with no response queued.
+func NewTransport(t *testing.T) *Transport {
+	return &Transport{t: t}
+}
+
+// Client returns an *http.Client using the transport.
+func (tr *Transport) Client() *http.Client {
+	return &http.Client{Transport: tr}
+}
+
+// Respond queues resp as the response to the next request.
+func (tr *Transport) Respond(resp *http.Response) *Transport {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.responses = append(tr.responses, response{resp: resp})
+	return tr
+}
+
+// Fail queues err as the error returned for the next request.
+func (tr *Transport) Fail(err error) *Transport {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.responses = append(tr.responses, response{err: err})
+	return tr
+}
+
+// Fallback responds to requests with f once the queue is empty.
+func (tr *Transport) Fallback(f TransportFunc) *Transport {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.fallback = f
+	return tr
+}
+
+func (tr *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	dump := DumpRequest(tr.t, req)
+	tr.mu.Lock()
+	tr.requests = append(tr.requests, dump)
+	var next *response
+	if len(tr.responses) > 0 {
+		next = &tr.responses[0]
+		tr.responses = tr.responses[1:]
+	}
+	fallback := tr.fallback
+	tr.mu.Unlock()
+
+	switch {
+	case next != nil:
+		if next.resp != nil {
+			next.resp.Request = req
+		}
+		return next.resp, next.err
+	case fallback != nil:
+		return fallback(req)
+	}
+	tr.t.Errorf("unexpected request, no response queued:\n%s", dump)
+	return nil, fmt.Errorf("multipartclienttest: no response queued for %s %s", req.Method, req.URL)
+}
+
+// Requests returns the requests received so far, dumped by DumpRequest.
+func (tr *Transport) Requests() []string {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return append([]string(nil), tr.requests...)
+}
+
+// AssertRequestLines fails the test unless the request lines, such as
+// "POST /bucket/key?uploads HTTP/1.1", of the requests received so far are
+// want, in order.
+func (tr *Transport) AssertRequestLines(t *testing.T, want ...string) {
+	t.Helper()
+
+	var got []string
+	for _, dump := range tr.Requests() {
+		line, _, _ := strings.Cut(dump, "\r\n")
+		got = append(got, line)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected diff for request lines: (-want, +got):\n%s", diff)
+	}
+}
+
+// AssertRequest fails the test unless the i-th request received, dumped by
+// DumpRequest, is want. Line endings are compared without carriage returns,
+// so want can use "\n".
+func (tr *Transport) AssertRequest(t *testing.T, i int, want string) {
+	t.Helper()
+
+	requests := tr.Requests()
+	if i >= len(requests) {
+		t.Fatalf("got %d requests, want at least %d", len(requests), i+1)
+	}
+	fixNewlines := cmp.Transformer("fix_newlines", func(in string) string {
+		return strings.ReplaceAll(in, "\r", "")
+	})
+	if diff := cmp.Diff(want, requests[i], fixNewlines); diff != "" {
+		t.Errorf("unexpected diff for request %d: (-want, +got):\n%s", i, diff)
+	}
+}
+
+// DumpRequest returns req in its HTTP/1.x wire representation, including the
+// body. The body is restored so req can still be sent.
+func DumpRequest(t *testing.T, req *http.Request) string {
+	t.Helper()
+
+	dump, err := httputil.DumpRequest(req, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(dump)
+}
+
+// Body returns s as a response or request body.
+func Body(s string) io.ReadCloser {
+	return io.NopCloser(strings.NewReader(s))
+}
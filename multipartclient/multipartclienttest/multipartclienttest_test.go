@@ -0,0 +1,115 @@
+package multipartclienttest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multipartclienttest"
+)
+
+func TestCannedResponses(t *testing.T) {
+	tr := multipartclienttest.NewTransport(t).
+		Respond(multipartclienttest.InitiateResponse("bucket1", "a&b.txt", "upload-1")).
+		Respond(multipartclienttest.UploadPartResponse("etag-1")).
+		Respond(multipartclienttest.ListPartsResponse(multipartclienttest.Part{PartNumber: 1, ETag: "etag-1"})).
+		Respond(multipartclienttest.CompleteResponse("bucket1", "a&b.txt", "etag-final")).
+		Respond(multipartclienttest.ListUploadsResponse("bucket1", "upload-2"))
+	mpuc := multipartclient.New(tr.Client())
+	ctx := context.Background()
+
+	initResult, err := mpuc.InitiateMultipartUpload(ctx, &multipartclient.InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "a&b.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if initResult.UploadID != "upload-1" || initResult.Key != "a&b.txt" {
+		t.Errorf("got upload ID %q for key %q, want %q for %q", initResult.UploadID, initResult.Key, "upload-1", "a&b.txt")
+	}
+	partResult, err := mpuc.UploadObjectPart(ctx, &multipartclient.UploadObjectPartRequest{
+		Bucket:     "bucket1",
+		Key:        "a&b.txt",
+		PartNumber: 1,
+		UploadID:   "upload-1",
+		Body:       multipartclienttest.Body("contents"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"etag-1"`; partResult.ETag != want {
+		t.Errorf("got ETag %s, want %s", partResult.ETag, want)
+	}
+	listResult, err := mpuc.ListObjectParts(ctx, &multipartclient.ListObjectPartsRequest{Bucket: "bucket1", Key: "a&b.txt", UploadID: "upload-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]multipartclient.CompletePart{{PartNumber: 1, ETag: `"etag-1"`}}, listResult.Parts); diff != "" {
+		t.Errorf("unexpected diff for parts: (-want, +got):\n%s", diff)
+	}
+	completeResult, err := mpuc.CompleteMultipartUpload(ctx, &multipartclient.CompleteMultipartUploadRequest{
+		Bucket:   "bucket1",
+		Key:      "a&b.txt",
+		UploadID: "upload-1",
+		Body:     multipartclient.CompleteMultipartUploadBody{Parts: listResult.Parts},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"etag-final"`; completeResult.ETag != want {
+		t.Errorf("got ETag %s, want %s", completeResult.ETag, want)
+	}
+	uploads, err := mpuc.ListMultipartUploads(ctx, &multipartclient.ListMultipartUploadsRequest{Bucket: "bucket1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uploads.Uploads) != 1 || uploads.Uploads[0].UploadID != "upload-2" {
+		t.Errorf("got uploads %+v, want upload-2", uploads.Uploads)
+	}
+
+	tr.AssertRequestLines(t,
+		"POST /bucket1/a&b.txt?uploads HTTP/1.1",
+		"PUT /bucket1/a&b.txt?partNumber=1&uploadId=upload-1 HTTP/1.1",
+		"GET /bucket1/a&b.txt?uploadId=upload-1 HTTP/1.1",
+		"POST /bucket1/a&b.txt?uploadId=upload-1 HTTP/1.1",
+		"GET /bucket1/?uploads HTTP/1.1",
+	)
+	tr.AssertRequest(t, 1, "PUT /bucket1/a&b.txt?partNumber=1&uploadId=upload-1 HTTP/1.1\n"+
+		"Host: storage.googleapis.com\n\n"+
+		"contents")
+}
+
+func TestErrorResponse(t *testing.T) {
+	tr := multipartclienttest.NewTransport(t).
+		Respond(multipartclienttest.ErrorResponse(http.StatusNotFound, "NoSuchUpload", "The requested upload was not found.")).
+		Fail(errors.New("connection reset"))
+	mpuc := multipartclient.New(tr.Client())
+	req := &multipartclient.AbortMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt", UploadID: "upload-1"}
+
+	err := mpuc.AbortMultipartUpload(context.Background(), req)
+	if err == nil || !strings.Contains(err.Error(), "NoSuchUpload") {
+		t.Errorf("got error %v, want NoSuchUpload", err)
+	}
+	err = mpuc.AbortMultipartUpload(context.Background(), req)
+	if err == nil || !strings.Contains(err.Error(), "connection reset") {
+		t.Errorf("got error %v, want connection reset", err)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	tr := multipartclienttest.NewTransport(t).
+		Fallback(func(req *http.Request) (*http.Response, error) {
+			return multipartclienttest.AbortResponse(), nil
+		})
+	mpuc := multipartclient.New(tr.Client())
+	for i := 0; i < 2; i++ {
+		if err := mpuc.AbortMultipartUpload(context.Background(), &multipartclient.AbortMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt", UploadID: "upload-1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := len(tr.Requests()); got != 2 {
+		t.Errorf("got %d requests, want 2", got)
+	}
+}
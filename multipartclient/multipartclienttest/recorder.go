@@ -0,0 +1,276 @@
+package multipartclienttest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Mode selects whether a Recorder records or replays interactions.
+type Mode int
+
+const (
+	// ModeReplay answers requests from the fixture without network access.
+	ModeReplay Mode = iota
+	// ModeRecord sends requests to the real transport and records them.
+	ModeRecord
+)
+
+// Interaction is a request and its response, as stored in a fixture.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a sanitized request. Its body is stored as a hash only,
+// since part bodies are large and the requests are only matched, not sent.
+type RecordedRequest struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	Header     http.Header `json:"header,omitempty"`
+	BodySHA256 string      `json:"body_sha256,omitempty"`
+}
+
+// RecordedResponse is a sanitized response.
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Recorder is an http.RoundTripper that records real GCS interactions to a
+// fixture file and replays them in later runs, so tests can check behavior
+// against real server responses without network access. Credentials and
+// encryption keys are removed from recorded headers and URLs.
+//
+// A typical test records when run with a flag:
+//
+//	var record = flag.Bool("record", false, "record fixtures from GCS")
+//
+//	mode := multipartclienttest.ModeReplay
+//	if *record {
+//		mode = multipartclienttest.ModeRecord
+//	}
+//	rec, err := multipartclienttest.NewRecorder("testdata/upload.json", mode, authenticatedTransport)
+//	...
+//	defer rec.Save()
+type Recorder struct {
+	path  string
+	mode  Mode
+	trans http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewRecorder returns a Recorder for the fixture at path. In ModeRecord,
+// requests are sent with trans, or http.DefaultTransport if nil, and Save
+// writes them to path. In ModeReplay the fixture is read from path.
+func NewRecorder(path string, mode Mode, trans http.RoundTripper) (*Recorder, error) {
+	if trans == nil {
+		trans = http.DefaultTransport
+	}
+	r := &Recorder{path: path, mode: mode, trans: trans}
+	if mode == ModeReplay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &r.interactions); err != nil {
+			return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+		}
+		r.used = make([]bool, len(r.interactions))
+	}
+	return r, nil
+}
+
+// Client returns an *http.Client using the recorder.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	recReq, err := recordRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.mode == ModeReplay {
+		return r.replay(req, recReq)
+	}
+
+	resp, err := r.trans.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactions = append(r.interactions, Interaction{
+		Request: recReq,
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     sanitizeHeader(resp.Header),
+			Body:       string(body),
+		},
+	})
+	return resp, nil
+}
+
+// replay answers req with the first unused interaction whose request has the
+// same method, URL and body.
+func (r *Recorder) replay(req *http.Request, recReq RecordedRequest) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, in := range r.interactions {
+		if r.used[i] || in.Request.Method != recReq.Method || in.Request.URL != recReq.URL || in.Request.BodySHA256 != recReq.BodySHA256 {
+			continue
+		}
+		r.used[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Response.StatusCode, http.StatusText(in.Response.StatusCode)),
+			StatusCode:    in.Response.StatusCode,
+			Header:        in.Response.Header.Clone(),
+			ContentLength: int64(len(in.Response.Body)),
+			Body:          Body(in.Response.Body),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("multipartclienttest: no recorded interaction for %s %s in %s", recReq.Method, recReq.URL, r.path)
+}
+
+// Unused returns the interactions of the fixture that have not been replayed,
+// to check that a test made every recorded request.
+func (r *Recorder) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []Interaction
+	for i, in := range r.interactions {
+		if !r.used[i] {
+			unused = append(unused, in)
+		}
+	}
+	return unused
+}
+
+// Save writes the recorded interactions to the fixture file. It does nothing
+// in ModeReplay.
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, err := json.MarshalIndent(r.interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, append(data, '\n'), 0o644)
+}
+
+// recordRequest returns the sanitized form of req. The body is read to be
+// hashed and restored so req can still be sent.
+func recordRequest(req *http.Request) (RecordedRequest, error) {
+	rec := RecordedRequest{
+		Method: req.Method,
+		URL:    sanitizeURL(req.URL),
+		Header: sanitizeHeader(req.Header),
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return RecordedRequest{}, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		if len(body) > 0 {
+			sum := sha256.Sum256(body)
+			rec.BodySHA256 = hex.EncodeToString(sum[:])
+		}
+	}
+	return rec, nil
+}
+
+// droppedHeaders are removed from fixtures: credentials, encryption keys, and
+// headers that change between runs.
+var droppedHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"Proxy-Authorization",
+	"X-Goog-Encryption-Key",
+	"X-Goog-Encryption-Key-Sha256",
+	"X-Goog-Copy-Source-Encryption-Key",
+	"X-Goog-Copy-Source-Encryption-Key-Sha256",
+	"X-Amz-Server-Side-Encryption-Customer-Key",
+	"X-Amz-Server-Side-Encryption-Customer-Key-Md5",
+	"Date",
+	"Expires",
+	"User-Agent",
+	"X-Goog-Date",
+}
+
+func sanitizeHeader(h http.Header) http.Header {
+	h = h.Clone()
+	for _, name := range droppedHeaders {
+		h.Del(name)
+	}
+	if len(h) == 0 {
+		return nil
+	}
+	return h
+}
+
+// droppedQueryParams are the signed URL parameters removed from fixtures.
+var droppedQueryParams = []string{
+	"X-Goog-Signature",
+	"X-Goog-Credential",
+	"X-Goog-Date",
+	"X-Goog-Expires",
+	"X-Goog-SignedHeaders",
+	"X-Goog-Algorithm",
+	"Signature",
+	"GoogleAccessId",
+	"Expires",
+}
+
+// sanitizeURL returns u without signed URL parameters. The remaining query
+// is kept in its original form so GCS-style valueless parameters such as
+// "?uploads" survive.
+func sanitizeURL(u *url.URL) string {
+	u2 := *u
+	if u2.RawQuery != "" {
+		var kept []string
+		for _, param := range strings.Split(u2.RawQuery, "&") {
+			name, _, _ := strings.Cut(param, "=")
+			if !containsFold(droppedQueryParams, name) {
+				kept = append(kept, param)
+			}
+		}
+		u2.RawQuery = strings.Join(kept, "&")
+	}
+	return u2.String()
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package multipartclienttest_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multipartclienttest"
+)
+
+func TestRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixture.json")
+
+	// Stand in for GCS behind an authenticating transport.
+	server := multipartclienttest.NewTransport(t).
+		Respond(multipartclienttest.InitiateResponse("bucket1", "object.txt", "upload-1")).
+		Respond(multipartclienttest.UploadPartResponse("etag-1"))
+	authTrans := multipartclienttest.TransportFunc(func(req *http.Request) (*http.Response, error) {
+		req.Header.Set("Authorization", "Bearer secret-token")
+		return server.RoundTrip(req)
+	})
+
+	upload := func(t *testing.T, hc *http.Client) string {
+		t.Helper()
+
+		mpuc := multipartclient.New(hc)
+		ctx := context.Background()
+		initResult, err := mpuc.InitiateMultipartUpload(ctx, &multipartclient.InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		partResult, err := mpuc.UploadObjectPart(ctx, &multipartclient.UploadObjectPartRequest{
+			Bucket:     "bucket1",
+			Key:        "object.txt",
+			PartNumber: 1,
+			UploadID:   initResult.UploadID,
+			Body:       multipartclienttest.Body("contents"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return partResult.ETag
+	}
+
+	rec, err := multipartclienttest.NewRecorder(path, multipartclienttest.ModeRecord, authTrans)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordedETag := upload(t, rec.Client())
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	fixture, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(fixture), "secret") {
+		t.Errorf("fixture contains a secret: %s", fixture)
+	}
+
+	replay, err := multipartclienttest.NewRecorder(path, multipartclienttest.ModeReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := upload(t, replay.Client()); got != recordedETag {
+		t.Errorf("got replayed ETag %s, want %s", got, recordedETag)
+	}
+	if unused := replay.Unused(); len(unused) != 0 {
+		t.Errorf("got %d unused interactions, want 0", len(unused))
+	}
+
+	// Every interaction is replayed once, and a changed body does not match.
+	mpuc := multipartclient.New(replay.Client())
+	_, err = mpuc.UploadObjectPart(context.Background(), &multipartclient.UploadObjectPartRequest{
+		Bucket:     "bucket1",
+		Key:        "object.txt",
+		PartNumber: 1,
+		UploadID:   "upload-1",
+		Body:       multipartclienttest.Body("contents"),
+	})
+	if err == nil || !strings.Contains(err.Error(), "no recorded interaction") {
+		t.Errorf("got error %v, want no recorded interaction", err)
+	}
+}
+
+func TestRecorderSanitizesURL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	server := multipartclienttest.NewTransport(t).Respond(multipartclienttest.AbortResponse())
+	rec, err := multipartclienttest.NewRecorder(path, multipartclienttest.ModeRecord, server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodDelete, "https://storage.googleapis.com/bucket1/object.txt?uploadId=upload-1&X-Goog-Signature=secret-signature", http.NoBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := rec.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	fixture, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(fixture), "secret") {
+		t.Errorf("fixture contains a secret: %s", fixture)
+	}
+	if !strings.Contains(string(fixture), "https://storage.googleapis.com/bucket1/object.txt?uploadId=upload-1\"") {
+		t.Errorf("fixture does not contain the sanitized URL: %s", fixture)
+	}
+}
@@ -0,0 +1,123 @@
+package multipartclienttest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// XMLResponse returns a response with status code and the XML body.
+func XMLResponse(code int, body string) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Header:        http.Header{"Content-Type": []string{"application/xml"}},
+		ContentLength: int64(len(body)),
+		Body:          Body(body),
+	}
+}
+
+// EmptyResponse returns a response with status code and no body.
+func EmptyResponse(code int) *http.Response {
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode: code,
+		Header:     http.Header{},
+		Body:       http.NoBody,
+	}
+}
+
+// escape returns s escaped for use as XML character data.
+func escape(s string) string {
+	b := &strings.Builder{}
+	// strings.Builder.Write does not return errors.
+	_ = xml.EscapeText(b, []byte(s))
+	return b.String()
+}
+
+// InitiateResponse returns a successful InitiateMultipartUpload response.
+func InitiateResponse(bucket, key, uploadID string) *http.Response {
+	return XMLResponse(http.StatusOK, fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<InitiateMultipartUploadResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Bucket>%s</Bucket>
+  <Key>%s</Key>
+  <UploadId>%s</UploadId>
+</InitiateMultipartUploadResult>`, escape(bucket), escape(key), escape(uploadID)))
+}
+
+// UploadPartResponse returns a successful UploadObjectPart response. etag is
+// sent quoted, as GCS does.
+func UploadPartResponse(etag string) *http.Response {
+	resp := EmptyResponse(http.StatusOK)
+	resp.Header.Set("ETag", fmt.Sprintf("%q", etag))
+	return resp
+}
+
+// CopyPartResponse returns a successful UploadPartCopy response.
+func CopyPartResponse(etag string) *http.Response {
+	return XMLResponse(http.StatusOK, fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<CopyPartResult>
+  <LastModified>2024-01-01T00:00:00.000Z</LastModified>
+  <ETag>%s</ETag>
+</CopyPartResult>`, escape(fmt.Sprintf("%q", etag))))
+}
+
+// CompleteResponse returns a successful CompleteMultipartUpload response.
+func CompleteResponse(bucket, key, etag string) *http.Response {
+	return XMLResponse(http.StatusOK, fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<CompleteMultipartUploadResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Location>http://storage.googleapis.com/%[1]s/%[2]s</Location>
+  <Bucket>%[1]s</Bucket>
+  <Key>%[2]s</Key>
+  <ETag>%[3]s</ETag>
+</CompleteMultipartUploadResult>`, escape(bucket), escape(key), escape(fmt.Sprintf("%q", etag))))
+}
+
+// AbortResponse returns a successful AbortMultipartUpload response.
+func AbortResponse() *http.Response {
+	return EmptyResponse(http.StatusNoContent)
+}
+
+// ListUploadsResponse returns a ListMultipartUploads response listing the
+// uploads with the given IDs.
+func ListUploadsResponse(bucket string, uploadIDs ...string) *http.Response {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<ListMultipartUploadsResult>\n  <Bucket>%s</Bucket>\n", escape(bucket))
+	for _, id := range uploadIDs {
+		fmt.Fprintf(b, "  <Upload>\n    <UploadId>%s</UploadId>\n  </Upload>\n", escape(id))
+	}
+	b.WriteString("</ListMultipartUploadsResult>")
+	return XMLResponse(http.StatusOK, b.String())
+}
+
+// Part is a part listed by ListPartsResponse.
+type Part struct {
+	PartNumber int
+	ETag       string
+}
+
+// ListPartsResponse returns a ListObjectParts response listing parts.
+func ListPartsResponse(parts ...Part) *http.Response {
+	b := &strings.Builder{}
+	b.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<ListPartsResult>\n")
+	for _, p := range parts {
+		fmt.Fprintf(b, "  <Part>\n    <PartNumber>%d</PartNumber>\n", p.PartNumber)
+		if p.ETag != "" {
+			fmt.Fprintf(b, "    <ETag>%s</ETag>\n", escape(fmt.Sprintf("%q", p.ETag)))
+		}
+		b.WriteString("  </Part>\n")
+	}
+	b.WriteString("</ListPartsResult>")
+	return XMLResponse(http.StatusOK, b.String())
+}
+
+// ErrorResponse returns a GCS XML error response, e.g.
+// ErrorResponse(http.StatusNotFound, "NoSuchUpload", "The requested upload was not found.").
+func ErrorResponse(code int, errorCode, message string) *http.Response {
+	return XMLResponse(code, fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<Error>
+  <Code>%s</Code>
+  <Message>%s</Message>
+</Error>`, escape(errorCode), escape(message)))
+}
@@ -0,0 +1,161 @@
+package multiparttest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multipartclienttest"
+)
+
+// ErrCrashed is returned for the requests of an upload that a CrashScenario
+// has crashed.
+var ErrCrashed = errors.New("multiparttest: upload crashed")
+
+// CrashPoint is where a CrashScenario crashes an upload. The request at the
+// crash point reaches the server, which handles it, but the upload never sees
+// the response, as if the process was killed while waiting for it.
+type CrashPoint struct {
+	// AfterPart crashes once the server has stored part AfterPart.
+	AfterPart int
+	// MidComplete crashes once the server has completed the upload.
+	MidComplete bool
+}
+
+func (cp CrashPoint) String() string {
+	if cp.MidComplete {
+		return "mid-complete"
+	}
+	return fmt.Sprintf("after part %d", cp.AfterPart)
+}
+
+func (cp CrashPoint) matches(req *http.Request) bool {
+	if cp.MidComplete {
+		return multipartclienttest.MatchComplete(req)
+	}
+	return multipartclienttest.MatchPart(cp.AfterPart)(req)
+}
+
+// Checkpoint is the only state an upload keeps across crashes. It is safe for
+// concurrent use.
+type Checkpoint struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+// Load returns the data last saved, or nil if there is none.
+func (c *Checkpoint) Load() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return bytes.Clone(c.data)
+}
+
+// Save replaces the checkpoint with data.
+func (c *Checkpoint) Save(data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data = bytes.Clone(data)
+}
+
+// UploadFunc uploads an object with hc, resuming from cp if it holds state
+// saved by an earlier run. It must return an error unless the object was
+// uploaded.
+type UploadFunc func(ctx context.Context, hc *http.Client, cp *Checkpoint) error
+
+// CrashScenario runs an upload against a Server, crashing it at each of
+// Crashes in turn and restarting it from its Checkpoint, and then checks that
+// a final uninterrupted run leaves the object with the expected data.
+type CrashScenario struct {
+	Server *Server
+	Bucket string
+	Key    string
+	// Want is the data the object must hold once the upload is done.
+	Want   []byte
+	Upload UploadFunc
+	// Crashes are the points the upload is crashed at, one per run.
+	Crashes []CrashPoint
+}
+
+// Run runs the scenario, failing t if a crash point is never reached, if a
+// crashed run reports success, or if the final run fails or leaves the object
+// with the wrong data.
+func (s *CrashScenario) Run(t *testing.T) {
+	t.Helper()
+	cp := &Checkpoint{}
+	for i, point := range s.Crashes {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		ct := &crashTransport{base: s.Server.Transport(), point: point, cancel: cancel}
+		err := s.Upload(ctx, &http.Client{Transport: ct}, cp)
+		cancel(nil)
+		if !ct.isCrashed() {
+			t.Fatalf("run %d: upload ended before crashing %v: %v", i+1, point, err)
+		}
+		if err == nil {
+			t.Fatalf("run %d: upload crashed %v but reported success", i+1, point)
+		}
+	}
+
+	if err := s.Upload(context.Background(), s.Server.Client(), cp); err != nil {
+		t.Fatalf("upload after %d crashes failed: %v", len(s.Crashes), err)
+	}
+	got, ok := s.Server.Object(s.Bucket, s.Key)
+	if !ok {
+		t.Fatalf("object %s/%s does not exist after the upload", s.Bucket, s.Key)
+	}
+	if !bytes.Equal(got, s.Want) {
+		t.Errorf("object %s/%s has the wrong data: got %d bytes, want %d, first difference at byte %d",
+			s.Bucket, s.Key, len(got), len(s.Want), firstDifference(got, s.Want))
+	}
+}
+
+func firstDifference(a, b []byte) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
+
+// crashTransport passes requests to base until a request matches point, and
+// then fails it and every later request with ErrCrashed.
+type crashTransport struct {
+	base   http.RoundTripper
+	point  CrashPoint
+	cancel context.CancelCauseFunc
+
+	mu      sync.Mutex
+	crashed bool
+}
+
+func (ct *crashTransport) isCrashed() bool {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	return ct.crashed
+}
+
+func (ct *crashTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if ct.isCrashed() {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, ErrCrashed
+	}
+	match := ct.point.matches(req)
+	resp, err := ct.base.RoundTrip(req)
+	if err != nil || !match || resp.StatusCode >= 300 {
+		return resp, err
+	}
+	resp.Body.Close()
+	ct.mu.Lock()
+	ct.crashed = true
+	ct.mu.Unlock()
+	// Stop requests the upload has in flight, as a killed process would.
+	ct.cancel(ErrCrashed)
+	return nil, ErrCrashed
+}
@@ -0,0 +1,199 @@
+package multiparttest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multiparttest"
+)
+
+const partSize = 4
+
+// uploadState is what resumableUpload saves in its checkpoint.
+type uploadState struct {
+	UploadID   string
+	Parts      []multipartclient.PartRecord
+	Completing bool
+}
+
+// resumableUpload uploads data in parts of partSize, recording each part in
+// cp so that a restarted upload only sends the parts the server is missing.
+func resumableUpload(data []byte) multiparttest.UploadFunc {
+	return func(ctx context.Context, hc *http.Client, cp *multiparttest.Checkpoint) error {
+		mpuc := multipartclient.New(hc)
+		var state uploadState
+		if saved := cp.Load(); saved != nil {
+			if err := json.Unmarshal(saved, &state); err != nil {
+				return err
+			}
+		}
+		save := func() error {
+			b, err := json.Marshal(state)
+			if err != nil {
+				return err
+			}
+			cp.Save(b)
+			return nil
+		}
+
+		if state.Completing {
+			// The crash may have come after the server completed the upload.
+			done, err := objectExists(ctx, hc)
+			if err != nil || done {
+				return err
+			}
+		}
+		if state.UploadID == "" {
+			initResult, err := mpuc.InitiateMultipartUpload(ctx, &multipartclient.InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt"})
+			if err != nil {
+				return err
+			}
+			state.UploadID = initResult.UploadID
+			if err := save(); err != nil {
+				return err
+			}
+		}
+
+		validation, err := mpuc.ValidateUploadedParts(ctx, &multipartclient.ListObjectPartsRequest{
+			Bucket:   "bucket1",
+			Key:      "object.txt",
+			UploadID: state.UploadID,
+		}, state.Parts)
+		if err != nil {
+			return err
+		}
+		verified := map[int]bool{}
+		for _, part := range validation.Verified {
+			verified[part.PartNumber] = true
+		}
+		state.Parts = state.Parts[:0]
+		for _, part := range validation.Verified {
+			state.Parts = append(state.Parts, multipartclient.PartRecord{PartNumber: part.PartNumber, ETag: part.ETag})
+		}
+
+		for start := 0; start < len(data); start += partSize {
+			partNumber := start/partSize + 1
+			if verified[partNumber] {
+				continue
+			}
+			result, err := mpuc.UploadObjectPart(ctx, &multipartclient.UploadObjectPartRequest{
+				Bucket:          "bucket1",
+				Key:             "object.txt",
+				PartNumber:      partNumber,
+				UploadID:        state.UploadID,
+				Body:            io.NopCloser(bytes.NewReader(data[start:min(start+partSize, len(data))])),
+				VerifyChecksums: true,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+			}
+			state.Parts = append(state.Parts, multipartclient.PartRecordFromResult(partNumber, result))
+			if err := save(); err != nil {
+				return err
+			}
+		}
+
+		state.Completing = true
+		if err := save(); err != nil {
+			return err
+		}
+		parts := make([]multipartclient.CompletePart, 0, len(state.Parts))
+		for _, record := range state.Parts {
+			parts = append(parts, multipartclient.CompletePart{PartNumber: record.PartNumber, ETag: record.ETag})
+		}
+		_, err = mpuc.CompleteMultipartUpload(ctx, &multipartclient.CompleteMultipartUploadRequest{
+			Bucket:   "bucket1",
+			Key:      "object.txt",
+			UploadID: state.UploadID,
+			Body:     multipartclient.CompleteMultipartUploadBody{Parts: parts},
+		})
+		return err
+	}
+}
+
+func objectExists(ctx context.Context, hc *http.Client) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://storage.googleapis.com/bucket1/object.txt", nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK, nil
+}
+
+func TestCrashScenario(t *testing.T) {
+	data := []byte("the quick brown fox jumps over the lazy dog")
+
+	tests := []struct {
+		name    string
+		crashes []multiparttest.CrashPoint
+	}{
+		{
+			name: "No crashes",
+		},
+		{
+			name:    "After first part",
+			crashes: []multiparttest.CrashPoint{{AfterPart: 1}},
+		},
+		{
+			name:    "After last part",
+			crashes: []multiparttest.CrashPoint{{AfterPart: 11}},
+		},
+		{
+			name:    "Mid-complete",
+			crashes: []multiparttest.CrashPoint{{MidComplete: true}},
+		},
+		{
+			name: "Repeated crashes",
+			crashes: []multiparttest.CrashPoint{
+				{AfterPart: 3},
+				{AfterPart: 7},
+				{MidComplete: true},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := multiparttest.NewServer(t)
+			srv.MinPartSize = partSize
+			scenario := &multiparttest.CrashScenario{
+				Server:  srv,
+				Bucket:  "bucket1",
+				Key:     "object.txt",
+				Want:    data,
+				Upload:  resumableUpload(data),
+				Crashes: tc.crashes,
+			}
+			scenario.Run(t)
+
+			if uploads := srv.Uploads(); len(uploads) != 0 {
+				t.Errorf("got uploads %v left in progress, want none", uploads)
+			}
+		})
+	}
+}
+
+func TestCrashPointString(t *testing.T) {
+	tests := []struct {
+		point multiparttest.CrashPoint
+		want  string
+	}{
+		{point: multiparttest.CrashPoint{AfterPart: 2}, want: "after part 2"},
+		{point: multiparttest.CrashPoint{MidComplete: true}, want: "mid-complete"},
+	}
+	for _, tc := range tests {
+		if got := tc.point.String(); got != tc.want {
+			t.Errorf("got %q, want %q", got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package multiparttest
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// NewPersistentServer starts a Server that keeps its state in dir as well as
+// in memory, and loads any state left there by an earlier server, so uploads
+// survive restarts of the test process. The server is closed when the test
+// finishes; dir is not removed.
+//
+// Part and object contents are stored as plain files so they can be
+// inspected:
+//
+//	dir/next-id                          the number of uploads initiated
+//	dir/uploads/UPLOAD_ID/upload.json    the bucket, key and initiation time of an upload
+//	dir/uploads/UPLOAD_ID/part-NNNNN     the data of part NNNNN
+//	dir/objects/BUCKET/KEY               the data of a completed object
+//
+// Bucket names and keys are path-escaped in file names.
+func NewPersistentServer(t *testing.T, dir string) (*Server, error) {
+	s := newServer()
+	s.dir = dir
+	if err := s.load(); err != nil {
+		return nil, fmt.Errorf("failed to load fake server state from %s: %w", dir, err)
+	}
+	s.start(t)
+	return s, nil
+}
+
+type uploadFile struct {
+	Bucket    string    `json:"bucket"`
+	Key       string    `json:"key"`
+	Initiated time.Time `json:"initiated"`
+}
+
+func (s *Server) uploadDir(uploadID string) string {
+	return filepath.Join(s.dir, "uploads", url.PathEscape(uploadID))
+}
+
+func (s *Server) objectPath(obj objectKey) string {
+	return filepath.Join(s.dir, "objects", url.PathEscape(obj.bucket), url.PathEscape(obj.key))
+}
+
+func partFileName(partNumber int) string {
+	return fmt.Sprintf("part-%05d", partNumber)
+}
+
+// writeFile replaces the file at path with data, so a crash never leaves it
+// partially written.
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// persistUpload records a new upload, and that nextID uploads have been
+// initiated so IDs aren't reused after a restart. s.mu must be held.
+func (s *Server) persistUpload(uploadID string, u *upload, nextID int) error {
+	if s.dir == "" {
+		return nil
+	}
+	b, err := json.Marshal(uploadFile{Bucket: u.bucket, Key: u.key, Initiated: u.initiated})
+	if err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(s.uploadDir(uploadID), "upload.json"), b); err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(s.dir, "next-id"), []byte(strconv.Itoa(nextID)))
+}
+
+// persistPart records the data of a part. s.mu must be held.
+func (s *Server) persistPart(uploadID string, partNumber int, data []byte) error {
+	if s.dir == "" {
+		return nil
+	}
+	return writeFile(filepath.Join(s.uploadDir(uploadID), partFileName(partNumber)), data)
+}
+
+// persistObject records the data of a completed object. s.mu must be held.
+func (s *Server) persistObject(obj objectKey, data []byte) error {
+	if s.dir == "" {
+		return nil
+	}
+	return writeFile(s.objectPath(obj), data)
+}
+
+// removeUpload deletes a completed or aborted upload. s.mu must be held.
+func (s *Server) removeUpload(uploadID string) error {
+	if s.dir == "" {
+		return nil
+	}
+	return os.RemoveAll(s.uploadDir(uploadID))
+}
+
+// load reads the state persisted in s.dir.
+func (s *Server) load() error {
+	b, err := os.ReadFile(filepath.Join(s.dir, "next-id"))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		// A new directory.
+	case err != nil:
+		return err
+	default:
+		if s.nextID, err = strconv.Atoi(strings.TrimSpace(string(b))); err != nil {
+			return fmt.Errorf("malformed next-id: %w", err)
+		}
+	}
+
+	uploadEntries, err := os.ReadDir(filepath.Join(s.dir, "uploads"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for _, entry := range uploadEntries {
+		uploadID, err := url.PathUnescape(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		u, err := s.loadUpload(uploadID)
+		if err != nil {
+			return fmt.Errorf("upload %s: %w", uploadID, err)
+		}
+		s.uploads[uploadID] = u
+	}
+
+	objectsDir := filepath.Join(s.dir, "objects")
+	bucketEntries, err := os.ReadDir(objectsDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for _, bucketEntry := range bucketEntries {
+		bucket, err := url.PathUnescape(bucketEntry.Name())
+		if err != nil || !bucketEntry.IsDir() {
+			continue
+		}
+		objectEntries, err := os.ReadDir(filepath.Join(objectsDir, bucketEntry.Name()))
+		if err != nil {
+			return err
+		}
+		for _, objectEntry := range objectEntries {
+			key, err := url.PathUnescape(objectEntry.Name())
+			if err != nil || objectEntry.IsDir() || strings.HasPrefix(objectEntry.Name(), ".tmp-") {
+				continue
+			}
+			path := filepath.Join(objectsDir, bucketEntry.Name(), objectEntry.Name())
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			s.objects[objectKey{bucket, key}] = &object{data: data, modified: info.ModTime()}
+		}
+	}
+	return nil
+}
+
+func (s *Server) loadUpload(uploadID string) (*upload, error) {
+	dir := s.uploadDir(uploadID)
+	b, err := os.ReadFile(filepath.Join(dir, "upload.json"))
+	if err != nil {
+		return nil, err
+	}
+	var uf uploadFile
+	if err := json.Unmarshal(b, &uf); err != nil {
+		return nil, err
+	}
+	u := &upload{objectKey: objectKey{uf.Bucket, uf.Key}, initiated: uf.Initiated, parts: map[int]*part{}}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		numStr, ok := strings.CutPrefix(entry.Name(), "part-")
+		if !ok {
+			continue
+		}
+		partNumber, err := strconv.Atoi(numStr)
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		sum := md5.Sum(data)
+		u.parts[partNumber] = &part{data: data, md5: hex.EncodeToString(sum[:])}
+	}
+	return u, nil
+}
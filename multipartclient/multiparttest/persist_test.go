@@ -0,0 +1,95 @@
+package multiparttest_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multiparttest"
+)
+
+func TestPersistentServer(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	// The first server starts an upload and is then abandoned, as if the
+	// process had exited.
+	srv1, err := multiparttest.NewPersistentServer(t, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mpuc := multipartclient.New(srv1.Client())
+	initResult, err := mpuc.InitiateMultipartUpload(ctx, &multipartclient.InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag1, err := uploadPart(ctx, mpuc, initResult.UploadID, 1, "hello ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, "uploads", initResult.UploadID, "part-00001"))
+	if err != nil || string(got) != "hello " {
+		t.Errorf("got part file %q (err %v), want %q", got, err, "hello ")
+	}
+
+	srv2, err := multiparttest.NewPersistentServer(t, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv2.MinPartSize = 4
+	mpuc = multipartclient.New(srv2.Client())
+	if diff := cmp.Diff([]string{initResult.UploadID}, srv2.Uploads()); diff != "" {
+		t.Errorf("unexpected diff for uploads after restart: (-want, +got):\n%s", diff)
+	}
+	listResult, err := mpuc.ListObjectParts(ctx, &multipartclient.ListObjectPartsRequest{Bucket: "bucket1", Key: "object.txt", UploadID: initResult.UploadID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantParts := []multipartclient.CompletePart{{PartNumber: 1, ETag: etag1}}
+	if diff := cmp.Diff(wantParts, listResult.Parts); diff != "" {
+		t.Errorf("unexpected diff for parts after restart: (-want, +got):\n%s", diff)
+	}
+
+	etag2, err := uploadPart(ctx, mpuc, initResult.UploadID, 2, "world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := complete(ctx, mpuc, initResult.UploadID, append(wantParts, multipartclient.CompletePart{PartNumber: 2, ETag: etag2})); err != nil {
+		t.Fatal(err)
+	}
+	otherInit, err := mpuc.InitiateMultipartUpload(ctx, &multipartclient.InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "other.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otherInit.UploadID == initResult.UploadID {
+		t.Errorf("upload ID %s was reused after a restart", otherInit.UploadID)
+	}
+
+	srv3, err := multiparttest.NewPersistentServer(t, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := srv3.Object("bucket1", "object.txt"); !ok || string(got) != "hello world" {
+		t.Errorf("got object %q (exists %v) after restart, want %q", got, ok, "hello world")
+	}
+	if diff := cmp.Diff([]string{otherInit.UploadID}, srv3.Uploads()); diff != "" {
+		t.Errorf("unexpected diff for uploads after completion: (-want, +got):\n%s", diff)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "uploads", initResult.UploadID)); !os.IsNotExist(err) {
+		t.Errorf("completed upload directory still exists: %v", err)
+	}
+}
+
+func TestPersistentServerMalformedState(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "next-id"), []byte("not a number"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := multiparttest.NewPersistentServer(t, dir); err == nil {
+		t.Errorf("got nil error for malformed state, want an error")
+	}
+}
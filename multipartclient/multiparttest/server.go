@@ -0,0 +1,537 @@
+// Package multiparttest provides a fake of the Cloud Storage XML multipart
+// upload API, kept in memory or persisted to a directory, and a simulator
+// that crashes and resumes uploads against it, so upload code can be
+// integration-tested without GCS.
+//
+// The fake initiates, lists, completes and aborts uploads, uploads and lists
+// parts, and serves completed objects. ETags are those of Cloud Storage: the
+// hex MD5 of a part, and for an object the MD5 of its parts' MD5s followed by
+// the number of parts. Listings are paginated as by Cloud Storage, with at
+// most 1000 entries a page unless the request asks for fewer.
+package multiparttest
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multipartclienttest"
+)
+
+// DefaultMinPartSize is the smallest size Cloud Storage allows for every part
+// but the last.
+const DefaultMinPartSize = 5 << 20
+
+// maxListEntries is the most uploads or parts listed in a page.
+const maxListEntries = 1000
+
+// Server is a fake Cloud Storage endpoint serving multipart uploads from
+// memory, or from a directory if started with NewPersistentServer. Buckets
+// don't need to be created. It is safe for concurrent use.
+type Server struct {
+	// MinPartSize is the smallest size allowed for every part but the last
+	// when an upload is completed. Defaults to DefaultMinPartSize; set it
+	// before sending requests.
+	MinPartSize int64
+
+	srv *httptest.Server
+	// dir, if set, is where the state is persisted; see NewPersistentServer.
+	dir string
+
+	mu      sync.Mutex
+	nextID  int
+	uploads map[string]*upload
+	objects map[objectKey]*object
+}
+
+type objectKey struct {
+	bucket, key string
+}
+
+type object struct {
+	data     []byte
+	modified time.Time
+}
+
+type upload struct {
+	objectKey
+	initiated time.Time
+	parts     map[int]*part
+}
+
+type part struct {
+	data []byte
+	// md5 is the hex MD5 of data, which is also the part's ETag.
+	md5 string
+}
+
+// NewServer starts a Server that is closed when the test finishes.
+func NewServer(t *testing.T) *Server {
+	s := newServer()
+	s.start(t)
+	return s
+}
+
+func newServer() *Server {
+	return &Server{
+		MinPartSize: DefaultMinPartSize,
+		uploads:     map[string]*upload{},
+		objects:     map[objectKey]*object{},
+	}
+}
+
+func (s *Server) start(t *testing.T) {
+	s.srv = httptest.NewServer(s)
+	t.Cleanup(s.srv.Close)
+}
+
+// URL returns the base URL of the server.
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Transport returns an http.RoundTripper that sends requests for any host,
+// such as storage.googleapis.com, to the server.
+func (s *Server) Transport() http.RoundTripper {
+	return multipartclienttest.TransportFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.URL.Scheme = "http"
+		req.URL.Host = s.srv.Listener.Addr().String()
+		req.Host = req.URL.Host
+		return s.srv.Client().Transport.RoundTrip(req)
+	})
+}
+
+// Client returns an *http.Client using Transport.
+func (s *Server) Client() *http.Client {
+	return &http.Client{Transport: s.Transport()}
+}
+
+// Object returns the data of a completed object, and whether it exists.
+func (s *Server) Object(bucket, key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.objects[objectKey{bucket, key}]
+	if !ok {
+		return nil, false
+	}
+	return bytes.Clone(o.data), true
+}
+
+// Uploads returns the IDs of the uploads in progress, in the order they were
+// initiated.
+func (s *Server) Uploads() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.uploads))
+	for id := range s.uploads {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return uploadSeq(ids[i]) < uploadSeq(ids[j])
+	})
+	return ids
+}
+
+func uploadSeq(id string) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(id, "upload-"))
+	return n
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	obj := objectKey{bucket, key}
+	q := r.URL.Query()
+
+	var resp *http.Response
+	switch {
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		resp = s.initiate(obj)
+	case r.Method == http.MethodPut && q.Has("uploadId") && r.Header.Get("x-goog-copy-source") == "":
+		resp = s.uploadPart(obj, q.Get("uploadId"), q.Get("partNumber"), r)
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		resp = s.complete(obj, q.Get("uploadId"), r.Body)
+	case r.Method == http.MethodDelete && q.Has("uploadId"):
+		resp = s.abort(obj, q.Get("uploadId"))
+	case r.Method == http.MethodGet && q.Has("uploads") && key == "":
+		resp = s.listUploads(bucket, q)
+	case r.Method == http.MethodGet && q.Has("uploadId"):
+		resp = s.listParts(obj, q.Get("uploadId"), q)
+	case r.Method == http.MethodGet && len(q) == 0:
+		resp = s.getObject(obj)
+	case r.Method == http.MethodHead && len(q) == 0:
+		resp = s.headObject(obj)
+	default:
+		resp = multipartclienttest.ErrorResponse(http.StatusNotImplemented, "NotImplemented",
+			fmt.Sprintf("%s %s is not supported by the fake server.", r.Method, r.URL.RequestURI()))
+	}
+	writeResponse(w, resp)
+}
+
+func writeResponse(w http.ResponseWriter, resp *http.Response) {
+	defer resp.Body.Close()
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+func internalError(err error) *http.Response {
+	return multipartclienttest.ErrorResponse(http.StatusInternalServerError, "InternalError", err.Error())
+}
+
+func noSuchUpload(uploadID string) *http.Response {
+	return multipartclienttest.ErrorResponse(http.StatusNotFound, "NoSuchUpload",
+		fmt.Sprintf("The requested upload %s was not found.", uploadID))
+}
+
+// lookup returns the upload uploadID of obj. s.mu must be held.
+func (s *Server) lookup(obj objectKey, uploadID string) (*upload, bool) {
+	u, ok := s.uploads[uploadID]
+	if !ok || u.objectKey != obj {
+		return nil, false
+	}
+	return u, true
+}
+
+func (s *Server) initiate(obj objectKey) *http.Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := fmt.Sprintf("upload-%d", s.nextID+1)
+	u := &upload{objectKey: obj, initiated: time.Now().UTC().Truncate(time.Millisecond), parts: map[int]*part{}}
+	if err := s.persistUpload(id, u, s.nextID+1); err != nil {
+		return internalError(err)
+	}
+	s.nextID++
+	s.uploads[id] = u
+	return multipartclienttest.InitiateResponse(obj.bucket, obj.key, id)
+}
+
+func (s *Server) uploadPart(obj objectKey, uploadID, partNumber string, r *http.Request) *http.Response {
+	n, err := strconv.Atoi(partNumber)
+	if err != nil || n < 1 || n > 10000 {
+		return multipartclienttest.ErrorResponse(http.StatusBadRequest, "InvalidArgument",
+			fmt.Sprintf("Part number %q must be an integer between 1 and 10000.", partNumber))
+	}
+	want, err := gcshash.ParseHeader(r.Header)
+	if err != nil {
+		return multipartclienttest.ErrorResponse(http.StatusBadRequest, "InvalidDigest", err.Error())
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return multipartclienttest.ErrorResponse(http.StatusBadRequest, "IncompleteBody", err.Error())
+	}
+
+	md5Sum := md5.Sum(data)
+	got := gcshash.Sums{CRC32C: gcshash.CRC32C(data), HasCRC32C: true, MD5: md5Sum[:]}
+	if (want.HasCRC32C && want.CRC32C != got.CRC32C) || (want.MD5 != nil && !bytes.Equal(want.MD5, got.MD5)) {
+		return multipartclienttest.ErrorResponse(http.StatusBadRequest, "BadDigest",
+			"The checksums sent do not match the data received.")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.lookup(obj, uploadID)
+	if !ok {
+		return noSuchUpload(uploadID)
+	}
+	if err := s.persistPart(uploadID, n, data); err != nil {
+		return internalError(err)
+	}
+	p := &part{data: data, md5: hex.EncodeToString(md5Sum[:])}
+	u.parts[n] = p
+	resp := multipartclienttest.UploadPartResponse(p.md5)
+	got.SetHeader(resp.Header)
+	return resp
+}
+
+// completeBody is the CompleteMultipartUpload request body.
+type completeBody struct {
+	Parts []completePart `xml:"Part"`
+}
+
+type completePart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func (s *Server) complete(obj objectKey, uploadID string, body io.Reader) *http.Response {
+	var cb completeBody
+	if err := xml.NewDecoder(body).Decode(&cb); err != nil {
+		return multipartclienttest.ErrorResponse(http.StatusBadRequest, "MalformedXML", err.Error())
+	}
+	parts := cb.Parts
+	if len(parts) == 0 {
+		return multipartclienttest.ErrorResponse(http.StatusBadRequest, "MalformedXML",
+			"The request must list at least one part.")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.lookup(obj, uploadID)
+	if !ok {
+		return noSuchUpload(uploadID)
+	}
+	var data []byte
+	md5s := &strings.Builder{}
+	for i, cp := range parts {
+		if i > 0 && cp.PartNumber <= parts[i-1].PartNumber {
+			return multipartclienttest.ErrorResponse(http.StatusBadRequest, "InvalidPartOrder",
+				"The parts must be listed in ascending order of part number.")
+		}
+		p, ok := u.parts[cp.PartNumber]
+		if !ok || strings.Trim(cp.ETag, `"`) != p.md5 {
+			return multipartclienttest.ErrorResponse(http.StatusBadRequest, "InvalidPart",
+				fmt.Sprintf("Part %d was not found or its ETag does not match.", cp.PartNumber))
+		}
+		if i < len(parts)-1 && int64(len(p.data)) < s.MinPartSize {
+			return multipartclienttest.ErrorResponse(http.StatusBadRequest, "EntityTooSmall",
+				fmt.Sprintf("Part %d is smaller than the minimum allowed size.", cp.PartNumber))
+		}
+		data = append(data, p.data...)
+		sum, _ := hex.DecodeString(p.md5)
+		md5s.Write(sum)
+	}
+
+	if err := s.persistObject(obj, data); err != nil {
+		return internalError(err)
+	}
+	if err := s.removeUpload(uploadID); err != nil {
+		return internalError(err)
+	}
+	s.objects[obj] = &object{data: data, modified: time.Now()}
+	delete(s.uploads, uploadID)
+	etag := fmt.Sprintf("%x-%d", md5.Sum([]byte(md5s.String())), len(parts))
+	return multipartclienttest.CompleteResponse(obj.bucket, obj.key, etag)
+}
+
+func (s *Server) abort(obj objectKey, uploadID string) *http.Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.lookup(obj, uploadID); !ok {
+		return noSuchUpload(uploadID)
+	}
+	if err := s.removeUpload(uploadID); err != nil {
+		return internalError(err)
+	}
+	delete(s.uploads, uploadID)
+	return multipartclienttest.AbortResponse()
+}
+
+// maxEntries returns the page size asked for by the query parameter name, or
+// maxListEntries if it is missing or larger.
+func maxEntries(q url.Values, name string) (int, *http.Response) {
+	v := q.Get(name)
+	if v == "" {
+		return maxListEntries, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, multipartclienttest.ErrorResponse(http.StatusBadRequest, "InvalidArgument",
+			fmt.Sprintf("%s %q must be a non-negative integer.", name, v))
+	}
+	return min(n, maxListEntries), nil
+}
+
+// listUploadsResult is the ListMultipartUploads response body.
+type listUploadsResult struct {
+	XMLName            xml.Name       `xml:"ListMultipartUploadsResult"`
+	Bucket             string         `xml:"Bucket"`
+	KeyMarker          string         `xml:"KeyMarker"`
+	UploadIDMarker     string         `xml:"UploadIdMarker"`
+	NextKeyMarker      string         `xml:"NextKeyMarker,omitempty"`
+	NextUploadIDMarker string         `xml:"NextUploadIdMarker,omitempty"`
+	Prefix             string         `xml:"Prefix"`
+	MaxUploads         int            `xml:"MaxUploads"`
+	IsTruncated        bool           `xml:"IsTruncated"`
+	Uploads            []listedUpload `xml:"Upload"`
+}
+
+type listedUpload struct {
+	Key       string    `xml:"Key"`
+	UploadID  string    `xml:"UploadId"`
+	Initiated time.Time `xml:"Initiated"`
+}
+
+// listUploads lists the uploads in progress in bucket, ordered by key and
+// then by the order they were initiated, after the key-marker and
+// upload-id-marker of q.
+func (s *Server) listUploads(bucket string, q url.Values) *http.Response {
+	maxUploads, errResp := maxEntries(q, "max-uploads")
+	if errResp != nil {
+		return errResp
+	}
+	prefix, keyMarker, idMarker := q.Get("prefix"), q.Get("key-marker"), q.Get("upload-id-marker")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var uploads []listedUpload
+	for id, u := range s.uploads {
+		if u.bucket != bucket || !strings.HasPrefix(u.key, prefix) {
+			continue
+		}
+		// Without an upload ID marker, the uploads of the key marker are
+		// skipped too.
+		if u.key < keyMarker || u.key == keyMarker && (idMarker == "" || uploadSeq(id) <= uploadSeq(idMarker)) {
+			continue
+		}
+		uploads = append(uploads, listedUpload{Key: u.key, UploadID: id, Initiated: u.initiated})
+	}
+	sort.Slice(uploads, func(i, j int) bool {
+		if uploads[i].Key != uploads[j].Key {
+			return uploads[i].Key < uploads[j].Key
+		}
+		return uploadSeq(uploads[i].UploadID) < uploadSeq(uploads[j].UploadID)
+	})
+
+	result := &listUploadsResult{
+		Bucket:         bucket,
+		KeyMarker:      keyMarker,
+		UploadIDMarker: idMarker,
+		Prefix:         prefix,
+		MaxUploads:     maxUploads,
+	}
+	if len(uploads) > maxUploads {
+		uploads = uploads[:maxUploads]
+		result.IsTruncated = true
+		if maxUploads > 0 {
+			last := uploads[len(uploads)-1]
+			result.NextKeyMarker, result.NextUploadIDMarker = last.Key, last.UploadID
+		} else {
+			result.NextKeyMarker, result.NextUploadIDMarker = keyMarker, idMarker
+		}
+	}
+	result.Uploads = uploads
+	return xmlResponse(result)
+}
+
+// listPartsResult is the ListObjectParts response body.
+type listPartsResult struct {
+	XMLName              xml.Name     `xml:"ListPartsResult"`
+	Bucket               string       `xml:"Bucket"`
+	Key                  string       `xml:"Key"`
+	UploadID             string       `xml:"UploadId"`
+	PartNumberMarker     int          `xml:"PartNumberMarker"`
+	NextPartNumberMarker int          `xml:"NextPartNumberMarker,omitempty"`
+	MaxParts             int          `xml:"MaxParts"`
+	IsTruncated          bool         `xml:"IsTruncated"`
+	Parts                []listedPart `xml:"Part"`
+}
+
+type listedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+	Size       int    `xml:"Size"`
+}
+
+// listParts lists the parts of an upload in order of part number, after the
+// part-number-marker of q.
+func (s *Server) listParts(obj objectKey, uploadID string, q url.Values) *http.Response {
+	maxParts, errResp := maxEntries(q, "max-parts")
+	if errResp != nil {
+		return errResp
+	}
+	marker := 0
+	if v := q.Get("part-number-marker"); v != "" {
+		var err error
+		if marker, err = strconv.Atoi(v); err != nil {
+			return multipartclienttest.ErrorResponse(http.StatusBadRequest, "InvalidArgument",
+				fmt.Sprintf("part-number-marker %q must be an integer.", v))
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.lookup(obj, uploadID)
+	if !ok {
+		return noSuchUpload(uploadID)
+	}
+	parts := make([]listedPart, 0, len(u.parts))
+	for n, p := range u.parts {
+		if n > marker {
+			parts = append(parts, listedPart{PartNumber: n, ETag: strconv.Quote(p.md5), Size: len(p.data)})
+		}
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+
+	result := &listPartsResult{
+		Bucket:           obj.bucket,
+		Key:              obj.key,
+		UploadID:         uploadID,
+		PartNumberMarker: marker,
+		MaxParts:         maxParts,
+	}
+	if len(parts) > maxParts {
+		parts = parts[:maxParts]
+		result.IsTruncated = true
+		result.NextPartNumberMarker = marker
+		if maxParts > 0 {
+			result.NextPartNumberMarker = parts[len(parts)-1].PartNumber
+		}
+	}
+	result.Parts = parts
+	return xmlResponse(result)
+}
+
+// xmlResponse returns a successful response with v as its XML body.
+func xmlResponse(v any) *http.Response {
+	b, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return internalError(err)
+	}
+	return multipartclienttest.XMLResponse(http.StatusOK, xml.Header+string(b))
+}
+
+func (s *Server) getObject(obj objectKey) *http.Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.objects[obj]
+	if !ok {
+		return noSuchKey()
+	}
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Content-Type": []string{"application/octet-stream"}},
+		ContentLength: int64(len(o.data)),
+		Body:          io.NopCloser(bytes.NewReader(bytes.Clone(o.data))),
+	}
+}
+
+// headObject responds with the size, modification time and CRC32C of an
+// object, like Cloud Storage does for an object assembled from parts.
+func (s *Server) headObject(obj objectKey) *http.Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.objects[obj]
+	if !ok {
+		resp := noSuchKey()
+		resp.Body = http.NoBody
+		return resp
+	}
+	h := http.Header{
+		"Content-Type":   []string{"application/octet-stream"},
+		"Content-Length": []string{strconv.Itoa(len(o.data))},
+		"Last-Modified":  []string{o.modified.UTC().Format(http.TimeFormat)},
+	}
+	gcshash.Sums{CRC32C: gcshash.CRC32C(o.data), HasCRC32C: true}.SetHeader(h)
+	return &http.Response{StatusCode: http.StatusOK, Header: h, Body: http.NoBody}
+}
+
+func noSuchKey() *http.Response {
+	return multipartclienttest.ErrorResponse(http.StatusNotFound, "NoSuchKey",
+		"The specified key does not exist.")
+}
@@ -0,0 +1,316 @@
+package multiparttest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multiparttest"
+)
+
+func uploadPart(ctx context.Context, mpuc *multipartclient.MultipartClient, uploadID string, partNumber int, data string) (string, error) {
+	result, err := mpuc.UploadObjectPart(ctx, &multipartclient.UploadObjectPartRequest{
+		Bucket:          "bucket1",
+		Key:             "object.txt",
+		PartNumber:      partNumber,
+		UploadID:        uploadID,
+		Body:            io.NopCloser(strings.NewReader(data)),
+		VerifyChecksums: true,
+	})
+	if err != nil {
+		return "", err
+	}
+	return result.ETag, nil
+}
+
+func TestServerUpload(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	srv.MinPartSize = 4
+	mpuc := multipartclient.New(srv.Client())
+	ctx := context.Background()
+
+	initResult, err := mpuc.InitiateMultipartUpload(ctx, &multipartclient.InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{initResult.UploadID}, srv.Uploads()); diff != "" {
+		t.Errorf("unexpected diff for uploads: (-want, +got):\n%s", diff)
+	}
+
+	var parts []multipartclient.CompletePart
+	for i, data := range []string{"hello ", "world"} {
+		etag, err := uploadPart(ctx, mpuc, initResult.UploadID, i+1, data)
+		if err != nil {
+			t.Fatalf("failed to upload part %d: %v", i+1, err)
+		}
+		parts = append(parts, multipartclient.CompletePart{PartNumber: i + 1, ETag: etag})
+	}
+
+	listResult, err := mpuc.ListObjectParts(ctx, &multipartclient.ListObjectPartsRequest{Bucket: "bucket1", Key: "object.txt", UploadID: initResult.UploadID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(parts, listResult.Parts, cmpopts.IgnoreFields(multipartclient.CompletePart{}, "ETag")); diff != "" {
+		t.Errorf("unexpected diff for listed parts: (-want, +got):\n%s", diff)
+	}
+
+	completeResult, err := mpuc.CompleteMultipartUpload(ctx, &multipartclient.CompleteMultipartUploadRequest{
+		Bucket:   "bucket1",
+		Key:      "object.txt",
+		UploadID: initResult.UploadID,
+		Body:     multipartclient.CompleteMultipartUploadBody{Parts: parts},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := multipartclient.MultipartETagFromParts(parts); strings.Trim(completeResult.ETag, `"`) != want {
+		t.Errorf("got ETag %s, want %s", completeResult.ETag, want)
+	}
+
+	got, ok := srv.Object("bucket1", "object.txt")
+	if !ok || string(got) != "hello world" {
+		t.Errorf("got object %q (exists %v), want %q", got, ok, "hello world")
+	}
+	if uploads := srv.Uploads(); len(uploads) != 0 {
+		t.Errorf("got uploads %v after completion, want none", uploads)
+	}
+
+	resp, err := srv.Client().Get("https://storage.googleapis.com/bucket1/object.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "hello world" {
+		t.Errorf("got GET response %d %q, want 200 %q", resp.StatusCode, body, "hello world")
+	}
+
+	attrs, err := mpuc.StatObject(ctx, multipartclient.ObjectRef{Bucket: "bucket1", Key: "object.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSums := gcshash.Sums{CRC32C: gcshash.CRC32C([]byte("hello world")), HasCRC32C: true}
+	if attrs.Size != 11 || !cmp.Equal(attrs.Sums, wantSums) || attrs.LastModified.IsZero() {
+		t.Errorf("got attrs %+v, want 11 bytes with sums %+v and a modification time", attrs, wantSums)
+	}
+	if _, err := mpuc.StatObject(ctx, multipartclient.ObjectRef{Bucket: "bucket1", Key: "missing.txt"}); !errors.Is(err, multipartclient.ErrObjectNotExist) {
+		t.Errorf("got error %v for a missing object, want ErrObjectNotExist", err)
+	}
+}
+
+func TestServerListUploads(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	mpuc := multipartclient.New(srv.Client())
+	ctx := context.Background()
+
+	ids := map[string][]string{}
+	for _, key := range []string{"dir/b.txt", "dir/a.txt", "other.txt", "dir/a.txt", "dir/c.txt"} {
+		result, err := mpuc.InitiateMultipartUpload(ctx, &multipartclient.InitiateMultipartUploadRequest{Bucket: "bucket1", Key: key})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[key] = append(ids[key], result.UploadID)
+	}
+	if _, err := mpuc.InitiateMultipartUpload(ctx, &multipartclient.InitiateMultipartUploadRequest{Bucket: "bucket2", Key: "dir/a.txt"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var pages [][]string
+	err := mpuc.ListMultipartUploadsPages(ctx, &multipartclient.ListMultipartUploadsRequest{Bucket: "bucket1", Prefix: "dir/", MaxUploads: 2},
+		func(page *multipartclient.ListMultipartUploadsResult) bool {
+			var got []string
+			for _, u := range page.Uploads {
+				if u.Initiated.IsZero() {
+					t.Errorf("upload %s has no initiation time", u.UploadID)
+				}
+				got = append(got, u.Key+" "+u.UploadID)
+			}
+			pages = append(pages, got)
+			return true
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"dir/a.txt " + ids["dir/a.txt"][0], "dir/a.txt " + ids["dir/a.txt"][1]},
+		{"dir/b.txt " + ids["dir/b.txt"][0], "dir/c.txt " + ids["dir/c.txt"][0]},
+	}
+	if diff := cmp.Diff(want, pages); diff != "" {
+		t.Errorf("unexpected diff for pages of uploads: (-want, +got):\n%s", diff)
+	}
+
+	// Without an upload ID marker, the listing starts after the key marker.
+	result, err := mpuc.ListMultipartUploads(ctx, &multipartclient.ListMultipartUploadsRequest{Bucket: "bucket1", KeyMarker: "dir/b.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, u := range result.Uploads {
+		keys = append(keys, u.Key)
+	}
+	if diff := cmp.Diff([]string{"dir/c.txt", "other.txt"}, keys); diff != "" || result.IsTruncated {
+		t.Errorf("unexpected diff for keys after dir/b.txt (truncated %v): (-want, +got):\n%s", result.IsTruncated, diff)
+	}
+}
+
+func TestServerListPartsPages(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	mpuc := multipartclient.New(srv.Client())
+	ctx := context.Background()
+
+	initResult, err := mpuc.InitiateMultipartUpload(ctx, &multipartclient.InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{5, 1, 3, 2} {
+		if _, err := uploadPart(ctx, mpuc, initResult.UploadID, n, "part"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := &multipartclient.ListObjectPartsRequest{Bucket: "bucket1", Key: "object.txt", UploadID: initResult.UploadID, MaxParts: 3}
+	first, err := mpuc.ListObjectParts(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.IsTruncated || first.NextPartNumberMarker != 3 || len(first.Parts) != 3 {
+		t.Errorf("got first page %+v, want parts 1 to 3 and a marker of 3", first)
+	}
+
+	parts, err := mpuc.ListAllObjectParts(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int
+	for _, p := range parts {
+		got = append(got, p.PartNumber)
+	}
+	if diff := cmp.Diff([]int{1, 2, 3, 5}, got); diff != "" {
+		t.Errorf("unexpected diff for part numbers: (-want, +got):\n%s", diff)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		// run is given an initiated upload with parts 1 "hello " and 2 "world".
+		run     func(ctx context.Context, mpuc *multipartclient.MultipartClient, uploadID string, parts []multipartclient.CompletePart) error
+		wantErr string
+	}{
+		{
+			name: "Unknown upload",
+			run: func(ctx context.Context, mpuc *multipartclient.MultipartClient, _ string, _ []multipartclient.CompletePart) error {
+				_, err := uploadPart(ctx, mpuc, "upload-99", 1, "data")
+				return err
+			},
+			wantErr: "NoSuchUpload",
+		},
+		{
+			name: "Part number out of range",
+			run: func(ctx context.Context, mpuc *multipartclient.MultipartClient, uploadID string, _ []multipartclient.CompletePart) error {
+				_, err := uploadPart(ctx, mpuc, uploadID, 10001, "data")
+				return err
+			},
+			wantErr: "InvalidArgument",
+		},
+		{
+			name: "Bad digest",
+			run: func(ctx context.Context, mpuc *multipartclient.MultipartClient, uploadID string, _ []multipartclient.CompletePart) error {
+				_, err := mpuc.UploadObjectPart(ctx, &multipartclient.UploadObjectPartRequest{
+					Bucket:     "bucket1",
+					Key:        "object.txt",
+					PartNumber: 3,
+					UploadID:   uploadID,
+					Body:       io.NopCloser(strings.NewReader("data")),
+					Hashes:     gcshash.Sums{CRC32C: 1, HasCRC32C: true},
+				})
+				return err
+			},
+			wantErr: "BadDigest",
+		},
+		{
+			name: "Wrong ETag",
+			run: func(ctx context.Context, mpuc *multipartclient.MultipartClient, uploadID string, parts []multipartclient.CompletePart) error {
+				parts[1].ETag = `"0123"`
+				return complete(ctx, mpuc, uploadID, parts)
+			},
+			wantErr: "InvalidPart",
+		},
+		{
+			name: "Parts out of order",
+			run: func(ctx context.Context, mpuc *multipartclient.MultipartClient, uploadID string, parts []multipartclient.CompletePart) error {
+				parts[0], parts[1] = parts[1], parts[0]
+				return complete(ctx, mpuc, uploadID, parts)
+			},
+			wantErr: "InvalidPartOrder",
+		},
+		{
+			name: "Part too small",
+			run: func(ctx context.Context, mpuc *multipartclient.MultipartClient, uploadID string, parts []multipartclient.CompletePart) error {
+				etag, err := uploadPart(ctx, mpuc, uploadID, 1, "hi")
+				if err != nil {
+					return err
+				}
+				parts[0].ETag = etag
+				return complete(ctx, mpuc, uploadID, parts)
+			},
+			wantErr: "EntityTooSmall",
+		},
+		{
+			name: "Aborted upload",
+			run: func(ctx context.Context, mpuc *multipartclient.MultipartClient, uploadID string, parts []multipartclient.CompletePart) error {
+				if err := mpuc.AbortMultipartUpload(ctx, &multipartclient.AbortMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt", UploadID: uploadID}); err != nil {
+					return err
+				}
+				return complete(ctx, mpuc, uploadID, parts)
+			},
+			wantErr: "NoSuchUpload",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := multiparttest.NewServer(t)
+			srv.MinPartSize = 4
+			mpuc := multipartclient.New(srv.Client())
+			ctx := context.Background()
+
+			initResult, err := mpuc.InitiateMultipartUpload(ctx, &multipartclient.InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var parts []multipartclient.CompletePart
+			for i, data := range []string{"hello ", "world"} {
+				etag, err := uploadPart(ctx, mpuc, initResult.UploadID, i+1, data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				parts = append(parts, multipartclient.CompletePart{PartNumber: i + 1, ETag: etag})
+			}
+
+			err = tc.run(ctx, mpuc, initResult.UploadID, parts)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("got error %v, want one containing %q", err, tc.wantErr)
+			}
+			if _, ok := srv.Object("bucket1", "object.txt"); ok {
+				t.Errorf("object exists after a failed upload")
+			}
+		})
+	}
+}
+
+func complete(ctx context.Context, mpuc *multipartclient.MultipartClient, uploadID string, parts []multipartclient.CompletePart) error {
+	_, err := mpuc.CompleteMultipartUpload(ctx, &multipartclient.CompleteMultipartUploadRequest{
+		Bucket:   "bucket1",
+		Key:      "object.txt",
+		UploadID: uploadID,
+		Body:     multipartclient.CompleteMultipartUploadBody{Parts: parts},
+	})
+	return err
+}
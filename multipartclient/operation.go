@@ -0,0 +1,59 @@
+package multipartclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// operationInfo describes a call to one of the client's operations, as
+// opposed to a single HTTP request of it.
+type operationInfo struct {
+	Bucket     string
+	Key        string
+	UploadID   string
+	PartNumber int
+	// Bytes is the part data sent, over all attempts.
+	Bytes int64
+}
+
+// InterruptedError is returned by an operation that failed once its context
+// was done. It tells how far the operation got, such as how much of a part
+// was sent before it was stopped. It wraps the error of the request, so
+// errors.Is(err, context.Canceled) still holds.
+type InterruptedError struct {
+	// Op is the operation, such as OpUploadObjectPart.
+	Op string
+	// Elapsed is the time from the start of the operation until it stopped.
+	Elapsed time.Duration
+	// BytesSent is the part data read by the HTTP transport over all
+	// attempts, some of which may not have reached the server. It is zero for
+	// operations other than UploadObjectPart.
+	BytesSent int64
+	Err       error
+}
+
+func (e *InterruptedError) Error() string {
+	return fmt.Sprintf("%s interrupted after %v with %d bytes sent: %v", e.Op, e.Elapsed, e.BytesSent, e.Err)
+}
+
+func (e *InterruptedError) Unwrap() error {
+	return e.Err
+}
+
+// operationDone is called once at the end of every operation started at
+// start, with its request and the error it returns, and returns the error the
+// operation returns instead.
+func (mpuc *MultipartClient) operationDone(ctx context.Context, op string, req any, info operationInfo, start time.Time, err error) error {
+	var interrupted *InterruptedError
+	if err != nil && ctx.Err() != nil && !errors.As(err, &interrupted) {
+		err = &InterruptedError{Op: op, Elapsed: mpuc.since(start), BytesSent: info.Bytes, Err: err}
+	}
+	mpuc.audit(ctx, op, info, start, err)
+	if err != nil && mpuc.onError != nil {
+		mpuc.onError(op, req, err)
+	}
+	endOperationSpan(ctx, info, err)
+	return err
+}
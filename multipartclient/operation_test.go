@@ -0,0 +1,131 @@
+package multipartclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+)
+
+func TestWithOnError(t *testing.T) {
+	trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodDelete {
+			return &http.Response{StatusCode: http.StatusNoContent, Status: "No Content", Body: http.NoBody}, nil
+		}
+		if _, err := io.Copy(io.Discard, req.Body); err != nil {
+			t.Fatal(err)
+		}
+		// Report a corrupt part on every upload.
+		resp := &http.Response{StatusCode: http.StatusOK, Status: "OK", Header: http.Header{}, Body: http.NoBody}
+		gcshash.Sums{CRC32C: 1, HasCRC32C: true}.SetHeader(resp.Header)
+		return resp, nil
+	})
+
+	type call struct {
+		op  string
+		req any
+		err error
+	}
+	var calls []call
+	mpuc := New(&http.Client{Transport: trans}, WithOnError(func(op string, req any, err error) {
+		calls = append(calls, call{op, req, err})
+	}))
+
+	ctx := context.Background()
+	if err := mpuc.AbortMultipartUpload(ctx, &AbortMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt", UploadID: "my-upload-id"}); err != nil {
+		t.Fatal(err)
+	}
+	req := &UploadObjectPartRequest{
+		Bucket:          "bucket1",
+		Key:             "object.txt",
+		PartNumber:      1,
+		UploadID:        "my-upload-id",
+		Body:            &seekableBody{Reader: strings.NewReader("part contents")},
+		VerifyChecksums: true,
+	}
+	_, err := mpuc.UploadObjectPart(ctx, req)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	// Only the upload failed, and it is reported once after all attempts.
+	if len(calls) != 1 {
+		t.Fatalf("got %d calls, want 1", len(calls))
+	}
+	if calls[0].op != OpUploadObjectPart {
+		t.Errorf("got op %q, want %q", calls[0].op, OpUploadObjectPart)
+	}
+	if calls[0].req != req {
+		t.Errorf("got req %v, want %v", calls[0].req, req)
+	}
+	var mismatchErr *ChecksumMismatchError
+	if !errors.As(calls[0].err, &mismatchErr) {
+		t.Errorf("got error %v, want a *ChecksumMismatchError", calls[0].err)
+	}
+}
+
+func TestInterruptedError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+		// Send part of the body before the caller gives up.
+		if _, err := io.CopyN(io.Discard, req.Body, 1000); err != nil {
+			t.Fatal(err)
+		}
+		cancel()
+		return nil, req.Context().Err()
+	})
+	var reported error
+	mpuc := New(&http.Client{Transport: trans}, WithOnError(func(op string, req any, err error) { reported = err }))
+
+	_, err := mpuc.UploadObjectPart(ctx, &UploadObjectPartRequest{
+		Bucket:     "bucket1",
+		Key:        "object.txt",
+		PartNumber: 1,
+		UploadID:   "my-upload-id",
+		Body:       NewSectionBody(strings.NewReader(strings.Repeat("x", 4096)), 0, 4096),
+	})
+	var interrupted *InterruptedError
+	if !errors.As(err, &interrupted) {
+		t.Fatalf("got error %v, want an *InterruptedError", err)
+	}
+	if interrupted.Op != OpUploadObjectPart || interrupted.BytesSent != 1000 {
+		t.Errorf("got op %q with %d bytes sent, want %q with 1000", interrupted.Op, interrupted.BytesSent, OpUploadObjectPart)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	if reported != err {
+		t.Errorf("WithOnError got %v, want %v", reported, err)
+	}
+}
+
+func TestUploadObjectPartCanceled(t *testing.T) {
+	trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+		t.Fatal("unexpected request")
+		return nil, nil
+	})
+	mpuc := New(&http.Client{Transport: trans})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	body := &seekableBody{Reader: strings.NewReader("part contents")}
+	_, err := mpuc.UploadObjectPart(ctx, &UploadObjectPartRequest{
+		Bucket:          "bucket1",
+		Key:             "object.txt",
+		PartNumber:      1,
+		UploadID:        "my-upload-id",
+		Body:            body,
+		VerifyChecksums: true,
+	})
+	var interrupted *InterruptedError
+	if !errors.As(err, &interrupted) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want an *InterruptedError for %v", err, context.Canceled)
+	}
+	if !body.closed {
+		t.Error("body wasn't closed")
+	}
+}
@@ -0,0 +1,153 @@
+package multipartclient
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+)
+
+// Option configures a client created by New.
+type Option func(*MultipartClient)
+
+// WithEndpoint sends requests to the XML API at endpoint instead of
+// https://storage.googleapis.com: for example https://storage.mtls.googleapis.com,
+// a Private Service Connect endpoint, or the URL of an emulator or a
+// multiparttest.Server in tests. Buckets are addressed under the endpoint's
+// path, if any. WithS3Compatibility sets the endpoint of S3-compatible
+// servers instead. With NewClientWithOptions, option.WithEndpoint takes
+// precedence, and WithEndpoint takes precedence over $STORAGE_EMULATOR_HOST.
+func WithEndpoint(endpoint string) Option {
+	return func(mpuc *MultipartClient) {
+		mpuc.endpoint = strings.TrimSuffix(endpoint, "/")
+	}
+}
+
+// WithContentSHA256 sets how the x-goog-content-sha256 payload hash header is
+// attached to requests. Request signers include this header in the signed
+// request, so it must be set whenever signing is enabled.
+func WithContentSHA256(mode ContentSHA256Mode) Option {
+	return func(mpuc *MultipartClient) {
+		mpuc.contentSHA256 = mode
+	}
+}
+
+// WithHashAlgorithms selects the checksums computed for parts uploaded with
+// VerifyChecksums from the algorithms enabled in registry, or all of them if
+// no names are given. Only CRC32C and MD5 can be verified against the server;
+// other digests are reported in UploadObjectPartResult.ComputedDigests. By
+// default CRC32C and MD5 are computed.
+func WithHashAlgorithms(registry *gcshash.Registry, names ...string) Option {
+	return func(mpuc *MultipartClient) {
+		mpuc.hashRegistry = registry
+		mpuc.hashNames = names
+	}
+}
+
+// WithHashWorkers hashes part data on background goroutines, at most workers
+// blocks at a time across all parts, instead of on the goroutine sending the
+// part. This overlaps CPU-bound hashing with network I/O for high-throughput
+// uploads of parts with VerifyChecksums.
+func WithHashWorkers(workers int) Option {
+	return func(mpuc *MultipartClient) {
+		if workers > 0 {
+			mpuc.hashPool = newHashPool(workers)
+		}
+	}
+}
+
+// WithHashingDisabled turns off all client-side hashing of request bodies for
+// maximum throughput on trusted networks: VerifyChecksums and the check of
+// supplied hashes are skipped, and ContentSHA256Auto sends UnsignedPayload for
+// every non-empty body. Supplied hashes are still sent for the server to
+// check. Results and part records report that the client did not verify
+// integrity.
+func WithHashingDisabled() Option {
+	return func(mpuc *MultipartClient) {
+		mpuc.hashingDisabled = true
+	}
+}
+
+// WithPartChecksums verifies every part uploaded with UploadObjectPart as
+// VerifyChecksums does, and sends the checksums of parts whose body can be
+// read twice, because it is seekable or has GetBody, so that the server
+// rejects a part corrupted on the way rather than storing it. Such a body is
+// hashed before it is sent, unless Hashes are supplied, and its CRC32C and MD5
+// are sent in the x-goog-hash header and its MD5 in the Content-MD5 header.
+// WithHashAlgorithms selects which of them are computed. Other bodies are
+// hashed as they are sent and checked against the checksums the server
+// reports. A mismatch fails with a *ChecksumMismatchError. WithHashingDisabled
+// takes precedence.
+func WithPartChecksums() Option {
+	return func(mpuc *MultipartClient) {
+		mpuc.partChecksums = true
+	}
+}
+
+// WithMetrics reports request counts, latencies, uploaded bytes, retries and
+// in-flight parts to m.
+func WithMetrics(m Metrics) Option {
+	return func(mpuc *MultipartClient) {
+		mpuc.metrics = m
+	}
+}
+
+// WithLogger logs one record per request to logger, at the levels set in opts.
+// Credentials and encryption keys are redacted.
+func WithLogger(logger *slog.Logger, opts LogOptions) Option {
+	return func(mpuc *MultipartClient) {
+		mpuc.logger = logger
+		mpuc.logOpts = opts
+	}
+}
+
+// WithDebug writes every request and its response to w for troubleshooting,
+// e.g. interoperability with emulators, proxies and S3-compatible servers.
+// Bodies are truncated to maxBodyBytes, or omitted if it is zero, and
+// credentials and encryption keys are redacted. Headers added by the HTTP
+// client's transport, such as Authorization, are not visible to the dump.
+func WithDebug(w io.Writer, maxBodyBytes int) Option {
+	return func(mpuc *MultipartClient) {
+		mpuc.debug = &debugDumper{w: w, maxBodyBytes: maxBodyBytes}
+	}
+}
+
+// WithAuditHook calls hook with one AuditRecord per operation, after any
+// retries, e.g. to ship them to an audit log. hook is called synchronously
+// from the operation and must be safe for concurrent use.
+func WithAuditHook(hook func(AuditRecord)) Option {
+	return func(mpuc *MultipartClient) {
+		mpuc.auditHook = hook
+	}
+}
+
+// WithOnError calls f for every operation that fails, once any retries are
+// exhausted, e.g. to alert or start compensating actions from one place. op
+// is one of the Op constants and req is the operation's request, such as a
+// *CompleteMultipartUploadRequest. f is called synchronously from the
+// operation and must be safe for concurrent use.
+func WithOnError(f func(op string, req any, err error)) Option {
+	return func(mpuc *MultipartClient) {
+		mpuc.onError = f
+	}
+}
+
+// WithClock makes the client read the time from clock, for latencies,
+// timestamps and waits, instead of the system clock. clock must be safe for
+// concurrent use.
+func WithClock(clock Clock) Option {
+	return func(mpuc *MultipartClient) {
+		mpuc.clock = clock
+	}
+}
+
+// WithRandom makes the client draw random numbers, for log sampling and the
+// jitter of retry pauses, from random, which returns numbers in [0, 1) like
+// rand.Float64. With WithClock, it makes retry pauses exact. random must be
+// safe for concurrent use.
+func WithRandom(random func() float64) Option {
+	return func(mpuc *MultipartClient) {
+		mpuc.random = random
+	}
+}
@@ -0,0 +1,167 @@
+package multipartclient
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer and meter of the client.
+const instrumentationName = "github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+
+// Attributes of spans and measurements.
+const (
+	attrOperation  = attribute.Key("gcs.operation")
+	attrBucket     = attribute.Key("gcs.bucket")
+	attrKey        = attribute.Key("gcs.object")
+	attrUploadID   = attribute.Key("gcs.upload_id")
+	attrPartNumber = attribute.Key("gcs.part_number")
+	attrBytes      = attribute.Key("gcs.bytes_sent")
+	attrStatusCode = attribute.Key("http.response.status_code")
+)
+
+// WithTracerProvider traces every operation of the client, such as
+// UploadObjectPart, with a client span from a tracer of tp. The span is a
+// child of the span of the operation's context, if any, and is propagated to
+// the server as setTraceHeaders describes. It has the attributes
+// gcs.operation, gcs.bucket, gcs.object, gcs.upload_id, gcs.part_number and
+// gcs.bytes_sent where they apply, and http.response.status_code of the last
+// response, and records the error the operation returns.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(mpuc *MultipartClient) {
+		mpuc.tracer = tp.Tracer(instrumentationName)
+	}
+}
+
+// WithMeterProvider reports the client's Metrics with instruments of a meter
+// of mp:
+//
+//   - gcs.multipart.request.duration, a histogram of request latency in
+//     seconds by gcs.operation and http.response.status_code, which is left
+//     out if no response was received
+//   - gcs.multipart.uploaded_bytes, a counter of request body bytes sent by
+//     gcs.operation
+//   - gcs.multipart.retries, a counter of requests sent again by
+//     gcs.operation
+//   - gcs.multipart.parts_in_flight, an up-down counter of part uploads in
+//     progress
+//
+// It replaces the Metrics of WithMetrics, and is replaced by a later
+// WithMetrics. Errors creating the instruments are passed to otel.Handle.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(mpuc *MultipartClient) {
+		mpuc.metrics = newOTelMetrics(mp.Meter(instrumentationName))
+	}
+}
+
+// otelMetrics implements Metrics with OpenTelemetry instruments.
+type otelMetrics struct {
+	latency  metric.Float64Histogram
+	bytes    metric.Int64Counter
+	retries  metric.Int64Counter
+	inFlight metric.Int64UpDownCounter
+}
+
+func newOTelMetrics(meter metric.Meter) *otelMetrics {
+	m := &otelMetrics{}
+	var errs [4]error
+	m.latency, errs[0] = meter.Float64Histogram("gcs.multipart.request.duration",
+		metric.WithDescription("Latency of requests to the XML multipart API."),
+		metric.WithUnit("s"))
+	m.bytes, errs[1] = meter.Int64Counter("gcs.multipart.uploaded_bytes",
+		metric.WithDescription("Request body bytes sent."),
+		metric.WithUnit("By"))
+	m.retries, errs[2] = meter.Int64Counter("gcs.multipart.retries",
+		metric.WithDescription("Requests sent again after a failed attempt."),
+		metric.WithUnit("{request}"))
+	m.inFlight, errs[3] = meter.Int64UpDownCounter("gcs.multipart.parts_in_flight",
+		metric.WithDescription("Part uploads and copies in progress."),
+		metric.WithUnit("{part}"))
+	if err := errors.Join(errs[:]...); err != nil {
+		otel.Handle(err)
+	}
+	return m
+}
+
+func (m *otelMetrics) RequestDone(op string, statusCode int, latency time.Duration) {
+	attrs := []attribute.KeyValue{attrOperation.String(op)}
+	if statusCode != 0 {
+		attrs = append(attrs, attrStatusCode.Int(statusCode))
+	}
+	m.latency.Record(context.Background(), latency.Seconds(), metric.WithAttributes(attrs...))
+}
+
+func (m *otelMetrics) BytesUploaded(op string, n int64) {
+	m.bytes.Add(context.Background(), n, metric.WithAttributes(attrOperation.String(op)))
+}
+
+func (m *otelMetrics) RequestRetried(op string) {
+	m.retries.Add(context.Background(), 1, metric.WithAttributes(attrOperation.String(op)))
+}
+
+func (m *otelMetrics) PartsInFlight(delta int) {
+	m.inFlight.Add(context.Background(), int64(delta))
+}
+
+type operationSpanKey struct{}
+
+// startOperation starts the span of the operation op if the client has a
+// tracer, and returns ctx with it. operationDone ends it.
+func (mpuc *MultipartClient) startOperation(ctx context.Context, op string) context.Context {
+	if mpuc.tracer == nil {
+		return ctx
+	}
+	ctx, span := mpuc.tracer.Start(ctx, op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrOperation.String(op)))
+	return context.WithValue(ctx, operationSpanKey{}, span)
+}
+
+// operationSpan returns the span started by startOperation for the operation
+// of ctx, or nil if there is none.
+func operationSpan(ctx context.Context) trace.Span {
+	span, _ := ctx.Value(operationSpanKey{}).(trace.Span)
+	return span
+}
+
+// setSpanStatusCode records the status code of a response to a request of
+// the operation of ctx on its span, if any.
+func setSpanStatusCode(ctx context.Context, statusCode int) {
+	if span := operationSpan(ctx); span != nil && statusCode != 0 {
+		span.SetAttributes(attrStatusCode.Int(statusCode))
+	}
+}
+
+// endOperationSpan ends the span of the operation of ctx, if any, with the
+// attributes of info and the error the operation returns.
+func endOperationSpan(ctx context.Context, info operationInfo, err error) {
+	span := operationSpan(ctx)
+	if span == nil {
+		return
+	}
+	attrs := []attribute.KeyValue{attrBucket.String(info.Bucket)}
+	if info.Key != "" {
+		attrs = append(attrs, attrKey.String(info.Key))
+	}
+	if info.UploadID != "" {
+		attrs = append(attrs, attrUploadID.String(info.UploadID))
+	}
+	if info.PartNumber != 0 {
+		attrs = append(attrs, attrPartNumber.Int(info.PartNumber))
+	}
+	if info.Bytes != 0 {
+		attrs = append(attrs, attrBytes.Int64(info.Bytes))
+	}
+	span.SetAttributes(attrs...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,210 @@
+package multipartclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multiparttest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/embedded"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// spanAttrs returns the attributes of span as a map.
+func spanAttrs(span sdktrace.ReadOnlySpan) map[string]string {
+	attrs := map[string]string{}
+	for _, kv := range span.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	return attrs
+}
+
+func TestWithTracerProvider(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	mpuc := New(srv.Client(), WithTracerProvider(tp))
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	init, err := mpuc.InitiateMultipartUpload(ctx, &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mpuc.UploadObjectPart(ctx, &UploadObjectPartRequest{Bucket: "bucket1", Key: "object.txt", UploadID: init.UploadID, PartNumber: 2, Body: toBody("hello")}); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	spans := rec.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(spans))
+	}
+	for _, span := range spans[:2] {
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("got span %s with parent %v, want the span of the context", span.Name(), span.Parent().SpanID())
+		}
+		if span.SpanKind() != trace.SpanKindClient {
+			t.Errorf("got span %s of kind %v, want a client span", span.Name(), span.SpanKind())
+		}
+	}
+	want := map[string]string{
+		"gcs.operation":             OpUploadObjectPart,
+		"gcs.bucket":                "bucket1",
+		"gcs.object":                "object.txt",
+		"gcs.upload_id":             init.UploadID,
+		"gcs.part_number":           "2",
+		"gcs.bytes_sent":            "5",
+		"http.response.status_code": "200",
+	}
+	if spans[1].Name() != OpUploadObjectPart {
+		t.Errorf("got span %s, want %s", spans[1].Name(), OpUploadObjectPart)
+	}
+	if diff := cmp.Diff(want, spanAttrs(spans[1])); diff != "" {
+		t.Errorf("unexpected diff for the attributes of the part's span (-want, +got):\n%s", diff)
+	}
+	if got := spans[0].Status().Code; got != codes.Unset {
+		t.Errorf("got status %v of %s, want it unset", got, spans[0].Name())
+	}
+}
+
+func TestWithTracerProviderError(t *testing.T) {
+	hc := &http.Client{Transport: funcTransport(func(*http.Request) (*http.Response, error) {
+		resp := xmlResponse("<Error><Code>NoSuchUpload</Code></Error>")
+		resp.StatusCode, resp.Status = http.StatusNotFound, "Not Found"
+		return resp, nil
+	})}
+	rec := tracetest.NewSpanRecorder()
+	mpuc := New(hc, WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))))
+	err := mpuc.AbortMultipartUpload(context.Background(), &AbortMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt", UploadID: "u1"})
+	if err == nil {
+		t.Fatal("got no error, want one")
+	}
+	spans := rec.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.Status().Code != codes.Error || len(span.Events()) != 1 || span.Events()[0].Name != "exception" {
+		t.Errorf("got status %v and events %v, want the error recorded", span.Status(), span.Events())
+	}
+	if got := spanAttrs(span)["http.response.status_code"]; got != "404" {
+		t.Errorf("got status code %q, want 404", got)
+	}
+}
+
+// measurement is a value recorded by an instrument of a meterRecorder.
+type measurement struct {
+	Name  string
+	Value float64
+	Attrs string
+}
+
+// meterRecorder is a Meter recording the values of its instruments other
+// than those of the request duration, which vary.
+type meterRecorder struct {
+	noop.Meter
+
+	mu           sync.Mutex
+	measurements []measurement
+	durations    []string
+}
+
+// meterProvider provides the Meter m.
+type meterProvider struct {
+	embedded.MeterProvider
+	m metric.Meter
+}
+
+func (p meterProvider) Meter(string, ...metric.MeterOption) metric.Meter { return p.m }
+
+func (r *meterRecorder) record(name string, v float64, opts []metric.AddOption) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attrs := metric.NewAddConfig(opts).Attributes()
+	r.measurements = append(r.measurements, measurement{name, v, attrs.Encoded(attribute.DefaultEncoder())})
+}
+
+func (r *meterRecorder) Int64Counter(name string, _ ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	return int64Instrument{r: r, name: name}, nil
+}
+
+func (r *meterRecorder) Int64UpDownCounter(name string, _ ...metric.Int64UpDownCounterOption) (metric.Int64UpDownCounter, error) {
+	return int64Instrument{r: r, name: name}, nil
+}
+
+func (r *meterRecorder) Float64Histogram(name string, _ ...metric.Float64HistogramOption) (metric.Float64Histogram, error) {
+	return float64Histogram{r: r}, nil
+}
+
+type int64Instrument struct {
+	noop.Int64Counter
+	noop.Int64UpDownCounter
+	r    *meterRecorder
+	name string
+}
+
+func (i int64Instrument) Add(_ context.Context, v int64, opts ...metric.AddOption) {
+	i.r.record(i.name, float64(v), opts)
+}
+
+type float64Histogram struct {
+	noop.Float64Histogram
+	r *meterRecorder
+}
+
+func (h float64Histogram) Record(_ context.Context, _ float64, opts ...metric.RecordOption) {
+	h.r.mu.Lock()
+	defer h.r.mu.Unlock()
+	attrs := metric.NewRecordConfig(opts).Attributes()
+	h.r.durations = append(h.r.durations, attrs.Encoded(attribute.DefaultEncoder()))
+}
+
+func TestWithMeterProvider(t *testing.T) {
+	st := &statusTransport{statuses: []int{http.StatusServiceUnavailable}}
+	mp := &meterRecorder{}
+	mpuc := New(&http.Client{Transport: st}, WithRetry(RetryConfig{Backoff: testBackoff}), WithMeterProvider(meterProvider{m: mp}))
+	_, err := mpuc.UploadObjectPart(context.Background(), &UploadObjectPartRequest{Bucket: "bucket1", Key: "object.txt", UploadID: "u1", PartNumber: 1, Body: NewSectionBody(strings.NewReader("hello"), 0, 5)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := "gcs.operation=" + OpUploadObjectPart
+	wantMeasurements := []measurement{
+		{"gcs.multipart.parts_in_flight", 1, ""},
+		{"gcs.multipart.retries", 1, op},
+		// The part is counted each time it is sent.
+		{"gcs.multipart.uploaded_bytes", 10, op},
+		{"gcs.multipart.parts_in_flight", -1, ""},
+	}
+	if diff := cmp.Diff(wantMeasurements, mp.measurements); diff != "" {
+		t.Errorf("unexpected diff for the measurements (-want, +got):\n%s", diff)
+	}
+	wantDurations := []string{op + ",http.response.status_code=503", op + ",http.response.status_code=200"}
+	if diff := cmp.Diff(wantDurations, mp.durations); diff != "" {
+		t.Errorf("unexpected diff for the attributes of request durations (-want, +got):\n%s", diff)
+	}
+}
+
+func TestWithMeterProviderNoResponse(t *testing.T) {
+	hc := &http.Client{Transport: funcTransport(func(*http.Request) (*http.Response, error) {
+		return nil, errMock
+	})}
+	mp := &meterRecorder{}
+	mpuc := New(hc, WithMeterProvider(meterProvider{m: mp}))
+	err := mpuc.AbortMultipartUpload(context.Background(), &AbortMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt", UploadID: "u1"})
+	if !errors.Is(err, errMock) {
+		t.Fatalf("got error %v, want %v", err, errMock)
+	}
+	if diff := cmp.Diff([]string{"gcs.operation=" + OpAbortMultipartUpload}, mp.durations); diff != "" {
+		t.Errorf("unexpected diff for the attributes of request durations (-want, +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,68 @@
+package multipartclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// partScheduler runs the part transfers of an upload, such as uploads or
+// copies, at most concurrency at a time. The first that fails cancels the
+// others.
+type partScheduler struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	sem    chan struct{}
+	wg     sync.WaitGroup
+}
+
+// newPartScheduler returns a partScheduler whose transfers are made with a
+// context derived from ctx.
+func newPartScheduler(ctx context.Context, concurrency int) *partScheduler {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &partScheduler{ctx: ctx, cancel: cancel, sem: make(chan struct{}, concurrency)}
+}
+
+// acquire waits until another transfer may start, and returns the cause of
+// its context being done if it is first. The slot acquired is released by
+// the transfer passed to start, or by release.
+func (p *partScheduler) acquire() error {
+	select {
+	case p.sem <- struct{}{}:
+		return nil
+	case <-p.ctx.Done():
+		return context.Cause(p.ctx)
+	}
+}
+
+// release releases a slot acquired for a transfer that isn't started.
+func (p *partScheduler) release() {
+	<-p.sem
+}
+
+// start runs transfer on its own goroutine in the slot acquired for it. If
+// it fails, the scheduler's context is cancelled with err wrapped as the
+// failure of part partNumber, for the verb of the transfer.
+func (p *partScheduler) start(verb string, partNumber int, transfer func(ctx context.Context) error) {
+	p.wg.Add(1)
+	go func() {
+		defer func() {
+			p.release()
+			p.wg.Done()
+		}()
+		if err := transfer(p.ctx); err != nil {
+			p.cancel(fmt.Errorf("failed to %s part %d: %w", verb, partNumber, err))
+		}
+	}()
+}
+
+// wait waits for the transfers started and returns the failure of the first
+// that failed, or the cause of the context of the scheduler being done.
+func (p *partScheduler) wait() error {
+	p.wg.Wait()
+	defer p.cancel(nil)
+	if p.ctx.Err() != nil {
+		return context.Cause(p.ctx)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package multipartclient
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+)
+
+const (
+	contentSHA256Header = "x-goog-content-sha256"
+	// UnsignedPayload is sent in place of a payload hash when the body is not
+	// hashed, e.g. because it is streamed and can only be read once.
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// ContentSHA256Mode controls the x-goog-content-sha256 header.
+type ContentSHA256Mode int
+
+const (
+	// ContentSHA256Off omits the header. This is the default.
+	ContentSHA256Off ContentSHA256Mode = iota
+	// ContentSHA256Auto sends the hex SHA-256 of buffered and seekable bodies,
+	// which are hashed before sending and then rewound, and UnsignedPayload
+	// for streaming bodies. CompleteMultipartUpload bodies are encoded once to
+	// be hashed and again as they are sent.
+	ContentSHA256Auto
+	// ContentSHA256Unsigned sends UnsignedPayload for every request, avoiding
+	// an extra pass over part bodies.
+	ContentSHA256Unsigned
+)
+
+// emptySHA256 is the hex SHA-256 of an empty body.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// setPayloadHash sets the x-goog-content-sha256 header of req according to the
+// client's mode. body is the request body before any wrapping; if it is
+// hashed it is rewound to its original position afterwards.
+func (mpuc *MultipartClient) setPayloadHash(req *http.Request, body io.Reader) error {
+	var value string
+	switch mpuc.contentSHA256 {
+	case ContentSHA256Off:
+		return nil
+	case ContentSHA256Unsigned:
+		value = UnsignedPayload
+	case ContentSHA256Auto:
+		if mpuc.hashingDisabled && body != nil && body != http.NoBody {
+			value = UnsignedPayload
+			break
+		}
+		var err error
+		if value, err = payloadSHA256(body); err != nil {
+			return err
+		}
+	}
+	req.Header.Set(mpuc.header(contentSHA256Header), value)
+	return nil
+}
+
+// payloadSHA256 returns the hex SHA-256 of body, or UnsignedPayload if body
+// can't be read without consuming it.
+func payloadSHA256(body io.Reader) (string, error) {
+	if body == nil || body == http.NoBody {
+		return emptySHA256, nil
+	}
+	if xr, ok := body.(*xmlBodyReader); ok {
+		// Encode the document a second time rather than consume the reader.
+		h := sha256.New()
+		if _, err := xr.body.WriteTo(h); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+	seeker, ok := body.(io.ReadSeeker)
+	if !ok {
+		return UnsignedPayload, nil
+	}
+
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, seeker); err != nil {
+		return "", err
+	}
+	if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,129 @@
+package multipartclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestContentSHA256(t *testing.T) {
+	const contents = "part contents"
+	tests := []struct {
+		name string
+		mode ContentSHA256Mode
+		call func(mpuc *MultipartClient) error
+		want string
+	}{
+		{
+			name: "Off",
+			mode: ContentSHA256Off,
+			call: func(mpuc *MultipartClient) error {
+				_, err := mpuc.InitiateMultipartUpload(context.Background(), &InitiateMultipartUploadRequest{Bucket: "b", Key: "k"})
+				return err
+			},
+			want: "",
+		},
+		{
+			name: "Auto with an empty body",
+			mode: ContentSHA256Auto,
+			call: func(mpuc *MultipartClient) error {
+				return mpuc.AbortMultipartUpload(context.Background(), &AbortMultipartUploadRequest{Bucket: "b", Key: "k", UploadID: "u"})
+			},
+			want: sha256Hex(""),
+		},
+		{
+			name: "Auto with a seekable part body",
+			mode: ContentSHA256Auto,
+			call: func(mpuc *MultipartClient) error {
+				_, err := mpuc.UploadObjectPart(context.Background(), &UploadObjectPartRequest{
+					Bucket: "b", Key: "k", PartNumber: 1, UploadID: "u",
+					Body: &seekableBody{Reader: strings.NewReader(contents)},
+				})
+				return err
+			},
+			want: sha256Hex(contents),
+		},
+		{
+			name: "Auto with a verified seekable part body",
+			mode: ContentSHA256Auto,
+			call: func(mpuc *MultipartClient) error {
+				_, err := mpuc.UploadObjectPart(context.Background(), &UploadObjectPartRequest{
+					Bucket: "b", Key: "k", PartNumber: 1, UploadID: "u",
+					Body:            &seekableBody{Reader: strings.NewReader(contents)},
+					VerifyChecksums: true,
+				})
+				return err
+			},
+			want: sha256Hex(contents),
+		},
+		{
+			name: "Auto with a streaming part body",
+			mode: ContentSHA256Auto,
+			call: func(mpuc *MultipartClient) error {
+				_, err := mpuc.UploadObjectPart(context.Background(), &UploadObjectPartRequest{
+					Bucket: "b", Key: "k", PartNumber: 1, UploadID: "u",
+					Body: toBody(contents),
+				})
+				return err
+			},
+			want: UnsignedPayload,
+		},
+		{
+			name: "Auto with a complete body",
+			mode: ContentSHA256Auto,
+			call: func(mpuc *MultipartClient) error {
+				_, err := mpuc.CompleteMultipartUpload(context.Background(), &CompleteMultipartUploadRequest{
+					Bucket: "b", Key: "k", UploadID: "u",
+					Body: CompleteMultipartUploadBody{Parts: []CompletePart{{PartNumber: 1}}},
+				})
+				return err
+			},
+			want: sha256Hex("<CompleteMultipartUpload>\n" +
+				"  <Part>\n" +
+				"    <PartNumber>1</PartNumber>\n" +
+				"  </Part>\n" +
+				"</CompleteMultipartUpload>"),
+		},
+		{
+			name: "Unsigned with a seekable part body",
+			mode: ContentSHA256Unsigned,
+			call: func(mpuc *MultipartClient) error {
+				_, err := mpuc.UploadObjectPart(context.Background(), &UploadObjectPartRequest{
+					Bucket: "b", Key: "k", PartNumber: 1, UploadID: "u",
+					Body: &seekableBody{Reader: strings.NewReader(contents)},
+				})
+				return err
+			},
+			want: UnsignedPayload,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got, gotBody string
+			trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+				got = req.Header.Get("x-goog-content-sha256")
+				gotBody = httpReqToStr(t, req)
+				return &http.Response{StatusCode: http.StatusOK, Status: "OK", Body: toBody("")}, nil
+			})
+			mpuc := New(&http.Client{Transport: trans}, WithContentSHA256(tc.mode))
+			// Responses are empty, so decoding errors are expected.
+			_ = tc.call(mpuc)
+			if got != tc.want {
+				t.Errorf("got x-goog-content-sha256 %q, want %q", got, tc.want)
+			}
+			// Hashing must not consume the body.
+			if strings.Contains(tc.name, "part body") && !strings.HasSuffix(gotBody, contents) {
+				t.Errorf("request body was not sent in full: %q", gotBody)
+			}
+		})
+	}
+}
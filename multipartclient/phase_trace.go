@@ -0,0 +1,117 @@
+package multipartclient
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// PhaseTimings are the durations of the phases of one HTTP request, measured
+// with net/http/httptrace. Phases that did not happen, such as DNS, Connect
+// and TLS on a reused connection, are zero.
+type PhaseTimings struct {
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	// RequestWrite is the time from obtaining a connection until the request,
+	// including its body, was written.
+	RequestWrite time.Duration
+	// TimeToFirstByte is the time from the request being written until the
+	// first response byte arrived, i.e. mostly server time.
+	TimeToFirstByte time.Duration
+	// ReusedConn reports whether an idle connection was reused.
+	ReusedConn bool
+}
+
+// PhaseObserver can be implemented by a Metrics to also receive the phase
+// timings of every request, to tell network, server and client time apart.
+type PhaseObserver interface {
+	ObservePhases(op string, timings PhaseTimings)
+}
+
+// phaseTracer collects PhaseTimings for one request. httptrace hooks may run
+// on transport goroutines, so fields are guarded by mu.
+type phaseTracer struct {
+	clock              Clock
+	mu                 sync.Mutex
+	timings            PhaseTimings
+	dnsStart           time.Time
+	connectStart       time.Time
+	tlsStart           time.Time
+	gotConn            time.Time
+	wroteRequest       time.Time
+	gotFirstByte       time.Time
+	connectStartCalled bool
+}
+
+// withPhaseTrace returns ctx with a ClientTrace recording into a new tracer.
+func withPhaseTrace(ctx context.Context, clock Clock) (context.Context, *phaseTracer) {
+	pt := &phaseTracer{clock: clock}
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			pt.record(func() { pt.dnsStart = clock.Now() })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			pt.record(func() { pt.timings.DNS = pt.since(pt.dnsStart) })
+		},
+		ConnectStart: func(string, string) {
+			pt.record(func() {
+				// With multiple addresses only the first attempt's start counts.
+				if !pt.connectStartCalled {
+					pt.connectStart = clock.Now()
+					pt.connectStartCalled = true
+				}
+			})
+		},
+		ConnectDone: func(string, string, error) {
+			pt.record(func() { pt.timings.Connect = pt.since(pt.connectStart) })
+		},
+		TLSHandshakeStart: func() {
+			pt.record(func() { pt.tlsStart = clock.Now() })
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			pt.record(func() { pt.timings.TLS = pt.since(pt.tlsStart) })
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			pt.record(func() {
+				pt.gotConn = clock.Now()
+				pt.timings.ReusedConn = info.Reused
+			})
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			pt.record(func() {
+				pt.wroteRequest = clock.Now()
+				pt.timings.RequestWrite = pt.since(pt.gotConn)
+			})
+		},
+		GotFirstResponseByte: func() {
+			pt.record(func() {
+				pt.gotFirstByte = clock.Now()
+				pt.timings.TimeToFirstByte = pt.since(pt.wroteRequest)
+			})
+		},
+	}
+	return httptrace.WithClientTrace(ctx, trace), pt
+}
+
+func (pt *phaseTracer) record(f func()) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	f()
+}
+
+func (pt *phaseTracer) result() PhaseTimings {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return pt.timings
+}
+
+// since returns the time elapsed since t, or zero if t is unset.
+func (pt *phaseTracer) since(t time.Time) time.Duration {
+	if t.IsZero() {
+		return 0
+	}
+	return pt.clock.Now().Sub(t)
+}
@@ -0,0 +1,91 @@
+package multipartclient
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type phaseRecordingMetrics struct {
+	*recordingMetrics
+	mu      sync.Mutex
+	ops     []string
+	timings []PhaseTimings
+}
+
+func (m *phaseRecordingMetrics) ObservePhases(op string, timings PhaseTimings) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ops = append(m.ops, op)
+	m.timings = append(m.timings, timings)
+}
+
+// newTLSTestClient returns an http.Client that sends every request, whatever
+// its host, to a TLS server running handler.
+func newTLSTestClient(t *testing.T, handler http.Handler) *http.Client {
+	t.Helper()
+
+	srv := httptest.NewTLSServer(handler)
+	t.Cleanup(srv.Close)
+	tr := srv.Client().Transport.(*http.Transport).Clone()
+	tr.TLSClientConfig.ServerName = "example.com"
+	tr.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, srv.Listener.Addr().String())
+	}
+	return &http.Client{Transport: tr}
+}
+
+func TestPhaseTimings(t *testing.T) {
+	hc := newTLSTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"etag"`)
+	}))
+	metrics := &phaseRecordingMetrics{recordingMetrics: newRecordingMetrics()}
+	mpuc := New(hc, WithMetrics(metrics))
+
+	for i := 0; i < 2; i++ {
+		_, err := mpuc.UploadObjectPart(context.Background(), &UploadObjectPartRequest{
+			Bucket:     "bucket",
+			Key:        "key",
+			PartNumber: i + 1,
+			UploadID:   "upload-id",
+			Body:       toBody("contents"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(metrics.timings) != 2 {
+		t.Fatalf("got %d phase observations, want 2", len(metrics.timings))
+	}
+	for _, op := range metrics.ops {
+		if op != OpUploadObjectPart {
+			t.Errorf("got op %q, want %q", op, OpUploadObjectPart)
+		}
+	}
+
+	first := metrics.timings[0]
+	if first.ReusedConn {
+		t.Error("first request: got ReusedConn, want a new connection")
+	}
+	if first.Connect <= 0 || first.TLS <= 0 {
+		t.Errorf("first request: got Connect %v and TLS %v, want both positive", first.Connect, first.TLS)
+	}
+	for i, timings := range metrics.timings {
+		if timings.RequestWrite <= 0 || timings.TimeToFirstByte <= 0 {
+			t.Errorf("request %d: got RequestWrite %v and TimeToFirstByte %v, want both positive", i, timings.RequestWrite, timings.TimeToFirstByte)
+		}
+	}
+
+	second := metrics.timings[1]
+	if !second.ReusedConn {
+		t.Error("second request: got a new connection, want ReusedConn")
+	}
+	if second.Connect != 0 || second.TLS != 0 {
+		t.Errorf("second request: got Connect %v and TLS %v, want both zero", second.Connect, second.TLS)
+	}
+}
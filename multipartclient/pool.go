@@ -0,0 +1,227 @@
+package multipartclient
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxPooledBufferBytes bounds the buffers returned to the pools below, so a
+// single unusually long request isn't kept for the life of the process.
+const maxPooledBufferBytes = 64 << 10
+
+// xmlEncoder is an XML encoder that writes to w, which is set for
+// each document encoded.
+type xmlEncoder struct {
+	w io.Writer
+	// started is set once the current document has been written to.
+	started bool
+	enc     *xml.Encoder
+}
+
+func (e *xmlEncoder) Write(p []byte) (int, error) {
+	n := len(p)
+	if !e.started && n > 0 {
+		e.started = true
+		// An indenting encoder starts every document after its first on a
+		// new line.
+		p = bytes.TrimPrefix(p, []byte("\n"))
+	}
+	if _, err := e.w.Write(p); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// xmlEncoderPools hold indenting and, for compact documents, non-indenting
+// encoders.
+var xmlEncoderPools = [2]sync.Pool{
+	{New: func() any { return newXMLEncoder("  ") }},
+	{New: func() any { return newXMLEncoder("") }},
+}
+
+func newXMLEncoder(indent string) *xmlEncoder {
+	e := &xmlEncoder{}
+	e.enc = xml.NewEncoder(e)
+	e.enc.Indent("", indent)
+	return e
+}
+
+// encodeXML writes v to w encoded as the element start, indented unless
+// compact, using a pooled encoder.
+func encodeXML(w io.Writer, v any, start xml.StartElement, compact bool) error {
+	pool := &xmlEncoderPools[0]
+	if compact {
+		pool = &xmlEncoderPools[1]
+	}
+	e := pool.Get().(*xmlEncoder)
+	e.w, e.started = w, false
+	if err := e.enc.EncodeElement(v, start); err != nil {
+		// The encoder may be left inside an element, so it isn't reused.
+		return err
+	}
+	e.w = nil
+	pool.Put(e)
+	return nil
+}
+
+// xmlResponseBufferBytes is the size of the buffers XML responses are read
+// through, enough for a listing of a hundred uploads in one read.
+const xmlResponseBufferBytes = 16 << 10
+
+// xmlResponseReader reads an XML response body, limited to
+// maxXMLResponseBytes, through a buffer.
+type xmlResponseReader struct {
+	limited limitedReader
+	buf     *bufio.Reader
+}
+
+var xmlResponseReaderPool = sync.Pool{
+	New: func() any {
+		r := &xmlResponseReader{}
+		r.buf = bufio.NewReaderSize(&r.limited, xmlResponseBufferBytes)
+		return r
+	},
+}
+
+// getXMLResponseReader returns a pooled reader of body. It must be returned
+// with putXMLResponseReader once the body is decoded.
+func getXMLResponseReader(body io.Reader) *xmlResponseReader {
+	r := xmlResponseReaderPool.Get().(*xmlResponseReader)
+	r.limited = limitedReader{r: body, n: maxXMLResponseBytes}
+	return r
+}
+
+// putXMLResponseReader drops r's reference to its body, and anything left in
+// its buffer, and returns it to the pool.
+func putXMLResponseReader(r *xmlResponseReader) {
+	r.limited = limitedReader{}
+	r.buf.Reset(&r.limited)
+	xmlResponseReaderPool.Put(r)
+}
+
+// urlBufferPool recycles the buffers request URLs are built in.
+var urlBufferPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 256)
+		return &b
+	},
+}
+
+// urlTemplate holds the parts of request URLs around the bucket name, built
+// once for the endpoint it's keyed on: a request URL is prefix, the bucket,
+// suffix, "/", the key and the query.
+type urlTemplate struct {
+	endpoint      string
+	virtualHosted bool
+	prefix        string
+	suffix        string
+}
+
+// urlTemplate returns the template of the client's request URLs, building it
+// again only if the endpoint has changed since it was last built.
+func (mpuc *MultipartClient) urlTemplate() *urlTemplate {
+	endpoint := mpuc.endpointURL()
+	virtualHosted := mpuc.compat != nil && mpuc.compat.URLStyle == VirtualHostedStyle
+	if t := mpuc.urls.Load(); t != nil && t.endpoint == endpoint && t.virtualHosted == virtualHosted {
+		return t
+	}
+	t := &urlTemplate{endpoint: endpoint, virtualHosted: virtualHosted, prefix: endpoint + "/"}
+	if virtualHosted {
+		scheme, host, _ := strings.Cut(endpoint, "://")
+		t.prefix, t.suffix = scheme+"://", "."+host
+	}
+	mpuc.urls.Store(t)
+	return t
+}
+
+// appendRequestURL appends the URL of key in bucket, without a query, to b.
+// Each segment of the key is escaped as url.PathEscape escapes it, so keys
+// with characters such as '?', '#' or '%' name the object rather than a query
+// or fragment.
+func (mpuc *MultipartClient) appendRequestURL(b []byte, bucket, key string) []byte {
+	t := mpuc.urlTemplate()
+	b = append(b, t.prefix...)
+	b = append(b, bucket...)
+	b = append(b, t.suffix...)
+	b = append(b, '/')
+	return appendPathEscaped(b, key)
+}
+
+// appendPathEscaped appends key to b with each segment escaped like
+// url.PathEscape, keeping the '/' between segments.
+func appendPathEscaped(b []byte, key string) []byte {
+	const hex = "0123456789ABCDEF"
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~',
+			c == '$', c == '&', c == '+', c == ':', c == '=', c == '@', c == '/':
+			b = append(b, c)
+		default:
+			b = append(b, '%', hex[c>>4], hex[c&0xf])
+		}
+	}
+	return b
+}
+
+// appendQueryEscaped appends s to b escaped like url.QueryEscape.
+func appendQueryEscaped(b []byte, s string) []byte {
+	const hex = "0123456789ABCDEF"
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b = append(b, c)
+		case c == ' ':
+			b = append(b, '+')
+		default:
+			b = append(b, '%', hex[c>>4], hex[c&0xf])
+		}
+	}
+	return b
+}
+
+// urlString returns the URL built in b and returns bp, which b was appended
+// to, to urlBufferPool.
+func urlString(bp *[]byte, b []byte) string {
+	u := string(b)
+	if cap(b) <= maxPooledBufferBytes {
+		*bp = b
+		urlBufferPool.Put(bp)
+	}
+	return u
+}
+
+// partURL returns the URL of requests for part partNumber of an upload of key
+// in bucket. It allocates only the returned string.
+func (mpuc *MultipartClient) partURL(bucket, key string, partNumber int, uploadID string) string {
+	bp := urlBufferPool.Get().(*[]byte)
+	b := mpuc.appendRequestURL((*bp)[:0], bucket, key)
+	b = append(b, "?partNumber="...)
+	b = strconv.AppendInt(b, int64(partNumber), 10)
+	b = append(b, "&uploadId="...)
+	b = appendQueryEscaped(b, uploadID)
+	return urlString(bp, b)
+}
+
+// headerKeys holds the canonical forms of the headers passed to header, for
+// Cloud Storage and for S3-compatible servers, so setting and reading them
+// doesn't canonicalize them again on every request.
+var headerKeys = func() map[string][2]string {
+	keys := map[string][2]string{}
+	for _, name := range []string{contentSHA256Header, "x-goog-copy-source", "x-goog-copy-source-range", "x-goog-storage-class"} {
+		keys[name] = [2]string{
+			http.CanonicalHeaderKey(name),
+			http.CanonicalHeaderKey("x-amz-" + strings.TrimPrefix(name, "x-goog-")),
+		}
+	}
+	return keys
+}()
@@ -0,0 +1,280 @@
+package multipartclient
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multiparttest"
+)
+
+// discardTransport reads and discards request bodies and responds with an
+// empty 200.
+var discardTransport = funcTransport(func(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		if _, err := io.Copy(io.Discard, req.Body); err != nil {
+			return nil, err
+		}
+	}
+	return &http.Response{StatusCode: http.StatusOK, Status: "OK", Header: http.Header{}, Body: http.NoBody}, nil
+})
+
+func TestEncodeXMLReusesEncoders(t *testing.T) {
+	body := completeBody(2)
+	start := xml.StartElement{Name: xml.Name{Local: "CompleteMultipartUpload"}}
+	want, err := xml.MarshalIndent(body, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Encode enough times that pooled encoders are reused.
+	for i := 0; i < 3; i++ {
+		var got strings.Builder
+		if err := encodeXML(&got, body, start, false); err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != string(want) {
+			t.Errorf("encoding %d: got %s, want %s", i, got.String(), want)
+		}
+	}
+}
+
+func TestRequestURL(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{
+			name: "Cloud Storage",
+			want: "https://storage.googleapis.com/bucket1/dir/object.txt?partNumber=3&uploadId=my-upload-id",
+		},
+		{
+			name: "Path style",
+			opts: []Option{WithS3Compatibility(S3Compatibility{Endpoint: "http://localhost:9000/"})},
+			want: "http://localhost:9000/bucket1/dir/object.txt?partNumber=3&uploadId=my-upload-id",
+		},
+		{
+			name: "Virtual hosted style",
+			opts: []Option{WithS3Compatibility(S3Compatibility{Endpoint: "https://s3.example.com", URLStyle: VirtualHostedStyle})},
+			want: "https://bucket1.s3.example.com/dir/object.txt?partNumber=3&uploadId=my-upload-id",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mpuc := New(nil, tc.opts...)
+			for i := 0; i < 2; i++ {
+				if got := mpuc.partURL("bucket1", "dir/object.txt", 3, "my-upload-id"); got != tc.want {
+					t.Errorf("got %s, want %s", got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func completeBody(parts int) CompleteMultipartUploadBody {
+	var body CompleteMultipartUploadBody
+	for i := 1; i <= parts; i++ {
+		body.Parts = append(body.Parts, CompletePart{PartNumber: i, ETag: fmt.Sprintf(`"%032x"`, i)})
+	}
+	return body
+}
+
+// BenchmarkUploadObjectPartRequest measures the allocations of building and
+// sending a small part, which dominate at high request rates.
+func BenchmarkUploadObjectPartRequest(b *testing.B) {
+	mpuc := New(&http.Client{Transport: discardTransport})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, err := mpuc.UploadObjectPart(context.Background(), &UploadObjectPartRequest{
+			Bucket:     "bucket",
+			Key:        "dir/object",
+			PartNumber: 1 + i%10000,
+			UploadID:   "my-upload-id",
+			Body:       io.NopCloser(strings.NewReader("hello world")),
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCompleteMultipartUpload(b *testing.B) {
+	for _, parts := range []int{10, 1000, 10000} {
+		b.Run(fmt.Sprintf("%d parts", parts), func(b *testing.B) {
+			mpuc := New(&http.Client{Transport: discardTransport})
+			req := &CompleteMultipartUploadRequest{Bucket: "bucket", Key: "object", UploadID: "my-upload-id", Body: completeBody(parts)}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := mpuc.CompleteMultipartUpload(context.Background(), req); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestRequestURLEndpointChanged(t *testing.T) {
+	mpuc := New(nil)
+	if got, want := mpuc.requestURL("bucket1", "", "uploads"), "https://storage.googleapis.com/bucket1/?uploads"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	mpuc.endpoint = "http://localhost:8080"
+	if got, want := mpuc.requestURL("bucket1", "", "uploads"), "http://localhost:8080/bucket1/?uploads"; got != want {
+		t.Errorf("got %s after changing the endpoint, want %s", got, want)
+	}
+}
+
+func TestRequestURLEscapesKey(t *testing.T) {
+	mpuc := New(nil)
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "a?b.txt", want: "https://storage.googleapis.com/bucket1/a%3Fb.txt?partNumber=1&uploadId=u1"},
+		{key: "c#d.txt", want: "https://storage.googleapis.com/bucket1/c%23d.txt?partNumber=1&uploadId=u1"},
+		{key: "100% done.txt", want: "https://storage.googleapis.com/bucket1/100%25%20done.txt?partNumber=1&uploadId=u1"},
+		{key: "dir/with space/x+y,z.txt", want: "https://storage.googleapis.com/bucket1/dir/with%20space/x+y%2Cz.txt?partNumber=1&uploadId=u1"},
+	}
+	for _, tc := range tests {
+		if got := mpuc.partURL("bucket1", tc.key, 1, "u1"); got != tc.want {
+			t.Errorf("got %s for key %q, want %s", got, tc.key, tc.want)
+		}
+	}
+	// Every segment is escaped as url.PathEscape escapes it.
+	for c := 0; c < 256; c++ {
+		key := string([]byte{'a', byte(c), 'b'})
+		if got, want := string(appendPathEscaped(nil, key)), url.PathEscape(key); got != want && c != '/' {
+			t.Errorf("got %s for key %q, want %s", got, key, want)
+		}
+	}
+}
+
+func TestRequestURLEscapesUploadID(t *testing.T) {
+	// Upload IDs of S3-compatible servers may hold any of these.
+	const uploadID = "a+b&c=d/e f"
+	var got []string
+	hc := &http.Client{Transport: funcTransport(func(req *http.Request) (*http.Response, error) {
+		if len(req.URL.Query()["uploadId"]) != 1 || len(req.URL.Query()) > 2 {
+			t.Errorf("got query %q of %s, want the upload ID alone", req.URL.RawQuery, req.Method)
+		}
+		got = append(got, req.URL.Query().Get("uploadId"))
+		resp := xmlResponse("<ListPartsResult></ListPartsResult>")
+		if req.Method == http.MethodPost {
+			resp = xmlResponse("<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+		}
+		resp.Header.Set("ETag", `"etag"`)
+		return resp, nil
+	})}
+	mpuc := New(hc)
+	ctx := context.Background()
+	if _, err := mpuc.UploadObjectPart(ctx, &UploadObjectPartRequest{Bucket: "bucket1", Key: "object.txt", UploadID: uploadID, PartNumber: 1, Body: toBody("hello")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mpuc.ListObjectParts(ctx, &ListObjectPartsRequest{Bucket: "bucket1", Key: "object.txt", UploadID: uploadID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mpuc.CompleteMultipartUpload(ctx, &CompleteMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt", UploadID: uploadID, Body: CompleteMultipartUploadBody{Parts: []CompletePart{{PartNumber: 1, ETag: `"etag"`}}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mpuc.AbortMultipartUpload(ctx, &AbortMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt", UploadID: uploadID}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{uploadID, uploadID, uploadID, uploadID}, got); diff != "" {
+		t.Errorf("unexpected diff for the upload IDs received (-want, +got):\n%s", diff)
+	}
+	// Upload IDs are escaped as url.QueryEscape escapes them.
+	for c := 0; c < 256; c++ {
+		id := string([]byte{'a', byte(c), 'b'})
+		if got, want := string(appendQueryEscaped(nil, id)), url.QueryEscape(id); got != want {
+			t.Errorf("got %s for upload ID %q, want %s", got, id, want)
+		}
+	}
+}
+
+func TestUploadKeysWithSpecialCharacters(t *testing.T) {
+	for _, key := range []string{"a?b.txt", "c#d.txt", "100% done.txt", "dir/with space.txt"} {
+		t.Run(key, func(t *testing.T) {
+			srv := multiparttest.NewServer(t)
+			mpuc := New(srv.Client())
+			ctx := context.Background()
+			init, err := mpuc.InitiateMultipartUpload(ctx, &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: key})
+			if err != nil {
+				t.Fatal(err)
+			}
+			part, err := mpuc.UploadObjectPart(ctx, &UploadObjectPartRequest{Bucket: "bucket1", Key: key, UploadID: init.UploadID, PartNumber: 1, Body: toBody("hello")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = mpuc.CompleteMultipartUpload(ctx, &CompleteMultipartUploadRequest{Bucket: "bucket1", Key: key, UploadID: init.UploadID, Body: CompleteMultipartUploadBody{Parts: []CompletePart{{PartNumber: 1, ETag: part.ETag}}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, ok := srv.Object("bucket1", key); !ok || string(got) != "hello" {
+				t.Errorf("got object %q (found: %t), want %q", got, ok, "hello")
+			}
+			if _, err := mpuc.StatObject(ctx, ObjectRef{Bucket: "bucket1", Key: key}); err != nil {
+				t.Errorf("StatObject: %v", err)
+			}
+		})
+	}
+}
+
+// BenchmarkRequestURL measures building the URL of a part, which allocates
+// only the URL itself.
+func BenchmarkRequestURL(b *testing.B) {
+	mpuc := New(nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = mpuc.partURL("bucket", "dir/object", 1+i%10000, "my-upload-id")
+	}
+}
+
+// listUploadsBody returns a ListMultipartUploadsResult document of n uploads.
+func listUploadsBody(n int) string {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8"?><ListMultipartUploadsResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Bucket>bucket</Bucket>`)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, "<Upload><Key>dir/object-%d</Key><UploadId>upload-id-%d</UploadId><Initiated>2024-01-01T00:00:00.000Z</Initiated></Upload>", i, i)
+	}
+	sb.WriteString("</ListMultipartUploadsResult>")
+	return sb.String()
+}
+
+func TestDecodeXMLResponseReusesBuffers(t *testing.T) {
+	body := listUploadsBody(1000)
+	for i := 0; i < 3; i++ {
+		result := &ListMultipartUploadsResult{}
+		if err := decodeXMLResponse(xmlResponse(body), result); err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Uploads) != 1000 || result.Uploads[999].UploadID != "upload-id-999" {
+			t.Fatalf("decode %d: got %d uploads, want 1000", i, len(result.Uploads))
+		}
+	}
+}
+
+// BenchmarkDecodeXMLResponse measures decoding listings, as a janitor sweeping
+// stale uploads does thousands of times a minute. Reading through a pooled
+// buffer halves the memory allocated for short listings; longer ones are
+// dominated by the decoder's allocations per element.
+func BenchmarkDecodeXMLResponse(b *testing.B) {
+	for _, uploads := range []int{1, 100, 1000} {
+		b.Run(fmt.Sprintf("%d uploads", uploads), func(b *testing.B) {
+			body := listUploadsBody(uploads)
+			b.SetBytes(int64(len(body)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}
+				if err := decodeXMLResponse(resp, &ListMultipartUploadsResult{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
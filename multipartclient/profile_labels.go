@@ -0,0 +1,15 @@
+package multipartclient
+
+import "runtime/pprof"
+
+// UploadLabels returns the profiler labels carried by the goroutines that
+// upload the parts of an upload: "bucket" and "upload_id". UploadFS and
+// mpublob set them, so that CPU and goroutine profiles of a busy service can
+// be broken down by transfer, for example with go tool pprof -tagfocus. Pass
+// them to pprof.Do to label goroutines of your own that upload parts; requests
+// sent with the context it passes carry the labels too.
+//
+// Heap profiles don't record labels.
+func UploadLabels(bucket, uploadID string) pprof.LabelSet {
+	return pprof.Labels("bucket", bucket, "upload_id", uploadID)
+}
@@ -0,0 +1,90 @@
+package multipartclient
+
+import (
+	"io"
+	"sync"
+)
+
+// ProgressFunc is called as the data of a part is sent, with the bytes
+// transferred so far out of totalBytes, or -1 if the total is unknown, and the
+// number of the part being sent. When a part is sent again, after a redirect,
+// a retry or a checksum mismatch, its bytes are counted again from zero, so
+// bytesTransferred can go down.
+type ProgressFunc func(bytesTransferred, totalBytes int64, partNumber int)
+
+// progressReader reports the bytes read from r to f.
+type progressReader struct {
+	r          io.Reader
+	n          int64
+	total      int64
+	partNumber int
+	f          ProgressFunc
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	if n > 0 {
+		pr.n += int64(n)
+		pr.f(pr.n, pr.total, pr.partNumber)
+	}
+	return n, err
+}
+
+// Close closes r if it is an io.Closer, so the HTTP client still closes
+// request bodies it is given.
+func (pr *progressReader) Close() error {
+	if c, ok := pr.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// withProgress returns body and getBody reporting the bytes read from every
+// copy of body to f, counting each copy from zero.
+func withProgress(body io.Reader, getBody func() (io.ReadCloser, error), total int64, partNumber int, f ProgressFunc) (io.Reader, func() (io.ReadCloser, error)) {
+	body = &progressReader{r: body, total: total, partNumber: partNumber, f: f}
+	if getBody == nil {
+		return body, nil
+	}
+	return body, func() (io.ReadCloser, error) {
+		r, err := getBody()
+		if err != nil {
+			return nil, err
+		}
+		// The previous copy may still be read by the transport, so each copy
+		// has its own count.
+		return &progressReader{r: r, total: total, partNumber: partNumber, f: f}, nil
+	}
+}
+
+// objectProgress adds up the progress of the parts of an object for the
+// ProgressFunc of an Uploader, calling it with one part's progress at a time.
+type objectProgress struct {
+	f     ProgressFunc
+	total int64
+
+	mu    sync.Mutex
+	sent  int64
+	parts map[int]int64
+}
+
+func newObjectProgress(f ProgressFunc, total int64) *objectProgress {
+	if f == nil {
+		return nil
+	}
+	return &objectProgress{f: f, total: total, parts: make(map[int]int64)}
+}
+
+// part returns the ProgressFunc of the part partNumber, or nil if op is nil.
+func (op *objectProgress) part() ProgressFunc {
+	if op == nil {
+		return nil
+	}
+	return func(n, _ int64, partNumber int) {
+		op.mu.Lock()
+		defer op.mu.Unlock()
+		op.sent += n - op.parts[partNumber]
+		op.parts[partNumber] = n
+		op.f(op.sent, op.total, partNumber)
+	}
+}
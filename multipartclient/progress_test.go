@@ -0,0 +1,169 @@
+package multipartclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multiparttest"
+)
+
+// progressCall is a call of a ProgressFunc.
+type progressCall struct {
+	Transferred, Total int64
+	PartNumber         int
+}
+
+// progressRecorder records the calls of its ProgressFunc.
+type progressRecorder struct {
+	mu    sync.Mutex
+	calls []progressCall
+}
+
+func (r *progressRecorder) progress(transferred, total int64, partNumber int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, progressCall{transferred, total, partNumber})
+}
+
+func (r *progressRecorder) last() progressCall {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.calls) == 0 {
+		return progressCall{}
+	}
+	return r.calls[len(r.calls)-1]
+}
+
+func TestUploadObjectPartProgress(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "part")
+	if err := os.WriteFile(name, []byte("part data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		body func(t *testing.T) io.Reader
+		want progressCall
+	}{
+		{
+			name: "Sized",
+			body: func(*testing.T) io.Reader { return NewSectionBody(strings.NewReader("part data"), 0, 9) },
+			want: progressCall{9, 9, 3},
+		},
+		{
+			name: "Streaming",
+			body: func(*testing.T) io.Reader { return io.NopCloser(strings.NewReader("part data")) },
+			want: progressCall{9, -1, 3},
+		},
+		{
+			name: "File",
+			body: func(t *testing.T) io.Reader {
+				b, err := OpenFileBody(name, 0, 9)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return b
+			},
+			want: progressCall{9, 9, 3},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := multiparttest.NewServer(t)
+			mpuc := New(srv.Client())
+			ctx := context.Background()
+			init, err := mpuc.InitiateMultipartUpload(ctx, &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			rec := &progressRecorder{}
+			_, err = mpuc.UploadObjectPart(ctx, &UploadObjectPartRequest{
+				Bucket:     "bucket1",
+				Key:        "object.txt",
+				PartNumber: 3,
+				UploadID:   init.UploadID,
+				Body:       tc.body(t),
+				Progress:   rec.progress,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, rec.last()); diff != "" {
+				t.Errorf("unexpected diff for the last progress (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUploadObjectPartProgressResend(t *testing.T) {
+	st := &statusTransport{statuses: []int{http.StatusServiceUnavailable}}
+	mpuc := New(&http.Client{Transport: st}, WithRetry(RetryConfig{Backoff: testBackoff}))
+	rec := &progressRecorder{}
+	_, err := mpuc.UploadObjectPart(context.Background(), &UploadObjectPartRequest{
+		Bucket:     "bucket1",
+		Key:        "object.txt",
+		PartNumber: 1,
+		UploadID:   "u1",
+		Body:       NewSectionBody(strings.NewReader("part data"), 0, 9),
+		Progress:   rec.progress,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each copy is counted from zero.
+	sentInFull := 0
+	for _, c := range rec.calls {
+		if c.Transferred == 9 {
+			sentInFull++
+		}
+		if c.Total != 9 || c.PartNumber != 1 {
+			t.Errorf("got call %+v, want a total of 9 for part 1", c)
+		}
+	}
+	if sentInFull != 2 {
+		t.Errorf("got calls %+v, want the part sent in full twice", rec.calls)
+	}
+}
+
+func TestUploaderProgress(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), (2*MinPartSize+MinPartSize/2)/16)
+	for _, tc := range []struct {
+		name      string
+		r         func() io.Reader
+		wantTotal int64
+	}{
+		{name: "In place", r: func() io.Reader { return bytes.NewReader(data) }, wantTotal: int64(len(data))},
+		{name: "Streaming", r: func() io.Reader { return io.MultiReader(bytes.NewReader(data)) }, wantTotal: -1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := multiparttest.NewServer(t)
+			rec := &progressRecorder{}
+			u := NewUploader(New(srv.Client()), &UploaderOptions{PartSize: MinPartSize, Progress: rec.progress})
+			if _, err := u.Upload(context.Background(), &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt"}, tc.r()); err != nil {
+				t.Fatal(err)
+			}
+			var prev int64
+			parts := map[int]bool{}
+			for _, c := range rec.calls {
+				if c.Transferred < prev || c.Total != tc.wantTotal {
+					t.Fatalf("got call %+v after %d bytes, want increasing progress of %d bytes", c, prev, tc.wantTotal)
+				}
+				prev = c.Transferred
+				parts[c.PartNumber] = true
+			}
+			if prev != int64(len(data)) {
+				t.Errorf("got %d bytes sent in the end, want %d", prev, len(data))
+			}
+			if len(parts) != 3 {
+				t.Errorf("got progress of parts %v, want 3 parts", parts)
+			}
+		})
+	}
+}
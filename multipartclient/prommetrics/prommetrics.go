@@ -0,0 +1,160 @@
+// Package prommetrics reports multipartclient metrics to Prometheus.
+package prommetrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics implements multipartclient.Metrics with Prometheus collectors:
+//
+//   - <namespace>_requests_total{operation,status}
+//   - <namespace>_request_duration_seconds{operation,status}
+//   - <namespace>_uploaded_bytes_total{operation}
+//   - <namespace>_retries_total{operation}
+//   - <namespace>_parts_in_flight
+//   - <namespace>_request_phase_seconds{operation,phase}
+//   - <namespace>_part_duration_seconds{upload,result}
+//   - <namespace>_part_bytes_total{upload}
+//   - <namespace>_part_attempts_total{upload}
+//
+// status is the HTTP status code, or "error" if no response was received.
+// phase is one of dns, connect, tls, request_write and time_to_first_byte;
+// phases that did not happen for a request are not observed. upload is the
+// label from multipartclient.ContextWithUploadLabel and result is "success"
+// or "error". Per-upload throughput is the rate of part_bytes_total, and the
+// retry ratio is part_attempts_total over the part_duration_seconds count.
+type Metrics struct {
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	bytes    *prometheus.CounterVec
+	retries  *prometheus.CounterVec
+	inFlight prometheus.Gauge
+	phases   *prometheus.HistogramVec
+
+	partLatency  *prometheus.HistogramVec
+	partBytes    *prometheus.CounterVec
+	partAttempts *prometheus.CounterVec
+}
+
+// DefaultNamespace prefixes the metric names unless another is given to New.
+const DefaultNamespace = "gcs_multipart"
+
+// New creates the collectors under namespace, or DefaultNamespace if empty,
+// and registers them with reg.
+func New(reg prometheus.Registerer, namespace string) (*Metrics, error) {
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "Requests sent to the XML multipart API by operation and status.",
+		}, []string{"operation", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "Latency of requests to the XML multipart API by operation and status.",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 2, 16),
+		}, []string{"operation", "status"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "uploaded_bytes_total",
+			Help:      "Request body bytes sent by operation.",
+		}, []string{"operation"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "retries_total",
+			Help:      "Requests sent again after a failed attempt, by operation.",
+		}, []string{"operation"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "parts_in_flight",
+			Help:      "Part uploads and copies in progress.",
+		}),
+		phases: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_phase_seconds",
+			Help:      "Duration of the phases of requests to the XML multipart API by operation and phase.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 18),
+		}, []string{"operation", "phase"}),
+		partLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "part_duration_seconds",
+			Help:      "Duration of part uploads, including retries, by upload label and result.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 16),
+		}, []string{"upload", "result"}),
+		partBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "part_bytes_total",
+			Help:      "Part bytes sent, including retries, by upload label.",
+		}, []string{"upload"}),
+		partAttempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "part_attempts_total",
+			Help:      "Requests sent for part uploads by upload label.",
+		}, []string{"upload"}),
+	}
+	collectors := []prometheus.Collector{
+		m.requests, m.latency, m.bytes, m.retries, m.inFlight, m.phases,
+		m.partLatency, m.partBytes, m.partAttempts,
+	}
+	for _, c := range collectors {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *Metrics) RequestDone(op string, statusCode int, latency time.Duration) {
+	status := "error"
+	if statusCode != 0 {
+		status = strconv.Itoa(statusCode)
+	}
+	m.requests.WithLabelValues(op, status).Inc()
+	m.latency.WithLabelValues(op, status).Observe(latency.Seconds())
+}
+
+func (m *Metrics) BytesUploaded(op string, n int64) {
+	m.bytes.WithLabelValues(op).Add(float64(n))
+}
+
+func (m *Metrics) RequestRetried(op string) {
+	m.retries.WithLabelValues(op).Inc()
+}
+
+func (m *Metrics) PartsInFlight(delta int) {
+	m.inFlight.Add(float64(delta))
+}
+
+func (m *Metrics) ObservePhases(op string, t multipartclient.PhaseTimings) {
+	for _, p := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"dns", t.DNS},
+		{"connect", t.Connect},
+		{"tls", t.TLS},
+		{"request_write", t.RequestWrite},
+		{"time_to_first_byte", t.TimeToFirstByte},
+	} {
+		if p.d > 0 {
+			m.phases.WithLabelValues(op, p.name).Observe(p.d.Seconds())
+		}
+	}
+}
+
+func (m *Metrics) PartDone(label string, stats multipartclient.PartStats) {
+	result := "success"
+	if stats.Failed {
+		result = "error"
+	}
+	m.partLatency.WithLabelValues(label, result).Observe(stats.Duration.Seconds())
+	m.partBytes.WithLabelValues(label).Add(float64(stats.Bytes))
+	m.partAttempts.WithLabelValues(label).Add(float64(stats.Attempts))
+}
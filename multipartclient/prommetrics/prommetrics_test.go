@@ -0,0 +1,87 @@
+package prommetrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var (
+	_ multipartclient.Metrics       = (*Metrics)(nil)
+	_ multipartclient.PhaseObserver = (*Metrics)(nil)
+	_ multipartclient.UploadMetrics = (*Metrics)(nil)
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	m, err := New(reg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.RequestDone(multipartclient.OpUploadObjectPart, 200, 10*time.Millisecond)
+	m.RequestDone(multipartclient.OpUploadObjectPart, 200, 20*time.Millisecond)
+	m.RequestDone(multipartclient.OpUploadObjectPart, 0, time.Second)
+	m.BytesUploaded(multipartclient.OpUploadObjectPart, 1024)
+	m.RequestRetried(multipartclient.OpUploadObjectPart)
+	m.PartsInFlight(2)
+	m.PartsInFlight(-1)
+	m.ObservePhases(multipartclient.OpUploadObjectPart, multipartclient.PhaseTimings{
+		RequestWrite:    5 * time.Millisecond,
+		TimeToFirstByte: 50 * time.Millisecond,
+		ReusedConn:      true,
+	})
+	m.PartDone("nightly", multipartclient.PartStats{Bytes: 2048, Duration: time.Second, Attempts: 2})
+	m.PartDone("nightly", multipartclient.PartStats{Bytes: 1024, Duration: time.Second, Attempts: 1, Failed: true})
+
+	want := `
+# HELP gcs_multipart_part_attempts_total Requests sent for part uploads by upload label.
+# TYPE gcs_multipart_part_attempts_total counter
+gcs_multipart_part_attempts_total{upload="nightly"} 3
+# HELP gcs_multipart_part_bytes_total Part bytes sent, including retries, by upload label.
+# TYPE gcs_multipart_part_bytes_total counter
+gcs_multipart_part_bytes_total{upload="nightly"} 3072
+# HELP gcs_multipart_parts_in_flight Part uploads and copies in progress.
+# TYPE gcs_multipart_parts_in_flight gauge
+gcs_multipart_parts_in_flight 1
+# HELP gcs_multipart_requests_total Requests sent to the XML multipart API by operation and status.
+# TYPE gcs_multipart_requests_total counter
+gcs_multipart_requests_total{operation="UploadObjectPart",status="200"} 2
+gcs_multipart_requests_total{operation="UploadObjectPart",status="error"} 1
+# HELP gcs_multipart_retries_total Requests sent again after a failed attempt, by operation.
+# TYPE gcs_multipart_retries_total counter
+gcs_multipart_retries_total{operation="UploadObjectPart"} 1
+# HELP gcs_multipart_uploaded_bytes_total Request body bytes sent by operation.
+# TYPE gcs_multipart_uploaded_bytes_total counter
+gcs_multipart_uploaded_bytes_total{operation="UploadObjectPart"} 1024
+`
+	err = testutil.GatherAndCompare(reg, strings.NewReader(want),
+		"gcs_multipart_part_attempts_total",
+		"gcs_multipart_part_bytes_total",
+		"gcs_multipart_parts_in_flight",
+		"gcs_multipart_requests_total",
+		"gcs_multipart_retries_total",
+		"gcs_multipart_uploaded_bytes_total")
+	if err != nil {
+		t.Error(err)
+	}
+	if got := testutil.CollectAndCount(m.latency); got != 2 {
+		t.Errorf("got %d latency series, want 2", got)
+	}
+	// Only the phases that happened on the reused connection are observed.
+	if got := testutil.CollectAndCount(m.phases); got != 2 {
+		t.Errorf("got %d phase series, want 2", got)
+	}
+	if got := testutil.CollectAndCount(m.partLatency); got != 2 {
+		t.Errorf("got %d part latency series, want 2", got)
+	}
+
+	// Registering twice with the same namespace conflicts.
+	if _, err := New(reg, ""); err == nil {
+		t.Error("New() with an already registered namespace: expected error")
+	}
+}
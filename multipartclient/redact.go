@@ -0,0 +1,64 @@
+package multipartclient
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const redacted = "REDACTED"
+
+// sensitiveHeaders hold credentials or key material and are never logged.
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Goog-Encryption-Key",
+	"X-Goog-Copy-Source-Encryption-Key",
+	"X-Amz-Server-Side-Encryption-Customer-Key",
+	"X-Amz-Security-Token",
+}
+
+// sensitiveQueryParams carry request signatures and are never logged.
+var sensitiveQueryParams = []string{
+	"X-Goog-Signature",
+	"X-Amz-Signature",
+	"Signature",
+}
+
+// redactHeader returns a copy of h with the values of sensitive headers
+// replaced.
+func redactHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range sensitiveHeaders {
+		if _, ok := out[name]; ok {
+			out[name] = []string{redacted}
+		}
+	}
+	return out
+}
+
+// redactURL returns u as a string with the values of sensitive query
+// parameters replaced.
+func redactURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.String()
+	}
+	query := u.Query()
+	changed := false
+	for name := range query {
+		for _, sensitive := range sensitiveQueryParams {
+			if strings.EqualFold(name, sensitive) {
+				query[name] = []string{redacted}
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return u.String()
+	}
+	redactedURL := *u
+	redactedURL.RawQuery = query.Encode()
+	return redactedURL.String()
+}
@@ -0,0 +1,53 @@
+package multipartclient
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRedactHeader(t *testing.T) {
+	h := http.Header{
+		"Authorization":         []string{"Bearer token"},
+		"X-Goog-Encryption-Key": []string{"key"},
+		"Content-Type":          []string{"application/xml"},
+	}
+	want := http.Header{
+		"Authorization":         []string{"REDACTED"},
+		"X-Goog-Encryption-Key": []string{"REDACTED"},
+		"Content-Type":          []string{"application/xml"},
+	}
+	if diff := cmp.Diff(want, redactHeader(h)); diff != "" {
+		t.Errorf("unexpected diff for redacted header: (-want, +got):\n%s", diff)
+	}
+	if h.Get("Authorization") != "Bearer token" {
+		t.Error("redactHeader modified its input")
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{
+			in:   "https://storage.googleapis.com/b/o?uploadId=abc",
+			want: "https://storage.googleapis.com/b/o?uploadId=abc",
+		},
+		{
+			in:   "https://storage.googleapis.com/b/o?X-Goog-Signature=deadbeef&uploadId=abc",
+			want: "https://storage.googleapis.com/b/o?X-Goog-Signature=REDACTED&uploadId=abc",
+		},
+	}
+	for _, tc := range tests {
+		u, err := url.Parse(tc.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := redactURL(u); got != tc.want {
+			t.Errorf("redactURL(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
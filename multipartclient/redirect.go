@@ -0,0 +1,91 @@
+package multipartclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+)
+
+// maxRedirects bounds the redirects followed by one request, as the HTTP
+// client's default policy does.
+const maxRedirects = 10
+
+// WithRedirectHosts lets requests follow 307 and 308 redirects to hosts, such
+// as "storage-eu.example.com" or "localhost:4443", as well as to the host
+// they were sent to. Such redirects come from emulators, proxies and, during
+// migrations, the XML API itself. The request is sent again as it was, with
+// its body, to the Location of the redirect; a part body can be sent again if
+// it is seekable. Redirects to other hosts, and from HTTPS to HTTP, fail with
+// an error naming their target.
+func WithRedirectHosts(hosts ...string) Option {
+	return func(mpuc *MultipartClient) {
+		mpuc.redirectHosts = append(mpuc.redirectHosts, hosts...)
+	}
+}
+
+// httpClient returns the client that sends requests, which leaves 307 and 308
+// redirects to doChecked so that they follow WithRedirectHosts and part
+// bodies are sent again. Other redirects follow the policy of the client
+// given to New.
+func (mpuc *MultipartClient) httpClient() *http.Client {
+	hc := *mpuc.hc
+	next := hc.CheckRedirect
+	hc.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if isRedirect(req.Response) {
+			return http.ErrUseLastResponse
+		}
+		if next != nil {
+			return next(req, via)
+		}
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		return nil
+	}
+	return &hc
+}
+
+// isRedirect reports whether resp is a redirect that doChecked follows.
+func isRedirect(resp *http.Response) bool {
+	return resp != nil && (resp.StatusCode == http.StatusTemporaryRedirect || resp.StatusCode == http.StatusPermanentRedirect)
+}
+
+// redirect prepares httpReq, which got the redirect resp, to be sent to the
+// redirect's Location. resp is closed.
+func (mpuc *MultipartClient) redirect(httpReq *http.Request, resp *http.Response) error {
+	loc := resp.Header.Get("Location")
+	if loc == "" {
+		return errors.New("redirect without a Location")
+	}
+	target, err := httpReq.URL.Parse(loc)
+	if err != nil {
+		return fmt.Errorf("invalid redirect Location %q: %w", loc, err)
+	}
+	if err := mpuc.checkRedirectTarget(httpReq.URL, target); err != nil {
+		return err
+	}
+	if httpReq.GetBody == nil && httpReq.Body != nil && httpReq.Body != http.NoBody {
+		return fmt.Errorf("redirected to %s, but the body can't be sent again", target.Redacted())
+	}
+	if err := rewind(httpReq, resp); err != nil {
+		return err
+	}
+	httpReq.URL, httpReq.Host = target, target.Host
+	return nil
+}
+
+// checkRedirectTarget returns an error unless a request to from may be
+// redirected to target.
+func (mpuc *MultipartClient) checkRedirectTarget(from, target *url.URL) error {
+	switch {
+	case target.Scheme != "http" && target.Scheme != "https":
+		return fmt.Errorf("redirected to %s, which isn't an HTTP URL", target.Redacted())
+	case from.Scheme == "https" && target.Scheme == "http":
+		return fmt.Errorf("redirected from HTTPS to %s", target.Redacted())
+	case target.Host != from.Host && !slices.Contains(mpuc.redirectHosts, target.Host):
+		return fmt.Errorf("redirected to %s, whose host isn't allowed by WithRedirectHosts", target.Redacted())
+	}
+	return nil
+}
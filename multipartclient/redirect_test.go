@@ -0,0 +1,205 @@
+package multipartclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+)
+
+// redirectTransport redirects the requests to the URLs in locations, in
+// order, with status, then responds as the server would, with the hashes of
+// the request body. It records the URLs and bodies of the requests.
+type redirectTransport struct {
+	status    int
+	locations []string
+	urls      []string
+	bodies    []string
+}
+
+func (rt *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body.Close()
+	rt.urls = append(rt.urls, req.URL.String())
+	rt.bodies = append(rt.bodies, string(body))
+	if n := len(rt.urls); n <= len(rt.locations) {
+		return &http.Response{
+			StatusCode: rt.status,
+			Status:     http.StatusText(rt.status),
+			Header:     http.Header{"Location": {rt.locations[n-1]}},
+			Body:       http.NoBody,
+		}, nil
+	}
+	resp := xmlResponse("<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+	hasher := gcshash.NewHasher()
+	hasher.Write(body)
+	hasher.Sums().SetHeader(resp.Header)
+	resp.Header.Set("ETag", `"etag1"`)
+	return resp, nil
+}
+
+func TestRedirectPart(t *testing.T) {
+	const partURL = "https://storage.googleapis.com/bucket1/object.txt?partNumber=1&uploadId=u1"
+	tests := []struct {
+		name      string
+		opts      []Option
+		status    int
+		locations []string
+		body      func() io.ReadCloser
+		verify    bool
+		wantURLs  []string
+		wantErr   string
+	}{
+		{
+			name:      "Same host",
+			status:    http.StatusTemporaryRedirect,
+			locations: []string{"/bucket1/object.txt?partNumber=1&uploadId=u1&shard=2"},
+			body:      func() io.ReadCloser { return NewSectionBody(strings.NewReader("xxpart data"), 2, 9) },
+			wantURLs:  []string{partURL, partURL + "&shard=2"},
+		},
+		{
+			name:      "Verified",
+			status:    http.StatusPermanentRedirect,
+			locations: []string{"/bucket1/object.txt?partNumber=1&uploadId=u1&shard=2"},
+			body:      func() io.ReadCloser { return NewSectionBody(strings.NewReader("part data"), 0, 9) },
+			verify:    true,
+			wantURLs:  []string{partURL, partURL + "&shard=2"},
+		},
+		{
+			name:      "Allowed host",
+			opts:      []Option{WithRedirectHosts("storage-eu.example.com")},
+			status:    http.StatusTemporaryRedirect,
+			locations: []string{"https://storage-eu.example.com/bucket1/object.txt?partNumber=1&uploadId=u1"},
+			body:      func() io.ReadCloser { return NewSectionBody(strings.NewReader("part data"), 0, 9) },
+			wantURLs:  []string{partURL, "https://storage-eu.example.com/bucket1/object.txt?partNumber=1&uploadId=u1"},
+		},
+		{
+			name:      "Other host",
+			status:    http.StatusTemporaryRedirect,
+			locations: []string{"https://evil.example.com/bucket1/object.txt"},
+			body:      func() io.ReadCloser { return NewSectionBody(strings.NewReader("part data"), 0, 9) },
+			wantURLs:  []string{partURL},
+			wantErr:   "host isn't allowed",
+		},
+		{
+			name:      "To HTTP",
+			status:    http.StatusTemporaryRedirect,
+			locations: []string{"http://storage.googleapis.com/bucket1/object.txt"},
+			body:      func() io.ReadCloser { return NewSectionBody(strings.NewReader("part data"), 0, 9) },
+			wantURLs:  []string{partURL},
+			wantErr:   "from HTTPS",
+		},
+		{
+			name:      "Streaming body",
+			status:    http.StatusTemporaryRedirect,
+			locations: []string{"/bucket1/object.txt?partNumber=1&uploadId=u1&shard=2"},
+			body:      func() io.ReadCloser { return io.NopCloser(strings.NewReader("part data")) },
+			wantURLs:  []string{partURL},
+			wantErr:   "can't be sent again",
+		},
+		{
+			name:      "Too many redirects",
+			status:    http.StatusTemporaryRedirect,
+			locations: strings.Split(strings.Repeat(partURL+" ", maxRedirects+1), " "),
+			body:      func() io.ReadCloser { return NewSectionBody(strings.NewReader("part data"), 0, 9) },
+			wantURLs:  strings.Split(strings.TrimSpace(strings.Repeat(partURL+" ", maxRedirects+1)), " "),
+			wantErr:   "stopped after 10 redirects",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rt := &redirectTransport{status: tc.status, locations: tc.locations}
+			mpuc := New(&http.Client{Transport: rt}, tc.opts...)
+			result, err := mpuc.UploadObjectPart(context.Background(), &UploadObjectPartRequest{
+				Bucket:          "bucket1",
+				Key:             "object.txt",
+				PartNumber:      1,
+				UploadID:        "u1",
+				Body:            tc.body(),
+				VerifyChecksums: tc.verify,
+			})
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("got error %v, want one containing %q", err, tc.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if result.ETag != `"etag1"` {
+				t.Errorf("got ETag %q, want %q", result.ETag, `"etag1"`)
+			}
+			if diff := cmp.Diff(tc.wantURLs, rt.urls); diff != "" {
+				t.Errorf("unexpected diff for request URLs (-want, +got):\n%s", diff)
+			}
+			for i, body := range rt.bodies {
+				if body != "part data" {
+					t.Errorf("got body %q in request %d, want %q", body, i, "part data")
+				}
+			}
+			if got := mpuc.Stats().Retries; got != 0 {
+				t.Errorf("got %d retries in stats, want none for redirects", got)
+			}
+		})
+	}
+}
+
+func TestRedirectComplete(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		wantURLs []string
+		wantErr  bool
+	}{
+		{
+			name: "Allowed host",
+			opts: []Option{WithRedirectHosts("localhost:4443")},
+			wantURLs: []string{
+				"https://storage.googleapis.com/bucket1/object.txt?uploadId=u1",
+				"https://localhost:4443/bucket1/object.txt?uploadId=u1",
+			},
+		},
+		{
+			// The HTTP client would follow the redirect itself.
+			name:     "Other host",
+			wantURLs: []string{"https://storage.googleapis.com/bucket1/object.txt?uploadId=u1"},
+			wantErr:  true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rt := &redirectTransport{
+				status:    http.StatusPermanentRedirect,
+				locations: []string{"https://localhost:4443/bucket1/object.txt?uploadId=u1"},
+			}
+			mpuc := New(&http.Client{Transport: rt}, tc.opts...)
+			body := CompleteMultipartUploadBody{Parts: []CompletePart{{PartNumber: 1, ETag: `"e1"`}}}
+			_, err := mpuc.CompleteMultipartUpload(context.Background(), &CompleteMultipartUploadRequest{
+				Bucket:   "bucket1",
+				Key:      "object.txt",
+				UploadID: "u1",
+				Body:     body,
+			})
+			if (err != nil) != tc.wantErr {
+				t.Errorf("got error %v, want error: %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.wantURLs, rt.urls); diff != "" {
+				t.Errorf("unexpected diff for request URLs (-want, +got):\n%s", diff)
+			}
+			want, err := mpuc.MarshalCompleteBody(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, got := range rt.bodies {
+				if got != string(want) {
+					t.Errorf("got body %q in request %d, want %q", got, i, want)
+				}
+			}
+		})
+	}
+}
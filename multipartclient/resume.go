@@ -0,0 +1,98 @@
+package multipartclient
+
+import (
+	"context"
+	"encoding/hex"
+	"sort"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+)
+
+// PartRecord is what a client records about an uploaded part, e.g. in a
+// checkpoint, so that a later run can prove the server holds the same data.
+type PartRecord struct {
+	PartNumber int
+	// ETag is the ETag returned when the part was uploaded.
+	ETag string
+	// Hashes are the checksums of the local data for the part.
+	Hashes gcshash.Sums
+	// HashState is the serialized gcshash.Hasher of the data of a part that
+	// was only partly buffered, as recorded by UploadSession.SavePartHashState,
+	// so hashing can continue from where it stopped instead of re-reading the
+	// data buffered so far. Only the records of Checkpoint.Partial have it.
+	HashState []byte `json:",omitempty"`
+	// HashingDisabled records that the part was uploaded without client-side
+	// hashing (see UploadObjectPartResult.HashingDisabled), so downstream
+	// verification knows its integrity was not checked by the client.
+	HashingDisabled bool `json:",omitempty"`
+}
+
+// PartValidation is the result of ValidateUploadedParts.
+type PartValidation struct {
+	// Verified are the parts present on the server whose identity was proven,
+	// ready to be passed to CompleteMultipartUpload.
+	Verified []CompletePart
+	// Reupload are the numbers of the parts that must be uploaded again, in
+	// ascending order.
+	Reupload []int
+}
+
+// ValidateUploadedParts lists the parts of an upload and checks each record
+// against what the server holds, so an upload can be resumed without trusting
+// parts whose data may differ from the local data.
+//
+// A part is proven if the server's ETag equals the hex MD5 in its record's
+// Hashes, which is how Cloud Storage computes part ETags. Without an MD5, a
+// part is proven if the server's ETag equals the recorded ETag; this only
+// shows the stored part is unchanged, so the record's data must be known not
+// to have changed since it was uploaded. Parts that are missing on the server
+// or can't be proven are returned for re-upload.
+func (mpuc *MultipartClient) ValidateUploadedParts(ctx context.Context, req *ListObjectPartsRequest, records []PartRecord) (*PartValidation, error) {
+	parts, err := mpuc.ListAllObjectParts(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	serverETags := make(map[int]string, len(parts))
+	for _, part := range parts {
+		serverETags[part.PartNumber] = part.ETag
+	}
+
+	validation := &PartValidation{}
+	for _, record := range records {
+		serverETag, ok := serverETags[record.PartNumber]
+		if ok && partProven(record, serverETag) {
+			validation.Verified = append(validation.Verified, CompletePart{
+				PartNumber: record.PartNumber,
+				ETag:       serverETag,
+			})
+		} else {
+			validation.Reupload = append(validation.Reupload, record.PartNumber)
+		}
+	}
+	sort.Slice(validation.Verified, func(i, j int) bool {
+		return validation.Verified[i].PartNumber < validation.Verified[j].PartNumber
+	})
+	sort.Ints(validation.Reupload)
+	return validation, nil
+}
+
+func partProven(record PartRecord, serverETag string) bool {
+	if serverETag == "" {
+		return false
+	}
+	if record.Hashes.MD5 != nil {
+		return normalizeETag(serverETag) == hex.EncodeToString(record.Hashes.MD5)
+	}
+	return record.ETag != "" && normalizeETag(serverETag) == normalizeETag(record.ETag)
+}
+
+// PartRecordFromResult returns the record of a part uploaded with the given
+// result.
+func PartRecordFromResult(partNumber int, result *UploadObjectPartResult) PartRecord {
+	return PartRecord{
+		PartNumber:      partNumber,
+		ETag:            result.ETag,
+		Hashes:          result.ComputedHashes,
+		HashingDisabled: result.HashingDisabled,
+	}
+}
@@ -0,0 +1,58 @@
+package multipartclient
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+)
+
+func TestValidateUploadedParts(t *testing.T) {
+	md5One, md5Two, md5Changed := md5Of("one"), md5Of("two"), md5Of("changed")
+	listBody := "<ListPartsResult>\n" +
+		"  <Part><PartNumber>1</PartNumber><ETag>\"" + hex.EncodeToString(md5One) + "\"</ETag></Part>\n" +
+		"  <Part><PartNumber>2</PartNumber><ETag>\"" + hex.EncodeToString(md5Two) + "\"</ETag></Part>\n" +
+		"  <Part><PartNumber>3</PartNumber><ETag>\"etag-3\"</ETag></Part>\n" +
+		"  <Part><PartNumber>4</PartNumber><ETag>\"etag-4\"</ETag></Part>\n" +
+		"</ListPartsResult>"
+
+	records := []PartRecord{
+		// Local data matches the server.
+		{PartNumber: 1, Hashes: gcshash.Sums{MD5: md5One}},
+		// Local data changed since the part was uploaded.
+		{PartNumber: 2, ETag: "\"" + hex.EncodeToString(md5Two) + "\"", Hashes: gcshash.Sums{MD5: md5Changed}},
+		// No MD5, but the recorded ETag matches.
+		{PartNumber: 3, ETag: "\"etag-3\"", Hashes: gcshash.Sums{CRC32C: 7, HasCRC32C: true}},
+		// Nothing to prove identity with.
+		{PartNumber: 4},
+		// Missing on the server.
+		{PartNumber: 5, Hashes: gcshash.Sums{MD5: md5One}},
+	}
+
+	trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Status: "OK", Body: toBody(listBody)}, nil
+	})
+	mpuc := New(&http.Client{Transport: trans})
+	got, err := mpuc.ValidateUploadedParts(context.Background(), &ListObjectPartsRequest{
+		Bucket:   "bucket1",
+		Key:      "object.txt",
+		UploadID: "my-upload-id",
+	}, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &PartValidation{
+		Verified: []CompletePart{
+			{PartNumber: 1, ETag: "\"" + hex.EncodeToString(md5One) + "\""},
+			{PartNumber: 3, ETag: "\"etag-3\""},
+		},
+		Reupload: []int{2, 4, 5},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected diff for validation: (-want, +got):\n%s", diff)
+	}
+}
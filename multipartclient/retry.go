@@ -0,0 +1,239 @@
+package multipartclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/api/googleapi"
+)
+
+// RetryPolicy selects the operations that are retried. Its values are those
+// of storage.RetryPolicy, so one converts to the other.
+type RetryPolicy int
+
+const (
+	// RetryIdempotent retries every operation but InitiateMultipartUpload,
+	// which would start another upload, and CompleteMultipartUpload, which
+	// fails once the upload is completed.
+	RetryIdempotent RetryPolicy = iota
+	// RetryAlways retries every operation.
+	RetryAlways
+	// RetryNever retries no operation.
+	RetryNever
+)
+
+// RetryConfig configures WithRetry. Its fields have the shapes of the retry
+// options of cloud.google.com/go/storage, so that a policy can be defined
+// once for both clients:
+//
+//	backoff := gax.Backoff{Initial: time.Second, Max: time.Minute, Multiplier: 2}
+//	sc.Bucket(b).Object(o).Retryer(storage.WithBackoff(backoff), storage.WithMaxAttempts(5))
+//	mpuc := multipartclient.New(hc, multipartclient.WithRetry(multipartclient.RetryConfig{
+//		Backoff:     backoff,
+//		MaxAttempts: 5,
+//		ShouldRetry: storage.ShouldRetry,
+//	}))
+type RetryConfig struct {
+	// Backoff is the pause before each retry, as set by storage.WithBackoff.
+	// Pauses are jittered as gax.Backoff.Pause jitters them, with random
+	// numbers drawn as set by WithRandom.
+	Backoff gax.Backoff
+	// MaxAttempts bounds the number of times a request is sent, as set by
+	// storage.WithMaxAttempts. If zero, it is DefaultMaxAttempts.
+	MaxAttempts int
+	// Policy is the operations retried, as set by storage.WithPolicy.
+	Policy RetryPolicy
+	// ShouldRetry reports whether a failed request is retried, as set by
+	// storage.WithErrorFunc. Error responses are passed as *googleapi.Error,
+	// so storage.ShouldRetry can be used. Defaults to ShouldRetry.
+	ShouldRetry func(err error) bool
+}
+
+// DefaultMaxAttempts is the number of times a request is sent at most if
+// RetryConfig.MaxAttempts is zero.
+const DefaultMaxAttempts = 10
+
+// WithRetry sends requests again when they fail transiently, as configured by
+// cfg. Only requests whose body can be sent again are retried, which excludes
+// parts with streaming bodies. A response with a Retry-After header, as GCS
+// sends with some 429 and 503 responses, lengthens the pause before the retry
+// to the time it asks for, up to a minute. Without WithRetry no request is
+// retried.
+func WithRetry(cfg RetryConfig) Option {
+	return func(mpuc *MultipartClient) {
+		if cfg.ShouldRetry == nil {
+			cfg.ShouldRetry = ShouldRetry
+		}
+		if cfg.MaxAttempts == 0 {
+			cfg.MaxAttempts = DefaultMaxAttempts
+		}
+		mpuc.retry = &cfg
+	}
+}
+
+// backoff is a gax.Backoff whose pauses are jittered with random numbers of
+// the client.
+type backoff struct {
+	cur, max   time.Duration
+	multiplier float64
+	random     func() float64
+}
+
+// newBackoff returns the backoff of bo, with its defaults applied as
+// gax.Backoff.Pause applies them, drawing jitter from random.
+func newBackoff(bo gax.Backoff, random func() float64) *backoff {
+	b := &backoff{cur: bo.Initial, max: bo.Max, multiplier: bo.Multiplier, random: random}
+	if b.cur == 0 {
+		b.cur = time.Second
+	}
+	if b.max == 0 {
+		b.max = 30 * time.Second
+	}
+	if b.multiplier < 1 {
+		b.multiplier = 2
+	}
+	return b
+}
+
+// pause returns the pause before the next retry, between 1ns and the current
+// maximum, and raises the maximum by the multiplier up to Max.
+func (b *backoff) pause() time.Duration {
+	d := 1 + time.Duration(b.random()*float64(b.cur))
+	b.cur = min(time.Duration(float64(b.cur)*b.multiplier), b.max)
+	return d
+}
+
+// ShouldRetry reports whether err, from a request or as a *googleapi.Error for
+// an error response, is transient: a 408, 429 or 5xx response, an
+// *IncompleteResponseError, or a network error that storage.ShouldRetry also
+// retries, such as a timeout, a connection refused or reset, or a response
+// cut short. Other errors, such as those of certificates that can't be
+// verified or of malformed URLs, and the request's context being done, are
+// permanent.
+func ShouldRetry(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == http.StatusRequestTimeout || apiErr.Code == http.StatusTooManyRequests || apiErr.Code >= 500
+	}
+	var incomplete *IncompleteResponseError
+	if errors.As(err, &incomplete) {
+		return true
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	// Like storage.ShouldRetry, match the messages of errors that don't wrap
+	// the error numbers, such as those of HTTP/2 connections.
+	msg := err.Error()
+	for _, s := range transientErrorMessages {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// transientErrorMessages are parts of the messages of transient network
+// errors.
+var transientErrorMessages = []string{
+	"connection refused",
+	"connection reset",
+	"broken pipe",
+	"http2: client connection lost",
+}
+
+// idempotentOps are the operations retried under RetryIdempotent.
+var idempotentOps = map[string]bool{
+	OpUploadObjectPart:     true,
+	OpUploadPartCopy:       true,
+	OpAbortMultipartUpload: true,
+	OpListMultipartUploads: true,
+	OpListObjectParts:      true,
+	OpHealthCheck:          true,
+	OpStatObject:           true,
+	OpPatchObjectMetadata:  true,
+}
+
+// retryable reports whether a request for op that was sent attempts times
+// and got resp and err may be sent again.
+func (mpuc *MultipartClient) retryable(op string, httpReq *http.Request, attempts int, resp *http.Response, err error) bool {
+	cfg := mpuc.retry
+	switch {
+	case cfg == nil || err == nil:
+		return false
+	case cfg.Policy == RetryNever, cfg.Policy == RetryIdempotent && !idempotentOps[op]:
+		return false
+	case cfg.MaxAttempts > 0 && attempts >= cfg.MaxAttempts:
+		return false
+	case httpReq.GetBody == nil && httpReq.Body != nil && httpReq.Body != http.NoBody:
+		return false
+	}
+	var incomplete *IncompleteResponseError
+	switch {
+	case resp == nil:
+	case resp.StatusCode < 300:
+		// Of the successful responses that failed a check, only those cut
+		// short may be different when sent again.
+		if !errors.As(err, &incomplete) {
+			return false
+		}
+	default:
+		err = &googleapi.Error{Code: resp.StatusCode, Header: resp.Header}
+	}
+	return cfg.ShouldRetry(err)
+}
+
+// maxRetryAfter bounds the pause a Retry-After header can ask for, so that a
+// bad header doesn't stall requests.
+const maxRetryAfter = time.Minute
+
+// retryAfter returns the pause resp asks for with its Retry-After header, in
+// seconds or as an HTTP date, or zero if it has none.
+func (mpuc *MultipartClient) retryAfter(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	var d time.Duration
+	if secs, err := strconv.Atoi(v); err == nil {
+		d = time.Duration(min(secs, int(maxRetryAfter/time.Second))) * time.Second
+	} else if t, err := http.ParseTime(v); err == nil {
+		d = t.Sub(mpuc.clock.Now())
+	}
+	return min(max(d, 0), maxRetryAfter)
+}
+
+// rewind prepares httpReq to be sent again after resp, which is closed.
+func rewind(httpReq *http.Request, resp *http.Response) error {
+	if resp != nil {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBodyBytes))
+		resp.Body.Close()
+	}
+	if httpReq.GetBody == nil {
+		return nil
+	}
+	body, err := httpReq.GetBody()
+	if err != nil {
+		return err
+	}
+	httpReq.Body = body
+	return nil
+}
@@ -0,0 +1,415 @@
+package multipartclient
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/api/googleapi"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multipartclienttest"
+)
+
+// testBackoff keeps retries in tests fast.
+var testBackoff = gax.Backoff{Initial: time.Millisecond, Max: time.Millisecond}
+
+// statusTransport responds with the statuses in order, then with 200s, and
+// records the request bodies.
+type statusTransport struct {
+	statuses []int
+	bodies   []string
+}
+
+func (st *statusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+	}
+	st.bodies = append(st.bodies, string(body))
+	status := http.StatusOK
+	if n := len(st.bodies); n <= len(st.statuses) {
+		status = st.statuses[n-1]
+	}
+	if status != http.StatusOK {
+		return &http.Response{StatusCode: status, Status: http.StatusText(status), Body: toBody("error")}, nil
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: toBody(`<ListMultipartUploadsResult></ListMultipartUploadsResult>`)}, nil
+}
+
+func TestWithRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         []Option
+		statuses     []int
+		wantRequests int
+		wantErr      bool
+	}{
+		{
+			name:         "No retry by default",
+			statuses:     []int{http.StatusServiceUnavailable},
+			wantRequests: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "Transient failures",
+			opts:         []Option{WithRetry(RetryConfig{Backoff: testBackoff})},
+			statuses:     []int{http.StatusServiceUnavailable, http.StatusTooManyRequests},
+			wantRequests: 3,
+		},
+		{
+			name:         "Max attempts",
+			opts:         []Option{WithRetry(RetryConfig{Backoff: testBackoff, MaxAttempts: 2})},
+			statuses:     []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError},
+			wantRequests: 2,
+			wantErr:      true,
+		},
+		{
+			name:         "Permanent failure",
+			opts:         []Option{WithRetry(RetryConfig{Backoff: testBackoff})},
+			statuses:     []int{http.StatusForbidden},
+			wantRequests: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "Never",
+			opts:         []Option{WithRetry(RetryConfig{Backoff: testBackoff, Policy: RetryNever})},
+			statuses:     []int{http.StatusServiceUnavailable},
+			wantRequests: 1,
+			wantErr:      true,
+		},
+		{
+			name: "Storage shapes",
+			opts: []Option{WithRetry(RetryConfig{
+				Backoff:     testBackoff,
+				Policy:      RetryPolicy(storage.RetryAlways),
+				ShouldRetry: storage.ShouldRetry,
+			})},
+			statuses:     []int{http.StatusBadGateway},
+			wantRequests: 2,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			st := &statusTransport{statuses: tc.statuses}
+			mpuc := New(&http.Client{Transport: st}, tc.opts...)
+			_, err := mpuc.ListMultipartUploads(context.Background(), &ListMultipartUploadsRequest{Bucket: "bucket1"})
+			if (err != nil) != tc.wantErr {
+				t.Errorf("got error %v, want error: %v", err, tc.wantErr)
+			}
+			if len(st.bodies) != tc.wantRequests {
+				t.Errorf("got %d requests, want %d", len(st.bodies), tc.wantRequests)
+			}
+			if got, want := mpuc.Stats().Retries, uint64(tc.wantRequests-1); got != want {
+				t.Errorf("got %d retries in stats, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestWithRetryPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy       RetryPolicy
+		wantRequests int
+	}{
+		{policy: RetryIdempotent, wantRequests: 1},
+		{policy: RetryAlways, wantRequests: 2},
+	} {
+		st := &statusTransport{statuses: []int{http.StatusServiceUnavailable}}
+		mpuc := New(&http.Client{Transport: st}, WithRetry(RetryConfig{Backoff: testBackoff, Policy: tc.policy}))
+		// The response doesn't decode, but only the number of requests
+		// matters.
+		_, _ = mpuc.InitiateMultipartUpload(context.Background(), &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt"})
+		if len(st.bodies) != tc.wantRequests {
+			t.Errorf("got %d InitiateMultipartUpload requests with policy %d, want %d", len(st.bodies), tc.policy, tc.wantRequests)
+		}
+	}
+}
+
+func TestWithRetryResendsBody(t *testing.T) {
+	st := &statusTransport{statuses: []int{http.StatusServiceUnavailable}}
+	mpuc := New(&http.Client{Transport: st}, WithRetry(RetryConfig{Backoff: testBackoff}))
+	contentType := "text/plain"
+	// The response isn't an object resource, but only the requests matter.
+	_, _ = mpuc.PatchObjectMetadata(context.Background(), &PatchObjectMetadataRequest{
+		Bucket: "bucket1",
+		Key:    "object.txt",
+		Patch:  ObjectMetadataPatch{ContentType: &contentType},
+	})
+	want := `{"contentType":"text/plain"}`
+	if len(st.bodies) != 2 || st.bodies[0] != want || st.bodies[1] != want {
+		t.Errorf("got request bodies %q, want %q twice", st.bodies, want)
+	}
+}
+
+func TestWithRetryResendsPartBody(t *testing.T) {
+	getBody := func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader("part data")), nil }
+	for _, tc := range []struct {
+		name         string
+		body         io.Reader
+		getBody      func() (io.ReadCloser, error)
+		verify       bool
+		wantRequests int
+	}{
+		{name: "Seekable", body: NewSectionBody(strings.NewReader("part data"), 0, 9), wantRequests: 2},
+		{name: "Streaming", body: io.NopCloser(strings.NewReader("part data")), wantRequests: 1},
+		{name: "GetBody", body: strings.NewReader("part data"), getBody: getBody, wantRequests: 2},
+		{name: "GetBody verified", body: io.MultiReader(strings.NewReader("part data")), getBody: getBody, verify: true, wantRequests: 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			st := &statusTransport{statuses: []int{http.StatusServiceUnavailable}}
+			mpuc := New(&http.Client{Transport: st}, WithRetry(RetryConfig{Backoff: testBackoff}))
+			_, _ = mpuc.UploadObjectPart(context.Background(), &UploadObjectPartRequest{
+				Bucket:          "bucket1",
+				Key:             "object.txt",
+				PartNumber:      1,
+				UploadID:        "u1",
+				Body:            tc.body,
+				GetBody:         tc.getBody,
+				VerifyChecksums: tc.verify,
+			})
+			if len(st.bodies) != tc.wantRequests {
+				t.Fatalf("got %d requests, want %d", len(st.bodies), tc.wantRequests)
+			}
+			for i, body := range st.bodies {
+				if body != "part data" {
+					t.Errorf("got body %q in request %d, want %q", body, i, "part data")
+				}
+			}
+		})
+	}
+}
+
+func TestWithRetryCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	requests := 0
+	trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+		requests++
+		cancel()
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable", Body: toBody("")}, nil
+	})
+	mpuc := New(&http.Client{Transport: trans}, WithRetry(RetryConfig{Backoff: gax.Backoff{Initial: time.Hour, Max: time.Hour}}))
+	_, err := mpuc.ListMultipartUploads(ctx, &ListMultipartUploadsRequest{Bucket: "bucket1"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	if requests != 1 {
+		t.Errorf("got %d requests, want 1", requests)
+	}
+}
+
+// pauseClock records the pauses waited for, without waiting.
+type pauseClock struct {
+	systemClock
+	now    time.Time
+	pauses []time.Duration
+}
+
+func (c *pauseClock) Now() time.Time { return c.now }
+
+func (c *pauseClock) After(d time.Duration) <-chan time.Time {
+	c.pauses = append(c.pauses, d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func TestWithRetryRetryAfter(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		retryAfter string
+		// want is the pause, or zero for the jittered pause of the backoff.
+		want time.Duration
+	}{
+		{name: "None"},
+		{name: "Seconds", retryAfter: "3", want: 3 * time.Second},
+		{name: "Date", retryAfter: now.Add(5 * time.Second).Format(http.TimeFormat), want: 5 * time.Second},
+		{name: "Past date", retryAfter: now.Add(-time.Hour).Format(http.TimeFormat)},
+		{name: "Too long", retryAfter: "86400", want: maxRetryAfter},
+		{name: "Invalid", retryAfter: "soon"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := 0
+			trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+				requests++
+				if requests == 1 {
+					resp := &http.Response{StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests", Header: http.Header{}, Body: toBody("slow down")}
+					if tc.retryAfter != "" {
+						resp.Header.Set("Retry-After", tc.retryAfter)
+					}
+					return resp, nil
+				}
+				return xmlResponse("<ListMultipartUploadsResult></ListMultipartUploadsResult>"), nil
+			})
+			clock := &pauseClock{now: now}
+			mpuc := New(&http.Client{Transport: trans}, WithClock(clock), WithRetry(RetryConfig{Backoff: testBackoff}))
+			if _, err := mpuc.ListMultipartUploads(context.Background(), &ListMultipartUploadsRequest{Bucket: "bucket1"}); err != nil {
+				t.Fatal(err)
+			}
+			if len(clock.pauses) != 1 {
+				t.Fatalf("got pauses %v, want 1", clock.pauses)
+			}
+			if got := clock.pauses[0]; tc.want == 0 && got > testBackoff.Max || tc.want != 0 && got != tc.want {
+				t.Errorf("got pause %v, want %v", got, cmp.Or(tc.want, testBackoff.Max))
+			}
+		})
+	}
+}
+
+func TestWithRetryRandomJitter(t *testing.T) {
+	clock := multipartclienttest.NewFakeClock(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC))
+	st := &statusTransport{statuses: []int{503, 503, 503}}
+	mpuc := New(&http.Client{Transport: st}, WithClock(clock), WithRandom(func() float64 { return 0.5 }), WithRetry(RetryConfig{
+		Backoff: gax.Backoff{Initial: time.Second, Max: 3 * time.Second, Multiplier: 2},
+	}))
+	errc := make(chan error, 1)
+	go func() {
+		_, err := mpuc.ListMultipartUploads(context.Background(), &ListMultipartUploadsRequest{Bucket: "bucket1"})
+		errc <- err
+	}()
+
+	// Half of the backoff, which doubles up to Max, plus the nanosecond that
+	// gax.Backoff.Pause adds.
+	for _, want := range []time.Duration{500*time.Millisecond + 1, time.Second + 1, 1500*time.Millisecond + 1} {
+		for clock.Waiters() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		clock.Advance(want - 1)
+		if clock.Waiters() != 1 {
+			t.Fatalf("retried before the pause of %v", want)
+		}
+		clock.Advance(1)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if len(st.bodies) != 4 {
+		t.Errorf("got %d requests, want 4", len(st.bodies))
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{err: &googleapi.Error{Code: http.StatusServiceUnavailable}, want: true},
+		{err: &googleapi.Error{Code: http.StatusTooManyRequests}, want: true},
+		{err: &googleapi.Error{Code: http.StatusRequestTimeout}, want: true},
+		{err: &googleapi.Error{Code: http.StatusNotFound}, want: false},
+		{err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: true},
+		{err: &url.Error{Op: "Put", URL: "https://storage.googleapis.com/b/o", Err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}}, want: true},
+		{err: &url.Error{Op: "Put", URL: "https://storage.googleapis.com/b/o", Err: io.ErrUnexpectedEOF}, want: true},
+		{err: &url.Error{Op: "Put", URL: "https://storage.googleapis.com/b/o", Err: timeoutError{}}, want: true},
+		{err: &IncompleteResponseError{StatusCode: http.StatusOK, Err: io.EOF}, want: true},
+		{err: &url.Error{Op: "Put", URL: "https://storage.googleapis.com/b/o", Err: &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}}, want: false},
+		{err: &url.Error{Op: "parse", URL: "https://storage.googleapis.com/%zz", Err: url.EscapeError("%zz")}, want: false},
+		{err: errMock, want: false},
+		{err: context.Canceled, want: false},
+		{err: context.DeadlineExceeded, want: false},
+		{err: nil, want: false},
+	} {
+		if got := ShouldRetry(tc.err); got != tc.want {
+			t.Errorf("ShouldRetry(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+// timeoutError is a net.Error that timed out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestWithRetryPermanentNetworkError(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+	// The default client doesn't trust the server's certificate.
+	mpuc := New(&http.Client{}, WithEndpoint(srv.URL), WithRetry(RetryConfig{Backoff: testBackoff}))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var attempts int
+	trace := &httptrace.ClientTrace{GetConn: func(string) { attempts++ }}
+	_, err := mpuc.ListMultipartUploads(httptrace.WithClientTrace(ctx, trace), &ListMultipartUploadsRequest{Bucket: "bucket1"})
+	var certErr *tls.CertificateVerificationError
+	if !errors.As(err, &certErr) {
+		t.Errorf("got error %v, want a certificate error", err)
+	}
+	if attempts != 1 {
+		t.Errorf("got %d attempts, want 1", attempts)
+	}
+}
+
+func TestWithRetryDefaultMaxAttempts(t *testing.T) {
+	statuses := make([]int, 2*DefaultMaxAttempts)
+	for i := range statuses {
+		statuses[i] = http.StatusServiceUnavailable
+	}
+	st := &statusTransport{statuses: statuses}
+	mpuc := New(&http.Client{Transport: st}, WithRetry(RetryConfig{Backoff: testBackoff}))
+	if _, err := mpuc.ListMultipartUploads(context.Background(), &ListMultipartUploadsRequest{Bucket: "bucket1"}); err == nil {
+		t.Error("got no error, want the last 503")
+	}
+	if len(st.bodies) != DefaultMaxAttempts {
+		t.Errorf("got %d requests, want %d", len(st.bodies), DefaultMaxAttempts)
+	}
+}
+
+func TestWithRetryIncompleteResponse(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         []Option
+		wantRequests int
+	}{
+		{name: "No retry", wantRequests: 1},
+		{name: "Retry", opts: []Option{WithRetry(RetryConfig{Backoff: testBackoff})}, wantRequests: 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := 0
+			trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+				requests++
+				body := `<ListPartsResult><Part><PartNumber>1</PartNumber><ETag>"a"</ETag></Part></ListPartsResult>`
+				if requests == 1 {
+					// A proxy cuts the first response short.
+					body = body[:30]
+				}
+				return xmlResponse(body), nil
+			})
+			mpuc := New(&http.Client{Transport: trans}, tc.opts...)
+			result, err := mpuc.ListObjectParts(context.Background(), &ListObjectPartsRequest{Bucket: "bucket1", Key: "object.txt", UploadID: "my-upload-id"})
+			if requests != tc.wantRequests {
+				t.Errorf("got %d requests, want %d", requests, tc.wantRequests)
+			}
+			if tc.wantRequests == 1 {
+				var incomplete *IncompleteResponseError
+				if !errors.As(err, &incomplete) {
+					t.Errorf("got error %v, want an *IncompleteResponseError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Nothing of the first response is left over.
+			if len(result.Parts) != 1 {
+				t.Errorf("got parts %v, want 1", result.Parts)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package multipartclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/pprof"
+)
+
+// defaultRewriteConcurrency is the number of parts Rewrite copies at once.
+const defaultRewriteConcurrency = 4
+
+// ObjectRef names an object in a bucket.
+type ObjectRef struct {
+	Bucket string
+	Key    string
+}
+
+// RewriteOptions configures RewriteWithOptions.
+type RewriteOptions struct {
+	// Concurrency is the number of parts copied at once. Defaults to 4.
+	Concurrency int
+}
+
+// Rewrite rebuilds the existing object src into the object described by req
+// as a multipart upload whose parts are copied server-side from the ranges
+// in partPlan, so no object data passes through the client, with the default
+// RewriteOptions. req sets the destination and its attributes, such as its
+// storage class, metadata or content type. Part i of the new object is copied
+// from partPlan[i]; the ranges must cover src in order, without gaps or
+// overlaps, which is checked against its size before the upload is started.
+//
+// If any step fails the multipart upload is aborted so no parts are left
+// behind.
+func (mpuc *MultipartClient) Rewrite(ctx context.Context, src ObjectRef, req *InitiateMultipartUploadRequest, partPlan []ByteRange) (*CompleteMultipartUploadResult, error) {
+	return mpuc.RewriteWithOptions(ctx, src, req, partPlan, nil)
+}
+
+// RewriteWithOptions is Rewrite configured by opts, which may be nil. The
+// first part that fails to be copied cancels the others.
+func (mpuc *MultipartClient) RewriteWithOptions(ctx context.Context, src ObjectRef, req *InitiateMultipartUploadRequest, partPlan []ByteRange, opts *RewriteOptions) (*CompleteMultipartUploadResult, error) {
+	concurrency := defaultRewriteConcurrency
+	if opts != nil && opts.Concurrency > 0 {
+		concurrency = opts.Concurrency
+	}
+	attrs, err := mpuc.StatObject(ctx, src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the size of %s/%s: %w", src.Bucket, src.Key, err)
+	}
+	if err := checkPartPlan(partPlan, attrs.Size); err != nil {
+		return nil, err
+	}
+
+	initResult, err := mpuc.InitiateMultipartUpload(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	dst := ObjectRef{Bucket: req.Bucket, Key: req.Key}
+	var result *CompleteMultipartUploadResult
+	pprof.Do(ctx, UploadLabels(dst.Bucket, initResult.UploadID), func(ctx context.Context) {
+		result, err = mpuc.copyAndComplete(ctx, src, dst, initResult.UploadID, partPlan, concurrency)
+	})
+	if err != nil {
+		// Abort even if ctx was cancelled so the copied parts are not orphaned.
+		abortErr := mpuc.AbortMultipartUpload(context.WithoutCancel(ctx), &AbortMultipartUploadRequest{
+			Bucket:   dst.Bucket,
+			Key:      dst.Key,
+			UploadID: initResult.UploadID,
+		})
+		if abortErr != nil {
+			abortErr = fmt.Errorf("failed to abort upload %s: %w", initResult.UploadID, abortErr)
+		}
+		return nil, errors.Join(err, abortErr)
+	}
+	return result, nil
+}
+
+// checkPartPlan returns an error unless the ranges of partPlan cover an
+// object of size bytes in order, without gaps or overlaps.
+func checkPartPlan(partPlan []ByteRange, size int64) error {
+	if len(partPlan) == 0 {
+		return errors.New("part plan must contain at least one range")
+	}
+	if len(partPlan) > MaxParts {
+		return fmt.Errorf("part plan has %d ranges, more than the %d parts of an upload", len(partPlan), MaxParts)
+	}
+	var off int64
+	for i, r := range partPlan {
+		switch {
+		case r.Length <= 0:
+			return fmt.Errorf("range %d of the part plan is empty", i)
+		case r.Offset != off:
+			return fmt.Errorf("range %d of the part plan starts at %d, want %d", i, r.Offset, off)
+		}
+		off += r.Length
+	}
+	if off != size {
+		return fmt.Errorf("part plan covers %d bytes, but the source object has %d", off, size)
+	}
+	return nil
+}
+
+// copyAndComplete copies the parts of partPlan from src to the upload
+// uploadID of dst, at most concurrency at a time, and completes it.
+func (mpuc *MultipartClient) copyAndComplete(ctx context.Context, src, dst ObjectRef, uploadID string, partPlan []ByteRange, concurrency int) (*CompleteMultipartUploadResult, error) {
+	sched := newPartScheduler(ctx, concurrency)
+	parts := make([]CompletePart, len(partPlan))
+	for i := range partPlan {
+		if sched.acquire() != nil {
+			break
+		}
+		partNumber := i + 1
+		sched.start("copy", partNumber, func(ctx context.Context) error {
+			copyResult, err := mpuc.UploadPartCopy(ctx, &UploadPartCopyRequest{
+				Bucket:       dst.Bucket,
+				Key:          dst.Key,
+				PartNumber:   partNumber,
+				UploadID:     uploadID,
+				SourceBucket: src.Bucket,
+				SourceKey:    src.Key,
+				SourceRange:  &partPlan[i],
+			})
+			if err != nil {
+				return err
+			}
+			parts[i] = CompletePart{PartNumber: partNumber, ETag: copyResult.ETag}
+			return nil
+		})
+	}
+	if err := sched.wait(); err != nil {
+		return nil, err
+	}
+
+	return mpuc.CompleteMultipartUpload(ctx, &CompleteMultipartUploadRequest{
+		Bucket:   dst.Bucket,
+		Key:      dst.Key,
+		UploadID: uploadID,
+		Body: CompleteMultipartUploadBody{
+			Parts: parts,
+		},
+	})
+}
@@ -0,0 +1,250 @@
+package multipartclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// funcTransport responds to each request by calling the wrapped function.
+type funcTransport func(req *http.Request) (*http.Response, error)
+
+func (ft funcTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return ft(req)
+}
+
+func TestRewrite(t *testing.T) {
+	tests := []struct {
+		name         string
+		failCopyPart int
+		wantRequests []string
+		wantErr      bool
+	}{
+		{
+			name: "All parts copied",
+			wantRequests: []string{
+				"HEAD /src-bucket/src.txt",
+				"POST /dst-bucket/dst.txt?uploads",
+				"PUT /dst-bucket/dst.txt?partNumber=1&uploadId=upload-1 bytes=0-99",
+				"PUT /dst-bucket/dst.txt?partNumber=2&uploadId=upload-1 bytes=100-149",
+				"POST /dst-bucket/dst.txt?uploadId=upload-1",
+			},
+		},
+		{
+			name:         "Copy failure aborts the upload",
+			failCopyPart: 2,
+			wantRequests: []string{
+				"HEAD /src-bucket/src.txt",
+				"POST /dst-bucket/dst.txt?uploads",
+				"PUT /dst-bucket/dst.txt?partNumber=1&uploadId=upload-1 bytes=0-99",
+				"PUT /dst-bucket/dst.txt?partNumber=2&uploadId=upload-1 bytes=100-149",
+				"DELETE /dst-bucket/dst.txt?uploadId=upload-1",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotRequests []string
+			var completeBody string
+			trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+				summary := fmt.Sprintf("%s %s", req.Method, req.URL.RequestURI())
+				if r := req.Header.Get("x-goog-copy-source-range"); r != "" {
+					summary += " " + r
+				}
+				gotRequests = append(gotRequests, summary)
+
+				body := ""
+				switch {
+				case req.Method == http.MethodHead:
+					return &http.Response{StatusCode: http.StatusOK, Status: "OK", ContentLength: 150, Body: http.NoBody}, nil
+				case req.Method == http.MethodPost && req.URL.Query().Has("uploads"):
+					body = "<InitiateMultipartUploadResult><UploadId>upload-1</UploadId></InitiateMultipartUploadResult>"
+				case req.Method == http.MethodPut:
+					partNumber := req.URL.Query().Get("partNumber")
+					if partNumber == fmt.Sprint(tc.failCopyPart) {
+						return &http.Response{StatusCode: http.StatusInternalServerError, Status: "Internal Server Error", Body: toBody("")}, nil
+					}
+					if got := req.Header.Get("x-goog-copy-source"); got != "/src-bucket/src.txt" {
+						t.Errorf("got copy source %q", got)
+					}
+					body = fmt.Sprintf("<CopyPartResult><ETag>etag-%s</ETag></CopyPartResult>", partNumber)
+				case req.Method == http.MethodPost:
+					completeBody = httpReqToStr(t, req)
+				}
+				return &http.Response{StatusCode: http.StatusOK, Status: "OK", Body: toBody(body)}, nil
+			})
+
+			mpuc := New(&http.Client{Transport: trans})
+			_, err := mpuc.RewriteWithOptions(context.Background(),
+				ObjectRef{Bucket: "src-bucket", Key: "src.txt"},
+				&InitiateMultipartUploadRequest{Bucket: "dst-bucket", Key: "dst.txt"},
+				[]ByteRange{{Offset: 0, Length: 100}, {Offset: 100, Length: 50}},
+				&RewriteOptions{Concurrency: 1})
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("got error %v, want error: %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.wantRequests, gotRequests); diff != "" {
+				t.Errorf("unexpected diff for requests: (-want, +got):\n%s", diff)
+			}
+			if !tc.wantErr {
+				for _, want := range []string{"<ETag>etag-1</ETag>", "<ETag>etag-2</ETag>"} {
+					if !strings.Contains(completeBody, want) {
+						t.Errorf("complete request %q does not contain %q", completeBody, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+// copyTransport responds to the requests of Rewrite of a source object of
+// size bytes, calling copied for each part copy request.
+func copyTransport(size int64, copied func(req *http.Request)) funcTransport {
+	return funcTransport(func(req *http.Request) (*http.Response, error) {
+		body := ""
+		switch {
+		case req.Method == http.MethodHead:
+			return &http.Response{StatusCode: http.StatusOK, Status: "OK", ContentLength: size, Body: http.NoBody}, nil
+		case req.Method == http.MethodPost && req.URL.Query().Has("uploads"):
+			body = "<InitiateMultipartUploadResult><UploadId>upload-1</UploadId></InitiateMultipartUploadResult>"
+		case req.Method == http.MethodPut:
+			copied(req)
+			body = fmt.Sprintf("<CopyPartResult><ETag>etag-%s</ETag></CopyPartResult>", req.URL.Query().Get("partNumber"))
+		}
+		return &http.Response{StatusCode: http.StatusOK, Status: "OK", Body: toBody(body)}, nil
+	})
+}
+
+func TestRewriteInvalidPlan(t *testing.T) {
+	tests := []struct {
+		name string
+		plan []ByteRange
+	}{
+		{name: "Empty", plan: nil},
+		{name: "Gap", plan: []ByteRange{{Offset: 0, Length: 100}, {Offset: 110, Length: 40}}},
+		{name: "Overlap", plan: []ByteRange{{Offset: 0, Length: 100}, {Offset: 90, Length: 60}}},
+		{name: "Short", plan: []ByteRange{{Offset: 0, Length: 100}}},
+		{name: "Long", plan: []ByteRange{{Offset: 0, Length: 100}, {Offset: 100, Length: 100}}},
+		{name: "Empty range", plan: []ByteRange{{Offset: 0, Length: 150}, {Offset: 150, Length: 0}}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var methods []string
+			trans := copyTransport(150, func(*http.Request) {})
+			mpuc := New(&http.Client{Transport: funcTransport(func(req *http.Request) (*http.Response, error) {
+				methods = append(methods, req.Method)
+				return trans(req)
+			})})
+			_, err := mpuc.Rewrite(context.Background(), ObjectRef{Bucket: "src-bucket", Key: "src.txt"}, &InitiateMultipartUploadRequest{Bucket: "dst-bucket", Key: "dst.txt"}, tc.plan)
+			if err == nil {
+				t.Fatal("got no error, want one for the plan")
+			}
+			if len(methods) > 1 {
+				t.Errorf("got requests %q, want no upload started", methods)
+			}
+		})
+	}
+}
+
+func TestRewriteDestination(t *testing.T) {
+	var initHeader http.Header
+	var copySources []string
+	var mu sync.Mutex
+	trans := copyTransport(150, func(req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		copySources = append(copySources, req.Header.Get("x-goog-copy-source"))
+	})
+	mpuc := New(&http.Client{Transport: funcTransport(func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodPost && req.URL.Query().Has("uploads") {
+			initHeader = req.Header
+		}
+		return trans(req)
+	})})
+	_, err := mpuc.Rewrite(context.Background(),
+		ObjectRef{Bucket: "src-bucket", Key: "dir/a?b c.txt"},
+		&InitiateMultipartUploadRequest{Bucket: "dst-bucket", Key: "dst.txt", StorageClass: "NEARLINE", ContentType: "text/plain"},
+		[]ByteRange{{Offset: 0, Length: 100}, {Offset: 100, Length: 50}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := initHeader.Get("x-goog-storage-class"); got != "NEARLINE" {
+		t.Errorf("got storage class %q, want NEARLINE", got)
+	}
+	if got := initHeader.Get("Content-Type"); got != "text/plain" {
+		t.Errorf("got content type %q, want text/plain", got)
+	}
+	for _, got := range copySources {
+		if want := "/src-bucket/dir/a%3Fb%20c.txt"; got != want {
+			t.Errorf("got copy source %q, want %q", got, want)
+		}
+	}
+}
+
+func TestRewriteConcurrency(t *testing.T) {
+	const parts = 3
+	// Every copy waits for the others, so the rewrite only finishes if they
+	// run at once.
+	var arrived sync.WaitGroup
+	arrived.Add(parts)
+	trans := copyTransport(parts*100, func(req *http.Request) {
+		arrived.Done()
+		arrived.Wait()
+	})
+	mpuc := New(&http.Client{Transport: trans})
+	plan := make([]ByteRange, parts)
+	for i := range plan {
+		plan[i] = ByteRange{Offset: int64(i) * 100, Length: 100}
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := mpuc.RewriteWithOptions(context.Background(), ObjectRef{Bucket: "src-bucket", Key: "src.txt"}, &InitiateMultipartUploadRequest{Bucket: "dst-bucket", Key: "dst.txt"}, plan, &RewriteOptions{Concurrency: parts})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("copies didn't run at once")
+	}
+}
+
+func TestRewriteLabelsAndMetrics(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		labels []partLabels
+	)
+	trans := copyTransport(300, func(req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		labels = append(labels, labelsOf(req))
+	})
+	metrics := newRecordingMetrics()
+	mpuc := New(&http.Client{Transport: trans}, WithMetrics(metrics))
+	plan := []ByteRange{{Offset: 0, Length: 100}, {Offset: 100, Length: 100}, {Offset: 200, Length: 100}}
+	if _, err := mpuc.Rewrite(context.Background(), ObjectRef{Bucket: "src-bucket", Key: "src.txt"}, &InitiateMultipartUploadRequest{Bucket: "dst-bucket", Key: "dst.txt"}, plan); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(labels) != len(plan) {
+		t.Fatalf("got %d parts copied, want %d", len(labels), len(plan))
+	}
+	for _, l := range labels {
+		if l.bucket != "dst-bucket" || l.labelUploadID != "upload-1" {
+			t.Errorf("got labels bucket=%q upload_id=%q for a copy, want dst-bucket and upload-1", l.bucket, l.labelUploadID)
+		}
+	}
+	if metrics.maxInFlight == 0 || metrics.inFlight != 0 {
+		t.Errorf("got %d parts in flight at most and %d at the end, want copies counted and none left", metrics.maxInFlight, metrics.inFlight)
+	}
+}
@@ -0,0 +1,44 @@
+package multipartclient
+
+import "io"
+
+// SectionBody is a part body of n bytes read at an offset of an io.ReaderAt,
+// such as an *os.File or a bytes.Reader over a memory-mapped file. The bytes
+// are read straight into the HTTP transport's buffers, so uploading parts of a
+// large file costs no memory beyond the transport's own. Its length is known,
+// so the part is sent with a Content-Length, and it is seekable, so it can be
+// sent again after a redirect, a failure WithRetry retries, or a mismatch
+// VerifyChecksums finds.
+//
+// Parts of one file can be uploaded concurrently with a SectionBody each, as
+// long as the io.ReaderAt allows concurrent calls, as *os.File and
+// bytes.Reader do. Closing a SectionBody doesn't close the io.ReaderAt.
+type SectionBody struct {
+	*io.SectionReader
+}
+
+// NewSectionBody returns a body of the n bytes of r starting at off.
+func NewSectionBody(r io.ReaderAt, off, n int64) *SectionBody {
+	return &SectionBody{SectionReader: io.NewSectionReader(r, off, n)}
+}
+
+// Close does nothing, since the io.ReaderAt is shared with other parts.
+func (b *SectionBody) Close() error {
+	return nil
+}
+
+// sizedBody is a part body whose length is known, so it is sent with a
+// Content-Length.
+type sizedBody interface {
+	// remaining returns the number of bytes left to read.
+	remaining() (int64, error)
+}
+
+// remaining returns the number of bytes left to read from b.
+func (b *SectionBody) remaining() (int64, error) {
+	pos, err := b.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	return max(b.Size()-pos, 0), nil
+}
@@ -0,0 +1,72 @@
+package multipartclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSectionBody(t *testing.T) {
+	const contents = "0123456789"
+	var gotLength int64
+	var gotBody string
+	trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+		gotLength = req.ContentLength
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		gotBody = string(b)
+		return &http.Response{StatusCode: http.StatusOK, Status: "OK", Body: http.NoBody}, nil
+	})
+	mpuc := New(&http.Client{Transport: trans})
+	body := NewSectionBody(strings.NewReader(contents), 3, 4)
+	_, err := mpuc.UploadObjectPart(context.Background(), &UploadObjectPartRequest{
+		Bucket:     "bucket1",
+		Key:        "object.txt",
+		PartNumber: 1,
+		UploadID:   "my-upload-id",
+		Body:       body,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotBody != "3456" || gotLength != 4 {
+		t.Errorf("got body %q with Content-Length %d, want %q with 4", gotBody, gotLength, "3456")
+	}
+}
+
+func TestSectionBodyResentOnMismatch(t *testing.T) {
+	const contents = "0123456789"
+	var bodies []string
+	trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		bodies = append(bodies, string(b))
+		// Report the checksums of other data to the first attempt.
+		header := http.Header{}
+		if len(bodies) == 1 {
+			header.Set("x-goog-hash", "crc32c=AAAAAA==")
+		}
+		return &http.Response{StatusCode: http.StatusOK, Status: "OK", Header: header, Body: http.NoBody}, nil
+	})
+	mpuc := New(&http.Client{Transport: trans})
+	_, err := mpuc.UploadObjectPart(context.Background(), &UploadObjectPartRequest{
+		Bucket:          "bucket1",
+		Key:             "object.txt",
+		PartNumber:      1,
+		UploadID:        "my-upload-id",
+		Body:            NewSectionBody(strings.NewReader(contents), 5, 5),
+		VerifyChecksums: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 2 || bodies[0] != "56789" || bodies[1] != "56789" {
+		t.Errorf("got request bodies %q, want %q twice", bodies, "56789")
+	}
+}
@@ -0,0 +1,322 @@
+package multipartclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+	"google.golang.org/api/iterator"
+)
+
+// UploadSession is a multipart upload that records the ETags of its parts as
+// they are uploaded, so it can be completed without the caller collecting
+// them. With a Checkpointer, it also saves them, so a process that restarts
+// can resume the upload with ResumeSession. It is safe for concurrent use, so
+// parts can be uploaded from several goroutines.
+type UploadSession struct {
+	upload *UploadHandle
+	// partSize is recorded in checkpoints; see Checkpoint.PartSize.
+	partSize int64
+
+	mu sync.Mutex
+	// parts holds the record of each part by part number.
+	parts map[int]PartRecord
+	// partial holds the record of each part being buffered, with its
+	// HashState, by part number.
+	partial map[int]PartRecord
+
+	// saveMu orders saves so that a checkpoint never replaces a later one.
+	saveMu       sync.Mutex
+	checkpointer Checkpointer
+}
+
+// NewSession initiates the multipart upload req and returns a session for
+// it.
+func (mpuc *MultipartClient) NewSession(ctx context.Context, req *InitiateMultipartUploadRequest) (*UploadSession, error) {
+	result, err := mpuc.InitiateMultipartUpload(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &UploadSession{
+		upload:  mpuc.Bucket(req.Bucket).Object(req.Key).Upload(result.UploadID),
+		parts:   make(map[int]PartRecord),
+		partial: make(map[int]PartRecord),
+	}, nil
+}
+
+// AttachSession returns a session for the upload uploadID of the object key
+// in bucket that was initiated elsewhere, such as by another process or
+// another SDK. It lists the parts uploaded so far, which the session
+// completes along with those it uploads, and fails if the upload doesn't
+// exist.
+func (mpuc *MultipartClient) AttachSession(ctx context.Context, bucket, key, uploadID string) (*UploadSession, error) {
+	s := &UploadSession{
+		upload:  mpuc.Bucket(bucket).Object(key).Upload(uploadID),
+		parts:   make(map[int]PartRecord),
+		partial: make(map[int]PartRecord),
+	}
+	it := s.upload.Parts(ctx)
+	for {
+		part, err := it.Next()
+		if err == iterator.Done {
+			return s, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.parts[part.PartNumber] = PartRecord{PartNumber: part.PartNumber, ETag: part.ETag}
+	}
+}
+
+// ResumeSession returns a session for the upload of cp, saved by a session
+// that was interrupted. It keeps the parts of cp that the server proves it
+// holds, as ValidateUploadedParts does; the others are left to be uploaded
+// again. It fails with the *Error NoSuchUpload if the upload was completed or
+// aborted since.
+func (mpuc *MultipartClient) ResumeSession(ctx context.Context, cp *Checkpoint) (*UploadSession, error) {
+	s := &UploadSession{
+		upload:   mpuc.Bucket(cp.Bucket).Object(cp.Key).Upload(cp.UploadID),
+		partSize: cp.PartSize,
+		parts:    make(map[int]PartRecord),
+		partial:  make(map[int]PartRecord),
+	}
+	validation, err := mpuc.ValidateUploadedParts(ctx, &ListObjectPartsRequest{Bucket: cp.Bucket, Key: cp.Key, UploadID: cp.UploadID}, cp.Parts)
+	if err != nil {
+		return nil, err
+	}
+	records := make(map[int]PartRecord, len(cp.Parts))
+	for _, record := range cp.Parts {
+		records[record.PartNumber] = record
+	}
+	for _, part := range validation.Verified {
+		record := records[part.PartNumber]
+		record.ETag = part.ETag
+		s.parts[part.PartNumber] = record
+	}
+	for _, record := range cp.Partial {
+		if _, err := mpuc.unmarshalHasher(record.HashState); err != nil {
+			return nil, fmt.Errorf("invalid hash state of part %d: %w", record.PartNumber, err)
+		}
+		if _, ok := s.parts[record.PartNumber]; !ok {
+			s.partial[record.PartNumber] = record
+		}
+	}
+	return s, nil
+}
+
+// Upload returns a handle for the session's upload.
+func (s *UploadSession) Upload() *UploadHandle {
+	return s.upload
+}
+
+// ID returns the ID of the session's upload.
+func (s *UploadSession) ID() string {
+	return s.upload.ID()
+}
+
+// Parts returns the parts of the session in ascending order of part number.
+func (s *UploadSession) Parts() []CompletePart {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	parts := make([]CompletePart, 0, len(s.parts))
+	for n, record := range s.parts {
+		parts = append(parts, CompletePart{PartNumber: n, ETag: record.ETag})
+	}
+	slices.SortFunc(parts, func(a, b CompletePart) int { return a.PartNumber - b.PartNumber })
+	return parts
+}
+
+// HasPart reports whether the session has part partNumber.
+func (s *UploadSession) HasPart(partNumber int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.parts[partNumber]
+	return ok
+}
+
+// partMatches reports whether body holds the data of the recorded part
+// partNumber, comparing its checksums with those of the record, or its MD5
+// with the ETag if the record has none, and seeks body back to its start. It
+// reports false, without reading body, if the part isn't recorded or body
+// can't be read again.
+func (s *UploadSession) partMatches(partNumber int, body io.Reader) (bool, error) {
+	s.mu.Lock()
+	record, ok := s.parts[partNumber]
+	s.mu.Unlock()
+	seeker, seekable := body.(io.Seeker)
+	if !ok || !seekable {
+		return false, nil
+	}
+	h := gcshash.NewHasher()
+	if _, err := io.Copy(h, body); err != nil {
+		return false, err
+	}
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	sums := h.Sums()
+	switch {
+	case record.Hashes.MD5 != nil || record.Hashes.HasCRC32C:
+		return (record.Hashes.MD5 == nil || bytes.Equal(record.Hashes.MD5, sums.MD5)) &&
+			(!record.Hashes.HasCRC32C || record.Hashes.CRC32C == sums.CRC32C), nil
+	default:
+		return record.ETag != "" && normalizeETag(record.ETag) == hex.EncodeToString(sums.MD5), nil
+	}
+}
+
+// Checkpoint returns the state of the session, from which ResumeSession
+// resumes it.
+func (s *UploadSession) Checkpoint() *Checkpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := &Checkpoint{
+		Bucket:   s.upload.Object().BucketName(),
+		Key:      s.upload.Object().ObjectName(),
+		UploadID: s.upload.ID(),
+		PartSize: s.partSize,
+		Parts:    make([]PartRecord, 0, len(s.parts)),
+	}
+	for _, record := range s.parts {
+		cp.Parts = append(cp.Parts, record)
+	}
+	slices.SortFunc(cp.Parts, func(a, b PartRecord) int { return a.PartNumber - b.PartNumber })
+	for _, record := range s.partial {
+		cp.Partial = append(cp.Partial, record)
+	}
+	slices.SortFunc(cp.Partial, func(a, b PartRecord) int { return a.PartNumber - b.PartNumber })
+	return cp
+}
+
+// SavePartHashState records h, the hash of the data of part partNumber
+// buffered so far, such as from a stream read a part at a time, and saves the
+// checkpoint of the session. A session resumed from the checkpoint returns it
+// from PartHasher, so the data buffered before the process restarted isn't
+// read again to hash it. Uploading the part discards it.
+func (s *UploadSession) SavePartHashState(ctx context.Context, partNumber int, h *gcshash.Hasher) error {
+	state, err := h.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to serialize hash state of part %d: %w", partNumber, err)
+	}
+	s.mu.Lock()
+	s.partial[partNumber] = PartRecord{PartNumber: partNumber, HashState: state}
+	s.mu.Unlock()
+	return s.save(ctx)
+}
+
+// PartHasher returns the hash of the data of part partNumber buffered so far,
+// as last recorded by SavePartHashState, to continue hashing the part from
+// where it stopped, or nil if there is none.
+func (s *UploadSession) PartHasher(partNumber int) (*gcshash.Hasher, error) {
+	s.mu.Lock()
+	record, ok := s.partial[partNumber]
+	s.mu.Unlock()
+	if !ok {
+		return nil, nil
+	}
+	return s.upload.mpuc.unmarshalHasher(record.HashState)
+}
+
+// SetCheckpointer makes the session save its checkpoint with c now and after
+// every part it records, and delete it once the upload is completed or
+// aborted.
+func (s *UploadSession) SetCheckpointer(ctx context.Context, c Checkpointer) error {
+	s.saveMu.Lock()
+	s.checkpointer = c
+	s.saveMu.Unlock()
+	return s.save(ctx)
+}
+
+// save saves the checkpoint of the session, if it has a Checkpointer.
+func (s *UploadSession) save(ctx context.Context) error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	if s.checkpointer == nil {
+		return nil
+	}
+	if err := s.checkpointer.Save(ctx, s.Checkpoint()); err != nil {
+		return fmt.Errorf("failed to save checkpoint of upload %s: %w", s.ID(), err)
+	}
+	return nil
+}
+
+// deleteCheckpoint deletes the checkpoint of the session, if it has a
+// Checkpointer.
+func (s *UploadSession) deleteCheckpoint(ctx context.Context) error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	if s.checkpointer == nil {
+		return nil
+	}
+	ref := s.upload.Object().Ref()
+	if err := s.checkpointer.Delete(ctx, ref.Bucket, ref.Key); err != nil {
+		return fmt.Errorf("failed to delete checkpoint of upload %s: %w", s.ID(), err)
+	}
+	return nil
+}
+
+// UploadPart uploads body as part partNumber, as UploadHandle.UploadPart,
+// and records it, replacing any part with the same number.
+func (s *UploadSession) UploadPart(ctx context.Context, partNumber int, body io.ReadCloser) (*UploadObjectPartResult, error) {
+	return s.uploadPart(ctx, partNumber, body, nil)
+}
+
+// uploadPart is UploadPart reporting the progress of body to progress, if
+// not nil.
+func (s *UploadSession) uploadPart(ctx context.Context, partNumber int, body io.ReadCloser, progress ProgressFunc) (*UploadObjectPartResult, error) {
+	result, err := s.upload.uploadPart(ctx, partNumber, body, progress)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.record(ctx, PartRecordFromResult(partNumber, result)); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CopyPart creates part partNumber from the range r of the object src, as
+// UploadHandle.CopyPart, and records it, replacing any part with the same
+// number.
+func (s *UploadSession) CopyPart(ctx context.Context, partNumber int, src ObjectRef, r *ByteRange) (*CopyPartResult, error) {
+	result, err := s.upload.CopyPart(ctx, partNumber, src, r)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.record(ctx, PartRecord{PartNumber: partNumber, ETag: result.ETag}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// record records a part and saves the checkpoint of the session.
+func (s *UploadSession) record(ctx context.Context, record PartRecord) error {
+	s.mu.Lock()
+	s.parts[record.PartNumber] = record
+	delete(s.partial, record.PartNumber)
+	s.mu.Unlock()
+	return s.save(ctx)
+}
+
+// Complete assembles the parts of the session into the object.
+func (s *UploadSession) Complete(ctx context.Context) (*CompleteMultipartUploadResult, error) {
+	result, err := s.upload.Complete(ctx, s.Parts())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.deleteCheckpoint(ctx); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Abort aborts the upload, deleting its parts.
+func (s *UploadSession) Abort(ctx context.Context) error {
+	if err := s.upload.Abort(ctx); err != nil {
+		return err
+	}
+	return s.deleteCheckpoint(ctx)
+}
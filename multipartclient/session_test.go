@@ -0,0 +1,249 @@
+package multipartclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multiparttest"
+)
+
+func TestAttachSession(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	srv.MinPartSize = 4
+	ctx := context.Background()
+
+	// Another process starts the upload and uploads part 1.
+	first, err := New(srv.Client()).NewSession(ctx, &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	part1, err := first.UploadPart(ctx, 1, toBody("hello "))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := New(srv.Client()).AttachSession(ctx, "bucket1", "object.txt", first.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	part2, err := s.UploadPart(ctx, 2, toBody("world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []CompletePart{{PartNumber: 1, ETag: part1.ETag}, {PartNumber: 2, ETag: part2.ETag}}
+	if diff := cmp.Diff(want, s.Parts()); diff != "" {
+		t.Errorf("unexpected diff for parts (-want, +got):\n%s", diff)
+	}
+	if _, err := s.Complete(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := srv.Object("bucket1", "object.txt"); string(got) != "hello world" {
+		t.Errorf("got object %q, want %q", got, "hello world")
+	}
+}
+
+func TestAttachSessionNoSuchUpload(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	_, err := New(srv.Client()).AttachSession(context.Background(), "bucket1", "object.txt", "missing")
+	if err == nil || !strings.Contains(err.Error(), "NoSuchUpload") {
+		t.Errorf("got error %v, want NoSuchUpload", err)
+	}
+}
+
+// memCheckpointer keeps checkpoints in memory.
+type memCheckpointer struct {
+	mu    sync.Mutex
+	cps   map[string]*Checkpoint
+	saves int
+}
+
+func (c *memCheckpointer) Load(ctx context.Context, bucket, key string) (*Checkpoint, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cps[bucket+"/"+key], nil
+}
+
+func (c *memCheckpointer) Save(ctx context.Context, cp *Checkpoint) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cps == nil {
+		c.cps = make(map[string]*Checkpoint)
+	}
+	c.cps[cp.Bucket+"/"+cp.Key] = cp
+	c.saves++
+	return nil
+}
+
+func (c *memCheckpointer) Delete(ctx context.Context, bucket, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.cps, bucket+"/"+key)
+	return nil
+}
+
+func TestResumeSession(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	srv.MinPartSize = 4
+	ctx := context.Background()
+	c := &memCheckpointer{}
+
+	// A process uploads parts 1 and 2, then crashes.
+	first, err := New(srv.Client()).NewSession(ctx, &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := first.SetCheckpointer(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := first.UploadPart(ctx, 1, toBody("hello ")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := first.UploadPart(ctx, 2, toBody("wor")); err != nil {
+		t.Fatal(err)
+	}
+	if c.saves != 3 {
+		t.Errorf("got %d saves, want one on SetCheckpointer and one per part", c.saves)
+	}
+	cp, _ := c.Load(ctx, "bucket1", "object.txt")
+	if cp == nil {
+		t.Fatal("no checkpoint was saved")
+	}
+	// Part 2 changed since it was checkpointed.
+	cp.Parts[1].ETag = `"stale"`
+	cp.Parts[1].Hashes = gcshash.Sums{}
+
+	s, err := New(srv.Client()).ResumeSession(ctx, cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.HasPart(1) || s.HasPart(2) {
+		t.Errorf("got parts %v, want only part 1 resumed", s.Parts())
+	}
+	if err := s.SetCheckpointer(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UploadPart(ctx, 2, toBody("world")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Complete(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := srv.Object("bucket1", "object.txt"); string(got) != "hello world" {
+		t.Errorf("got object %q, want %q", got, "hello world")
+	}
+	if cp, _ := c.Load(ctx, "bucket1", "object.txt"); cp != nil {
+		t.Errorf("got checkpoint %+v after completing, want it deleted", cp)
+	}
+
+	// The upload is gone now.
+	_, err = New(srv.Client()).ResumeSession(ctx, s.Checkpoint())
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Code != "NoSuchUpload" {
+		t.Errorf("got error %v resuming a completed upload, want NoSuchUpload", err)
+	}
+}
+
+func TestResumeSessionPartHashState(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	ctx := context.Background()
+	c := &FileCheckpointer{Dir: t.TempDir()}
+
+	// A process buffers the first half of part 1 from a stream, hashing it as
+	// it goes, then crashes.
+	first, err := New(srv.Client()).NewSession(ctx, &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := first.SetCheckpointer(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	var buffered strings.Builder
+	h := gcshash.NewHasher()
+	w := io.MultiWriter(&buffered, h)
+	io.WriteString(w, "hello ")
+	if err := first.SavePartHashState(ctx, 1, h); err != nil {
+		t.Fatal(err)
+	}
+
+	cp, err := c.Load(ctx, "bucket1", "object.txt")
+	if err != nil || cp == nil {
+		t.Fatalf("Load() = %v, %v, want the checkpoint", cp, err)
+	}
+	s, err := New(srv.Client()).ResumeSession(ctx, cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetCheckpointer(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	h, err = s.PartHasher(1)
+	if err != nil || h == nil {
+		t.Fatalf("PartHasher(1) = %v, %v, want the saved hash", h, err)
+	}
+	if got := h.Size(); got != 6 {
+		t.Errorf("got hash of %d bytes, want 6", got)
+	}
+	// Only the rest of the stream is hashed.
+	io.WriteString(io.MultiWriter(&buffered, h), "world")
+	result, err := s.UploadPart(ctx, 1, toBody(buffered.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(result.ComputedHashes, h.Sums()); diff != "" {
+		t.Errorf("hash of the part resumed from its state differs from that of its data (-data +resumed):\n%s", diff)
+	}
+	if h, err := s.PartHasher(1); h != nil || err != nil {
+		t.Errorf("PartHasher(1) = %v, %v after uploading the part, want nil", h, err)
+	}
+	if cp, _ := c.Load(ctx, "bucket1", "object.txt"); len(cp.Partial) != 0 {
+		t.Errorf("got Partial %+v after uploading the part, want none", cp.Partial)
+	}
+
+	// A corrupt state isn't resumed.
+	cp.Partial = []PartRecord{{PartNumber: 2, HashState: []byte("corrupt")}}
+	if _, err := New(srv.Client()).ResumeSession(ctx, cp); err == nil {
+		t.Error("ResumeSession() with a corrupt hash state succeeded, want an error")
+	}
+}
+
+func TestSessionPartMatches(t *testing.T) {
+	hasher := gcshash.NewHasher()
+	hasher.Write([]byte("hello"))
+	sums := hasher.Sums()
+	s := &UploadSession{parts: map[int]PartRecord{
+		1: {PartNumber: 1, ETag: `"etag"`, Hashes: sums},
+		2: {PartNumber: 2, ETag: fmt.Sprintf(`"%x"`, sums.MD5)},
+		3: {PartNumber: 3, ETag: `"not-an-md5"`},
+	}}
+	for _, tc := range []struct {
+		partNumber int
+		data       string
+		want       bool
+	}{
+		{partNumber: 1, data: "hello", want: true},
+		{partNumber: 1, data: "hellO", want: false},
+		{partNumber: 2, data: "hello", want: true},
+		{partNumber: 2, data: "world", want: false},
+		{partNumber: 3, data: "hello", want: false},
+		{partNumber: 4, data: "hello", want: false},
+	} {
+		body := NewSectionBody(strings.NewReader(tc.data), 0, int64(len(tc.data)))
+		got, err := s.partMatches(tc.partNumber, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("got match %t for %q as part %d, want %t", got, tc.data, tc.partNumber, tc.want)
+		}
+		if n, _ := body.remaining(); n != int64(len(tc.data)) {
+			t.Errorf("got %d bytes left to read of part %d, want the body at its start", n, tc.partNumber)
+		}
+	}
+}
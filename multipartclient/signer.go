@@ -0,0 +1,65 @@
+package multipartclient
+
+import (
+	"net/http"
+	"time"
+)
+
+// hmacRegion is the region requests to Cloud Storage are signed for.
+const hmacRegion = "auto"
+
+// Signer authorizes requests by signing them, so that the client can be
+// given an *http.Client without credentials, such as http.DefaultClient.
+// Sign is called before every request is sent, including retries and
+// redirects, after its headers are set, and must set the Authorization
+// header. A Signer must be safe for concurrent use.
+type Signer interface {
+	Sign(req *http.Request, now time.Time)
+}
+
+// WithSigner signs every request with s. It takes precedence over the
+// Credentials of WithS3Compatibility.
+//
+//	mpuc := multipartclient.New(http.DefaultClient, multipartclient.WithSigner(&multipartclient.HMACSigner{
+//		AccessID: "GOOG1E...",
+//		Secret:   secret,
+//	}))
+func WithSigner(s Signer) Option {
+	return func(mpuc *MultipartClient) {
+		mpuc.signer = s
+	}
+}
+
+// HMACSigner signs requests to Cloud Storage with an HMAC key, using the V4
+// signing process with the GOOG4-HMAC-SHA256 algorithm. With
+// WithContentSHA256, the payload hash is signed; otherwise requests are sent
+// with UnsignedPayload.
+type HMACSigner struct {
+	// AccessID is the access ID of the HMAC key, such as "GOOG1E...".
+	AccessID string
+	Secret   string
+}
+
+func (s *HMACSigner) Sign(req *http.Request, now time.Time) {
+	googScheme.sign(req, &AWSCredentials{AccessKeyID: s.AccessID, SecretAccessKey: s.Secret}, hmacRegion, now)
+}
+
+// AWSSigner signs requests with AWS Signature Version 4, as the Credentials
+// of WithS3Compatibility do. Cloud Storage accepts such signatures made with
+// its HMAC keys, so tools written for S3 can share credentials with the
+// client.
+type AWSSigner struct {
+	Credentials *AWSCredentials
+	// Region is the region requests are signed for. Defaults to "auto",
+	// which Cloud Storage expects; S3-compatible servers generally expect
+	// us-east-1.
+	Region string
+}
+
+func (s *AWSSigner) Sign(req *http.Request, now time.Time) {
+	region := s.Region
+	if region == "" {
+		region = hmacRegion
+	}
+	awsScheme.sign(req, s.Credentials, region, now)
+}
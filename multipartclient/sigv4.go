@@ -0,0 +1,192 @@
+package multipartclient
+
+import (
+	"cmp"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultRegion is the region requests are signed for if none is configured.
+const defaultRegion = "us-east-1"
+
+const (
+	amzContentSHA   = "x-amz-content-sha256"
+	sigV4TimeFormat = "20060102T150405Z"
+)
+
+// sigV4Scheme is a variant of Signature Version 4: AWS's, or the V4 signing
+// process of Cloud Storage, which differs only in names.
+type sigV4Scheme struct {
+	// algorithm names the scheme in the Authorization header.
+	algorithm string
+	// keyPrefix is prepended to the secret to derive the signing key.
+	keyPrefix string
+	service   string
+	// terminator ends the credential scope.
+	terminator string
+	// headerPrefix is the prefix of the date, token and payload hash
+	// headers, and of the headers signed along with Host, Content-Type and
+	// Content-MD5.
+	headerPrefix string
+}
+
+var (
+	awsScheme  = &sigV4Scheme{algorithm: "AWS4-HMAC-SHA256", keyPrefix: "AWS4", service: "s3", terminator: "aws4_request", headerPrefix: "x-amz-"}
+	googScheme = &sigV4Scheme{algorithm: "GOOG4-HMAC-SHA256", keyPrefix: "GOOG4", service: "storage", terminator: "goog4_request", headerPrefix: "x-goog-"}
+)
+
+// AWSCredentials are the access key requests to S3-compatible servers are
+// signed with.
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is sent with temporary credentials, and is empty
+	// otherwise.
+	SessionToken string
+}
+
+// AWSCredentialsFromEnv returns the credentials in the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and, if set, AWS_SESSION_TOKEN environment variables.
+func AWSCredentialsFromEnv() (*AWSCredentials, error) {
+	creds := &AWSCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+	return creds, nil
+}
+
+// signV4 adds an AWS Signature Version 4 Authorization header to req, signed
+// at now for the s3 service in region. The payload hash is taken from the
+// x-amz-content-sha256 header, which is set to UnsignedPayload if missing.
+func signV4(req *http.Request, creds *AWSCredentials, region string, now time.Time) {
+	awsScheme.sign(req, creds, region, now)
+}
+
+// sign adds an Authorization header of the scheme to req, signed with creds
+// at now for region. The payload hash is taken from the scheme's
+// content-sha256 header, or else from the x-amz- or x-goog- one the client
+// sent, and the scheme's header is set to it, or to UnsignedPayload if there
+// is none.
+func (sc *sigV4Scheme) sign(req *http.Request, creds *AWSCredentials, region string, now time.Time) {
+	now = now.UTC()
+	req.Header.Set(sc.headerPrefix+"date", now.Format(sigV4TimeFormat))
+	if creds.SessionToken != "" {
+		req.Header.Set(sc.headerPrefix+"security-token", creds.SessionToken)
+	}
+	payloadHash := cmp.Or(req.Header.Get(sc.headerPrefix+"content-sha256"), req.Header.Get(amzContentSHA), req.Header.Get(contentSHA256Header), UnsignedPayload)
+	req.Header.Set(sc.headerPrefix+"content-sha256", payloadHash)
+
+	path := req.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	signedHeaders, canonicalHeaders := canonicalHeaders(req, sc.headerPrefix)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(path, false),
+		canonicalQuery(req),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	date := now.Format("20060102")
+	scope := fmt.Sprintf("%s/%s/%s/%s", date, region, sc.service, sc.terminator)
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		sc.algorithm,
+		now.Format(sigV4TimeFormat),
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte(sc.keyPrefix+creds.SecretAccessKey), date)
+	for _, part := range []string{region, sc.service, sc.terminator} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sc.algorithm, creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalHeaders returns the names of the signed headers of req, which are
+// Host, Content-Type, Content-MD5 and the headers with prefix, and their
+// canonical form.
+func canonicalHeaders(req *http.Request, prefix string) (signed, canonical string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	values := map[string]string{"host": host}
+	for name, vs := range req.Header {
+		name = strings.ToLower(name)
+		if name != "content-type" && name != "content-md5" && !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		trimmed := make([]string, len(vs))
+		for i, v := range vs {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		values[name] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + ":" + values[name] + "\n")
+	}
+	return strings.Join(names, ";"), b.String()
+}
+
+// canonicalQuery returns the query of req with its parameters sorted and
+// URI-encoded.
+func canonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+	params := make([]string, 0, len(query))
+	for name, vs := range query {
+		for _, v := range vs {
+			params = append(params, uriEncode(name, true)+"="+uriEncode(v, true))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// uriEncode percent-encodes every byte of s but the unreserved characters of
+// RFC 3986 and, unless encodeSlash is set, slashes.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
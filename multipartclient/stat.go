@@ -0,0 +1,116 @@
+package multipartclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+	"google.golang.org/api/googleapi"
+)
+
+// ErrObjectNotExist is returned by StatObject if the object doesn't exist.
+var ErrObjectNotExist = errors.New("object does not exist")
+
+// ObjectAttrs are the attributes of an object, as returned by StatObject.
+type ObjectAttrs struct {
+	Correlation
+	Bucket string
+	Key    string
+	Size   int64
+	ETag   string
+	// Generation and Metageneration are 0 if the server didn't send them.
+	Generation     int64
+	Metageneration int64
+	ContentType    string
+	StorageClass   string
+	// KMSKeyName is the Cloud KMS key that encrypts the object, or "" if it
+	// isn't encrypted with one.
+	KMSKeyName   string
+	LastModified time.Time
+	// Metadata is the custom metadata of the object, or nil if it has none.
+	// StatObject reads it from x-goog-meta- headers, whose names are
+	// case-insensitive, so it returns the keys in lower case.
+	Metadata map[string]string
+	// Sums are the checksums the server reported. Cloud Storage reports no
+	// MD5 for objects assembled from parts.
+	Sums gcshash.Sums
+}
+
+// StatObject returns the attributes of an object, read with a HEAD request,
+// or ErrObjectNotExist if there is no such object.
+func (mpuc *MultipartClient) StatObject(ctx context.Context, ref ObjectRef) (attrs *ObjectAttrs, err error) {
+	ctx = mpuc.startOperation(ctx, OpStatObject)
+	defer func(start time.Time) {
+		err = mpuc.operationDone(ctx, OpStatObject, ref, operationInfo{Bucket: ref.Bucket, Key: ref.Key}, start, err)
+	}(mpuc.clock.Now())
+
+	if err := mpuc.validate(OpStatObject, ref); err != nil {
+		return nil, err
+	}
+	url := mpuc.requestURL(ref.Bucket, ref.Key, "")
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodHead, url, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	mpuc.setUserProject(ctx, httpReq, ref.Bucket)
+	if err := mpuc.setPayloadHash(httpReq, http.NoBody); err != nil {
+		return nil, err
+	}
+
+	resp, err := mpuc.do(ctx, OpStatObject, httpReq)
+	defer googleapi.CloseBody(resp)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, ErrObjectNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	attrs = &ObjectAttrs{
+		Correlation: correlationOf(ctx, resp),
+		Bucket:      ref.Bucket,
+		Key:         ref.Key,
+		Size:        resp.ContentLength,
+		ETag:        resp.Header.Get("ETag"),
+		ContentType: resp.Header.Get("Content-Type"),
+		// S3-compatible servers send x-amz-storage-class.
+		StorageClass: resp.Header.Get(mpuc.header("x-goog-storage-class")),
+		KMSKeyName:   mpuc.kmsKeyNameOf(resp),
+	}
+	for name, dst := range map[string]*int64{
+		"x-goog-generation":     &attrs.Generation,
+		"x-goog-metageneration": &attrs.Metageneration,
+	} {
+		if v := resp.Header.Get(name); v != "" {
+			*dst, err = strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s header %q: %w", name, v, err)
+			}
+		}
+	}
+	if lm := resp.Header.Get("Last-Modified"); lm != "" {
+		attrs.LastModified, err = http.ParseTime(lm)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Last-Modified header %q: %w", lm, err)
+		}
+	}
+	metaPrefix := http.CanonicalHeaderKey(mpuc.header("x-goog-meta-"))
+	for name, values := range resp.Header {
+		if key, ok := strings.CutPrefix(name, metaPrefix); ok && len(values) > 0 {
+			if attrs.Metadata == nil {
+				attrs.Metadata = map[string]string{}
+			}
+			attrs.Metadata[strings.ToLower(key)] = values[0]
+		}
+	}
+	attrs.Sums, err = gcshash.ParseHeader(resp.Header)
+	if err != nil {
+		return nil, err
+	}
+	return attrs, nil
+}
@@ -0,0 +1,82 @@
+package multipartclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+)
+
+func TestStatObject(t *testing.T) {
+	tests := []struct {
+		name    string
+		resp    *http.Response
+		want    *ObjectAttrs
+		wantErr error
+	}{
+		{
+			name: "Exists",
+			resp: &http.Response{
+				StatusCode:    http.StatusOK,
+				ContentLength: 11,
+				Header: http.Header{
+					"Etag":                           []string{`"abc-2"`},
+					"X-Goog-Generation":              []string{"1700000000000001"},
+					"X-Goog-Metageneration":          []string{"1"},
+					"X-Goog-Storage-Class":           []string{"STANDARD"},
+					"X-Goog-Encryption-Kms-Key-Name": []string{"projects/p/locations/us/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1"},
+					"Content-Type":                   []string{"text/plain"},
+					"X-Goog-Meta-Owner":              []string{"team1"},
+					"Last-Modified":                  []string{"Sun, 10 Mar 2024 12:00:00 GMT"},
+					"X-Goog-Hash":                    []string{"crc32c=yZRlqg=="},
+				},
+				Body: http.NoBody,
+			},
+			want: &ObjectAttrs{
+				Bucket:         "bucket1",
+				Key:            "dir/object.txt",
+				Size:           11,
+				ETag:           `"abc-2"`,
+				Generation:     1700000000000001,
+				Metageneration: 1,
+				ContentType:    "text/plain",
+				StorageClass:   "STANDARD",
+				KMSKeyName:     "projects/p/locations/us/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1",
+				Metadata:       map[string]string{"owner": "team1"},
+				LastModified:   time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC),
+				Sums:           gcshash.Sums{CRC32C: gcshash.CRC32C([]byte("hello world")), HasCRC32C: true},
+			},
+		},
+		{
+			name:    "Not found",
+			resp:    &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found", Body: http.NoBody},
+			wantErr: ErrObjectNotExist,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotReq string
+			trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+				gotReq = req.Method + " " + req.URL.String()
+				return tc.resp, nil
+			})
+			mpuc := New(&http.Client{Transport: trans})
+
+			got, err := mpuc.StatObject(context.Background(), ObjectRef{Bucket: "bucket1", Key: "dir/object.txt"})
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("got error %v, want %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected diff for attrs: (-want, +got):\n%s", diff)
+			}
+			if want := "HEAD https://storage.googleapis.com/bucket1/dir/object.txt"; gotReq != want {
+				t.Errorf("got request %q, want %q", gotReq, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package multipartclient
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// FailureClass groups failed requests by cause.
+type FailureClass string
+
+const (
+	// FailureNetwork is a request that got no response, e.g. a connection
+	// error or timeout.
+	FailureNetwork FailureClass = "network"
+	// FailureCanceled is a request whose context was canceled or expired.
+	FailureCanceled FailureClass = "canceled"
+	// FailureClient is a request rejected with a 4xx status.
+	FailureClient FailureClass = "client"
+	// FailureServer is a request that failed with a 5xx status.
+	FailureServer FailureClass = "server"
+	// FailureOther is any other failure, e.g. an unexpected 3xx status.
+	FailureOther FailureClass = "other"
+)
+
+// Stats is a snapshot of the client's counters since it was created, for
+// embedders without a metrics stack. Use WithMetrics for more detail.
+type Stats struct {
+	// Requests is the number of HTTP requests sent, by operation.
+	Requests map[string]uint64
+	// Successes is the number of requests that got a 2xx response.
+	Successes uint64
+	// Failures is the number of failed requests, by class.
+	Failures map[FailureClass]uint64
+	// Retries is the number of requests that were sent again.
+	Retries uint64
+	// BytesSent is the part data sent, including retries.
+	BytesSent int64
+}
+
+// clientStats holds the counters behind Stats. The maps are filled once by
+// newClientStats and only read afterwards, so the counters can be updated
+// without a lock.
+type clientStats struct {
+	requests  map[string]*atomic.Uint64
+	successes atomic.Uint64
+	failures  map[FailureClass]*atomic.Uint64
+	retries   atomic.Uint64
+	bytesSent atomic.Int64
+}
+
+func newClientStats() *clientStats {
+	s := &clientStats{
+		requests: map[string]*atomic.Uint64{},
+		failures: map[FailureClass]*atomic.Uint64{},
+	}
+	for _, op := range []string{
+		OpInitiateMultipartUpload,
+		OpUploadObjectPart,
+		OpUploadPartCopy,
+		OpCompleteMultipartUpload,
+		OpAbortMultipartUpload,
+		OpListMultipartUploads,
+		OpListObjectParts,
+		OpHealthCheck,
+		OpStatObject,
+		OpPatchObjectMetadata,
+	} {
+		s.requests[op] = &atomic.Uint64{}
+	}
+	for _, c := range []FailureClass{FailureNetwork, FailureCanceled, FailureClient, FailureServer, FailureOther} {
+		s.failures[c] = &atomic.Uint64{}
+	}
+	return s
+}
+
+func (s *clientStats) requestDone(ctx context.Context, op string, statusCode int, err error) {
+	if n, ok := s.requests[op]; ok {
+		n.Add(1)
+	}
+	if err == nil {
+		s.successes.Add(1)
+		return
+	}
+	s.failures[classifyFailure(ctx, statusCode, err)].Add(1)
+}
+
+func classifyFailure(ctx context.Context, statusCode int, err error) FailureClass {
+	switch {
+	case ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return FailureCanceled
+	case statusCode == 0:
+		return FailureNetwork
+	case 400 <= statusCode && statusCode < 500:
+		return FailureClient
+	case 500 <= statusCode:
+		return FailureServer
+	}
+	return FailureOther
+}
+
+// Stats returns a snapshot of the client's request counters.
+func (mpuc *MultipartClient) Stats() Stats {
+	st := Stats{
+		Requests:  make(map[string]uint64, len(mpuc.stats.requests)),
+		Successes: mpuc.stats.successes.Load(),
+		Failures:  make(map[FailureClass]uint64, len(mpuc.stats.failures)),
+		Retries:   mpuc.stats.retries.Load(),
+		BytesSent: mpuc.stats.bytesSent.Load(),
+	}
+	for op, n := range mpuc.stats.requests {
+		st.Requests[op] = n.Load()
+	}
+	for c, n := range mpuc.stats.failures {
+		st.Failures[c] = n.Load()
+	}
+	return st
+}
@@ -0,0 +1,111 @@
+package multipartclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+)
+
+func TestStats(t *testing.T) {
+	const contents = "part contents"
+	uploads := 0
+	trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+		switch req.Method {
+		case http.MethodDelete:
+			return &http.Response{StatusCode: http.StatusNotFound, Status: "Not Found", Body: http.NoBody}, nil
+		case http.MethodGet:
+			return nil, errors.New("connection reset")
+		}
+		if _, err := io.Copy(io.Discard, req.Body); err != nil {
+			t.Fatal(err)
+		}
+		uploads++
+		resp := &http.Response{StatusCode: http.StatusOK, Status: "OK", Header: http.Header{}, Body: http.NoBody}
+		// Report a corrupt part on the first upload to cause a retry.
+		if uploads == 1 {
+			gcshash.Sums{CRC32C: 1, HasCRC32C: true}.SetHeader(resp.Header)
+		}
+		return resp, nil
+	})
+
+	mpuc := New(&http.Client{Transport: trans})
+	ctx := context.Background()
+	if _, err := mpuc.UploadObjectPart(ctx, &UploadObjectPartRequest{
+		Bucket:          "bucket1",
+		Key:             "object.txt",
+		PartNumber:      1,
+		UploadID:        "my-upload-id",
+		Body:            &seekableBody{Reader: strings.NewReader(contents)},
+		VerifyChecksums: true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mpuc.AbortMultipartUpload(ctx, &AbortMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt", UploadID: "my-upload-id"}); err == nil {
+		t.Fatal("expected abort error")
+	}
+	if _, err := mpuc.ListMultipartUploads(ctx, &ListMultipartUploadsRequest{Bucket: "bucket1"}); err == nil {
+		t.Fatal("expected list error")
+	}
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := mpuc.ListObjectParts(canceledCtx, &ListObjectPartsRequest{Bucket: "bucket1", Key: "object.txt", UploadID: "my-upload-id"}); err == nil {
+		t.Fatal("expected list error")
+	}
+
+	want := Stats{
+		Requests: map[string]uint64{
+			OpInitiateMultipartUpload: 0,
+			OpUploadObjectPart:        2,
+			OpUploadPartCopy:          0,
+			OpCompleteMultipartUpload: 0,
+			OpAbortMultipartUpload:    1,
+			OpListMultipartUploads:    1,
+			OpListObjectParts:         1,
+			OpHealthCheck:             0,
+			OpStatObject:              0,
+			OpPatchObjectMetadata:     0,
+		},
+		Successes: 2,
+		Failures: map[FailureClass]uint64{
+			FailureNetwork:  1,
+			FailureCanceled: 1,
+			FailureClient:   1,
+			FailureServer:   0,
+			FailureOther:    0,
+		},
+		Retries:   1,
+		BytesSent: 2 * int64(len(contents)),
+	}
+	if diff := cmp.Diff(want, mpuc.Stats()); diff != "" {
+		t.Errorf("unexpected diff for stats: (-want, +got):\n%s", diff)
+	}
+}
+
+func TestClassifyFailure(t *testing.T) {
+	errFailed := errors.New("failed")
+	tests := []struct {
+		name       string
+		statusCode int
+		err        error
+		want       FailureClass
+	}{
+		{name: "No response", err: errFailed, want: FailureNetwork},
+		{name: "Deadline exceeded", err: context.DeadlineExceeded, want: FailureCanceled},
+		{name: "Client error", statusCode: http.StatusForbidden, err: errFailed, want: FailureClient},
+		{name: "Server error", statusCode: http.StatusServiceUnavailable, err: errFailed, want: FailureServer},
+		{name: "Redirect", statusCode: http.StatusMovedPermanently, err: errFailed, want: FailureOther},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := classifyFailure(context.Background(), tc.statusCode, tc.err); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
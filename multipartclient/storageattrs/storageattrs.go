@@ -0,0 +1,54 @@
+// Package storageattrs converts multipartclient results to the attributes
+// type of cloud.google.com/go/storage, for code written against that package.
+// It is separate from multipartclient so that only its users depend on the
+// storage package.
+package storageattrs
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/storage"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+)
+
+// FromObjectAttrs returns attrs, as returned by StatObject, as
+// storage.ObjectAttrs. Attributes a HEAD request doesn't report, such as
+// Created and ACL, are left zero; Updated is the Last-Modified time.
+func FromObjectAttrs(attrs *multipartclient.ObjectAttrs) *storage.ObjectAttrs {
+	out := &storage.ObjectAttrs{
+		Bucket:         attrs.Bucket,
+		Name:           attrs.Key,
+		ContentType:    attrs.ContentType,
+		Size:           attrs.Size,
+		Generation:     attrs.Generation,
+		Metageneration: attrs.Metageneration,
+		StorageClass:   attrs.StorageClass,
+		KMSKeyName:     attrs.KMSKeyName,
+		Updated:        attrs.LastModified,
+		Etag:           attrs.ETag,
+		Metadata:       attrs.Metadata,
+	}
+	if attrs.Sums.HasCRC32C {
+		out.CRC32C = attrs.Sums.CRC32C
+	}
+	if attrs.Sums.MD5 != nil {
+		out.MD5 = append([]byte(nil), attrs.Sums.MD5...)
+	}
+	return out
+}
+
+// FromCompleteResult stats the object created by a completed multipart upload
+// and returns its attributes as storage.ObjectAttrs. It fails if the object
+// has since been replaced by another generation.
+func FromCompleteResult(ctx context.Context, api multipartclient.MultipartAPI, result *multipartclient.CompleteMultipartUploadResult) (*storage.ObjectAttrs, error) {
+	attrs, err := api.StatObject(ctx, multipartclient.ObjectRef{Bucket: result.Bucket, Key: result.Key})
+	if err != nil {
+		return nil, err
+	}
+	if result.Generation != 0 && attrs.Generation != 0 && attrs.Generation != result.Generation {
+		return nil, fmt.Errorf("object gs://%s/%s is at generation %d, not %d created by the upload",
+			result.Bucket, result.Key, attrs.Generation, result.Generation)
+	}
+	return FromObjectAttrs(attrs), nil
+}
@@ -0,0 +1,96 @@
+package storageattrs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multipartclientmock"
+	"go.uber.org/mock/gomock"
+)
+
+var testAttrs = &multipartclient.ObjectAttrs{
+	Bucket:         "bucket1",
+	Key:            "dir/object.txt",
+	Size:           11,
+	ETag:           `"abc-2"`,
+	Generation:     1700000000000001,
+	Metageneration: 1,
+	ContentType:    "text/plain",
+	StorageClass:   "STANDARD",
+	KMSKeyName:     "projects/p/locations/us/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1",
+	LastModified:   time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC),
+	Sums:           gcshash.Sums{CRC32C: 0xc99465aa, HasCRC32C: true},
+}
+
+var wantAttrs = &storage.ObjectAttrs{
+	Bucket:         "bucket1",
+	Name:           "dir/object.txt",
+	ContentType:    "text/plain",
+	Size:           11,
+	CRC32C:         0xc99465aa,
+	Generation:     1700000000000001,
+	Metageneration: 1,
+	StorageClass:   "STANDARD",
+	KMSKeyName:     "projects/p/locations/us/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1",
+	Updated:        time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC),
+	Etag:           `"abc-2"`,
+}
+
+func TestFromObjectAttrs(t *testing.T) {
+	if diff := cmp.Diff(wantAttrs, FromObjectAttrs(testAttrs)); diff != "" {
+		t.Errorf("unexpected diff (-want, +got):\n%s", diff)
+	}
+
+	withMD5 := *testAttrs
+	withMD5.Sums.MD5 = []byte{1, 2, 3}
+	if got := FromObjectAttrs(&withMD5).MD5; !cmp.Equal(got, []byte{1, 2, 3}) {
+		t.Errorf("got MD5 %x, want 010203", got)
+	}
+}
+
+func TestFromCompleteResult(t *testing.T) {
+	errStat := errors.New("stat failed")
+	tests := []struct {
+		name       string
+		generation int64
+		statErr    error
+		want       *storage.ObjectAttrs
+		wantErr    bool
+	}{
+		{name: "Same generation", generation: 1700000000000001, want: wantAttrs},
+		{name: "No generation", want: wantAttrs},
+		{name: "Replaced", generation: 1600000000000001, wantErr: true},
+		{name: "Stat failed", generation: 1700000000000001, statErr: errStat, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			api := multipartclientmock.NewMockMultipartAPI(gomock.NewController(t))
+			attrs := testAttrs
+			if tt.statErr != nil {
+				attrs = nil
+			}
+			api.EXPECT().
+				StatObject(ctx, multipartclient.ObjectRef{Bucket: "bucket1", Key: "dir/object.txt"}).
+				Return(attrs, tt.statErr)
+
+			result := &multipartclient.CompleteMultipartUploadResult{Bucket: "bucket1", Key: "dir/object.txt", Generation: tt.generation}
+			got, err := FromCompleteResult(ctx, api, result)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error: %v", err, tt.wantErr)
+			}
+			if tt.statErr != nil && !errors.Is(err, tt.statErr) {
+				t.Errorf("got error %v, want %v", err, tt.statErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("unexpected diff (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package multipartclient
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// cloudTraceHeader links GCS request logs to a trace in Cloud Trace.
+	cloudTraceHeader = "X-Cloud-Trace-Context"
+	// traceparentHeader is the W3C Trace Context header.
+	traceparentHeader = "traceparent"
+)
+
+// setTraceHeaders propagates the OpenTelemetry span of ctx, if there is one,
+// onto req as the traceparent header and, for Cloud Storage, the
+// X-Cloud-Trace-Context header, so server-side logs of the request correlate
+// with the caller's trace. Headers already set on req are kept.
+func (mpuc *MultipartClient) setTraceHeaders(ctx context.Context, req *http.Request) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	if req.Header.Get(traceparentHeader) == "" {
+		req.Header.Set(traceparentHeader, fmt.Sprintf("00-%s-%s-%s", sc.TraceID(), sc.SpanID(), sc.TraceFlags()))
+	}
+	if mpuc.compat == nil && req.Header.Get(cloudTraceHeader) == "" {
+		spanID := sc.SpanID()
+		sampled := 0
+		if sc.IsSampled() {
+			sampled = 1
+		}
+		req.Header.Set(cloudTraceHeader, fmt.Sprintf("%s/%d;o=%d", sc.TraceID(), binary.BigEndian.Uint64(spanID[:]), sampled))
+	}
+}
@@ -0,0 +1,79 @@
+package multipartclient
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceHeaders(t *testing.T) {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:     trace.SpanID{0, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: trace.FlagsSampled,
+	})
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		compat *S3Compatibility
+		want   map[string]string
+	}{
+		{
+			name: "Cloud Storage",
+			ctx:  trace.ContextWithSpanContext(context.Background(), sc),
+			want: map[string]string{
+				"traceparent":           "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+				"X-Cloud-Trace-Context": "4bf92f3577b34da6a3ce929d0e0e4736/67667974448284343;o=1",
+			},
+		},
+		{
+			name: "Not sampled",
+			ctx:  trace.ContextWithSpanContext(context.Background(), sc.WithTraceFlags(0)),
+			want: map[string]string{
+				"traceparent":           "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+				"X-Cloud-Trace-Context": "4bf92f3577b34da6a3ce929d0e0e4736/67667974448284343;o=0",
+			},
+		},
+		{
+			name:   "S3-compatible",
+			ctx:    trace.ContextWithSpanContext(context.Background(), sc),
+			compat: &S3Compatibility{Endpoint: "http://localhost:9000"},
+			want: map[string]string{
+				"traceparent":           "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+				"X-Cloud-Trace-Context": "",
+			},
+		},
+		{
+			name: "No span",
+			ctx:  context.Background(),
+			want: map[string]string{
+				"traceparent":           "",
+				"X-Cloud-Trace-Context": "",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got http.Header
+			trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+				got = req.Header
+				return &http.Response{StatusCode: http.StatusNoContent, Status: "No Content", Body: http.NoBody}, nil
+			})
+			var opts []Option
+			if tt.compat != nil {
+				opts = append(opts, WithS3Compatibility(*tt.compat))
+			}
+			mpuc := New(&http.Client{Transport: trans}, opts...)
+			if err := mpuc.AbortMultipartUpload(tt.ctx, &AbortMultipartUploadRequest{Bucket: "bucket1", Key: "file1.txt", UploadID: "my-upload-id"}); err != nil {
+				t.Fatal(err)
+			}
+			for header, want := range tt.want {
+				if got := got.Get(header); got != want {
+					t.Errorf("got %s header %q, want %q", header, got, want)
+				}
+			}
+		})
+	}
+}
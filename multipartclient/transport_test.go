@@ -0,0 +1,153 @@
+package multipartclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+)
+
+// writeCountingConn counts the writes to a connection.
+type writeCountingConn struct {
+	net.Conn
+	writes *atomic.Int64
+}
+
+func (c writeCountingConn) Write(p []byte) (int, error) {
+	c.writes.Add(1)
+	return c.Conn.Write(p)
+}
+
+func TestWithCopyBufferSize(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 64<<10)
+	var got []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		if got, err = io.ReadAll(r.Body); err != nil {
+			t.Error(err)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	var writes atomic.Int64
+	base := &http.Transport{DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+		return writeCountingConn{conn, &writes}, err
+	}}
+	mpuc := New(&http.Client{Transport: base}, WithCopyBufferSize(256<<10), WithEndpoint(srv.URL))
+	if base.DialContext == nil || mpuc.hc.Transport == http.RoundTripper(base) {
+		t.Fatal("the client's transport was changed rather than cloned")
+	}
+
+	bodies := []struct {
+		name string
+		body func() (io.ReadCloser, error)
+	}{
+		{
+			name: "SectionBody",
+			body: func() (io.ReadCloser, error) { return NewSectionBody(bytes.NewReader(data), 0, int64(len(data))), nil },
+		},
+		{
+			// Copied through the buffer, since writeCountingConn has no
+			// ReadFrom to send it with sendfile(2).
+			name: "FileBody",
+			body: func() (io.ReadCloser, error) { return OpenFileBody(writeTempFile(t, data), 0, int64(len(data))) },
+		},
+	}
+	for _, tc := range bodies {
+		t.Run(tc.name, func(t *testing.T) {
+			body, err := tc.body()
+			if err != nil {
+				t.Fatal(err)
+			}
+			writes.Store(0)
+			_, err = mpuc.UploadObjectPart(context.Background(), &UploadObjectPartRequest{
+				Bucket:     "bucket1",
+				Key:        "object.txt",
+				PartNumber: 1,
+				UploadID:   "my-upload-id",
+				Body:       body,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("server got %d bytes that differ from the part", len(got))
+			}
+			// The headers, then 1 MiB in 256 KiB writes rather than 32 KiB.
+			if n := writes.Load(); n > 5 {
+				t.Errorf("got %d writes to the connection, want at most 5", n)
+			}
+		})
+	}
+}
+
+func TestWithDialer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	t.Cleanup(srv.Close)
+
+	var controls atomic.Int64
+	d := &net.Dialer{
+		Control: func(network, address string, c syscall.RawConn) error {
+			controls.Add(1)
+			return nil
+		},
+	}
+	// The copy buffer wraps the dialer's connections.
+	mpuc := New(nil, WithDialer(d), WithCopyBufferSize(64<<10), WithEndpoint(srv.URL))
+	for i := 1; i <= 2; i++ {
+		_, err := mpuc.UploadObjectPart(context.Background(), &UploadObjectPartRequest{
+			Bucket:     "bucket1",
+			Key:        "object.txt",
+			PartNumber: i,
+			UploadID:   "my-upload-id",
+			Body:       NewSectionBody(bytes.NewReader([]byte("data")), 0, 4),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The second part reuses the first's connection.
+	if n := controls.Load(); n != 1 {
+		t.Errorf("Control called %d times, want 1", n)
+	}
+}
+
+// BenchmarkCopyBufferSize uploads parts to a local plain-text server through
+// copy buffers of several sizes, where 0 is the net package's 32 KiB.
+func BenchmarkCopyBufferSize(b *testing.B) {
+	const partSize = 16 << 20
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	b.Cleanup(srv.Close)
+	data := make([]byte, partSize)
+
+	for _, size := range []int{0, 128 << 10, 512 << 10, 2 << 20} {
+		b.Run(fmt.Sprintf("%d KiB", size>>10), func(b *testing.B) {
+			mpuc := New(srv.Client(), WithCopyBufferSize(size), WithEndpoint(srv.URL))
+			b.SetBytes(partSize)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, err := mpuc.UploadObjectPart(context.Background(), &UploadObjectPartRequest{
+					Bucket:     "bucket",
+					Key:        "object",
+					PartNumber: 1,
+					UploadID:   fmt.Sprint(i),
+					Body:       NewSectionBody(bytes.NewReader(data), 0, partSize),
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
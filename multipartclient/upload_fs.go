@@ -0,0 +1,136 @@
+package multipartclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"sync"
+)
+
+const (
+	// defaultFSPartSize is the part size of UploadFS.
+	defaultFSPartSize = 16 << 20
+	// defaultFSConcurrency is the number of files UploadFS uploads at once.
+	defaultFSConcurrency = 4
+)
+
+// UploadFSOptions configures UploadFSWithOptions.
+type UploadFSOptions struct {
+	// PartSize is the size of every part of a file but the last, as for
+	// UploaderOptions.PartSize. Files that don't implement io.ReaderAt are
+	// read a part at a time, so it is also the memory used per such file
+	// being uploaded. Defaults to 16 MiB.
+	PartSize int64
+	// Concurrency is the number of files uploaded at once. Defaults to 4.
+	Concurrency int
+	// NewChunker, if set, splits every file into parts, as for
+	// UploaderOptions.NewChunker.
+	NewChunker func(r io.Reader) (Chunker, error)
+}
+
+// UploadedFile is a file uploaded by UploadFS.
+type UploadedFile struct {
+	// Path is the name of the file in the uploaded file system.
+	Path   string
+	Result *CompleteMultipartUploadResult
+}
+
+// UploadFS uploads every regular file of fsys, such as an embed.FS, a
+// *zip.Reader or an os.DirFS, to bucket as an object named by the file's
+// path under prefix, with the default UploadFSOptions.
+func (mpuc *MultipartClient) UploadFS(ctx context.Context, bucket, prefix string, fsys fs.FS) ([]UploadedFile, error) {
+	return mpuc.UploadFSWithOptions(ctx, bucket, prefix, fsys, nil)
+}
+
+// UploadFSWithOptions is like UploadFS with options. Each file is uploaded
+// by an Uploader with its own multipart upload, so its parts are planned as
+// PlanParts does and their checksums verified. If a file fails, the files not
+// yet uploaded are skipped, the failed file's upload is aborted and the files
+// uploaded so far are returned, sorted by path, with the error.
+func (mpuc *MultipartClient) UploadFSWithOptions(ctx context.Context, bucket, prefix string, fsys fs.FS, opts *UploadFSOptions) ([]UploadedFile, error) {
+	partSize, concurrency := int64(defaultFSPartSize), defaultFSConcurrency
+	if opts != nil && opts.PartSize > 0 {
+		partSize = opts.PartSize
+	}
+	if opts != nil && opts.Concurrency > 0 {
+		concurrency = opts.Concurrency
+	}
+	var newChunker func(io.Reader) (Chunker, error)
+	if opts != nil {
+		newChunker = opts.NewChunker
+	}
+
+	// Each file's parts are uploaded one at a time, as files are uploaded
+	// concurrently.
+	u := NewUploader(mpuc, &UploaderOptions{PartSize: partSize, Concurrency: 1, NewChunker: newChunker})
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	var (
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, concurrency)
+		mu       sync.Mutex
+		uploaded []UploadedFile
+	)
+	walkErr := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			req := &InitiateMultipartUploadRequest{Bucket: bucket, Key: path.Join(prefix, p)}
+			result, err := uploadFile(ctx, u, fsys, p, req)
+			if err != nil {
+				cancel(fmt.Errorf("failed to upload %s: %w", p, err))
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			uploaded = append(uploaded, UploadedFile{Path: p, Result: result})
+		}()
+		return nil
+	})
+	wg.Wait()
+
+	sort.Slice(uploaded, func(i, j int) bool {
+		return uploaded[i].Path < uploaded[j].Path
+	})
+	if ctx.Err() != nil {
+		return uploaded, context.Cause(ctx)
+	}
+	return uploaded, walkErr
+}
+
+// uploadFile uploads the file name of fsys as the object described by req
+// with u. Unless u has a NewChunker, files that implement io.ReaderAt, such
+// as those of os.DirFS, are uploaded in place; others are read a part at a
+// time.
+func uploadFile(ctx context.Context, u *Uploader, fsys fs.FS, name string, req *InitiateMultipartUploadRequest) (*CompleteMultipartUploadResult, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if ra, ok := f.(io.ReaderAt); ok && u.newChunker == nil {
+		info, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		return u.uploadSection(ctx, req, ra, 0, info.Size())
+	}
+	return u.Upload(ctx, req, f)
+}
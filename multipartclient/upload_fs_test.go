@@ -0,0 +1,204 @@
+package multipartclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"net/http"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multipartclienttest"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multiparttest"
+)
+
+// bigFileData is the data of a file of three parts of MinPartSize bytes, the
+// last one short.
+var bigFileData = bytes.Repeat([]byte("0123456789abcdef"), (2*MinPartSize+MinPartSize/2)/16)
+
+var testFS = fstest.MapFS{
+	"a.txt":       {Data: []byte("hello multipart world")},
+	"big.bin":     {Data: bigFileData},
+	"dir/b.txt":   {Data: []byte("bb")},
+	"empty.txt":   {Data: []byte{}},
+	"dir/link":    {Data: []byte("a.txt"), Mode: fs.ModeSymlink},
+	"dir/sub/c.x": {Data: []byte("c")},
+}
+
+func TestUploadFS(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	mpuc := New(srv.Client())
+
+	uploaded, err := mpuc.UploadFSWithOptions(context.Background(), "bucket1", "backup", testFS, &UploadFSOptions{PartSize: MinPartSize, Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	for _, f := range uploaded {
+		paths = append(paths, f.Path)
+		if f.Result.Key != "backup/"+f.Path {
+			t.Errorf("got key %q for %s, want %q", f.Result.Key, f.Path, "backup/"+f.Path)
+		}
+	}
+	if diff := cmp.Diff([]string{"a.txt", "big.bin", "dir/b.txt", "dir/sub/c.x", "empty.txt"}, paths); diff != "" {
+		t.Errorf("unexpected diff for uploaded paths (-want, +got):\n%s", diff)
+	}
+	for name, file := range testFS {
+		got, ok := srv.Object("bucket1", "backup/"+name)
+		if file.Mode&fs.ModeSymlink != 0 {
+			if ok {
+				t.Errorf("symlink %s was uploaded", name)
+			}
+			continue
+		}
+		if !ok || string(got) != string(file.Data) {
+			t.Errorf("got object for %s %q (exists %v), want %q", name, got, ok, file.Data)
+		}
+	}
+}
+
+func TestUploadFSNoPrefix(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	mpuc := New(srv.Client())
+	fsys := fstest.MapFS{"dir/b.txt": {Data: []byte("bb")}}
+	if _, err := mpuc.UploadFS(context.Background(), "bucket1", "", fsys); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := srv.Object("bucket1", "dir/b.txt"); !ok {
+		t.Error("object dir/b.txt was not created")
+	}
+}
+
+func TestUploadFSFailure(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	ft := multipartclienttest.NewFaultTransport(srv.Transport(), &multipartclienttest.Fault{
+		Match: func(req *http.Request) bool {
+			return req.Method == http.MethodPut && req.URL.Path == "/bucket1/dir/b.txt"
+		},
+		Error: func() *http.Response {
+			return multipartclienttest.ErrorResponse(http.StatusForbidden, "AccessDenied", "Access denied.")
+		},
+	})
+	mpuc := New(ft.Client())
+
+	uploaded, err := mpuc.UploadFSWithOptions(context.Background(), "bucket1", "", testFS, &UploadFSOptions{PartSize: MinPartSize, Concurrency: 1})
+	if err == nil || !strings.Contains(err.Error(), "failed to upload dir/b.txt") {
+		t.Fatalf("got error %v, want the failure of dir/b.txt", err)
+	}
+	// Files are walked in lexical order and uploaded one at a time.
+	if len(uploaded) != 2 || uploaded[0].Path != "a.txt" || uploaded[1].Path != "big.bin" {
+		t.Errorf("got uploaded files %+v, want only a.txt and big.bin", uploaded)
+	}
+	if _, ok := srv.Object("bucket1", "empty.txt"); ok {
+		t.Error("empty.txt was uploaded after the failure")
+	}
+	if uploads := srv.Uploads(); len(uploads) != 0 {
+		t.Errorf("got uploads %v left in progress, want them aborted", uploads)
+	}
+}
+
+func TestUploadFSNewChunker(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	mpuc := New(srv.Client())
+	var (
+		mu    sync.Mutex
+		calls int
+	)
+	opts := &UploadFSOptions{NewChunker: func(r io.Reader) (Chunker, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		return NewFixedSizeChunker(r, MinPartSize)
+	}}
+	if _, err := mpuc.UploadFSWithOptions(context.Background(), "bucket1", "", testFS, opts); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 5 {
+		t.Errorf("got %d chunkers, want one for each of the 5 files", calls)
+	}
+	if got, _ := srv.Object("bucket1", "big.bin"); !bytes.Equal(got, bigFileData) {
+		t.Errorf("got object big.bin of %d bytes, want %d", len(got), len(bigFileData))
+	}
+}
+
+// streamFS hides the io.ReaderAt of the files of an fs.FS.
+type streamFS struct {
+	fs.FS
+}
+
+func (s streamFS) Open(name string) (fs.File, error) {
+	f, err := s.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return struct{ fs.File }{f}, nil
+}
+
+func (s streamFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(s.FS, name)
+}
+
+func TestUploadFSStreamedFiles(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	mpuc := New(srv.Client())
+	fsys := fstest.MapFS{
+		"a.txt":     {Data: []byte("hello multipart world")},
+		"big.bin":   {Data: bigFileData},
+		"empty.txt": {Data: []byte{}},
+	}
+	if _, err := mpuc.UploadFSWithOptions(context.Background(), "bucket1", "", streamFS{fsys}, &UploadFSOptions{PartSize: MinPartSize}); err != nil {
+		t.Fatal(err)
+	}
+	for name, file := range fsys {
+		if got, ok := srv.Object("bucket1", name); !ok || string(got) != string(file.Data) {
+			t.Errorf("got object for %s %q (exists %v), want %q", name, got, ok, file.Data)
+		}
+	}
+}
+
+func TestUploadFSProfileLabels(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	var (
+		mu    sync.Mutex
+		parts []partLabels
+	)
+	hc := &http.Client{Transport: multipartclienttest.TransportFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodPut {
+			mu.Lock()
+			parts = append(parts, labelsOf(req))
+			mu.Unlock()
+		}
+		return srv.Transport().RoundTrip(req)
+	})}
+	mpuc := New(hc)
+
+	fsys := fstest.MapFS{"big.bin": {Data: bigFileData}}
+	if _, err := mpuc.UploadFSWithOptions(context.Background(), "bucket1", "", fsys, &UploadFSOptions{PartSize: MinPartSize}); err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 3 {
+		t.Fatalf("got %d parts uploaded, want 3", len(parts))
+	}
+	for _, p := range parts {
+		if p.bucket != "bucket1" || p.labelUploadID != p.uploadID {
+			t.Errorf("got labels bucket=%q upload_id=%q for a part of upload %q, want bucket1 and the upload", p.bucket, p.labelUploadID, p.uploadID)
+		}
+	}
+}
+
+// partLabels are the profiler labels of a part request, and its upload.
+type partLabels struct {
+	bucket, labelUploadID, uploadID string
+}
+
+func labelsOf(req *http.Request) partLabels {
+	bucket, _ := pprof.Label(req.Context(), "bucket")
+	uploadID, _ := pprof.Label(req.Context(), "upload_id")
+	return partLabels{bucket: bucket, labelUploadID: uploadID, uploadID: req.URL.Query().Get("uploadId")}
+}
@@ -0,0 +1,81 @@
+package multipartclient
+
+import (
+	"context"
+	"time"
+)
+
+type uploadLabelKey struct{}
+
+// ContextWithUploadLabel returns a copy of ctx that labels the part uploads
+// made with it for UploadMetrics, e.g. with the name of the workload or
+// pipeline. Keep the number of distinct labels small: metrics backends
+// usually keep a series per label.
+func ContextWithUploadLabel(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, uploadLabelKey{}, label)
+}
+
+// UploadLabelFromContext returns the label set by ContextWithUploadLabel, or
+// "" if there is none.
+func UploadLabelFromContext(ctx context.Context) string {
+	label, _ := ctx.Value(uploadLabelKey{}).(string)
+	return label
+}
+
+// PartStats describes one call to UploadObjectPart.
+type PartStats struct {
+	// Bytes is the request body bytes sent over all attempts.
+	Bytes int64
+	// Duration is the wall time of the call, including retries.
+	Duration time.Duration
+	// Attempts is the number of requests sent for the part.
+	Attempts int
+	// Failed reports whether the call returned an error.
+	Failed bool
+}
+
+// UploadMetrics can be implemented by a Metrics to also receive one
+// observation per part upload, keyed by the label from
+// ContextWithUploadLabel, from which throughput, part latency percentiles
+// and retry ratios can be compared between workloads.
+type UploadMetrics interface {
+	PartDone(label string, stats PartStats)
+}
+
+type partStatsKey struct{}
+
+// partStats accumulates the attempts of one UploadObjectPart call.
+type partStats struct {
+	bytes    int64
+	attempts int
+}
+
+// trackPartStats returns ctx carrying a partStats for the part upload being
+// started.
+func trackPartStats(ctx context.Context) (context.Context, *partStats) {
+	stats := &partStats{}
+	return context.WithValue(ctx, partStatsKey{}, stats), stats
+}
+
+// recordPartAttempt adds an attempt sending n bytes to the partStats in ctx.
+func recordPartAttempt(ctx context.Context, n int64) {
+	if stats, ok := ctx.Value(partStatsKey{}).(*partStats); ok {
+		stats.attempts++
+		stats.bytes += n
+	}
+}
+
+// reportPartDone passes the stats collected for a part upload started at start
+// to the UploadMetrics, if the metrics implement it.
+func (mpuc *MultipartClient) reportPartDone(ctx context.Context, stats *partStats, start time.Time, err error) {
+	uploadMetrics, ok := mpuc.metrics.(UploadMetrics)
+	if !ok {
+		return
+	}
+	uploadMetrics.PartDone(UploadLabelFromContext(ctx), PartStats{
+		Bytes:    stats.bytes,
+		Duration: mpuc.since(start),
+		Attempts: stats.attempts,
+		Failed:   err != nil,
+	})
+}
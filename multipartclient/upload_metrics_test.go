@@ -0,0 +1,91 @@
+package multipartclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+)
+
+type uploadRecordingMetrics struct {
+	*recordingMetrics
+	mu     sync.Mutex
+	labels []string
+	parts  []PartStats
+}
+
+func (m *uploadRecordingMetrics) PartDone(label string, stats PartStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.labels = append(m.labels, label)
+	m.parts = append(m.parts, stats)
+}
+
+func TestUploadMetrics(t *testing.T) {
+	const contents = "part contents"
+	uploads := 0
+	trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+		if _, err := io.Copy(io.Discard, req.Body); err != nil {
+			t.Fatal(err)
+		}
+		uploads++
+		resp := &http.Response{StatusCode: http.StatusOK, Status: "OK", Header: http.Header{}, Body: http.NoBody}
+		switch uploads {
+		case 1:
+			// Report a corrupt part to cause a retry.
+			gcshash.Sums{CRC32C: 1, HasCRC32C: true}.SetHeader(resp.Header)
+		case 3:
+			resp.StatusCode, resp.Status = http.StatusServiceUnavailable, "Service Unavailable"
+		}
+		return resp, nil
+	})
+
+	metrics := &uploadRecordingMetrics{recordingMetrics: newRecordingMetrics()}
+	mpuc := New(&http.Client{Transport: trans}, WithMetrics(metrics))
+	ctx := ContextWithUploadLabel(context.Background(), "nightly-backup")
+	if _, err := mpuc.UploadObjectPart(ctx, &UploadObjectPartRequest{
+		Bucket:          "bucket1",
+		Key:             "object.txt",
+		PartNumber:      1,
+		UploadID:        "my-upload-id",
+		Body:            &seekableBody{Reader: strings.NewReader(contents)},
+		VerifyChecksums: true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mpuc.UploadObjectPart(context.Background(), &UploadObjectPartRequest{
+		Bucket:     "bucket1",
+		Key:        "object.txt",
+		PartNumber: 2,
+		UploadID:   "my-upload-id",
+		Body:       toBody(contents),
+	}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if diff := cmp.Diff([]string{"nightly-backup", ""}, metrics.labels); diff != "" {
+		t.Errorf("unexpected diff for labels: (-want, +got):\n%s", diff)
+	}
+	wantParts := []PartStats{
+		{Bytes: 2 * int64(len(contents)), Attempts: 2},
+		{Bytes: int64(len(contents)), Attempts: 1, Failed: true},
+	}
+	if diff := cmp.Diff(wantParts, metrics.parts, cmpopts.IgnoreFields(PartStats{}, "Duration")); diff != "" {
+		t.Errorf("unexpected diff for part stats: (-want, +got):\n%s", diff)
+	}
+}
+
+func TestUploadLabelFromContext(t *testing.T) {
+	if got := UploadLabelFromContext(context.Background()); got != "" {
+		t.Errorf("got label %q for unlabelled context, want empty", got)
+	}
+	if got := UploadLabelFromContext(ContextWithUploadLabel(context.Background(), "a")); got != "a" {
+		t.Errorf("got label %q, want %q", got, "a")
+	}
+}
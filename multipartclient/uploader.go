@@ -0,0 +1,332 @@
+package multipartclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"runtime/pprof"
+)
+
+// defaultUploaderConcurrency is the number of parts an Uploader uploads at
+// once.
+const defaultUploaderConcurrency = 4
+
+// UploaderOptions configures NewUploader.
+type UploaderOptions struct {
+	// PartSize is the size of every part but the last. Data of unknown size
+	// is read a part at a time, so it is also the memory used per part being
+	// uploaded, and it must be between MinPartSize and MaxPartSize. For data
+	// of known size it is raised as PlanParts does when the data doesn't fit
+	// in MaxParts parts. Defaults to DefaultPlanPartSize.
+	PartSize int64
+	// Concurrency is the number of parts uploaded at once. Defaults to 4.
+	Concurrency int
+	// NewChunker, if set, returns the Chunker that splits the data of each
+	// upload into parts, such as one of NewContentDefinedChunker. All data is
+	// then read a part at a time, even if its size is known. Its chunks but
+	// the last must be between MinPartSize and MaxPartSize bytes. Defaults to
+	// NewFixedSizeChunker in parts of PartSize bytes.
+	NewChunker func(r io.Reader) (Chunker, error)
+	// Checkpointer, if set, saves the checkpoint of each upload as its parts
+	// are uploaded. An upload that fails is then left in progress rather than
+	// aborted, and uploading the same object again with the same part size
+	// resumes it, skipping the parts the server holds whose data has the
+	// checksums recorded when they were uploaded. Parts whose data changed
+	// are uploaded again. Other uploads and those that no longer exist start
+	// over.
+	// Uploads that are never resumed are deleted by AbortMultipartUpload or
+	// by a lifecycle rule of the bucket.
+	Checkpointer Checkpointer
+	// Progress, if set, is called as the data of each upload is sent, with
+	// the bytes of the object sent so far, its size, or -1 if it is read a
+	// part at a time, and the number of the part whose progress is reported.
+	// Parts skipped when an upload is resumed count as sent. Calls are
+	// serialized, and may come from the goroutines of the HTTP transport.
+	Progress ProgressFunc
+}
+
+// Uploader uploads objects with multipart uploads: it initiates the upload,
+// splits the data into parts, uploads them concurrently, verifying their
+// checksums, and completes the upload, or aborts it if any step fails.
+//
+//	u := multipartclient.NewUploader(mpuc, &multipartclient.UploaderOptions{Concurrency: 8})
+//	result, err := u.UploadFile(ctx, &multipartclient.InitiateMultipartUploadRequest{
+//		Bucket: "bucket1",
+//		Key:    "backup.tar",
+//	}, "/var/backups/backup.tar")
+//
+// An Uploader is safe for concurrent use, and each upload has its own
+// Concurrency parts in flight.
+type Uploader struct {
+	mpuc         *MultipartClient
+	partSize     int64
+	concurrency  int
+	newChunker   func(io.Reader) (Chunker, error)
+	checkpointer Checkpointer
+	progress     ProgressFunc
+}
+
+// NewUploader returns an Uploader that uploads with mpuc, with the options
+// opts, which may be nil.
+func NewUploader(mpuc *MultipartClient, opts *UploaderOptions) *Uploader {
+	u := &Uploader{mpuc: mpuc, partSize: DefaultPlanPartSize, concurrency: defaultUploaderConcurrency}
+	if opts != nil && opts.PartSize > 0 {
+		u.partSize = opts.PartSize
+	}
+	if opts != nil && opts.Concurrency > 0 {
+		u.concurrency = opts.Concurrency
+	}
+	if opts != nil {
+		u.newChunker = opts.NewChunker
+		u.checkpointer = opts.Checkpointer
+		u.progress = opts.Progress
+	}
+	return u
+}
+
+// Upload uploads the data of r, from its current position to its end, as the
+// object described by req. Unless the Uploader has a NewChunker, if r
+// implements io.ReaderAt and io.Seeker, as an *os.File or a *bytes.Reader
+// does, its parts are read in place, so uploading costs no memory beyond the
+// HTTP transport's, and r is left at its end. Other readers are read a part
+// at a time.
+func (u *Uploader) Upload(ctx context.Context, req *InitiateMultipartUploadRequest, r io.Reader) (*CompleteMultipartUploadResult, error) {
+	if ra, ok := r.(interface {
+		io.ReaderAt
+		io.Seeker
+	}); ok && u.newChunker == nil {
+		start, err := ra.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		end, err := ra.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, err
+		}
+		return u.uploadSection(ctx, req, ra, start, end-start)
+	}
+	chunker, err := u.chunker(r)
+	if err != nil {
+		return nil, err
+	}
+	return u.upload(ctx, req, u.partSize, -1, chunkerParts(chunker))
+}
+
+// chunker returns the Chunker that splits r into parts.
+func (u *Uploader) chunker(r io.Reader) (Chunker, error) {
+	if u.newChunker != nil {
+		return u.newChunker(r)
+	}
+	if u.partSize < MinPartSize || u.partSize > MaxPartSize {
+		return nil, fmt.Errorf("part size must be between %d and %d bytes, got %d", int64(MinPartSize), int64(MaxPartSize), u.partSize)
+	}
+	if u.partSize > math.MaxInt {
+		return nil, fmt.Errorf("part size %d is too large to read into memory", u.partSize)
+	}
+	return NewFixedSizeChunker(r, int(u.partSize))
+}
+
+// UploadFile uploads the file name as the object described by req, reading
+// its parts in place unless the Uploader has a NewChunker.
+func (u *Uploader) UploadFile(ctx context.Context, req *InitiateMultipartUploadRequest, name string) (*CompleteMultipartUploadResult, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return u.Upload(ctx, req, f)
+}
+
+// uploadSection uploads the size bytes of r from start as the object
+// described by req, reading its parts in place.
+func (u *Uploader) uploadSection(ctx context.Context, req *InitiateMultipartUploadRequest, r io.ReaderAt, start, size int64) (*CompleteMultipartUploadResult, error) {
+	plan, err := PlanParts(size, &PartPlanOptions{PartSize: u.partSize})
+	if err != nil {
+		return nil, err
+	}
+	return u.upload(ctx, req, plan.PartSize, size, planParts(r, start, plan))
+}
+
+// partBodies returns the body of each part in turn, and io.EOF after the
+// last.
+type partBodies func() (io.ReadCloser, error)
+
+// chunkerParts returns the bodies of the chunks of chunker.
+func chunkerParts(chunker Chunker) partBodies {
+	return func() (io.ReadCloser, error) {
+		chunk, err := chunker.Next()
+		if err != nil {
+			return nil, err
+		}
+		return NewSectionBody(bytes.NewReader(chunk.Data), 0, int64(len(chunk.Data))), nil
+	}
+}
+
+// planParts returns the bodies of the parts of plan, read from r starting at
+// start.
+func planParts(r io.ReaderAt, start int64, plan *PartPlan) partBodies {
+	i := 0
+	return func() (io.ReadCloser, error) {
+		if i == len(plan.Parts) {
+			return nil, io.EOF
+		}
+		part := plan.Parts[i]
+		i++
+		return NewSectionBody(r, start+part.Offset, part.Length), nil
+	}
+}
+
+// upload uploads the parts returned by next, of partSize bytes but the last
+// and size bytes in all, or -1 if unknown, as the object described by req,
+// aborting the upload if any step fails and there is no Checkpointer.
+func (u *Uploader) upload(ctx context.Context, req *InitiateMultipartUploadRequest, partSize, size int64, next partBodies) (*CompleteMultipartUploadResult, error) {
+	s, err := u.session(ctx, req, partSize)
+	if err != nil {
+		return nil, err
+	}
+	var result *CompleteMultipartUploadResult
+	pprof.Do(ctx, UploadLabels(req.Bucket, s.ID()), func(ctx context.Context) {
+		result, err = u.uploadParts(ctx, s, next, newObjectProgress(u.progress, size))
+	})
+	if err != nil {
+		if u.checkpointer != nil {
+			// Leave the upload to be resumed.
+			return nil, err
+		}
+		// Abort even if ctx was cancelled so the uploaded parts are not
+		// orphaned.
+		if abortErr := s.Abort(context.WithoutCancel(ctx)); abortErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to abort upload %s: %w", s.ID(), abortErr))
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+// session returns the session of an upload of req in parts of partSize
+// bytes: the one resumed from the upload's checkpoint, if any, or a new one.
+func (u *Uploader) session(ctx context.Context, req *InitiateMultipartUploadRequest, partSize int64) (*UploadSession, error) {
+	if u.checkpointer == nil {
+		return u.newSession(ctx, req, partSize)
+	}
+	cp, err := u.checkpointer.Load(ctx, req.Bucket, req.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+	if cp != nil && cp.PartSize == partSize && cp.UploadID != "" {
+		s, err := u.mpuc.ResumeSession(ctx, cp)
+		var apiErr *Error
+		switch {
+		case err == nil:
+			return s, s.SetCheckpointer(ctx, u.checkpointer)
+		case !errors.As(err, &apiErr) || apiErr.Code != "NoSuchUpload":
+			return nil, fmt.Errorf("failed to resume upload %s: %w", cp.UploadID, err)
+		}
+	} else if cp != nil && cp.UploadID != "" {
+		// The parts of the upload don't match the data, so delete them.
+		// Failing to is no reason to fail the new upload.
+		_ = u.mpuc.Bucket(cp.Bucket).Object(cp.Key).Upload(cp.UploadID).Abort(ctx)
+	}
+	s, err := u.newSession(ctx, req, partSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.SetCheckpointer(ctx, u.checkpointer); err != nil {
+		// The upload couldn't be resumed, so don't leave it behind.
+		if abortErr := s.Abort(context.WithoutCancel(ctx)); abortErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to abort upload %s: %w", s.ID(), abortErr))
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// newSession initiates the upload req in parts of partSize bytes.
+func (u *Uploader) newSession(ctx context.Context, req *InitiateMultipartUploadRequest, partSize int64) (*UploadSession, error) {
+	s, err := u.mpuc.NewSession(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	s.partSize = partSize
+	return s, nil
+}
+
+// uploadParts uploads the parts returned by next to s, at most concurrency
+// at a time, reporting their progress to progress if not nil, and completes
+// it. The first part that fails cancels the others.
+func (u *Uploader) uploadParts(ctx context.Context, s *UploadSession, next partBodies, progress *objectProgress) (*CompleteMultipartUploadResult, error) {
+	sched := newPartScheduler(ctx, u.concurrency)
+	var (
+		partNumber int
+		nextErr    error
+	)
+	for nextErr == nil {
+		if err := sched.acquire(); err != nil {
+			nextErr = err
+			continue
+		}
+		body, err := next()
+		if errors.Is(err, io.EOF) {
+			sched.release()
+			break
+		}
+		if err != nil {
+			sched.release()
+			nextErr = err
+			continue
+		}
+		partNumber++
+		if partNumber > MaxParts {
+			body.Close()
+			sched.release()
+			if u.newChunker != nil {
+				// The chunker, not partSize, sets the part boundaries.
+				nextErr = fmt.Errorf("the chunker cut the data into more than %d parts", MaxParts)
+			} else {
+				nextErr = fmt.Errorf("data doesn't fit in %d parts of %d bytes", MaxParts, u.partSize)
+			}
+			continue
+		}
+		// A part uploaded before the upload was resumed is skipped unless
+		// the data changed since.
+		match, err := s.partMatches(partNumber, body)
+		if err != nil {
+			body.Close()
+			sched.release()
+			nextErr = fmt.Errorf("failed to read part %d: %w", partNumber, err)
+			continue
+		}
+		if match {
+			if sb, ok := body.(sizedBody); ok && progress != nil {
+				if n, err := sb.remaining(); err == nil && n > 0 {
+					progress.part()(n, n, partNumber)
+				}
+			}
+			body.Close()
+			sched.release()
+			continue
+		}
+		partNumber := partNumber
+		sched.start("upload", partNumber, func(ctx context.Context) error {
+			_, err := s.uploadPart(ctx, partNumber, body, progress.part())
+			return err
+		})
+	}
+	if err := sched.wait(); err != nil {
+		return nil, err
+	}
+	if nextErr != nil {
+		return nil, nextErr
+	}
+	if partNumber == 0 && !s.HasPart(1) {
+		// An empty object is uploaded as one empty part.
+		if _, err := s.UploadPart(ctx, 1, NewSectionBody(bytes.NewReader(nil), 0, 0)); err != nil {
+			return nil, fmt.Errorf("failed to upload part 1: %w", err)
+		}
+	}
+	return s.Complete(ctx)
+}
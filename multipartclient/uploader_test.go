@@ -0,0 +1,372 @@
+package multipartclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/multiparttest"
+)
+
+func TestUploader(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), (2*MinPartSize+MinPartSize/2)/16)
+	name := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		upload func(ctx context.Context, u *Uploader, req *InitiateMultipartUploadRequest) (*CompleteMultipartUploadResult, error)
+		want   []byte
+	}{
+		{
+			name: "In place",
+			upload: func(ctx context.Context, u *Uploader, req *InitiateMultipartUploadRequest) (*CompleteMultipartUploadResult, error) {
+				return u.Upload(ctx, req, bytes.NewReader(data))
+			},
+			want: data,
+		},
+		{
+			name: "In place from the current position",
+			upload: func(ctx context.Context, u *Uploader, req *InitiateMultipartUploadRequest) (*CompleteMultipartUploadResult, error) {
+				r := bytes.NewReader(data)
+				r.Seek(100, io.SeekStart)
+				return u.Upload(ctx, req, r)
+			},
+			want: data[100:],
+		},
+		{
+			name: "Streaming",
+			upload: func(ctx context.Context, u *Uploader, req *InitiateMultipartUploadRequest) (*CompleteMultipartUploadResult, error) {
+				return u.Upload(ctx, req, io.MultiReader(bytes.NewReader(data)))
+			},
+			want: data,
+		},
+		{
+			name: "Empty stream",
+			upload: func(ctx context.Context, u *Uploader, req *InitiateMultipartUploadRequest) (*CompleteMultipartUploadResult, error) {
+				return u.Upload(ctx, req, io.MultiReader(strings.NewReader("")))
+			},
+			want: []byte{},
+		},
+		{
+			name: "File",
+			upload: func(ctx context.Context, u *Uploader, req *InitiateMultipartUploadRequest) (*CompleteMultipartUploadResult, error) {
+				return u.UploadFile(ctx, req, name)
+			},
+			want: data,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := multiparttest.NewServer(t)
+			u := NewUploader(New(srv.Client()), &UploaderOptions{PartSize: MinPartSize, Concurrency: 2})
+			req := &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.bin"}
+			if _, err := tc.upload(context.Background(), u, req); err != nil {
+				t.Fatal(err)
+			}
+			got, ok := srv.Object("bucket1", "object.bin")
+			if !ok {
+				t.Fatal("object wasn't created")
+			}
+			if !bytes.Equal(got, tc.want) {
+				t.Errorf("got object of %d bytes, want %d", len(got), len(tc.want))
+			}
+			if uploads := srv.Uploads(); len(uploads) != 0 {
+				t.Errorf("got uploads %q in progress, want none", uploads)
+			}
+		})
+	}
+}
+
+func TestUploaderNewChunker(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	var (
+		mu    sync.Mutex
+		sizes = map[int]int64{}
+	)
+	trans := srv.Transport()
+	hc := &http.Client{Transport: funcTransport(func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodPut {
+			partNumber, _ := strconv.Atoi(req.URL.Query().Get("partNumber"))
+			mu.Lock()
+			sizes[partNumber] = req.ContentLength
+			mu.Unlock()
+		}
+		return trans.RoundTrip(req)
+	})}
+	newChunker := func(r io.Reader) (Chunker, error) {
+		return NewContentDefinedChunker(r, CDCOptions{})
+	}
+	u := NewUploader(New(hc), &UploaderOptions{NewChunker: newChunker, Concurrency: 2})
+
+	// Data of known size is split by the chunker too.
+	data := randomBytes(t, 1, 40<<20)
+	if _, err := u.Upload(context.Background(), &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.bin"}, bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := srv.Object("bucket1", "object.bin"); !bytes.Equal(got, data) {
+		t.Errorf("got object of %d bytes, want %d", len(got), len(data))
+	}
+	c, err := newChunker(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]int64{}
+	for i, chunk := range readAllChunks(t, c) {
+		want[i+1] = int64(len(chunk.Data))
+	}
+	if diff := cmp.Diff(want, sizes); diff != "" {
+		t.Errorf("unexpected diff for part sizes (-want, +got):\n%s", diff)
+	}
+}
+
+// byteChunker is a Chunker of chunks of a byte that never ends.
+type byteChunker struct {
+	offset int64
+}
+
+func (c *byteChunker) Next() (*Chunk, error) {
+	c.offset++
+	return &Chunk{Offset: c.offset - 1, Data: []byte{'x'}}, nil
+}
+
+func TestUploaderNewChunkerTooManyParts(t *testing.T) {
+	hc := &http.Client{Transport: funcTransport(func(req *http.Request) (*http.Response, error) {
+		resp := xmlResponse("<InitiateMultipartUploadResult><UploadId>u1</UploadId></InitiateMultipartUploadResult>")
+		resp.Header.Set("ETag", `"etag"`)
+		return resp, nil
+	})}
+	u := NewUploader(New(hc), &UploaderOptions{
+		NewChunker:  func(io.Reader) (Chunker, error) { return &byteChunker{}, nil },
+		PartSize:    MaxPartSize,
+		Concurrency: 16,
+	})
+	_, err := u.Upload(context.Background(), &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.bin"}, strings.NewReader(""))
+	if want := "the chunker cut the data into more than 10000 parts"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("got error %v, want it to contain %q", err, want)
+	}
+	// The chunker, not PartSize, cut the data.
+	if err != nil && strings.Contains(err.Error(), strconv.FormatInt(MaxPartSize, 10)) {
+		t.Errorf("got error %v, want it not to report the part size", err)
+	}
+}
+
+// TestUploaderConcurrency checks that an Uploader has at most Concurrency
+// parts in flight, and aborts the upload when a part fails.
+func TestUploaderConcurrency(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	var (
+		mu                  sync.Mutex
+		inFlight, maxFlight int
+	)
+	trans := srv.Transport()
+	hc := &http.Client{Transport: funcTransport(func(req *http.Request) (*http.Response, error) {
+		if req.Method != http.MethodPut {
+			return trans.RoundTrip(req)
+		}
+		mu.Lock()
+		inFlight++
+		maxFlight = max(maxFlight, inFlight)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+		if req.URL.Query().Get("partNumber") == "5" {
+			return &http.Response{StatusCode: http.StatusInternalServerError, Status: "500 Internal Server Error", Body: toBody("part failed")}, nil
+		}
+		return trans.RoundTrip(req)
+	})}
+	u := NewUploader(New(hc), &UploaderOptions{PartSize: MinPartSize, Concurrency: 3})
+
+	data := make([]byte, 6*MinPartSize)
+	_, err := u.Upload(context.Background(), &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.bin"}, bytes.NewReader(data))
+	if err == nil || !strings.Contains(err.Error(), "failed to upload part 5") {
+		t.Errorf("got error %v, want part 5 to fail", err)
+	}
+	if maxFlight > 3 {
+		t.Errorf("got %d parts in flight, want at most 3", maxFlight)
+	}
+	if uploads := srv.Uploads(); len(uploads) != 0 {
+		t.Errorf("got uploads %q in progress, want the upload aborted", uploads)
+	}
+	if _, ok := srv.Object("bucket1", "object.bin"); ok {
+		t.Error("object was created")
+	}
+}
+
+func TestUploaderPartSize(t *testing.T) {
+	u := NewUploader(New(&http.Client{Transport: funcTransport(func(*http.Request) (*http.Response, error) {
+		t.Error("sent a request with an invalid part size")
+		return nil, errMock
+	})}), &UploaderOptions{PartSize: MinPartSize - 1})
+	_, err := u.Upload(context.Background(), &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.bin"}, io.MultiReader(strings.NewReader("data")))
+	if err == nil || !strings.Contains(err.Error(), "part size must be between") {
+		t.Errorf("got error %v, want one for the part size", err)
+	}
+}
+
+func TestUploaderResume(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	data := bytes.Repeat([]byte("0123456789abcdef"), 4*MinPartSize/16)
+	req := &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.bin"}
+	c := &memCheckpointer{}
+	trans := srv.Transport()
+	var parts []string
+	failPart := "3"
+	hc := &http.Client{Transport: funcTransport(func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodPut {
+			partNumber := req.URL.Query().Get("partNumber")
+			parts = append(parts, partNumber)
+			if partNumber == failPart {
+				return &http.Response{StatusCode: http.StatusInternalServerError, Status: "500 Internal Server Error", Body: toBody("part failed")}, nil
+			}
+		}
+		return trans.RoundTrip(req)
+	})}
+	u := NewUploader(New(hc), &UploaderOptions{PartSize: MinPartSize, Concurrency: 1, Checkpointer: c})
+
+	if _, err := u.Upload(context.Background(), req, bytes.NewReader(data)); err == nil {
+		t.Fatal("got no error with a failing part")
+	}
+	if uploads := srv.Uploads(); len(uploads) != 1 {
+		t.Fatalf("got uploads %q in progress, want the upload left to resume", uploads)
+	}
+	if cp, _ := c.Load(context.Background(), "bucket1", "object.bin"); cp == nil || len(cp.Parts) != 2 {
+		t.Fatalf("got checkpoint %+v, want one of 2 parts", cp)
+	}
+
+	parts, failPart = nil, ""
+	if _, err := u.Upload(context.Background(), req, io.MultiReader(bytes.NewReader(data))); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"3", "4"}; !slices.Equal(parts, want) {
+		t.Errorf("got parts %q uploaded when resuming, want %q", parts, want)
+	}
+	if got, _ := srv.Object("bucket1", "object.bin"); !bytes.Equal(got, data) {
+		t.Errorf("got object of %d bytes, want %d", len(got), len(data))
+	}
+	if uploads := srv.Uploads(); len(uploads) != 0 {
+		t.Errorf("got uploads %q in progress, want none", uploads)
+	}
+	if cp, _ := c.Load(context.Background(), "bucket1", "object.bin"); cp != nil {
+		t.Errorf("got checkpoint %+v after completing, want it deleted", cp)
+	}
+}
+
+func TestUploaderResumeChangedData(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	data := bytes.Repeat([]byte("0123456789abcdef"), 3*MinPartSize/16)
+	name := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	req := &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.bin"}
+	c := &memCheckpointer{}
+	trans := srv.Transport()
+	var parts []string
+	failPart := "3"
+	hc := &http.Client{Transport: funcTransport(func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodPut {
+			partNumber := req.URL.Query().Get("partNumber")
+			parts = append(parts, partNumber)
+			if partNumber == failPart {
+				return &http.Response{StatusCode: http.StatusInternalServerError, Status: "500 Internal Server Error", Body: toBody("part failed")}, nil
+			}
+		}
+		return trans.RoundTrip(req)
+	})}
+	u := NewUploader(New(hc), &UploaderOptions{PartSize: MinPartSize, Concurrency: 1, Checkpointer: c})
+	if _, err := u.UploadFile(context.Background(), req, name); err == nil {
+		t.Fatal("got no error with a failing part")
+	}
+
+	// The file changes in its second part before the upload is resumed.
+	copy(data[MinPartSize+10:], "changed")
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	parts, failPart = nil, ""
+	if _, err := u.UploadFile(context.Background(), req, name); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"2", "3"}; !slices.Equal(parts, want) {
+		t.Errorf("got parts %q uploaded when resuming, want %q", parts, want)
+	}
+	if got, _ := srv.Object("bucket1", "object.bin"); !bytes.Equal(got, data) {
+		t.Error("got object with data other than the changed file's")
+	}
+}
+
+func TestUploaderResumeOtherPartSize(t *testing.T) {
+	srv := multiparttest.NewServer(t)
+	ctx := context.Background()
+	req := &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.bin"}
+	s, err := New(srv.Client()).NewSession(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &memCheckpointer{}
+	c.Save(ctx, &Checkpoint{Bucket: "bucket1", Key: "object.bin", UploadID: s.ID(), PartSize: 2 * MinPartSize})
+
+	u := NewUploader(New(srv.Client()), &UploaderOptions{PartSize: MinPartSize, Checkpointer: c})
+	if _, err := u.Upload(ctx, req, strings.NewReader("data")); err != nil {
+		t.Fatal(err)
+	}
+	if uploads := srv.Uploads(); len(uploads) != 0 {
+		t.Errorf("got uploads %q in progress, want the old upload aborted", uploads)
+	}
+}
+
+// zeroReaderAt is an io.ReaderAt of size zero bytes, which takes no memory.
+type zeroReaderAt struct {
+	size int64
+}
+
+func (z zeroReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off >= z.size {
+		return 0, io.EOF
+	}
+	n := min(int64(len(p)), z.size-off)
+	clear(p[:n])
+	return int(n), nil
+}
+
+func TestPlanPartsOver4GiB(t *testing.T) {
+	const size = 12 << 30
+	plan, err := PlanParts(size, &PartPlanOptions{PartSize: MaxPartSize})
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := planParts(zeroReaderAt{size}, 0, plan)
+	var got []int64
+	for {
+		body, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := body.(*SectionBody).remaining()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, n)
+	}
+	if diff := cmp.Diff([]int64{5 << 30, 5 << 30, 2 << 30}, got); diff != "" {
+		t.Errorf("unexpected diff for part sizes (-want, +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,258 @@
+package multipartclient
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits of object names and metadata, as documented by Cloud Storage.
+const (
+	// maxKeyBytes is the longest object name.
+	maxKeyBytes = 1024
+	// maxCustomMetadataBytes bounds the keys and values of an object's
+	// custom metadata together.
+	maxCustomMetadataBytes = 8 << 10
+)
+
+// WithStrictValidation checks every request against the constraints Cloud
+// Storage documents before sending it, and returns a *ValidationError instead
+// of sending one the server would reject: malformed bucket and object names,
+// part numbers out of range, parts over 5 GiB, part lists of
+// CompleteMultipartUpload that aren't in ascending order or lack ETags,
+// malformed MD5 hashes and content types, custom metadata over 8 KiB, and
+// retention modes without retain-until times or the reverse.
+// Without it, such requests are sent and fail with the server's error, or,
+// for constraints the server doesn't enforce, succeed.
+//
+// It suits CI and staging, where an invalid request is a bug to catch before
+// it reaches production.
+func WithStrictValidation() Option {
+	return func(mpuc *MultipartClient) {
+		mpuc.strictValidation = true
+	}
+}
+
+// ValidationError is returned by operations of a client with
+// WithStrictValidation for a request that breaks a documented constraint. The
+// request isn't sent.
+type ValidationError struct {
+	// Op is the operation, such as OpUploadObjectPart.
+	Op string
+	// Field is the invalid field of the request, such as "PartNumber" or
+	// "Body.Parts[2].ETag".
+	Field string
+	// Reason describes the constraint broken.
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s request: %s %s", e.Op, e.Field, e.Reason)
+}
+
+// validate returns a *ValidationError if req, the request of op, is invalid and
+// the client has WithStrictValidation.
+func (mpuc *MultipartClient) validate(op string, req any) error {
+	if !mpuc.strictValidation {
+		return nil
+	}
+	v := validator{s3: mpuc.compat != nil}
+	switch req := req.(type) {
+	case *InitiateMultipartUploadRequest:
+		v.object("", req.Bucket, req.Key)
+		v.metadata("Metadata", req.Metadata)
+		v.contentType("ContentType", req.ContentType)
+		switch {
+		case req.RetentionMode == "" && !req.RetainUntil.IsZero():
+			v.fail("RetentionMode", "must be set with RetainUntil")
+		case req.RetentionMode != "" && req.RetainUntil.IsZero():
+			v.fail("RetainUntil", "must be set with RetentionMode")
+		}
+	case *UploadObjectPartRequest:
+		v.object("", req.Bucket, req.Key)
+		v.partNumber("PartNumber", req.PartNumber)
+		v.uploadID(req.UploadID)
+		if req.Hashes.MD5 != nil && len(req.Hashes.MD5) != 16 {
+			v.fail("Hashes.MD5", "must be 16 bytes, not %d", len(req.Hashes.MD5))
+		}
+		if sb, ok := req.Body.(sizedBody); ok {
+			if n, err := sb.remaining(); err == nil && n > MaxPartSize {
+				v.fail("Body", "must be at most 5 GiB, not %d bytes", n)
+			}
+		}
+	case *UploadPartCopyRequest:
+		v.object("", req.Bucket, req.Key)
+		v.partNumber("PartNumber", req.PartNumber)
+		v.uploadID(req.UploadID)
+		v.object("Source", req.SourceBucket, req.SourceKey)
+		if r := req.SourceRange; r != nil {
+			switch {
+			case r.Offset < 0:
+				v.fail("SourceRange.Offset", "must not be negative")
+			case r.Length <= 0:
+				v.fail("SourceRange.Length", "must be positive")
+			case r.Length > MaxPartSize:
+				v.fail("SourceRange.Length", "must be at most 5 GiB, not %d bytes", r.Length)
+			}
+		}
+	case *CompleteMultipartUploadRequest:
+		v.object("", req.Bucket, req.Key)
+		v.uploadID(req.UploadID)
+		v.completeParts(req.Body.Parts)
+	case *AbortMultipartUploadRequest:
+		v.object("", req.Bucket, req.Key)
+		v.uploadID(req.UploadID)
+	case *ListMultipartUploadsRequest:
+		v.bucket("Bucket", req.Bucket)
+		if len(req.Prefix) > maxKeyBytes || !utf8.ValidString(req.Prefix) {
+			v.fail("Prefix", "must be valid UTF-8 of at most %d bytes", maxKeyBytes)
+		}
+		if req.MaxUploads < 0 {
+			v.fail("MaxUploads", "must not be negative")
+		}
+	case *ListObjectPartsRequest:
+		v.object("", req.Bucket, req.Key)
+		v.uploadID(req.UploadID)
+		switch {
+		case req.PartNumberMarker < 0 || req.PartNumberMarker > MaxParts:
+			v.fail("PartNumberMarker", "must be between 0 and %d, not %d", MaxParts, req.PartNumberMarker)
+		case req.MaxParts < 0:
+			v.fail("MaxParts", "must not be negative")
+		}
+	case *PatchObjectMetadataRequest:
+		v.object("", req.Bucket, req.Key)
+		v.metadataPatch(req.Patch)
+	case ObjectRef:
+		v.object("", req.Bucket, req.Key)
+	}
+	if v.err != nil {
+		v.err.Op = op
+		return v.err
+	}
+	return nil
+}
+
+// validator records the first constraint a request breaks.
+type validator struct {
+	// s3 applies the naming rules of S3-compatible servers rather than those
+	// of Cloud Storage.
+	s3  bool
+	err *ValidationError
+}
+
+func (v *validator) fail(field, format string, args ...any) {
+	if v.err == nil {
+		v.err = &ValidationError{Field: field, Reason: fmt.Sprintf(format, args...)}
+	}
+}
+
+// object checks the bucket and object names of fields prefix+"Bucket" and
+// prefix+"Key".
+func (v *validator) object(prefix, bucket, key string) {
+	v.bucket(prefix+"Bucket", bucket)
+	field := prefix + "Key"
+	switch {
+	case key == "":
+		v.fail(field, "must not be empty")
+	case len(key) > maxKeyBytes:
+		v.fail(field, "must be at most %d bytes, not %d", maxKeyBytes, len(key))
+	case !utf8.ValidString(key):
+		v.fail(field, "must be valid UTF-8")
+	case strings.ContainsAny(key, "\r\n"):
+		v.fail(field, "must not contain carriage returns or line feeds")
+	case key == "." || key == "..":
+		v.fail(field, "must not be %q", key)
+	}
+}
+
+// bucket checks the bucket name of field. Cloud Storage allows names of up to
+// 222 characters if they contain dots, and underscores, which S3 doesn't.
+func (v *validator) bucket(field, name string) {
+	maxLen := 63
+	if !v.s3 && strings.Contains(name, ".") {
+		maxLen = 222
+	}
+	if len(name) < 3 || len(name) > maxLen {
+		v.fail(field, "must be 3 to %d characters, not %d", maxLen, len(name))
+		return
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		alnum := 'a' <= c && c <= 'z' || '0' <= c && c <= '9'
+		switch {
+		case (i == 0 || i == len(name)-1) && !alnum:
+			v.fail(field, "must start and end with a lowercase letter or digit")
+			return
+		case !alnum && c != '-' && c != '.' && (c != '_' || v.s3):
+			v.fail(field, "must not contain %q", c)
+			return
+		}
+	}
+}
+
+func (v *validator) partNumber(field string, n int) {
+	if n < 1 || n > MaxParts {
+		v.fail(field, "must be between 1 and %d, not %d", MaxParts, n)
+	}
+}
+
+func (v *validator) uploadID(id string) {
+	if id == "" {
+		v.fail("UploadID", "must not be empty")
+	}
+}
+
+// completeParts checks the part list of a CompleteMultipartUpload, which the
+// server requires in ascending order of part number.
+func (v *validator) completeParts(parts []CompletePart) {
+	switch {
+	case len(parts) == 0:
+		v.fail("Body.Parts", "must not be empty")
+	case len(parts) > MaxParts:
+		v.fail("Body.Parts", "must have at most %d parts, not %d", MaxParts, len(parts))
+	}
+	for i, part := range parts {
+		field := fmt.Sprintf("Body.Parts[%d]", i)
+		v.partNumber(field+".PartNumber", part.PartNumber)
+		if i > 0 && part.PartNumber <= parts[i-1].PartNumber {
+			v.fail(field+".PartNumber", "must be greater than the part number before it, %d", parts[i-1].PartNumber)
+		}
+		if part.ETag == "" {
+			v.fail(field+".ETag", "must not be empty")
+		}
+	}
+}
+
+// metadataPatch checks the content type and the custom metadata of p.
+func (v *validator) metadataPatch(p ObjectMetadataPatch) {
+	if p.ContentType != nil {
+		v.contentType("Patch.ContentType", *p.ContentType)
+	}
+	v.metadata("Patch.Metadata", p.Metadata)
+}
+
+// contentType checks that the content type t of field, if set, is a media
+// type.
+func (v *validator) contentType(field, t string) {
+	if t == "" {
+		return
+	}
+	if _, _, err := mime.ParseMediaType(t); err != nil {
+		v.fail(field, "must be a media type: %v", err)
+	}
+}
+
+// metadata checks the custom metadata m of field.
+func (v *validator) metadata(field string, m map[string]string) {
+	size := 0
+	for k, val := range m {
+		if k == "" {
+			v.fail(field, "must not have an empty key")
+		}
+		size += len(k) + len(val)
+	}
+	if size > maxCustomMetadataBytes {
+		v.fail(field, "must be at most 8 KiB, not %d bytes", size)
+	}
+}
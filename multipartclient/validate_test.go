@@ -0,0 +1,304 @@
+package multipartclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonmseaman/gcs-xml-multipart-client/multipartclient/gcshash"
+)
+
+func TestValidate(t *testing.T) {
+	contentType := "text/plain; charset=utf-8"
+	badContentType := "text/"
+	part := func(n int, etag string) CompletePart { return CompletePart{PartNumber: n, ETag: etag} }
+	tests := []struct {
+		name string
+		op   string
+		req  any
+		s3   bool
+		// field is the invalid field, or "" if req is valid.
+		field string
+	}{
+		{
+			name: "Valid initiate",
+			op:   OpInitiateMultipartUpload,
+			req:  &InitiateMultipartUploadRequest{Bucket: "my_bucket.example.com", Key: "dir/object.txt"},
+		},
+		{
+			name:  "Bad initiate content type",
+			op:    OpInitiateMultipartUpload,
+			req:   &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt", ContentType: badContentType},
+			field: "ContentType",
+		},
+		{
+			name:  "Short bucket",
+			op:    OpInitiateMultipartUpload,
+			req:   &InitiateMultipartUploadRequest{Bucket: "ab", Key: "object.txt"},
+			field: "Bucket",
+		},
+		{
+			name:  "Uppercase bucket",
+			op:    OpInitiateMultipartUpload,
+			req:   &InitiateMultipartUploadRequest{Bucket: "Bucket1", Key: "object.txt"},
+			field: "Bucket",
+		},
+		{
+			name:  "Bucket ending in a dash",
+			op:    OpStatObject,
+			req:   ObjectRef{Bucket: "bucket-", Key: "object.txt"},
+			field: "Bucket",
+		},
+		{
+			name:  "S3 bucket with an underscore",
+			op:    OpStatObject,
+			req:   ObjectRef{Bucket: "my_bucket", Key: "object.txt"},
+			s3:    true,
+			field: "Bucket",
+		},
+		{
+			name:  "Empty key",
+			op:    OpStatObject,
+			req:   ObjectRef{Bucket: "bucket1"},
+			field: "Key",
+		},
+		{
+			name:  "Long key",
+			op:    OpStatObject,
+			req:   ObjectRef{Bucket: "bucket1", Key: strings.Repeat("a", 1025)},
+			field: "Key",
+		},
+		{
+			name:  "Key with a line feed",
+			op:    OpStatObject,
+			req:   ObjectRef{Bucket: "bucket1", Key: "object\n.txt"},
+			field: "Key",
+		},
+		{
+			name:  "Invalid UTF-8 key",
+			op:    OpStatObject,
+			req:   ObjectRef{Bucket: "bucket1", Key: "object\xff"},
+			field: "Key",
+		},
+		{
+			name: "Valid part",
+			op:   OpUploadObjectPart,
+			req:  &UploadObjectPartRequest{Bucket: "bucket1", Key: "object.txt", PartNumber: 10000, UploadID: "my-upload-id"},
+		},
+		{
+			name:  "Part number 0",
+			op:    OpUploadObjectPart,
+			req:   &UploadObjectPartRequest{Bucket: "bucket1", Key: "object.txt", UploadID: "my-upload-id"},
+			field: "PartNumber",
+		},
+		{
+			name:  "Part number 10001",
+			op:    OpUploadObjectPart,
+			req:   &UploadObjectPartRequest{Bucket: "bucket1", Key: "object.txt", PartNumber: 10001, UploadID: "my-upload-id"},
+			field: "PartNumber",
+		},
+		{
+			name:  "No upload ID",
+			op:    OpUploadObjectPart,
+			req:   &UploadObjectPartRequest{Bucket: "bucket1", Key: "object.txt", PartNumber: 1},
+			field: "UploadID",
+		},
+		{
+			name: "Part over 5 GiB",
+			op:   OpUploadObjectPart,
+			req: &UploadObjectPartRequest{
+				Bucket: "bucket1", Key: "object.txt", PartNumber: 1, UploadID: "my-upload-id",
+				Body: NewSectionBody(strings.NewReader(""), 0, 5<<30+1),
+			},
+			field: "Body",
+		},
+		{
+			name: "Short MD5",
+			op:   OpUploadObjectPart,
+			req: &UploadObjectPartRequest{
+				Bucket: "bucket1", Key: "object.txt", PartNumber: 1, UploadID: "my-upload-id",
+				Hashes: gcshash.Sums{MD5: []byte("short")},
+			},
+			field: "Hashes.MD5",
+		},
+		{
+			name: "Copy from an invalid source",
+			op:   OpUploadPartCopy,
+			req: &UploadPartCopyRequest{
+				Bucket: "bucket1", Key: "object.txt", PartNumber: 1, UploadID: "my-upload-id",
+				SourceBucket: "bucket1",
+			},
+			field: "SourceKey",
+		},
+		{
+			name: "Copy of a negative offset",
+			op:   OpUploadPartCopy,
+			req: &UploadPartCopyRequest{
+				Bucket: "bucket1", Key: "object.txt", PartNumber: 1, UploadID: "my-upload-id",
+				SourceBucket: "bucket1", SourceKey: "source.txt", SourceRange: &ByteRange{Offset: -1, Length: 1},
+			},
+			field: "SourceRange.Offset",
+		},
+		{
+			name: "Valid complete",
+			op:   OpCompleteMultipartUpload,
+			req: &CompleteMultipartUploadRequest{
+				Bucket: "bucket1", Key: "object.txt", UploadID: "my-upload-id",
+				Body: CompleteMultipartUploadBody{Parts: []CompletePart{part(1, `"a"`), part(3, `"b"`)}},
+			},
+		},
+		{
+			name: "Complete without parts",
+			op:   OpCompleteMultipartUpload,
+			req: &CompleteMultipartUploadRequest{
+				Bucket: "bucket1", Key: "object.txt", UploadID: "my-upload-id",
+			},
+			field: "Body.Parts",
+		},
+		{
+			name: "Complete out of order",
+			op:   OpCompleteMultipartUpload,
+			req: &CompleteMultipartUploadRequest{
+				Bucket: "bucket1", Key: "object.txt", UploadID: "my-upload-id",
+				Body: CompleteMultipartUploadBody{Parts: []CompletePart{part(2, `"a"`), part(1, `"b"`)}},
+			},
+			field: "Body.Parts[1].PartNumber",
+		},
+		{
+			name: "Complete without an ETag",
+			op:   OpCompleteMultipartUpload,
+			req: &CompleteMultipartUploadRequest{
+				Bucket: "bucket1", Key: "object.txt", UploadID: "my-upload-id",
+				Body: CompleteMultipartUploadBody{Parts: []CompletePart{part(1, `"a"`), part(2, "")}},
+			},
+			field: "Body.Parts[1].ETag",
+		},
+		{
+			name:  "Abort without an upload ID",
+			op:    OpAbortMultipartUpload,
+			req:   &AbortMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt"},
+			field: "UploadID",
+		},
+		{
+			name:  "List with a long prefix",
+			op:    OpListMultipartUploads,
+			req:   &ListMultipartUploadsRequest{Bucket: "bucket1", Prefix: strings.Repeat("a", 1025)},
+			field: "Prefix",
+		},
+		{
+			name:  "List parts without an upload ID",
+			op:    OpListObjectParts,
+			req:   &ListObjectPartsRequest{Bucket: "bucket1", Key: "object.txt"},
+			field: "UploadID",
+		},
+		{
+			name: "Valid patch",
+			op:   OpPatchObjectMetadata,
+			req: &PatchObjectMetadataRequest{Bucket: "bucket1", Key: "object.txt", Patch: ObjectMetadataPatch{
+				ContentType: &contentType,
+				Metadata:    map[string]string{"owner": "team"},
+			}},
+		},
+		{
+			name: "Patch with an invalid content type",
+			op:   OpPatchObjectMetadata,
+			req: &PatchObjectMetadataRequest{Bucket: "bucket1", Key: "object.txt", Patch: ObjectMetadataPatch{
+				ContentType: &badContentType,
+			}},
+			field: "Patch.ContentType",
+		},
+		{
+			name: "Patch with metadata over 8 KiB",
+			op:   OpPatchObjectMetadata,
+			req: &PatchObjectMetadataRequest{Bucket: "bucket1", Key: "object.txt", Patch: ObjectMetadataPatch{
+				Metadata: map[string]string{"a": strings.Repeat("b", 8<<10)},
+			}},
+			field: "Patch.Metadata",
+		},
+		{
+			name:  "Initiate with an empty metadata key",
+			op:    OpInitiateMultipartUpload,
+			req:   &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt", Metadata: map[string]string{"": "x"}},
+			field: "Metadata",
+		},
+		{
+			name:  "Initiate with a retention mode but no retain-until time",
+			op:    OpInitiateMultipartUpload,
+			req:   &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt", RetentionMode: "Locked"},
+			field: "RetainUntil",
+		},
+		{
+			name:  "Initiate with a retain-until time but no retention mode",
+			op:    OpInitiateMultipartUpload,
+			req:   &InitiateMultipartUploadRequest{Bucket: "bucket1", Key: "object.txt", RetainUntil: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)},
+			field: "RetentionMode",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []Option{WithStrictValidation()}
+			if tc.s3 {
+				opts = append(opts, WithS3Compatibility(S3Compatibility{}))
+			}
+			err := New(nil, opts...).validate(tc.op, tc.req)
+			if tc.field == "" {
+				if err != nil {
+					t.Errorf("got error %v, want none", err)
+				}
+				return
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("got error %v, want a *ValidationError", err)
+			}
+			if validationErr.Op != tc.op || validationErr.Field != tc.field {
+				t.Errorf("got error for %s %s, want %s %s", validationErr.Op, validationErr.Field, tc.op, tc.field)
+			}
+		})
+	}
+}
+
+func TestWithStrictValidation(t *testing.T) {
+	requests := 0
+	trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+		requests++
+		return &http.Response{StatusCode: http.StatusOK, Status: "OK", Header: http.Header{}, Body: http.NoBody}, nil
+	})
+	req := func() *UploadObjectPartRequest {
+		return &UploadObjectPartRequest{
+			Bucket: "bucket1",
+			Key:    "object.txt",
+			// Out of range.
+			PartNumber: 0,
+			UploadID:   "my-upload-id",
+			Body:       &seekableBody{Reader: strings.NewReader("part contents")},
+		}
+	}
+
+	// Without strict validation, the server decides.
+	if _, err := New(&http.Client{Transport: trans}).UploadObjectPart(context.Background(), req()); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 1 {
+		t.Fatalf("got %d requests, want 1", requests)
+	}
+
+	r := req()
+	_, err := New(&http.Client{Transport: trans}, WithStrictValidation()).UploadObjectPart(context.Background(), r)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("got error %v, want a *ValidationError", err)
+	}
+	if want := "invalid UploadObjectPart request: PartNumber must be between 1 and 10000, not 0"; err.Error() != want {
+		t.Errorf("got error %q, want %q", err, want)
+	}
+	if requests != 1 {
+		t.Errorf("an invalid request was sent")
+	}
+	if !r.Body.(*seekableBody).closed {
+		t.Error("body wasn't closed")
+	}
+}
@@ -0,0 +1,173 @@
+package multipartclient
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+)
+
+// Warmup opens up to n connections to the client's endpoint ahead of a large
+// upload, so that its first parts don't wait for DNS lookups, TCP and TLS
+// handshakes or, with NewClient, an access token. It sends n HEAD requests to
+// the endpoint at once and keeps each connection busy while other requests
+// are still opening theirs, so that none is reused by another; the responses'
+// statuses are ignored. It returns the number of connections opened, which is
+// less than n if idle connections already existed or were freed before a
+// request got one, and 1 over HTTP/2, which multiplexes requests on a
+// connection.
+//
+// n is capped at the transport's MaxConnsPerHost, if set, as further requests
+// would only wait for one of those connections. It returns an error if n is
+// negative.
+//
+// The connections outlive Warmup only if the transport keeps n idle
+// connections per host: http.Transport keeps 2 unless MaxIdleConnsPerHost is
+// set, while WithHTTPVersion(HTTP1) and NewClient keep 100. With
+// VirtualHostedStyle, requests go to a host per bucket, so only connections
+// to the endpoint's own host are opened.
+func (mpuc *MultipartClient) Warmup(ctx context.Context, n int) (int, error) {
+	if n < 0 {
+		return 0, fmt.Errorf("failed to warm up connections: negative number of connections %d", n)
+	}
+	if max := maxConnsPerHost(mpuc.hc); max > 0 && n > max {
+		n = max
+	}
+	url := mpuc.endpointURL() + "/"
+	var (
+		wg     sync.WaitGroup
+		opened atomic.Int64
+		state  = newWarmupState(n)
+		errs   = make([]error, n)
+	)
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			var asked, once sync.Once
+			done := func() { once.Do(state.done) }
+			trace := &httptrace.ClientTrace{
+				GetConn: func(string) {
+					asked.Do(func() { state.update(func() { state.waiting++ }) })
+				},
+				ConnectStart:      func(string, string) { state.update(func() { state.opening++ }) },
+				ConnectDone:       func(string, string, error) { state.update(func() { state.opening-- }) },
+				TLSHandshakeStart: func() { state.update(func() { state.opening++ }) },
+				TLSHandshakeDone:  func(tls.ConnectionState, error) { state.update(func() { state.opening-- }) },
+				GotConn: func(info httptrace.GotConnInfo) {
+					if !info.Reused {
+						opened.Add(1)
+					}
+					done()
+					// Hold the connection while other requests are opening
+					// theirs. The response to a HEAD request has no body, so
+					// the connection would otherwise be free for another
+					// request as soon as it arrives.
+					select {
+					case <-state.released:
+					case <-ctx.Done():
+					}
+				},
+			}
+			resp, err := mpuc.warmupRequest(httptrace.WithClientTrace(ctx, trace), url)
+			done()
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}(i)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return int(opened.Load()), fmt.Errorf("failed to warm up connections to %s: %w", url, err)
+	}
+	return int(opened.Load()), nil
+}
+
+// warmupState tracks the requests of a Warmup, to release the connections
+// they hold once no more are being opened.
+type warmupState struct {
+	n  int
+	mu sync.Mutex
+	// waiting is the number of requests that have asked the transport for a
+	// connection, and finished the number that got one or failed.
+	waiting, finished int
+	// opening is the number of dials and TLS handshakes in progress.
+	opening int
+	// released is closed once every request got a connection or failed, or
+	// every request asked for one and none is being opened, so the rest are
+	// waiting for a connection the held ones would otherwise never free,
+	// such as under the transport's MaxConnsPerHost.
+	released chan struct{}
+}
+
+func newWarmupState(n int) *warmupState {
+	s := &warmupState{n: n, released: make(chan struct{})}
+	if n == 0 {
+		close(s.released)
+	}
+	return s
+}
+
+// update applies f to the state and releases the held connections if no more
+// are expected.
+func (s *warmupState) update(f func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f()
+	select {
+	case <-s.released:
+		return
+	default:
+	}
+	if s.finished == s.n || s.waiting == s.n && s.opening == 0 && s.finished > 0 {
+		close(s.released)
+	}
+}
+
+// done records that a request got a connection or failed.
+func (s *warmupState) done() {
+	s.update(func() { s.finished++ })
+}
+
+// maxConnsPerHost returns the MaxConnsPerHost of the transport of hc, or 0 if
+// it isn't an *http.Transport.
+func maxConnsPerHost(hc *http.Client) int {
+	rt := http.DefaultTransport
+	if hc != nil && hc.Transport != nil {
+		rt = hc.Transport
+	}
+	if trans, ok := rt.(*http.Transport); ok {
+		return trans.MaxConnsPerHost
+	}
+	return 0
+}
+
+func (mpuc *MultipartClient) warmupRequest(ctx context.Context, url string) (*http.Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodHead, url, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	setContextHeaders(ctx, httpReq)
+	mpuc.sign(httpReq)
+	return mpuc.hc.Do(httpReq)
+}
+
+// endpointURL returns the URL of the endpoint the client sends requests to,
+// without a trailing slash.
+func (mpuc *MultipartClient) endpointURL() string {
+	switch {
+	case mpuc.compat != nil:
+		return mpuc.compat.Endpoint
+	case mpuc.endpoint != "":
+		return mpuc.endpoint
+	}
+	return defaultEndpoint
+}
@@ -0,0 +1,116 @@
+package multipartclient
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWarmup(t *testing.T) {
+	var conns, requests atomic.Int64
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Method != http.MethodHead || r.URL.Path != "/" {
+			t.Errorf("got request %s %s, want HEAD /", r.Method, r.URL)
+		}
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	mpuc := New(srv.Client(), WithHTTPVersion(HTTP1), WithEndpoint(srv.URL))
+	ctx := context.Background()
+	opened, err := mpuc.Warmup(ctx, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened != 4 || conns.Load() != 4 {
+		t.Errorf("got %d connections opened, %d accepted by the server, want 4", opened, conns.Load())
+	}
+
+	// The warm connections are reused.
+	opened, err = mpuc.Warmup(ctx, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened != 0 || conns.Load() != 4 {
+		t.Errorf("got %d connections opened, %d accepted by the server on the second warmup, want 0 and 4", opened, conns.Load())
+	}
+	if got := requests.Load(); got != 8 {
+		t.Errorf("got %d requests, want 8", got)
+	}
+}
+
+func TestWarmupMaxConnsPerHost(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+	trans := srv.Client().Transport.(*http.Transport).Clone()
+	trans.MaxConnsPerHost = 2
+	trans.MaxIdleConnsPerHost = 2
+	mpuc := New(&http.Client{Transport: trans}, WithEndpoint(srv.URL))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	opened, err := mpuc.Warmup(ctx, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened != 2 {
+		t.Errorf("got %d connections opened, want 2", opened)
+	}
+}
+
+// limitTransport lets at most one request at a time through to its
+// transport, like a transport with a connection limit Warmup can't see.
+type limitTransport struct {
+	sem  chan struct{}
+	base http.RoundTripper
+}
+
+func (lt limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if trace := httptrace.ContextClientTrace(req.Context()); trace != nil && trace.GetConn != nil {
+		trace.GetConn(req.URL.Host)
+	}
+	lt.sem <- struct{}{}
+	defer func() { <-lt.sem }()
+	return lt.base.RoundTrip(req)
+}
+
+func TestWarmupConnectionLimit(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+	mpuc := New(&http.Client{Transport: limitTransport{sem: make(chan struct{}, 1), base: srv.Client().Transport}}, WithEndpoint(srv.URL))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := mpuc.Warmup(ctx, 3); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWarmupInvalidCount(t *testing.T) {
+	mpuc := New(nil)
+	if _, err := mpuc.Warmup(context.Background(), -1); err == nil {
+		t.Error("got no error warming up -1 connections")
+	}
+	if opened, err := mpuc.Warmup(context.Background(), 0); opened != 0 || err != nil {
+		t.Errorf("got %d, %v warming up 0 connections, want 0, nil", opened, err)
+	}
+}
+
+func TestWarmupUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	mpuc := New(srv.Client(), WithEndpoint(srv.URL))
+	if _, err := mpuc.Warmup(context.Background(), 2); err == nil {
+		t.Error("got no error warming up connections to a closed server")
+	}
+}
@@ -0,0 +1,72 @@
+package multipartclient
+
+import (
+	"encoding/xml"
+	"io"
+	"sync"
+)
+
+// xmlBody is a request body encoded as XML while it is read, so that large
+// documents such as the part list of a 10,000-part CompleteMultipartUpload are
+// never held in memory. Every reader encodes the document again, which lets it
+// be hashed before it is sent and sent again on retry.
+type xmlBody struct {
+	v     any
+	start xml.StartElement
+	// compact leaves out the indentation.
+	compact bool
+}
+
+// WriteTo writes the encoded document to w.
+func (b xmlBody) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	var err error
+	if body, ok := b.v.(CompleteMultipartUploadBody); ok && canWriteCompleteBody(b.start) {
+		err = writeCompleteBody(cw, body, b.start, b.compact)
+	} else {
+		err = encodeXML(cw, b.v, b.start, b.compact)
+	}
+	return cw.n, err
+}
+
+// reader returns a reader of the encoded document.
+func (b xmlBody) reader() io.ReadCloser {
+	pr, pw := io.Pipe()
+	return &xmlBodyReader{body: b, pr: pr, pw: pw}
+}
+
+// xmlBodyReader encodes its body into a pipe once it is first read, so that
+// a request that is never sent doesn't leave an encoding goroutine behind.
+type xmlBodyReader struct {
+	body xmlBody
+	once sync.Once
+	pr   *io.PipeReader
+	pw   *io.PipeWriter
+}
+
+func (r *xmlBodyReader) Read(p []byte) (int, error) {
+	r.once.Do(func() {
+		go func() {
+			_, err := r.body.WriteTo(r.pw)
+			r.pw.CloseWithError(err)
+		}()
+	})
+	return r.pr.Read(p)
+}
+
+// Close stops the encoding goroutine, if any, once it next writes.
+func (r *xmlBodyReader) Close() error {
+	return r.pr.Close()
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
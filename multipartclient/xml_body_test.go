@@ -0,0 +1,85 @@
+package multipartclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestXMLBodyReader(t *testing.T) {
+	body := completeBody(3)
+	want, err := xml.MarshalIndent(body, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	xb := xmlBody{v: body, start: xml.StartElement{Name: xml.Name{Local: "CompleteMultipartUpload"}}}
+	// Every reader encodes the whole document.
+	for i := 0; i < 2; i++ {
+		r := xb.reader()
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Close()
+		if string(got) != string(want) {
+			t.Errorf("reader %d: got %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestXMLBodyReaderClosed(t *testing.T) {
+	xb := xmlBody{v: completeBody(1000), start: xml.StartElement{Name: xml.Name{Local: "CompleteMultipartUpload"}}}
+	r := xb.reader()
+	if _, err := r.Read(make([]byte, 10)); err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	if _, err := r.Read(make([]byte, 10)); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("got error %v reading after close, want %v", err, io.ErrClosedPipe)
+	}
+}
+
+func TestCompleteMultipartUploadStreamedBody(t *testing.T) {
+	body := completeBody(3)
+	want, err := xml.MarshalIndent(body, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(want)
+
+	var bodies []string
+	trans := funcTransport(func(req *http.Request) (*http.Response, error) {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		bodies = append(bodies, string(b))
+		if got, want := req.Header.Get(contentSHA256Header), hex.EncodeToString(sum[:]); got != want {
+			t.Errorf("got payload hash %s, want %s", got, want)
+		}
+		if len(bodies) == 1 {
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable", Body: toBody("")}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Status: "OK", Body: http.NoBody}, nil
+	})
+	mpuc := New(&http.Client{Transport: trans},
+		WithContentSHA256(ContentSHA256Auto),
+		WithRetry(RetryConfig{Backoff: testBackoff, Policy: RetryAlways}))
+	_, err = mpuc.CompleteMultipartUpload(context.Background(), &CompleteMultipartUploadRequest{
+		Bucket:   "bucket1",
+		Key:      "object.txt",
+		UploadID: "my-upload-id",
+		Body:     body,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 2 || bodies[0] != string(want) || bodies[1] != string(want) {
+		t.Errorf("got request bodies %q, want %q twice", bodies, want)
+	}
+}